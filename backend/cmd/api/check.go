@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/config"
+	"github.com/naratel/naratel-box/backend/internal/handler"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+	"github.com/naratel/naratel-box/backend/migrations"
+)
+
+// minJWTSecretLen is the shortest JWT_SECRET the check accepts: 32 bytes, the size of
+// the HMAC-SHA256 key that signs tokens.
+const minJWTSecretLen = 32
+
+// placeholderSecrets are JWT_SECRET values copied from examples.
+var placeholderSecrets = []string{"change-this-to-a-long-random-secret", "secret", "changeme", "change-me"}
+
+// checkResult is one step of the self-check.
+type checkResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"` // an earlier step it depends on failed
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// checkReport is the output of `serve --check`.
+type checkReport struct {
+	OK        bool           `json:"ok"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Checks    []*checkResult `json:"checks"`
+}
+
+// run records the outcome of step as check name. A step returns a detail to report
+// on success, or an error.
+func (r *checkReport) run(name string, step func() (string, error)) bool {
+	start := time.Now()
+	detail, err := step()
+	res := &checkResult{Name: name, OK: err == nil, Detail: detail, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Detail = err.Error()
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, res)
+	return res.OK
+}
+
+func (r *checkReport) skip(names ...string) {
+	for _, name := range names {
+		r.Checks = append(r.Checks, &checkResult{Name: name, Skipped: true})
+	}
+	r.OK = false
+}
+
+// runCheck validates the configuration, the database and its schema version, S3
+// access and the JWT secret without serving anything, prints a JSON report to stdout
+// and returns the exit code: 0 when every check passed, 1 otherwise.
+func runCheck() int {
+	report := &checkReport{OK: true, CheckedAt: time.Now().UTC()}
+	defer func() {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	}()
+
+	var cfg *config.Config
+	if !report.run("config", func() (string, error) {
+		var err error
+		if cfg, err = loadConfig(); err != nil {
+			return "", err
+		}
+		return "", errors.Join(configErrors(cfg)...)
+	}) && cfg == nil {
+		report.skip("jwt_secret", "database", "migrations", "s3")
+		return 1
+	}
+
+	report.run("jwt_secret", func() (string, error) {
+		return "", checkJWTSecret(cfg.JWTSecret)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var db *pgxpool.Pool
+	if report.run("database", func() (string, error) {
+		pool, err := repository.NewPool(ctx, cfg.DSN(), repository.QueryLimits{}, repository.ConnLabels{ApplicationName: cfg.DBApplicationName}, nil)
+		if err != nil {
+			return "", err
+		}
+		db = pool
+		return fmt.Sprintf("connected to %s:%s/%s", cfg.DBHost, cfg.DBPort, cfg.DBName), nil
+	}) {
+		defer db.Close()
+		report.run("migrations", func() (string, error) {
+			return checkMigrations(ctx, db)
+		})
+	} else {
+		report.skip("migrations")
+	}
+
+	report.run("s3", func() (string, error) {
+		return checkS3(ctx, cfg)
+	})
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// loadConfig loads the configuration, reporting a missing required variable as an
+// error instead of a panic.
+func loadConfig() (cfg *config.Config, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return config.Load()
+}
+
+// configErrors runs the validations main applies at startup that need no database.
+func configErrors(cfg *config.Config) []error {
+	var errs []error
+	fail := func(setting string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", setting, err))
+		}
+	}
+	if cfg.ChaosEnabled && cfg.AppEnv == "production" {
+		errs = append(errs, errors.New("CHAOS_ENABLED must not be set in production"))
+	}
+	chunking, err := block.ParseChunking(cfg.BlockChunking)
+	fail("BLOCK_CHUNKING", err)
+	if chunking == block.ChunkingFastCDC {
+		_, err = block.FastCDC(cfg.BlockCDCMinKB*1024, cfg.BlockCDCAvgKB*1024, cfg.BlockCDCMaxKB*1024)
+		fail("BLOCK_CDC_*_KB", err)
+	}
+	_, err = block.ParseDedupScope(cfg.DedupScope)
+	fail("DEDUP_SCOPE", err)
+	_, err = block.ParseCompression(cfg.BlockCompression)
+	fail("BLOCK_COMPRESSION", err)
+	if cfg.BlockWorkers < 1 {
+		errs = append(errs, fmt.Errorf("BLOCK_WORKERS must be at least 1, got %d", cfg.BlockWorkers))
+	}
+	_, err = proxy.NewResolver(cfg.TrustedProxies, cfg.IPv6PrefixLength)
+	fail("TRUSTED_PROXIES", err)
+	sameSite, err := auth.ParseSameSite(cfg.CookieSameSite)
+	fail("COOKIE_SAMESITE", err)
+	if err == nil && sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		errs = append(errs, errors.New("COOKIE_SAMESITE=none requires COOKIE_SECURE=true"))
+	}
+	_, err = auth.ParseSessionMode(cfg.SessionMode)
+	fail("SESSION_MODE", err)
+	_, err = handler.NewUploadPolicy(int64(cfg.UploadMaxFileMB)*1024*1024, cfg.UploadAllowedTypes, cfg.UploadDeniedTypes)
+	fail("UPLOAD_*_TYPES", err)
+	_, err = handler.ParseScanGate(cfg.ShareScanGate)
+	fail("SHARE_SCAN_GATE", err)
+	return errs
+}
+
+// checkJWTSecret refuses secrets short or well-known enough to be guessed, since
+// anyone who knows the secret can sign tokens for any user.
+func checkJWTSecret(secret string) error {
+	for _, p := range placeholderSecrets {
+		if strings.EqualFold(secret, p) {
+			return errors.New("JWT_SECRET is a placeholder value; generate a random one (e.g. openssl rand -hex 32)")
+		}
+	}
+	if len(secret) < minJWTSecretLen {
+		return fmt.Errorf("JWT_SECRET is %d bytes; use at least %d random bytes", len(secret), minJWTSecretLen)
+	}
+	if strings.Count(secret, secret[:1]) == len(secret) {
+		return errors.New("JWT_SECRET repeats a single character")
+	}
+	return nil
+}
+
+// checkMigrations compares the schema version of the database with the newest
+// migration this build ships.
+func checkMigrations(ctx context.Context, db *pgxpool.Pool) (string, error) {
+	version, dirty, err := repository.MigrationVersion(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	latest := migrations.Latest()
+	switch {
+	case dirty:
+		return "", fmt.Errorf("migration %d failed half-way (dirty); fix the schema and force the version", version)
+	case version < latest:
+		return "", fmt.Errorf("schema at version %d, this build needs %d; run the pending migrations", version, latest)
+	case version > latest:
+		return "", fmt.Errorf("schema at version %d is newer than this build (%d)", version, latest)
+	}
+	return fmt.Sprintf("schema at version %d", version), nil
+}
+
+// checkS3 writes, reads back and deletes a probe object, which needs the same
+// permissions as storing and collecting blocks.
+func checkS3(ctx context.Context, cfg *config.Config) (string, error) {
+	s3, err := storage.NewS3Client(cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Region, cfg.S3Bucket, cfg.S3ForcePathStyle)
+	if err != nil {
+		return "", err
+	}
+	probe := make([]byte, 32)
+	if _, err := rand.Read(probe); err != nil {
+		return "", err
+	}
+	key := ".selfcheck/" + hex.EncodeToString(probe[:8])
+
+	if err := s3.PutObject(ctx, key, bytes.NewReader(probe), int64(len(probe))); err != nil {
+		return "", fmt.Errorf("put probe: %w", err)
+	}
+	body, err := s3.GetObject(ctx, key)
+	if err != nil {
+		_ = s3.DeleteObject(ctx, key)
+		return "", fmt.Errorf("get probe: %w", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err == nil && !bytes.Equal(got, probe) {
+		err = errors.New("content differs from what was written")
+	}
+	if err != nil {
+		_ = s3.DeleteObject(ctx, key)
+		return "", fmt.Errorf("read probe: %w", err)
+	}
+	if err := s3.DeleteObject(ctx, key); err != nil {
+		return "", fmt.Errorf("delete probe %s: %w", key, err)
+	}
+	return fmt.Sprintf("put/get/delete of %s in bucket %s succeeded", key, cfg.S3Bucket), nil
+}
+
+// serveFlags parses the command line: `[serve] [--check]`.
+func serveFlags(args []string) (check bool) {
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	}
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	checkFlag := flags.Bool("check", false, "validate config, database, migrations, S3 access and the JWT secret, print a JSON report and exit (non-zero on failure)")
+	_ = flags.Parse(args)
+	return *checkFlag
+}
@@ -3,6 +3,8 @@
 // @title          Naratel Box API
 // @version        1.0
 // @description    Block-level deduplicated file storage with JWT authentication and QNAP S3 backend.
+// @description    Successful JSON responses are wrapped in an envelope, {"data": ..., "request_id": ...} plus
+// @description    "pagination" for lists, as their schemas show; error bodies are sent flat with request_id.
 // @termsOfService http://naratel.id/terms
 //
 // @contact.name   Naratel Dev Team
@@ -26,6 +28,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
@@ -34,29 +37,73 @@ import (
 	"github.com/go-chi/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
 
+	"github.com/naratel/naratel-box/backend/internal/admin"
+	"github.com/naratel/naratel-box/backend/internal/audio"
+	"github.com/naratel/naratel-box/backend/internal/audit"
 	"github.com/naratel/naratel-box/backend/internal/auth"
 	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/changes"
+	"github.com/naratel/naratel-box/backend/internal/chaos"
 	"github.com/naratel/naratel-box/backend/internal/config"
 	"github.com/naratel/naratel-box/backend/internal/handler"
+	"github.com/naratel/naratel-box/backend/internal/i18n"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
 	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/mailer"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/mount"
+	"github.com/naratel/naratel-box/backend/internal/pdfrender"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/push"
+	"github.com/naratel/naratel-box/backend/internal/ratelimit"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/security"
+	"github.com/naratel/naratel-box/backend/internal/service"
 	"github.com/naratel/naratel-box/backend/internal/storage"
 
 	_ "github.com/naratel/naratel-box/backend/docs" // generated by swag
 )
 
 func main() {
+	if serveFlags(os.Args[1:]) {
+		os.Exit(runCheck())
+	}
+
 	// ── Config ────────────────────────────────────────────────────────────────
 	cfg, err := config.Load()
 	if err != nil {
 		logger.Fatalf("config.Load: %v", err)
 	}
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		logger.Fatalf("Invalid LOG_LEVEL: %v", err)
+	}
 
 	// ── Database ──────────────────────────────────────────────────────────────
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	pool, err := repository.NewPool(ctx, cfg.DSN())
+	// Chaos mode (test environments): inject S3 errors, latency and dropped DB connections.
+	var faults *chaos.Injector
+	if cfg.ChaosEnabled {
+		if cfg.AppEnv == "production" {
+			logger.Fatalf("CHAOS_ENABLED must not be set in production")
+		}
+		faults = &chaos.Injector{
+			S3ErrorPct: cfg.ChaosS3ErrorPct,
+			LatencyPct: cfg.ChaosLatencyPct,
+			Latency:    time.Duration(cfg.ChaosLatencyMS) * time.Millisecond,
+			DBDropPct:  cfg.ChaosDBDropPct,
+		}
+		logger.Infof("Chaos mode enabled: s3_error=%d%% latency=%d%%/%dms db_drop=%d%%",
+			cfg.ChaosS3ErrorPct, cfg.ChaosLatencyPct, cfg.ChaosLatencyMS, cfg.ChaosDBDropPct)
+	}
+
+	queryLimits := repository.QueryLimits{
+		Timeout:       time.Duration(cfg.DBQueryTimeoutMS) * time.Millisecond,
+		SlowThreshold: time.Duration(cfg.DBSlowQueryMS) * time.Millisecond,
+	}
+	connLabels := repository.ConnLabels{ApplicationName: cfg.DBApplicationName, TagRequests: cfg.DBTagRequests}
+	pool, err := repository.NewPool(ctx, cfg.DSN(), queryLimits, connLabels, faults)
 	if err != nil {
 		logger.Fatalf("Database connection failed: %v", err)
 	}
@@ -75,79 +122,600 @@ func main() {
 	if err != nil {
 		logger.Fatalf("S3 client init failed: %v", err)
 	}
+	s3Client.InjectFaults(faults)
 	logger.Infof("S3 client ready (endpoint=%s, bucket=%s)", cfg.S3Endpoint, cfg.S3Bucket)
 
 	// ── Repositories ──────────────────────────────────────────────────────────
-	userRepo      := repository.NewUserRepository(pool)
-	blockRepo     := repository.NewBlockRepository(pool)
-	fileRepo      := repository.NewFileRepository(pool)
-	folderRepo    := repository.NewFolderRepository(pool)
-	shareLinkRepo := repository.NewShareLinkRepository(pool)
+	userRepo       := repository.NewUserRepository(pool)
+	blockRepo      := repository.NewBlockRepository(pool)
+	fileRepo       := repository.NewFileRepository(pool)
+	folderRepo     := repository.NewFolderRepository(pool)
+	pinnedRepo     := repository.NewQuickAccessRepository(pool)
+	ruleRepo       := repository.NewUploadRuleRepository(pool)
+	dropRepo       := repository.NewUploadRequestRepository(pool)
+	shareLinkRepo  := repository.NewShareLinkRepository(pool)
+	jobRepo        := repository.NewJobRepository(pool)
+	fileStatsRepo  := repository.NewFileStatsRepository(pool)
+	deletionRepo   := repository.NewS3DeletionRepository(pool)
+	notifRepo      := repository.NewNotificationRepository(pool)
+	abuseRepo      := repository.NewAbuseReportRepository(pool)
+	deviceRepo     := repository.NewPushDeviceRepository(pool)
+	sessionRepo    := repository.NewSessionRepository(pool)
+	refreshRepo    := repository.NewRefreshTokenRepository(pool)
+	securityRepo   := repository.NewSecurityEventRepository(pool)
+	uploadRepo     := repository.NewUploadSessionRepository(pool)
+	mountRepo      := repository.NewMountRepository(pool)
+	folderLinkRepo := repository.NewFolderShareLinkRepository(pool)
+	snapshotRepo   := repository.NewUsageSnapshotRepository(pool)
+	planRepo       := repository.NewPlanRepository(pool)
+	egressRepo     := repository.NewEgressUsageRepository(pool)
+	auditRepo      := repository.NewAdminAuditRepository(pool)
+	inviteRepo     := repository.NewUserInviteRepository(pool)
+	waveformRepo   := repository.NewWaveformRepository(pool)
+	checksumRepo   := repository.NewChecksumRepository(pool)
+
+	if len(cfg.AdminEmails) > 0 {
+		if n, err := userRepo.PromoteAdmins(ctx, cfg.AdminEmails); err != nil {
+			logger.Fatalf("Failed to promote admins: %v", err)
+		} else if n > 0 {
+			logger.Infof("Granted admin role to %d user(s) from ADMIN_EMAILS", n)
+		}
+	}
+
+	// ── Background Jobs ───────────────────────────────────────────────────────
+	if n, err := jobRepo.FailInterrupted(ctx); err != nil {
+		logger.Fatalf("Failed to reset interrupted jobs: %v", err)
+	} else if n > 0 {
+		logger.Infof("Marked %d interrupted job(s) as failed", n)
+	}
+	jobRunner := jobs.NewRunner(jobRepo)
+
+	pusher, err := push.New(push.Config{
+		VAPIDPrivateKey:    cfg.VAPIDPrivateKey,
+		VAPIDSubject:       cfg.VAPIDSubject,
+		FCMCredentialsFile: cfg.FCMCredentialsFile,
+	})
+	if err != nil {
+		logger.Fatalf("Push init failed: %v", err)
+	}
+
+	scheduler := jobs.NewScheduler()
+	cleanup := jobs.NewCleanup(cfg.MaintenanceDryRun)
+	shareLinkRetention := time.Duration(cfg.ShareLinkRetentionHours) * time.Hour
+	scheduler.Every(jobs.TaskPurgeShareLinks,
+		time.Duration(cfg.ShareLinkCleanupIntervalMinutes)*time.Minute,
+		cleanup.Register(jobs.TaskPurgeShareLinks,
+			jobs.PreviewPurgeExpiredShareLinks(shareLinkRepo, shareLinkRetention),
+			jobs.PurgeExpiredShareLinks(shareLinkRepo, shareLinkRetention)))
+	trashRetention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+	scheduler.Every(jobs.TaskPurgeTrash,
+		time.Duration(cfg.TrashPurgeIntervalMinutes)*time.Minute,
+		cleanup.Register(jobs.TaskPurgeTrash,
+			jobs.PreviewPurgeTrash(fileRepo, trashRetention),
+			jobs.PurgeTrash(fileRepo, trashRetention)))
+	scheduler.Every("expire-items",
+		time.Duration(cfg.ExpiryIntervalMinutes)*time.Minute,
+		jobs.ExpireItems(fileRepo, folderRepo, notifRepo, jobRunner, time.Duration(cfg.ExpiryNoticeHours)*time.Hour))
+	bundleStore, err := jobs.NewBundleStore(cfg.DownloadBundleDir, time.Duration(cfg.DownloadBundleTTLMinutes)*time.Minute)
+	if err != nil {
+		logger.Fatalf("Download bundle store init failed: %v", err)
+	}
+	scheduler.Every("purge-download-bundles",
+		time.Duration(cfg.DownloadBundleTTLMinutes)*time.Minute/4,
+		bundleStore.PurgeExpired())
+	scheduler.Every(jobs.TaskCollectBlockGarbage,
+		time.Duration(cfg.BlockGCIntervalMinutes)*time.Minute,
+		cleanup.Register(jobs.TaskCollectBlockGarbage,
+			jobs.PreviewBlockGarbage(blockRepo),
+			jobs.CollectBlockGarbage(blockRepo)))
+	scheduler.Every(jobs.TaskProcessS3Deletions,
+		time.Duration(cfg.S3DeletionIntervalSeconds)*time.Second,
+		cleanup.Register(jobs.TaskProcessS3Deletions,
+			jobs.PreviewProcessS3Deletions(deletionRepo),
+			jobs.ProcessS3Deletions(deletionRepo, s3Client, cfg.S3DeletionMaxAttempts)))
+	scheduler.Every("dispatch-push",
+		time.Duration(cfg.PushIntervalSeconds)*time.Second,
+		jobs.DispatchPush(notifRepo, deviceRepo, userRepo, pusher))
+	scheduler.Every("purge-expired-sessions", time.Hour, jobs.PurgeExpiredSessions(sessionRepo))
+	scheduler.Every("purge-refresh-tokens", time.Hour, jobs.PurgeExpiredRefreshTokens(refreshRepo))
+	scheduler.Every(jobs.TaskPurgeUploadSessions, time.Hour,
+		cleanup.Register(jobs.TaskPurgeUploadSessions,
+			jobs.PreviewPurgeExpiredUploadSessions(uploadRepo),
+			jobs.PurgeExpiredUploadSessions(uploadRepo)))
+	scheduler.Every("reconcile-storage-usage",
+		time.Duration(cfg.UsageReconcileIntervalHours)*time.Hour,
+		jobs.ReconcileStorageUsage(userRepo))
+	scheduler.Every("snapshot-storage-usage",
+		time.Duration(cfg.UsageSnapshotIntervalHours)*time.Hour,
+		jobs.SnapshotStorageUsage(snapshotRepo, time.Duration(cfg.UsageSnapshotRetentionDays)*24*time.Hour))
+	scheduler.Every("purge-security-events", 24*time.Hour,
+		jobs.PurgeSecurityEvents(securityRepo, time.Duration(cfg.SecurityLogRetentionDays)*24*time.Hour))
+	var audioAnalyzer *audio.Analyzer
+	if cfg.AudioFFmpeg != "" && cfg.AudioFFprobe != "" {
+		audioAnalyzer, err = audio.NewAnalyzer(cfg.AudioFFmpeg, cfg.AudioFFprobe)
+		if err != nil {
+			logger.Infof("Audio analysis disabled: %v", err)
+		}
+	}
+	if audioAnalyzer != nil {
+		scheduler.Every(jobs.TaskAnalyzeAudio,
+			time.Duration(cfg.AudioAnalyzeIntervalSeconds)*time.Second,
+			jobs.AnalyzeAudio(waveformRepo, fileRepo, blockRepo, s3Client, audioAnalyzer, int64(cfg.AudioAnalyzeMaxMB)*1024*1024))
+	}
+	scheduler.Start()
 
 	// ── Block Processor ───────────────────────────────────────────────────────
-	processor := block.NewProcessor(cfg.BlockSizeBytes(), blockRepo, s3Client)
+	const mb = 1024 * 1024
+	blockPolicy := block.Policy{
+		SmallBlockSize:   cfg.BlockSizeSmallMB * mb,
+		DefaultBlockSize: cfg.BlockSizeBytes(),
+		LargeBlockSize:   cfg.BlockSizeLargeMB * mb,
+		SmallFileBelow:   int64(cfg.BlockSmallFileMB) * mb,
+		LargeFileFrom:    int64(cfg.BlockLargeFileMB) * mb,
+	}
+	chunking, err := block.ParseChunking(cfg.BlockChunking)
+	if err != nil {
+		logger.Fatalf("Invalid BLOCK_CHUNKING: %v", err)
+	}
+	if chunking == block.ChunkingFastCDC {
+		blockPolicy.CDC, err = block.FastCDC(cfg.BlockCDCMinKB*1024, cfg.BlockCDCAvgKB*1024, cfg.BlockCDCMaxKB*1024)
+		if err != nil {
+			logger.Fatalf("Invalid BLOCK_CDC_*_KB: %v", err)
+		}
+	}
+	dedupScope, err := block.ParseDedupScope(cfg.DedupScope)
+	if err != nil {
+		logger.Fatalf("Invalid DEDUP_SCOPE: %v", err)
+	}
+	blockKeys := block.NewKeySigner(cfg.BlockKeySecret)
+	compression, err := block.ParseCompression(cfg.BlockCompression)
+	if err != nil {
+		logger.Fatalf("Invalid BLOCK_COMPRESSION: %v", err)
+	}
+	processor := block.NewProcessor(blockPolicy, dedupScope, blockKeys, compression, blockRepo, s3Client)
+	if err := processor.SetWorkers(cfg.BlockWorkers); err != nil {
+		logger.Fatalf("Invalid BLOCK_WORKERS: %v", err)
+	}
+	uploadLimiter := block.NewLimiter(cfg.UploadMaxConcurrent, cfg.UploadQueueSize,
+		time.Duration(cfg.UploadQueueTimeoutSeconds)*time.Second)
+
+	var shareCache *block.FileCache
+	if cfg.ShareCacheMaxMB > 0 {
+		shareCache, err = block.NewFileCache(cfg.ShareCacheDir, int64(cfg.ShareCacheMaxMB)*mb, int64(cfg.ShareCacheFileMaxMB)*mb)
+		if err != nil {
+			logger.Fatalf("Share cache init failed: %v", err)
+		}
+	}
+
+	var pdfRenderer *pdfrender.Renderer
+	if cfg.PDFRenderer != "" {
+		pdfRenderer, err = pdfrender.New(cfg.PDFRenderer, cfg.PDFRenderConcurrency, int64(cfg.PDFRenderMaxMB)*mb, int64(cfg.PDFPageCacheMB)*mb)
+		if err != nil {
+			logger.Infof("PDF page rendering disabled: %v", err)
+		}
+	}
+
+	// ── Change Notifications ──────────────────────────────────────────────────
+	// File and folder changes from every replica arrive through Postgres NOTIFY.
+	changeHub := changes.NewHub(pool)
+	if shareCache != nil {
+		changeHub.Subscribe(func(c changes.Change) {
+			if c.Table == "files" && c.Op == "delete" {
+				shareCache.Forget(c.ID)
+			}
+		})
+	}
+	changeHub.Start()
+
+	// ── Mail ──────────────────────────────────────────────────────────────────
+	mail, err := mailer.New(mailer.Config{
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+		SMTPTLS:      cfg.SMTPTLS,
+		From:         cfg.MailFrom,
+		LogOnly:      cfg.MailLogOnly,
+		Brand:        cfg.BrandName,
+		BaseURL:      cfg.PublicBaseURL,
+		QueueSize:    cfg.MailQueueSize,
+		MaxAttempts:  cfg.MailMaxAttempts,
+	})
+	if err != nil {
+		logger.Fatalf("Mailer init failed: %v", err)
+	}
+	mail.Start()
+
+	// ── Security Log ──────────────────────────────────────────────────────────
+	security.Start(securityRepo.Insert, cfg.SecurityLogQueueSize)
+
+	// ── Reverse Proxy ─────────────────────────────────────────────────────────
+	proxyResolver, err := proxy.NewResolver(cfg.TrustedProxies, cfg.IPv6PrefixLength)
+	if err != nil {
+		logger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// ── Services ──────────────────────────────────────────────────────────────
+	featureService, err := service.NewFeatureService(planRepo, cfg.FeaturesDisabled)
+	if err != nil {
+		logger.Fatalf("Invalid FEATURES_DISABLED: %v", err)
+	}
+	egressService, err := service.NewEgressService(egressRepo, planRepo, cfg.EgressOverLimit, cfg.EgressThrottleKBps)
+	if err != nil {
+		logger.Fatalf("Invalid EGRESS_OVER_LIMIT: %v", err)
+	}
+	accounting, err := service.NewAccounting(cfg.UsageAccounting, userRepo, blockRepo)
+	if err != nil {
+		logger.Fatalf("Invalid USAGE_ACCOUNTING: %v", err)
+	}
+	planService   := service.NewPlanService(planRepo, userRepo, accounting)
+	fileService   := service.NewFileService(processor, fileRepo, folderRepo, blockRepo, checksumRepo, planService, scheduler)
+	folderService := service.NewFolderService(folderRepo, cfg.FolderMaxDepth, cfg.FolderMaxChildren)
+	shareService  := service.NewShareService(shareLinkRepo, folderLinkRepo, folderRepo, featureService)
 
 	// ── Handlers ──────────────────────────────────────────────────────────────
-	authHandler     := handler.NewAuthHandler(userRepo, cfg.JWTSecret, cfg.JWTExpiryHours)
-	uploadHandler   := handler.NewUploadHandler(fileRepo, processor)
-	downloadHandler := handler.NewDownloadHandler(fileRepo, blockRepo, s3Client)
-	folderHandler   := handler.NewFolderHandler(folderRepo, fileRepo)
-	shareHandler    := handler.NewShareHandler(shareLinkRepo, fileRepo, blockRepo, s3Client)
+	sameSite, err := auth.ParseSameSite(cfg.CookieSameSite)
+	if err != nil {
+		logger.Fatalf("Invalid COOKIE_SAMESITE: %v", err)
+	}
+	if sameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		logger.Fatalf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+	}
+	cookieConfig := auth.CookieConfig{Domain: cfg.CookieDomain, Secure: cfg.CookieSecure, SameSite: sameSite}
+	sessionMode, err := auth.ParseSessionMode(cfg.SessionMode)
+	if err != nil {
+		logger.Fatalf("Invalid SESSION_MODE: %v", err)
+	}
+	uploadPolicy, err := handler.NewUploadPolicy(int64(cfg.UploadMaxFileMB)*1024*1024, cfg.UploadAllowedTypes, cfg.UploadDeniedTypes)
+	if err != nil {
+		logger.Fatalf("Invalid upload restrictions: %v", err)
+	}
+	scanGate, err := handler.ParseScanGate(cfg.ShareScanGate)
+	if err != nil {
+		logger.Fatalf("Invalid SHARE_SCAN_GATE: %v", err)
+	}
+	mountReader, err := mount.NewReader(s3Client, cfg.MountLocalRoots)
+	if err != nil {
+		logger.Fatalf("Invalid MOUNT_LOCAL_ROOTS: %v", err)
+	}
+	previewPolicy   := handler.NewPreviewPolicy(cfg.PreviewInlineTypes, int64(cfg.PreviewTextMaxKB)*1024)
+	loginLimiter    := ratelimit.New("login_failures", cfg.LoginMaxFailures, time.Duration(cfg.LoginFailureWindowMinutes)*time.Minute)
+	authHandler     := handler.NewAuthHandler(userRepo, sessionRepo, refreshRepo, cfg.JWTSecret,
+		time.Duration(cfg.AccessTokenTTLMinutes)*time.Minute, time.Duration(cfg.RefreshTokenTTLHours)*time.Hour, sessionMode, time.Duration(cfg.SessionTTLHours)*time.Hour, cookieConfig, loginLimiter)
+	tosHandler      := handler.NewTOSHandler(userRepo, cfg.TOSVersion, cfg.TOSURL)
+	prefsHandler    := handler.NewPreferencesHandler(userRepo)
+	csrfHandler     := handler.NewCSRFHandler(cookieConfig)
+	uploadHandler   := handler.NewUploadHandler(fileService, fileRepo, fileStatsRepo, ruleRepo, processor, uploadLimiter, uploadPolicy,
+		uploadRepo, time.Duration(cfg.UploadSessionTTLHours)*time.Hour)
+	downloadHandler := handler.NewDownloadHandler(fileRepo, blockRepo, fileStatsRepo, s3Client, previewPolicy, egressService, fileService)
+	pdfPageHandler  := handler.NewPDFPageHandler(fileRepo, blockRepo, s3Client, pdfRenderer, int64(cfg.PDFRenderMaxMB)*1024*1024)
+	checksumHandler := handler.NewChecksumHandler(fileRepo, blockRepo, checksumRepo, s3Client, jobRunner)
+	dupHandler      := handler.NewNearDuplicateHandler(fileRepo)
+	diffHandler     := handler.NewFileDiffHandler(fileRepo, blockRepo)
+	waveformHandler := handler.NewWaveformHandler(fileRepo, blockRepo, shareLinkRepo, waveformRepo, scheduler,
+		audioAnalyzer != nil && cfg.AudioAnalyzeIntervalSeconds > 0, int64(cfg.AudioAnalyzeMaxMB)*1024*1024)
+	folderHandler   := handler.NewFolderHandler(folderService, folderRepo, fileRepo, jobRunner)
+	webdavHandler   := handler.NewWebDAVHandler("/dav", uploadHandler, downloadHandler, folderHandler)
+	shareHandler    := handler.NewShareHandler(shareService, shareLinkRepo, folderLinkRepo, fileRepo, folderRepo, blockRepo, fileStatsRepo, s3Client, previewPolicy, shareCache, scanGate, egressService,
+		fileService, notifRepo, uploadLimiter, uploadPolicy, int64(cfg.FileDropMaxMB)*1024*1024, cfg.PublicBaseURL, cfg.BrandName)
+	snippetHandler  := handler.NewSnippetHandler(fileService, shareHandler, cfg.SnippetMaxKB*1024)
+	reportHandler   := handler.NewDataReportHandler(userRepo, fileRepo, folderRepo, shareLinkRepo, jobRepo, jobRunner)
+	trashHandler    := handler.NewTrashHandler(fileRepo, fileService)
+	pinHandler      := handler.NewQuickAccessHandler(pinnedRepo, folderRepo)
+	dropHandler     := handler.NewUploadRequestHandler(fileService, dropRepo, folderRepo, notifRepo, uploadLimiter, uploadPolicy,
+		int64(cfg.FileDropMaxMB)*1024*1024, cfg.PublicBaseURL)
+	ruleHandler     := handler.NewUploadRuleHandler(ruleRepo, folderRepo)
+	jobHandler      := handler.NewJobHandler(jobRepo)
+	eventHandler    := handler.NewEventHandler(changeHub)
+	notifHandler    := handler.NewNotificationHandler(notifRepo)
+	pushHandler     := handler.NewPushHandler(deviceRepo, pusher)
+	bundleHandler   := handler.NewDownloadBundleHandler(fileRepo, blockRepo, jobRepo, s3Client, jobRunner, bundleStore, egressService,
+		cfg.DownloadBundleMaxFiles, int64(cfg.DownloadBundleMaxMB)*1024*1024)
+	adminHandler    := handler.NewAdminHandler(blockRepo, fileRepo, jobRepo, deletionRepo, jobRunner, cleanup, s3Client, blockKeys)
+	abuseHandler    := handler.NewAbuseReportHandler(shareLinkRepo, abuseRepo, notifRepo)
+	securityHandler := handler.NewSecurityEventHandler(securityRepo)
+	userAdmHandler  := handler.NewAdminUserHandler(userRepo)
+	mountHandler    := handler.NewMountHandler(mountRepo, mountReader, previewPolicy, egressService)
+	usageHandler    := handler.NewUsageHandler(snapshotRepo, userRepo, planService, egressService)
+	planHandler     := handler.NewPlanHandler(planService, planRepo, userRepo)
+	featureHandler  := handler.NewFeatureHandler(featureService)
+	auditHandler    := handler.NewAdminAuditHandler(auditRepo)
+	importHandler   := handler.NewUserImportHandler(userRepo, inviteRepo, planRepo, mail, cfg.PublicBaseURL,
+		time.Duration(cfg.InviteTTLHours)*time.Hour)
+	inviteHandler   := handler.NewInviteHandler(inviteRepo)
+	reportLimiter   := ratelimit.New("abuse_reports", cfg.AbuseReportsPerHour, time.Hour)
+	shareLimiter    := ratelimit.New("share_requests", cfg.ShareRequestsPerMinute, time.Minute)
+	shareGuard      := ratelimit.NewMissGuard("share_token", cfg.ShareTokenMissesPerHour, time.Hour)
+
+	// ── Config Reload ─────────────────────────────────────────────────────────
+	// Tunables re-read on SIGHUP or POST /admin/config/reload; the rest needs a restart.
+	reloader := config.NewReloader(cfg)
+	reloader.On(func(c *config.Config) error {
+		return logger.SetLevel(c.LogLevel)
+	}, "LogLevel")
+	reloader.On(func(c *config.Config) error {
+		repository.SetSlowQueryThreshold(pool, time.Duration(c.DBSlowQueryMS)*time.Millisecond)
+		return nil
+	}, "DBSlowQueryMS")
+	reloader.On(func(c *config.Config) error {
+		uploadLimiter.Resize(c.UploadMaxConcurrent, c.UploadQueueSize, time.Duration(c.UploadQueueTimeoutSeconds)*time.Second)
+		return nil
+	}, "UploadMaxConcurrent", "UploadQueueSize", "UploadQueueTimeoutSeconds")
+	reloader.On(func(c *config.Config) error {
+		return processor.SetWorkers(c.BlockWorkers)
+	}, "BlockWorkers")
+	reloader.On(func(c *config.Config) error {
+		uploadPolicy.SetMaxBytes(int64(c.UploadMaxFileMB) * 1024 * 1024)
+		return nil
+	}, "UploadMaxFileMB")
+	reloader.On(func(c *config.Config) error {
+		loginLimiter.SetLimit(c.LoginMaxFailures, time.Duration(c.LoginFailureWindowMinutes)*time.Minute)
+		reportLimiter.SetLimit(c.AbuseReportsPerHour, time.Hour)
+		shareLimiter.SetLimit(c.ShareRequestsPerMinute, time.Minute)
+		shareGuard.SetLimit(c.ShareTokenMissesPerHour, time.Hour)
+		return nil
+	}, "LoginMaxFailures", "LoginFailureWindowMinutes", "AbuseReportsPerHour", "ShareRequestsPerMinute", "ShareTokenMissesPerHour")
+	configHandler := handler.NewConfigHandler(reloader)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := reloader.Reload(); err != nil {
+				logger.ErrorLog(context.Background(), "Configuration reload failed", logger.ErrorDetails{
+					Code: "CONFIG_RELOAD_ERR", Details: err.Error(),
+				})
+			}
+		}
+	}()
 
 	// ── Chi Router ────────────────────────────────────────────────────────────
+	// WebDAV's methods must be known to chi before any route is added.
+	for _, method := range handler.WebDAVMethods {
+		chi.RegisterMethod(method)
+	}
 	r := chi.NewRouter()
 
 	// Global middleware
 	r.Use(middleware.Recoverer)
+	r.Use(proxyResolver.Middleware)
 	r.Use(logger.Middleware)
+	// Browsers refuse credentialed responses with a wildcard origin, so cookie sessions
+	// from another origin need CORS_ALLOWED_ORIGINS listed explicitly.
+	corsCredentials := !slices.Contains(cfg.CORSAllowedOrigins, "*")
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		AllowCredentials: false,
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Origin", "Content-Type", "Accept", "Authorization", "Accept-Language", "X-CSRF-Token", "X-Share-Password", "X-Client", "Range", "If-Range", "If-None-Match", "Upload-Offset"},
+		ExposedHeaders:   []string{"Content-Length", "Content-Range", "Content-Disposition", "Accept-Ranges", "ETag", "Content-Language", "X-Request-Id", "X-Preview-Truncated", "X-Preview-Total-Size", "Upload-Offset", "X-Blocks"},
+		AllowCredentials: corsCredentials,
 		MaxAge:           300,
 	}))
+	// Innermost, so handlers write straight into its ResponseWriter (see i18n.Localize).
+	r.Use(i18n.Middleware)
 
 	// ── Routes ────────────────────────────────────────────────────────────────
+	// Every authenticated request also checks that the account is not deactivated.
+	authenticate  := auth.Authenticate(cfg.JWTSecret, sessionMode, authHandler.LookupSession)
+	requireActive := auth.RequireActive(userRepo.IsActive)
+	requireAuth   := func(next http.Handler) http.Handler { return authenticate(requireActive(next)) }
+
+	// Files, folders and sharing need the current terms of service accepted; account,
+	// data export and notification endpoints stay reachable so users can still act on them.
+	requireTOS := auth.RequireTOS(cfg.TOSVersion, userRepo.AcceptedTOSVersion)
+	// requireFeature refuses a route to users for whom the feature is off.
+	requireFeature := func(feature string) func(http.Handler) http.Handler {
+		return auth.RequireFeature(feature, featureService.Enabled)
+	}
+
 	r.Route("/api/v1", func(api chi.Router) {
+		api.Use(auth.CSRF)
+
 		// Public auth
+		api.Get("/auth/csrf", csrfHandler.IssueCSRFToken)
 		api.Post("/auth/register", authHandler.Register)
 		api.Post("/auth/login", authHandler.Login)
+		api.Post("/auth/refresh", authHandler.Refresh)
+		api.Post("/auth/logout", authHandler.Logout)
+		api.Post("/auth/invite/{token}", inviteHandler.AcceptInvite)
+		api.Get("/tos", tosHandler.GetTOS)
 
 		// Public share link download
-		api.Get("/share/{token}", shareHandler.DownloadShared)
+		api.Group(func(share chi.Router) {
+			share.Use(shareGuard.Middleware(proxy.ClientKey))
+			share.Use(shareLimiter.Middleware(proxy.ClientKey))
+			share.Get("/share/{token}", shareHandler.DownloadShared)
+			share.Head("/share/{token}", shareHandler.DownloadShared)
+			share.Get("/share/{token}/waveform", waveformHandler.SharedWaveform)
+			share.Get("/share/folder/{token}", shareHandler.ListSharedFolder)
+			share.Get("/share/folder/{token}/files/{id}", shareHandler.DownloadSharedFolderFile)
+			share.Head("/share/folder/{token}/files/{id}", shareHandler.DownloadSharedFolderFile)
+			share.Get("/share/folder/{token}/files/{id}/thumbnail", shareHandler.SharedFolderThumbnail)
+			share.Post("/share/folder/{token}/files", shareHandler.UploadToSharedFolder)
+			share.With(reportLimiter.Middleware(proxy.ClientKey)).Post("/share/{token}/report", abuseHandler.ReportShareLink)
+
+			// Public file-drop links
+			share.Get("/drop/{token}", dropHandler.GetDropMetadata)
+			share.Post("/drop/{token}", dropHandler.SubmitDrop)
+		})
 
 		// Protected auth
-		api.With(auth.Middleware(cfg.JWTSecret)).Get("/auth/me", authHandler.Me)
+		api.With(requireAuth).Get("/auth/me", authHandler.Me)
+		api.With(requireAuth).Post("/auth/tos/accept", tosHandler.AcceptTOS)
+		api.With(requireAuth).Get("/auth/me/preferences", prefsHandler.GetPreferences)
+		api.With(requireAuth).Patch("/auth/me/preferences", prefsHandler.UpdatePreferences)
+		api.With(requireAuth).Get("/auth/me/data-report", reportHandler.GetDataReport)
+		api.With(requireAuth).Get("/me/usage", usageHandler.GetMyUsage)
+		api.With(requireAuth).Get("/auth/me/usage", usageHandler.GetMyUsage)
+		api.With(requireAuth).Get("/auth/me/usage/history", usageHandler.GetMyUsageHistory)
+		api.With(requireAuth).Get("/auth/me/plan", planHandler.GetMyPlan)
+		api.With(requireAuth).Get("/auth/me/features", featureHandler.GetMyFeatures)
+		api.With(requireAuth).Get("/events", eventHandler.Stream)
+		api.With(requireAuth).Get("/jobs", jobHandler.ListJobs)
+		api.With(requireAuth).Get("/jobs/{id}", jobHandler.GetJob)
+
+		// Notifications
+		api.Group(func(notif chi.Router) {
+			notif.Use(requireAuth)
+			notif.Get("/notifications", notifHandler.ListNotifications)
+			notif.Post("/notifications/read-all", notifHandler.MarkAllNotificationsRead)
+			notif.Post("/notifications/{id}/read", notifHandler.MarkNotificationRead)
+			notif.Get("/push/config", pushHandler.GetPushConfig)
+			notif.Get("/push/devices", pushHandler.ListPushDevices)
+			notif.With(requireFeature(model.FeaturePush)).Post("/push/devices", pushHandler.RegisterPushDevice)
+			notif.Delete("/push/devices/{id}", pushHandler.DeletePushDevice)
+		})
 
 		// Protected file routes
 		api.Group(func(files chi.Router) {
-			files.Use(auth.Middleware(cfg.JWTSecret))
+			files.Use(requireAuth)
+			files.Use(requireTOS)
 			files.Post("/files", uploadHandler.Upload)
 			files.Get("/files", uploadHandler.ListFiles)
+			files.Get("/files/near-duplicates", dupHandler.ListNearDuplicates)
 			files.Get("/files/{id}/info", uploadHandler.FileInfo)
+			files.Get("/files/{id}/stats", uploadHandler.FileStats)
+			files.Get("/files/{id}/table", downloadHandler.TablePreview)
+			files.Get("/files/{id}/range", downloadHandler.ReadRange)
+			files.Get("/files/{id}/pages/{n}", pdfPageHandler.RenderPage)
+			files.Get("/files/{id}/waveform", waveformHandler.GetWaveform)
+			files.Get("/files/{id}/checksums", checksumHandler.GetChecksums)
+			files.Post("/files/{id}/verify", checksumHandler.VerifyFile)
+			files.Get("/files/{id}/diff/{otherId}", diffHandler.DiffFiles)
 			files.Get("/files/{id}", downloadHandler.Download)
+			files.Head("/files/{id}", downloadHandler.Download)
 			files.Delete("/files/{id}", downloadHandler.DeleteFile)
+			files.Patch("/files/{id}/content", uploadHandler.WriteContent)
 			files.Patch("/files/{id}/rename", uploadHandler.RenameFile)
 			files.Patch("/files/{id}/move", uploadHandler.MoveFile)
+			files.Put("/files/{id}/expiry", uploadHandler.SetFileExpiry)
+
+			// Resumable uploads
+			files.Post("/uploads", uploadHandler.CreateUploadSession)
+			files.Get("/uploads/{id}", uploadHandler.GetUploadSession)
+			files.Put("/uploads/{id}/chunks/{index}", uploadHandler.PutUploadChunk)
+			files.Patch("/uploads/{id}", uploadHandler.PatchUploadSession)
+			files.Post("/uploads/{id}/complete", uploadHandler.FinalizeUploadSession)
+			files.Delete("/uploads/{id}", uploadHandler.AbortUploadSession)
+
+			// Upload rules
+			files.Get("/upload-rules", ruleHandler.ListUploadRules)
+			files.Post("/upload-rules", ruleHandler.CreateUploadRule)
+			files.Patch("/upload-rules/{id}", ruleHandler.UpdateUploadRule)
+			files.Delete("/upload-rules/{id}", ruleHandler.DeleteUploadRule)
+
+			// File-drop links
+			files.Get("/upload-requests", dropHandler.ListUploadRequests)
+			files.With(requireFeature(model.FeatureFileDrops)).Post("/upload-requests", dropHandler.CreateUploadRequest)
+			files.Patch("/upload-requests/{id}", dropHandler.UpdateUploadRequest)
+			files.Delete("/upload-requests/{id}", dropHandler.DeleteUploadRequest)
+
+			// Multi-file downloads
+			files.With(requireFeature(model.FeatureDownloadBundles)).Post("/downloads", bundleHandler.CreateDownload)
+			files.Get("/downloads/{id}", bundleHandler.GetDownload)
+			files.Get("/downloads/{id}/content", bundleHandler.DownloadContent)
+
+			// Trash
+			files.Get("/trash", trashHandler.ListTrash)
+			files.Post("/trash/empty", trashHandler.EmptyTrash)
+			files.Post("/trash/{id}/restore", trashHandler.RestoreFile)
+			files.Delete("/trash/{id}", trashHandler.PurgeFile)
 
 			// Share links
 			files.Post("/files/{id}/share", shareHandler.CreateShareLink)
 			files.Get("/files/{id}/share", shareHandler.GetShareLinks)
 			files.Delete("/share/{linkId}", shareHandler.DeleteShareLink)
+			files.Get("/share-links", shareHandler.ListShareLinks)
+			files.Post("/snippets", snippetHandler.CreateSnippet)
+			files.Delete("/share-links", shareHandler.BulkDeleteShareLinks)
+			files.Patch("/share-links/{id}", shareHandler.UpdateShareLink)
 		})
 
 		// Protected folder routes
 		api.Group(func(folders chi.Router) {
-			folders.Use(auth.Middleware(cfg.JWTSecret))
+			folders.Use(requireAuth)
+			folders.Use(requireTOS)
 			folders.Post("/folders", folderHandler.CreateFolder)
 			folders.Get("/folders/contents", folderHandler.ListFolderContents)
 			folders.Get("/folders/all", folderHandler.ListAllFolders)
 			folders.Get("/folders/{id}/breadcrumb", folderHandler.Breadcrumb)
 			folders.Patch("/folders/{id}/rename", folderHandler.RenameFolder)
 			folders.Patch("/folders/{id}/move", folderHandler.MoveFolder)
+			folders.Patch("/folders/{id}", folderHandler.UpdateFolder)
+			folders.Put("/folders/{id}/expiry", folderHandler.SetFolderExpiry)
 			folders.Delete("/folders/{id}", folderHandler.DeleteFolder)
+			folders.Get("/folders/{id}/share-settings", folderHandler.GetShareSettings)
+			folders.Put("/folders/{id}/share-settings", folderHandler.UpdateShareSettings)
+			folders.Post("/folders/{id}/share", shareHandler.CreateFolderShareLink)
+			folders.Get("/folders/{id}/share", shareHandler.GetFolderShareLinks)
+			folders.Get("/folders/{id}/share-uploads", shareHandler.GetFolderShareUploads)
+			folders.Patch("/folder-share-links/{id}", shareHandler.UpdateFolderShareLink)
+			folders.Delete("/folder-share-links/{id}", shareHandler.DeleteFolderShareLink)
+
+			// Quick access (pinned folders)
+			folders.Get("/quick-access", pinHandler.ListQuickAccess)
+			folders.Put("/quick-access/order", pinHandler.ReorderQuickAccess)
+			folders.Put("/quick-access/{id}", pinHandler.PinFolder)
+			folders.Delete("/quick-access/{id}", pinHandler.UnpinFolder)
+
+			// Mounted external storage (read-only)
+			folders.Get("/mounts/{id}/entries", mountHandler.ListMountEntries)
+			folders.Get("/mounts/{id}/content", mountHandler.DownloadMountFile)
+			folders.Head("/mounts/{id}/content", mountHandler.DownloadMountFile)
 		})
+
+		// Admin-only maintenance routes
+		api.Route("/admin", func(adm chi.Router) {
+			adm.Use(requireAuth)
+			adm.Use(auth.RequireAdmin(userRepo.IsAdmin))
+			adm.Use(audit.Middleware(auditRepo.Insert))
+			adm.Post("/blocks/integrity-check", adminHandler.StartBlockIntegrityCheck)
+			adm.Post("/blocks/rekey", adminHandler.StartBlockRekey)
+			adm.Get("/near-duplicates", dupHandler.AdminListNearDuplicates)
+			adm.Get("/jobs/{id}", adminHandler.GetJob)
+			adm.Get("/s3-deletions/dead", adminHandler.ListDeadS3Deletions)
+			adm.Get("/maintenance/preview", adminHandler.PreviewCleanup)
+			adm.Post("/config/reload", configHandler.ReloadConfig)
+			adm.Post("/s3-deletions/dead/retry", adminHandler.RetryDeadS3Deletions)
+			adm.Put("/files/{id}/scan-status", adminHandler.SetScanStatus)
+			adm.Get("/abuse-reports", abuseHandler.ListAbuseReports)
+			adm.Post("/abuse-reports/{id}/disable-link", abuseHandler.DisableReportedLink)
+			adm.Post("/abuse-reports/{id}/disable-file", abuseHandler.DisableReportedFile)
+			adm.Post("/abuse-reports/{id}/dismiss", abuseHandler.DismissAbuseReport)
+			adm.Get("/security-events", securityHandler.ListSecurityEvents)
+			adm.Get("/audit", auditHandler.ListAdminAudit)
+			adm.Post("/users/import", importHandler.ImportUsers)
+			adm.Get("/users/export", importHandler.ExportUsers)
+			adm.Post("/users/{id}/deactivate", userAdmHandler.DeactivateUser)
+			adm.Post("/users/{id}/reactivate", userAdmHandler.ReactivateUser)
+			adm.Get("/users/{id}/usage/history", usageHandler.GetUserUsageHistory)
+			adm.Get("/usage/history", usageHandler.GetSystemUsageHistory)
+			adm.Get("/plans", planHandler.ListPlans)
+			adm.Post("/plans", planHandler.CreatePlan)
+			adm.Patch("/plans/{id}", planHandler.UpdatePlan)
+			adm.Delete("/plans/{id}", planHandler.DeletePlan)
+			adm.Put("/users/{id}/plan", planHandler.AssignPlan)
+			adm.Put("/users/{id}/quota", planHandler.SetUserQuota)
+			adm.Get("/mounts", mountHandler.ListMounts)
+			adm.Post("/mounts", mountHandler.CreateMount)
+			adm.Delete("/mounts/{id}", mountHandler.DeleteMount)
+		})
+	})
+
+	// Public share landing page — the URL handed to recipients
+	r.Group(func(landing chi.Router) {
+		landing.Use(shareGuard.Middleware(proxy.ClientKey), shareLimiter.Middleware(proxy.ClientKey))
+		landing.Get("/s/{token}", shareHandler.ShareLanding)
+		landing.Post("/s/{token}", shareHandler.ShareLanding)
+	})
+
+	// WebDAV mount of the user's files. Clients sign in with email and password (HTTP
+	// Basic) or a bearer token; session cookies are not accepted, so no CSRF check.
+	davAuth := auth.Basic(cfg.BrandName, authHandler.BasicLogin, auth.Middleware(cfg.JWTSecret))
+	r.Route("/dav", func(dav chi.Router) {
+		dav.Use(davAuth, requireActive, requireTOS, requireFeature(model.FeatureWebDAV))
+		dav.Handle("/", webdavHandler)
+		dav.Handle("/*", webdavHandler)
 	})
 
 	// Health check
@@ -168,6 +736,19 @@ func main() {
 		WriteTimeout: 10 * time.Minute,
 		IdleTimeout:  2 * time.Minute,
 	}
+	srv.RegisterOnShutdown(eventHandler.Close)
+
+	// ── Admin / Diagnostics Server ────────────────────────────────────────────
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = admin.NewServer(cfg.AdminAddr)
+		go func() {
+			logger.Infof("Admin diagnostics listening on http://%s/debug/", cfg.AdminAddr)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("Admin server error: %v", err)
+			}
+		}()
+	}
 
 	// ── Graceful Shutdown ─────────────────────────────────────────────────────
 	quit := make(chan os.Signal, 1)
@@ -189,5 +770,13 @@ func main() {
 			Code: "SHUTDOWN_ERR", Details: err.Error(),
 		})
 	}
+	if adminSrv != nil {
+		adminSrv.Shutdown(shutdownCtx)
+	}
+	scheduler.Stop(shutdownCtx)
+	jobRunner.Shutdown(shutdownCtx)
+	mail.Stop(shutdownCtx)
+	security.Stop(shutdownCtx)
+	changeHub.Stop(shutdownCtx)
 	logger.Infof("Server stopped")
 }
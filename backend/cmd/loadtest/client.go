@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// client talks to a running API as one user. It works with either session mode:
+// a bearer token when login returns one, otherwise the session cookie plus CSRF header.
+type client struct {
+	base  string // e.g. http://localhost:8080/api/v1
+	http  *http.Client
+	token string
+	csrf  string
+}
+
+func newClient(base string, timeout time.Duration) *client {
+	jar, _ := cookiejar.New(nil)
+	return &client{
+		base: strings.TrimRight(base, "/"),
+		http: &http.Client{
+			Jar:     jar,
+			Timeout: timeout,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 64,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+	}
+}
+
+// apiError is a non-2xx response.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Body)
+}
+
+func (c *client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.csrf != "" {
+		req.Header.Set(auth.CSRFHeader, c.csrf)
+	}
+	return req, nil
+}
+
+// do sends req and decodes the data of a JSON response envelope into out (if non-nil).
+func (c *client) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &apiError{Status: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	var env respond.Envelope
+	env.Data = out
+	return json.NewDecoder(resp.Body).Decode(&env)
+}
+
+func (c *client) postJSON(ctx context.Context, path string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+// login signs in, registering the account first if register is set, and accepts the
+// current terms of service so file endpoints are usable.
+func (c *client) login(ctx context.Context, email, password string, register bool) error {
+	creds := map[string]string{"email": email, "password": password}
+	if register {
+		if err := c.postJSON(ctx, "/auth/register", creds, nil); err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+				return fmt.Errorf("register: %w", err)
+			}
+		}
+	}
+
+	var tok struct {
+		Token string `json:"token"`
+	}
+	if err := c.postJSON(ctx, "/auth/login", creds, &tok); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	c.token = tok.Token
+
+	if c.token == "" {
+		// Cookie sessions: mutating requests need the CSRF token.
+		var csrf struct {
+			CSRFToken string `json:"csrf_token"`
+		}
+		req, err := c.newRequest(ctx, http.MethodGet, "/auth/csrf", nil)
+		if err != nil {
+			return err
+		}
+		if err := c.do(req, &csrf); err != nil {
+			return fmt.Errorf("csrf: %w", err)
+		}
+		c.csrf = csrf.CSRFToken
+	}
+
+	var tos struct {
+		Version string `json:"version"`
+	}
+	req, err := c.newRequest(ctx, http.MethodGet, "/tos", nil)
+	if err != nil {
+		return err
+	}
+	if err := c.do(req, &tos); err != nil {
+		return fmt.Errorf("tos: %w", err)
+	}
+	if tos.Version != "" {
+		if err := c.postJSON(ctx, "/auth/tos/accept", map[string]string{"version": tos.Version}, nil); err != nil {
+			return fmt.Errorf("accept tos: %w", err)
+		}
+	}
+	return nil
+}
+
+// upload streams size bytes from content as a multipart upload and returns the file ID.
+func (c *client) upload(ctx context.Context, name string, content io.Reader) (int64, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", name)
+		if err == nil {
+			_, err = io.Copy(part, content)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/files", pr)
+	if err != nil {
+		pr.Close()
+		return 0, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var out struct {
+		FileID int64 `json:"file_id"`
+	}
+	if err := c.do(req, &out); err != nil {
+		return 0, err
+	}
+	return out.FileID, nil
+}
+
+// download fetches a file and discards it, returning the bytes read and the time to
+// the first byte of the body.
+func (c *client) download(ctx context.Context, fileID int64) (int64, time.Duration, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, fmt.Sprintf("/files/%d", fileID), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, 0, &apiError{Status: resp.StatusCode, Body: strings.TrimSpace(string(b))}
+	}
+
+	var first [1]byte
+	n, err := io.ReadFull(resp.Body, first[:])
+	ttfb := time.Since(start)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return int64(n), ttfb, nil
+		}
+		return 0, ttfb, err
+	}
+	rest, err := io.Copy(io.Discard, resp.Body)
+	return int64(n) + rest, ttfb, err
+}
+
+// deleteFile moves a file to the trash.
+func (c *client) deleteFile(ctx context.Context, fileID int64) error {
+	req, err := c.newRequest(ctx, http.MethodDelete, fmt.Sprintf("/files/%d", fileID), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, nil)
+}
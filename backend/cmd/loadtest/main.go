@@ -0,0 +1,141 @@
+// Command loadtest runs load scenarios against a running Naratel Box API and reports
+// latency and throughput, optionally failing when results regress against a saved
+// baseline. It needs a user account on the target (created with -register) and
+// leaves nothing behind unless -keep is set: uploaded files go to the user's trash.
+//
+// Usage:
+//
+//	go run ./cmd/loadtest -url http://localhost:8080/api/v1 -email load@example.com -register \
+//	    -out report.json -baseline baseline.json
+//
+// Scenarios: small-files (many small distinct uploads), huge-file (one large upload),
+// high-dedup (the same content uploaded repeatedly) and downloads (one file fetched
+// concurrently). Pick some with -scenarios; sizes and counts have flags of their own.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var (
+		url          = flag.String("url", "http://localhost:8080/api/v1", "API base URL")
+		email        = flag.String("email", os.Getenv("LOADTEST_EMAIL"), "account to run as (default $LOADTEST_EMAIL)")
+		password     = flag.String("password", os.Getenv("LOADTEST_PASSWORD"), "account password (default $LOADTEST_PASSWORD)")
+		register     = flag.Bool("register", false, "register the account first if it does not exist")
+		only         = flag.String("scenarios", "all", "comma-separated scenarios to run, or all")
+		out          = flag.String("out", "", "write the JSON report to this file")
+		baselinePath = flag.String("baseline", "", "compare against this JSON report and exit 1 on regressions")
+		maxPct       = flag.Float64("max-regression", 20, "allowed throughput drop / p95 latency rise against the baseline, in percent")
+		keep         = flag.Bool("keep", false, "keep uploaded files instead of moving them to the trash")
+		timeout      = flag.Duration("timeout", 30*time.Minute, "per-request timeout")
+
+		o options
+	)
+	flag.IntVar(&o.concurrency, "concurrency", 8, "concurrent requests per scenario")
+	flag.IntVar(&o.smallCount, "small-count", 200, "small-files: number of uploads")
+	flag.IntVar(&o.smallKB, "small-kb", 64, "small-files: size of each file in KB")
+	flag.IntVar(&o.hugeMB, "huge-mb", 1024, "huge-file: file size in MB")
+	flag.IntVar(&o.dedupCopies, "dedup-copies", 20, "high-dedup: number of identical uploads")
+	flag.IntVar(&o.dedupMB, "dedup-mb", 32, "high-dedup: size of each file in MB")
+	flag.IntVar(&o.downloadCount, "download-count", 100, "downloads: number of downloads")
+	flag.IntVar(&o.downloadMB, "download-mb", 64, "downloads: file size in MB")
+	flag.Parse()
+
+	if *email == "" || *password == "" {
+		fmt.Fprintln(os.Stderr, "loadtest: -email and -password (or LOADTEST_EMAIL / LOADTEST_PASSWORD) are required")
+		os.Exit(2)
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+
+	selected := scenarios
+	if *only != "all" {
+		names := strings.Split(*only, ",")
+		selected = nil
+		for _, s := range scenarios {
+			if slices.Contains(names, s.name) {
+				selected = append(selected, s)
+			}
+		}
+		if len(selected) == 0 {
+			fmt.Fprintf(os.Stderr, "loadtest: no known scenario in %q\n", *only)
+			os.Exit(2)
+		}
+	}
+
+	var baseline *Report
+	if *baselinePath != "" {
+		var err error
+		if baseline, err = loadReport(*baselinePath); err != nil {
+			fmt.Fprintf(os.Stderr, "loadtest: baseline: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := newClient(*url, *timeout)
+	if err := c.login(ctx, *email, *password, *register); err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := &Report{Target: *url, StartedAt: time.Now().UTC()}
+	created := &fileList{}
+	for _, s := range selected {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "running %s...\n", s.name)
+		report.Scenarios = append(report.Scenarios, s.run(ctx, c, o, created))
+	}
+
+	if !*keep {
+		// Use a fresh context so an interrupted run still cleans up.
+		cleanup, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		for _, id := range created.ids {
+			if err := c.deleteFile(cleanup, id); err != nil {
+				fmt.Fprintf(os.Stderr, "loadtest: delete file %d: %v\n", id, err)
+			}
+		}
+		cancel()
+	}
+
+	report.print(os.Stdout)
+	if *out != "" {
+		if err := report.save(*out); err != nil {
+			fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	failed := false
+	for _, s := range report.Scenarios {
+		if s.Errors > 0 {
+			failed = true
+		}
+	}
+	if baseline != nil {
+		if regressions := compare(os.Stdout, report, baseline, *maxPct); len(regressions) > 0 {
+			fmt.Println("\nregressions:")
+			for _, r := range regressions {
+				fmt.Println("  " + r)
+			}
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
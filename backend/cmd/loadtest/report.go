@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// recorder collects the outcome of every operation in a scenario. Safe for
+// concurrent use.
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	ttfb      []time.Duration
+	bytes     int64
+	errors    int
+	firstErr  error
+}
+
+func (r *recorder) ok(latency time.Duration, n int64) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, latency)
+	r.bytes += n
+	r.mu.Unlock()
+}
+
+func (r *recorder) firstByte(d time.Duration) {
+	r.mu.Lock()
+	r.ttfb = append(r.ttfb, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) fail(err error) {
+	r.mu.Lock()
+	r.errors++
+	if r.firstErr == nil {
+		r.firstErr = err
+	}
+	r.mu.Unlock()
+}
+
+// ScenarioResult is the report for one scenario. Latencies are in milliseconds.
+type ScenarioResult struct {
+	Name       string  `json:"name"`
+	Ops        int     `json:"ops"`
+	Errors     int     `json:"errors"`
+	FirstError string  `json:"first_error,omitempty"`
+	Bytes      int64   `json:"bytes"`
+	Seconds    float64 `json:"seconds"`
+	OpsPerSec  float64 `json:"ops_per_sec"`
+	MBPerSec   float64 `json:"mb_per_sec"`
+	LatencyP50 float64 `json:"latency_p50_ms"`
+	LatencyP95 float64 `json:"latency_p95_ms"`
+	LatencyP99 float64 `json:"latency_p99_ms"`
+	LatencyMax float64 `json:"latency_max_ms"`
+	TTFBP50    float64 `json:"ttfb_p50_ms,omitempty"` // downloads only
+	TTFBP95    float64 `json:"ttfb_p95_ms,omitempty"`
+}
+
+// Report is the output of one run, also used as a baseline for later runs.
+type Report struct {
+	Target    string            `json:"target"`
+	StartedAt time.Time         `json:"started_at"`
+	Scenarios []*ScenarioResult `json:"scenarios"`
+}
+
+func (r *recorder) result(name string, elapsed time.Duration) *ScenarioResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := &ScenarioResult{
+		Name:    name,
+		Ops:     len(r.latencies),
+		Errors:  r.errors,
+		Bytes:   r.bytes,
+		Seconds: elapsed.Seconds(),
+	}
+	if r.firstErr != nil {
+		res.FirstError = r.firstErr.Error()
+	}
+	if res.Seconds > 0 {
+		res.OpsPerSec = float64(res.Ops) / res.Seconds
+		res.MBPerSec = float64(res.Bytes) / (1 << 20) / res.Seconds
+	}
+	res.LatencyP50, res.LatencyP95, res.LatencyP99, res.LatencyMax = percentiles(r.latencies)
+	res.TTFBP50, res.TTFBP95, _, _ = percentiles(r.ttfb)
+	return res
+}
+
+// percentiles returns p50, p95, p99 and max of ds in milliseconds (zeros if empty).
+func percentiles(ds []time.Duration) (p50, p95, p99, max float64) {
+	if len(ds) == 0 {
+		return 0, 0, 0, 0
+	}
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+	at := func(q float64) float64 {
+		i := int(q * float64(len(sorted)-1))
+		return float64(sorted[i].Microseconds()) / 1000
+	}
+	return at(0.50), at(0.95), at(0.99), at(1)
+}
+
+func (r *Report) print(w io.Writer) {
+	fmt.Fprintf(w, "%-16s %6s %6s %10s %9s %9s %9s %9s %9s\n",
+		"scenario", "ops", "errors", "MB/s", "ops/s", "p50 ms", "p95 ms", "p99 ms", "ttfb p95")
+	for _, s := range r.Scenarios {
+		fmt.Fprintf(w, "%-16s %6d %6d %10.1f %9.2f %9.1f %9.1f %9.1f %9.1f\n",
+			s.Name, s.Ops, s.Errors, s.MBPerSec, s.OpsPerSec, s.LatencyP50, s.LatencyP95, s.LatencyP99, s.TTFBP95)
+		if s.FirstError != "" {
+			fmt.Fprintf(w, "  first error: %s\n", s.FirstError)
+		}
+	}
+}
+
+func (r *Report) save(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func loadReport(path string) (*Report, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// compare prints how each scenario moved against baseline and returns the
+// regressions: throughput down, or p95 latency up, by more than maxPct percent.
+// Scenarios missing from either report are skipped.
+func compare(w io.Writer, current, baseline *Report, maxPct float64) []string {
+	base := make(map[string]*ScenarioResult, len(baseline.Scenarios))
+	for _, s := range baseline.Scenarios {
+		base[s.Name] = s
+	}
+
+	var regressions []string
+	fmt.Fprintf(w, "\nagainst baseline from %s (threshold %.0f%%):\n", baseline.StartedAt.Format(time.RFC3339), maxPct)
+	for _, cur := range current.Scenarios {
+		b, ok := base[cur.Name]
+		if !ok {
+			continue
+		}
+		throughput := change(b.MBPerSec, cur.MBPerSec)
+		p95 := change(b.LatencyP95, cur.LatencyP95)
+		fmt.Fprintf(w, "%-16s MB/s %+6.1f%%   p95 %+6.1f%%\n", cur.Name, throughput, p95)
+
+		if throughput < -maxPct {
+			regressions = append(regressions, fmt.Sprintf("%s: throughput %.1f MB/s -> %.1f MB/s", cur.Name, b.MBPerSec, cur.MBPerSec))
+		}
+		if p95 > maxPct {
+			regressions = append(regressions, fmt.Sprintf("%s: p95 latency %.1f ms -> %.1f ms", cur.Name, b.LatencyP95, cur.LatencyP95))
+		}
+		if cur.Errors > b.Errors {
+			regressions = append(regressions, fmt.Sprintf("%s: errors %d -> %d", cur.Name, b.Errors, cur.Errors))
+		}
+	}
+	return regressions
+}
+
+// change returns the percentage change from before to after (0 if before is 0).
+func change(before, after float64) float64 {
+	if before == 0 {
+		return 0
+	}
+	return (after - before) / before * 100
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const mb = 1 << 20
+
+// options sizes the scenarios; see the flags in main.
+type options struct {
+	concurrency   int
+	smallCount    int
+	smallKB       int
+	hugeMB        int
+	dedupCopies   int
+	dedupMB       int
+	downloadCount int
+	downloadMB    int
+}
+
+// scenario runs one workload and reports on it. Files it creates are appended to
+// created so they can be cleaned up afterwards.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, c *client, o options, created *fileList) *ScenarioResult
+}
+
+var scenarios = []scenario{
+	{"small-files", runSmallFiles},
+	{"huge-file", runHugeFile},
+	{"high-dedup", runHighDedup},
+	{"downloads", runDownloads},
+}
+
+// fileList is a concurrency-safe list of uploaded file IDs.
+type fileList struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (l *fileList) add(id int64) {
+	l.mu.Lock()
+	l.ids = append(l.ids, id)
+	l.mu.Unlock()
+}
+
+// content returns size bytes of pseudo-random data. The same seed always yields the
+// same bytes, so uploads with equal seeds deduplicate and different seeds don't.
+func content(seed int64, size int64) io.Reader {
+	return io.LimitReader(rand.New(rand.NewSource(seed)), size)
+}
+
+// runParallel calls fn(i) for i in [0, n) on at most concurrency goroutines.
+func runParallel(ctx context.Context, n, concurrency int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// uploadMany uploads count files of size bytes, seeded by seedOf(i), and records each upload.
+func uploadMany(ctx context.Context, c *client, rec *recorder, created *fileList, prefix string, count, concurrency int, size int64, seedOf func(i int) int64) {
+	runParallel(ctx, count, concurrency, func(i int) {
+		start := time.Now()
+		id, err := c.upload(ctx, fmt.Sprintf("loadtest-%s-%d-%d.bin", prefix, time.Now().UnixNano(), i), content(seedOf(i), size))
+		if err != nil {
+			rec.fail(err)
+			return
+		}
+		rec.ok(time.Since(start), size)
+		created.add(id)
+	})
+}
+
+// runSmallFiles uploads many small, distinct files: per-request overhead dominates.
+func runSmallFiles(ctx context.Context, c *client, o options, created *fileList) *ScenarioResult {
+	rec := &recorder{}
+	base := time.Now().UnixNano()
+	start := time.Now()
+	uploadMany(ctx, c, rec, created, "small", o.smallCount, o.concurrency, int64(o.smallKB)<<10,
+		func(i int) int64 { return base + int64(i) })
+	return rec.result("small-files", time.Since(start))
+}
+
+// runHugeFile uploads one large file: block processing and S3 throughput dominate.
+func runHugeFile(ctx context.Context, c *client, o options, created *fileList) *ScenarioResult {
+	rec := &recorder{}
+	start := time.Now()
+	uploadMany(ctx, c, rec, created, "huge", 1, 1, int64(o.hugeMB)*mb,
+		func(int) int64 { return time.Now().UnixNano() })
+	return rec.result("huge-file", time.Since(start))
+}
+
+// runHighDedup uploads the same content repeatedly: after the first copy every block
+// is a dedup hit, so this measures hashing and metadata work without S3 writes.
+func runHighDedup(ctx context.Context, c *client, o options, created *fileList) *ScenarioResult {
+	rec := &recorder{}
+	seed := time.Now().UnixNano()
+	start := time.Now()
+	uploadMany(ctx, c, rec, created, "dedup", o.dedupCopies, o.concurrency, int64(o.dedupMB)*mb,
+		func(int) int64 { return seed })
+	return rec.result("high-dedup", time.Since(start))
+}
+
+// runDownloads uploads one file (not measured) and downloads it concurrently: this
+// measures block streaming from S3 to clients.
+func runDownloads(ctx context.Context, c *client, o options, created *fileList) *ScenarioResult {
+	rec := &recorder{}
+	size := int64(o.downloadMB) * mb
+	id, err := c.upload(ctx, fmt.Sprintf("loadtest-download-%d.bin", time.Now().UnixNano()), content(time.Now().UnixNano(), size))
+	if err != nil {
+		rec.fail(fmt.Errorf("setup upload: %w", err))
+		return rec.result("downloads", 0)
+	}
+	created.add(id)
+
+	start := time.Now()
+	runParallel(ctx, o.downloadCount, o.concurrency, func(int) {
+		opStart := time.Now()
+		n, ttfb, err := c.download(ctx, id)
+		if err != nil {
+			rec.fail(err)
+			return
+		}
+		if n != size {
+			rec.fail(fmt.Errorf("short download: got %d of %d bytes", n, size))
+			return
+		}
+		rec.firstByte(ttfb)
+		rec.ok(time.Since(opStart), n)
+	})
+	return rec.result("downloads", time.Since(start))
+}
@@ -0,0 +1,56 @@
+// Package admin serves runtime diagnostics (pprof profiles and expvar counters)
+// on a separate listener that is never exposed through the public API port.
+package admin
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("heap", expvar.Func(func() interface{} {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return map[string]uint64{
+			"alloc_bytes":    m.HeapAlloc,
+			"inuse_bytes":    m.HeapInuse,
+			"sys_bytes":      m.HeapSys,
+			"objects":        m.HeapObjects,
+			"num_gc":         uint64(m.NumGC),
+			"next_gc_bytes":  m.NextGC,
+			"pause_total_ns": m.PauseTotalNs,
+		}
+	}))
+}
+
+// NewServer returns an HTTP server exposing:
+//
+//	/debug/pprof/  — CPU, heap, goroutine, block and mutex profiles
+//	/debug/vars    — expvar snapshot (goroutines, heap, upload worker occupancy,
+//	                 S3 in-flight requests, S3 deletion queue outcomes, share file cache,
+//	                 per-route traffic counters, block dedup totals, memstats)
+//
+// It has no authentication, so addr should be bound to localhost or an internal network.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &http.Server{
+		Addr:        addr,
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+		// CPU profiles and traces stream for ?seconds=N, so allow long writes.
+		WriteTimeout: 5 * time.Minute,
+	}
+}
@@ -0,0 +1,250 @@
+// Package audio measures audio files and outlines their waveform by running ffprobe
+// and ffmpeg, so players can show a file's length and a seekable waveform without
+// decoding it in the browser.
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decoding parameters: ffmpeg downmixes to mono 16-bit PCM at sampleRate, which is
+// plenty for an outline, and each peak starts out covering windowSamples samples.
+const (
+	sampleRate    = 8000
+	windowSamples = sampleRate / 100 // 10 ms
+)
+
+// analyzeTimeout bounds one file's analysis, so a hostile file cannot hold the worker.
+const analyzeTimeout = 10 * time.Minute
+
+// ErrNoAudio is returned for files without an audio stream ffmpeg can decode.
+var ErrNoAudio = errors.New("audio: no decodable audio stream")
+
+// Analysis describes an audio file.
+type Analysis struct {
+	DurationMs int64     // playing time
+	Bitrate    int64     // average bits per second of the file
+	SampleRate int       // of the audio stream
+	Channels   int       // of the audio stream
+	Codec      string    // ffprobe's codec name, e.g. "mp3", "aac", "opus"
+	Peaks      []float64 // loudest absolute sample per slice of time, 0-1
+}
+
+// Supported reports whether a file of mimeType or, failing that, name is audio.
+func Supported(mimeType, name string) bool {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "audio/") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp3", ".m4a", ".aac", ".wav", ".flac", ".ogg", ".oga", ".opus", ".weba":
+		return true
+	}
+	return false
+}
+
+// Analyzer runs ffprobe for a file's metadata and ffmpeg for its waveform.
+type Analyzer struct {
+	ffmpeg  string
+	ffprobe string
+}
+
+// NewAnalyzer returns an Analyzer using the ffmpeg and ffprobe binaries (names looked
+// up in PATH, or paths). It returns an error if either cannot be found.
+func NewAnalyzer(ffmpeg, ffprobe string) (*Analyzer, error) {
+	ffmpegPath, err := exec.LookPath(ffmpeg)
+	if err != nil {
+		return nil, fmt.Errorf("audio.NewAnalyzer: %w", err)
+	}
+	ffprobePath, err := exec.LookPath(ffprobe)
+	if err != nil {
+		return nil, fmt.Errorf("audio.NewAnalyzer: %w", err)
+	}
+	return &Analyzer{ffmpeg: ffmpegPath, ffprobe: ffprobePath}, nil
+}
+
+// Analyze measures the audio file at file (of size bytes) and reduces its first audio
+// stream to at most peaks waveform peaks, each the loudest sample of an equal slice.
+// Files shorter than peaks 10 ms windows get one peak per window.
+func (a *Analyzer) Analyze(ctx context.Context, file string, size int64, peaks int) (*Analysis, error) {
+	ctx, cancel := context.WithTimeout(ctx, analyzeTimeout)
+	defer cancel()
+
+	res, err := a.probe(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := newPeakAccumulator(peaks)
+	if err := a.decode(ctx, file, acc); err != nil {
+		return nil, err
+	}
+	if acc.samples == 0 {
+		return nil, ErrNoAudio
+	}
+	res.Peaks = acc.result(peaks)
+	if res.DurationMs <= 0 {
+		res.DurationMs = acc.samples * 1000 / sampleRate
+	}
+	if res.Bitrate <= 0 && res.DurationMs > 0 {
+		res.Bitrate = size * 8 * 1000 / res.DurationMs
+	}
+	return res, nil
+}
+
+// probe reads the container duration and bitrate and the first audio stream's format.
+func (a *Analyzer) probe(ctx context.Context, file string) (*Analysis, error) {
+	out, err := exec.CommandContext(ctx, a.ffprobe, "-v", "error", "-select_streams", "a:0",
+		"-show_entries", "format=duration,bit_rate:stream=codec_name,sample_rate,channels",
+		"-of", "json", file).Output()
+	if err != nil {
+		return nil, fmt.Errorf("audio.Analyze: ffprobe: %w", commandError(err))
+	}
+	var probe struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return nil, fmt.Errorf("audio.Analyze: ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return nil, ErrNoAudio
+	}
+	s := probe.Streams[0]
+	res := &Analysis{Codec: s.CodecName, Channels: s.Channels}
+	res.SampleRate, _ = strconv.Atoi(s.SampleRate)
+	if d, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil && d > 0 {
+		res.DurationMs = int64(math.Round(d * 1000))
+	}
+	res.Bitrate, _ = strconv.ParseInt(probe.Format.BitRate, 10, 64)
+	return res, nil
+}
+
+// decode streams the first audio stream as mono PCM into acc.
+func (a *Analyzer) decode(ctx context.Context, file string, acc *peakAccumulator) error {
+	cmd := exec.CommandContext(ctx, a.ffmpeg, "-v", "error", "-nostdin", "-i", file,
+		"-map", "0:a:0", "-ac", "1", "-ar", strconv.Itoa(sampleRate), "-f", "s16le", "pipe:1")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("audio.Analyze: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("audio.Analyze: ffmpeg: %w", err)
+	}
+
+	buf := make([]byte, 32*1024)
+	var readErr error
+	for {
+		n, err := io.ReadFull(stdout, buf)
+		for i := 0; i+1 < n; i += 2 {
+			acc.add(int(int16(binary.LittleEndian.Uint16(buf[i:]))))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("audio.Analyze: ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if readErr != nil {
+		return fmt.Errorf("audio.Analyze: %w", readErr)
+	}
+	return nil
+}
+
+// commandError adds a failed command's stderr to its error.
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
+
+// peakAccumulator keeps the loudest sample of each window of a stream of unknown
+// length in bounded memory: when it holds limit peaks, neighbouring pairs are merged
+// and the window doubles.
+type peakAccumulator struct {
+	window  int   // samples per peak
+	n       int   // samples in the current window
+	cur     int   // loudest of them
+	peaks   []int // finished windows
+	limit   int
+	samples int64
+}
+
+func newPeakAccumulator(peaks int) *peakAccumulator {
+	limit := 4 * max(peaks, 1)
+	return &peakAccumulator{window: windowSamples, limit: limit, peaks: make([]int, 0, limit)}
+}
+
+func (a *peakAccumulator) add(sample int) {
+	if sample < 0 {
+		sample = -sample
+	}
+	a.cur = max(a.cur, sample)
+	a.n++
+	a.samples++
+	if a.n == a.window {
+		a.flush()
+	}
+}
+
+func (a *peakAccumulator) flush() {
+	a.peaks = append(a.peaks, a.cur)
+	a.cur, a.n = 0, 0
+	if len(a.peaks) < a.limit {
+		return
+	}
+	half := len(a.peaks) / 2
+	for i := 0; i < half; i++ {
+		a.peaks[i] = max(a.peaks[2*i], a.peaks[2*i+1])
+	}
+	a.peaks = a.peaks[:half]
+	a.window *= 2
+}
+
+// result returns at most want peaks scaled to 0-1, rounded to three decimals.
+func (a *peakAccumulator) result(want int) []float64 {
+	if a.n > 0 {
+		a.flush()
+	}
+	peaks := a.peaks
+	if len(peaks) > want {
+		merged := make([]int, want)
+		for i := range merged {
+			for _, p := range peaks[i*len(peaks)/want : (i+1)*len(peaks)/want] {
+				merged[i] = max(merged[i], p)
+			}
+		}
+		peaks = merged
+	}
+	out := make([]float64, len(peaks))
+	for i, p := range peaks {
+		out[i] = math.Round(float64(p)/32768*1000) / 1000
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+// Package audit keeps the admin audit trail: one append-only record for every
+// state-changing admin request, naming the admin who made it, what it targeted, the
+// target's state before and after, and the status it ended with. Records are written
+// synchronously once the handler returns, so an answered request is always on file.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+)
+
+var (
+	recorded = expvar.NewInt("admin_audit_recorded")
+	failed   = expvar.NewInt("admin_audit_failed") // store errors; each is also logged
+)
+
+// Store persists one audit entry.
+type Store func(ctx context.Context, e *model.AdminAuditEntry) error
+
+type ctxKey struct{}
+
+// Middleware records every request through it except GET, HEAD and OPTIONS. It must
+// run after auth.Middleware, and within the chi router so the route pattern is known.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			e := &model.AdminAuditEntry{
+				IPAddress: proxy.ClientIP(r),
+				RequestID: logger.GetRequestID(r.Context()),
+			}
+			e.ActorID, _ = auth.GetUserID(r)
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), ctxKey{}, e)))
+
+			e.Action = r.Method + " " + r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				e.Action = r.Method + " " + rctx.RoutePattern()
+			}
+			e.Status = sw.status
+			if e.Status == 0 {
+				e.Status = http.StatusOK
+			}
+
+			// The client may already have gone; the record is written regardless.
+			ctx := context.WithoutCancel(r.Context())
+			if err := store(ctx, e); err != nil {
+				failed.Add(1)
+				logger.ErrorLog(ctx, "Failed to write admin audit record", logger.ErrorDetails{
+					Code: "ADMIN_AUDIT_ERR", Details: err.Error(),
+				})
+				return
+			}
+			recorded.Add(1)
+		})
+	}
+}
+
+// Describe attaches the target of the admin request in ctx and its state before and
+// after the change to the request's audit record. before and after are stored as
+// JSON; either may be nil (nothing existed before a create, nothing after a delete).
+// Handlers call it once they know the outcome; outside Middleware it does nothing.
+func Describe(ctx context.Context, targetType, targetID string, before, after interface{}) {
+	e, ok := ctx.Value(ctxKey{}).(*model.AdminAuditEntry)
+	if !ok {
+		return
+	}
+	e.TargetType, e.TargetID = targetType, targetID
+	e.Before, e.After = marshal(before), marshal(after)
+}
+
+func marshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// statusWriter records the status code a handler responds with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController and i18n.Localize reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
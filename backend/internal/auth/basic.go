@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BasicLogin checks the email and password of an HTTP Basic request, returning the
+// user's ID and email. On failure it writes the error response and returns false.
+type BasicLogin func(w http.ResponseWriter, r *http.Request, email, password string) (int64, string, bool)
+
+// Basic authenticates requests carrying HTTP Basic credentials (email and password)
+// through login, for clients such as WebDAV mounts that cannot obtain a token.
+// Requests with any other Authorization header go to fallback; requests without one
+// are answered with a challenge for realm so the client prompts for a password.
+func Basic(realm string, login BasicLogin, fallback func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	challenge := `Basic realm="` + realm + `", charset="UTF-8"`
+	return func(next http.Handler) http.Handler {
+		other := fallback(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				logger.Warn(r.Context(), "Missing Authorization header", nil)
+				w.Header().Set("WWW-Authenticate", challenge)
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "missing Authorization header")
+				return
+			}
+			email, password, ok := r.BasicAuth()
+			if !ok {
+				other.ServeHTTP(w, r)
+				return
+			}
+			// Set before login writes a refusal, so the client asks again.
+			w.Header().Set("WWW-Authenticate", challenge)
+			userID, userEmail, ok := login(w, r, email, password)
+			if !ok {
+				return
+			}
+			w.Header().Del("WWW-Authenticate")
+			next.ServeHTTP(w, withUser(r, userID, userEmail))
+		})
+	}
+}
+
+// maxCachedCredentials bounds the memory a CredentialCache may use; past it new
+// credentials are checked without being remembered until entries expire.
+const maxCachedCredentials = 10000
+
+// CredentialCache remembers recently verified Basic credentials, so that clients
+// sending them with every request (WebDAV mounts do) do not pay for a bcrypt
+// comparison each time. Entries are keyed by an HMAC of the email and password under
+// a per-process random key, never the password itself, and hold the password hash
+// they matched: once the password changes, the stored hash differs and the cached
+// check no longer counts.
+type CredentialCache struct {
+	mu      sync.Mutex
+	key     []byte
+	ttl     time.Duration
+	entries map[string]cachedCredential
+	now     func() time.Time
+}
+
+type cachedCredential struct {
+	passwordHash string
+	expires      time.Time
+}
+
+// NewCredentialCache creates a cache keeping verified credentials for ttl.
+func NewCredentialCache(ttl time.Duration) *CredentialCache {
+	key := make([]byte, 32)
+	rand.Read(key) // never fails since Go 1.24
+	return &CredentialCache{key: key, ttl: ttl, entries: make(map[string]cachedCredential), now: time.Now}
+}
+
+// Verify reports whether password matches passwordHash, the user's bcrypt hash,
+// answering from the cache when the same credentials matched the same hash within
+// the TTL. A nil cache always runs bcrypt.
+func (c *CredentialCache) Verify(email, password, passwordHash string) bool {
+	if c == nil {
+		return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil
+	}
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(strings.ToLower(email)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	id := string(mac.Sum(nil))
+
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok && e.passwordHash == passwordHash && c.now().Before(e.expires) {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		if ok {
+			c.mu.Lock()
+			delete(c.entries, id)
+			c.mu.Unlock()
+		}
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCachedCredentials {
+		now := c.now()
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) < maxCachedCredentials {
+		c.entries[id] = cachedCredential{passwordHash: passwordHash, expires: c.now().Add(c.ttl)}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookies the API sets for browser sessions.
+const (
+	SessionCookieName = "nb_session" // HttpOnly session ID; set only in cookie session mode
+	CSRFCookieName    = "nb_csrf"    // CSRF token the web UI echoes in CSRFHeader
+)
+
+// CookieConfig holds the per-deployment attributes of the cookies the API sets.
+type CookieConfig struct {
+	Domain   string // empty = host-only
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// ParseSameSite parses a SAMESITE setting: "lax", "strict" or "none".
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return 0, fmt.Errorf("invalid SameSite mode %q (want lax, strict or none)", s)
+}
+
+// Cookie builds a cookie with the configured attributes. A zero maxAge makes a
+// session cookie; a negative one deletes the cookie.
+func (c CookieConfig) Cookie(name, value string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   c.Domain,
+		Secure:   c.Secure,
+		HttpOnly: httpOnly,
+		SameSite: c.SameSite,
+	}
+	switch {
+	case maxAge > 0:
+		cookie.MaxAge = int(maxAge.Seconds())
+		cookie.Expires = time.Now().Add(maxAge)
+	case maxAge < 0:
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
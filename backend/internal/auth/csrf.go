@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"github.com/naratel/naratel-box/backend/internal/security"
+)
+
+// CSRFHeader carries the CSRF token on mutating requests made with a session cookie.
+const CSRFHeader = "X-CSRF-Token"
+
+// NewCSRFToken returns a random CSRF token.
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("NewCSRFToken: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CSRF protects cookie-authenticated requests with the double-submit pattern: a
+// mutating request that carries the session cookie must send the value of the CSRF
+// cookie in CSRFHeader. A cross-site page can make the browser send cookies but
+// cannot read them, so it cannot produce the header. Requests without the session
+// cookie (Bearer tokens, anonymous) are not affected; nothing ambient authenticates them.
+func CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, err := r.Cookie(SessionCookieName); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		expected, err := r.Cookie(CSRFCookieName)
+		sent := r.Header.Get(CSRFHeader)
+		if err != nil || expected.Value == "" || subtle.ConstantTimeCompare([]byte(expected.Value), []byte(sent)) != 1 {
+			logger.Warn(r.Context(), "CSRF token check failed", map[string]interface{}{
+				"path": r.URL.Path, "header_present": sent != "", "cookie_present": err == nil,
+			})
+			security.Record(r, model.SecurityCSRFFailed, 0, map[string]interface{}{
+				"header_present": sent != "", "cookie_present": err == nil,
+			})
+			respond.Error(w, http.StatusForbidden, "csrf_failed", "missing or invalid CSRF token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -14,9 +14,9 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a signed JWT for a user.
-func GenerateToken(userID int64, email, secret string, expiryHours int) (string, time.Time, error) {
-	expiresAt := time.Now().Add(time.Duration(expiryHours) * time.Hour)
+// GenerateToken creates a signed JWT for a user, valid for ttl.
+func GenerateToken(userID int64, email, secret string, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
 
 	claims := &Claims{
 		UserID: userID,
@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"github.com/naratel/naratel-box/backend/internal/security"
 )
 
 type contextKey string
@@ -16,39 +19,189 @@ const userEmailCtxKey contextKey = "user_email"
 // Middleware returns an http.Handler middleware that validates JWT from the Authorization header.
 // On success it injects user_id and user_email into the request context.
 func Middleware(jwtSecret string) func(http.Handler) http.Handler {
+	return Authenticate(jwtSecret, SessionModeBearer, nil)
+}
+
+// Authenticate is Middleware for the given session mode. In cookie and both modes a
+// session cookie is resolved through sessions; in both mode a request that also sends
+// an Authorization header is authenticated by the JWT instead.
+func Authenticate(jwtSecret string, mode SessionMode, sessions SessionLookup) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
+
+			if mode.Cookies() && (!mode.Bearer() || header == "") {
+				cookie, err := r.Cookie(SessionCookieName)
+				if err == nil && cookie.Value != "" {
+					user, err := sessions(r.Context(), HashSessionID(cookie.Value))
+					if err != nil {
+						respond.Error(w, http.StatusInternalServerError, "db_error", "failed to check session")
+						return
+					}
+					if user == nil {
+						logger.Warn(r.Context(), "Unknown or expired session", nil)
+						security.Record(r, model.SecurityTokenInvalid, 0, map[string]interface{}{"credential": "session", "reason": "unknown or expired session"})
+						respond.Error(w, http.StatusUnauthorized, "unauthorized", "session expired, sign in again")
+						return
+					}
+					next.ServeHTTP(w, withUser(r, user.UserID, user.Email))
+					return
+				}
+				if !mode.Bearer() {
+					logger.Warn(r.Context(), "Missing session cookie", nil)
+					respond.Error(w, http.StatusUnauthorized, "unauthorized", "missing session")
+					return
+				}
+			}
+
 			if header == "" {
 				logger.Warn(r.Context(), "Missing Authorization header", nil)
-				http.Error(w, `{"error":"unauthorized","message":"missing Authorization header"}`, http.StatusUnauthorized)
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "missing Authorization header")
 				return
 			}
 
 			parts := strings.SplitN(header, " ", 2)
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
 				logger.Warn(r.Context(), "Invalid Authorization format", nil)
-				http.Error(w, `{"error":"unauthorized","message":"invalid Authorization format, expected: Bearer <token>"}`, http.StatusUnauthorized)
+				security.Record(r, model.SecurityTokenInvalid, 0, map[string]interface{}{"credential": "jwt", "reason": "invalid Authorization format"})
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "invalid Authorization format, expected: Bearer <token>")
 				return
 			}
 
 			claims, err := ParseToken(parts[1], jwtSecret)
 			if err != nil {
 				logger.Warn(r.Context(), "JWT token validation failed", map[string]interface{}{"error": err.Error()})
-				http.Error(w, `{"error":"unauthorized","message":"`+err.Error()+`"}`, http.StatusUnauthorized)
+				security.Record(r, model.SecurityTokenInvalid, 0, map[string]interface{}{"credential": "jwt", "reason": err.Error()})
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", err.Error())
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), userIDCtxKey, claims.UserID)
-			ctx = context.WithValue(ctx, userEmailCtxKey, claims.Email)
-			ctx = logger.WithUserID(ctx, claims.UserID)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, withUser(r, claims.UserID, claims.Email))
 		})
 	}
 }
 
+// withUser returns r with the authenticated user in its context.
+func withUser(r *http.Request, userID int64, email string) *http.Request {
+	ctx := context.WithValue(r.Context(), userIDCtxKey, userID)
+	ctx = context.WithValue(ctx, userEmailCtxKey, email)
+	ctx = logger.WithUserID(ctx, userID)
+	return r.WithContext(ctx)
+}
+
 // GetUserID extracts the authenticated user ID from the request context.
 func GetUserID(r *http.Request) (int64, bool) {
 	id, ok := r.Context().Value(userIDCtxKey).(int64)
 	return id, ok
 }
+
+// GetUserEmail extracts the authenticated user's email from the request context.
+func GetUserEmail(r *http.Request) string {
+	email, _ := r.Context().Value(userEmailCtxKey).(string)
+	return email
+}
+
+// RequireAdmin rejects requests from users that are not admins. It must run after Middleware.
+// The role is looked up on every request (not stored in the JWT) so demotions apply immediately.
+func RequireAdmin(isAdmin func(ctx context.Context, userID int64) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			admin, err := isAdmin(r.Context(), userID)
+			if err != nil {
+				respond.Error(w, http.StatusInternalServerError, "db_error", "failed to check permissions")
+				return
+			}
+			if !admin {
+				logger.Warn(r.Context(), "Non-admin access to admin endpoint", map[string]interface{}{"user_id": userID})
+				security.Record(r, model.SecurityPermissionDenied, userID, map[string]interface{}{"required_role": "admin"})
+				respond.Error(w, http.StatusForbidden, "forbidden", "admin role required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireActive rejects requests from users whose account has been deactivated or no
+// longer exists. It must run after Middleware; the state is looked up on every request
+// so a deactivation locks out tokens that were already issued.
+func RequireActive(isActive func(ctx context.Context, userID int64) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			active, err := isActive(r.Context(), userID)
+			if err != nil {
+				respond.Error(w, http.StatusInternalServerError, "db_error", "failed to check account status")
+				return
+			}
+			if !active {
+				logger.Warn(r.Context(), "Request from deactivated account", map[string]interface{}{"user_id": userID})
+				security.Record(r, model.SecurityPermissionDenied, userID, map[string]interface{}{"reason": "account deactivated"})
+				respond.Error(w, http.StatusForbidden, "account_deactivated", "this account has been deactivated")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireTOS rejects requests from users who have not accepted the current terms of
+// service version, so changing the configured version makes everyone re-accept.
+// It must run after Middleware. An empty version disables the check.
+func RequireTOS(version string, acceptedVersion func(ctx context.Context, userID int64) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if version == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			accepted, err := acceptedVersion(r.Context(), userID)
+			if err != nil {
+				respond.Error(w, http.StatusInternalServerError, "db_error", "failed to check terms of service acceptance")
+				return
+			}
+			if accepted != version {
+				respond.Error(w, http.StatusForbidden, "tos_required", "accept the current terms of service (POST /auth/tos/accept) to continue")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireFeature rejects requests from users for whom feature is off, by the server's
+// configuration or their plan. It must run after Middleware.
+func RequireFeature(feature string, enabled func(ctx context.Context, userID int64, feature string) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r)
+			if !ok {
+				respond.Error(w, http.StatusUnauthorized, "unauthorized", "authentication required")
+				return
+			}
+			on, err := enabled(r.Context(), userID, feature)
+			if err != nil {
+				respond.Error(w, http.StatusInternalServerError, "db_error", "failed to check features")
+				return
+			}
+			if !on {
+				respond.Error(w, http.StatusForbidden, "feature_disabled", feature+" is not available on this server or your plan")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
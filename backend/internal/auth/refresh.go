@@ -0,0 +1,12 @@
+package auth
+
+// NewRefreshToken returns a random opaque refresh token. Like session IDs, refresh
+// tokens are stored only as their hash (HashRefreshToken).
+func NewRefreshToken() (string, error) {
+	return NewSessionID()
+}
+
+// HashRefreshToken returns the hash refresh tokens are stored under.
+func HashRefreshToken(token string) []byte {
+	return HashSessionID(token)
+}
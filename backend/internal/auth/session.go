@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// SessionMode selects how clients authenticate.
+type SessionMode string
+
+const (
+	SessionModeBearer SessionMode = "bearer" // JWT in the Authorization header only
+	SessionModeCookie SessionMode = "cookie" // server-side session in an HttpOnly cookie only
+	SessionModeBoth   SessionMode = "both"   // either; login sets the cookie and returns a JWT
+)
+
+// ParseSessionMode parses a SESSION_MODE setting.
+func ParseSessionMode(s string) (SessionMode, error) {
+	switch m := SessionMode(s); m {
+	case SessionModeBearer, SessionModeCookie, SessionModeBoth:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid session mode %q (want bearer, cookie or both)", s)
+}
+
+// Cookies reports whether the mode uses session cookies.
+func (m SessionMode) Cookies() bool { return m == SessionModeCookie || m == SessionModeBoth }
+
+// Bearer reports whether the mode accepts JWTs.
+func (m SessionMode) Bearer() bool { return m == SessionModeBearer || m == SessionModeBoth }
+
+// SessionUser is the user a session cookie resolves to.
+type SessionUser struct {
+	UserID int64
+	Email  string
+}
+
+// SessionLookup resolves the hash of a session ID to its user, or nil if the session
+// does not exist or has expired.
+type SessionLookup func(ctx context.Context, idHash []byte) (*SessionUser, error)
+
+// NewSessionID returns a random opaque session ID for the cookie.
+func NewSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("NewSessionID: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashSessionID returns the hash sessions are stored under.
+func HashSessionID(id string) []byte {
+	sum := sha256.Sum256([]byte(id))
+	return sum[:]
+}
@@ -0,0 +1,200 @@
+package block
+
+import (
+	"container/list"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+var (
+	fileCacheHits      = expvar.NewInt("file_cache_hits")
+	fileCacheMisses    = expvar.NewInt("file_cache_misses")
+	fileCacheEvictions = expvar.NewInt("file_cache_evictions")
+	fileCacheBytes     = expvar.NewInt("file_cache_bytes")
+)
+
+// ErrCacheBusy is returned by FileCache.Fill when the entry is already cached or
+// another request is filling it; the caller should stream from S3 instead.
+var ErrCacheBusy = errors.New("file cache entry is busy")
+
+var cacheKeyPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// FileCache keeps fully assembled small files on local disk so that popular files
+// are read from S3 once rather than on every request. Entries are keyed by the
+// file's content ETag, so a new version is a different entry; the previous one is
+// dropped as soon as the new one is stored, and the least recently used entries
+// are evicted once the cache grows past its size limit.
+type FileCache struct {
+	dir          string
+	maxBytes     int64 // total size of all entries
+	maxFileBytes int64 // largest file that is cached
+
+	mu      sync.Mutex
+	lru     *list.List               // of *cacheEntry, most recently used first
+	entries map[string]*list.Element // key -> lru element
+	byFile  map[int64]string         // file ID -> key of its cached version
+	filling map[string]bool
+	size    int64
+}
+
+type cacheEntry struct {
+	key    string
+	fileID int64
+	size   int64
+}
+
+// NewFileCache creates dir if needed and removes entries left by a previous run,
+// which the in-memory index no longer knows about.
+func NewFileCache(dir string, maxBytes, maxFileBytes int64) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("block.NewFileCache: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("block.NewFileCache: %w", err)
+	}
+	for _, e := range entries {
+		if cacheKeyPattern.MatchString(e.Name()) || filepath.Ext(e.Name()) == ".fill" {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return &FileCache{
+		dir:          dir,
+		maxBytes:     maxBytes,
+		maxFileBytes: maxFileBytes,
+		lru:          list.New(),
+		entries:      make(map[string]*list.Element),
+		byFile:       make(map[int64]string),
+		filling:      make(map[string]bool),
+	}, nil
+}
+
+// Cacheable reports whether a file of size bytes is small enough to be cached.
+func (c *FileCache) Cacheable(size int64) bool {
+	return size > 0 && size <= c.maxFileBytes && size <= c.maxBytes
+}
+
+// Open returns the cached copy of key, or false on a miss.
+func (c *FileCache) Open(key string) (*os.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		fileCacheMisses.Add(1)
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(c.dir, key))
+	if err != nil {
+		c.removeLocked(el)
+		fileCacheMisses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	fileCacheHits.Add(1)
+	return f, true
+}
+
+// Fill stores version key of fileID by running write against a temporary file,
+// which must receive exactly size bytes. Any older version of the same file is
+// dropped, then least recently used entries are evicted to stay under the limit.
+func (c *FileCache) Fill(fileID int64, key string, size int64, write func(io.Writer) error) error {
+	if !cacheKeyPattern.MatchString(key) {
+		return fmt.Errorf("block.FileCache.Fill: invalid key %q", key)
+	}
+	if !c.Cacheable(size) {
+		return fmt.Errorf("block.FileCache.Fill: %d bytes is too large to cache", size)
+	}
+
+	c.mu.Lock()
+	if _, ok := c.entries[key]; ok || c.filling[key] {
+		c.mu.Unlock()
+		return ErrCacheBusy
+	}
+	c.filling[key] = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.filling, key)
+		c.mu.Unlock()
+	}()
+
+	tmp, err := os.CreateTemp(c.dir, "*.fill")
+	if err != nil {
+		return fmt.Errorf("block.FileCache.Fill: %w", err)
+	}
+	cw := &countingWriter{w: tmp}
+	err = write(cw)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && cw.n != size {
+		err = fmt.Errorf("wrote %d of %d bytes", cw.n, size)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("block.FileCache.Fill: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.byFile[fileID]; ok && old != key {
+		if el, ok := c.entries[old]; ok {
+			c.removeLocked(el)
+		}
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, fileID: fileID, size: size})
+	c.byFile[fileID] = key
+	c.size += size
+	fileCacheBytes.Add(size)
+	for c.size > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+		fileCacheEvictions.Add(1)
+	}
+	return nil
+}
+
+// Forget drops the cached version of fileID, if any, e.g. once the file is deleted.
+func (c *FileCache) Forget(fileID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.byFile[fileID]; ok {
+		if el, ok := c.entries[key]; ok {
+			c.removeLocked(el)
+		}
+	}
+}
+
+// removeLocked drops an entry and its file. Readers that already opened it keep
+// their handle until they close it. Must be called with c.mu held.
+func (c *FileCache) removeLocked(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	if c.byFile[e.fileID] == e.key {
+		delete(c.byFile, e.fileID)
+	}
+	c.size -= e.size
+	fileCacheBytes.Add(-e.size)
+	os.Remove(filepath.Join(c.dir, e.key))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
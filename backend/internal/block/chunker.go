@@ -0,0 +1,159 @@
+package block
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Chunking selects how content is split into blocks.
+//
+// Fixed-size blocks are cheap to compute, but inserting or removing bytes shifts every
+// block after the edit, so an edited copy of a file no longer deduplicates against the
+// original. Content-defined chunking (FastCDC) cuts where the content itself matches a
+// pattern, so boundaries move with the content and shifted data still deduplicates.
+// Switching modes is safe: existing files keep their blocks, but new uploads of the same
+// content do not deduplicate against blocks cut the other way.
+type Chunking string
+
+const (
+	ChunkingFixed   Chunking = "fixed"
+	ChunkingFastCDC Chunking = "fastcdc"
+)
+
+// ParseChunking validates a BLOCK_CHUNKING value.
+func ParseChunking(s string) (Chunking, error) {
+	switch c := Chunking(s); c {
+	case ChunkingFixed, ChunkingFastCDC:
+		return c, nil
+	default:
+		return "", fmt.Errorf("block.ParseChunking: unknown chunking %q (want %q or %q)", s, ChunkingFixed, ChunkingFastCDC)
+	}
+}
+
+// Chunker decides where Process cuts content into blocks.
+type Chunker struct {
+	min, avg, max int
+	maskS, maskL  uint64 // FastCDC cut masks before and after the average size; 0 for fixed-size blocks
+}
+
+// Fixed returns a Chunker cutting blocks of exactly size bytes, except for the last one.
+func Fixed(size int) Chunker {
+	return Chunker{min: size, avg: size, max: size}
+}
+
+// FastCDC returns a content-defined Chunker cutting blocks of minSize to maxSize bytes,
+// averageSize on average. The sizes must satisfy 64 <= minSize <= averageSize <= maxSize.
+func FastCDC(minSize, averageSize, maxSize int) (Chunker, error) {
+	if minSize < 64 || minSize > averageSize || averageSize > maxSize {
+		return Chunker{}, fmt.Errorf("block.FastCDC: sizes must satisfy 64 <= min <= avg <= max, got %d/%d/%d", minSize, averageSize, maxSize)
+	}
+	// Normalized chunking: a stricter mask before the average size and a looser one
+	// after it keep most blocks close to the average.
+	b := bits.Len(uint(averageSize)) - 1
+	return Chunker{
+		min:   minSize,
+		avg:   averageSize,
+		max:   maxSize,
+		maskS: cutMask(b + 2),
+		maskL: cutMask(b - 2),
+	}, nil
+}
+
+// cutMask selects the n highest bits of the gear hash, which depend on the last 64
+// bytes rather than only the last few.
+func cutMask(n int) uint64 {
+	n = min(max(n, 1), 63)
+	return ^uint64(0) << (64 - n)
+}
+
+// ContentDefined reports whether c cuts blocks at content-defined boundaries.
+func (c Chunker) ContentDefined() bool {
+	return c.maskS != 0
+}
+
+// BlockSize returns the nominal block size: the size of every full block, or the
+// average size for content-defined chunking. Files record it as their block size.
+func (c Chunker) BlockSize() int {
+	return c.avg
+}
+
+// MaxBlockSize returns the largest block c cuts.
+func (c Chunker) MaxBlockSize() int {
+	return c.max
+}
+
+// cut returns the length of the block at the start of data. data holds up to
+// MaxBlockSize bytes; it only holds fewer at the end of the content.
+func (c Chunker) cut(data []byte) int {
+	n := min(len(data), c.max)
+	if !c.ContentDefined() || n <= c.min {
+		return n
+	}
+	normal := min(c.avg, n)
+	var fp uint64
+	i := c.min
+	for ; i < normal; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = fp<<1 + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// splitter reads content block by block as cut by a Chunker, holding one
+// MaxBlockSize buffer.
+type splitter struct {
+	r   io.Reader
+	c   Chunker
+	buf []byte
+	n   int // bytes buffered
+	eof bool
+}
+
+func newSplitter(r io.Reader, c Chunker) *splitter {
+	return &splitter{r: r, c: c, buf: make([]byte, c.max)}
+}
+
+// next returns the next block in a new slice, or io.EOF after the last one.
+func (s *splitter) next() ([]byte, error) {
+	if !s.eof && s.n < len(s.buf) {
+		m, err := io.ReadFull(s.r, s.buf[s.n:])
+		s.n += m
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			s.eof = true
+		case err != nil:
+			return nil, err
+		}
+	}
+	if s.n == 0 {
+		return nil, io.EOF
+	}
+	size := s.c.cut(s.buf[:s.n])
+	data := make([]byte, size)
+	copy(data, s.buf[:size])
+	s.n = copy(s.buf, s.buf[size:s.n])
+	return data, nil
+}
+
+// gear maps each byte to a pseudo-random value for the FastCDC rolling hash. It must
+// never change: blocks cut with another table do not deduplicate against stored ones.
+var gear = func() (t [256]uint64) {
+	x := uint64(0x6e61726174656c62) // splitmix64 with a fixed seed
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
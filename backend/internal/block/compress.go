@@ -0,0 +1,101 @@
+package block
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects how new blocks are compressed before they are uploaded.
+//
+// Blocks are hashed and deduplicated by their uncompressed content; compression only
+// changes the object stored in S3, which is decompressed again whenever the block is
+// read. A block keeps the compression it was stored with, so changing the setting
+// only affects blocks stored afterwards.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionZstd Compression = "zstd"
+)
+
+// minCompressionGain is the share of a block (1/16) compression must save for the
+// block to be stored compressed; smaller gains are not worth decompressing for.
+const minCompressionGain = 16
+
+// ParseCompression validates a BLOCK_COMPRESSION value.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case CompressionNone, CompressionZstd:
+		return c, nil
+	default:
+		return "", fmt.Errorf("block.ParseCompression: unknown compression %q (want %q or %q)", s, CompressionNone, CompressionZstd)
+	}
+}
+
+// incompressibleTypes are MIME types whose content is compressed already; their
+// blocks are stored as they are without trying.
+var incompressibleTypes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/heic", "image/heif",
+	"video/", "audio/mpeg", "audio/ogg", "audio/aac", "audio/mp4", "audio/webm", "audio/flac", "audio/opus",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-7z-compressed",
+	"application/x-rar-compressed", "application/vnd.rar", "application/x-xz", "application/x-bzip2",
+	"application/zstd", "application/x-zstd", "application/java-archive", "application/epub+zip",
+	"application/vnd.openxmlformats-officedocument.", "application/vnd.oasis.opendocument.",
+	"font/woff", "font/woff2",
+}
+
+// worthCompressing reports whether content of mimeType may shrink when compressed.
+func worthCompressing(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	for _, t := range incompressibleTypes {
+		prefix := strings.HasSuffix(t, "/") || strings.HasSuffix(t, ".") // a family of types
+		if mimeType == t || prefix && strings.HasPrefix(mimeType, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// zstdEncoder compresses every block; EncodeAll is safe for concurrent use.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+
+// compress returns data compressed with c.
+func compress(c Compression, data []byte) ([]byte, error) {
+	if c != CompressionZstd {
+		return nil, fmt.Errorf("block: cannot compress with %q", c)
+	}
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+// decompress wraps body, a block object stored with compression c, to read the
+// block's content.
+func decompress(body io.ReadCloser, c string) (io.ReadCloser, error) {
+	switch Compression(c) {
+	case "":
+		return body, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		return &decompressed{Decoder: zr, body: body}, nil
+	default:
+		body.Close()
+		return nil, fmt.Errorf("block: unknown compression %q", c)
+	}
+}
+
+// decompressed releases both the decoder and the object body.
+type decompressed struct {
+	*zstd.Decoder
+	body io.Closer
+}
+
+func (d *decompressed) Close() error {
+	d.Decoder.Close()
+	return d.body.Close()
+}
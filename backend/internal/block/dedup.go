@@ -0,0 +1,47 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// DedupScope controls which uploads may share a stored block.
+//
+// Global dedup saves the most space, but an upload that completes instantly
+// reveals that someone else already stored the same content. User scope
+// namespaces block hashes per user so dedup only happens within one account.
+type DedupScope string
+
+const (
+	DedupGlobal DedupScope = "global"
+	DedupUser   DedupScope = "user"
+)
+
+// ParseDedupScope validates a DEDUP_SCOPE value.
+func ParseDedupScope(s string) (DedupScope, error) {
+	switch scope := DedupScope(s); scope {
+	case DedupGlobal, DedupUser:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("block.ParseDedupScope: unknown scope %q (want %q or %q)", s, DedupGlobal, DedupUser)
+	}
+}
+
+// key returns the block hash (and S3 key) used for content hashed to contentHash
+// and uploaded by userID. Switching scopes never breaks existing files: old blocks
+// keep their keys, new uploads simply stop deduplicating against them.
+func (s DedupScope) key(userID int64, contentHash string) string {
+	if s != DedupUser {
+		return contentHash
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("user:%d:%s", userID, contentHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// UserKey returns the hash userID's blocks have in the user scope for content
+// recorded under hash in the global scope, so blocks of one user's files stored under
+// either scope can be matched without reading them.
+func UserKey(userID int64, hash string) string {
+	return DedupUser.key(userID, hash)
+}
@@ -0,0 +1,50 @@
+package block
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// KeySigner derives the S3 object key of a block from its hash.
+//
+// Keying objects by the raw SHA-256 lets anyone who can list the bucket confirm
+// that a known file is stored, just by hashing it. With a secret the key is
+// HMAC-SHA256(secret, hash), which cannot be computed without the secret. Blocks
+// keep the key they were stored under, so changing the secret (or setting one on an
+// existing deployment) only affects new blocks until a rekey job moves the old ones.
+type KeySigner struct {
+	secret []byte
+}
+
+// NewKeySigner returns a KeySigner for secret. An empty secret keeps the legacy
+// behaviour of using the hash itself as the key.
+func NewKeySigner(secret string) KeySigner {
+	return KeySigner{secret: []byte(secret)}
+}
+
+// Signed reports whether keys are derived with a secret.
+func (k KeySigner) Signed() bool {
+	return len(k.secret) > 0
+}
+
+// Key returns the S3 object key for a block with the given hash.
+func (k KeySigner) Key(hash string) string {
+	if !k.Signed() {
+		return hash
+	}
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ObjectKey returns the S3 object key for a block with the given hash stored with
+// compression (empty when stored as is). The algorithm is part of the key, so the
+// compressed and uncompressed objects of a block never overwrite each other.
+func (k KeySigner) ObjectKey(hash, compression string) string {
+	key := k.Key(hash)
+	if compression != "" {
+		key += "." + compression
+	}
+	return key
+}
@@ -0,0 +1,110 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSaturated is returned by Limiter.Acquire when no upload slot is available
+// and the wait queue is full or the wait timed out.
+var ErrSaturated = errors.New("upload capacity saturated")
+
+var (
+	uploadSlotsInUse = expvar.NewInt("block_upload_slots_in_use")
+	uploadQueueLen   = expvar.NewInt("block_upload_queue_length")
+	uploadsShed      = expvar.NewInt("block_uploads_rejected")
+)
+
+// Limiter caps how many Processor.Process runs execute at once. Each run spins
+// up BLOCK_WORKERS goroutines holding a block in memory, so unbounded concurrent
+// uploads can exhaust the pod memory limit and overwhelm the NAS.
+// Callers beyond the limit wait in a bounded queue; beyond that they are shed.
+type Limiter struct {
+	slots     atomic.Pointer[chan struct{}] // nil = unlimited
+	waiting   atomic.Int64
+	maxQueue  atomic.Int64
+	queueWait atomic.Int64 // time.Duration
+}
+
+// NewLimiter allows maxConcurrent runs, up to maxQueue waiters, each waiting at most queueWait.
+// maxConcurrent <= 0 disables limiting.
+func NewLimiter(maxConcurrent, maxQueue int, queueWait time.Duration) *Limiter {
+	l := &Limiter{}
+	l.Resize(maxConcurrent, maxQueue, queueWait)
+	return l
+}
+
+// Resize changes the limits of a Limiter in use. Runs holding a slot keep it, and
+// callers already waiting keep waiting for one of the old slots, so until they are
+// done up to maxConcurrent new runs can execute alongside them.
+func (l *Limiter) Resize(maxConcurrent, maxQueue int, queueWait time.Duration) {
+	l.maxQueue.Store(int64(maxQueue))
+	l.queueWait.Store(int64(queueWait))
+	current := l.slots.Load()
+	switch {
+	case maxConcurrent <= 0:
+		l.slots.Store(nil)
+	case current == nil || cap(*current) != maxConcurrent:
+		slots := make(chan struct{}, maxConcurrent)
+		l.slots.Store(&slots)
+	}
+}
+
+// RetryAfter is the delay suggested to shed clients.
+func (l *Limiter) RetryAfter() time.Duration {
+	if wait := time.Duration(l.queueWait.Load()); wait > 0 {
+		return wait
+	}
+	return 5 * time.Second
+}
+
+// Acquire reserves a processing slot. The returned release func must be called
+// once processing ends. Returns ErrSaturated when the caller should back off.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	ptr := l.slots.Load()
+	if ptr == nil {
+		return func() {}, nil
+	}
+	slots := *ptr
+
+	release := func() {
+		<-slots
+		uploadSlotsInUse.Add(-1)
+	}
+
+	// Fast path: free slot.
+	select {
+	case slots <- struct{}{}:
+		uploadSlotsInUse.Add(1)
+		return release, nil
+	default:
+	}
+
+	if l.waiting.Add(1) > l.maxQueue.Load() {
+		l.waiting.Add(-1)
+		uploadsShed.Add(1)
+		return nil, ErrSaturated
+	}
+	uploadQueueLen.Add(1)
+	defer func() {
+		l.waiting.Add(-1)
+		uploadQueueLen.Add(-1)
+	}()
+
+	timer := time.NewTimer(time.Duration(l.queueWait.Load()))
+	defer timer.Stop()
+
+	select {
+	case slots <- struct{}{}:
+		uploadSlotsInUse.Add(1)
+		return release, nil
+	case <-timer.C:
+		uploadsShed.Add(1)
+		return nil, ErrSaturated
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package block
+
+// Policy picks the block size for a file from its total size.
+//
+// Small files waste a DB row and an S3 round trip per oversized block, while
+// multi-GB files at the default size produce thousands of blocks. Identical
+// content split with different block sizes does not deduplicate, so the tiers
+// are coarse on purpose: most files land in the default tier. With content-defined
+// chunking the tiers only size resumable upload chunks.
+type Policy struct {
+	SmallBlockSize   int     // used when the file is smaller than SmallFileBelow
+	DefaultBlockSize int     // used in between, and when the size is unknown
+	LargeBlockSize   int     // used when the file is at least LargeFileFrom
+	SmallFileBelow   int64   // 0 disables the small tier
+	LargeFileFrom    int64   // 0 disables the large tier
+	CDC              Chunker // when content-defined (see FastCDC), splits every file instead of the tiers
+}
+
+// BlockSizeFor returns the block size in bytes for a file of totalSize bytes.
+// A negative totalSize means unknown.
+func (p Policy) BlockSizeFor(totalSize int64) int {
+	switch {
+	case totalSize < 0:
+		return p.DefaultBlockSize
+	case p.SmallFileBelow > 0 && totalSize < p.SmallFileBelow && p.SmallBlockSize > 0:
+		return p.SmallBlockSize
+	case p.LargeFileFrom > 0 && totalSize >= p.LargeFileFrom && p.LargeBlockSize > 0:
+		return p.LargeBlockSize
+	default:
+		return p.DefaultBlockSize
+	}
+}
+
+// ChunkerFor returns how to split a file of totalSize bytes (-1 if unknown).
+func (p Policy) ChunkerFor(totalSize int64) Chunker {
+	if p.CDC.ContentDefined() {
+		return p.CDC
+	}
+	return Fixed(p.BlockSizeFor(totalSize))
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"expvar"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/naratel/naratel-box/backend/internal/logger"
 	"github.com/naratel/naratel-box/backend/internal/model"
@@ -15,56 +17,137 @@ import (
 	"github.com/naratel/naratel-box/backend/internal/storage"
 )
 
-const maxWorkers = 8 // concurrent block upload workers
+// DefaultWorkers is how many blocks one Process run stores concurrently unless
+// SetWorkers says otherwise.
+const DefaultWorkers = 8
+
+// Worker pool occupancy, published on the admin /debug/vars endpoint.
+var (
+	activeProcesses = expvar.NewInt("block_active_uploads") // Process calls in progress
+	busyWorkers     = expvar.NewInt("block_busy_workers")   // workers currently handling a block
+)
+
+// dedupStats totals the Stats of every completed Process call: blocks_new,
+// blocks_deduped, logical_bytes and uploaded_bytes. A falling share of deduplicated
+// blocks for the same workload points at a chunking regression.
+var dedupStats = expvar.NewMap("block_dedup")
+
+// Stats describes how Process stored one piece of content.
+type Stats struct {
+	Blocks        int   // blocks the content was split into
+	BlocksNew     int   // blocks that did not exist yet
+	BlocksDeduped int   // blocks that already existed and gained a reference
+	LogicalBytes  int64 // size of the content
+	UploadedBytes int64 // bytes written to S3; below LogicalBytes when blocks were deduplicated or compressed
+
+	SHA256      string   // hex SHA-256 of the whole content
+	BlockSHA256 []string // hex SHA-256 of each block's content, in order
+}
+
+// add counts one processed block into s.
+func (s *Stats) add(res blockResult) {
+	s.Blocks++
+	if res.inserted {
+		s.BlocksNew++
+	} else {
+		s.BlocksDeduped++
+	}
+	s.UploadedBytes += res.uploaded
+}
 
 // blockJob carries a single block's data to a worker.
 type blockJob struct {
-	index int
-	data  []byte
-	hash  string
+	index    int
+	data     []byte
+	hash     string // dedup key: content hash, namespaced per user in DedupUser scope
+	compress bool   // the content type may compress
 }
 
 // blockResult is the result from a worker after processing a block.
 type blockResult struct {
-	index   int
-	blockID int64
-	err     error
+	index    int
+	blockID  int64
+	inserted bool  // the block row was new
+	uploaded int64 // bytes written to S3 for it
+	err      error
 }
 
 // Processor handles block splitting, hashing, dedup, and S3 upload.
 type Processor struct {
-	blockSize  int
-	blockRepo  *repository.BlockRepository
-	s3         *storage.S3Client
+	policy      Policy
+	dedup       DedupScope
+	keys        KeySigner
+	compression Compression
+	workers     atomic.Int64 // concurrent block workers per Process run
+	blockRepo   *repository.BlockRepository
+	s3          *storage.S3Client
 }
 
-// NewProcessor creates a Processor with the given block size in bytes.
-func NewProcessor(blockSizeBytes int, blockRepo *repository.BlockRepository, s3 *storage.S3Client) *Processor {
-	return &Processor{
-		blockSize: blockSizeBytes,
-		blockRepo: blockRepo,
-		s3:        s3,
+// NewProcessor creates a Processor that sizes blocks according to policy,
+// deduplicates them within dedup scope and stores new blocks under keys from keys,
+// compressed with compression where it pays off.
+func NewProcessor(policy Policy, dedup DedupScope, keys KeySigner, compression Compression, blockRepo *repository.BlockRepository, s3 *storage.S3Client) *Processor {
+	p := &Processor{
+		policy:      policy,
+		dedup:       dedup,
+		keys:        keys,
+		compression: compression,
+		blockRepo:   blockRepo,
+		s3:          s3,
 	}
+	p.workers.Store(DefaultWorkers)
+	return p
 }
 
-// Process streams r block-by-block into a worker pool.
-// Only maxWorkers blocks are held in memory at any time — O(workers × blockSize)
+// SetWorkers changes how many blocks each Process run stores concurrently. Runs in
+// progress keep the count they started with.
+func (p *Processor) SetWorkers(n int) error {
+	if n < 1 {
+		return fmt.Errorf("block.SetWorkers: need at least 1 worker, got %d", n)
+	}
+	p.workers.Store(int64(n))
+	return nil
+}
+
+// BlockSizeFor returns the fixed block size for a file of totalSize bytes (-1 if unknown).
+// Resumable uploads use it as their chunk size whatever the chunking mode.
+func (p *Processor) BlockSizeFor(totalSize int64) int {
+	return p.policy.BlockSizeFor(totalSize)
+}
+
+// ChunkerFor returns the Chunker Process should use for a file of totalSize bytes (-1 if unknown).
+func (p *Processor) ChunkerFor(totalSize int64) Chunker {
+	return p.policy.ChunkerFor(totalSize)
+}
+
+// Process streams r block-by-block, cut by chunker, into a worker pool.
+// Only a worker count of blocks are held in memory at any time — O(workers × max block size)
 // memory regardless of total file size, so a 10GB file uses the same RAM as a 10MB file.
-func (p *Processor) Process(ctx context.Context, r io.Reader) ([]int64, int64, error) {
-	// jobCh is bounded to maxWorkers so the reader blocks when all workers are busy,
-	// preventing unbounded memory growth.
-	jobCh    := make(chan blockJob, maxWorkers)
-	resultCh := make(chan blockResult, maxWorkers)
+// Callers should hold a Limiter slot so the number of concurrent runs stays bounded.
+// userID owns the upload and namespaces block hashes when dedup is per-user.
+// New blocks of content of mimeType are compressed unless it is compressed already.
+// Returns the block IDs in order and how the content was stored.
+func (p *Processor) Process(ctx context.Context, userID int64, r io.Reader, chunker Chunker, mimeType string) ([]int64, Stats, error) {
+	activeProcesses.Add(1)
+	defer activeProcesses.Add(-1)
+
+	// jobCh is bounded to the worker count so the reader blocks when all workers are
+	// busy, preventing unbounded memory growth.
+	workers  := int(p.workers.Load())
+	jobCh    := make(chan blockJob, workers)
+	resultCh := make(chan blockResult, workers)
 
 	// Start the fixed worker pool.
 	var wg sync.WaitGroup
-	for i := 0; i < maxWorkers; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for job := range jobCh {
-				blockID, err := p.processBlock(ctx, job)
-				resultCh <- blockResult{index: job.index, blockID: blockID, err: err}
+				busyWorkers.Add(1)
+				res := p.processBlock(ctx, job)
+				busyWorkers.Add(-1)
+				resultCh <- res
 			}
 		}()
 	}
@@ -79,26 +162,28 @@ func (p *Processor) Process(ctx context.Context, r io.Reader) ([]int64, int64, e
 	// This goroutine blocks on jobCh when all workers are busy, keeping memory bounded.
 	var totalBytes int64
 	var readErr   error
+	whole       := sha256.New()
+	var blockSums []string
+	compress    := p.compression != CompressionNone && worthCompressing(mimeType)
 	go func() {
 		defer close(jobCh)
-		buf   := make([]byte, p.blockSize)
+		split := newSplitter(r, chunker)
 		index := 0
 		for {
-			n, err := io.ReadFull(r, buf)
-			if n > 0 {
-				data := make([]byte, n)
-				copy(data, buf[:n])
-				totalBytes += int64(n)
-				jobCh <- blockJob{index: index, data: data, hash: sha256Block(data)}
-				index++
-			}
-			if err == io.EOF || err == io.ErrUnexpectedEOF {
+			data, err := split.next()
+			if err == io.EOF {
 				break
 			}
 			if err != nil {
 				readErr = fmt.Errorf("splitStream read error: %w", err)
 				return
 			}
+			totalBytes += int64(len(data))
+			whole.Write(data)
+			sum := sha256Block(data)
+			blockSums = append(blockSums, sum)
+			jobCh <- blockJob{index: index, data: data, hash: p.dedup.key(userID, sum), compress: compress}
+			index++
 		}
 	}()
 
@@ -106,60 +191,137 @@ func (p *Processor) Process(ctx context.Context, r io.Reader) ([]int64, int64, e
 	var results []blockResult
 	for res := range resultCh {
 		if res.err != nil {
-			return nil, 0, fmt.Errorf("worker error at block %d: %w", res.index, res.err)
+			return nil, Stats{}, fmt.Errorf("worker error at block %d: %w", res.index, res.err)
 		}
 		results = append(results, res)
 	}
 
 	if readErr != nil {
-		return nil, 0, readErr
+		return nil, Stats{}, readErr
 	}
 
 	ordered := make([]int64, len(results))
+	stats := Stats{LogicalBytes: totalBytes, SHA256: hex.EncodeToString(whole.Sum(nil)), BlockSHA256: blockSums}
 	for _, res := range results {
 		ordered[res.index] = res.blockID
+		stats.add(res)
 	}
-	return ordered, totalBytes, nil
+	dedupStats.Add("blocks_new", int64(stats.BlocksNew))
+	dedupStats.Add("blocks_deduped", int64(stats.BlocksDeduped))
+	dedupStats.Add("logical_bytes", stats.LogicalBytes)
+	dedupStats.Add("uploaded_bytes", stats.UploadedBytes)
+	return ordered, stats, nil
 }
 
-// processBlock handles one block: check dedup → upload if new → return block ID.
-func (p *Processor) processBlock(ctx context.Context, job blockJob) (int64, error) {
-	// Check dedup: does this hash already exist?
+// Resplit stores the content of blocks, read back from S3 in order, split again by
+// chunker, as Process does for an upload. It turns content that arrived in fixed-size
+// pieces into content-defined blocks. The old blocks are left as they are.
+func (p *Processor) Resplit(ctx context.Context, userID int64, blocks []*model.Block, chunker Chunker, mimeType string) ([]int64, Stats, error) {
+	pr, pw := io.Pipe()
+	// Closing the reader stops the copy if Process gives up early.
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(BlocksToStream(ctx, blocks, p.s3, pw))
+	}()
+	return p.Process(ctx, userID, pr, chunker, mimeType)
+}
+
+// processBlock handles one block: upload if unseen → take a reference → return block ID.
+func (p *Processor) processBlock(ctx context.Context, job blockJob) blockResult {
+	res := blockResult{index: job.index}
+
+	// Upload first when the hash is unknown so a new block row never points at a
+	// missing object for long. Objects are content-addressed, so re-uploading is harmless.
 	existing, err := p.blockRepo.FindByHash(ctx, job.hash)
 	if err != nil {
-		return 0, fmt.Errorf("processBlock FindByHash: %w", err)
+		res.err = fmt.Errorf("processBlock FindByHash: %w", err)
+		return res
 	}
-
+	var obj stored
 	if existing != nil {
-		// ── DEDUP HIT: skip upload, just bump ref count ──
-		if err := p.blockRepo.IncrementRefCount(ctx, existing.ID); err != nil {
-			return 0, err
+		obj = stored{key: existing.S3Key, compression: existing.Compression, size: existing.StoredBytes}
+	} else {
+		obj = p.encode(job)
+		if res.err = p.putBlock(ctx, job, &obj); res.err != nil {
+			return res
+		}
+		res.uploaded += int64(len(obj.data))
+	}
+
+	blockID, inserted, err := p.blockRepo.Acquire(ctx, job.hash, obj.key, int64(len(job.data)), obj.compression, obj.size)
+	if err != nil {
+		res.err = fmt.Errorf("processBlock Acquire: %w", err)
+		return res
+	}
+	res.blockID, res.inserted = blockID, inserted
+
+	// A new row means the key may have been garbage collected in between, and the
+	// deletion worker may already have removed the object (even one we just uploaded).
+	if inserted {
+		if exists, _ := p.s3.ObjectExists(ctx, obj.key); !exists {
+			if res.err = p.putBlock(ctx, job, &obj); res.err != nil {
+				_ = p.blockRepo.Release(ctx, blockID)
+				return res
+			}
+			res.uploaded += int64(len(obj.data))
 		}
+	}
+
+	if inserted {
+		logger.Info(ctx, "New block uploaded to S3", map[string]interface{}{
+			"block_index": job.index, "block_id": blockID, "hash": job.hash, "size_bytes": len(job.data),
+			"stored_bytes": obj.size, "compression": obj.compression,
+		})
+	} else {
 		logger.Info(ctx, "Block deduplication hit", map[string]interface{}{
-			"block_index": job.index, "block_id": existing.ID, "hash": job.hash, "size_bytes": len(job.data),
+			"block_index": job.index, "block_id": blockID, "hash": job.hash, "size_bytes": len(job.data),
 		})
-		return existing.ID, nil
 	}
+	return res
+}
+
+// stored is the S3 object of a block: its key, compression ("" for none), size and,
+// once encoded, its bytes.
+type stored struct {
+	key         string
+	compression string
+	size        int64
+	data        []byte
+}
+
+// encode returns the object to store for job's block: compressed when the content
+// type may compress and compression saves at least 1/minCompressionGain of it.
+func (p *Processor) encode(job blockJob) stored {
+	if job.compress {
+		data, err := compress(p.compression, job.data)
+		if err == nil && len(data) <= len(job.data)-len(job.data)/minCompressionGain {
+			c := string(p.compression)
+			return stored{key: p.keys.ObjectKey(job.hash, c), compression: c, size: int64(len(data)), data: data}
+		}
+	}
+	return stored{key: p.keys.ObjectKey(job.hash, ""), size: int64(len(job.data)), data: job.data}
+}
 
-	// ── NEW BLOCK: upload to S3 then register in DB ──
-	s3Key := job.hash // S3 object key == SHA-256 hex
-	if err := p.s3.PutObject(ctx, s3Key, bytes.NewReader(job.data), int64(len(job.data))); err != nil {
+// putBlock uploads obj, encoding job's block the way obj is stored first if that has
+// not been done yet (an existing block whose object went missing).
+func (p *Processor) putBlock(ctx context.Context, job blockJob, obj *stored) error {
+	if obj.data == nil {
+		obj.data = job.data
+		if obj.compression != "" {
+			data, err := compress(Compression(obj.compression), job.data)
+			if err != nil {
+				return fmt.Errorf("processBlock: %w", err)
+			}
+			obj.data = data
+		}
+	}
+	if err := p.s3.PutObject(ctx, obj.key, bytes.NewReader(obj.data), int64(len(obj.data))); err != nil {
 		logger.ErrorLog(ctx, "Block S3 upload failed", logger.ErrorDetails{
 			Code: "S3_PUT_ERR", Details: fmt.Sprintf("index=%d hash=%s: %s", job.index, job.hash, err.Error()),
 		})
-		return 0, fmt.Errorf("processBlock PutObject: %w", err)
+		return fmt.Errorf("processBlock PutObject: %w", err)
 	}
-
-	newBlock, err := p.blockRepo.Create(ctx, job.hash, s3Key, int64(len(job.data)))
-	if err != nil {
-		return 0, fmt.Errorf("processBlock Create block record: %w", err)
-	}
-
-	logger.Info(ctx, "New block uploaded to S3", map[string]interface{}{
-		"block_index": job.index, "block_id": newBlock.ID, "hash": job.hash, "size_bytes": len(job.data),
-	})
-
-	return newBlock.ID, nil
+	return nil
 }
 
 // sha256Block returns the hex-encoded SHA-256 hash of data.
@@ -168,10 +330,11 @@ func sha256Block(data []byte) string {
 	return hex.EncodeToString(sum[:])
 }
 
-// BlocksToStream fetches blocks from S3 in order and writes them to w.
+// BlocksToStream fetches blocks from S3 in order and writes them to w, decompressing
+// compressed ones.
 func BlocksToStream(ctx context.Context, blocks []*model.Block, s3 *storage.S3Client, w io.Writer) error {
 	for _, b := range blocks {
-		body, err := s3.GetObject(ctx, b.S3Key)
+		body, err := openBlock(ctx, s3, b)
 		if err != nil {
 			logger.ErrorLog(ctx, "Block stream S3 fetch failed", logger.ErrorDetails{
 				Code: "S3_GET_ERR", Details: fmt.Sprintf("s3_key=%s: %s", b.S3Key, err.Error()),
@@ -189,3 +352,70 @@ func BlocksToStream(ctx context.Context, blocks []*model.Block, s3 *storage.S3Cl
 	}
 	return nil
 }
+
+// BlocksRangeToStream writes length bytes starting at offset of the file made of
+// blocks to w. Blocks entirely outside the range are skipped and partially covered
+// ones are fetched with ranged S3 reads, so a resume near the end of a large file
+// costs no more than the bytes it needs. A compressed block cannot be read from the
+// middle, so it is fetched whole and decompressed up to the end of the range.
+func BlocksRangeToStream(ctx context.Context, blocks []*model.Block, s3 *storage.S3Client, w io.Writer, offset, length int64) error {
+	var pos int64 // file offset of the current block
+	end := offset + length
+	for _, b := range blocks {
+		blockStart, blockEnd := pos, pos+b.SizeBytes
+		pos = blockEnd
+		if blockEnd <= offset {
+			continue
+		}
+		if blockStart >= end {
+			break
+		}
+
+		from := max(offset, blockStart) - blockStart
+		to := min(end, blockEnd) - blockStart - 1
+		body, err := openBlockRange(ctx, s3, b, from, to)
+		if err != nil {
+			logger.ErrorLog(ctx, "Block stream S3 fetch failed", logger.ErrorDetails{
+				Code: "S3_GET_ERR", Details: fmt.Sprintf("s3_key=%s range=%d-%d: %s", b.S3Key, from, to, err.Error()),
+			})
+			return fmt.Errorf("BlocksRangeToStream GetObjectRange key=%s: %w", b.S3Key, err)
+		}
+		_, copyErr := io.Copy(w, body)
+		body.Close()
+		if copyErr != nil {
+			logger.ErrorLog(ctx, "Block stream copy failed", logger.ErrorDetails{
+				Code: "STREAM_COPY_ERR", Details: fmt.Sprintf("s3_key=%s: %s", b.S3Key, copyErr.Error()),
+			})
+			return fmt.Errorf("BlocksRangeToStream io.Copy key=%s: %w", b.S3Key, copyErr)
+		}
+	}
+	return nil
+}
+
+// openBlock returns the content of block b.
+func openBlock(ctx context.Context, s3 *storage.S3Client, b *model.Block) (io.ReadCloser, error) {
+	body, err := s3.GetObject(ctx, b.S3Key)
+	if err != nil {
+		return nil, err
+	}
+	return decompress(body, b.Compression)
+}
+
+// openBlockRange returns bytes from to to (inclusive) of the content of block b.
+func openBlockRange(ctx context.Context, s3 *storage.S3Client, b *model.Block, from, to int64) (io.ReadCloser, error) {
+	if b.Compression == "" {
+		return s3.GetObjectRange(ctx, b.S3Key, from, to)
+	}
+	body, err := openBlock(ctx, s3, b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, body, from); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(body, to-from+1), body}, nil
+}
@@ -0,0 +1,86 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// ErrWriteOutOfRange means a write would start past the end of the file, leaving a hole.
+var ErrWriteOutOfRange = errors.New("write starts past the end of the file")
+
+// Rewrite describes new content for a file after a write: blocks [From, To) of its
+// old block list are replaced by BlockIDs, which hold one reference each.
+type Rewrite struct {
+	From, To  int
+	BlockIDs  []int64
+	TotalSize int64
+	Stats     Stats
+}
+
+// Rewrite stores the content of the file made of blocks after data is written at
+// offset. Only the blocks the write touches are replaced: their old content is read
+// back from S3, patched and split again by chunker, and the blocks are deduplicated
+// like any upload. Stored blocks are never modified, so blocks shared with other files
+// are unaffected. A write may extend the file but not start past its end. Only the
+// touched blocks are held in memory, so callers bound len(data). mimeType is the
+// file's content type, which decides whether new blocks are compressed.
+func (p *Processor) Rewrite(ctx context.Context, userID int64, blocks []*model.Block, offset int64, data []byte, chunker Chunker, mimeType string) (*Rewrite, error) {
+	starts := make([]int64, len(blocks)+1) // starts[i] is the offset of block i; the last entry is the size
+	for i, b := range blocks {
+		starts[i+1] = starts[i] + b.SizeBytes
+	}
+	size := starts[len(blocks)]
+	if offset > size {
+		return nil, ErrWriteOutOfRange
+	}
+	end := offset + int64(len(data))
+
+	from := 0
+	for from < len(blocks) && starts[from+1] <= offset {
+		from++
+	}
+	// Appending fills up a short last block rather than adding another short one. With
+	// content-defined chunking the last block was cut by the end of the file, not by
+	// its content, so it is cut again.
+	if from == len(blocks) && from > 0 && blocks[from-1].SizeBytes < int64(chunker.MaxBlockSize()) {
+		from--
+	}
+	to := from
+	for to < len(blocks) && starts[to] < end {
+		to++
+	}
+
+	regionStart := starts[from]
+	oldEnd := starts[to]
+	region := make([]byte, max(oldEnd, end)-regionStart)
+	if oldEnd > regionStart {
+		if err := BlocksRangeToStream(ctx, blocks, p.s3, &fixedWriter{buf: region}, regionStart, oldEnd-regionStart); err != nil {
+			return nil, fmt.Errorf("Processor.Rewrite read: %w", err)
+		}
+	}
+	copy(region[offset-regionStart:], data)
+
+	ids, stats, err := p.Process(ctx, userID, bytes.NewReader(region), chunker, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	return &Rewrite{From: from, To: to, BlockIDs: ids, TotalSize: max(size, end), Stats: stats}, nil
+}
+
+// fixedWriter writes into a preallocated buffer, failing rather than growing it.
+type fixedWriter struct {
+	buf []byte
+	n   int
+}
+
+func (w *fixedWriter) Write(p []byte) (int, error) {
+	if len(p) > len(w.buf)-w.n {
+		return 0, errors.New("block content longer than recorded")
+	}
+	w.n += copy(w.buf[w.n:], p)
+	return len(p), nil
+}
@@ -0,0 +1,67 @@
+package block
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// Checksums is what re-reading a file's blocks from S3 found: the SHA-256 of the
+// whole content and of each block, and the blocks that no longer match their record.
+type Checksums struct {
+	SHA256      string
+	BlockSHA256 []string
+	Mismatched  []int // indexes of blocks whose content differs from their recorded hash or size
+}
+
+// Checksum streams blocks from S3 in order and hashes the content, checking each
+// block against its recorded hash and size. userID owns the file; blocks stored
+// under per-user dedup are recorded under that user's namespaced hash. progress,
+// if not nil, is called with the bytes read so far after each block.
+func Checksum(ctx context.Context, blocks []*model.Block, s3 *storage.S3Client, userID int64, progress func(done int64)) (*Checksums, error) {
+	whole := sha256.New()
+	res := &Checksums{BlockSHA256: make([]string, len(blocks))}
+	var done int64
+	for i, b := range blocks {
+		h := sha256.New()
+		n, err := readBlock(ctx, s3, b, io.MultiWriter(whole, h))
+		if err != nil {
+			return nil, err
+		}
+		sum := hex.EncodeToString(h.Sum(nil))
+		res.BlockSHA256[i] = sum
+		if n != b.SizeBytes || !recordedAs(b.SHA256Hash, userID, sum) {
+			res.Mismatched = append(res.Mismatched, i)
+		}
+		done += n
+		if progress != nil {
+			progress(done)
+		}
+	}
+	res.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return res, nil
+}
+
+// recordedAs reports whether a block recorded under hash holds content hashing to
+// contentHash, under either dedup scope: the scope may have changed since it was stored.
+func recordedAs(hash string, userID int64, contentHash string) bool {
+	return hash == DedupGlobal.key(userID, contentHash) || hash == DedupUser.key(userID, contentHash)
+}
+
+func readBlock(ctx context.Context, s3 *storage.S3Client, b *model.Block, w io.Writer) (int64, error) {
+	body, err := openBlock(ctx, s3, b)
+	if err != nil {
+		return 0, fmt.Errorf("block.Checksum GetObject key=%s: %w", b.S3Key, err)
+	}
+	defer body.Close()
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return n, fmt.Errorf("block.Checksum read key=%s: %w", b.S3Key, err)
+	}
+	return n, nil
+}
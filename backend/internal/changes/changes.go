@@ -0,0 +1,174 @@
+// Package changes delivers file and folder changes made by any API replica to every
+// replica. Postgres triggers NOTIFY each row change on Channel; a Hub keeps one
+// connection LISTENing and fans the changes out to in-process subscribers such as
+// cache invalidation and the client event stream. No broker is needed, which suits
+// small deployments; notifications sent while a replica is reconnecting are lost, so
+// subscribers are told to resynchronize (OpResync) after every reconnect.
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// Channel is the Postgres notification channel the change triggers publish on.
+const Channel = "naratel_changes"
+
+// OpResync is sent to subscribers after the listener reconnects: changes made while
+// it was down were missed, so cached state should be reloaded.
+const OpResync = "resync"
+
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+var (
+	changesReceived = expvar.NewInt("changes_received")    // notifications received
+	listenerUp      = expvar.NewInt("changes_listener_up") // 1 while LISTENing
+)
+
+// Change is one row change on a watched table.
+type Change struct {
+	Table  string `json:"table"` // "files" or "folders"; empty for OpResync
+	Op     string `json:"op"`    // "insert", "update", "delete" or OpResync
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"` // owner of the row
+}
+
+// Hub listens for changes and passes each one to every subscriber.
+type Hub struct {
+	pool *pgxpool.Pool
+
+	mu   sync.RWMutex
+	subs map[int]func(Change)
+	next int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewHub creates an idle Hub; call Start to begin listening.
+func NewHub(pool *pgxpool.Pool) *Hub {
+	return &Hub{pool: pool, subs: make(map[int]func(Change))}
+}
+
+// Subscribe registers fn to be called with every change, from the listener goroutine,
+// until the returned function is called. fn must not block.
+func (h *Hub) Subscribe(fn func(Change)) func() {
+	h.mu.Lock()
+	id := h.next
+	h.next++
+	h.subs[id] = fn
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		delete(h.subs, id)
+		h.mu.Unlock()
+	}
+}
+
+// Start launches the listener. It holds one connection taken out of the pool for as
+// long as it runs and reconnects with backoff when the connection fails.
+func (h *Hub) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.done = make(chan struct{})
+	go h.loop(ctx)
+}
+
+// Stop halts the listener, waiting at most until ctx expires.
+func (h *Hub) Stop(ctx context.Context) {
+	if h.cancel == nil {
+		return
+	}
+	h.cancel()
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+	}
+}
+
+func (h *Hub) loop(ctx context.Context) {
+	defer close(h.done)
+
+	backoff := minBackoff
+	for retry := false; ; retry = true {
+		start := time.Now()
+		err := h.listen(ctx, retry)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(start) > maxBackoff {
+			backoff = minBackoff // it was up for a while; this is a fresh failure
+		}
+		logger.Warn(ctx, "Change listener disconnected", map[string]interface{}{
+			"error": err.Error(), "retry_in": backoff.String(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// listen LISTENs on a dedicated connection and dispatches notifications until the
+// connection fails or ctx is cancelled. On a retry, subscribers are sent a resync
+// once listening resumes.
+func (h *Hub) listen(ctx context.Context, retry bool) error {
+	pooled, err := h.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Take the connection out of the pool: it stays in LISTEN mode for good.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{Channel}.Sanitize()); err != nil {
+		return err
+	}
+	listenerUp.Set(1)
+	defer listenerUp.Set(0)
+	logger.Info(ctx, "Change listener started", map[string]interface{}{"channel": Channel})
+
+	if retry {
+		h.dispatch(Change{Op: OpResync})
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		changesReceived.Add(1)
+
+		var c Change
+		if err := json.Unmarshal([]byte(n.Payload), &c); err != nil {
+			logger.Warn(ctx, "Malformed change notification", map[string]interface{}{
+				"payload": n.Payload, "error": err.Error(),
+			})
+			continue
+		}
+		h.dispatch(c)
+	}
+}
+
+func (h *Hub) dispatch(c Change) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.subs {
+		fn(c)
+	}
+}
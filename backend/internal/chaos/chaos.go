@@ -0,0 +1,93 @@
+// Package chaos injects faults into the storage and database layers so test
+// environments can verify retries, cleanup on failure and that aborted uploads don't
+// leak blocks. It is off unless CHAOS_ENABLED is set and refuses to run in production.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// ErrInjected is the error returned by injected S3 faults.
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Injected faults, published on the admin /debug/vars endpoint.
+var (
+	s3Errors       = expvar.NewInt("chaos_s3_errors")
+	latencySpikes  = expvar.NewInt("chaos_latency_spikes")
+	droppedDBConns = expvar.NewInt("chaos_db_dropped_conns")
+)
+
+// Injector decides which operations fail. Rates are percentages (0-100) of
+// operations. A nil *Injector injects nothing, so callers need not check.
+type Injector struct {
+	S3ErrorPct int           // S3 calls that fail with ErrInjected
+	LatencyPct int           // S3 calls and pool acquires delayed by Latency
+	Latency    time.Duration // length of a latency spike
+	DBDropPct  int           // pool acquires that hand out a connection whose socket was closed
+}
+
+func roll(pct int) bool {
+	return pct > 0 && rand.IntN(100) < pct
+}
+
+// delay sleeps for a latency spike, cut short if ctx ends.
+func (in *Injector) delay(ctx context.Context, op string) {
+	if !roll(in.LatencyPct) {
+		return
+	}
+	latencySpikes.Add(1)
+	logger.Warn(ctx, "Chaos: injecting latency", map[string]interface{}{"op": op, "latency_ms": in.Latency.Milliseconds()})
+	t := time.NewTimer(in.Latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// S3 is called at the start of every S3 operation and returns the fault to fail it
+// with, if any, after an occasional latency spike.
+func (in *Injector) S3(ctx context.Context, op string) error {
+	if in == nil {
+		return nil
+	}
+	in.delay(ctx, "s3."+op)
+	if !roll(in.S3ErrorPct) {
+		return nil
+	}
+	s3Errors.Add(1)
+	logger.Warn(ctx, "Chaos: injecting S3 error", map[string]interface{}{"op": op})
+	return fmt.Errorf("%s: %w", op, ErrInjected)
+}
+
+// WrapPool makes cfg's pool delay acquires and drop connections. A dropped
+// connection has its socket closed before it is handed out, so the caller's next
+// query fails the way it would after a network cut, and the pool discards it.
+func (in *Injector) WrapPool(cfg *pgxpool.Config) {
+	if in == nil {
+		return
+	}
+	next := cfg.BeforeAcquire
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if next != nil && !next(ctx, conn) {
+			return false
+		}
+		in.delay(ctx, "db.acquire")
+		if roll(in.DBDropPct) {
+			droppedDBConns.Add(1)
+			logger.Warn(ctx, "Chaos: dropping database connection", nil)
+			_ = conn.PgConn().Conn().Close()
+		}
+		return true
+	}
+}
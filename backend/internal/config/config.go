@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,8 +14,34 @@ type Config struct {
 	AppPort    string
 	AppEnv     string
 
-	JWTSecret      string
-	JWTExpiryHours int
+	// LogLevel is the lowest level logged: "info", "warn" or "error".
+	LogLevel string
+
+	// AccessTokenTTLMinutes is how long a JWT stays valid (JWT_EXPIRY_HOURS, the older
+	// setting, is its default). Bearer logins also get a refresh token, valid for
+	// RefreshTokenTTLHours from its last use, to trade for new JWTs at POST /auth/refresh;
+	// 0 issues none.
+	JWTSecret             string
+	AccessTokenTTLMinutes int
+	RefreshTokenTTLHours  int
+
+	// Attributes of the cookies set for browser sessions and CSRF protection.
+	// CookieSameSite is "lax", "strict" or "none" (which requires CookieSecure).
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite string
+
+	// SessionMode is "bearer" (JWT only), "cookie" (server-side sessions in an HttpOnly
+	// cookie only) or "both". Cookie sessions last SessionTTLHours from login.
+	SessionMode     string
+	SessionTTLHours int
+
+	// InviteTTLHours is how long invite links for imported users stay valid.
+	InviteTTLHours int
+
+	// CORSAllowedOrigins lists the origins allowed to call the API from a browser.
+	// Credentials (cookies) are only allowed cross-origin when it is not "*".
+	CORSAllowedOrigins []string
 
 	DBHost     string
 	DBPort     string
@@ -22,6 +50,16 @@ type Config struct {
 	DBPassword string
 	DBSSLMode  string
 
+	// Every database statement gets a DBQueryTimeoutMS deadline (0 = none); those
+	// slower than DBSlowQueryMS are logged as warnings (0 = never).
+	DBQueryTimeoutMS int
+	DBSlowQueryMS    int
+
+	// DBApplicationName names the server's connections in pg_stat_activity; with
+	// DBTagRequests the ID of the request holding a connection is appended to it.
+	DBApplicationName string
+	DBTagRequests     bool
+
 	S3Endpoint       string
 	S3Bucket         string
 	S3AccessKey      string
@@ -30,6 +68,214 @@ type Config struct {
 	S3ForcePathStyle bool
 
 	BlockSizeMB int
+
+	// Adaptive block sizing: files under BlockSmallFileMB use BlockSizeSmallMB,
+	// files of at least BlockLargeFileMB use BlockSizeLargeMB (0 disables a tier).
+	BlockSizeSmallMB int
+	BlockSmallFileMB int
+	BlockSizeLargeMB int
+	BlockLargeFileMB int
+
+	// BlockChunking is "fixed" (blocks of the sizes above) or "fastcdc" (content-defined
+	// blocks of BlockCDCMinKB to BlockCDCMaxKB, BlockCDCAvgKB on average).
+	BlockChunking string
+	BlockCDCMinKB int
+	BlockCDCAvgKB int
+	BlockCDCMaxKB int
+
+	// DedupScope is "global" (blocks shared across all users) or "user" (per-user only).
+	DedupScope string
+
+	// BlockKeySecret, when set, derives block S3 keys as HMAC-SHA256(secret, hash) so
+	// bucket listings don't reveal content hashes. Empty keeps raw-hash keys.
+	BlockKeySecret string
+
+	// BlockCompression is "none" or "zstd": new blocks are compressed before upload
+	// unless their content type is compressed already or compressing saves too little.
+	BlockCompression string
+
+	// BlockWorkers is how many blocks one upload stores concurrently.
+	BlockWorkers int
+
+	UploadMaxConcurrent       int
+	UploadQueueSize           int
+	UploadQueueTimeoutSeconds int
+
+	// Limits on every uploaded file (including file drops): maximum size in MB
+	// (0 = unlimited) and allow/deny lists of extensions or MIME types ("exe",
+	// "image/*"). An empty allow list allows every type that is not denied.
+	UploadMaxFileMB    int
+	UploadAllowedTypes []string
+	UploadDeniedTypes  []string
+
+	// Resumable uploads (POST /uploads) not finished within this many hours are discarded.
+	UploadSessionTTLHours int
+
+	ShareLinkCleanupIntervalMinutes int
+	ShareLinkRetentionHours         int
+
+	BlockGCIntervalMinutes int
+
+	// Destructive maintenance tasks (block GC, trash, upload session, S3 deletion and
+	// share link purges) only log what they would remove.
+	MaintenanceDryRun bool
+
+	// How often users' used_bytes is recomputed from their files and drift corrected.
+	UsageReconcileIntervalHours int
+
+	// Daily usage snapshots (GET /auth/me/usage/history): how often today's is
+	// refreshed and how long they are kept; 0 keeps them forever.
+	UsageSnapshotIntervalHours int
+	UsageSnapshotRetentionDays int
+
+	// What happens once a user has been served more than their plan's monthly egress:
+	// "track" only counts it, "throttle" slows their downloads and shares to
+	// EgressThrottleKBps, "block" refuses them until the month ends.
+	EgressOverLimit    string
+	EgressThrottleKBps int
+
+	// UsageAccounting is how deduplicated blocks are charged against plan quotas:
+	// "logical" charges every file its full size, "first_uploader" charges each stored
+	// block once, to the user who has held it longest.
+	UsageAccounting string
+
+	S3DeletionIntervalSeconds int
+	S3DeletionMaxAttempts     int
+
+	TrashRetentionDays        int
+	TrashPurgeIntervalMinutes int
+
+	// Scheduled file/folder expiry: how often it is enforced and how far ahead owners are warned.
+	ExpiryIntervalMinutes int
+	ExpiryNoticeHours     int
+
+	// Folder tree limits; 0 disables the limit.
+	FolderMaxDepth    int
+	FolderMaxChildren int
+
+	// SnippetMaxKB caps the text accepted by POST /snippets.
+	SnippetMaxKB int
+
+	// FileDropMaxMB caps each file uploaded through a file-drop link or a folder share
+	// link that accepts uploads; links may set less.
+	FileDropMaxMB int
+
+	// AbuseReportsPerHour limits POST /share/{token}/report per client IP; 0 = unlimited.
+	AbuseReportsPerHour int
+
+	// Public share token routes: requests per client IP per minute, and how many
+	// not-found answers a client may get per hour before it is blocked; 0 = unlimited.
+	ShareRequestsPerMinute  int
+	ShareTokenMissesPerHour int
+
+	// Failed logins allowed per client IP and per account in each window before
+	// POST /auth/login answers 429 for the rest of it; 0 = unlimited.
+	LoginMaxFailures          int
+	LoginFailureWindowMinutes int
+
+	// Security log: how long events are kept and how many may wait to be written.
+	SecurityLogRetentionDays int
+	SecurityLogQueueSize     int
+
+	// ShareScanGate picks which malware scan verdicts block public downloads:
+	// "off", "infected" or "strict" (infected and pending).
+	ShareScanGate string
+
+	DownloadBundleDir        string
+	DownloadBundleTTLMinutes int
+	DownloadBundleMaxFiles   int
+	DownloadBundleMaxMB      int
+
+	// Local disk cache of small shared files, so popular links are not re-read
+	// from S3 on every visit. ShareCacheMaxMB = 0 disables it.
+	ShareCacheDir       string
+	ShareCacheMaxMB     int
+	ShareCacheFileMaxMB int
+
+	// PDF page rendering (GET /files/{id}/pages/{n}) runs PDFRenderer, poppler's
+	// pdftoppm; empty disables it. PDFs over PDFRenderMaxMB are refused, at most
+	// PDFRenderConcurrency renders run at once and PDFPageCacheMB of pages are cached.
+	PDFRenderer          string
+	PDFRenderMaxMB       int
+	PDFRenderConcurrency int
+	PDFPageCacheMB       int
+
+	// Audio analysis (GET /files/{id}/waveform) runs AudioFFmpeg and AudioFFprobe;
+	// empty disables it. Files up to AudioAnalyzeMaxMB are analysed by a background
+	// task every AudioAnalyzeIntervalSeconds (0 disables it).
+	AudioFFmpeg                 string
+	AudioFFprobe                string
+	AudioAnalyzeMaxMB           int
+	AudioAnalyzeIntervalSeconds int
+
+	// PublicBaseURL is the externally visible origin used to build share URLs
+	// (e.g. https://box.example.com). Empty = derive from the incoming request.
+	PublicBaseURL string
+	BrandName     string
+
+	// PreviewInlineTypes lists MIME types that ?preview=true may render inline;
+	// "image/*" allows a whole family. Anything else is served as an attachment
+	// (HTML as plain text).
+	PreviewInlineTypes []string
+	// PreviewTextMaxKB cuts inline previews of text (plain text, CSV, JSON, XML, HTML
+	// shown as text) to their first PreviewTextMaxKB KiB, so a huge log does not hang
+	// the browser tab. 0 serves them whole.
+	PreviewTextMaxKB int
+
+	// TrustedProxies is a comma-separated list of IPs/CIDRs whose X-Forwarded-* headers are honoured.
+	TrustedProxies string
+	// IPv6PrefixLength groups IPv6 clients into one network for rate limits and login
+	// throttling; IPv4 clients are always keyed by address.
+	IPv6PrefixLength int
+
+	// TOSVersion is the terms of service version users must have accepted to use files,
+	// folders and sharing; changing it makes everyone re-accept. Empty disables the gate.
+	TOSVersion string
+	TOSURL     string
+
+	// Outbound mail. An empty SMTPHost or MailLogOnly logs messages instead of sending
+	// them. SMTPTLS is "starttls", "tls" (implicit, usually port 465) or "none".
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPTLS         string
+	MailFrom        string
+	MailLogOnly     bool
+	MailQueueSize   int
+	MailMaxAttempts int
+
+	// Push notifications. Web Push is enabled by a base64url P-256 VAPIDPrivateKey, FCM
+	// by a service account key file. PushIntervalSeconds is how often new notifications
+	// are dispatched.
+	VAPIDPrivateKey     string
+	VAPIDSubject        string
+	FCMCredentialsFile  string
+	PushIntervalSeconds int
+
+	// MountLocalRoots lists the server directories admins may mount read-only into user
+	// trees (or mount subdirectories of). Empty disables local mounts; S3 prefix mounts
+	// are always available.
+	MountLocalRoots []string
+
+	// FeaturesDisabled switches feature flags (model.Features) off for every user,
+	// whatever their plan says.
+	FeaturesDisabled []string
+
+	// AdminEmails lists users promoted to the admin role at startup (comma-separated).
+	AdminEmails []string
+
+	// AdminAddr is the listen address for pprof and /debug/vars. Empty disables it.
+	AdminAddr string
+
+	// Chaos mode injects faults for resilience testing and is refused when APP_ENV is
+	// production. The *Pct settings are percentages of operations: S3 calls that fail,
+	// S3 calls and DB pool acquires delayed by ChaosLatencyMS, and DB connections dropped.
+	ChaosEnabled    bool
+	ChaosS3ErrorPct int
+	ChaosLatencyPct int
+	ChaosLatencyMS  int
+	ChaosDBDropPct  int
 }
 
 // DSN returns the PostgreSQL connection string.
@@ -40,13 +286,14 @@ func (c *Config) DSN() string {
 	)
 }
 
-// BlockSizeBytes returns block size in bytes.
+// BlockSizeBytes returns the default block size in bytes.
 func (c *Config) BlockSizeBytes() int {
 	return c.BlockSizeMB * 1024 * 1024
 }
 
 // Load reads .env (if present) then environment variables.
 func Load() (*Config, error) {
+	snapshotProcessEnv()
 	// Best-effort: load .env file, ignore error if not found
 	_ = godotenv.Load()
 
@@ -54,8 +301,22 @@ func Load() (*Config, error) {
 		AppPort:    getEnv("APP_PORT", "8080"),
 		AppEnv:     getEnv("APP_ENV", "development"),
 
-		JWTSecret:      mustGetEnv("JWT_SECRET"),
-		JWTExpiryHours: getEnvInt("JWT_EXPIRY_HOURS", 24),
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		JWTSecret:             mustGetEnv("JWT_SECRET"),
+		AccessTokenTTLMinutes: getEnvInt("ACCESS_TOKEN_TTL_MINUTES", 60*getEnvInt("JWT_EXPIRY_HOURS", 24)),
+		RefreshTokenTTLHours:  getEnvInt("REFRESH_TOKEN_TTL_HOURS", 720),
+
+		CookieDomain:   getEnv("COOKIE_DOMAIN", ""),
+		CookieSecure:   getEnvBool("COOKIE_SECURE", true),
+		CookieSameSite: getEnv("COOKIE_SAMESITE", "lax"),
+
+		SessionMode:     getEnv("SESSION_MODE", "bearer"),
+		SessionTTLHours: getEnvInt("SESSION_TTL_HOURS", 168),
+
+		InviteTTLHours: getEnvInt("INVITE_TTL_HOURS", 168),
+
+		CORSAllowedOrigins: getEnvList("CORS_ALLOWED_ORIGINS", "*"),
 
 		DBHost:     getEnv("DB_HOST", "localhost"),
 		DBPort:     getEnv("DB_PORT", "5432"),
@@ -64,6 +325,12 @@ func Load() (*Config, error) {
 		DBPassword: getEnv("DB_PASSWORD", "postgres"),
 		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
 
+		DBQueryTimeoutMS: getEnvInt("DB_QUERY_TIMEOUT_MS", 30000),
+		DBSlowQueryMS:    getEnvInt("DB_SLOW_QUERY_MS", 500),
+
+		DBApplicationName: getEnv("DB_APPLICATION_NAME", "naratel-box"),
+		DBTagRequests:     getEnvBool("DB_TAG_REQUESTS", true),
+
 		S3Endpoint:       mustGetEnv("S3_ENDPOINT"),
 		S3Bucket:         mustGetEnv("S3_BUCKET"),
 		S3AccessKey:      mustGetEnv("S3_ACCESS_KEY"),
@@ -72,6 +339,140 @@ func Load() (*Config, error) {
 		S3ForcePathStyle: getEnvBool("S3_FORCE_PATH_STYLE", true),
 
 		BlockSizeMB: getEnvInt("BLOCK_SIZE_MB", 8),
+
+		BlockSizeSmallMB: getEnvInt("BLOCK_SIZE_SMALL_MB", 1),
+		BlockSmallFileMB: getEnvInt("BLOCK_SMALL_FILE_MB", 16),
+		BlockSizeLargeMB: getEnvInt("BLOCK_SIZE_LARGE_MB", 32),
+		BlockLargeFileMB: getEnvInt("BLOCK_LARGE_FILE_MB", 2048),
+
+		BlockChunking: getEnv("BLOCK_CHUNKING", "fixed"),
+		BlockCDCMinKB: getEnvInt("BLOCK_CDC_MIN_KB", 2048),
+		BlockCDCAvgKB: getEnvInt("BLOCK_CDC_AVG_KB", 8192),
+		BlockCDCMaxKB: getEnvInt("BLOCK_CDC_MAX_KB", 32768),
+
+		DedupScope: getEnv("DEDUP_SCOPE", "global"),
+
+		BlockKeySecret: getEnv("BLOCK_KEY_SECRET", ""),
+
+		BlockCompression: getEnv("BLOCK_COMPRESSION", "none"),
+
+		BlockWorkers: getEnvInt("BLOCK_WORKERS", 8),
+
+		UploadMaxConcurrent:       getEnvInt("UPLOAD_MAX_CONCURRENT", 4),
+		UploadQueueSize:           getEnvInt("UPLOAD_QUEUE_SIZE", 16),
+		UploadQueueTimeoutSeconds: getEnvInt("UPLOAD_QUEUE_TIMEOUT_SECONDS", 30),
+
+		UploadMaxFileMB:    getEnvInt("UPLOAD_MAX_FILE_MB", 0),
+		UploadAllowedTypes: getEnvList("UPLOAD_ALLOWED_TYPES", ""),
+		UploadDeniedTypes:  getEnvList("UPLOAD_DENIED_TYPES", ""),
+
+		UploadSessionTTLHours: getEnvInt("UPLOAD_SESSION_TTL_HOURS", 24),
+
+		ShareLinkCleanupIntervalMinutes: getEnvInt("SHARE_LINK_CLEANUP_INTERVAL_MINUTES", 60),
+		ShareLinkRetentionHours:         getEnvInt("SHARE_LINK_RETENTION_HOURS", 24),
+
+		BlockGCIntervalMinutes: getEnvInt("BLOCK_GC_INTERVAL_MINUTES", 10),
+
+		MaintenanceDryRun: getEnvBool("MAINTENANCE_DRY_RUN", false),
+
+		UsageReconcileIntervalHours: getEnvInt("USAGE_RECONCILE_INTERVAL_HOURS", 24),
+
+		UsageSnapshotIntervalHours: getEnvInt("USAGE_SNAPSHOT_INTERVAL_HOURS", 6),
+		UsageSnapshotRetentionDays: getEnvInt("USAGE_SNAPSHOT_RETENTION_DAYS", 730),
+
+		EgressOverLimit:    getEnv("EGRESS_OVER_LIMIT", "throttle"),
+		EgressThrottleKBps: getEnvInt("EGRESS_THROTTLE_KBPS", 256),
+
+		UsageAccounting: getEnv("USAGE_ACCOUNTING", "logical"),
+
+		S3DeletionIntervalSeconds: getEnvInt("S3_DELETION_INTERVAL_SECONDS", 60),
+		S3DeletionMaxAttempts:     getEnvInt("S3_DELETION_MAX_ATTEMPTS", 10),
+
+		TrashRetentionDays:        getEnvInt("TRASH_RETENTION_DAYS", 30),
+		TrashPurgeIntervalMinutes: getEnvInt("TRASH_PURGE_INTERVAL_MINUTES", 60),
+
+		ExpiryIntervalMinutes: getEnvInt("EXPIRY_INTERVAL_MINUTES", 15),
+		ExpiryNoticeHours:     getEnvInt("EXPIRY_NOTICE_HOURS", 24),
+
+		FolderMaxDepth:    getEnvInt("FOLDER_MAX_DEPTH", 32),
+		FolderMaxChildren: getEnvInt("FOLDER_MAX_CHILDREN", 1000),
+
+		SnippetMaxKB: getEnvInt("SNIPPET_MAX_KB", 1024),
+
+		FileDropMaxMB: getEnvInt("FILE_DROP_MAX_MB", 1024),
+
+		AbuseReportsPerHour: getEnvInt("ABUSE_REPORTS_PER_HOUR", 5),
+
+		ShareRequestsPerMinute:  getEnvInt("SHARE_REQUESTS_PER_MINUTE", 120),
+		ShareTokenMissesPerHour: getEnvInt("SHARE_TOKEN_MISSES_PER_HOUR", 30),
+
+		LoginMaxFailures:          getEnvInt("LOGIN_MAX_FAILURES", 10),
+		LoginFailureWindowMinutes: getEnvInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+
+		SecurityLogRetentionDays: getEnvInt("SECURITY_LOG_RETENTION_DAYS", 90),
+		SecurityLogQueueSize:     getEnvInt("SECURITY_LOG_QUEUE_SIZE", 1024),
+
+		ShareScanGate: getEnv("SHARE_SCAN_GATE", "infected"),
+
+		DownloadBundleDir:        getEnv("DOWNLOAD_BUNDLE_DIR", filepath.Join(os.TempDir(), "naratel-bundles")),
+		DownloadBundleTTLMinutes: getEnvInt("DOWNLOAD_BUNDLE_TTL_MINUTES", 60),
+		DownloadBundleMaxFiles:   getEnvInt("DOWNLOAD_BUNDLE_MAX_FILES", 500),
+		DownloadBundleMaxMB:      getEnvInt("DOWNLOAD_BUNDLE_MAX_MB", 4096),
+
+		ShareCacheDir:       getEnv("SHARE_CACHE_DIR", filepath.Join(os.TempDir(), "naratel-share-cache")),
+		ShareCacheMaxMB:     getEnvInt("SHARE_CACHE_MAX_MB", 1024),
+		ShareCacheFileMaxMB: getEnvInt("SHARE_CACHE_FILE_MAX_MB", 8),
+
+		PDFRenderer:          getEnv("PDF_RENDERER", "pdftoppm"),
+		PDFRenderMaxMB:       getEnvInt("PDF_RENDER_MAX_MB", 100),
+		PDFRenderConcurrency: getEnvInt("PDF_RENDER_CONCURRENCY", 2),
+		PDFPageCacheMB:       getEnvInt("PDF_PAGE_CACHE_MB", 64),
+
+		AudioFFmpeg:                 getEnv("AUDIO_FFMPEG", "ffmpeg"),
+		AudioFFprobe:                getEnv("AUDIO_FFPROBE", "ffprobe"),
+		AudioAnalyzeMaxMB:           getEnvInt("AUDIO_ANALYZE_MAX_MB", 500),
+		AudioAnalyzeIntervalSeconds: getEnvInt("AUDIO_ANALYZE_INTERVAL_SECONDS", 60),
+
+		PublicBaseURL: strings.TrimRight(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		BrandName:     getEnv("BRAND_NAME", "Naratel Box"),
+
+		PreviewInlineTypes: getEnvList("PREVIEW_INLINE_TYPES",
+			"image/png,image/jpeg,image/gif,image/webp,image/svg+xml,application/pdf,text/plain,audio/mpeg,audio/ogg,video/mp4,video/webm"),
+		PreviewTextMaxKB: getEnvInt("PREVIEW_TEXT_MAX_KB", 2048),
+
+		TrustedProxies:   getEnv("TRUSTED_PROXIES", ""),
+		IPv6PrefixLength: getEnvInt("IPV6_PREFIX_LENGTH", 64),
+
+		TOSVersion: getEnv("TOS_VERSION", ""),
+		TOSURL:     getEnv("TOS_URL", ""),
+
+		SMTPHost:        getEnv("SMTP_HOST", ""),
+		SMTPPort:        getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:    getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:    getEnv("SMTP_PASSWORD", ""),
+		SMTPTLS:         getEnv("SMTP_TLS", "starttls"),
+		MailFrom:        getEnv("MAIL_FROM", "Naratel Box <no-reply@localhost>"),
+		MailLogOnly:     getEnvBool("MAIL_LOG_ONLY", false),
+		MailQueueSize:   getEnvInt("MAIL_QUEUE_SIZE", 256),
+		MailMaxAttempts: getEnvInt("MAIL_MAX_ATTEMPTS", 5),
+
+		VAPIDPrivateKey:     getEnv("VAPID_PRIVATE_KEY", ""),
+		VAPIDSubject:        getEnv("VAPID_SUBJECT", "mailto:admin@localhost"),
+		FCMCredentialsFile:  getEnv("FCM_CREDENTIALS_FILE", ""),
+		PushIntervalSeconds: getEnvInt("PUSH_INTERVAL_SECONDS", 10),
+
+		MountLocalRoots: getEnvList("MOUNT_LOCAL_ROOTS", ""),
+
+		FeaturesDisabled: getEnvList("FEATURES_DISABLED", ""),
+
+		AdminEmails: getEnvList("ADMIN_EMAILS", ""),
+		AdminAddr:   getEnv("ADMIN_ADDR", ""),
+
+		ChaosEnabled:    getEnvBool("CHAOS_ENABLED", false),
+		ChaosS3ErrorPct: getEnvInt("CHAOS_S3_ERROR_PCT", 5),
+		ChaosLatencyPct: getEnvInt("CHAOS_LATENCY_PCT", 5),
+		ChaosLatencyMS:  getEnvInt("CHAOS_LATENCY_MS", 2000),
+		ChaosDBDropPct:  getEnvInt("CHAOS_DB_DROP_PCT", 2),
 	}
 
 	return cfg, nil
@@ -115,3 +516,13 @@ func getEnvBool(key string, fallback bool) bool {
 	}
 	return b
 }
+
+func getEnvList(key, fallback string) []string {
+	var out []string
+	for _, v := range strings.Split(getEnv(key, fallback), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
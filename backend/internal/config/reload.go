@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// processEnv holds the variables of the process environment before .env was first
+// loaded. They take precedence over .env, on reload too.
+var (
+	processEnv     map[string]bool
+	processEnvOnce sync.Once
+)
+
+func snapshotProcessEnv() {
+	processEnvOnce.Do(func() {
+		processEnv = make(map[string]bool)
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			processEnv[name] = true
+		}
+	})
+}
+
+// SettingChange is a setting a reload changed, named as the Config field.
+type SettingChange struct {
+	Setting string      `json:"setting"`
+	Old     interface{} `json:"old"`
+	New     interface{} `json:"new"`
+	Error   string      `json:"error,omitempty"` // why the new value was refused; the old one stays in force
+}
+
+// ReloadResult reports what a Reload changed.
+type ReloadResult struct {
+	ReloadedAt      time.Time        `json:"reloaded_at"`
+	Applied         []*SettingChange `json:"applied"`          // tunable settings now in force, or refused
+	RestartRequired []string         `json:"restart_required"` // changed settings that only take effect on restart
+}
+
+type tunable struct {
+	fields []string
+	apply  func(*Config) error
+}
+
+// Reloader re-reads the configuration of a running server and hands tunable settings
+// (log level, rate limits, upload limits...) to the components using them, so they
+// change without a restart. Components register with On; every other setting still
+// needs a restart.
+type Reloader struct {
+	mu       sync.Mutex
+	started  *Config // the configuration the server started with
+	current  *Config // the tunables in force
+	tunables []tunable
+}
+
+// NewReloader creates a Reloader for a server started with cfg.
+func NewReloader(cfg *Config) *Reloader {
+	current := *cfg
+	return &Reloader{started: cfg, current: &current}
+}
+
+// On registers apply to be called with the new configuration when a reload changes
+// any of fields (Config field names). An error refuses the new values, which are then
+// reported and not applied. Register everything before the first Reload.
+func (r *Reloader) On(apply func(*Config) error, fields ...string) {
+	for _, f := range fields {
+		if _, ok := reflect.TypeOf(Config{}).FieldByName(f); !ok {
+			panic(fmt.Sprintf("config.Reloader.On: no setting %q", f))
+		}
+	}
+	r.tunables = append(r.tunables, tunable{fields: fields, apply: apply})
+}
+
+// Reload re-reads .env and the environment and applies the tunable settings that
+// changed, logging what it did. Values from .env replace those it set before, but never variables of the
+// process environment; a variable removed from .env keeps its value until restart.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values, err := godotenv.Read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("config.Reload: %w", err)
+	}
+	for name, value := range values {
+		if !processEnv[name] {
+			os.Setenv(name, value)
+		}
+	}
+	next, err := Load()
+	if err != nil {
+		return nil, fmt.Errorf("config.Reload: %w", err)
+	}
+
+	result := &ReloadResult{ReloadedAt: time.Now().UTC(), Applied: []*SettingChange{}, RestartRequired: []string{}}
+	cur, nxt := reflect.ValueOf(r.current).Elem(), reflect.ValueOf(next).Elem()
+	tunableFields := make(map[string]bool)
+	for _, t := range r.tunables {
+		var changes []*SettingChange
+		for _, f := range t.fields {
+			tunableFields[f] = true
+			old, new := cur.FieldByName(f).Interface(), nxt.FieldByName(f).Interface()
+			if !reflect.DeepEqual(old, new) {
+				changes = append(changes, &SettingChange{Setting: f, Old: old, New: new})
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		if err := t.apply(next); err != nil {
+			for _, c := range changes {
+				c.Error = err.Error()
+				nxt.FieldByName(c.Setting).Set(reflect.ValueOf(c.Old))
+			}
+		}
+		result.Applied = append(result.Applied, changes...)
+	}
+
+	started := reflect.ValueOf(r.started).Elem()
+	for i := 0; i < nxt.NumField(); i++ {
+		name := nxt.Type().Field(i).Name
+		if !tunableFields[name] && !reflect.DeepEqual(started.Field(i).Interface(), nxt.Field(i).Interface()) {
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+	r.current = next
+
+	applied, refused := []string{}, []string{}
+	for _, c := range result.Applied {
+		if c.Error != "" {
+			refused = append(refused, c.Setting+": "+c.Error)
+		} else {
+			applied = append(applied, c.Setting)
+		}
+	}
+	logger.Info(context.Background(), "Configuration reloaded", map[string]interface{}{
+		"applied": applied, "refused": refused, "restart_required": result.RestartRequired,
+	})
+	return result, nil
+}
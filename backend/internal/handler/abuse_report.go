@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Abuse report limits: free-text details length and review queue page sizes.
+const (
+	maxAbuseDetailsLen     = 4000
+	defaultAbuseQueueLimit = 50
+	maxAbuseQueueLimit     = 200
+)
+
+// AbuseReportHandler takes abuse reports against public links and serves the admin review queue.
+type AbuseReportHandler struct {
+	shareRepo  *repository.ShareLinkRepository
+	reportRepo *repository.AbuseReportRepository
+	notifRepo  *repository.NotificationRepository
+}
+
+func NewAbuseReportHandler(shareRepo *repository.ShareLinkRepository, reportRepo *repository.AbuseReportRepository, notifRepo *repository.NotificationRepository) *AbuseReportHandler {
+	return &AbuseReportHandler{
+		shareRepo:  shareRepo,
+		reportRepo: reportRepo,
+		notifRepo:  notifRepo,
+	}
+}
+
+// ReportShareLinkRequest is the payload for POST /share/{token}/report.
+type ReportShareLinkRequest struct {
+	Reason  string `json:"reason"            example:"copyright"` // copyright, malware, phishing, illegal, harassment, spam or other
+	Details string `json:"details,omitempty" example:"This is my copyrighted work, originally published at ..."`
+	Email   string `json:"email,omitempty"   example:"legal@example.com"` // optional contact for follow-up
+}
+
+// AbuseReportReceipt acknowledges a filed report.
+type AbuseReportReceipt struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status" example:"open"`
+}
+
+// ReportShareLink godoc
+// @Summary      Report a share link (public)
+// @Description  Files an abuse or copyright complaint about the content behind a share link for admins to
+// @Description  review. No password is needed. Rate-limited per IP address.
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        token path     string                 true "Share token"
+// @Param        body  body     ReportShareLinkRequest true "Report"
+// @Success      202   {object} respond.Envelope{data=AbuseReportReceipt}
+// @Failure      400   {object} ErrorResponse
+// @Failure      404   {object} ErrorResponse
+// @Failure      429   {object} ErrorResponse
+// @Router       /share/{token}/report [post]
+func (h *AbuseReportHandler) ReportShareLink(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4*maxAbuseDetailsLen+4096)
+	var req ReportShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	req.Reason = strings.ToLower(strings.TrimSpace(req.Reason))
+	if !slices.Contains(model.AbuseReasons, req.Reason) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "reason must be one of " + strings.Join(model.AbuseReasons, ", ")})
+		return
+	}
+	details := strings.TrimSpace(req.Details)
+	if len([]rune(details)) > maxAbuseDetailsLen {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "details must be at most 4000 characters"})
+		return
+	}
+	var email *string
+	if e := strings.TrimSpace(req.Email); e != "" {
+		if len(e) > 254 || !strings.Contains(e, "@") {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid email address"})
+			return
+		}
+		email = &e
+	}
+
+	link, err := h.shareRepo.FindByToken(r.Context(), token)
+	if err != nil || link == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "share link not found"})
+		return
+	}
+
+	report, err := h.reportRepo.Create(r.Context(), link, req.Reason, details, email, proxy.ClientIP(r))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to file report"})
+		return
+	}
+
+	logger.Warn(r.Context(), "Share link reported", map[string]interface{}{
+		"report_id": report.ID, "link_id": link.ID, "file_id": link.FileID, "reason": report.Reason,
+	})
+	writeJSON(w, http.StatusAccepted, AbuseReportReceipt{ID: report.ID, Status: report.Status})
+}
+
+// ListAbuseReports godoc
+// @Summary      List abuse reports
+// @Description  The review queue: open reports oldest first, with the reported file, its owner and how
+// @Description  many open reports the file has. ?status=dismissed|actioned lists resolved ones, newest first.
+// @Tags         admin
+// @Produce      json
+// @Param        status query    string false "open (default), dismissed or actioned"
+// @Param        limit  query    int    false "Max reports (default 50, max 200)"
+// @Success      200    {object}  respond.Envelope{data=[]model.AbuseReportWithFile}
+// @Failure      400    {object} ErrorResponse
+// @Failure      403    {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/abuse-reports [get]
+func (h *AbuseReportHandler) ListAbuseReports(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = model.AbuseStatusOpen
+	case model.AbuseStatusOpen, model.AbuseStatusDismissed, model.AbuseStatusActioned:
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "status must be open, dismissed or actioned"})
+		return
+	}
+
+	limit := defaultAbuseQueueLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAbuseQueueLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	reports, err := h.reportRepo.List(r.Context(), status, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list abuse reports"})
+		return
+	}
+	if reports == nil {
+		reports = []*model.AbuseReportWithFile{}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{Items: reports, Pagination: respond.Pagination{Count: len(reports), Limit: limit}})
+}
+
+// DisableReportedLink godoc
+// @Summary      Take down a reported share link
+// @Description  Disables the reported link for good (the owner cannot re-enable it), resolves every open
+// @Description  report against it and notifies the owner. Other links to the same file keep working.
+// @Tags         admin
+// @Produce      json
+// @Param        id  path     int true "Abuse report ID"
+// @Success      200 {object} respond.Envelope{data=model.Takedown}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse "Report already resolved"
+// @Failure      410 {object} ErrorResponse "Link no longer exists"
+// @Security     BearerAuth
+// @Router       /admin/abuse-reports/{id}/disable-link [post]
+func (h *AbuseReportHandler) DisableReportedLink(w http.ResponseWriter, r *http.Request) {
+	h.takeDown(w, r, h.reportRepo.TakeDownLink)
+}
+
+// DisableReportedFile godoc
+// @Summary      Take down a reported file
+// @Description  Disables every share link to the reported file and blocks new ones, resolves every open
+// @Description  report against the file and notifies the owner. The owner keeps private access to the file.
+// @Tags         admin
+// @Produce      json
+// @Param        id  path     int true "Abuse report ID"
+// @Success      200 {object} respond.Envelope{data=model.Takedown}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse "Report already resolved"
+// @Failure      410 {object} ErrorResponse "File no longer exists"
+// @Security     BearerAuth
+// @Router       /admin/abuse-reports/{id}/disable-file [post]
+func (h *AbuseReportHandler) DisableReportedFile(w http.ResponseWriter, r *http.Request) {
+	h.takeDown(w, r, h.reportRepo.TakeDownFile)
+}
+
+// takeDown resolves the report in the URL with action and notifies the content owner.
+func (h *AbuseReportHandler) takeDown(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, reportID, adminID int64) (*model.Takedown, error)) {
+	adminID, _ := auth.GetUserID(r)
+	report, ok := h.openReport(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := action(r.Context(), report.ID, adminID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrAbuseReportNotFound):
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "already_resolved", Message: "report was already resolved"})
+		case errors.Is(err, repository.ErrReportTargetGone):
+			writeJSON(w, http.StatusGone, ErrorResponse{Error: "target_gone", Message: "the reported link or file no longer exists"})
+		default:
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to take down content"})
+		}
+		return
+	}
+
+	audit.Describe(r.Context(), "abuse_report", strconv.FormatInt(report.ID, 10), report, t)
+	if _, err := h.notifRepo.Create(r.Context(), t.OwnerID, model.NotificationTakedown, model.TakedownNotice{
+		Action: t.Action, FileID: t.FileID, FileName: t.FileName, Reason: report.Reason,
+	}); err != nil {
+		logger.Warn(r.Context(), "Failed to notify owner of takedown", map[string]interface{}{
+			"report_id": report.ID, "owner_id": t.OwnerID, "error": err.Error(),
+		})
+	}
+
+	logger.Warn(r.Context(), "Reported content taken down", map[string]interface{}{
+		"admin_id": adminID, "report_id": report.ID, "action": t.Action, "file_id": t.FileID,
+		"links_disabled": t.LinksDisabled, "reports_resolved": t.ReportsResolved,
+	})
+	writeJSON(w, http.StatusOK, t)
+}
+
+// DismissAbuseReport godoc
+// @Summary      Dismiss an abuse report
+// @Description  Closes the report without taking anything down.
+// @Tags         admin
+// @Param        id  path int true "Abuse report ID"
+// @Success      204
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse "Report already resolved"
+// @Security     BearerAuth
+// @Router       /admin/abuse-reports/{id}/dismiss [post]
+func (h *AbuseReportHandler) DismissAbuseReport(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+	report, ok := h.openReport(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.reportRepo.Dismiss(r.Context(), report.ID, adminID); err != nil {
+		if errors.Is(err, repository.ErrAbuseReportNotFound) {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "already_resolved", Message: "report was already resolved"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to dismiss report"})
+		return
+	}
+
+	audit.Describe(r.Context(), "abuse_report", strconv.FormatInt(report.ID, 10),
+		map[string]string{"status": report.Status}, map[string]string{"status": model.AbuseStatusDismissed})
+	logger.Info(r.Context(), "Abuse report dismissed", map[string]interface{}{
+		"admin_id": adminID, "report_id": report.ID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// openReport loads the report in the URL and checks it is still open.
+// Writes the error response and returns false otherwise.
+func (h *AbuseReportHandler) openReport(w http.ResponseWriter, r *http.Request) (*model.AbuseReportWithFile, bool) {
+	reportID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid report id"})
+		return nil, false
+	}
+
+	report, err := h.reportRepo.FindByID(r.Context(), reportID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to load report"})
+		return nil, false
+	}
+	if report == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "abuse report not found"})
+		return nil, false
+	}
+	if report.Status != model.AbuseStatusOpen {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "already_resolved", Message: "report was already resolved"})
+		return nil, false
+	}
+	return report, true
+}
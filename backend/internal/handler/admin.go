@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// AdminHandler serves maintenance endpoints restricted to admins.
+type AdminHandler struct {
+	blockRepo    *repository.BlockRepository
+	fileRepo     *repository.FileRepository
+	jobRepo      *repository.JobRepository
+	deletionRepo *repository.S3DeletionRepository
+	runner       *jobs.Runner
+	cleanup      *jobs.Cleanup
+	s3           *storage.S3Client
+	blockKeys    block.KeySigner
+}
+
+func NewAdminHandler(blockRepo *repository.BlockRepository, fileRepo *repository.FileRepository, jobRepo *repository.JobRepository, deletionRepo *repository.S3DeletionRepository, runner *jobs.Runner, cleanup *jobs.Cleanup, s3 *storage.S3Client, blockKeys block.KeySigner) *AdminHandler {
+	return &AdminHandler{
+		blockRepo:    blockRepo,
+		fileRepo:     fileRepo,
+		jobRepo:      jobRepo,
+		deletionRepo: deletionRepo,
+		runner:       runner,
+		cleanup:      cleanup,
+		s3:           s3,
+		blockKeys:    blockKeys,
+	}
+}
+
+// StartBlockIntegrityCheck godoc
+// @Summary      Check block reference counts
+// @Description  Starts a background job that compares every block's ref_count with its actual references
+// @Description  and reports discrepancies. With ?repair=true drifted counts are rewritten.
+// @Description  Poll GET /admin/jobs/{id} for the model.BlockIntegrityReport result.
+// @Tags         admin
+// @Produce      json
+// @Param        repair query bool false "Repair drifted ref_counts"
+// @Success      202 {object} respond.Envelope{data=model.Job}
+// @Failure      403 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/blocks/integrity-check [post]
+func (h *AdminHandler) StartBlockIntegrityCheck(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+	repair := r.URL.Query().Get("repair") == "true"
+
+	job, err := h.runner.Submit(r.Context(), userID, jobs.KindBlockIntegrity, jobs.BlockIntegrityPayload{Repair: repair},
+		jobs.CheckBlockIntegrity(h.blockRepo, repair))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start integrity check"})
+		return
+	}
+
+	audit.Describe(r.Context(), "job", strconv.FormatInt(job.ID, 10), nil, job)
+	logger.Info(r.Context(), "Block integrity check requested", map[string]interface{}{
+		"user_id": userID, "job_id": job.ID, "repair": repair,
+	})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// StartBlockRekey godoc
+// @Summary      Move blocks to signed S3 keys
+// @Description  Starts a background job that copies every block not yet stored under its HMAC-derived key
+// @Description  (see BLOCK_KEY_SECRET) to that key and queues the old object for deletion. Safe to rerun.
+// @Description  Poll GET /admin/jobs/{id} for the model.BlockRekeyReport result.
+// @Tags         admin
+// @Produce      json
+// @Success      202 {object} respond.Envelope{data=model.Job}
+// @Failure      403 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/blocks/rekey [post]
+func (h *AdminHandler) StartBlockRekey(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+	if !h.blockKeys.Signed() {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "not_configured", Message: "block key secret is not configured"})
+		return
+	}
+
+	job, err := h.runner.Submit(r.Context(), userID, jobs.KindBlockRekey, nil,
+		jobs.RekeyBlocks(h.blockRepo, h.s3, h.blockKeys))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start block rekey"})
+		return
+	}
+
+	audit.Describe(r.Context(), "job", strconv.FormatInt(job.ID, 10), nil, job)
+	logger.Info(r.Context(), "Block rekey requested", map[string]interface{}{
+		"user_id": userID, "job_id": job.ID,
+	})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// GetJob godoc
+// @Summary      Get a background job
+// @Description  Returns any job by ID, including its result once completed.
+// @Tags         admin
+// @Produce      json
+// @Param        id path int true "Job ID"
+// @Success      200 {object} respond.Envelope{data=model.Job}
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/jobs/{id} [get]
+func (h *AdminHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid job id"})
+		return
+	}
+
+	job, err := h.jobRepo.FindByID(r.Context(), repository.Unscoped("admin"), jobID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch job"})
+		return
+	}
+	if job == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "job not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// ListDeadS3Deletions godoc
+// @Summary      List dead-lettered S3 deletions
+// @Description  Returns queued S3 object deletions that exhausted their retries, with the last error.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=[]model.S3Deletion}
+// @Failure      403 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/s3-deletions/dead [get]
+func (h *AdminHandler) ListDeadS3Deletions(w http.ResponseWriter, r *http.Request) {
+	dead, err := h.deletionRepo.ListDead(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list dead-lettered deletions"})
+		return
+	}
+	if dead == nil {
+		dead = []*model.S3Deletion{}
+	}
+
+	writeJSON(w, http.StatusOK, dead)
+}
+
+// PreviewCleanup godoc
+// @Summary      Preview destructive maintenance
+// @Description  Reports what each destructive scheduled task (block GC, trash purge, expired upload session
+// @Description  and share link purges, S3 deletions) would remove if it ran now, and how many bytes that
+// @Description  frees. Nothing is deleted. With MAINTENANCE_DRY_RUN=true the scheduled runs only log this
+// @Description  preview, so operators can check it before enabling them.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=model.CleanupReport}
+// @Failure      403 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/maintenance/preview [get]
+func (h *AdminHandler) PreviewCleanup(w http.ResponseWriter, r *http.Request) {
+	report, err := h.cleanup.Report(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to preview maintenance tasks"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// RetryDeadS3Deletions godoc
+// @Summary      Retry dead-lettered S3 deletions
+// @Description  Puts every dead-lettered deletion back in the queue with a fresh retry budget.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=map[string]int64}
+// @Failure      403 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/s3-deletions/dead/retry [post]
+func (h *AdminHandler) RetryDeadS3Deletions(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+
+	n, err := h.deletionRepo.RetryDead(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to requeue deletions"})
+		return
+	}
+
+	audit.Describe(r.Context(), "s3_deletions", "dead", nil, map[string]int64{"requeued": n})
+	logger.Info(r.Context(), "Dead-lettered S3 deletions requeued", map[string]interface{}{
+		"user_id": userID, "requeued": n,
+	})
+	writeJSON(w, http.StatusOK, map[string]int64{"requeued": n})
+}
+
+// ScanStatusRequest is the payload for PUT /admin/files/{id}/scan-status.
+type ScanStatusRequest struct {
+	Status string `json:"status" example:"clean"` // pending, clean or infected
+}
+
+// SetScanStatus godoc
+// @Summary      Report a malware scan verdict
+// @Description  Lets an external scanner record a file's verdict. Depending on SHARE_SCAN_GATE, share
+// @Description  links refuse infected files (451) and, in strict mode, files still pending (423).
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int               true "File ID"
+// @Param        body body     ScanStatusRequest true "Verdict"
+// @Success      200  {object} respond.Envelope{data=model.File}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/files/{id}/scan-status [put]
+func (h *AdminHandler) SetScanStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _ := auth.GetUserID(r)
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+
+	var req ScanStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	switch req.Status {
+	case model.ScanPending, model.ScanClean, model.ScanInfected:
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "status must be pending, clean or infected"})
+		return
+	}
+
+	before, err := h.fileRepo.FindByID(r.Context(), repository.Unscoped("admin"), fileID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+		return
+	}
+	if before == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
+		return
+	}
+
+	file, err := h.fileRepo.SetScanStatus(r.Context(), fileID, req.Status)
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to record scan status"})
+		return
+	}
+
+	audit.Describe(r.Context(), "file", strconv.FormatInt(file.ID, 10),
+		map[string]*string{"scan_status": before.ScanStatus}, map[string]*string{"scan_status": file.ScanStatus})
+	logger.Info(r.Context(), "File scan status recorded", map[string]interface{}{
+		"user_id": userID, "file_id": file.ID, "scan_status": req.Status,
+	})
+	writeJSON(w, http.StatusOK, file)
+}
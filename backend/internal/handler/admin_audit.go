@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Admin audit trail page sizes.
+const (
+	defaultAdminAuditLimit = 100
+	maxAdminAuditLimit     = 500
+)
+
+// AdminAuditHandler serves the admin audit trail.
+type AdminAuditHandler struct {
+	auditRepo *repository.AdminAuditRepository
+}
+
+func NewAdminAuditHandler(auditRepo *repository.AdminAuditRepository) *AdminAuditHandler {
+	return &AdminAuditHandler{auditRepo: auditRepo}
+}
+
+// ListAdminAudit godoc
+// @Summary      Query the admin audit trail
+// @Description  Every state-changing admin request with the admin who made it, its target, the target's state
+// @Description  before and after, and the response status, newest first. Records cannot be changed or deleted.
+// @Description  Page with before_id set to the last ID of the previous page.
+// @Tags         admin
+// @Produce      json
+// @Param        actor_id    query    int    false "Admin who made the request"
+// @Param        target_type query    string false "user, plan, file, mount, abuse_report, job or s3_deletions"
+// @Param        target_id   query    string false "ID of the target (requires target_type)"
+// @Param        since       query    string false "RFC 3339 time, inclusive"
+// @Param        until       query    string false "RFC 3339 time, exclusive"
+// @Param        before_id   query    int    false "Only records with a smaller ID"
+// @Param        limit       query    int    false "Max records (default 100, max 500)"
+// @Success      200         {object}  respond.Envelope{data=[]model.AdminAuditEntry}
+// @Failure      400         {object} ErrorResponse
+// @Failure      403         {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/audit [get]
+func (h *AdminAuditHandler) ListAdminAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := model.AdminAuditFilter{
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+		Limit:      defaultAdminAuditLimit,
+	}
+	if f.TargetID != "" && f.TargetType == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "target_id requires target_type"})
+		return
+	}
+
+	var err error
+	if v := q.Get("actor_id"); v != "" {
+		if f.ActorID, err = strconv.ParseInt(v, 10, 64); err != nil || f.ActorID < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid actor_id"})
+			return
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || f.BeforeID < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid before_id"})
+			return
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "since must be an RFC 3339 time"})
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "until must be an RFC 3339 time"})
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAdminAuditLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "limit must be between 1 and 500"})
+			return
+		}
+		f.Limit = n
+	}
+
+	entries, err := h.auditRepo.List(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list audit records"})
+		return
+	}
+	if entries == nil {
+		entries = []*model.AdminAuditEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{Items: entries, Pagination: respond.Pagination{Count: len(entries), Limit: f.Limit}})
+}
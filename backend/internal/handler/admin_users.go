@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// AdminUserHandler lets admins deactivate and reactivate accounts.
+type AdminUserHandler struct {
+	userRepo *repository.UserRepository
+}
+
+func NewAdminUserHandler(userRepo *repository.UserRepository) *AdminUserHandler {
+	return &AdminUserHandler{userRepo: userRepo}
+}
+
+// DeactivateUser godoc
+// @Summary      Deactivate a user
+// @Description  Blocks the account without deleting anything: sign-in and every authenticated request are refused,
+// @Description  cookie sessions end, refresh tokens are revoked, and the user's share and file-drop links stop working. Admins cannot deactivate themselves.
+// @Tags         admin
+// @Produce      json
+// @Param        id  path     int true "User ID"
+// @Success      200 {object} respond.Envelope{data=UserResponse}
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/deactivate [post]
+func (h *AdminUserHandler) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if userID == adminID {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "you cannot deactivate your own account"})
+		return
+	}
+
+	before, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		h.writeUserError(w, err, "failed to fetch user")
+		return
+	}
+
+	user, err := h.userRepo.Deactivate(r.Context(), userID, adminID)
+	if err != nil {
+		h.writeUserError(w, err, "failed to deactivate user")
+		return
+	}
+	audit.Describe(r.Context(), "user", strconv.FormatInt(user.ID, 10), newUserResponse(before), newUserResponse(user))
+
+	logger.Warn(r.Context(), "User deactivated", map[string]interface{}{"user_id": user.ID, "admin_id": adminID})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// ReactivateUser godoc
+// @Summary      Reactivate a user
+// @Description  Lifts a deactivation: the user can sign in again and their links work as before.
+// @Tags         admin
+// @Produce      json
+// @Param        id  path     int true "User ID"
+// @Success      200 {object} respond.Envelope{data=UserResponse}
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/reactivate [post]
+func (h *AdminUserHandler) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		h.writeUserError(w, err, "failed to fetch user")
+		return
+	}
+
+	user, err := h.userRepo.Reactivate(r.Context(), userID)
+	if err != nil {
+		h.writeUserError(w, err, "failed to reactivate user")
+		return
+	}
+	audit.Describe(r.Context(), "user", strconv.FormatInt(user.ID, 10), newUserResponse(before), newUserResponse(user))
+
+	logger.Info(r.Context(), "User reactivated", map[string]interface{}{"user_id": user.ID, "admin_id": adminID})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (h *AdminUserHandler) writeUserError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "user not found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: message})
+}
+
+// parseUserID reads the {id} URL parameter. Writes the error response and returns false if it is invalid.
+func parseUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || userID < 1 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
@@ -1,17 +1,26 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/i18n"
 	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/ratelimit"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"github.com/naratel/naratel-box/backend/internal/security"
 )
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
@@ -28,46 +37,138 @@ type LoginRequest struct {
 	Password string `json:"password" example:"supersecret123"`
 }
 
-// TokenResponse is returned on successful login.
+// TokenResponse is returned on successful login. In cookie session mode there is no
+// token; the session cookie authenticates and expires_at is when the session ends.
 type TokenResponse struct {
-	Token     string    `json:"token"      example:"eyJhbGciOiJIUzI1NiJ9..."`
+	Token     string    `json:"token,omitempty" example:"eyJhbGciOiJIUzI1NiJ9..."`
 	ExpiresAt time.Time `json:"expires_at" example:"2026-02-19T10:00:00Z"`
+
+	// RefreshToken trades for a new token at POST /auth/refresh until RefreshExpiresAt;
+	// each use replaces it. Only issued with a bearer token, when enabled.
+	RefreshToken     string     `json:"refresh_token,omitempty"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+}
+
+// RefreshRequest is the payload for POST /auth/refresh, and optionally for POST
+// /auth/logout to revoke the refresh token.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // UserResponse is returned for profile endpoints.
 type UserResponse struct {
 	UserID    int64     `json:"user_id"    example:"5"`
 	Email     string    `json:"email"      example:"user@example.com"`
+	Role      string    `json:"role"       example:"user"`
 	CreatedAt time.Time `json:"created_at" example:"2026-02-18T12:00:00Z"`
+
+	TOSVersion    *string    `json:"tos_version"     example:"2026-01"` // latest terms of service version accepted
+	TOSAcceptedAt *time.Time `json:"tos_accepted_at"`
+
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"` // set while an admin has deactivated the account
+
+	UsedBytes  int64  `json:"used_bytes" example:"1073741824"` // total size of the user's files, trash included
+	PlanID     *int64 `json:"plan_id"`                         // assigned plan; null = the default plan
+	QuotaBytes *int64 `json:"quota_bytes"`                     // set for this user, overriding the plan's; null = the plan's
+}
+
+func newUserResponse(u *model.User) UserResponse {
+	return UserResponse{
+		UserID:        u.ID,
+		Email:         u.Email,
+		Role:          u.Role,
+		CreatedAt:     u.CreatedAt,
+		TOSVersion:    u.TOSVersion,
+		TOSAcceptedAt: u.TOSAcceptedAt,
+		DeactivatedAt: u.DeactivatedAt,
+		UsedBytes:     u.UsedBytes,
+		PlanID:        u.PlanID,
+		QuotaBytes:    u.QuotaBytes,
+	}
 }
 
-// ErrorResponse is the standard error envelope.
+// ErrorResponse is the standard error body. Unlike successful responses it is not
+// wrapped in a respond.Envelope; writeJSON fills in RequestID.
 type ErrorResponse struct {
-	Error   string `json:"error"   example:"unauthorized"`
-	Message string `json:"message" example:"invalid email or password"`
+	Error     string `json:"error"                example:"unauthorized"`
+	Message   string `json:"message"              example:"invalid email or password"`
+	RequestID string `json:"request_id,omitempty" example:"4f9c2a4e-1c1b-4d55-9e43-0c1f6a3e8b21"`
 }
 
 // AuthHandler handles authentication endpoints.
 type AuthHandler struct {
-	userRepo       *repository.UserRepository
-	jwtSecret      string
-	jwtExpiryHours int
+	userRepo      *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+	refreshRepo   *repository.RefreshTokenRepository
+	jwtSecret     string
+	accessTTL     time.Duration
+	refreshTTL    time.Duration // 0 = no refresh tokens
+	sessionMode   auth.SessionMode
+	sessionTTL    time.Duration
+	cookies       auth.CookieConfig
+	loginFailures *ratelimit.Limiter
+	basicLogins   *auth.CredentialCache // verified Basic credentials, see BasicLogin
 }
 
 // NewAuthHandler creates a new AuthHandler.
-func NewAuthHandler(userRepo *repository.UserRepository, jwtSecret string, jwtExpiryHours int) *AuthHandler {
+func NewAuthHandler(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, refreshRepo *repository.RefreshTokenRepository,
+	jwtSecret string, accessTTL, refreshTTL time.Duration,
+	sessionMode auth.SessionMode, sessionTTL time.Duration, cookies auth.CookieConfig, loginFailures *ratelimit.Limiter) *AuthHandler {
 	return &AuthHandler{
-		userRepo:       userRepo,
-		jwtSecret:      jwtSecret,
-		jwtExpiryHours: jwtExpiryHours,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		refreshRepo:   refreshRepo,
+		jwtSecret:     jwtSecret,
+		accessTTL:     accessTTL,
+		refreshTTL:    refreshTTL,
+		sessionMode:   sessionMode,
+		sessionTTL:    sessionTTL,
+		cookies:       cookies,
+		loginFailures: loginFailures,
+		basicLogins:   auth.NewCredentialCache(basicLoginTTL),
 	}
 }
 
 // writeJSON is a helper that writes a JSON response with the given status code.
+// Successful responses are wrapped in a respond.Envelope (pass a respond.Page to add
+// a list's pagination); error bodies (status 400 and up) are sent flat with the
+// request ID filled in. ErrorResponse messages are translated into the language
+// negotiated for the request.
 func writeJSON(w http.ResponseWriter, status int, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(v)
+	switch e := v.(type) {
+	case ErrorResponse:
+		if e.Message != "" {
+			var lang string
+			e.Message, lang = i18n.Localize(w, e.Message)
+			w.Header().Set("Content-Language", lang)
+		}
+		e.RequestID = respond.RequestID(w)
+		v = e
+	case FileExistsResponse:
+		e.RequestID = respond.RequestID(w)
+		v = e
+	}
+	if status >= http.StatusBadRequest {
+		respond.Write(w, status, v)
+		return
+	}
+	respond.JSON(w, status, v)
+}
+
+// writeRepoError answers a failed repository call by the kind of the error: 404 with
+// notFound for repository.ErrNotFound, 409 for ErrConflict, 403 for ErrForbidden, and
+// otherwise a 500 with failed.
+func writeRepoError(w http.ResponseWriter, err error, notFound, failed string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: notFound})
+	case errors.Is(err, repository.ErrConflict):
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "conflict", Message: "the change conflicts with existing data"})
+	case errors.Is(err, repository.ErrForbidden):
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "you do not have access to this resource"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: failed})
+	}
 }
 
 // Register godoc
@@ -77,7 +178,7 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 // @Accept       json
 // @Produce      json
 // @Param        body body     RegisterRequest true "Register payload"
-// @Success      201  {object} UserResponse
+// @Success      201  {object} respond.Envelope{data=UserResponse}
 // @Failure      400  {object} ErrorResponse
 // @Failure      409  {object} ErrorResponse
 // @Router       /auth/register [post]
@@ -130,19 +231,22 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	logger.Info(r.Context(), "User registered successfully", map[string]interface{}{
 		"user_id": user.ID, "email": user.Email,
 	})
-	writeJSON(w, http.StatusCreated, UserResponse{UserID: user.ID, Email: user.Email, CreatedAt: user.CreatedAt})
+	writeJSON(w, http.StatusCreated, newUserResponse(user))
 }
 
 // Login godoc
 // @Summary      Login
-// @Description  Authenticate with email and password, receive a JWT token
+// @Description  Authenticate with email and password. Depending on the server's session mode this returns a JWT, sets an HttpOnly session cookie (plus the nb_csrf cookie), or both.
+// @Description  With a JWT comes a refresh token (unless REFRESH_TOKEN_TTL_HOURS is 0) to get new JWTs from POST /auth/refresh without signing in again.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        body body     LoginRequest true "Login payload"
-// @Success      200  {object} TokenResponse
+// @Success      200  {object} respond.Envelope{data=TokenResponse}
 // @Failure      400  {object} ErrorResponse
 // @Failure      401  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse "Account deactivated"
+// @Failure      429  {object} ErrorResponse "Too many failed logins from this client or for this account"
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
@@ -157,32 +261,274 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.userRepo.FindByEmail(r.Context(), req.Email)
-	if err != nil {
-		logger.Warn(r.Context(), "Login failed - user not found", map[string]interface{}{"email": req.Email})
-		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "invalid email or password"})
+	user := h.checkPassword(w, r, req.Email, req.Password, nil)
+	if user == nil {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		logger.Warn(r.Context(), "Login failed - invalid password", map[string]interface{}{"user_id": user.ID, "email": req.Email})
+	var resp TokenResponse
+	if h.sessionMode.Cookies() {
+		expiresAt, ok := h.startSession(w, r, user.ID)
+		if !ok {
+			return
+		}
+		resp.ExpiresAt = expiresAt
+	}
+	if h.sessionMode.Bearer() {
+		if !h.issueTokens(w, r, user, &resp) {
+			return
+		}
+	}
+
+	logger.Info(r.Context(), "User logged in successfully", map[string]interface{}{
+		"user_id": user.ID, "email": user.Email, "session_mode": h.sessionMode,
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// checkPassword returns the active user with email and password. Failed attempts are
+// counted per client IP and per account; either running out refuses further attempts
+// until its window resets. The password is checked through credentials unless nil. On
+// failure it writes the error response and returns nil.
+func (h *AuthHandler) checkPassword(w http.ResponseWriter, r *http.Request, email, password string, credentials *auth.CredentialCache) *model.User {
+	failureKeys := []string{"ip:" + proxy.ClientKey(r), "email:" + strings.ToLower(email)}
+	for _, key := range failureKeys {
+		if blocked, retryAfter := h.loginFailures.Exceeded(key); blocked {
+			logger.Warn(r.Context(), "Login throttled", map[string]interface{}{"email": email, "key": key})
+			security.Record(r, model.SecurityLoginThrottled, 0, map[string]interface{}{"email": email, "key": key})
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: "rate_limited", Message: "too many failed login attempts, try again later"})
+			return nil
+		}
+	}
+	loginFailed := func(userID int64, reason string) {
+		for _, key := range failureKeys {
+			h.loginFailures.Allow(key)
+		}
+		security.Record(r, model.SecurityLoginFailed, userID, map[string]interface{}{"email": email, "reason": reason})
 		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "invalid email or password"})
-		return
 	}
 
-	token, expiresAt, err := auth.GenerateToken(user.ID, user.Email, h.jwtSecret, h.jwtExpiryHours)
+	user, err := h.userRepo.FindByEmail(r.Context(), email)
+	if err != nil {
+		logger.Warn(r.Context(), "Login failed - user not found", map[string]interface{}{"email": email})
+		loginFailed(0, "unknown email")
+		return nil
+	}
+
+	if !credentials.Verify(email, password, user.Password) {
+		logger.Warn(r.Context(), "Login failed - invalid password", map[string]interface{}{"user_id": user.ID, "email": email})
+		loginFailed(user.ID, "wrong password")
+		return nil
+	}
+	// Checked only after the password so the response does not reveal the account
+	// state to someone guessing.
+	if user.DeactivatedAt != nil {
+		logger.Warn(r.Context(), "Login refused - account deactivated", map[string]interface{}{"user_id": user.ID})
+		security.Record(r, model.SecurityLoginFailed, user.ID, map[string]interface{}{"email": email, "reason": "account deactivated"})
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "account_deactivated", Message: "this account has been deactivated"})
+		return nil
+	}
+	return user
+}
+
+// basicLoginTTL is how long BasicLogin trusts a password it verified before running
+// bcrypt on it again.
+const basicLoginTTL = time.Minute
+
+// BasicLogin checks HTTP Basic credentials for auth.Basic, with the same throttling
+// and account checks as Login. Clients send the credentials with every request, so
+// the bcrypt check is cached for basicLoginTTL; the account is still looked up each
+// time, and a changed password or deactivated account is refused at once. Nothing is
+// issued.
+func (h *AuthHandler) BasicLogin(w http.ResponseWriter, r *http.Request, email, password string) (int64, string, bool) {
+	user := h.checkPassword(w, r, email, password, h.basicLogins)
+	if user == nil {
+		return 0, "", false
+	}
+	return user.ID, user.Email, true
+}
+
+// issueTokens fills resp with a JWT for user and, if enabled, the first refresh token
+// of a new login. On failure it writes the error response and returns false.
+func (h *AuthHandler) issueTokens(w http.ResponseWriter, r *http.Request, user *model.User, resp *TokenResponse) bool {
+	if !h.issueAccessToken(w, r, user, resp) {
+		return false
+	}
+	if h.refreshTTL <= 0 {
+		return true
+	}
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to generate refresh token", logger.ErrorDetails{
+			Code: "CRYPTO_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+		return false
+	}
+	expiresAt := time.Now().Add(h.refreshTTL)
+	if _, err := h.refreshRepo.Create(r.Context(), auth.HashRefreshToken(refreshToken), user.ID, proxy.ClientIP(r), r.UserAgent(), expiresAt); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to generate token"})
+		return false
+	}
+	resp.RefreshToken, resp.RefreshExpiresAt = refreshToken, &expiresAt
+	return true
+}
+
+// issueAccessToken sets resp's JWT for user. On failure it writes the error response
+// and returns false.
+func (h *AuthHandler) issueAccessToken(w http.ResponseWriter, r *http.Request, user *model.User, resp *TokenResponse) bool {
+	token, expiresAt, err := auth.GenerateToken(user.ID, user.Email, h.jwtSecret, h.accessTTL)
 	if err != nil {
 		logger.ErrorLog(r.Context(), "Failed to generate JWT token", logger.ErrorDetails{
 			Code: "JWT_GEN_ERR", Details: err.Error(),
 		})
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+		return false
+	}
+	resp.Token, resp.ExpiresAt = token, expiresAt
+	return true
+}
+
+// Refresh godoc
+// @Summary      Refresh a JWT
+// @Description  Trades a refresh token from login (or the previous refresh) for a new JWT and a new refresh
+// @Description  token; the one sent stops working. Sending an already used refresh token again signs out that
+// @Description  login everywhere, as it means the token was copied: clients must keep only the latest one and
+// @Description  not refresh concurrently.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body     RefreshRequest true "Refresh token"
+// @Success      200  {object} respond.Envelope{data=TokenResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse "Unknown, expired, revoked or reused refresh token"
+// @Failure      403  {object} ErrorResponse "Account deactivated"
+// @Failure      404  {object} ErrorResponse "Refresh tokens are not enabled"
+// @Router       /auth/refresh [post]
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if !h.sessionMode.Bearer() || h.refreshTTL <= 0 {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "refresh tokens are not enabled"})
+		return
+	}
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "refresh_token is required"})
 		return
 	}
 
-	logger.Info(r.Context(), "User logged in successfully", map[string]interface{}{
-		"user_id": user.ID, "email": user.Email,
-	})
-	writeJSON(w, http.StatusOK, TokenResponse{Token: token, ExpiresAt: expiresAt})
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to generate refresh token", logger.ErrorDetails{
+			Code: "CRYPTO_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+		return
+	}
+	expiresAt := time.Now().Add(h.refreshTTL)
+	rt, err := h.refreshRepo.Rotate(r.Context(), auth.HashRefreshToken(req.RefreshToken), auth.HashRefreshToken(refreshToken),
+		proxy.ClientIP(r), r.UserAgent(), expiresAt)
+	switch {
+	case errors.Is(err, repository.ErrRefreshTokenReused):
+		logger.Warn(r.Context(), "Refresh token reused; login revoked", map[string]interface{}{"user_id": rt.UserID, "family_id": rt.FamilyID})
+		security.Record(r, model.SecurityRefreshTokenReused, rt.UserID, map[string]interface{}{"family_id": rt.FamilyID})
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "invalid refresh token"})
+		return
+	case errors.Is(err, repository.ErrRefreshTokenInvalid):
+		security.Record(r, model.SecurityTokenInvalid, 0, map[string]interface{}{"reason": "unknown or expired refresh token"})
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "invalid refresh token"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to refresh token"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), rt.UserID)
+	if err != nil {
+		writeRepoError(w, err, "user not found", "failed to fetch user")
+		return
+	}
+	if user.DeactivatedAt != nil {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "account_deactivated", Message: "this account has been deactivated"})
+		return
+	}
+
+	resp := TokenResponse{RefreshToken: refreshToken, RefreshExpiresAt: &expiresAt}
+	if !h.issueAccessToken(w, r, user, &resp) {
+		return
+	}
+	logger.Info(r.Context(), "Token refreshed", map[string]interface{}{"user_id": user.ID, "family_id": rt.FamilyID})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// startSession creates a server-side session for userID and sets its HttpOnly cookie,
+// together with a CSRF cookie for the web UI to echo. On failure it writes the error
+// response and returns false.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int64) (time.Time, bool) {
+	sessionID, err := auth.NewSessionID()
+	if err == nil {
+		var csrfToken string
+		if csrfToken, err = auth.NewCSRFToken(); err == nil {
+			http.SetCookie(w, h.cookies.Cookie(auth.CSRFCookieName, csrfToken, 0, false))
+		}
+	}
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to generate session ID", logger.ErrorDetails{
+			Code: "CRYPTO_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to start session"})
+		return time.Time{}, false
+	}
+
+	expiresAt := time.Now().Add(h.sessionTTL)
+	if _, err := h.sessionRepo.Create(r.Context(), auth.HashSessionID(sessionID), userID, proxy.ClientIP(r), r.UserAgent(), expiresAt); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start session"})
+		return time.Time{}, false
+	}
+	http.SetCookie(w, h.cookies.Cookie(auth.SessionCookieName, sessionID, h.sessionTTL, true))
+	return expiresAt, true
+}
+
+// Logout godoc
+// @Summary      Logout
+// @Description  Ends the cookie session, if any, and clears the session and CSRF cookies. A refresh_token in the body is revoked,
+// @Description  with every token its login was refreshed to. Bearer tokens stay valid until they expire; clients simply discard them.
+// @Tags         auth
+// @Accept       json
+// @Param        body body RefreshRequest false "Refresh token to revoke"
+// @Success      204
+// @Failure      500 {object} ErrorResponse
+// @Router       /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(auth.SessionCookieName); err == nil && cookie.Value != "" {
+		if err := h.sessionRepo.Delete(r.Context(), auth.HashSessionID(cookie.Value)); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to end session"})
+			return
+		}
+		logger.Info(r.Context(), "Session ended", nil)
+	}
+	// The body is optional; cookie clients send none.
+	var req RefreshRequest
+	if json.NewDecoder(r.Body).Decode(&req) == nil && req.RefreshToken != "" {
+		revoked, err := h.refreshRepo.RevokeFamily(r.Context(), auth.HashRefreshToken(req.RefreshToken))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to revoke refresh token"})
+			return
+		}
+		logger.Info(r.Context(), "Refresh tokens revoked", map[string]interface{}{"revoked": revoked})
+	}
+	http.SetCookie(w, h.cookies.Cookie(auth.SessionCookieName, "", -1, true))
+	http.SetCookie(w, h.cookies.Cookie(auth.CSRFCookieName, "", -1, false))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LookupSession resolves a session cookie for auth.Authenticate.
+func (h *AuthHandler) LookupSession(ctx context.Context, idHash []byte) (*auth.SessionUser, error) {
+	s, err := h.sessionRepo.FindActive(ctx, idHash)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &auth.SessionUser{UserID: s.UserID, Email: s.Email}, nil
 }
 
 // Me godoc
@@ -190,7 +536,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 // @Description  Returns the profile of the currently authenticated user
 // @Tags         auth
 // @Produce      json
-// @Success      200 {object} UserResponse
+// @Success      200 {object} respond.Envelope{data=UserResponse}
 // @Failure      401 {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /auth/me [get]
@@ -210,5 +556,5 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Info(r.Context(), "User profile retrieved", map[string]interface{}{"user_id": user.ID})
-	writeJSON(w, http.StatusOK, UserResponse{UserID: user.ID, Email: user.Email, CreatedAt: user.CreatedAt})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
 }
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxClientLen caps the client description stored with file modifications.
+const maxClientLen = 200
+
+// requestClient describes the client that sent r, for the modified_client column.
+// Apps identify themselves with X-Client (e.g. "naratel-web/1.4 (macOS)"); anything
+// else is known by its User-Agent.
+func requestClient(r *http.Request) string {
+	client := strings.TrimSpace(r.Header.Get("X-Client"))
+	if client == "" {
+		client = strings.TrimSpace(r.UserAgent())
+	}
+	if len(client) > maxClientLen {
+		client = client[:maxClientLen]
+		for !utf8.ValidString(client) {
+			client = client[:len(client)-1]
+		}
+	}
+	return client
+}
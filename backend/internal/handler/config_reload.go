@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/config"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// ConfigHandler reloads the configuration of the running server.
+type ConfigHandler struct {
+	reloader *config.Reloader
+}
+
+// NewConfigHandler creates a ConfigHandler applying reloads through reloader.
+func NewConfigHandler(reloader *config.Reloader) *ConfigHandler {
+	return &ConfigHandler{reloader: reloader}
+}
+
+// ReloadConfig godoc
+// @Summary      Reload tunable settings
+// @Description  Re-reads .env and the environment, like SIGHUP, and applies the tunable settings without a
+// @Description  restart: LOG_LEVEL, DB_SLOW_QUERY_MS, BLOCK_WORKERS, UPLOAD_MAX_CONCURRENT, UPLOAD_QUEUE_SIZE,
+// @Description  UPLOAD_QUEUE_TIMEOUT_SECONDS, UPLOAD_MAX_FILE_MB and the rate limits. Uploads in progress keep
+// @Description  running. Reports the settings applied (or refused, with the reason) and the other settings
+// @Description  that changed but only take effect after a restart.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=config.ReloadResult}
+// @Failure      403 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/config/reload [post]
+func (h *ConfigHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	result, err := h.reloader.Reload()
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Configuration reload failed", logger.ErrorDetails{
+			Code: "CONFIG_RELOAD_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "reload_failed", Message: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// CSRFHandler hands browser clients the CSRF token for cookie sessions.
+type CSRFHandler struct {
+	cookies auth.CookieConfig
+}
+
+func NewCSRFHandler(cookies auth.CookieConfig) *CSRFHandler {
+	return &CSRFHandler{cookies: cookies}
+}
+
+// CSRFTokenResponse carries the token to send in X-CSRF-Token.
+type CSRFTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// IssueCSRFToken godoc
+// @Summary      Get a CSRF token
+// @Description  Sets the nb_csrf cookie and returns its value. Browser clients using cookie sessions must send it in X-CSRF-Token on POST, PUT, PATCH and DELETE requests. An existing token is returned unchanged so open tabs keep working.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=CSRFTokenResponse}
+// @Failure      500 {object} ErrorResponse
+// @Router       /auth/csrf [get]
+func (h *CSRFHandler) IssueCSRFToken(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(auth.CSRFCookieName); err == nil && c.Value != "" {
+		writeJSON(w, http.StatusOK, CSRFTokenResponse{CSRFToken: c.Value})
+		return
+	}
+
+	token, err := auth.NewCSRFToken()
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to generate CSRF token", logger.ErrorDetails{
+			Code: "CRYPTO_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+		return
+	}
+	// Readable by the web UI's script on purpose; the session cookie is the HttpOnly one.
+	http.SetCookie(w, h.cookies.Cookie(auth.CSRFCookieName, token, 0, false))
+	writeJSON(w, http.StatusOK, CSRFTokenResponse{CSRFToken: token})
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// fileCursor is the JSON form of a repository.FileCursor. The sort is included so a
+// cursor cannot be replayed against a listing in a different order.
+type fileCursor struct {
+	Sort      repository.FileSort `json:"s"`
+	Name      string              `json:"n,omitempty"`
+	CreatedAt *time.Time          `json:"t,omitempty"`
+	ID        int64               `json:"id"`
+}
+
+// encodeFileCursor turns a page cursor into the opaque next_cursor string.
+func encodeFileCursor(sort repository.FileSort, c *repository.FileCursor) string {
+	fc := fileCursor{Sort: sort, ID: c.ID}
+	if sort == repository.FileSortCreated {
+		fc.CreatedAt = &c.CreatedAt
+	} else {
+		fc.Name = c.Name
+	}
+	b, _ := json.Marshal(fc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeFileCursor parses a next_cursor string issued for the given sort.
+func decodeFileCursor(sort repository.FileSort, s string) (*repository.FileCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var fc fileCursor
+	if err := json.Unmarshal(b, &fc); err != nil || fc.Sort != sort || fc.ID <= 0 {
+		return nil, errInvalidCursor
+	}
+	c := &repository.FileCursor{Name: fc.Name, ID: fc.ID}
+	if sort == repository.FileSortCreated {
+		if fc.CreatedAt == nil {
+			return nil, errInvalidCursor
+		}
+		c.CreatedAt = *fc.CreatedAt
+	}
+	return c, nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+const jobKindDataReport = "data_report"
+
+// DataReport is the machine-readable export of everything stored about a user.
+// Sessions are stateless JWTs and no activity history is persisted, so neither
+// has a section of its own.
+type DataReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Profile     UserResponse       `json:"profile"`
+	Folders     []*model.Folder    `json:"folders"`
+	Files       []*model.File      `json:"files"`
+	ShareLinks  []*model.ShareLink `json:"share_links"`
+}
+
+// DataReportHandler serves GDPR data-access reports.
+type DataReportHandler struct {
+	userRepo   *repository.UserRepository
+	fileRepo   *repository.FileRepository
+	folderRepo *repository.FolderRepository
+	shareRepo  *repository.ShareLinkRepository
+	jobRepo    *repository.JobRepository
+	runner     *jobs.Runner
+}
+
+func NewDataReportHandler(
+	userRepo *repository.UserRepository,
+	fileRepo *repository.FileRepository,
+	folderRepo *repository.FolderRepository,
+	shareRepo *repository.ShareLinkRepository,
+	jobRepo *repository.JobRepository,
+	runner *jobs.Runner,
+) *DataReportHandler {
+	return &DataReportHandler{
+		userRepo:   userRepo,
+		fileRepo:   fileRepo,
+		folderRepo: folderRepo,
+		shareRepo:  shareRepo,
+		jobRepo:    jobRepo,
+		runner:     runner,
+	}
+}
+
+// GetDataReport godoc
+// @Summary      Get a GDPR data-access report
+// @Description  Returns the latest generated report of all data stored about the current user.
+// @Description  If no report exists (or ?refresh=true), a background job is started and 202 is returned with the job;
+// @Description  poll the same endpoint until the report is ready.
+// @Tags         auth
+// @Produce      json
+// @Param        refresh query bool false "Generate a fresh report"
+// @Success      200 {object} respond.Envelope{data=DataReport}
+// @Success      202 {object} respond.Envelope{data=model.Job}
+// @Failure      401 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /auth/me/data-report [get]
+func (h *DataReportHandler) GetDataReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	job, err := h.jobRepo.FindLatestByKind(r.Context(), userID, jobKindDataReport)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to look up data report"})
+		return
+	}
+
+	inFlight := job != nil && (job.Status == model.JobPending || job.Status == model.JobRunning)
+	if job == nil || (r.URL.Query().Get("refresh") == "true" && !inFlight) {
+		job, err = h.runner.Submit(r.Context(), userID, jobKindDataReport, nil, func(ctx context.Context, p *jobs.Progress) (interface{}, error) {
+			return h.buildReport(ctx, userID, p)
+		})
+		if err != nil {
+			logger.ErrorLog(r.Context(), "Failed to start data report job", logger.ErrorDetails{
+				Code: "JOB_SUBMIT_ERR", Details: err.Error(),
+			})
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start data report"})
+			return
+		}
+		logger.Info(r.Context(), "Data report requested", map[string]interface{}{
+			"user_id": userID, "job_id": job.ID,
+		})
+		writeJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	switch job.Status {
+	case model.JobCompleted:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="data-report-%d.json"`, userID))
+		w.WriteHeader(http.StatusOK)
+		w.Write(job.Result)
+	case model.JobFailed:
+		msg := "data report generation failed"
+		if job.Error != nil {
+			msg = *job.Error
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "report_failed", Message: msg})
+	default:
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// buildReport gathers every user-owned record into a DataReport.
+func (h *DataReportHandler) buildReport(ctx context.Context, userID int64, p *jobs.Progress) (*DataReport, error) {
+	user, err := h.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	p.Set(25)
+
+	folders, err := h.folderRepo.ListAllByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	p.Set(50)
+
+	files, err := h.fileRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	p.Set(75)
+
+	links, err := h.shareRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DataReport{
+		GeneratedAt: time.Now().UTC(),
+		Profile:     newUserResponse(user),
+		Folders:     folders,
+		Files:       files,
+		ShareLinks:  links,
+	}
+	if report.Folders == nil {
+		report.Folders = []*model.Folder{}
+	}
+	if report.Files == nil {
+		report.Files = []*model.File{}
+	}
+	if report.ShareLinks == nil {
+		report.ShareLinks = []*model.ShareLink{}
+	}
+	return report, nil
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// contentDisposition builds a Content-Disposition header value ("inline" or
+// "attachment") carrying both an ASCII-only filename for old clients and an
+// RFC 5987 filename* with the exact UTF-8 name. Quotes, backslashes and control
+// characters never reach the header, so a crafted filename cannot inject headers.
+func contentDisposition(dispositionType, filename string) string {
+	return dispositionType + `; filename="` + asciiFilename(filename) + `"; filename*=UTF-8''` + rfc5987Encode(filename)
+}
+
+// asciiFilename replaces everything that is not safe inside a quoted-string with '_'.
+func asciiFilename(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "download"
+	}
+	return b.String()
+}
+
+// rfc5987Encode percent-encodes every byte outside RFC 5987 attr-char.
+func rfc5987Encode(s string) string {
+	const hex = "0123456789ABCDEF"
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "_")
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
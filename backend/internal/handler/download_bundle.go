@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// DownloadBundleHandler lets clients download many files as one zip: a background
+// job assembles the bundle while the client polls, then streams it in one request.
+type DownloadBundleHandler struct {
+	fileRepo  *repository.FileRepository
+	blockRepo *repository.BlockRepository
+	jobRepo   *repository.JobRepository
+	s3        *storage.S3Client
+	runner    *jobs.Runner
+	store     *jobs.BundleStore
+	egress    *service.EgressService
+
+	maxFiles int
+	maxBytes int64
+}
+
+func NewDownloadBundleHandler(
+	fileRepo *repository.FileRepository,
+	blockRepo *repository.BlockRepository,
+	jobRepo *repository.JobRepository,
+	s3 *storage.S3Client,
+	runner *jobs.Runner,
+	store *jobs.BundleStore,
+	egress *service.EgressService,
+	maxFiles int,
+	maxBytes int64,
+) *DownloadBundleHandler {
+	return &DownloadBundleHandler{
+		fileRepo:  fileRepo,
+		blockRepo: blockRepo,
+		jobRepo:   jobRepo,
+		s3:        s3,
+		runner:    runner,
+		store:     store,
+		egress:    egress,
+		maxFiles:  maxFiles,
+		maxBytes:  maxBytes,
+	}
+}
+
+// CreateDownloadRequest lists the files to bundle.
+type CreateDownloadRequest struct {
+	FileIDs []int64 `json:"file_ids"`
+}
+
+// CreateDownload godoc
+// @Summary      Start a multi-file download
+// @Description  Starts a background job that bundles the given files into a zip. Poll GET /downloads/{id}
+// @Description  for progress, then fetch GET /downloads/{id}/content once the job is completed.
+// @Tags         downloads
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreateDownloadRequest true "Files to bundle"
+// @Success      202  {object} respond.Envelope{data=model.Job}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      413  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /downloads [post]
+func (h *DownloadBundleHandler) CreateDownload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CreateDownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.FileIDs) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "file_ids is required"})
+		return
+	}
+	if len(req.FileIDs) > h.maxFiles {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "too_many_files", Message: fmt.Sprintf("a download may contain at most %d files", h.maxFiles),
+		})
+		return
+	}
+
+	files := make([]*model.File, 0, len(req.FileIDs))
+	seen := make(map[int64]bool, len(req.FileIDs))
+	var totalBytes int64
+	for _, id := range req.FileIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		file, err := h.fileRepo.FindByIDAndUserID(r.Context(), id, userID)
+		if err != nil || file == nil {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: fmt.Sprintf("file %d not found or unauthorized", id)})
+			return
+		}
+		files = append(files, file)
+		totalBytes += file.TotalSize
+	}
+	if h.maxBytes > 0 && totalBytes > h.maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "too_large", Message: fmt.Sprintf("selected files exceed the %d MB download limit", h.maxBytes/(1024*1024)),
+		})
+		return
+	}
+
+	job, err := h.runner.Submit(r.Context(), userID, jobs.KindDownloadBundle, jobs.DownloadBundlePayload{FileIDs: req.FileIDs},
+		jobs.BuildDownloadBundle(h.blockRepo, h.s3, h.store, files))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start download"})
+		return
+	}
+
+	logger.Info(r.Context(), "Download bundle requested", map[string]interface{}{
+		"user_id": userID, "job_id": job.ID, "files": len(files), "total_size": totalBytes,
+	})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// GetDownload godoc
+// @Summary      Get multi-file download progress
+// @Tags         downloads
+// @Produce      json
+// @Param        id  path     int true "Download job ID"
+// @Success      200 {object} respond.Envelope{data=model.Job}
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /downloads/{id} [get]
+func (h *DownloadBundleHandler) GetDownload(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// DownloadContent godoc
+// @Summary      Stream a finished multi-file download
+// @Description  Streams the zip built by the download job. Supports Range requests.
+// @Tags         downloads
+// @Produce      application/zip
+// @Param        id  path     int true "Download job ID"
+// @Success      200 {file}   binary
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      410 {object} ErrorResponse
+// @Failure      429 {object} ErrorResponse "monthly egress limit reached"
+// @Security     BearerAuth
+// @Router       /downloads/{id}/content [get]
+func (h *DownloadBundleHandler) DownloadContent(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.findJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != model.JobCompleted {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "not_ready", Message: "download is still being prepared"})
+		return
+	}
+
+	var result jobs.DownloadBundleResult
+	if err := json.Unmarshal(job.Result, &result); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "invalid download result"})
+		return
+	}
+
+	f, err := h.store.Open(result.Bundle)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			writeJSON(w, http.StatusGone, ErrorResponse{Error: "expired", Message: "download has expired, request it again"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to open download"})
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to open download"})
+		return
+	}
+
+	rate, ok := checkEgress(w, r, h.egress, job.UserID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", fmt.Sprintf("download-%d.zip", job.ID)))
+	mw := newMeteredWriter(r.Context(), w, rate)
+	http.ServeContent(mw, r, "", info.ModTime(), f)
+	recordEgress(r.Context(), h.egress, job.UserID, mw)
+
+	logger.Info(r.Context(), "Download bundle served", map[string]interface{}{
+		"user_id": job.UserID, "job_id": job.ID, "size_bytes": result.SizeBytes, "files": result.FileCount,
+	})
+}
+
+// findJob loads the download job from the URL and checks it belongs to the caller.
+func (h *DownloadBundleHandler) findJob(w http.ResponseWriter, r *http.Request) (*model.Job, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return nil, false
+	}
+
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid download id"})
+		return nil, false
+	}
+
+	job, err := h.jobRepo.FindByID(r.Context(), repository.ForUser(userID), jobID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch download"})
+		return nil, false
+	}
+	if job == nil || job.Kind != jobs.KindDownloadBundle {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "download not found"})
+		return nil, false
+	}
+	return job, true
+}
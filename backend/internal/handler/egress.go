@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// egressChunk is how much a throttled writer sends between pauses.
+const egressChunk = 32 << 10
+
+// checkEgress runs before content is served on ownerID's behalf and returns the rate
+// to pace it to (0 = full speed). Writes the error response and returns false if the
+// owner is over their monthly egress and such traffic is blocked.
+func checkEgress(w http.ResponseWriter, r *http.Request, egress *service.EgressService, ownerID int64) (int64, bool) {
+	rate, err := egress.Allow(r.Context(), ownerID)
+	if writePlanError(w, err) {
+		logger.Warn(r.Context(), "Download blocked by egress limit", map[string]interface{}{"owner_id": ownerID})
+		return 0, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check download traffic"})
+		return 0, false
+	}
+	return rate, true
+}
+
+// meteredWriter counts the body bytes written through it and, with a rate, paces
+// them to that many bytes per second.
+type meteredWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	rate    int64
+	start   time.Time
+	written int64
+}
+
+func newMeteredWriter(ctx context.Context, w http.ResponseWriter, rate int64) *meteredWriter {
+	return &meteredWriter{ResponseWriter: w, ctx: ctx, rate: rate, start: time.Now()}
+}
+
+func (m *meteredWriter) Write(p []byte) (int, error) {
+	if m.rate <= 0 {
+		n, err := m.ResponseWriter.Write(p)
+		m.written += int64(n)
+		return n, err
+	}
+
+	total := 0
+	for len(p) > 0 {
+		n, err := m.ResponseWriter.Write(p[:min(len(p), egressChunk)])
+		total += n
+		m.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		// Wait until what has been sent fits the rate.
+		due := m.start.Add(time.Duration(float64(m.written) / float64(m.rate) * float64(time.Second)))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-m.ctx.Done():
+				timer.Stop()
+				return total, m.ctx.Err()
+			}
+		}
+	}
+	return total, nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (m *meteredWriter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+// recordEgress counts what mw wrote towards ownerID's month. It runs even if the
+// client went away mid-transfer, since those bytes were still sent.
+func recordEgress(ctx context.Context, egress *service.EgressService, ownerID int64, mw *meteredWriter) {
+	if err := egress.Record(context.WithoutCancel(ctx), ownerID, mw.written); err != nil {
+		logger.Warn(ctx, "Failed to record egress", map[string]interface{}{
+			"owner_id": ownerID, "bytes": mw.written, "error": err.Error(),
+		})
+	}
+}
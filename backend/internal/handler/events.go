@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/changes"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+const (
+	// eventBufferSize is how many changes may wait for a slow client before the
+	// stream falls back to a resync event.
+	eventBufferSize = 64
+
+	// eventKeepAlive keeps idle streams open through proxies that drop quiet connections.
+	eventKeepAlive = 25 * time.Second
+)
+
+// EventHandler streams changes to the caller's files and folders as server-sent events.
+type EventHandler struct {
+	hub *changes.Hub
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewEventHandler(hub *changes.Hub) *EventHandler {
+	return &EventHandler{hub: hub, closed: make(chan struct{})}
+}
+
+// Close ends all open streams. http.Server.Shutdown does not cancel running
+// handlers, so register this with RegisterOnShutdown.
+func (h *EventHandler) Close() {
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// Stream godoc
+// @Summary      Stream file and folder changes
+// @Description  Server-sent events for every change to the caller's files and folders, made through any API
+// @Description  replica. Each event is named "<table>.<op>" (e.g. files.update, folders.delete) and its data is
+// @Description  {"table","op","id","user_id"}; fetch the row to see what changed. A "resync" event means changes
+// @Description  may have been missed and the client should reload what it shows.
+// @Tags         events
+// @Produce      text/event-stream
+// @Success      200
+// @Failure      401 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /events [get]
+func (h *EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	// The stream outlives the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn(r.Context(), "Event stream cannot lift write deadline", map[string]interface{}{"error": err.Error()})
+	}
+
+	events := make(chan changes.Change, eventBufferSize)
+	var lost atomic.Bool
+	unsubscribe := h.hub.Subscribe(func(c changes.Change) {
+		if c.UserID != userID && c.Op != changes.OpResync {
+			return
+		}
+		select {
+		case events <- c:
+		default:
+			lost.Store(true)
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.closed:
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case c := <-events:
+			if lost.Swap(false) {
+				// The client fell behind and some changes were dropped.
+				c = changes.Change{Op: changes.OpResync}
+			}
+			err = writeEvent(w, c)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return // client went away
+		}
+	}
+}
+
+// writeEvent writes c as one server-sent event.
+func writeEvent(w http.ResponseWriter, c changes.Change) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	name := c.Op
+	if c.Table != "" {
+		name = c.Table + "." + c.Op
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// ExpiryRequest is the payload for PUT /files/{id}/expiry and PUT /folders/{id}/expiry.
+type ExpiryRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"` // RFC 3339, in the future; null = never expire
+}
+
+// readExpiry decodes an ExpiryRequest and rejects dates that are not in the future.
+// Writes the error response and returns false otherwise.
+func readExpiry(w http.ResponseWriter, r *http.Request) (*time.Time, bool) {
+	var req ExpiryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_at must be an RFC 3339 date or null"})
+		return nil, false
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_at must be in the future"})
+		return nil, false
+	}
+	return req.ExpiresAt, true
+}
+
+// SetFileExpiry godoc
+// @Summary      Schedule a file to expire
+// @Description  After expires_at the file is moved to the trash automatically. The owner gets a
+// @Description  notification ahead of time and another one once it has expired. null clears the expiry.
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int           true "File ID"
+// @Param        body body     ExpiryRequest true "Expiry date"
+// @Success      200  {object} respond.Envelope{data=model.File}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/expiry [put]
+func (h *UploadHandler) SetFileExpiry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+
+	expiresAt, ok := readExpiry(w, r)
+	if !ok {
+		return
+	}
+
+	file, err := h.fileRepo.SetExpiry(r.Context(), fileID, userID, expiresAt)
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to set expiry"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, file)
+}
+
+// SetFolderExpiry godoc
+// @Summary      Schedule a folder to expire
+// @Description  After expires_at the folder is deleted with everything in it (files go to the trash),
+// @Description  as by DELETE /folders/{id}. The owner is notified ahead of time. null clears the expiry.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int           true "Folder ID"
+// @Param        body body     ExpiryRequest true "Expiry date"
+// @Success      200  {object} respond.Envelope{data=model.Folder}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/expiry [put]
+func (h *FolderHandler) SetFolderExpiry(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	expiresAt, ok := readExpiry(w, r)
+	if !ok {
+		return
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID)
+	if err != nil || folder == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
+		return
+	}
+
+	folder, err = h.folderRepo.SetExpiry(r.Context(), folderID, userID, expiresAt)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to set expiry"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
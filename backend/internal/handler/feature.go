@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// FeatureHandler tells clients which features are on for the caller.
+type FeatureHandler struct {
+	features *service.FeatureService
+}
+
+func NewFeatureHandler(features *service.FeatureService) *FeatureHandler {
+	return &FeatureHandler{features: features}
+}
+
+// FeaturesResponse maps every feature flag to whether it is on.
+type FeaturesResponse struct {
+	Features map[string]bool `json:"features"`
+}
+
+// GetMyFeatures godoc
+// @Summary      List your features
+// @Description  Every feature flag and whether it is available to you, so clients can hide what is not.
+// @Description  A feature is off when the server disables it (FEATURES_DISABLED) or your plan does.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=FeaturesResponse}
+// @Security     BearerAuth
+// @Router       /auth/me/features [get]
+func (h *FeatureHandler) GetMyFeatures(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	features, err := h.features.ForUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch features"})
+		return
+	}
+	writeJSON(w, http.StatusOK, FeaturesResponse{Features: features})
+}
+
+// writeFeatureError answers a *service.FeatureError with 403 and returns true; other
+// errors are left to the caller.
+func writeFeatureError(w http.ResponseWriter, err error) bool {
+	var featureErr *service.FeatureError
+	if !errors.As(err, &featureErr) {
+		return false
+	}
+	writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "feature_disabled", Message: featureErr.Error()})
+	return true
+}
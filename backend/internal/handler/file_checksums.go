@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// ChecksumHandler exposes file checksums and verifies files against S3.
+type ChecksumHandler struct {
+	fileRepo     *repository.FileRepository
+	blockRepo    *repository.BlockRepository
+	checksumRepo *repository.ChecksumRepository
+	s3           *storage.S3Client
+	runner       *jobs.Runner
+}
+
+func NewChecksumHandler(fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository, checksumRepo *repository.ChecksumRepository,
+	s3 *storage.S3Client, runner *jobs.Runner) *ChecksumHandler {
+	return &ChecksumHandler{fileRepo: fileRepo, blockRepo: blockRepo, checksumRepo: checksumRepo, s3: s3, runner: runner}
+}
+
+// GetChecksums godoc
+// @Summary      Get a file's checksums
+// @Description  Returns the SHA-256 of the file's content and of each block, with the block's offset and size,
+// @Description  for documenting fixity. They are recorded at upload; for files uploaded in resumable chunks
+// @Description  (and files stored before checksums were kept) they are null until POST /files/{id}/verify
+// @Description  has run. verified_at is the last time the content was re-read from storage and matched.
+// @Tags         files
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      200 {object} respond.Envelope{data=model.FileChecksums}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/checksums [get]
+func (h *ChecksumHandler) GetChecksums(w http.ResponseWriter, r *http.Request) {
+	file, ok := h.ownFile(w, r)
+	if !ok {
+		return
+	}
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(file.UserID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	stored, err := h.checksumRepo.FindByFileID(r.Context(), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch checksums"})
+		return
+	}
+	// Checksums of earlier content (the file changed since) are not reported.
+	if stored != nil && (model.ContentETag(stored.ContentHash) != model.BlocksETag(blocks) || len(stored.BlockSHA256) != len(blocks)) {
+		stored = nil
+	}
+
+	resp := &model.FileChecksums{
+		FileID:    file.ID,
+		Size:      file.TotalSize,
+		Algorithm: "sha256",
+		Blocks:    make([]*model.BlockChecksum, len(blocks)),
+	}
+	if stored != nil {
+		resp.SHA256 = &stored.SHA256
+		resp.ComputedAt = &stored.ComputedAt
+		resp.VerifiedAt = stored.VerifiedAt
+	}
+	var offset int64
+	for i, b := range blocks {
+		bc := &model.BlockChecksum{Index: i, Offset: offset, Size: b.SizeBytes}
+		if stored != nil {
+			bc.SHA256 = &stored.BlockSHA256[i]
+		}
+		resp.Blocks[i] = bc
+		offset += b.SizeBytes
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// VerifyFile godoc
+// @Summary      Verify a file's integrity
+// @Description  Starts a background job that re-reads the file from storage, checks every block against its
+// @Description  recorded hash and the content against the recorded SHA-256, then records the checksums and
+// @Description  verified_at if it passed. Poll GET /jobs/{id}; the result is a model.FileVerification.
+// @Tags         files
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      202 {object} respond.Envelope{data=model.Job}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/verify [post]
+func (h *ChecksumHandler) VerifyFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := h.ownFile(w, r)
+	if !ok {
+		return
+	}
+	job, err := h.runner.Submit(r.Context(), file.UserID, jobs.KindFileVerify, jobs.FileVerifyPayload{FileID: file.ID},
+		jobs.VerifyFile(h.checksumRepo, h.blockRepo, h.s3, file))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start verification"})
+		return
+	}
+
+	logger.Info(r.Context(), "File verification requested", map[string]interface{}{
+		"user_id": file.UserID, "file_id": file.ID, "job_id": job.ID,
+	})
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// ownFile loads the caller's {id} file, writing the error response on failure.
+func (h *ChecksumHandler) ownFile(w http.ResponseWriter, r *http.Request) (*model.File, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return nil, false
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return nil, false
+	}
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return nil, false
+	}
+	return file, true
+}
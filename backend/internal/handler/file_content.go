@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// maxContentWriteBytes bounds one PATCH /files/{id}/content write. The write and the
+// blocks it touches are held in memory while they are stored.
+const maxContentWriteBytes = 64 << 20
+
+// ContentWriteResponse is the result of PATCH /files/{id}/content: the updated file,
+// the ETag of its new content, and which blocks the write replaced. Blocks
+// [first_block, first_block+blocks_replaced) of the old content became blocks_written
+// blocks, of which blocks_new were not stored before.
+type ContentWriteResponse struct {
+	File           *model.File `json:"file"`
+	ETag           string      `json:"etag"`
+	FirstBlock     int         `json:"first_block"`
+	BlocksReplaced int         `json:"blocks_replaced"`
+	BlocksWritten  int         `json:"blocks_written"`
+	BlocksNew      int         `json:"blocks_new"`
+	BytesUploaded  int64       `json:"bytes_uploaded"`
+}
+
+// WriteContent godoc
+// @Summary      Write part of a file's content
+// @Description  Writes the request body into the file at offset, replacing only the blocks it touches: sync
+// @Description  clients can push a small in-place edit of a huge file without uploading it again. The
+// @Description  touched blocks are rewritten as new blocks (blocks are shared between files and never change
+// @Description  in place) and the file gets a new ETag. Writing at the file's size appends; writing past it
+// @Description  is refused. Send the ETag the edit was based on as If-Match to get 412 instead of
+// @Description  overwriting a newer version. At most 64 MiB per request.
+// @Tags         files
+// @Accept       application/octet-stream
+// @Produce      json
+// @Param        id       path     int    true  "File ID"
+// @Param        offset   query    int    true  "Byte offset to write at"
+// @Param        If-Match header   string false "ETag the file must still have"
+// @Success      200 {object} respond.Envelope{data=ContentWriteResponse}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse "The file no longer matches If-Match, or changed during the write"
+// @Failure      413 {object} ErrorResponse "Write over 64 MiB, or the file would exceed UPLOAD_MAX_FILE_MB or the plan's max_file_bytes"
+// @Failure      416 {object} ErrorResponse "offset is past the end of the file"
+// @Failure      503 {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Failure      507 {object} ErrorResponse "Storage quota exceeded"
+// @Security     BearerAuth
+// @Router       /files/{id}/content [patch]
+func (h *UploadHandler) WriteContent(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "offset must be a non-negative integer"})
+		return
+	}
+	if r.ContentLength > maxContentWriteBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "write_too_large", Message: "a write may be at most 64 MiB"})
+		return
+	}
+
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+
+	release, ok := h.acquireSlot(w, r, userID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContentWriteBytes))
+	if err != nil {
+		var tooLargeErr *http.MaxBytesError
+		if errors.As(err, &tooLargeErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "write_too_large", Message: "a write may be at most 64 MiB"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "failed to read request body"})
+		return
+	}
+	if len(data) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "request body is empty"})
+		return
+	}
+	if maxBytes := h.policy.limit(0); maxBytes > 0 && offset+int64(len(data)) > maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxBytes))
+		return
+	}
+
+	req := service.WriteRequest{UserID: userID, FileID: file.ID, Offset: offset, Data: data, Client: requestClient(r)}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		req.IfMatch = func(etag string) bool { return etagMatches(ifMatch, etag) }
+	}
+	updated, res, err := h.files.WriteAt(r.Context(), req)
+	if writePlanError(w, err) {
+		return
+	}
+	var contentErr *service.ContentError
+	switch {
+	case errors.Is(err, service.ErrContentChanged):
+		writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "precondition_failed", Message: "the file has changed"})
+		return
+	case errors.Is(err, block.ErrWriteOutOfRange):
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(file.TotalSize, 10))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, ErrorResponse{Error: "range_not_satisfiable", Message: "offset is past the end of the file"})
+		return
+	case errors.As(err, &contentErr):
+		logger.ErrorLog(r.Context(), "File content write block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: err.Error()})
+		return
+	case err != nil:
+		writeRepoError(w, err, "file not found", "failed to write file content")
+		return
+	}
+
+	logger.Info(r.Context(), "File content written", map[string]interface{}{
+		"user_id": userID, "file_id": file.ID, "offset": offset, "bytes": len(data), "total_size": updated.TotalSize,
+		"first_block": res.From, "blocks_replaced": res.To - res.From, "blocks_written": res.Blocks,
+		"blocks_new": res.Stats.BlocksNew, "bytes_uploaded_to_s3": res.Stats.UploadedBytes,
+	})
+	if res.ETag != "" {
+		w.Header().Set("ETag", res.ETag)
+	}
+	writeJSON(w, http.StatusOK, ContentWriteResponse{
+		File:           updated,
+		ETag:           res.ETag,
+		FirstBlock:     res.From,
+		BlocksReplaced: res.To - res.From,
+		BlocksWritten:  res.Blocks,
+		BlocksNew:      res.Stats.BlocksNew,
+		BytesUploaded:  res.Stats.UploadedBytes,
+	})
+}
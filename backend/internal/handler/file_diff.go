@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// FileDiffHandler compares files by their blocks.
+type FileDiffHandler struct {
+	fileRepo  *repository.FileRepository
+	blockRepo *repository.BlockRepository
+}
+
+func NewFileDiffHandler(fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository) *FileDiffHandler {
+	return &FileDiffHandler{fileRepo: fileRepo, blockRepo: blockRepo}
+}
+
+// DiffFiles godoc
+// @Summary      Diff two files block by block
+// @Description  Compares the caller's files {id} and {otherId} (say v3 and v4 of a document) by block hash
+// @Description  without reading their content. changed_blocks are the indexes of otherId's blocks whose
+// @Description  content id has nowhere; changed_ranges are the byte ranges of otherId holding them, which a
+// @Description  client that has id can fetch with Range requests (If-Range: other_etag) to rebuild otherId,
+// @Description  copying the other blocks from id by their content hash (GET /files/{id}/checksums). Blocks
+// @Description  stored under either dedup scope match. Files cut into blocks differently share few blocks.
+// @Tags         files
+// @Produce      json
+// @Param        id      path     int true "File ID"
+// @Param        otherId path     int true "File ID to compare against"
+// @Success      200     {object} respond.Envelope{data=model.FileDiff}
+// @Failure      400     {object} ErrorResponse
+// @Failure      401     {object} ErrorResponse
+// @Failure      404     {object} ErrorResponse
+// @Failure      500     {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/diff/{otherId} [get]
+func (h *FileDiffHandler) DiffFiles(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	otherID, err := strconv.ParseInt(chi.URLParam(r, "otherId"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid other file id"})
+		return
+	}
+
+	blocks, ok := h.fileBlocks(w, r, fileID, userID)
+	if !ok {
+		return
+	}
+	other, ok := h.fileBlocks(w, r, otherID, userID)
+	if !ok {
+		return
+	}
+
+	diff := model.DiffBlocks(blocks, other, func(hash string) string { return block.UserKey(userID, hash) })
+	diff.FileID, diff.OtherID = fileID, otherID
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// fileBlocks loads the blocks of the caller's file fileID, writing the error
+// response on failure.
+func (h *FileDiffHandler) fileBlocks(w http.ResponseWriter, r *http.Request, fileID, userID int64) ([]*model.Block, bool) {
+	if _, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID); err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return nil, false
+	}
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), fileID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return nil, false
+	}
+	return blocks, true
+}
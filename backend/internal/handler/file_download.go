@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,41 +10,63 @@ import (
 	"github.com/naratel/naratel-box/backend/internal/auth"
 	"github.com/naratel/naratel-box/backend/internal/block"
 	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/security"
+	"github.com/naratel/naratel-box/backend/internal/service"
 	"github.com/naratel/naratel-box/backend/internal/storage"
 )
 
 type DownloadHandler struct {
 	fileRepo  *repository.FileRepository
 	blockRepo *repository.BlockRepository
+	statsRepo *repository.FileStatsRepository
 	s3        *storage.S3Client
+	preview   PreviewPolicy
+	egress    *service.EgressService
+	files     *service.FileService
 }
 
 func NewDownloadHandler(
 	fileRepo *repository.FileRepository,
 	blockRepo *repository.BlockRepository,
+	statsRepo *repository.FileStatsRepository,
 	s3 *storage.S3Client,
+	preview PreviewPolicy,
+	egress *service.EgressService,
+	files *service.FileService,
 ) *DownloadHandler {
 	return &DownloadHandler{
 		fileRepo:  fileRepo,
 		blockRepo: blockRepo,
+		statsRepo: statsRepo,
 		s3:        s3,
+		preview:   preview,
+		egress:    egress,
+		files:     files,
 	}
 }
 
 // Download godoc
 // @Summary      Download a file
 // @Description  Stream a file by ID. Returns 403 if the file does not belong to the authenticated user.
+// @Description  Supports HEAD, a single-range Range header (with If-Range) and If-None-Match against the ETag.
 // @Tags         files
 // @Produce      application/octet-stream
-// @Param        id  path     int true "File ID"
+// @Param        id    path     int    true  "File ID"
+// @Param        Range header   string false "Byte range, e.g. bytes=0-1023"
 // @Success      200 {file}   binary "File stream"
+// @Success      206 {file}   binary "Partial content"
+// @Success      304 "Not Modified"
 // @Failure      400 {object} ErrorResponse
 // @Failure      401 {object} ErrorResponse
 // @Failure      403 {object} ErrorResponse
+// @Failure      416 {object} ErrorResponse
+// @Failure      429 {object} ErrorResponse "monthly egress limit reached"
 // @Failure      500 {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /files/{id} [get]
+// @Router       /files/{id} [head]
 func (h *DownloadHandler) Download(w http.ResponseWriter, r *http.Request) {
 	userID, ok := auth.GetUserID(r)
 	if !ok {
@@ -65,26 +87,31 @@ func (h *DownloadHandler) Download(w http.ResponseWriter, r *http.Request) {
 
 	// ── AUTHORIZATION CHECK ──
 	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+		return
+	}
 	if err != nil {
 		logger.Warn(r.Context(), "Download forbidden - file not found or unauthorized", map[string]interface{}{
 			"user_id": userID, "file_id": fileID,
 		})
+		security.Record(r, model.SecurityPermissionDenied, userID, map[string]interface{}{"file_id": fileID})
 		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "you do not have access to this file"})
 		return
 	}
+	h.serveFile(w, r, userID, file)
+}
 
-	// Fetch ordered block IDs for this file
-	blockIDs, err := h.fileRepo.GetBlockIDs(r.Context(), file.ID)
-	if err != nil {
-		logger.ErrorLog(r.Context(), "Failed to fetch block IDs for download", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
-		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch block ids"})
+// serveFile streams the user's file as Download answers it: with its ETag, HEAD,
+// If-None-Match, a single Range and egress metering.
+func (h *DownloadHandler) serveFile(w http.ResponseWriter, r *http.Request, userID int64, file *model.File) {
+	rate, ok := checkEgress(w, r, h.egress, userID)
+	if !ok {
 		return
 	}
 
-	// Fetch block metadata (S3 keys)
-	blocks, err := h.blockRepo.FindByIDs(r.Context(), blockIDs)
+	// Fetch ordered block metadata (S3 keys), scoped to the caller
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), file.ID)
 	if err != nil {
 		logger.ErrorLog(r.Context(), "Failed to fetch block metadata for download", logger.ErrorDetails{
 			Code: "DB_ERR", Details: err.Error(),
@@ -93,24 +120,54 @@ func (h *DownloadHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set response headers before streaming
-	mimeType := file.MimeType
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	etag := model.BlocksETag(blocks)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rng, err := requestedRange(r, etag, file.TotalSize)
+	if err != nil {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(file.TotalSize, 10))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, ErrorResponse{Error: "range_not_satisfiable", Message: "requested range is outside the file"})
+		return
 	}
 
-	// Support preview mode (inline display for images, PDFs, text)
-	if r.URL.Query().Get("preview") == "true" {
-		w.Header().Set("Content-Type", mimeType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, file.Name))
-	} else {
-		w.Header().Set("Content-Type", mimeType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.Name))
+	// Set response headers before streaming. Preview mode displays allowlisted
+	// types inline; anything else falls back to an attachment.
+	preview := h.preview.SetContentHeaders(w, file, r.URL.Query().Get("preview") == "true")
+	cut := h.preview.Truncate(w, file, preview, rng)
+	status := http.StatusOK
+	switch {
+	case rng != nil:
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", rng.contentRange(file.TotalSize))
+		w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+	case cut != nil:
+		// A truncated preview streams only its first bytes, still as a 200.
+		w.Header().Set("Content-Length", strconv.FormatInt(cut.length, 10))
+		rng = cut
+	default:
+		w.Header().Set("Content-Length", strconv.FormatInt(file.TotalSize, 10))
+	}
+
+	w.WriteHeader(status)
+	// HEAD lets download managers and players probe size, type and ETag.
+	if r.Method == http.MethodHead {
+		return
 	}
-	w.Header().Set("Content-Length", strconv.FormatInt(file.TotalSize, 10))
 
 	// Stream blocks directly to response writer
-	if err := block.BlocksToStream(r.Context(), blocks, h.s3, w); err != nil {
+	mw := newMeteredWriter(r.Context(), w, rate)
+	if rng != nil {
+		err = block.BlocksRangeToStream(r.Context(), blocks, h.s3, mw, rng.start, rng.length)
+	} else {
+		err = block.BlocksToStream(r.Context(), blocks, h.s3, mw)
+	}
+	recordEgress(r.Context(), h.egress, userID, mw)
+	if err != nil {
 		logger.ErrorLog(r.Context(), "File download streaming failed", logger.ErrorDetails{
 			Code: "S3_STREAM_ERR", Details: err.Error(),
 		})
@@ -118,6 +175,19 @@ func (h *DownloadHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Count a download once, not once per resumed chunk.
+	if rng == nil || rng.start == 0 {
+		access := repository.AccessOwnerDownload
+		if preview {
+			access = repository.AccessOwnerPreview
+		}
+		if err := h.statsRepo.RecordAccess(r.Context(), file.ID, access); err != nil {
+			logger.Warn(r.Context(), "Failed to record file access", map[string]interface{}{
+				"file_id": file.ID, "error": err.Error(),
+			})
+		}
+	}
+
 	logger.Info(r.Context(), "File downloaded successfully", map[string]interface{}{
 		"user_id":    userID,
 		"file_id":    file.ID,
@@ -128,8 +198,9 @@ func (h *DownloadHandler) Download(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeleteFile godoc
-// @Summary      Delete a file
-// @Description  Delete a file by ID. Decrements block ref counts and removes orphaned blocks from S3.
+// @Summary      Move a file to the trash
+// @Description  Trashes a file by ID. It keeps its blocks until restored via /trash/{id}/restore or purged
+// @Description  (DELETE /trash/{id}, or automatically after the trash retention period).
 // @Tags         files
 // @Produce      json
 // @Param        id  path     int true "File ID"
@@ -158,56 +229,18 @@ func (h *DownloadHandler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		"user_id": userID, "file_id": fileID,
 	})
 
-	// Fetch block IDs before deleting the file (cascade would remove file_blocks)
-	blockIDs, err := h.fileRepo.GetBlockIDs(r.Context(), fileID)
-	if err != nil {
-		logger.ErrorLog(r.Context(), "Failed to fetch block IDs for deletion", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
-		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch block ids"})
-		return
-	}
-
-	// Delete file record (also cascades file_blocks)
-	if err := h.fileRepo.Delete(r.Context(), fileID, userID); err != nil {
-		logger.Warn(r.Context(), "File deletion failed - not found or unauthorized", map[string]interface{}{
-			"user_id": userID, "file_id": fileID, "error": err.Error(),
-		})
-		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "file not found or unauthorized"})
-		return
-	}
-
-	// Decrement ref_count for each block; delete from S3 + DB if orphaned
-	blocks, err := h.blockRepo.FindByIDs(r.Context(), blockIDs)
-	if err == nil {
-		for _, b := range blocks {
-			newCount, err := h.blockRepo.DecrementRefCount(r.Context(), b.ID)
-			if err != nil {
-				logger.ErrorLog(r.Context(), "Failed to decrement block ref count", logger.ErrorDetails{
-					Code: "BLOCK_DEREF_ERR", Details: fmt.Sprintf("block_id=%d: %s", b.ID, err.Error()),
-				})
-				continue
-			}
-			if newCount <= 0 {
-				if err := h.s3.DeleteObject(r.Context(), b.S3Key); err != nil {
-					logger.ErrorLog(r.Context(), "Failed to delete orphaned block from S3", logger.ErrorDetails{
-						Code: "S3_DELETE_ERR", Details: fmt.Sprintf("s3_key=%s: %s", b.S3Key, err.Error()),
-					})
-				}
-				if err := h.blockRepo.Delete(r.Context(), b.ID); err != nil {
-					logger.ErrorLog(r.Context(), "Failed to delete orphaned block from DB", logger.ErrorDetails{
-						Code: "DB_DELETE_ERR", Details: fmt.Sprintf("block_id=%d: %s", b.ID, err.Error()),
-					})
-				}
-				logger.Info(r.Context(), "Orphaned block garbage collected", map[string]interface{}{
-					"block_id": b.ID, "s3_key": b.S3Key,
-				})
-			}
+	// Block references are only released when the file is purged from the trash.
+	if err := h.files.Trash(r.Context(), userID, fileID, requestClient(r)); err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "file not found or unauthorized"})
+			return
 		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete file"})
+		return
 	}
 
-	logger.Info(r.Context(), "File deleted successfully", map[string]interface{}{
-		"user_id": userID, "file_id": fileID, "blocks_processed": len(blockIDs),
+	logger.Info(r.Context(), "File moved to trash", map[string]interface{}{
+		"user_id": userID, "file_id": fileID,
 	})
 	w.WriteHeader(http.StatusNoContent)
 }
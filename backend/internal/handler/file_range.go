@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// ReadRange godoc
+// @Summary      Read a byte range of a file
+// @Description  Returns exactly length bytes of the file from offset (fewer if the file ends first), fetching
+// @Description  only the blocks that hold them and only the needed part of the first and last: random access
+// @Description  for readers of parquet footers or zip central directories, without Range headers. The
+// @Description  X-Blocks header names the first and last block index read. Send the ETag of an earlier read as
+// @Description  If-Match to get 412 rather than bytes of different content if the file changed in between.
+// @Tags         files
+// @Produce      application/octet-stream
+// @Param        id       path     int    true  "File ID"
+// @Param        offset   query    int    true  "First byte"
+// @Param        length   query    int    true  "Number of bytes"
+// @Param        If-Match header   string false "ETag the file must still have"
+// @Success      200 {file}   binary "The requested bytes"
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse "The file no longer matches If-Match"
+// @Failure      416 {object} ErrorResponse "offset is at or past the end of the file"
+// @Failure      429 {object} ErrorResponse "monthly egress limit reached"
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/range [get]
+func (h *DownloadHandler) ReadRange(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "missing token"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "offset must be a non-negative integer"})
+		return
+	}
+	length, err := strconv.ParseInt(r.URL.Query().Get("length"), 10, 64)
+	if err != nil || length < 1 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "length must be a positive integer"})
+		return
+	}
+
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+	if offset >= file.TotalSize {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(file.TotalSize, 10))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, ErrorResponse{Error: "range_not_satisfiable", Message: "offset is outside the file"})
+		return
+	}
+	length = min(length, file.TotalSize-offset)
+
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	etag := model.BlocksETag(blocks)
+	w.Header().Set("ETag", etag)
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, etag) {
+		writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "precondition_failed", Message: "the file has changed"})
+		return
+	}
+
+	rate, ok := checkEgress(w, r, h.egress, userID)
+	if !ok {
+		return
+	}
+
+	first, last := blockSpan(blocks, offset, length)
+	rng := byteRange{start: offset, length: length}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Content-Range", rng.contentRange(file.TotalSize))
+	w.Header().Set("X-Blocks", strconv.Itoa(first)+"-"+strconv.Itoa(last))
+	w.WriteHeader(http.StatusOK)
+
+	mw := newMeteredWriter(r.Context(), w, rate)
+	err = block.BlocksRangeToStream(r.Context(), blocks, h.s3, mw, rng.start, rng.length)
+	recordEgress(r.Context(), h.egress, userID, mw)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "File range read streaming failed", logger.ErrorDetails{
+			Code: "S3_STREAM_ERR", Details: err.Error(),
+		})
+	}
+}
+
+// blockSpan returns the indexes of the first and last of blocks holding any of the
+// length bytes from offset.
+func blockSpan(blocks []*model.Block, offset, length int64) (first, last int) {
+	first, last = -1, -1
+	var pos int64
+	for i, b := range blocks {
+		if pos+b.SizeBytes > offset && pos < offset+length {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+		pos += b.SizeBytes
+	}
+	return first, last
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/tabular"
+)
+
+// Table preview limits: rows per request, how much of a CSV is scanned for them, and
+// the largest XLSX workbook read (it has to be held in memory to be unzipped).
+const (
+	defaultTableRows  = 100
+	maxTableRows      = 1000
+	maxTableScanBytes = 64 << 20
+	maxTableXLSXBytes = 32 << 20
+)
+
+// TablePreview godoc
+// @Summary      Preview a CSV or Excel file as a table
+// @Description  Parses the start of a CSV, TSV or XLSX file (the first worksheet) on the server and returns
+// @Description  the header row as columns with guessed types (integer, number, boolean, date, string or
+// @Description  empty) and up to rows data rows. truncated is set when the file has more rows. Dates in
+// @Description  XLSX files are returned as Excel serial numbers. Answers If-None-Match with 304.
+// @Tags         files
+// @Produce      json
+// @Param        id   path     int true  "File ID"
+// @Param        rows query    int false "Data rows (1-1000, default 100)"
+// @Success      200  {object} respond.Envelope{data=tabular.Table}
+// @Success      304  "Not Modified"
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      413  {object} ErrorResponse "XLSX workbook too large to preview"
+// @Failure      415  {object} ErrorResponse "Not a CSV, TSV or XLSX file"
+// @Failure      422  {object} ErrorResponse "The file could not be parsed"
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/table [get]
+func (h *DownloadHandler) TablePreview(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	rows := defaultTableRows
+	if v := r.URL.Query().Get("rows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTableRows {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("rows must be between 1 and %d", maxTableRows)})
+			return
+		}
+		rows = n
+	}
+
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+	format := tabular.Detect(file.MimeType, file.Name)
+	if format == "" {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "not_tabular", Message: "only CSV, TSV and XLSX files can be previewed as a table"})
+		return
+	}
+	if format == tabular.FormatXLSX && file.TotalSize > maxTableXLSXBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "file_too_large", Message: fmt.Sprintf("workbooks over %d bytes cannot be previewed", maxTableXLSXBytes),
+		})
+		return
+	}
+
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	etag := fmt.Sprintf(`"%s-r%d"`, strings.Trim(model.BlocksETag(blocks), `"`), rows)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var table *tabular.Table
+	if format == tabular.FormatXLSX {
+		var src bytes.Buffer
+		src.Grow(int(file.TotalSize))
+		if err := block.BlocksToStream(r.Context(), blocks, h.s3, &src); err != nil {
+			writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "storage_error", Message: "failed to read file"})
+			return
+		}
+		table, err = tabular.ReadXLSX(bytes.NewReader(src.Bytes()), int64(src.Len()), rows)
+	} else {
+		table, err = h.readDelimited(r.Context(), blocks, format, rows)
+	}
+	if errors.Is(err, tabular.ErrTooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "file_too_large", Message: "the workbook is too large to preview"})
+		return
+	}
+	if err != nil {
+		logger.Warn(r.Context(), "Table preview failed", map[string]interface{}{
+			"file_id": file.ID, "format": format, "error": err.Error(),
+		})
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "unreadable_table", Message: "the file could not be read as a table"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, table)
+}
+
+// readDelimited parses the start of a CSV or TSV file while it streams from S3 and
+// stops reading once enough rows have arrived. At most maxTableScanBytes are scanned;
+// a file that runs past that is reported as truncated.
+func (h *DownloadHandler) readDelimited(ctx context.Context, blocks []*model.Block, format tabular.Format, rows int) (*tabular.Table, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(block.BlocksToStream(ctx, blocks, h.s3, pw))
+	}()
+
+	src := &io.LimitedReader{R: pr, N: maxTableScanBytes}
+	table, err := tabular.ReadDelimited(src, format, rows)
+	if err != nil {
+		return nil, err
+	}
+	if src.N == 0 {
+		table.Truncated = true
+	}
+	return table, nil
+}
@@ -3,55 +3,145 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"mime"
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/naratel/naratel-box/backend/internal/auth"
 	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/i18n"
 	"github.com/naratel/naratel-box/backend/internal/logger"
 	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/organize"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
 )
 
-// UploadResponse is returned on a successful file upload.
+// UploadResponse is returned on a successful file upload. Of BlocksCount blocks,
+// BlocksNew were stored for the first time and BlocksDeduped already existed;
+// BytesUploaded is what was written to S3, against Size bytes of content.
 type UploadResponse struct {
-	FileID      int64  `json:"file_id"      example:"42"`
-	Name        string `json:"name"         example:"report.pdf"`
-	MimeType    string `json:"mime_type"    example:"application/pdf"`
-	Size        int64  `json:"size"         example:"8388608"`
-	BlocksCount int    `json:"blocks_count" example:"3"`
-	CreatedAt   string `json:"created_at"   example:"2026-02-18T12:00:00Z"`
+	FileID        int64  `json:"file_id"              example:"42"`
+	Name          string `json:"name"                 example:"report.pdf"`
+	MimeType      string `json:"mime_type"            example:"application/pdf"`
+	Size          int64  `json:"size"                 example:"8388608"`
+	BlocksCount   int    `json:"blocks_count"         example:"3"`
+	BlocksNew     int    `json:"blocks_new"           example:"1"`
+	BlocksDeduped int    `json:"blocks_deduped"       example:"2"`
+	BytesUploaded int64  `json:"bytes_uploaded_to_s3" example:"2097152"`
+	CreatedAt     string `json:"created_at"           example:"2026-02-18T12:00:00Z"`
+	FolderID      *int64 `json:"folder_id"            example:"12"`
+	RuleID        *int64 `json:"rule_id"              example:"3"` // upload rule that chose the folder; nil = none
+}
+
+// FileExistsResponse answers a conditional upload whose name is already taken, with
+// the file holding it (absent if it was deleted meanwhile).
+type FileExistsResponse struct {
+	Error     string      `json:"error"                example:"file_exists"`
+	Message   string      `json:"message"              example:"a file with this name already exists"`
+	File      *model.File `json:"file,omitempty"`
+	RequestID string      `json:"request_id,omitempty" example:"4f9c2a4e-1c1b-4d55-9e43-0c1f6a3e8b21"`
 }
 
 type UploadHandler struct {
+	files     *service.FileService
 	fileRepo  *repository.FileRepository
+	statsRepo *repository.FileStatsRepository
+	ruleRepo  *repository.UploadRuleRepository
 	processor *block.Processor
+	limiter   *block.Limiter
+	policy    UploadPolicy
+
+	sessionRepo *repository.UploadSessionRepository
+	sessionTTL  time.Duration // how long an upload session stays open for chunks
 }
 
-func NewUploadHandler(fileRepo *repository.FileRepository, processor *block.Processor) *UploadHandler {
+func NewUploadHandler(files *service.FileService, fileRepo *repository.FileRepository, statsRepo *repository.FileStatsRepository, ruleRepo *repository.UploadRuleRepository, processor *block.Processor, limiter *block.Limiter, policy UploadPolicy, sessionRepo *repository.UploadSessionRepository, sessionTTL time.Duration) *UploadHandler {
 	return &UploadHandler{
-		fileRepo:  fileRepo,
-		processor: processor,
+		files:       files,
+		fileRepo:    fileRepo,
+		statsRepo:   statsRepo,
+		ruleRepo:    ruleRepo,
+		processor:   processor,
+		limiter:     limiter,
+		policy:      policy,
+		sessionRepo: sessionRepo,
+		sessionTTL:  sessionTTL,
+	}
+}
+
+// requireOwnFolder checks that folderID (nil = root) exists and belongs to userID,
+// writing a 404 otherwise. Other users' folders are reported as missing so their
+// IDs cannot be probed.
+func (h *UploadHandler) requireOwnFolder(w http.ResponseWriter, r *http.Request, userID int64, folderID *int64) bool {
+	if folderID == nil {
+		return true
+	}
+	err := h.files.RequireFolder(r.Context(), userID, folderID)
+	if errors.Is(err, service.ErrFolderNotFound) {
+		logger.Warn(r.Context(), "Target folder not found or not owned", map[string]interface{}{
+			"user_id": userID, "folder_id": *folderID,
+		})
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "target folder not found"})
+		return false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to look up folder"})
+		return false
+	}
+	return true
+}
+
+// acquireSlot reserves an upload processing slot, writing a 503 with Retry-After when
+// the server is saturated. Nothing is written if the client went away while queued.
+func (h *UploadHandler) acquireSlot(w http.ResponseWriter, r *http.Request, userID int64) (func(), bool) {
+	release, err := h.limiter.Acquire(r.Context())
+	if err != nil {
+		if errors.Is(err, block.ErrSaturated) {
+			logger.Warn(r.Context(), "Upload rejected: server saturated", map[string]interface{}{"user_id": userID})
+			w.Header().Set("Retry-After", strconv.Itoa(int(h.limiter.RetryAfter().Seconds())))
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "server_busy",
+				Message: "too many uploads in progress, please retry shortly",
+			})
+		}
+		return nil, false
 	}
+	return release, true
+}
+
+// FileInfoResponse is a file's metadata together with its access statistics.
+type FileInfoResponse struct {
+	*model.File
+	Stats *model.FileStats `json:"stats"`
 }
 
 // Upload godoc
 // @Summary      Upload a file
-// @Description  Upload a file using multipart/form-data. Optionally specify folder_id form field.
+// @Description  Upload a file using multipart/form-data. Optionally specify folder_id form field; without
+// @Description  it, the first matching upload rule picks the folder (rule_id in the response), else the root.
 // @Tags         files
 // @Accept       mpfd
 // @Produce      json
 // @Param        file      formData file   true  "File to upload"
 // @Param        folder_id formData int    false "Target folder ID"
-// @Success      201  {object} UploadResponse
+// @Param        If-None-Match header string false "* to only create the file if the target folder has no file with its name"
+// @Success      201  {object} respond.Envelope{data=UploadResponse}
 // @Failure      400  {object} ErrorResponse
 // @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse "folder_id not found or not owned by the user"
+// @Failure      409  {object} FileExistsResponse "If-None-Match: * and the name is taken"
+// @Failure      413  {object} ErrorResponse "Larger than UPLOAD_MAX_FILE_MB or the plan's max_file_bytes"
+// @Failure      415  {object} ErrorResponse "File type not allowed by UPLOAD_ALLOWED_TYPES / UPLOAD_DENIED_TYPES"
 // @Failure      500  {object} ErrorResponse
+// @Failure      503  {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Failure      507  {object} ErrorResponse "Storage quota of the user or their plan exceeded"
 // @Security     BearerAuth
 // @Router       /files [post]
 func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
@@ -62,8 +152,34 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject oversized bodies early; 1 MB covers the multipart framing.
+	maxBytes := h.policy.limit(0)
+	if maxBytes > 0 && r.ContentLength > maxBytes+1<<20 {
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxBytes))
+		return
+	}
+	// Likewise refuse what cannot fit in the quota before spooling it; the body less
+	// the framing allowance is a lower bound of the file's size. Store checks the
+	// exact size before any block is processed.
+	if r.ContentLength > 1<<20 {
+		if err := h.files.CheckUpload(r.Context(), userID, r.ContentLength-1<<20); err != nil {
+			if !writePlanError(w, err) {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check plan limits"})
+			}
+			return
+		}
+	}
+
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	}
 	// 256MB in RAM; larger files spill to /tmp on disk to avoid OOMKill (pod limit: 512Mi)
 	if err := r.ParseMultipartForm(256 << 20); err != nil {
+		var tooLargeErr *http.MaxBytesError
+		if errors.As(err, &tooLargeErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxBytes))
+			return
+		}
 		logger.Warn(r.Context(), "Failed to parse multipart form", map[string]interface{}{
 			"user_id": userID, "error": err.Error(),
 		})
@@ -96,11 +212,45 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		}
 		folderID = &parsed
 	}
+	if !h.requireOwnFolder(w, r, userID, folderID) {
+		return
+	}
 
 	mimeType := mime.TypeByExtension(filepath.Ext(fileHeader.Filename))
 	if mimeType == "" {
 		mimeType = "application/octet-stream"
 	}
+	if status, resp := h.policy.check(fileHeader.Filename, mimeType, fileHeader.Size); status != 0 {
+		logger.Warn(r.Context(), "Upload refused by upload policy", map[string]interface{}{
+			"user_id": userID, "file_name": fileHeader.Filename, "mime_type": mimeType, "file_size": fileHeader.Size,
+		})
+		writeJSON(w, status, resp)
+		return
+	}
+
+	// Uploads without an explicit folder are filed by the user's upload rules.
+	var ruleID *int64
+	if folderID == nil {
+		rules, err := h.ruleRepo.ListByUser(r.Context(), userID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to load upload rules"})
+			return
+		}
+		if rule := organize.Match(rules, fileHeader.Filename, mimeType); rule != nil {
+			folderID, ruleID = &rule.TargetFolderID, &rule.ID
+			logger.Info(r.Context(), "Upload filed by rule", map[string]interface{}{
+				"user_id": userID, "rule_id": rule.ID, "folder_id": rule.TargetFolderID,
+			})
+		}
+	}
+
+	// Reserve a processing slot only once the body is spooled, so a slow client
+	// does not hold one while it trickles the upload in.
+	release, ok := h.acquireSlot(w, r, userID)
+	if !ok {
+		return
+	}
+	defer release()
 
 	logger.Info(r.Context(), "File upload started", map[string]interface{}{
 		"user_id":   userID,
@@ -117,8 +267,25 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	ctx = logger.WithMethod(ctx, logger.GetMethod(r.Context()))
 	ctx = logger.WithPath(ctx, logger.GetPath(r.Context()))
 
-	blockIDs, totalBytes, err := h.processor.Process(ctx, f)
-	if err != nil {
+	file, stats, err := h.files.Store(ctx, service.StoreRequest{
+		UserID: userID, Name: fileHeader.Filename, MimeType: mimeType, Size: fileHeader.Size,
+		FolderID: folderID, Client: requestClient(r), Content: f,
+		IfNoneExists: strings.TrimSpace(r.Header.Get("If-None-Match")) == "*",
+	})
+	if writePlanError(w, err) {
+		return
+	}
+	if errors.Is(err, repository.ErrFileExists) {
+		logger.Info(r.Context(), "Conditional upload skipped: name taken", map[string]interface{}{
+			"user_id": userID, "file_name": fileHeader.Filename, "folder_id": folderID,
+		})
+		msg, lang := i18n.Localize(w, "a file with this name already exists")
+		w.Header().Set("Content-Language", lang)
+		writeJSON(w, http.StatusConflict, FileExistsResponse{Error: "file_exists", Message: msg, File: file})
+		return
+	}
+	var contentErr *service.ContentError
+	if errors.As(err, &contentErr) {
 		logger.ErrorLog(r.Context(), "File upload block processing failed", logger.ErrorDetails{
 			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
 		})
@@ -128,8 +295,6 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-
-	file, err := h.fileRepo.Create(ctx, userID, fileHeader.Filename, mimeType, totalBytes, folderID)
 	if err != nil {
 		logger.ErrorLog(r.Context(), "Failed to save file metadata", logger.ErrorDetails{
 			Code: "DB_ERR", Details: err.Error(),
@@ -141,43 +306,43 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.fileRepo.LinkBlocks(ctx, file.ID, blockIDs); err != nil {
-		logger.ErrorLog(r.Context(), "Failed to link blocks to file", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
-		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{
-			Error:   "db_error",
-			Message: "failed to link blocks",
-		})
-		return
-	}
-
 	logger.Info(r.Context(), "File uploaded successfully", map[string]interface{}{
-		"user_id":     userID,
-		"file_id":     file.ID,
-		"file_name":   file.Name,
-		"total_size":  totalBytes,
-		"blocks_count": len(blockIDs),
+		"user_id":              userID,
+		"file_id":              file.ID,
+		"file_name":            file.Name,
+		"total_size":           file.TotalSize,
+		"block_size":           file.BlockSize,
+		"blocks_count":         stats.Blocks,
+		"blocks_new":           stats.BlocksNew,
+		"blocks_deduped":       stats.BlocksDeduped,
+		"logical_bytes":        stats.LogicalBytes,
+		"bytes_uploaded_to_s3": stats.UploadedBytes,
 	})
 
 	writeJSON(w, http.StatusCreated, UploadResponse{
-		FileID:      file.ID,
-		Name:        file.Name,
-		MimeType:    file.MimeType,
-		Size:        file.TotalSize,
-		BlocksCount: len(blockIDs),
-		CreatedAt:   file.CreatedAt.Format(time.RFC3339),
+		FileID:        file.ID,
+		Name:          file.Name,
+		MimeType:      file.MimeType,
+		Size:          file.TotalSize,
+		BlocksCount:   stats.Blocks,
+		BlocksNew:     stats.BlocksNew,
+		BlocksDeduped: stats.BlocksDeduped,
+		BytesUploaded: stats.UploadedBytes,
+		CreatedAt:     file.CreatedAt.Format(time.RFC3339),
+		FolderID:      file.FolderID,
+		RuleID:        ruleID,
 	})
 }
 
 // ListFiles godoc
 // @Summary      List files
 // @Description  Returns files in a folder (or root). Use ?folder_id=N or omit for root. Use ?search=term to search.
+// @Description  Each file carries its share state, etag, content_hash and block_count.
 // @Tags         files
 // @Produce      json
 // @Param        folder_id query int    false "Folder ID (omit for root)"
 // @Param        search    query string false "Search query"
-// @Success      200  {object} FolderContentsResponse
+// @Success      200  {object} respond.Envelope{data=FolderContentsResponse}
 // @Failure      401  {object} ErrorResponse
 // @Failure      500  {object} ErrorResponse
 // @Security     BearerAuth
@@ -203,7 +368,7 @@ func (h *UploadHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if files == nil {
-			files = []*model.File{}
+			files = []*model.FileEntry{}
 		}
 		writeJSON(w, http.StatusOK, FolderContentsResponse{
 			Files:   files,
@@ -232,7 +397,7 @@ func (h *UploadHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if files == nil {
-		files = []*model.File{}
+		files = []*model.FileEntry{}
 	}
 
 	writeJSON(w, http.StatusOK, files)
@@ -240,11 +405,11 @@ func (h *UploadHandler) ListFiles(w http.ResponseWriter, r *http.Request) {
 
 // FileInfo godoc
 // @Summary      Get file metadata
-// @Description  Returns metadata for a single file
+// @Description  Returns metadata and access statistics for a single file
 // @Tags         files
 // @Produce      json
 // @Param        id  path     int true "File ID"
-// @Success      200 {object} model.File
+// @Success      200 {object} respond.Envelope{data=FileInfoResponse}
 // @Failure      400 {object} ErrorResponse
 // @Failure      401 {object} ErrorResponse
 // @Failure      403 {object} ErrorResponse
@@ -264,27 +429,83 @@ func (h *UploadHandler) FileInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+		return
+	}
 	if err != nil {
 		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "file not found or unauthorized"})
 		return
 	}
 
-	writeJSON(w, http.StatusOK, file)
+	stats, err := h.statsRepo.FindByFileID(r.Context(), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file stats"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, FileInfoResponse{File: file, Stats: stats})
+}
+
+// FileStats godoc
+// @Summary      Get file access statistics
+// @Description  Returns download and preview counts for a file, split into owner and share-link access
+// @Tags         files
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      200 {object} respond.Envelope{data=model.FileStats}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/{id}/stats [get]
+func (h *UploadHandler) FileStats(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized", Message: "missing token"})
+		return
+	}
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+
+	if _, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID); err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+			return
+		}
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "file not found or unauthorized"})
+		return
+	}
+
+	stats, err := h.statsRepo.FindByFileID(r.Context(), fileID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file stats"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
 }
 
 // RenameRequest is the payload for PATCH /files/{id}/rename.
 type RenameRequest struct {
-	Name string `json:"name"`
+	Name      string `json:"name"`
+	Overwrite bool   `json:"overwrite,omitempty"` // trash a file already called name in the folder instead of keeping both
 }
 
 // RenameFile godoc
 // @Summary      Rename a file
+// @Description  With overwrite, another file already called name in the folder is moved to the trash in the
+// @Description  same step, so the name is never missing or doubled (save via temporary file, WebDAV Overwrite: T).
 // @Tags         files
 // @Accept       json
 // @Produce      json
 // @Param        id   path     int           true "File ID"
 // @Param        body body     RenameRequest true "New name"
-// @Success      200  {object} model.File
+// @Success      200  {object} respond.Envelope{data=model.File}
 // @Security     BearerAuth
 // @Router       /files/{id}/rename [patch]
 func (h *UploadHandler) RenameFile(w http.ResponseWriter, r *http.Request) {
@@ -306,28 +527,36 @@ func (h *UploadHandler) RenameFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, err := h.fileRepo.Rename(r.Context(), fileID, userID, req.Name)
+	file, replaced, err := h.files.Rename(r.Context(), userID, fileID, req.Name, requestClient(r), req.Overwrite)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
+		writeRepoError(w, err, "file not found", "failed to rename file")
 		return
 	}
+	if len(replaced) > 0 {
+		logger.Info(r.Context(), "Rename replaced existing files", map[string]interface{}{
+			"user_id": userID, "file_id": fileID, "trashed_file_ids": replaced,
+		})
+	}
 
 	writeJSON(w, http.StatusOK, file)
 }
 
 // MoveRequest is the payload for PATCH /files/{id}/move.
 type MoveRequest struct {
-	FolderID *int64 `json:"folder_id"` // null = move to root
+	FolderID  *int64 `json:"folder_id"`           // null = move to root
+	Overwrite bool   `json:"overwrite,omitempty"` // trash a file of the same name in the target folder instead of keeping both
 }
 
 // MoveFile godoc
 // @Summary      Move a file to a different folder
+// @Description  With overwrite, a file of the same name in the target folder is moved to the trash in the same step.
 // @Tags         files
 // @Accept       json
 // @Produce      json
 // @Param        id   path     int         true "File ID"
 // @Param        body body     MoveRequest true "Target folder"
-// @Success      200  {object} model.File
+// @Success      200  {object} respond.Envelope{data=model.File}
+// @Failure      404  {object} ErrorResponse "file or target folder not found"
 // @Security     BearerAuth
 // @Router       /files/{id}/move [patch]
 func (h *UploadHandler) MoveFile(w http.ResponseWriter, r *http.Request) {
@@ -348,18 +577,26 @@ func (h *UploadHandler) MoveFile(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
 		return
 	}
-
-	file, err := h.fileRepo.Move(r.Context(), fileID, userID, req.FolderID)
+	file, replaced, err := h.files.Move(r.Context(), userID, fileID, req.FolderID, requestClient(r), req.Overwrite)
+	if errors.Is(err, service.ErrFolderNotFound) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "target folder not found"})
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
+		writeRepoError(w, err, "file not found", "failed to move file")
 		return
 	}
+	if len(replaced) > 0 {
+		logger.Info(r.Context(), "Move replaced existing files", map[string]interface{}{
+			"user_id": userID, "file_id": fileID, "trashed_file_ids": replaced,
+		})
+	}
 
 	writeJSON(w, http.StatusOK, file)
 }
 
 // FolderContentsResponse wraps files and subfolders for a directory listing.
 type FolderContentsResponse struct {
-	Folders []*model.Folder `json:"folders"`
-	Files   []*model.File   `json:"files"`
+	Folders []*model.Folder    `json:"folders"`
+	Files   []*model.FileEntry `json:"files"`
 }
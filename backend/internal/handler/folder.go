@@ -2,26 +2,60 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
 	"github.com/naratel/naratel-box/backend/internal/logger"
 	"github.com/naratel/naratel-box/backend/internal/model"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"github.com/naratel/naratel-box/backend/internal/service"
 )
 
 type FolderHandler struct {
+	folders    *service.FolderService
 	folderRepo *repository.FolderRepository
 	fileRepo   *repository.FileRepository
+	runner     *jobs.Runner
 }
 
-func NewFolderHandler(folderRepo *repository.FolderRepository, fileRepo *repository.FileRepository) *FolderHandler {
+func NewFolderHandler(folders *service.FolderService, folderRepo *repository.FolderRepository, fileRepo *repository.FileRepository, runner *jobs.Runner) *FolderHandler {
 	return &FolderHandler{
+		folders:    folders,
 		folderRepo: folderRepo,
 		fileRepo:   fileRepo,
+		runner:     runner,
+	}
+}
+
+// writeFolderError answers a failed FolderService call: the placement checks map to
+// 404, 400 and 422, anything else goes through writeRepoError with notFound and failed.
+func writeFolderError(w http.ResponseWriter, err error, notFound, failed string) {
+	var limit *service.LimitError
+	switch {
+	case errors.Is(err, service.ErrFolderNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "parent folder not found"})
+	case errors.Is(err, service.ErrFolderCycle):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "cannot move folder into itself or its subfolders"})
+	case errors.As(err, &limit) && errors.Is(err, service.ErrDepthExceeded):
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Error: "folder_depth_exceeded", Message: fmt.Sprintf("folders cannot be nested more than %d levels deep", limit.Limit),
+		})
+	case errors.As(err, &limit) && errors.Is(err, service.ErrChildrenExceeded):
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Error: "folder_children_exceeded", Message: fmt.Sprintf("a folder cannot contain more than %d subfolders", limit.Limit),
+		})
+	default:
+		writeRepoError(w, err, notFound, failed)
 	}
 }
 
@@ -37,7 +71,9 @@ type CreateFolderRequest struct {
 // @Accept       json
 // @Produce      json
 // @Param        body body     CreateFolderRequest true "Folder details"
-// @Success      201  {object} model.Folder
+// @Success      201  {object} respond.Envelope{data=model.Folder}
+// @Failure      404  {object} ErrorResponse
+// @Failure      422  {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /folders [post]
 func (h *FolderHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
@@ -54,12 +90,9 @@ func (h *FolderHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	folder, err := h.folderRepo.Create(r.Context(), userID, req.ParentID, req.Name)
+	folder, err := h.folders.Create(r.Context(), userID, req.ParentID, req.Name)
 	if err != nil {
-		logger.ErrorLog(r.Context(), "Failed to create folder", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
-		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create folder"})
+		writeFolderError(w, err, "parent folder not found", "failed to create folder")
 		return
 	}
 
@@ -71,11 +104,19 @@ func (h *FolderHandler) CreateFolder(w http.ResponseWriter, r *http.Request) {
 
 // ListFolderContents godoc
 // @Summary      List folder contents
-// @Description  Returns subfolders and files within a folder. Omit folder_id for root.
+// @Description  Returns subfolders and files within a folder. Omit folder_id for root. Each subfolder
+// @Description  carries its direct item count and size; each file says whether it has an active share link
+// @Description  and carries its etag, content_hash and block_count so sync clients can skip unchanged files.
+// @Description  With limit, files are paged: pass next_cursor back as cursor for the following page, which
+// @Description  lists files only. Paging is keyset-based, so deep pages are as cheap as the first.
 // @Tags         folders
 // @Produce      json
-// @Param        folder_id query int false "Folder ID (omit for root)"
-// @Success      200  {object} FolderContentsResponse
+// @Param        folder_id query int    false "Folder ID (omit for root)"
+// @Param        sort      query string false "Order: name (default, case-insensitive), natural (file2 before file10) or created (files newest first)"
+// @Param        limit     query int    false "Files per page (1-1000); omit for all"
+// @Param        cursor    query string false "next_cursor from the previous page"
+// @Success      200  {object} respond.Envelope{data=model.FolderContents}
+// @Failure      400  {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /folders/contents [get]
 func (h *FolderHandler) ListFolderContents(w http.ResponseWriter, r *http.Request) {
@@ -95,30 +136,54 @@ func (h *FolderHandler) ListFolderContents(w http.ResponseWriter, r *http.Reques
 		folderID = &parsed
 	}
 
-	folders, err := h.folderRepo.ListByParent(r.Context(), userID, folderID)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list folders"})
+	opts := repository.ContentsOptions{Sort: repository.FileSortName}
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", string(repository.FileSortName):
+	case string(repository.FileSortNatural):
+		opts.Sort = repository.FileSortNatural
+	case string(repository.FileSortCreated):
+		opts.Sort = repository.FileSortCreated
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "sort must be name, natural or created"})
 		return
 	}
-	if folders == nil {
-		folders = []*model.Folder{}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxContentsPageSize {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "bad_request", Message: fmt.Sprintf("limit must be between 1 and %d", maxContentsPageSize),
+			})
+			return
+		}
+		opts.Limit = n
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		after, err := decodeFileCursor(opts.Sort, c)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid cursor"})
+			return
+		}
+		opts.After = after
 	}
 
-	files, err := h.fileRepo.ListByFolder(r.Context(), userID, folderID)
+	contents, next, err := h.folderRepo.Contents(r.Context(), userID, folderID, opts)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list files"})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list folder contents"})
 		return
 	}
-	if files == nil {
-		files = []*model.File{}
+	if next != nil {
+		contents.NextCursor = encodeFileCursor(opts.Sort, next)
 	}
 
-	writeJSON(w, http.StatusOK, FolderContentsResponse{
-		Folders: folders,
-		Files:   files,
+	writeJSON(w, http.StatusOK, respond.Page{
+		Items:      contents,
+		Pagination: respond.Pagination{Count: len(contents.Files), Limit: opts.Limit, NextCursor: contents.NextCursor},
 	})
 }
 
+// maxContentsPageSize bounds the limit parameter of ListFolderContents.
+const maxContentsPageSize = 1000
+
 // RenameFolderRequest is the payload for PATCH /folders/{id}/rename.
 type RenameFolderRequest struct {
 	Name string `json:"name"`
@@ -131,7 +196,7 @@ type RenameFolderRequest struct {
 // @Produce      json
 // @Param        id   path     int                  true "Folder ID"
 // @Param        body body     RenameFolderRequest   true "New name"
-// @Success      200  {object} model.Folder
+// @Success      200  {object} respond.Envelope{data=model.Folder}
 // @Security     BearerAuth
 // @Router       /folders/{id}/rename [patch]
 func (h *FolderHandler) RenameFolder(w http.ResponseWriter, r *http.Request) {
@@ -155,13 +220,105 @@ func (h *FolderHandler) RenameFolder(w http.ResponseWriter, r *http.Request) {
 
 	folder, err := h.folderRepo.Rename(r.Context(), folderID, userID, req.Name)
 	if err != nil {
+		writeRepoError(w, err, "folder not found", "failed to rename folder")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folder)
+}
+
+// UpdateFolderRequest is the payload for PATCH /folders/{id}. Omitted fields are left
+// unchanged; an empty string clears the field.
+type UpdateFolderRequest struct {
+	Color       *string `json:"color,omitempty"       example:"#3b82f6"`
+	Icon        *string `json:"icon,omitempty"        example:"briefcase"`
+	Description *string `json:"description,omitempty" example:"Quarterly reports"`
+}
+
+const maxFolderDescriptionLen = 500
+
+var (
+	folderColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	folderIconPattern  = regexp.MustCompile(`^[a-z0-9-]{1,32}$`)
+)
+
+// UpdateFolder godoc
+// @Summary      Update folder color, icon and description
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                 true "Folder ID"
+// @Param        body body     UpdateFolderRequest true "Fields to change"
+// @Success      200  {object} respond.Envelope{data=model.Folder}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id} [patch]
+func (h *FolderHandler) UpdateFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	var req UpdateFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if req.Color != nil && *req.Color != "" && !folderColorPattern.MatchString(*req.Color) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "color must be a hex value like #3b82f6"})
+		return
+	}
+	if req.Icon != nil && *req.Icon != "" && !folderIconPattern.MatchString(*req.Icon) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "icon must be up to 32 lowercase letters, digits or dashes"})
+		return
+	}
+	if req.Description != nil && utf8.RuneCountInString(*req.Description) > maxFolderDescriptionLen {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: "bad_request", Message: fmt.Sprintf("description must be at most %d characters", maxFolderDescriptionLen),
+		})
+		return
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID)
+	if err != nil || folder == nil {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
 		return
 	}
 
+	color := mergeOptional(folder.Color, req.Color)
+	if color != nil {
+		lower := strings.ToLower(*color)
+		color = &lower
+	}
+	folder, err = h.folderRepo.UpdateMetadata(r.Context(), folderID, userID,
+		color, mergeOptional(folder.Icon, req.Icon), mergeOptional(folder.Description, req.Description))
+	if err != nil {
+		writeRepoError(w, err, "folder not found", "failed to update folder")
+		return
+	}
+
 	writeJSON(w, http.StatusOK, folder)
 }
 
+// mergeOptional applies a PATCH field: nil keeps current, "" clears, anything else replaces.
+func mergeOptional(current, patch *string) *string {
+	if patch == nil {
+		return current
+	}
+	if *patch == "" {
+		return nil
+	}
+	return patch
+}
+
 // MoveFolderRequest is the payload for PATCH /folders/{id}/move.
 type MoveFolderRequest struct {
 	ParentID *int64 `json:"parent_id"` // null = move to root
@@ -174,7 +331,10 @@ type MoveFolderRequest struct {
 // @Produce      json
 // @Param        id   path     int              true "Folder ID"
 // @Param        body body     MoveFolderRequest true "New parent"
-// @Success      200  {object} model.Folder
+// @Success      200  {object} respond.Envelope{data=model.Folder}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      422  {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /folders/{id}/move [patch]
 func (h *FolderHandler) MoveFolder(w http.ResponseWriter, r *http.Request) {
@@ -196,15 +356,9 @@ func (h *FolderHandler) MoveFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Prevent moving a folder into itself
-	if req.ParentID != nil && *req.ParentID == folderID {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "cannot move folder into itself"})
-		return
-	}
-
-	folder, err := h.folderRepo.Move(r.Context(), folderID, userID, req.ParentID)
+	folder, err := h.folders.Move(r.Context(), userID, folderID, req.ParentID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
+		writeFolderError(w, err, "folder not found", "failed to move folder")
 		return
 	}
 
@@ -213,11 +367,13 @@ func (h *FolderHandler) MoveFolder(w http.ResponseWriter, r *http.Request) {
 
 // DeleteFolder godoc
 // @Summary      Delete a folder
-// @Description  Deletes a folder and all its contents recursively.
+// @Description  Starts a background job that moves every file under the folder to the trash and then deletes
+// @Description  the folder and its subfolders. Poll GET /jobs/{id} for progress.
 // @Tags         folders
 // @Produce      json
 // @Param        id path int true "Folder ID"
-// @Success      204
+// @Success      202 {object} respond.Envelope{data=model.Job}
+// @Failure      404 {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /folders/{id} [delete]
 func (h *FolderHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
@@ -233,18 +389,23 @@ func (h *FolderHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.folderRepo.Delete(r.Context(), folderID, userID); err != nil {
-		logger.Warn(r.Context(), "Folder deletion failed", map[string]interface{}{
-			"user_id": userID, "folder_id": folderID, "error": err.Error(),
-		})
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID)
+	if err != nil || folder == nil {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found or unauthorized"})
 		return
 	}
 
-	logger.Info(r.Context(), "Folder deleted successfully", map[string]interface{}{
-		"user_id": userID, "folder_id": folderID,
+	job, err := h.runner.Submit(r.Context(), userID, jobs.KindFolderDelete, jobs.FolderDeletePayload{FolderID: folderID},
+		jobs.DeleteFolderTree(h.folderRepo, h.fileRepo, folderID, userID, requestClient(r)))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start folder deletion"})
+		return
+	}
+
+	logger.Info(r.Context(), "Folder deletion started", map[string]interface{}{
+		"user_id": userID, "folder_id": folderID, "job_id": job.ID,
 	})
-	w.WriteHeader(http.StatusNoContent)
+	writeJSON(w, http.StatusAccepted, job)
 }
 
 // Breadcrumb godoc
@@ -252,7 +413,7 @@ func (h *FolderHandler) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 // @Tags         folders
 // @Produce      json
 // @Param        id path int true "Folder ID"
-// @Success      200  {array} model.Folder
+// @Success      200  {object} respond.Envelope{data=[]model.Folder}
 // @Security     BearerAuth
 // @Router       /folders/{id}/breadcrumb [get]
 func (h *FolderHandler) Breadcrumb(w http.ResponseWriter, r *http.Request) {
@@ -284,7 +445,7 @@ func (h *FolderHandler) Breadcrumb(w http.ResponseWriter, r *http.Request) {
 // @Summary      List all folders for move dialog
 // @Tags         folders
 // @Produce      json
-// @Success      200  {array} model.Folder
+// @Success      200  {object} respond.Envelope{data=[]model.Folder}
 // @Security     BearerAuth
 // @Router       /folders/all [get]
 func (h *FolderHandler) ListAllFolders(w http.ResponseWriter, r *http.Request) {
@@ -305,3 +466,124 @@ func (h *FolderHandler) ListAllFolders(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, folders)
 }
+
+// FolderShareSettingsRequest is the payload for PUT /folders/{id}/share-settings.
+// Omitted or null fields inherit from the parent folder.
+type FolderShareSettingsRequest struct {
+	DefaultExpiryHours *int  `json:"default_expiry_hours" example:"72"`
+	RequirePassword    *bool `json:"require_password"     example:"true"`
+	AllowPublicLinks   *bool `json:"allow_public_links"   example:"false"`
+}
+
+// FolderShareSettingsResponse returns the settings set on the folder and the inherited result.
+type FolderShareSettingsResponse struct {
+	*model.FolderShareSettings
+	Effective *model.EffectiveShareSettings `json:"effective"`
+}
+
+// GetShareSettings godoc
+// @Summary      Get a folder's default share settings
+// @Description  Returns the settings set directly on the folder and the effective settings after inheritance.
+// @Tags         folders
+// @Produce      json
+// @Param        id  path     int true "Folder ID"
+// @Success      200 {object} respond.Envelope{data=FolderShareSettingsResponse}
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/share-settings [get]
+func (h *FolderHandler) GetShareSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	h.writeShareSettings(w, r, folderID, userID)
+}
+
+// UpdateShareSettings godoc
+// @Summary      Set a folder's default share settings
+// @Description  Replaces the share defaults set on the folder. They apply to links created for any file
+// @Description  inside the folder or its subfolders unless a nearer folder overrides them.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                        true "Folder ID"
+// @Param        body body     FolderShareSettingsRequest true "Share defaults"
+// @Success      200  {object} respond.Envelope{data=FolderShareSettingsResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/share-settings [put]
+func (h *FolderHandler) UpdateShareSettings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	var req FolderShareSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if req.DefaultExpiryHours != nil && *req.DefaultExpiryHours <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "default_expiry_hours must be positive"})
+		return
+	}
+
+	if _, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
+		return
+	}
+
+	err = h.folderRepo.UpsertShareSettings(r.Context(), &model.FolderShareSettings{
+		FolderID:           folderID,
+		DefaultExpiryHours: req.DefaultExpiryHours,
+		RequirePassword:    req.RequirePassword,
+		AllowPublicLinks:   req.AllowPublicLinks,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save share settings"})
+		return
+	}
+
+	logger.Info(r.Context(), "Folder share settings updated", map[string]interface{}{
+		"user_id": userID, "folder_id": folderID,
+	})
+
+	h.writeShareSettings(w, r, folderID, userID)
+}
+
+// writeShareSettings responds with a folder's own and effective share settings.
+func (h *FolderHandler) writeShareSettings(w http.ResponseWriter, r *http.Request, folderID, userID int64) {
+	own, err := h.folderRepo.GetShareSettings(r.Context(), folderID, userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch share settings"})
+		return
+	}
+	if own == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
+		return
+	}
+
+	effective, err := h.folderRepo.EffectiveShareSettings(r.Context(), &folderID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to resolve share settings"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, FolderShareSettingsResponse{FolderShareSettings: own, Effective: effective})
+}
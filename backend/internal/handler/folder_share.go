@@ -0,0 +1,854 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+	"github.com/naratel/naratel-box/backend/internal/service"
+	"github.com/naratel/naratel-box/backend/internal/thumbnail"
+)
+
+// Folder share listing page sizes, and thumbnail limits: the default and largest edge
+// in pixels, and the largest source file decoded.
+const (
+	defaultFolderSharePageSize = 50
+	maxFolderSharePageSize     = 200
+	defaultThumbnailSize       = 320
+	maxThumbnailSize           = 1024
+	maxThumbnailSourceBytes    = 32 * 1024 * 1024
+	maxUploaderNameLen         = 100
+	maxFolderShareUploadsPage  = 200
+)
+
+// FolderShareLinkResponse is the owner's view of a folder share link. URL lists the
+// folder's files (or, in gallery view, its images and videos). With AllowUploads,
+// link holders may add files too, each at most UploadMaxBytes and UploadMaxFiles in
+// all; UploadCount is how many they have added.
+type FolderShareLinkResponse struct {
+	ID             int64      `json:"id"`
+	FolderID       int64      `json:"folder_id"`
+	Token          string     `json:"token"`
+	URL            string     `json:"url" example:"https://box.example.com/api/v1/share/folder/3f9a..."`
+	View           string     `json:"view" example:"gallery"`
+	Enabled        bool       `json:"enabled"`
+	Protected      bool       `json:"password_protected"`
+	Expired        bool       `json:"expired"`
+	AllowUploads   bool       `json:"allow_uploads"`
+	UploadMaxBytes *int64     `json:"upload_max_bytes,omitempty" example:"104857600"`
+	UploadMaxFiles *int       `json:"upload_max_files,omitempty" example:"20"`
+	UploadCount    int        `json:"upload_count"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func newFolderShareLinkResponse(l *model.FolderShareLink, baseURL string) FolderShareLinkResponse {
+	return FolderShareLinkResponse{
+		ID:        l.ID,
+		FolderID:  l.FolderID,
+		Token:     l.Token,
+		URL:       fmt.Sprintf("%s/api/v1/share/folder/%s", baseURL, l.Token),
+		View:      l.View,
+		Enabled:   l.Enabled,
+		Protected: l.PasswordHash != nil,
+		Expired:   l.ExpiresAt != nil && time.Now().After(*l.ExpiresAt),
+		ExpiresAt: l.ExpiresAt,
+		CreatedAt: l.CreatedAt,
+
+		AllowUploads:   l.AllowUploads,
+		UploadMaxBytes: l.UploadMaxBytes,
+		UploadMaxFiles: l.UploadMaxFiles,
+		UploadCount:    l.UploadCount,
+	}
+}
+
+// CreateFolderShareLinkRequest is the optional payload for POST /folders/{id}/share.
+type CreateFolderShareLinkRequest struct {
+	CreateShareLinkRequest
+	View           string `json:"view,omitempty"             example:"gallery"` // "list" (default) or "gallery"
+	AllowUploads   bool   `json:"allow_uploads,omitempty"    example:"true"`
+	UploadMaxBytes *int64 `json:"upload_max_bytes,omitempty" example:"104857600"` // per file; omit for the server limit
+	UploadMaxFiles *int   `json:"upload_max_files,omitempty" example:"20"`        // in all; omit for no cap
+}
+
+// UpdateFolderShareLinkRequest is the payload for PATCH /folder-share-links/{id}.
+// Omitted fields are left unchanged; 0 removes an upload cap.
+type UpdateFolderShareLinkRequest struct {
+	Enabled        *bool   `json:"enabled,omitempty"          example:"false"`
+	View           *string `json:"view,omitempty"             example:"list"`
+	AllowUploads   *bool   `json:"allow_uploads,omitempty"    example:"true"`
+	UploadMaxBytes *int64  `json:"upload_max_bytes,omitempty" example:"0"`
+	UploadMaxFiles *int    `json:"upload_max_files,omitempty" example:"50"`
+}
+
+// FolderShareItem is a file in a public folder listing. In gallery view Kind is
+// "image" or "video"; ThumbnailURL is set for images that can be scaled (JPEG, PNG,
+// GIF) and PosterURL for videos with a poster image. Password-protected links need
+// X-Share-Password on these URLs too, so clients fetch them rather than linking them
+// from <img>.
+type FolderShareItem struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	MimeType     string    `json:"mime_type"`
+	Size         int64     `json:"size"`
+	Kind         string    `json:"kind" example:"image"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	PosterURL    string    `json:"poster_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FolderShareListing is one page of a public folder share. Uploads is set when the
+// link accepts files.
+type FolderShareListing struct {
+	FolderName string                 `json:"folder_name"`
+	View       string                 `json:"view"`
+	Items      []FolderShareItem      `json:"items"`
+	NextCursor string                 `json:"next_cursor,omitempty"` // set when more items follow
+	Uploads    *FolderShareUploadInfo `json:"uploads,omitempty"`
+}
+
+// FolderShareUploadInfo tells link holders where and how much they may upload.
+type FolderShareUploadInfo struct {
+	URL            string `json:"url"`
+	MaxSizeBytes   int64  `json:"max_size_bytes"`
+	RemainingFiles *int   `json:"remaining_files,omitempty"` // nil = no cap
+}
+
+// CreateFolderShareLink godoc
+// @Summary      Create a share link for a folder
+// @Description  Shares the files directly inside the folder. view "gallery" lists only images and videos,
+// @Description  with thumbnail and poster URLs, as a browsable album. Password and expiry defaults come from
+// @Description  the folder's share settings like file links.
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                          true  "Folder ID"
+// @Param        body body     CreateFolderShareLinkRequest false "Link options"
+// @Success      201  {object} respond.Envelope{data=FolderShareLinkResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/share [post]
+func (h *ShareHandler) CreateFolderShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	var req CreateFolderShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_in_hours must be positive"})
+		return
+	}
+	if req.View == "" {
+		req.View = model.FolderViewList
+	}
+	if !validFolderView(req.View) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "view must be list or gallery"})
+		return
+	}
+	if (req.UploadMaxBytes != nil && *req.UploadMaxBytes <= 0) || (req.UploadMaxFiles != nil && *req.UploadMaxFiles <= 0) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "upload_max_bytes and upload_max_files must be positive"})
+		return
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch folder"})
+		return
+	}
+	if folder == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "folder not found"})
+		return
+	}
+
+	if req.AllowUploads && !h.checkShareUploads(w, r, userID) {
+		return
+	}
+	settings, ok := h.linkSettings(w, r, userID, &folder.ID, req.CreateShareLinkRequest)
+	if !ok {
+		return
+	}
+	link, err := h.shares.IssueFolder(r.Context(), folder, userID, &model.FolderShareLink{
+		View: req.View, AllowUploads: req.AllowUploads, UploadMaxBytes: req.UploadMaxBytes, UploadMaxFiles: req.UploadMaxFiles,
+	}, req.options(), settings)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to create folder share link", logger.ErrorDetails{
+			Code: "SHARE_CREATE_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to create share link"})
+		return
+	}
+
+	logger.Info(r.Context(), "Folder share link created", map[string]interface{}{
+		"user_id": userID, "folder_id": folder.ID, "link_id": link.ID, "view": link.View, "allow_uploads": link.AllowUploads, "expires_at": link.ExpiresAt,
+	})
+	writeJSON(w, http.StatusCreated, newFolderShareLinkResponse(link, h.baseURL(r)))
+}
+
+// GetFolderShareLinks godoc
+// @Summary      Get share links for a folder
+// @Tags         share
+// @Produce      json
+// @Param        id  path     int true "Folder ID"
+// @Success      200 {object}  respond.Envelope{data=[]FolderShareLinkResponse}
+// @Failure      400 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/share [get]
+func (h *ShareHandler) GetFolderShareLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	links, err := h.folderShareRepo.ListByFolder(r.Context(), folderID, userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch share links"})
+		return
+	}
+
+	responses := make([]FolderShareLinkResponse, 0, len(links))
+	for _, l := range links {
+		responses = append(responses, newFolderShareLinkResponse(l, h.baseURL(r)))
+	}
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// UpdateFolderShareLink godoc
+// @Summary      Change a folder share link
+// @Description  Enables or disables the link, switches its view, or changes whether and how much link
+// @Description  holders may upload.
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                          true "Folder share link ID"
+// @Param        body body     UpdateFolderShareLinkRequest true "Fields to change"
+// @Success      200  {object} respond.Envelope{data=FolderShareLinkResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folder-share-links/{id} [patch]
+func (h *ShareHandler) UpdateFolderShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid link id"})
+		return
+	}
+
+	var req UpdateFolderShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if req.View != nil && !validFolderView(*req.View) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "view must be list or gallery"})
+		return
+	}
+	if (req.UploadMaxBytes != nil && *req.UploadMaxBytes < 0) || (req.UploadMaxFiles != nil && *req.UploadMaxFiles < 0) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "upload_max_bytes and upload_max_files must not be negative"})
+		return
+	}
+	if req.AllowUploads != nil && *req.AllowUploads && !h.checkShareUploads(w, r, userID) {
+		return
+	}
+
+	link, err := h.folderShareRepo.Update(r.Context(), linkID, userID, repository.FolderShareLinkChanges{
+		Enabled: req.Enabled, View: req.View,
+		AllowUploads: req.AllowUploads, UploadMaxBytes: req.UploadMaxBytes, UploadMaxFiles: req.UploadMaxFiles,
+	})
+	if err != nil {
+		writeRepoError(w, err, "share link not found", "failed to update share link")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newFolderShareLinkResponse(link, h.baseURL(r)))
+}
+
+// DeleteFolderShareLink godoc
+// @Summary      Delete a folder share link
+// @Tags         share
+// @Param        id  path     int true "Folder share link ID"
+// @Success      204
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folder-share-links/{id} [delete]
+func (h *ShareHandler) DeleteFolderShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid link id"})
+		return
+	}
+
+	if err := h.folderShareRepo.Delete(r.Context(), linkID, userID); err != nil {
+		writeRepoError(w, err, "share link not found", "failed to delete share link")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSharedFolder godoc
+// @Summary      List a shared folder (public)
+// @Description  Lists the files of a folder share link, paged. In gallery view only images and videos are
+// @Description  listed, with thumbnail URLs for images and poster URLs for videos that have an image of the
+// @Description  same base name (clip.jpg for clip.mp4; such posters are not listed separately).
+// @Tags         share
+// @Produce      json
+// @Param        token            path   string true  "Folder share token"
+// @Param        sort             query  string false "Order: name (default), natural or created (newest first)"
+// @Param        limit            query  int    false "Items per page (1-200, default 50)"
+// @Param        cursor           query  string false "next_cursor from the previous page"
+// @Param        X-Share-Password header string false "Link password"
+// @Success      200 {object} respond.Envelope{data=FolderShareListing}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      410 {object} ErrorResponse
+// @Router       /share/folder/{token} [get]
+func (h *ShareHandler) ListSharedFolder(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.openFolderLink(w, r)
+	if !ok {
+		return
+	}
+
+	opts := repository.ContentsOptions{Sort: repository.FileSortName, Limit: defaultFolderSharePageSize}
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", string(repository.FileSortName):
+	case string(repository.FileSortNatural):
+		opts.Sort = repository.FileSortNatural
+	case string(repository.FileSortCreated):
+		opts.Sort = repository.FileSortCreated
+	default:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "sort must be name, natural or created"})
+		return
+	}
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxFolderSharePageSize {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "bad_request", Message: fmt.Sprintf("limit must be between 1 and %d", maxFolderSharePageSize),
+			})
+			return
+		}
+		opts.Limit = n
+	}
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		after, err := decodeFileCursor(opts.Sort, c)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid cursor"})
+			return
+		}
+		opts.After = after
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), link.FolderID, link.UserID)
+	if err != nil || folder == nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch folder"})
+		return
+	}
+
+	gallery := link.View == model.FolderViewGallery
+	files, next, err := h.fileRepo.ListShared(r.Context(), link.UserID, link.FolderID, opts, gallery)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list shared folder"})
+		return
+	}
+
+	base := fmt.Sprintf("%s/api/v1/share/folder/%s/files/", h.baseURL(r), link.Token)
+	listing := FolderShareListing{FolderName: folder.Name, View: link.View, Items: make([]FolderShareItem, 0, len(files))}
+	for _, f := range files {
+		item := FolderShareItem{
+			ID: f.ID, Name: f.Name, MimeType: f.MimeType, Size: f.TotalSize, Kind: "file", CreatedAt: f.CreatedAt,
+			URL: base + strconv.FormatInt(f.ID, 10),
+		}
+		switch {
+		case strings.HasPrefix(f.MimeType, "image/"):
+			item.Kind = "image"
+			if thumbnail.Supported(f.MimeType) {
+				item.ThumbnailURL = item.URL + "/thumbnail"
+			}
+		case strings.HasPrefix(f.MimeType, "video/"):
+			item.Kind = "video"
+			if f.PosterID != nil {
+				item.PosterURL = base + strconv.FormatInt(*f.PosterID, 10) + "/thumbnail"
+			}
+		}
+		listing.Items = append(listing.Items, item)
+	}
+	if next != nil {
+		listing.NextCursor = encodeFileCursor(opts.Sort, next)
+	}
+	if link.AllowUploads {
+		listing.Uploads = &FolderShareUploadInfo{
+			URL:          fmt.Sprintf("%s/api/v1/share/folder/%s/files", h.baseURL(r), link.Token),
+			MaxSizeBytes: h.shareUploadLimit(link),
+		}
+		if link.UploadMaxFiles != nil {
+			remaining := max(*link.UploadMaxFiles-link.UploadCount, 0)
+			listing.Uploads.RemainingFiles = &remaining
+		}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{
+		Items:      listing,
+		Pagination: respond.Pagination{Count: len(listing.Items), Limit: opts.Limit, NextCursor: listing.NextCursor},
+	})
+}
+
+// DownloadSharedFolderFile godoc
+// @Summary      Download a file from a shared folder (public)
+// @Description  Same as /share/{token} (HEAD, Range, If-None-Match, ?preview=true) for a file directly inside
+// @Description  the shared folder. Gallery links only serve images and videos.
+// @Tags         share
+// @Produce      application/octet-stream
+// @Param        token            path   string true  "Folder share token"
+// @Param        id               path   int    true  "File ID"
+// @Param        X-Share-Password header string false "Link password"
+// @Param        Range            header string false "Byte range, e.g. bytes=1048576-"
+// @Success      200 {file} binary
+// @Success      206 {file} binary "Partial Content"
+// @Success      304 "Not Modified"
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      410 {object} ErrorResponse
+// @Failure      429 {object} ErrorResponse "the owner's monthly egress limit reached"
+// @Failure      451 {object} ErrorResponse
+// @Router       /share/folder/{token}/files/{id} [get]
+// @Router       /share/folder/{token}/files/{id} [head]
+func (h *ShareHandler) DownloadSharedFolderFile(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.openFolderLink(w, r)
+	if !ok {
+		return
+	}
+	file, ok := h.sharedFolderFile(w, r, link)
+	if !ok {
+		return
+	}
+
+	if h.serveSharedFile(w, r, file, link.UserID) {
+		logger.Info(r.Context(), "Shared folder file downloaded", map[string]interface{}{
+			"link_id": link.ID, "file_id": file.ID, "total_size": file.TotalSize,
+		})
+	}
+}
+
+// SharedFolderThumbnail godoc
+// @Summary      Thumbnail of an image in a shared folder (public)
+// @Description  Returns a JPEG of a JPEG, PNG or GIF image in the shared folder, scaled so its longer side is
+// @Description  at most size pixels. Answers If-None-Match with 304.
+// @Tags         share
+// @Produce      image/jpeg
+// @Param        token path  string true  "Folder share token"
+// @Param        id    path  int    true  "File ID"
+// @Param        size  query int    false "Longest side in pixels (16-1024, default 320)"
+// @Success      200 {file} binary
+// @Success      304 "Not Modified"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      415 {object} ErrorResponse
+// @Router       /share/folder/{token}/files/{id}/thumbnail [get]
+func (h *ShareHandler) SharedFolderThumbnail(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.openFolderLink(w, r)
+	if !ok {
+		return
+	}
+
+	size := defaultThumbnailSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 16 || n > maxThumbnailSize {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("size must be between 16 and %d", maxThumbnailSize)})
+			return
+		}
+		size = n
+	}
+
+	file, ok := h.sharedFolderFile(w, r, link)
+	if !ok {
+		return
+	}
+	if !thumbnail.Supported(file.MimeType) || file.TotalSize > maxThumbnailSourceBytes {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "no_thumbnail", Message: "no thumbnail is available for this file"})
+		return
+	}
+	if status, resp := h.scanGate.check(file); status != 0 {
+		writeJSON(w, status, resp)
+		return
+	}
+
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(link.UserID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	etag := fmt.Sprintf(`"%s-t%d"`, strings.Trim(model.BlocksETag(blocks), `"`), size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var src bytes.Buffer
+	src.Grow(int(file.TotalSize))
+	if err := block.BlocksToStream(r.Context(), blocks, h.s3, &src); err != nil {
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "storage_error", Message: "failed to read image"})
+		return
+	}
+	thumb, err := thumbnail.Generate(src.Bytes(), size)
+	if err != nil {
+		logger.Warn(r.Context(), "Thumbnail generation failed", map[string]interface{}{
+			"file_id": file.ID, "error": err.Error(),
+		})
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "no_thumbnail", Message: "no thumbnail is available for this file"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(thumb)
+}
+
+// UploadToSharedFolder godoc
+// @Summary      Upload a file into a shared folder (public)
+// @Description  For folder share links that accept uploads. The file is stored in the shared folder if it
+// @Description  fits the link's size limit and file cap (gallery links take only images and videos). The
+// @Description  optional name field attributes the upload; the owner gets a notification and an entry in
+// @Description  the folder's share-upload activity.
+// @Tags         share
+// @Accept       mpfd
+// @Produce      json
+// @Param        token            path     string true  "Folder share token"
+// @Param        file             formData file   true  "File to upload"
+// @Param        name             formData string false "Uploader's name, shown to the owner"
+// @Param        X-Share-Password header   string false "Link password"
+// @Success      201 {object} respond.Envelope{data=DropReceipt}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse "uploads not allowed, or the link's file cap is reached"
+// @Failure      404 {object} ErrorResponse
+// @Failure      410 {object} ErrorResponse
+// @Failure      413 {object} ErrorResponse "file exceeds the size limit"
+// @Failure      415 {object} ErrorResponse "file type not accepted"
+// @Failure      503 {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Router       /share/folder/{token}/files [post]
+func (h *ShareHandler) UploadToSharedFolder(w http.ResponseWriter, r *http.Request) {
+	link, ok := h.openFolderLink(w, r)
+	if !ok {
+		return
+	}
+	if !link.AllowUploads {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "uploads_disabled", Message: "this share link does not accept uploads"})
+		return
+	}
+	// The owner's plan may have lost the feature since the link was made.
+	if err := h.shares.CheckShareUploads(r.Context(), link.UserID); err != nil {
+		if errors.Is(err, service.ErrFeatureDisabled) {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "uploads_disabled", Message: "this share link does not accept uploads"})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check share link"})
+		}
+		return
+	}
+	maxSize := h.shareUploadLimit(link)
+
+	// Reject oversized bodies early; 1 MB covers the multipart framing.
+	if r.ContentLength > maxSize+1<<20 {
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxSize))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLargeErr *http.MaxBytesError
+		if errors.As(err, &tooLargeErr) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxSize))
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "failed to parse multipart form"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var uploader *string
+	if name := strings.TrimSpace(r.FormValue("name")); name != "" {
+		if !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxUploaderNameLen {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "bad_request", Message: fmt.Sprintf("name must be at most %d characters", maxUploaderNameLen),
+			})
+			return
+		}
+		uploader = &name
+	}
+
+	f, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "field 'file' is required"})
+		return
+	}
+	defer f.Close()
+
+	if fileHeader.Size > maxSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxSize))
+		return
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(fileHeader.Filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if link.View == model.FolderViewGallery && !strings.HasPrefix(mimeType, "image/") && !strings.HasPrefix(mimeType, "video/") {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "type_not_accepted", Message: "this gallery accepts only images and videos"})
+		return
+	}
+	if status, resp := h.uploadPolicy.check(fileHeader.Filename, mimeType, fileHeader.Size); status != 0 {
+		writeJSON(w, status, resp)
+		return
+	}
+
+	// Take a processing slot only once the body is spooled, so a slow client does
+	// not hold one while it trickles the upload in.
+	release, err := h.limiter.Acquire(r.Context())
+	if err != nil {
+		if errors.Is(err, block.ErrSaturated) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(h.limiter.RetryAfter().Seconds())))
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "server_busy", Message: "too many uploads in progress, please retry shortly"})
+		}
+		return
+	}
+	defer release()
+
+	// Claim a slot first so concurrent uploads cannot overshoot the link's file cap.
+	reserved, err := h.folderShareRepo.ReserveUpload(r.Context(), link.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check upload limit"})
+		return
+	}
+	if !reserved {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "upload_limit_reached", Message: "this share link accepts no more files"})
+		return
+	}
+
+	folderID := link.FolderID
+	file, _, err := h.files.Store(r.Context(), service.StoreRequest{
+		UserID: link.UserID, Name: fileHeader.Filename, MimeType: mimeType, Size: fileHeader.Size,
+		FolderID: &folderID, Client: requestClient(r), Content: f,
+	})
+	if err != nil {
+		_ = h.folderShareRepo.ReleaseUpload(r.Context(), link.ID)
+		if writePlanError(w, err) {
+			return
+		}
+		var contentErr *service.ContentError
+		if errors.As(err, &contentErr) {
+			logger.ErrorLog(r.Context(), "Shared folder upload block processing failed", logger.ErrorDetails{
+				Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+			})
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: "failed to store file"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save file metadata"})
+		return
+	}
+
+	// The file is stored; bookkeeping failures are logged by the repositories only.
+	clientIP := proxy.ClientIP(r)
+	linkID, fileID := link.ID, file.ID
+	_ = h.folderShareRepo.RecordUpload(r.Context(), link.UserID, &model.FolderShareUpload{
+		LinkID: &linkID, FolderID: link.FolderID, FileID: &fileID, UploaderName: uploader,
+		FileName: file.Name, Size: file.TotalSize, ClientIP: &clientIP,
+	})
+	notice := model.ShareUploadNotice{LinkID: link.ID, FolderID: link.FolderID, FileID: file.ID, Name: file.Name, Size: file.TotalSize}
+	if uploader != nil {
+		notice.UploaderName = *uploader
+	}
+	_, _ = h.notifRepo.Create(r.Context(), link.UserID, model.NotificationShareUpload, notice)
+
+	logger.Info(r.Context(), "File received through folder share link", map[string]interface{}{
+		"link_id": link.ID, "user_id": link.UserID, "file_id": file.ID, "size": file.TotalSize, "client_ip": clientIP,
+	})
+	writeJSON(w, http.StatusCreated, DropReceipt{Name: file.Name, Size: file.TotalSize, ReceivedAt: file.CreatedAt})
+}
+
+// GetFolderShareUploads godoc
+// @Summary      List files uploaded into a folder through its share links
+// @Description  The folder's share-upload activity, newest first: file, size, uploader name (if given),
+// @Description  client IP and link. Entries stay after the link or file is deleted.
+// @Tags         share
+// @Produce      json
+// @Param        id    path     int true  "Folder ID"
+// @Param        limit query    int false "Entries to return (1-200, default 50)"
+// @Success      200   {object}  respond.Envelope{data=[]model.FolderShareUpload}
+// @Failure      400   {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /folders/{id}/share-uploads [get]
+func (h *ShareHandler) GetFolderShareUploads(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+	limit := defaultFolderSharePageSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxFolderShareUploadsPage {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "bad_request", Message: fmt.Sprintf("limit must be between 1 and %d", maxFolderShareUploadsPage),
+			})
+			return
+		}
+		limit = n
+	}
+
+	uploads, err := h.folderShareRepo.ListUploads(r.Context(), folderID, userID, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list share uploads"})
+		return
+	}
+	writeJSON(w, http.StatusOK, respond.Page{Items: uploads, Pagination: respond.Pagination{Count: len(uploads), Limit: limit}})
+}
+
+// openFolderLink resolves the {token} folder share link and checks that it may be
+// used: owner active, enabled, unexpired and the password (if any) given. Writes the
+// error response and returns false otherwise.
+func (h *ShareHandler) openFolderLink(w http.ResponseWriter, r *http.Request) (*model.FolderShareLink, bool) {
+	token := chi.URLParam(r, "token")
+
+	link, err := h.folderShareRepo.FindByToken(r.Context(), token)
+	if err != nil || link == nil {
+		logger.Warn(r.Context(), "Folder share link not found", map[string]interface{}{"token": token, "client_ip": proxy.ClientIP(r)})
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "share link not found"})
+		return nil, false
+	}
+	if link.OwnerDeactivated {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_unavailable", Message: "this link is no longer available"})
+		return nil, false
+	}
+	if !link.Enabled {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_disabled", Message: "share link has been disabled by its owner"})
+		return nil, false
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		writeJSON(w, http.StatusGone, ErrorResponse{Error: "expired", Message: "share link has expired"})
+		return nil, false
+	}
+	if !checkSharePassword(w, r, link.PasswordHash, map[string]interface{}{
+		"folder_link_id": link.ID, "folder_id": link.FolderID, "owner_id": link.UserID,
+	}) {
+		return nil, false
+	}
+	return link, true
+}
+
+// sharedFolderFile loads the {id} file and checks it lies directly inside the link's
+// folder (and, for gallery links, is an image or video). Writes the error response
+// and returns false otherwise.
+func (h *ShareHandler) sharedFolderFile(w http.ResponseWriter, r *http.Request, link *model.FolderShareLink) (*model.File, bool) {
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return nil, false
+	}
+
+	// Public share: the link owner's scope stands in for the (absent) user.
+	file, err := h.fileRepo.FindByID(r.Context(), repository.ForUser(link.UserID), fileID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+		return nil, false
+	}
+	media := file != nil && (strings.HasPrefix(file.MimeType, "image/") || strings.HasPrefix(file.MimeType, "video/"))
+	if file == nil || file.FolderID == nil || *file.FolderID != link.FolderID ||
+		(link.View == model.FolderViewGallery && !media) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found in this share"})
+		return nil, false
+	}
+	if file.TakenDownAt != nil {
+		writeJSON(w, http.StatusUnavailableForLegalReasons, ErrorResponse{Error: "taken_down", Message: "this content was taken down following a report"})
+		return nil, false
+	}
+	return file, true
+}
+
+// checkShareUploads checks that userID may let folder share links accept uploads.
+// Writes the error response and returns false otherwise.
+func (h *ShareHandler) checkShareUploads(w http.ResponseWriter, r *http.Request, userID int64) bool {
+	err := h.shares.CheckShareUploads(r.Context(), userID)
+	if writeFeatureError(w, err) {
+		return false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check features"})
+		return false
+	}
+	return true
+}
+
+// shareUploadLimit is the effective per-file upload limit of a folder share link.
+func (h *ShareHandler) shareUploadLimit(link *model.FolderShareLink) int64 {
+	if link.UploadMaxBytes != nil && *link.UploadMaxBytes < h.maxUpload {
+		return h.uploadPolicy.limit(*link.UploadMaxBytes)
+	}
+	return h.uploadPolicy.limit(h.maxUpload)
+}
+
+func validFolderView(view string) bool {
+	return view == model.FolderViewList || view == model.FolderViewGallery
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// InviteHandler lets users provisioned by an admin import choose their password.
+type InviteHandler struct {
+	inviteRepo *repository.UserInviteRepository
+}
+
+func NewInviteHandler(inviteRepo *repository.UserInviteRepository) *InviteHandler {
+	return &InviteHandler{inviteRepo: inviteRepo}
+}
+
+// AcceptInviteRequest is the payload for POST /auth/invite/{token}.
+type AcceptInviteRequest struct {
+	Password string `json:"password" example:"secret123"`
+}
+
+// AcceptInvite godoc
+// @Summary      Accept an invite
+// @Description  Sets the password of an account created by an admin import, using the token from its invite link.
+// @Description  Each link works once and until it expires; afterwards sign in with POST /auth/login.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        token path     string              true "Invite token"
+// @Param        body  body     AcceptInviteRequest true "New password"
+// @Success      200   {object} respond.Envelope{data=UserResponse}
+// @Failure      400   {object} ErrorResponse
+// @Failure      404   {object} ErrorResponse
+// @Router       /auth/invite/{token} [post]
+func (h *InviteHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	var req AcceptInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if len(req.Password) < 8 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "password must be at least 8 characters"})
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Failed to hash password", logger.ErrorDetails{
+			Code: "BCRYPT_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to hash password"})
+		return
+	}
+
+	user, err := h.inviteRepo.Accept(r.Context(), hashInviteToken(chi.URLParam(r, "token")), string(hashed))
+	if err != nil {
+		if errors.Is(err, repository.ErrInviteNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "invite link is invalid, used or expired"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to accept invite"})
+		return
+	}
+
+	logger.Info(r.Context(), "Invite accepted", map[string]interface{}{"user_id": user.ID})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Job listing page sizes.
+const (
+	defaultJobLimit = 50
+	maxJobLimit     = 200
+)
+
+// JobHandler lets users poll the background jobs they started.
+type JobHandler struct {
+	jobRepo *repository.JobRepository
+}
+
+func NewJobHandler(jobRepo *repository.JobRepository) *JobHandler {
+	return &JobHandler{jobRepo: jobRepo}
+}
+
+// GetJob godoc
+// @Summary      Get one of your background jobs
+// @Description  Returns status, progress and (once completed) the result of a job started by the current user.
+// @Tags         jobs
+// @Produce      json
+// @Param        id path int true "Job ID"
+// @Success      200 {object} respond.Envelope{data=model.Job}
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /jobs/{id} [get]
+func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid job id"})
+		return
+	}
+
+	job, err := h.jobRepo.FindByID(r.Context(), repository.ForUser(userID), jobID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch job"})
+		return
+	}
+	if job == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "job not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// ListJobs godoc
+// @Summary      List your background jobs
+// @Description  Exports, download bundles, folder deletions and other long-running operations started by the
+// @Description  current user, newest first, with status, progress and error details. Page with before_id set to
+// @Description  the last ID of the previous page.
+// @Tags         jobs
+// @Produce      json
+// @Param        kind      query    string false "Only jobs of this kind, e.g. folder_delete or download_bundle"
+// @Param        status    query    string false "pending, running, completed or failed"
+// @Param        before_id query    int    false "Only jobs with a smaller ID"
+// @Param        limit     query    int    false "Max jobs (default 50, max 200)"
+// @Success      200       {object}  respond.Envelope{data=[]model.Job}
+// @Failure      400       {object} ErrorResponse
+// @Failure      401       {object} ErrorResponse
+// @Failure      500       {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /jobs [get]
+func (h *JobHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	q := r.URL.Query()
+	f := model.JobFilter{
+		Kind:   q.Get("kind"),
+		Status: q.Get("status"),
+		Limit:  defaultJobLimit,
+	}
+	if f.Status != "" && !slices.Contains(model.JobStatuses, f.Status) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "unknown job status"})
+		return
+	}
+
+	var err error
+	if v := q.Get("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || f.BeforeID < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid before_id"})
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxJobLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "limit must be between 1 and 200"})
+			return
+		}
+		f.Limit = n
+	}
+
+	list, err := h.jobRepo.ListByUser(r.Context(), userID, f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list jobs"})
+		return
+	}
+	if list == nil {
+		list = []*model.Job{}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{Items: list, Pagination: respond.Pagination{Count: len(list), Limit: f.Limit}})
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/mount"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// MountHandler lets admins surface external storage read-only in a user's tree, and
+// lets that user browse and download it. Mount content is streamed straight from its
+// source; nothing is uploaded, split into blocks or counted against the user's usage.
+type MountHandler struct {
+	mountRepo *repository.MountRepository
+	reader    *mount.Reader
+	preview   PreviewPolicy
+	egress    *service.EgressService
+}
+
+func NewMountHandler(mountRepo *repository.MountRepository, reader *mount.Reader, preview PreviewPolicy, egress *service.EgressService) *MountHandler {
+	return &MountHandler{mountRepo: mountRepo, reader: reader, preview: preview, egress: egress}
+}
+
+// CreateMountRequest is the payload for POST /admin/mounts.
+type CreateMountRequest struct {
+	UserID   int64  `json:"user_id"             example:"7"`
+	ParentID *int64 `json:"parent_id,omitempty" example:"12"`
+	Name     string `json:"name"                example:"NAS share"`
+	Kind     string `json:"kind"                example:"s3"`
+	Location string `json:"location"            example:"nas/marketing"`
+}
+
+// MountListing is one directory of a mount.
+type MountListing struct {
+	Mount   *model.Mount       `json:"mount"`
+	Path    string             `json:"path"`
+	Entries []model.MountEntry `json:"entries"`
+}
+
+// CreateMount godoc
+// @Summary      Mount external storage
+// @Description  Surfaces an S3 prefix in the configured bucket (kind "s3") or a server directory under
+// @Description  MOUNT_LOCAL_ROOTS (kind "local") as a read-only folder in the user's tree, at parent_id
+// @Description  (omit for root). Its content is never imported into blocks.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreateMountRequest true "Mount"
+// @Success      201  {object} respond.Envelope{data=model.Mount}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/mounts [post]
+func (h *MountHandler) CreateMount(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+
+	var req CreateMountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if req.UserID < 1 || name == "" || len(name) > 255 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "user_id and a name of 1-255 characters are required"})
+		return
+	}
+	location, err := h.reader.NormalizeLocation(req.Kind, req.Location)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid_location", Message: err.Error()})
+		return
+	}
+
+	created, err := h.mountRepo.Create(r.Context(), &model.Mount{
+		UserID: req.UserID, ParentID: req.ParentID, Name: name, Kind: req.Kind, Location: location, CreatedBy: &adminID,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrFolderNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "parent folder not found for that user"})
+			return
+		}
+		writeRepoError(w, err, "user not found", "failed to create mount")
+		return
+	}
+
+	audit.Describe(r.Context(), "mount", strconv.FormatInt(created.ID, 10), nil, created)
+	logger.Info(r.Context(), "Mount created", map[string]interface{}{
+		"admin_id": adminID, "mount_id": created.ID, "user_id": created.UserID, "kind": created.Kind, "location": created.Location,
+	})
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// ListMounts godoc
+// @Summary      List mounts
+// @Description  Returns every mount, or only those of user_id, including their locations.
+// @Tags         admin
+// @Produce      json
+// @Param        user_id query    int false "Only this user's mounts"
+// @Success      200     {object}  respond.Envelope{data=[]model.Mount}
+// @Failure      400     {object} ErrorResponse
+// @Failure      403     {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/mounts [get]
+func (h *MountHandler) ListMounts(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid user_id"})
+			return
+		}
+		userID = parsed
+	}
+
+	mounts, err := h.mountRepo.List(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list mounts"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, mounts)
+}
+
+// DeleteMount godoc
+// @Summary      Remove a mount
+// @Description  Removes the mount from the user's tree. The external content is left untouched.
+// @Tags         admin
+// @Param        id  path     int true "Mount ID"
+// @Success      204
+// @Failure      400 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/mounts/{id} [delete]
+func (h *MountHandler) DeleteMount(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+
+	mountID, ok := parseMountID(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.mountRepo.FindByID(r.Context(), repository.Unscoped("admin"), mountID)
+	if err != nil {
+		writeRepoError(w, err, "mount not found", "failed to fetch mount")
+		return
+	}
+	if err := h.mountRepo.Delete(r.Context(), mountID); err != nil {
+		writeRepoError(w, err, "mount not found", "failed to delete mount")
+		return
+	}
+	audit.Describe(r.Context(), "mount", strconv.FormatInt(mountID, 10), before, nil)
+
+	logger.Info(r.Context(), "Mount removed", map[string]interface{}{"admin_id": adminID, "mount_id": mountID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMountEntries godoc
+// @Summary      Browse a mount
+// @Description  Lists one directory of a mounted folder, directories first. Mounts are read-only.
+// @Tags         mounts
+// @Produce      json
+// @Param        id   path     int    true  "Mount ID"
+// @Param        path query    string false "Directory inside the mount (omit for its root)"
+// @Success      200  {object} respond.Envelope{data=MountListing}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      502  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /mounts/{id}/entries [get]
+func (h *MountHandler) ListMountEntries(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.findMount(w, r)
+	if !ok {
+		return
+	}
+	dir := strings.TrimPrefix(path.Clean("/"+r.URL.Query().Get("path")), "/")
+
+	entries, err := h.reader.List(r.Context(), m, dir)
+	if err != nil {
+		h.writeReadError(w, r, m, err)
+		return
+	}
+	if entries == nil {
+		entries = []model.MountEntry{}
+	}
+
+	m.Location = ""
+	writeJSON(w, http.StatusOK, MountListing{Mount: m, Path: dir, Entries: entries})
+}
+
+// DownloadMountFile godoc
+// @Summary      Download a file from a mount
+// @Description  Streams a file straight from the mount's storage. Supports HEAD, a single-range Range header
+// @Description  (with If-Range), If-None-Match and ?preview=true like /files/{id}; the ETag follows size and modification time.
+// @Tags         mounts
+// @Produce      application/octet-stream
+// @Param        id    path     int    true  "Mount ID"
+// @Param        path  query    string true  "File inside the mount"
+// @Param        Range header   string false "Byte range, e.g. bytes=0-1023"
+// @Success      200 {file}   binary "File stream"
+// @Success      206 {file}   binary "Partial content"
+// @Success      304 "Not Modified"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      416 {object} ErrorResponse
+// @Failure      429 {object} ErrorResponse "monthly egress limit reached"
+// @Security     BearerAuth
+// @Router       /mounts/{id}/content [get]
+// @Router       /mounts/{id}/content [head]
+func (h *MountHandler) DownloadMountFile(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.findMount(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := h.reader.Stat(r.Context(), m, r.URL.Query().Get("path"))
+	if err != nil {
+		h.writeReadError(w, r, m, err)
+		return
+	}
+	rate, ok := checkEgress(w, r, h.egress, m.UserID)
+	if !ok {
+		return
+	}
+
+	etag := fmt.Sprintf(`"m%x-%x"`, entry.Size, entry.ModifiedAt.UnixNano())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rng, err := requestedRange(r, etag, entry.Size)
+	if err != nil {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(entry.Size, 10))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, ErrorResponse{Error: "range_not_satisfiable", Message: "requested range is outside the file"})
+		return
+	}
+	file := &model.File{Name: entry.Name, MimeType: mime.TypeByExtension(path.Ext(entry.Name)), TotalSize: entry.Size}
+	preview := h.preview.SetContentHeaders(w, file, r.URL.Query().Get("preview") == "true")
+
+	start, length := int64(0), entry.Size
+	if rng != nil {
+		start, length = rng.start, rng.length
+	} else if cut := h.preview.Truncate(w, file, preview, rng); cut != nil {
+		length = cut.length
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	if r.Method == http.MethodHead {
+		if rng != nil {
+			w.Header().Set("Content-Range", rng.contentRange(entry.Size))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		return
+	}
+
+	body, err := h.reader.Open(r.Context(), m, entry.Path, start, length)
+	if err != nil {
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Disposition")
+		h.writeReadError(w, r, m, err)
+		return
+	}
+	defer body.Close()
+
+	status := http.StatusOK
+	if rng != nil {
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", rng.contentRange(entry.Size))
+	}
+	w.WriteHeader(status)
+	mw := newMeteredWriter(r.Context(), w, rate)
+	_, err = io.Copy(mw, body)
+	recordEgress(r.Context(), h.egress, m.UserID, mw)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Mount download streaming failed", logger.ErrorDetails{
+			Code: "MOUNT_STREAM_ERR", Details: err.Error(),
+		})
+		return
+	}
+
+	logger.Info(r.Context(), "Mount file downloaded", map[string]interface{}{
+		"user_id": m.UserID, "mount_id": m.ID, "path": entry.Path, "bytes": length,
+	})
+}
+
+// findMount loads the {id} mount of the caller. Writes the error response and
+// returns false if there is none.
+func (h *MountHandler) findMount(w http.ResponseWriter, r *http.Request) (*model.Mount, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return nil, false
+	}
+	mountID, ok := parseMountID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	m, err := h.mountRepo.FindByID(r.Context(), repository.ForUser(userID), mountID)
+	if err != nil {
+		writeRepoError(w, err, "mount not found", "failed to fetch mount")
+		return nil, false
+	}
+	return m, true
+}
+
+// writeReadError answers a failed read of a mount's content.
+func (h *MountHandler) writeReadError(w http.ResponseWriter, r *http.Request, m *model.Mount, err error) {
+	switch {
+	case errors.Is(err, mount.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "path not found in mount"})
+	case errors.Is(err, mount.ErrIsDir):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "path is a directory"})
+	default:
+		logger.ErrorLog(r.Context(), "Mount read failed", logger.ErrorDetails{
+			Code: "MOUNT_READ_ERR", Details: fmt.Sprintf("mount_id=%d: %s", m.ID, err.Error()),
+		})
+		writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "mount_unavailable", Message: "the mounted storage could not be read"})
+	}
+}
+
+// parseMountID reads the {id} URL parameter. Writes the error response and returns false if it is invalid.
+func parseMountID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	mountID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || mountID < 1 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid mount id"})
+		return 0, false
+	}
+	return mountID, true
+}
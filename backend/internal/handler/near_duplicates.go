@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Near-duplicate report defaults and limits.
+const (
+	defaultNearDuplicateShared  = 80
+	defaultNearDuplicateMinSize = 1 << 20
+	defaultNearDuplicateLimit   = 50
+	maxNearDuplicateLimit       = 200
+)
+
+// NearDuplicateHandler reports files that share most of their blocks.
+type NearDuplicateHandler struct {
+	fileRepo *repository.FileRepository
+}
+
+func NewNearDuplicateHandler(fileRepo *repository.FileRepository) *NearDuplicateHandler {
+	return &NearDuplicateHandler{fileRepo: fileRepo}
+}
+
+// ListNearDuplicates godoc
+// @Summary      List near-duplicate files
+// @Description  Pairs of the caller's files that share at least min_shared percent of their blocks (counted
+// @Description  against whichever file has more), largest shared size first: almost-identical copies that
+// @Description  deduplication cannot fully collapse, where deleting one frees the blocks only it holds. Files
+// @Description  in the trash and under min_size bytes are left out, as are blocks found in over 100 files.
+// @Tags         files
+// @Produce      json
+// @Param        min_shared query    int false "Minimum shared percentage (1-100, default 80)"
+// @Param        min_size   query    int false "Minimum file size in bytes (default 1048576)"
+// @Param        limit      query    int false "Max pairs (1-200, default 50)"
+// @Success      200        {object}  respond.Envelope{data=[]model.NearDuplicate}
+// @Failure      400        {object} ErrorResponse
+// @Failure      401        {object} ErrorResponse
+// @Failure      500        {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /files/near-duplicates [get]
+func (h *NearDuplicateHandler) ListNearDuplicates(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	h.list(w, r, repository.ForUser(userID))
+}
+
+// AdminListNearDuplicates godoc
+// @Summary      List near-duplicate files of all users
+// @Description  Same as GET /files/near-duplicates across every user (pairs are always of one user's files),
+// @Description  or for user_id only, to find where storage could be reclaimed.
+// @Tags         admin
+// @Produce      json
+// @Param        user_id    query    int false "Only this user's files"
+// @Param        min_shared query    int false "Minimum shared percentage (1-100, default 80)"
+// @Param        min_size   query    int false "Minimum file size in bytes (default 1048576)"
+// @Param        limit      query    int false "Max pairs (1-200, default 50)"
+// @Success      200        {object}  respond.Envelope{data=[]model.NearDuplicate}
+// @Failure      400        {object} ErrorResponse
+// @Failure      403        {object} ErrorResponse
+// @Failure      500        {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/near-duplicates [get]
+func (h *NearDuplicateHandler) AdminListNearDuplicates(w http.ResponseWriter, r *http.Request) {
+	scope := repository.Unscoped("admin near-duplicate report")
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid user_id"})
+			return
+		}
+		scope = repository.ForUser(id)
+	}
+	h.list(w, r, scope)
+}
+
+func (h *NearDuplicateHandler) list(w http.ResponseWriter, r *http.Request, scope repository.Scope) {
+	f, msg := parseNearDuplicateFilter(r.URL.Query())
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: msg})
+		return
+	}
+	pairs, err := h.fileRepo.FindNearDuplicates(r.Context(), scope, f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to find near-duplicates"})
+		return
+	}
+	writeJSON(w, http.StatusOK, respond.Page{Items: pairs, Pagination: respond.Pagination{Count: len(pairs), Limit: f.Limit}})
+}
+
+// parseNearDuplicateFilter reads min_shared, min_size and limit, returning a message
+// for the first invalid one.
+func parseNearDuplicateFilter(q url.Values) (model.NearDuplicateFilter, string) {
+	f := model.NearDuplicateFilter{
+		MinSharedPercent: defaultNearDuplicateShared,
+		MinSize:          defaultNearDuplicateMinSize,
+		Limit:            defaultNearDuplicateLimit,
+	}
+	if v := q.Get("min_shared"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return f, "min_shared must be between 1 and 100"
+		}
+		f.MinSharedPercent = n
+	}
+	if v := q.Get("min_size"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return f, "invalid min_size"
+		}
+		f.MinSize = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxNearDuplicateLimit {
+			return f, "limit must be between 1 and 200"
+		}
+		f.Limit = n
+	}
+	return f, ""
+}
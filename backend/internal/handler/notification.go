@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Notification list sizes: the default and the most ?limit= may ask for.
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// NotificationHandler serves a user's in-app notifications.
+type NotificationHandler struct {
+	notifRepo *repository.NotificationRepository
+}
+
+func NewNotificationHandler(notifRepo *repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{notifRepo: notifRepo}
+}
+
+// MarkAllReadResponse reports how many notifications were marked read.
+type MarkAllReadResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+// ListNotifications godoc
+// @Summary      List notifications
+// @Description  Returns the user's most recent notifications, newest first.
+// @Tags         notifications
+// @Produce      json
+// @Param        unread query    bool false "Only unread notifications"
+// @Param        limit  query    int  false "Max notifications (default 50, max 200)"
+// @Success      200    {object}  respond.Envelope{data=[]model.Notification}
+// @Failure      400    {object} ErrorResponse
+// @Failure      500    {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /notifications [get]
+func (h *NotificationHandler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	limit := defaultNotificationLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "limit must be between 1 and 200"})
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := h.notifRepo.ListByUser(r.Context(), userID, unreadOnly, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list notifications"})
+		return
+	}
+	if notifications == nil {
+		notifications = []*model.Notification{}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{Items: notifications, Pagination: respond.Pagination{Count: len(notifications), Limit: limit}})
+}
+
+// MarkNotificationRead godoc
+// @Summary      Mark a notification as read
+// @Tags         notifications
+// @Param        id  path int true "Notification ID"
+// @Success      204
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /notifications/{id}/read [post]
+func (h *NotificationHandler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	notificationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid notification id"})
+		return
+	}
+
+	if err := h.notifRepo.MarkRead(r.Context(), notificationID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotificationNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "notification not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to update notification"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary      Mark all notifications as read
+// @Tags         notifications
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=MarkAllReadResponse}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /notifications/read-all [post]
+func (h *NotificationHandler) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	n, err := h.notifRepo.MarkAllRead(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to update notifications"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MarkAllReadResponse{Marked: n})
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/pdfrender"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// PDF page resolution limits, in dots per inch.
+const (
+	defaultPageDPI = 96
+	minPageDPI     = 36
+	maxPageDPI     = 300
+)
+
+// pageRenderWait bounds how long a request waits for a render slot and the render.
+const pageRenderWait = time.Minute
+
+// PDFPageHandler renders pages of the caller's PDFs to images.
+type PDFPageHandler struct {
+	fileRepo  *repository.FileRepository
+	blockRepo *repository.BlockRepository
+	s3        *storage.S3Client
+	renderer  *pdfrender.Renderer // nil = rendering disabled
+	maxBytes  int64               // largest PDF rendered
+}
+
+// NewPDFPageHandler creates a PDFPageHandler. A nil renderer answers every request
+// with 501.
+func NewPDFPageHandler(fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository, s3 *storage.S3Client,
+	renderer *pdfrender.Renderer, maxBytes int64) *PDFPageHandler {
+	return &PDFPageHandler{fileRepo: fileRepo, blockRepo: blockRepo, s3: s3, renderer: renderer, maxBytes: maxBytes}
+}
+
+// RenderPage godoc
+// @Summary      Render a PDF page as PNG
+// @Description  Renders page n (from 1) of a PDF on the server, so a viewer can show one page at a time
+// @Description  without downloading the whole document. Rendered pages are cached by file version, page and
+// @Description  dpi; the ETag follows the same and If-None-Match is answered with 304.
+// @Tags         files
+// @Produce      image/png
+// @Param        id   path  int true  "File ID"
+// @Param        n    path  int true  "Page number, from 1"
+// @Param        dpi  query int false "Resolution (36-300, default 96)"
+// @Success      200  {file} binary
+// @Success      304  "Not Modified"
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse "No such file or page"
+// @Failure      413  {object} ErrorResponse "PDF too large to render"
+// @Failure      415  {object} ErrorResponse "Not a PDF"
+// @Failure      422  {object} ErrorResponse "The PDF could not be rendered"
+// @Failure      501  {object} ErrorResponse "PDF rendering is not available on this server"
+// @Failure      503  {object} ErrorResponse "Renderer busy; retry after the Retry-After header"
+// @Security     BearerAuth
+// @Router       /files/{id}/pages/{n} [get]
+func (h *PDFPageHandler) RenderPage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	page, err := strconv.Atoi(chi.URLParam(r, "n"))
+	if err != nil || page < 1 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "page numbers start at 1"})
+		return
+	}
+	dpi := defaultPageDPI
+	if v := r.URL.Query().Get("dpi"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minPageDPI || n > maxPageDPI {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("dpi must be between %d and %d", minPageDPI, maxPageDPI)})
+			return
+		}
+		dpi = n
+	}
+	if h.renderer == nil {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: "pdf_rendering_unavailable", Message: "PDF rendering is not available on this server"})
+		return
+	}
+
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+	mimeType := strings.ToLower(strings.TrimSpace(strings.SplitN(file.MimeType, ";", 2)[0]))
+	if mimeType != "application/pdf" && !strings.EqualFold(path.Ext(file.Name), ".pdf") {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "not_pdf", Message: "only PDF files can be rendered"})
+		return
+	}
+	if file.TotalSize > h.maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "file_too_large", Message: fmt.Sprintf("PDFs over %d bytes cannot be rendered", h.maxBytes),
+		})
+		return
+	}
+
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	key := fmt.Sprintf("%s-p%d-d%d", strings.Trim(model.BlocksETag(blocks), `"`), page, dpi)
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if etagMatches(r.Header.Get("If-None-Match"), `"`+key+`"`) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	png, ok := h.renderer.Cached(key)
+	if !ok {
+		ctx, cancel := context.WithTimeout(r.Context(), pageRenderWait)
+		defer cancel()
+		var storageErr error
+		png, err = h.renderer.Render(ctx, key, func(dst io.Writer) error {
+			storageErr = block.BlocksToStream(ctx, blocks, h.s3, dst)
+			return storageErr
+		}, page, dpi)
+		switch {
+		case errors.Is(err, pdfrender.ErrTooLarge):
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{
+				Error: "file_too_large", Message: fmt.Sprintf("PDFs over %d bytes cannot be rendered", h.maxBytes),
+			})
+			return
+		case storageErr != nil:
+			writeJSON(w, http.StatusBadGateway, ErrorResponse{Error: "storage_error", Message: "failed to read file"})
+			return
+		case errors.Is(err, pdfrender.ErrPageNotFound):
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "page_not_found", Message: "the document has no such page"})
+			return
+		case errors.Is(err, pdfrender.ErrBusy):
+			w.Header().Set("Retry-After", "5")
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "renderer_busy", Message: "too many pages are being rendered, try again shortly"})
+			return
+		case err != nil:
+			logger.Warn(r.Context(), "PDF page rendering failed", map[string]interface{}{
+				"file_id": file.ID, "page": page, "dpi": dpi, "error": err.Error(),
+			})
+			writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: "unrenderable_pdf", Message: "the PDF could not be rendered"})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(png)
+}
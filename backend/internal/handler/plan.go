@@ -0,0 +1,416 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+const maxPlanNameLen = 100
+
+// PlanHandler lets admins define plans and assign them to users, and users see theirs.
+type PlanHandler struct {
+	plans    *service.PlanService
+	planRepo *repository.PlanRepository
+	userRepo *repository.UserRepository
+}
+
+func NewPlanHandler(plans *service.PlanService, planRepo *repository.PlanRepository, userRepo *repository.UserRepository) *PlanHandler {
+	return &PlanHandler{plans: plans, planRepo: planRepo, userRepo: userRepo}
+}
+
+// CreatePlanRequest is the payload for POST /admin/plans. Omitted limits are unlimited.
+// Features switches feature flags off by name (false); unlisted features stay on.
+type CreatePlanRequest struct {
+	Name         string          `json:"name"                     example:"Pro"`
+	Description  *string         `json:"description,omitempty"    example:"1 TB, public links"`
+	QuotaBytes   *int64          `json:"quota_bytes,omitempty"    example:"1099511627776"`
+	MaxFileBytes *int64          `json:"max_file_bytes,omitempty" example:"10737418240"`
+	EgressBytes  *int64          `json:"monthly_egress_bytes,omitempty" example:"107374182400"`
+	Features     map[string]bool `json:"features,omitempty"`
+	IsDefault    bool            `json:"is_default,omitempty"`
+}
+
+// UpdatePlanRequest is the payload for PATCH /admin/plans/{id}. Omitted fields are
+// left unchanged; 0 removes a limit and "" clears the description. Features are
+// merged into the plan's, so only the flags given change.
+type UpdatePlanRequest struct {
+	Name         *string         `json:"name,omitempty"`
+	Description  *string         `json:"description,omitempty"`
+	QuotaBytes   *int64          `json:"quota_bytes,omitempty"`
+	MaxFileBytes *int64          `json:"max_file_bytes,omitempty"`
+	EgressBytes  *int64          `json:"monthly_egress_bytes,omitempty"`
+	Features     map[string]bool `json:"features,omitempty"`
+	IsDefault    *bool           `json:"is_default,omitempty"`
+}
+
+// AssignPlanRequest is the payload for PUT /admin/users/{id}/plan; null returns the
+// user to the default plan.
+type AssignPlanRequest struct {
+	PlanID *int64 `json:"plan_id" example:"2"`
+}
+
+// SetQuotaRequest is the payload for PUT /admin/users/{id}/quota; null returns the
+// user to their plan's quota.
+type SetQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes" example:"10737418240"`
+}
+
+// PlanUsage is a user's plan (nil = no limits) with their current usage. UsedBytes is
+// the total size of their files; ChargedBytes is what counts towards the quota under
+// the server's usage accounting, which can be less when blocks are deduplicated.
+type PlanUsage struct {
+	Plan         *model.Plan `json:"plan"`
+	UsedBytes    int64       `json:"used_bytes"    example:"1073741824"`
+	ChargedBytes int64       `json:"charged_bytes" example:"805306368"`
+	Accounting   string      `json:"accounting"    example:"logical"`
+}
+
+// GetMyPlan godoc
+// @Summary      Get your plan
+// @Description  The plan you are held to (your assigned plan, else the default plan) and your usage.
+// @Description  plan is null when no limits apply.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=PlanUsage}
+// @Security     BearerAuth
+// @Router       /auth/me/plan [get]
+func (h *PlanHandler) GetMyPlan(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	plan, err := h.plans.ForUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch plan"})
+		return
+	}
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch user"})
+		return
+	}
+	charged, err := h.plans.ChargedBytes(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch storage usage"})
+		return
+	}
+	writeJSON(w, http.StatusOK, PlanUsage{Plan: plan, UsedBytes: user.UsedBytes, ChargedBytes: charged, Accounting: h.plans.Accounting()})
+}
+
+// ListPlans godoc
+// @Summary      List plans
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=[]model.Plan}
+// @Security     BearerAuth
+// @Router       /admin/plans [get]
+func (h *PlanHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := h.planRepo.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list plans"})
+		return
+	}
+	writeJSON(w, http.StatusOK, plans)
+}
+
+// CreatePlan godoc
+// @Summary      Create a plan
+// @Description  Defines a storage quota, largest file size, monthly download traffic and which features are switched off. With
+// @Description  is_default the plan applies to every user without an assigned plan (replacing the previous default).
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreatePlanRequest true "Plan"
+// @Success      201  {object} respond.Envelope{data=model.Plan}
+// @Failure      400  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "name taken"
+// @Security     BearerAuth
+// @Router       /admin/plans [post]
+func (h *PlanHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req CreatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxPlanNameLen {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("name must be 1-%d characters", maxPlanNameLen)})
+		return
+	}
+	if (req.QuotaBytes != nil && *req.QuotaBytes <= 0) || (req.MaxFileBytes != nil && *req.MaxFileBytes <= 0) || (req.EgressBytes != nil && *req.EgressBytes <= 0) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "quota_bytes, max_file_bytes and monthly_egress_bytes must be positive"})
+		return
+	}
+	if !validFeatures(w, req.Features) {
+		return
+	}
+	if req.Features == nil {
+		req.Features = map[string]bool{}
+	}
+
+	plan := &model.Plan{
+		Name: req.Name, Description: req.Description, QuotaBytes: req.QuotaBytes, MaxFileBytes: req.MaxFileBytes, EgressBytes: req.EgressBytes,
+		Features: req.Features, IsDefault: req.IsDefault,
+	}
+	created, err := h.planRepo.Create(r.Context(), plan)
+	if err != nil {
+		writeRepoError(w, err, "plan not found", "failed to create plan")
+		return
+	}
+
+	audit.Describe(r.Context(), "plan", strconv.FormatInt(created.ID, 10), nil, created)
+	adminID, _ := auth.GetUserID(r)
+	logger.Info(r.Context(), "Plan created", map[string]interface{}{"plan_id": created.ID, "name": created.Name, "admin_id": adminID})
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdatePlan godoc
+// @Summary      Change a plan
+// @Description  New limits apply to the plan's users from their next upload or share; nothing already
+// @Description  stored is removed.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int               true "Plan ID"
+// @Param        body body     UpdatePlanRequest true "Fields to change"
+// @Success      200  {object} respond.Envelope{data=model.Plan}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "name taken"
+// @Security     BearerAuth
+// @Router       /admin/plans/{id} [patch]
+func (h *PlanHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	planID, ok := parsePlanID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdatePlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > maxPlanNameLen {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("name must be 1-%d characters", maxPlanNameLen)})
+			return
+		}
+		req.Name = &name
+	}
+	if (req.QuotaBytes != nil && *req.QuotaBytes < 0) || (req.MaxFileBytes != nil && *req.MaxFileBytes < 0) || (req.EgressBytes != nil && *req.EgressBytes < 0) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "quota_bytes, max_file_bytes and monthly_egress_bytes must not be negative"})
+		return
+	}
+	if !validFeatures(w, req.Features) {
+		return
+	}
+
+	before, err := h.planRepo.FindByID(r.Context(), planID)
+	if err != nil {
+		writeRepoError(w, err, "plan not found", "failed to fetch plan")
+		return
+	}
+	plan, err := h.planRepo.Update(r.Context(), planID, repository.PlanChanges{
+		Name: req.Name, Description: req.Description, QuotaBytes: req.QuotaBytes, MaxFileBytes: req.MaxFileBytes, EgressBytes: req.EgressBytes,
+		Features: req.Features, IsDefault: req.IsDefault,
+	})
+	if err != nil {
+		writeRepoError(w, err, "plan not found", "failed to update plan")
+		return
+	}
+	audit.Describe(r.Context(), "plan", strconv.FormatInt(plan.ID, 10), before, plan)
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// DeletePlan godoc
+// @Summary      Delete a plan
+// @Description  Its users fall back to the default plan.
+// @Tags         admin
+// @Param        id  path int true "Plan ID"
+// @Success      204
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/plans/{id} [delete]
+func (h *PlanHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	planID, ok := parsePlanID(w, r)
+	if !ok {
+		return
+	}
+	before, err := h.planRepo.FindByID(r.Context(), planID)
+	if err != nil {
+		writeRepoError(w, err, "plan not found", "failed to fetch plan")
+		return
+	}
+	if err := h.planRepo.Delete(r.Context(), planID); err != nil {
+		writeRepoError(w, err, "plan not found", "failed to delete plan")
+		return
+	}
+	audit.Describe(r.Context(), "plan", strconv.FormatInt(planID, 10), before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AssignPlan godoc
+// @Summary      Assign a plan to a user
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int               true "User ID"
+// @Param        body body     AssignPlanRequest true "Plan (null = default plan)"
+// @Success      200  {object} respond.Envelope{data=UserResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse "unknown user or plan"
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/plan [put]
+func (h *PlanHandler) AssignPlan(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req AssignPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+
+	before, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		writeRepoError(w, err, "user not found", "failed to fetch user")
+		return
+	}
+
+	user, err := h.userRepo.SetPlan(r.Context(), userID, req.PlanID)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "user not found"})
+		return
+	case errors.Is(err, repository.ErrPlanNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "plan_not_found", Message: "plan not found"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to assign plan"})
+		return
+	}
+
+	audit.Describe(r.Context(), "user", strconv.FormatInt(userID, 10),
+		map[string]*int64{"plan_id": before.PlanID}, map[string]*int64{"plan_id": user.PlanID})
+	adminID, _ := auth.GetUserID(r)
+	logger.Info(r.Context(), "Plan assigned", map[string]interface{}{"user_id": userID, "plan_id": req.PlanID, "admin_id": adminID})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// SetUserQuota godoc
+// @Summary      Set a user's storage quota
+// @Description  Overrides the quota of the user's plan for this user alone, higher or lower; null returns
+// @Description  them to the plan's. Uploads that would take their charged bytes over it answer 507. Lowering
+// @Description  it below what they already store deletes nothing, but blocks further uploads.
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int             true "User ID"
+// @Param        body body     SetQuotaRequest true "Quota in bytes (null = the plan's)"
+// @Success      200  {object} respond.Envelope{data=UserResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/quota [put]
+func (h *PlanHandler) SetUserQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req SetQuotaRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if req.QuotaBytes != nil && *req.QuotaBytes <= 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "quota_bytes must be positive"})
+		return
+	}
+
+	before, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		writeRepoError(w, err, "user not found", "failed to fetch user")
+		return
+	}
+
+	user, err := h.userRepo.SetQuota(r.Context(), userID, req.QuotaBytes)
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "user not found"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to set quota"})
+		return
+	}
+
+	audit.Describe(r.Context(), "user", strconv.FormatInt(userID, 10),
+		map[string]*int64{"quota_bytes": before.QuotaBytes}, map[string]*int64{"quota_bytes": user.QuotaBytes})
+	adminID, _ := auth.GetUserID(r)
+	logger.Info(r.Context(), "User quota set", map[string]interface{}{"user_id": userID, "quota_bytes": req.QuotaBytes, "admin_id": adminID})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+// writePlanError answers a *service.PlanLimitError from the user's plan and returns
+// true; other errors are left to the caller.
+func writePlanError(w http.ResponseWriter, err error) bool {
+	var limitErr *service.PlanLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		writeJSON(w, http.StatusInsufficientStorage, ErrorResponse{
+			Error: "quota_exceeded", Message: fmt.Sprintf("this would exceed your storage quota of %d bytes", limitErr.Limit),
+		})
+	case errors.Is(err, service.ErrPlanFileTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(limitErr.Limit))
+	case errors.Is(err, service.ErrEgressExceeded):
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{
+			Error: "egress_limit", Message: fmt.Sprintf("the owner has used this month's download traffic of %d bytes", limitErr.Limit),
+		})
+	default:
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "plan_limit", Message: err.Error()})
+	}
+	return true
+}
+
+// validFeatures checks that every name in features is a known feature flag. Writes
+// the error response and returns false otherwise.
+func validFeatures(w http.ResponseWriter, features map[string]bool) bool {
+	for name := range features {
+		if !model.IsFeature(name) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("unknown feature %q (known: %s)", name, strings.Join(model.Features, ", "))})
+			return false
+		}
+	}
+	return true
+}
+
+// parsePlanID reads the {id} URL parameter. Writes the error response and returns false if it is invalid.
+func parsePlanID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	planID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || planID < 1 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid plan id"})
+		return 0, false
+	}
+	return planID, true
+}
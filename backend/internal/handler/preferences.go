@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/i18n"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// maxDefaultShareExpiryHours caps the default share expiry a user can pick (one year).
+const maxDefaultShareExpiryHours = 365 * 24
+
+// PreferencesHandler serves the signed-in user's stored preferences.
+type PreferencesHandler struct {
+	userRepo *repository.UserRepository
+}
+
+func NewPreferencesHandler(userRepo *repository.UserRepository) *PreferencesHandler {
+	return &PreferencesHandler{userRepo: userRepo}
+}
+
+// GetPreferences godoc
+// @Summary      Get preferences
+// @Description  Returns the user's UI and behaviour preferences; preferences never set have their defaults.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=model.UserPreferences}
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /auth/me/preferences [get]
+func (h *PreferencesHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// UpdatePreferences godoc
+// @Summary      Update preferences
+// @Description  Changes only the preferences present in the body; nested objects are merged the same way, muted_kinds is replaced as a whole. Returns all preferences.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body     model.UserPreferences true "Preferences to change"
+// @Success      200  {object} respond.Envelope{data=model.UserPreferences}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /auth/me/preferences [patch]
+func (h *PreferencesHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	// Decoding over the current values leaves absent fields untouched.
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(prefs); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if msg := validatePreferences(prefs); msg != "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: msg})
+		return
+	}
+
+	userID, _ := auth.GetUserID(r)
+	if err := h.userRepo.UpdatePreferences(r.Context(), userID, prefs); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save preferences"})
+		return
+	}
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+// load fetches the user's preferences, writing the error response and returning
+// false on failure.
+func (h *PreferencesHandler) load(w http.ResponseWriter, r *http.Request) (*model.UserPreferences, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return nil, false
+	}
+	prefs, err := h.userRepo.Preferences(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to load preferences"})
+		return nil, false
+	}
+	if prefs == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "user not found"})
+		return nil, false
+	}
+	return prefs, true
+}
+
+// validatePreferences returns a message describing the first invalid preference, or "".
+func validatePreferences(p *model.UserPreferences) string {
+	if !slices.Contains(model.PreferenceSorts, p.DefaultSort) {
+		return "default_sort must be one of " + strings.Join(model.PreferenceSorts, ", ")
+	}
+	if p.Language != "" && !slices.Contains(i18n.Supported(), p.Language) {
+		return "language must be empty or one of " + strings.Join(i18n.Supported(), ", ")
+	}
+	if p.DefaultShareExpiryHours < 0 || p.DefaultShareExpiryHours > maxDefaultShareExpiryHours {
+		return fmt.Sprintf("default_share_expiry_hours must be between 0 and %d", maxDefaultShareExpiryHours)
+	}
+	if p.Notifications.MutedKinds == nil {
+		p.Notifications.MutedKinds = []string{}
+	}
+	for _, kind := range p.Notifications.MutedKinds {
+		if !slices.Contains(model.NotificationKinds, kind) {
+			return "unknown notification kind in muted_kinds: " + kind
+		}
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// activeContentCSP is sent with inline previews of types that can carry scripts
+// (SVG, XML). The sandbox gives the document an opaque origin and script-src
+// 'none' keeps embedded scripts and event handlers from running.
+const activeContentCSP = "sandbox; default-src 'none'; script-src 'none'; style-src 'unsafe-inline'; img-src data:"
+
+// PreviewPolicy decides how file content is presented to the browser. Inline
+// rendering is limited to an allowlist of MIME types; HTML is never rendered,
+// only shown as text, since it would run in the API's origin.
+type PreviewPolicy struct {
+	inline       map[string]bool // exact types, e.g. "image/png"
+	groups       map[string]bool // top-level types allowed with any subtype, e.g. "image"
+	maxTextBytes int64           // inline text previews are cut to this; 0 = never
+}
+
+// NewPreviewPolicy allows the given MIME types (e.g. "image/png") to render inline.
+// "type/*" allows every subtype of type. Inline text previews larger than
+// maxTextBytes send only their first maxTextBytes bytes (0 = no limit).
+func NewPreviewPolicy(inlineTypes []string, maxTextBytes int64) PreviewPolicy {
+	p := PreviewPolicy{inline: make(map[string]bool, len(inlineTypes)), groups: map[string]bool{}, maxTextBytes: maxTextBytes}
+	for _, t := range inlineTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if group, ok := strings.CutSuffix(t, "/*"); ok {
+			p.groups[group] = true
+		} else {
+			p.inline[t] = true
+		}
+	}
+	return p
+}
+
+// allows reports whether the bare MIME type base may render inline.
+func (p PreviewPolicy) allows(base string) bool {
+	if p.inline[base] {
+		return true
+	}
+	group, _, ok := strings.Cut(base, "/")
+	return ok && p.groups[group]
+}
+
+// SetContentHeaders sets Content-Type and Content-Disposition for serving file.
+// preview is the client's request; it returns whether the content is actually
+// served inline.
+func (p PreviewPolicy) SetContentHeaders(w http.ResponseWriter, file *model.File, preview bool) bool {
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	base := strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	switch {
+	case !preview:
+	case isHTML(base):
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", contentDisposition("inline", file.Name))
+		return true
+	case p.allows(base):
+		if isActiveContent(base) {
+			w.Header().Set("Content-Security-Policy", activeContentCSP)
+		}
+		w.Header().Set("Content-Type", mimeType)
+		w.Header().Set("Content-Disposition", contentDisposition("inline", file.Name))
+		return true
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", file.Name))
+	return false
+}
+
+// Truncate returns the range an inline preview of file is cut to, setting the
+// X-Preview-Truncated and X-Preview-Total-Size headers, or nil if it is sent whole.
+// Only text previews over the limit are cut, and never when the client asked for a
+// range (rng) of its own. The cut range is sent as a complete 200 response.
+func (p PreviewPolicy) Truncate(w http.ResponseWriter, file *model.File, inline bool, rng *byteRange) *byteRange {
+	if !inline || rng != nil || p.maxTextBytes <= 0 || file.TotalSize <= p.maxTextBytes {
+		return nil
+	}
+	base := strings.ToLower(strings.TrimSpace(strings.SplitN(file.MimeType, ";", 2)[0]))
+	if !isText(base) {
+		return nil
+	}
+	w.Header().Set("X-Preview-Truncated", "true")
+	w.Header().Set("X-Preview-Total-Size", strconv.FormatInt(file.TotalSize, 10))
+	return &byteRange{start: 0, length: p.maxTextBytes}
+}
+
+// isText reports types a browser renders as text, which it has to lay out in full.
+func isText(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"), isHTML(mimeType):
+		return true
+	case mimeType == "application/json", strings.HasSuffix(mimeType, "+json"), mimeType == "application/x-ndjson":
+		return true
+	case mimeType == "application/xml", mimeType == "application/csv":
+		return true
+	}
+	return false
+}
+
+func isHTML(mimeType string) bool {
+	return mimeType == "text/html" || mimeType == "application/xhtml+xml"
+}
+
+// isActiveContent reports types browsers may execute scripts from when rendered directly.
+func isActiveContent(mimeType string) bool {
+	return mimeType == "image/svg+xml" || mimeType == "text/xml" || mimeType == "application/xml" ||
+		strings.HasSuffix(mimeType, "+xml")
+}
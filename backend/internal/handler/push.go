@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/push"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// maxPushTokenLen bounds FCM tokens and Web Push endpoint URLs.
+const maxPushTokenLen = 4096
+
+// PushHandler manages the devices a user's notifications are pushed to.
+type PushHandler struct {
+	deviceRepo *repository.PushDeviceRepository
+	pusher     *push.Pusher
+}
+
+func NewPushHandler(deviceRepo *repository.PushDeviceRepository, pusher *push.Pusher) *PushHandler {
+	return &PushHandler{deviceRepo: deviceRepo, pusher: pusher}
+}
+
+// PushConfigResponse tells clients which platforms they can register for.
+type PushConfigResponse struct {
+	Platforms        []string `json:"platforms"`
+	WebPushPublicKey string   `json:"webpush_public_key,omitempty"` // applicationServerKey for PushManager.subscribe
+}
+
+// RegisterPushDeviceRequest registers an FCM token or a Web Push subscription.
+// For Web Push, token is the subscription endpoint and keys its p256dh/auth keys,
+// exactly as PushSubscription.toJSON() returns them.
+type RegisterPushDeviceRequest struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+	Keys     *struct {
+		P256DH string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys,omitempty"`
+}
+
+// GetPushConfig godoc
+// @Summary      Get push configuration
+// @Description  Lists the enabled push platforms and, for Web Push, the VAPID public key to subscribe with.
+// @Tags         push
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=PushConfigResponse}
+// @Security     BearerAuth
+// @Router       /push/config [get]
+func (h *PushHandler) GetPushConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, PushConfigResponse{
+		Platforms:        h.pusher.Platforms(),
+		WebPushPublicKey: h.pusher.VAPIDPublicKey(),
+	})
+}
+
+// ListPushDevices godoc
+// @Summary      List push devices
+// @Tags         push
+// @Produce      json
+// @Success      200 {object}  respond.Envelope{data=[]model.PushDevice}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /push/devices [get]
+func (h *PushHandler) ListPushDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	devices, err := h.deviceRepo.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list push devices"})
+		return
+	}
+	if devices == nil {
+		devices = []*model.PushDevice{}
+	}
+
+	writeJSON(w, http.StatusOK, devices)
+}
+
+// RegisterPushDevice godoc
+// @Summary      Register a push device
+// @Description  Registers an FCM registration token or a Web Push subscription so the user's notifications are pushed to it. Registering a known token again updates it.
+// @Tags         push
+// @Accept       json
+// @Produce      json
+// @Param        body body     RegisterPushDeviceRequest true "Device"
+// @Success      201  {object} respond.Envelope{data=model.PushDevice}
+// @Failure      400  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /push/devices [post]
+func (h *PushHandler) RegisterPushDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req RegisterPushDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	req.Token = strings.TrimSpace(req.Token)
+	if !h.pusher.Supports(req.Platform) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "push platform is not enabled"})
+		return
+	}
+	if req.Token == "" || len(req.Token) > maxPushTokenLen {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "token is required"})
+		return
+	}
+
+	var p256dh, authSecret *string
+	if req.Platform == model.PushPlatformWebPush {
+		if u, err := url.Parse(req.Token); err != nil || u.Scheme != "https" || u.Host == "" {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "token must be the https endpoint of the subscription"})
+			return
+		}
+		if req.Keys == nil || !validPushKey(req.Keys.P256DH, 65) || !validPushKey(req.Keys.Auth, 16) {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "keys.p256dh and keys.auth of the subscription are required"})
+			return
+		}
+		p256dh, authSecret = &req.Keys.P256DH, &req.Keys.Auth
+	}
+
+	device, err := h.deviceRepo.Register(r.Context(), userID, req.Platform, req.Token, p256dh, authSecret, r.UserAgent())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to register push device"})
+		return
+	}
+
+	logger.Info(r.Context(), "Push device registered", map[string]interface{}{
+		"user_id": userID, "device_id": device.ID, "platform": device.Platform,
+	})
+	writeJSON(w, http.StatusCreated, device)
+}
+
+// DeletePushDevice godoc
+// @Summary      Unregister a push device
+// @Tags         push
+// @Param        id  path int true "Push device ID"
+// @Success      204
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /push/devices/{id} [delete]
+func (h *PushHandler) DeletePushDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid push device id"})
+		return
+	}
+
+	if err := h.deviceRepo.Delete(r.Context(), deviceID, userID); err != nil {
+		if errors.Is(err, repository.ErrPushDeviceNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "push device not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete push device"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validPushKey reports whether s is base64url for exactly size bytes.
+func validPushKey(s string, size int) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	return err == nil && len(raw) == size
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// maxQuickAccessFolders caps the sidebar list so it stays a quick-access list.
+const maxQuickAccessFolders = 50
+
+// QuickAccessHandler manages the folders a user pins to the sidebar.
+type QuickAccessHandler struct {
+	quickAccessRepo *repository.QuickAccessRepository
+	folderRepo      *repository.FolderRepository
+}
+
+func NewQuickAccessHandler(quickAccessRepo *repository.QuickAccessRepository, folderRepo *repository.FolderRepository) *QuickAccessHandler {
+	return &QuickAccessHandler{quickAccessRepo: quickAccessRepo, folderRepo: folderRepo}
+}
+
+// ListQuickAccess godoc
+// @Summary      List quick-access folders
+// @Description  Returns the user's pinned folders in their chosen order.
+// @Tags         quick-access
+// @Produce      json
+// @Success      200 {object}  respond.Envelope{data=[]model.QuickAccessItem}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /quick-access [get]
+func (h *QuickAccessHandler) ListQuickAccess(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	items, err := h.quickAccessRepo.List(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list quick-access folders"})
+		return
+	}
+	if items == nil {
+		items = []*model.QuickAccessItem{}
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// PinFolder godoc
+// @Summary      Pin a folder to quick access
+// @Description  Adds the folder to the end of the quick-access list. Pinning an already pinned folder is a no-op.
+// @Tags         quick-access
+// @Produce      json
+// @Param        id  path     int true "Folder ID"
+// @Success      200 {object}  respond.Envelope{data=[]model.QuickAccessItem}
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      422 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /quick-access/{id} [put]
+func (h *QuickAccessHandler) PinFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), folderID, userID)
+	if err != nil || folder == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder not found"})
+		return
+	}
+
+	items, err := h.quickAccessRepo.List(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to pin folder"})
+		return
+	}
+	for _, item := range items {
+		if item.Folder.ID == folderID {
+			writeJSON(w, http.StatusOK, items)
+			return
+		}
+	}
+	if len(items) >= maxQuickAccessFolders {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Error: "quick_access_full", Message: fmt.Sprintf("at most %d folders can be pinned", maxQuickAccessFolders),
+		})
+		return
+	}
+
+	if err := h.quickAccessRepo.Pin(r.Context(), userID, folderID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to pin folder"})
+		return
+	}
+	h.writeList(w, r, userID)
+}
+
+// UnpinFolder godoc
+// @Summary      Unpin a folder from quick access
+// @Tags         quick-access
+// @Param        id  path     int true "Folder ID"
+// @Success      204
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /quick-access/{id} [delete]
+func (h *QuickAccessHandler) UnpinFolder(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	folderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder id"})
+		return
+	}
+
+	removed, err := h.quickAccessRepo.Unpin(r.Context(), userID, folderID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to unpin folder"})
+		return
+	}
+	if !removed {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "folder is not pinned"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderQuickAccessRequest is the payload for PUT /quick-access/order.
+type ReorderQuickAccessRequest struct {
+	FolderIDs []int64 `json:"folder_ids"` // every pinned folder, in the new order
+}
+
+// ReorderQuickAccess godoc
+// @Summary      Reorder quick-access folders
+// @Description  Sets the quick-access order. folder_ids must list every pinned folder exactly once.
+// @Tags         quick-access
+// @Accept       json
+// @Produce      json
+// @Param        body body     ReorderQuickAccessRequest true "New order"
+// @Success      200  {object}  respond.Envelope{data=[]model.QuickAccessItem}
+// @Failure      400  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /quick-access/order [put]
+func (h *QuickAccessHandler) ReorderQuickAccess(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req ReorderQuickAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.FolderIDs == nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "folder_ids is required"})
+		return
+	}
+
+	if err := h.quickAccessRepo.Reorder(r.Context(), userID, req.FolderIDs); err != nil {
+		if errors.Is(err, repository.ErrQuickAccessMismatch) {
+			writeJSON(w, http.StatusConflict, ErrorResponse{Error: "order_mismatch", Message: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to reorder quick-access folders"})
+		return
+	}
+	h.writeList(w, r, userID)
+}
+
+func (h *QuickAccessHandler) writeList(w http.ResponseWriter, r *http.Request, userID int64) {
+	items, err := h.quickAccessRepo.List(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list quick-access folders"})
+		return
+	}
+	if items == nil {
+		items = []*model.QuickAccessItem{}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
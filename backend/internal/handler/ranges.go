@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errRangeNotSatisfiable means the Range header lies entirely outside the file.
+var errRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// byteRange is a single resolved byte range of a file.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+// contentRange formats the Content-Range header value for a file of size bytes.
+func (br byteRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(br.start, 10) + "-" + strconv.FormatInt(br.start+br.length-1, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+// etagMatches reports whether an If-None-Match / If-Match style header lists etag.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// requestedRange resolves the request's Range header against a file of size bytes.
+// It returns nil when the whole file should be sent: no Range, a Range we do not
+// serve (multiple ranges, other units), or an If-Range that no longer matches.
+func requestedRange(r *http.Request, etag string, size int64) (*byteRange, error) {
+	header := r.Header.Get("Range")
+	if header == "" || !strings.HasPrefix(header, "bytes=") {
+		return nil, nil
+	}
+	if ifRange := r.Header.Get("If-Range"); ifRange != "" && strings.TrimSpace(ifRange) != etag {
+		return nil, nil
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	if strings.Contains(spec, ",") {
+		return nil, nil
+	}
+
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, nil
+	}
+	first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+	if first == "" {
+		// Suffix range: the final N bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return nil, nil
+		}
+		if n == 0 || size == 0 {
+			return nil, errRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return &byteRange{start: size - n, length: n}, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return nil, nil
+	}
+	if start >= size {
+		return nil, errRangeNotSatisfiable
+	}
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return nil, nil
+		}
+		end = min(end, size-1)
+	}
+	return &byteRange{start: start, length: end - start + 1}, nil
+}
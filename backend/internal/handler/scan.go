@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// ScanGate decides which malware scan verdicts keep a file from being served
+// through public links. Verdicts are reported by an external scanner via
+// PUT /admin/files/{id}/scan-status. New files start out pending; files stored
+// before scanning existed have no verdict, which counts as pending too.
+type ScanGate string
+
+const (
+	ScanGateOff      ScanGate = "off"      // serve whatever the verdict
+	ScanGateInfected ScanGate = "infected" // refuse files found infected
+	ScanGateStrict   ScanGate = "strict"   // also refuse files still waiting for a verdict
+)
+
+// scanRetryAfter is suggested to clients of a file whose scan is still pending.
+const scanRetryAfter = "60"
+
+// ParseScanGate validates a SHARE_SCAN_GATE value.
+func ParseScanGate(s string) (ScanGate, error) {
+	switch gate := ScanGate(s); gate {
+	case ScanGateOff, ScanGateInfected, ScanGateStrict:
+		return gate, nil
+	default:
+		return "", fmt.Errorf("handler.ParseScanGate: unknown gate %q (want %q, %q or %q)", s, ScanGateOff, ScanGateInfected, ScanGateStrict)
+	}
+}
+
+// check returns the status and error a public request for file is refused with,
+// or 0 if it may be served.
+func (g ScanGate) check(file *model.File) (int, ErrorResponse) {
+	if g == ScanGateOff {
+		return 0, ErrorResponse{}
+	}
+	status := model.ScanPending
+	if file.ScanStatus != nil {
+		status = *file.ScanStatus
+	}
+	switch status {
+	case model.ScanInfected:
+		return http.StatusUnavailableForLegalReasons, ErrorResponse{Error: "infected", Message: "this file was flagged as malware and cannot be downloaded"}
+	case model.ScanPending:
+		if g == ScanGateStrict {
+			return http.StatusLocked, ErrorResponse{Error: "scan_pending", Message: "this file is being scanned for malware; try again shortly"}
+		}
+	}
+	return 0, ErrorResponse{}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+// Security log page sizes.
+const (
+	defaultSecurityEventLimit = 100
+	maxSecurityEventLimit     = 500
+)
+
+// SecurityEventHandler serves the security log to admins.
+type SecurityEventHandler struct {
+	eventRepo *repository.SecurityEventRepository
+}
+
+func NewSecurityEventHandler(eventRepo *repository.SecurityEventRepository) *SecurityEventHandler {
+	return &SecurityEventHandler{eventRepo: eventRepo}
+}
+
+// ListSecurityEvents godoc
+// @Summary      Query the security log
+// @Description  Failed and throttled logins, rejected tokens and sessions, permission denials, CSRF failures and
+// @Description  wrong share link passwords, newest first. Page with before_id set to the last ID of the previous page.
+// @Tags         admin
+// @Produce      json
+// @Param        type      query    string false "login_failed, login_throttled, token_invalid, permission_denied, csrf_failed, share_password_failed or refresh_token_reused"
+// @Param        user_id   query    int    false "User the event concerns"
+// @Param        ip        query    string false "Client IP address, or a network in CIDR form (e.g. 2001:db8:1:2::/64)"
+// @Param        since     query    string false "RFC 3339 time, inclusive"
+// @Param        until     query    string false "RFC 3339 time, exclusive"
+// @Param        before_id query    int    false "Only events with a smaller ID"
+// @Param        limit     query    int    false "Max events (default 100, max 500)"
+// @Success      200       {object}  respond.Envelope{data=[]model.SecurityEvent}
+// @Failure      400       {object} ErrorResponse
+// @Failure      403       {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/security-events [get]
+func (h *SecurityEventHandler) ListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := model.SecurityEventFilter{
+		Type:      q.Get("type"),
+		IPAddress: q.Get("ip"),
+		Limit:     defaultSecurityEventLimit,
+	}
+	if f.Type != "" && !slices.Contains(model.SecurityEventTypes, f.Type) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "unknown security event type"})
+		return
+	}
+
+	if f.IPAddress != "" {
+		if _, _, err := net.ParseCIDR(f.IPAddress); err != nil && net.ParseIP(f.IPAddress) == nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "ip must be an IP address or CIDR network"})
+			return
+		}
+	}
+
+	var err error
+	if v := q.Get("user_id"); v != "" {
+		if f.UserID, err = strconv.ParseInt(v, 10, 64); err != nil || f.UserID < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid user_id"})
+			return
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || f.BeforeID < 1 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid before_id"})
+			return
+		}
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "since must be an RFC 3339 time"})
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "until must be an RFC 3339 time"})
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSecurityEventLimit {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "limit must be between 1 and 500"})
+			return
+		}
+		f.Limit = n
+	}
+
+	events, err := h.eventRepo.List(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list security events"})
+		return
+	}
+	if events == nil {
+		events = []*model.SecurityEvent{}
+	}
+
+	writeJSON(w, http.StatusOK, respond.Page{Items: events, Pagination: respond.Pagination{Count: len(events), Limit: f.Limit}})
+}
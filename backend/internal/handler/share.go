@@ -1,59 +1,175 @@
 package handler
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/naratel/naratel-box/backend/internal/auth"
 	"github.com/naratel/naratel-box/backend/internal/block"
 	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
 	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/security"
+	"github.com/naratel/naratel-box/backend/internal/service"
 	"github.com/naratel/naratel-box/backend/internal/storage"
 )
 
 type ShareHandler struct {
-	shareRepo *repository.ShareLinkRepository
-	fileRepo  *repository.FileRepository
-	blockRepo *repository.BlockRepository
-	s3        *storage.S3Client
+	shares          *service.ShareService
+	shareRepo       *repository.ShareLinkRepository
+	folderShareRepo *repository.FolderShareLinkRepository
+	fileRepo        *repository.FileRepository
+	folderRepo      *repository.FolderRepository
+	blockRepo       *repository.BlockRepository
+	statsRepo       *repository.FileStatsRepository
+	s3              *storage.S3Client
+	preview         PreviewPolicy
+	cache           *block.FileCache // nil = always stream from S3
+	scanGate        ScanGate
+	egress          *service.EgressService
+
+	// Uploads through folder share links.
+	files        *service.FileService
+	notifRepo    *repository.NotificationRepository
+	limiter      *block.Limiter
+	uploadPolicy UploadPolicy
+	maxUpload    int64 // server-wide cap on one file uploaded through a link
+
+	publicBaseURL string
+	brandName     string
 }
 
 func NewShareHandler(
+	shares *service.ShareService,
 	shareRepo *repository.ShareLinkRepository,
+	folderShareRepo *repository.FolderShareLinkRepository,
 	fileRepo *repository.FileRepository,
+	folderRepo *repository.FolderRepository,
 	blockRepo *repository.BlockRepository,
+	statsRepo *repository.FileStatsRepository,
 	s3 *storage.S3Client,
+	preview PreviewPolicy,
+	cache *block.FileCache,
+	scanGate ScanGate,
+	egress *service.EgressService,
+	files *service.FileService,
+	notifRepo *repository.NotificationRepository,
+	limiter *block.Limiter,
+	uploadPolicy UploadPolicy,
+	maxUpload int64,
+	publicBaseURL string,
+	brandName string,
 ) *ShareHandler {
 	return &ShareHandler{
-		shareRepo: shareRepo,
-		fileRepo:  fileRepo,
-		blockRepo: blockRepo,
-		s3:        s3,
+		shares:          shares,
+		shareRepo:       shareRepo,
+		folderShareRepo: folderShareRepo,
+		fileRepo:        fileRepo,
+		folderRepo:      folderRepo,
+		blockRepo:       blockRepo,
+		statsRepo:       statsRepo,
+		s3:              s3,
+		preview:         preview,
+		cache:           cache,
+		scanGate:        scanGate,
+		egress:          egress,
+		files:           files,
+		notifRepo:       notifRepo,
+		limiter:         limiter,
+		uploadPolicy:    uploadPolicy,
+		maxUpload:       maxUpload,
+		publicBaseURL:   publicBaseURL,
+		brandName:       brandName,
 	}
 }
 
+// baseURL returns the origin share URLs are built from.
+func (h *ShareHandler) baseURL(r *http.Request) string {
+	return resolveBaseURL(h.publicBaseURL, r)
+}
+
+// resolveBaseURL returns the origin public URLs are built from: PUBLIC_BASE_URL when
+// configured, otherwise the scheme and host the client used (proxy-aware).
+func resolveBaseURL(publicBaseURL string, r *http.Request) string {
+	if publicBaseURL != "" {
+		return publicBaseURL
+	}
+	return proxy.BaseURL(r)
+}
+
 // ShareLinkResponse is returned when creating a share link.
+// URL is the landing page to hand to recipients; DownloadURL streams the file directly.
 type ShareLinkResponse struct {
-	ID        int64      `json:"id"`
-	FileID    int64      `json:"file_id"`
-	Token     string     `json:"token"`
-	URL       string     `json:"url"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID          int64      `json:"id"`
+	FileID      int64      `json:"file_id"`
+	Token       string     `json:"token"`
+	URL         string     `json:"url"          example:"https://box.example.com/s/3f9a..."`
+	DownloadURL string     `json:"download_url" example:"https://box.example.com/api/v1/share/3f9a..."`
+	FileName    string     `json:"file_name,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	TakenDown   bool       `json:"taken_down"` // disabled by an admin; cannot be re-enabled
+	Protected   bool       `json:"password_protected"`
+	Expired     bool       `json:"expired"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// BulkDeleteResponse reports how many share links were revoked.
+type BulkDeleteResponse struct {
+	Deleted int64 `json:"deleted" example:"3"`
+}
+
+// newShareLinkResponse builds the API representation of a share link.
+func newShareLinkResponse(l *model.ShareLink, baseURL string) ShareLinkResponse {
+	return ShareLinkResponse{
+		ID:          l.ID,
+		FileID:      l.FileID,
+		Token:       l.Token,
+		URL:         fmt.Sprintf("%s/s/%s", baseURL, l.Token),
+		DownloadURL: fmt.Sprintf("%s/api/v1/share/%s", baseURL, l.Token),
+		Enabled:     l.Enabled,
+		TakenDown:   l.TakenDownAt != nil,
+		Protected:   l.PasswordHash != nil,
+		Expired:     l.ExpiresAt != nil && time.Now().After(*l.ExpiresAt),
+		ExpiresAt:   l.ExpiresAt,
+		CreatedAt:   l.CreatedAt,
+	}
+}
+
+// CreateShareLinkRequest is the optional payload for POST /files/{id}/share.
+type CreateShareLinkRequest struct {
+	Password       string `json:"password,omitempty"         example:"hunter2"`
+	ExpiresInHours int    `json:"expires_in_hours,omitempty" example:"48"`
+}
+
+func (req CreateShareLinkRequest) options() service.LinkOptions {
+	return service.LinkOptions{Password: req.Password, ExpiresInHours: req.ExpiresInHours}
 }
 
 // CreateShareLink godoc
 // @Summary      Create a share link for a file
+// @Description  Defaults (expiry, password requirement, whether public links are allowed) are inherited
+// @Description  from the nearest enclosing folder that sets them.
 // @Tags         share
+// @Accept       json
 // @Produce      json
-// @Param        id path int true "File ID"
-// @Success      201  {object} ShareLinkResponse
+// @Param        id   path int                    true  "File ID"
+// @Param        body body CreateShareLinkRequest false "Link options"
+// @Success      201  {object} respond.Envelope{data=ShareLinkResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
 // @Security     BearerAuth
 // @Router       /files/{id}/share [post]
 func (h *ShareHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
@@ -69,8 +185,23 @@ func (h *ShareHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The body is optional; an empty one means "use the folder defaults".
+	var req CreateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_in_hours must be non-negative"})
+		return
+	}
+
 	// Verify ownership
-	_, err = h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch file"})
+		return
+	}
 	if err != nil {
 		logger.Warn(r.Context(), "Share link creation forbidden", map[string]interface{}{
 			"user_id": userID, "file_id": fileID,
@@ -79,41 +210,62 @@ func (h *ShareHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate a random token
-	tokenBytes := make([]byte, 24)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		logger.ErrorLog(r.Context(), "Failed to generate share token", logger.ErrorDetails{
-			Code: "CRYPTO_ERR", Details: err.Error(),
-		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+	settings, ok := h.linkSettings(w, r, userID, file.FolderID, req)
+	if !ok {
+		return
+	}
+	link, ok := h.issueLink(w, r, file, userID, req, settings)
+	if !ok {
 		return
 	}
-	token := hex.EncodeToString(tokenBytes)
 
-	// 7-day expiry by default
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+	writeJSON(w, http.StatusCreated, newShareLinkResponse(link, h.baseURL(r)))
+}
 
-	link, err := h.shareRepo.Create(r.Context(), fileID, userID, token, &expiresAt)
+// linkSettings resolves the share settings for a file in folderID (nil = root) and
+// checks req against them. Writes the error response and returns false otherwise.
+func (h *ShareHandler) linkSettings(w http.ResponseWriter, r *http.Request, userID int64, folderID *int64, req CreateShareLinkRequest) (*model.EffectiveShareSettings, bool) {
+	settings, err := h.shares.Settings(r.Context(), userID, folderID, req.options())
+	if writeFeatureError(w, err) {
+		return nil, false
+	}
+	switch {
+	case errors.Is(err, service.ErrPublicLinksForbidden):
+		logger.Warn(r.Context(), "Share link blocked by folder settings", map[string]interface{}{
+			"user_id": userID, "folder_id": folderID,
+		})
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "public_links_forbidden", Message: "public links are not allowed in this folder"})
+		return nil, false
+	case errors.Is(err, service.ErrSharePasswordRequired):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "password_required", Message: "links in this folder must have a password"})
+		return nil, false
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to resolve folder share settings"})
+		return nil, false
+	}
+	return settings, true
+}
+
+// issueLink creates a share link for file with the options in req, falling back to
+// settings for the expiry. Writes the error response and returns false on failure.
+func (h *ShareHandler) issueLink(w http.ResponseWriter, r *http.Request, file *model.File, userID int64, req CreateShareLinkRequest, settings *model.EffectiveShareSettings) (*model.ShareLink, bool) {
+	link, err := h.shares.Issue(r.Context(), file, userID, req.options(), settings)
+	if errors.Is(err, service.ErrTakenDown) {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "taken_down", Message: "this file was taken down after an abuse report and cannot be shared"})
+		return nil, false
+	}
 	if err != nil {
 		logger.ErrorLog(r.Context(), "Failed to create share link", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
+			Code: "SHARE_CREATE_ERR", Details: err.Error(),
 		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create share link"})
-		return
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to create share link"})
+		return nil, false
 	}
 
 	logger.Info(r.Context(), "Share link created successfully", map[string]interface{}{
-		"user_id": userID, "file_id": fileID, "link_id": link.ID, "expires_at": expiresAt.Format(time.RFC3339),
-	})
-
-	writeJSON(w, http.StatusCreated, ShareLinkResponse{
-		ID:        link.ID,
-		FileID:    link.FileID,
-		Token:     link.Token,
-		URL:       fmt.Sprintf("/api/v1/share/%s", link.Token),
-		ExpiresAt: link.ExpiresAt,
-		CreatedAt: link.CreatedAt,
+		"user_id": userID, "file_id": file.ID, "link_id": link.ID, "expires_at": link.ExpiresAt,
 	})
+	return link, true
 }
 
 // GetShareLinks godoc
@@ -121,7 +273,7 @@ func (h *ShareHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
 // @Tags         share
 // @Produce      json
 // @Param        id path int true "File ID"
-// @Success      200  {array} ShareLinkResponse
+// @Success      200  {object} respond.Envelope{data=[]ShareLinkResponse}
 // @Security     BearerAuth
 // @Router       /files/{id}/share [get]
 func (h *ShareHandler) GetShareLinks(w http.ResponseWriter, r *http.Request) {
@@ -145,19 +297,155 @@ func (h *ShareHandler) GetShareLinks(w http.ResponseWriter, r *http.Request) {
 
 	responses := make([]ShareLinkResponse, 0, len(links))
 	for _, l := range links {
-		responses = append(responses, ShareLinkResponse{
-			ID:        l.ID,
-			FileID:    l.FileID,
-			Token:     l.Token,
-			URL:       fmt.Sprintf("/api/v1/share/%s", l.Token),
-			ExpiresAt: l.ExpiresAt,
-			CreatedAt: l.CreatedAt,
-		})
+		responses = append(responses, newShareLinkResponse(l, h.baseURL(r)))
 	}
 
 	writeJSON(w, http.StatusOK, responses)
 }
 
+// ListShareLinks godoc
+// @Summary      List all share links of the current user
+// @Description  Returns every share link across all files, with target file names and expiry status.
+// @Tags         share
+// @Produce      json
+// @Success      200  {object} respond.Envelope{data=[]ShareLinkResponse}
+// @Failure      401  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /share-links [get]
+func (h *ShareHandler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	links, err := h.shareRepo.ListWithFilesByUserID(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch share links"})
+		return
+	}
+
+	responses := make([]ShareLinkResponse, 0, len(links))
+	for _, l := range links {
+		resp := newShareLinkResponse(&l.ShareLink, h.baseURL(r))
+		resp.FileName = l.FileName
+		responses = append(responses, resp)
+	}
+
+	writeJSON(w, http.StatusOK, responses)
+}
+
+// BulkDeleteShareLinks godoc
+// @Summary      Bulk-revoke share links
+// @Description  Deletes all of the current user's expired share links. Requires ?expired=true.
+// @Tags         share
+// @Produce      json
+// @Param        expired query bool true "Must be true; only expired links can be bulk-deleted"
+// @Success      200  {object} respond.Envelope{data=BulkDeleteResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /share-links [delete]
+func (h *ShareHandler) BulkDeleteShareLinks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if r.URL.Query().Get("expired") != "true" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "only expired=true bulk deletion is supported"})
+		return
+	}
+
+	deleted, err := h.shareRepo.DeleteExpiredByUserID(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete share links"})
+		return
+	}
+
+	logger.Info(r.Context(), "Expired share links revoked", map[string]interface{}{
+		"user_id": userID, "deleted": deleted,
+	})
+	writeJSON(w, http.StatusOK, BulkDeleteResponse{Deleted: deleted})
+}
+
+// UpdateShareLinkRequest is the payload for PATCH /share-links/{id}.
+// Omitted fields are left unchanged.
+type UpdateShareLinkRequest struct {
+	Enabled      *bool      `json:"enabled,omitempty"       example:"false"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"    example:"2026-03-01T00:00:00Z"`
+	NeverExpires bool       `json:"never_expires,omitempty" example:"false"`
+}
+
+// UpdateShareLink godoc
+// @Summary      Enable/disable a share link or change its expiry
+// @Description  Lets the owner suspend a leaked link without regenerating the URL for legitimate recipients.
+// @Description  Links taken down by an admin cannot be re-enabled (403 taken_down).
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                    true "Share Link ID"
+// @Param        body body     UpdateShareLinkRequest true "Fields to change"
+// @Success      200  {object} respond.Envelope{data=ShareLinkResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /share-links/{id} [patch]
+func (h *ShareHandler) UpdateShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	linkID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid link id"})
+		return
+	}
+
+	var req UpdateShareLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if req.Enabled == nil && req.ExpiresAt == nil && !req.NeverExpires {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "nothing to update"})
+		return
+	}
+	if req.ExpiresAt != nil && req.NeverExpires {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_at and never_expires are mutually exclusive"})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_at must be in the future"})
+		return
+	}
+
+	link, err := h.shareRepo.Update(r.Context(), linkID, userID, req.Enabled, req.ExpiresAt, req.NeverExpires)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to update share link"})
+		return
+	}
+	if link == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "share link not found"})
+		return
+	}
+	if req.Enabled != nil && *req.Enabled && link.TakenDownAt != nil {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "taken_down", Message: "this link was taken down after an abuse report and cannot be re-enabled"})
+		return
+	}
+
+	logger.Info(r.Context(), "Share link updated", map[string]interface{}{
+		"user_id": userID, "link_id": link.ID, "enabled": link.Enabled, "expires_at": link.ExpiresAt,
+	})
+	writeJSON(w, http.StatusOK, newShareLinkResponse(link, h.baseURL(r)))
+}
+
 // DeleteShareLink godoc
 // @Summary      Delete a share link
 // @Tags         share
@@ -179,7 +467,7 @@ func (h *ShareHandler) DeleteShareLink(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.shareRepo.Delete(r.Context(), linkID, userID); err != nil {
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "share link not found"})
+		writeRepoError(w, err, "share link not found", "failed to delete share link")
 		return
 	}
 
@@ -188,13 +476,28 @@ func (h *ShareHandler) DeleteShareLink(w http.ResponseWriter, r *http.Request) {
 
 // DownloadShared godoc
 // @Summary      Download a file via share link (public)
+// @Description  Also answers HEAD with size, type and ETag; If-None-Match returns 304. A single byte
+// @Description  Range (with optional If-Range) resumes an interrupted download; each chunk is checked
+// @Description  against the link's state and expiry, and only a request from byte 0 counts as a download.
 // @Tags         share
 // @Produce      application/octet-stream
-// @Param        token path string true "Share token"
+// @Param        token            path   string true  "Share token"
+// @Param        X-Share-Password header string false "Link password"
+// @Param        Range            header string false "Byte range, e.g. bytes=1048576-"
+// @Param        If-Range         header string false "ETag the range applies to; a mismatch returns the whole file"
 // @Success      200 {file} binary
+// @Success      206 {file} binary "Partial Content"
+// @Success      304 "Not Modified"
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
 // @Failure      404 {object} ErrorResponse
 // @Failure      410 {object} ErrorResponse
+// @Failure      416 {object} ErrorResponse
+// @Failure      423 {object} ErrorResponse "Malware scan pending (SHARE_SCAN_GATE=strict)"
+// @Failure      429 {object} ErrorResponse "Too many requests, or too many unknown tokens, from this client; or the owner's monthly egress limit reached"
+// @Failure      451 {object} ErrorResponse "Taken down after an abuse report, or flagged as malware"
 // @Router       /share/{token} [get]
+// @Router       /share/{token} [head]
 func (h *ShareHandler) DownloadShared(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
@@ -202,11 +505,57 @@ func (h *ShareHandler) DownloadShared(w http.ResponseWriter, r *http.Request) {
 		"token": token,
 	})
 
-	link, err := h.shareRepo.FindByToken(r.Context(), token)
+	link, ok := openShareLink(w, r, h.shareRepo)
+	if !ok {
+		return
+	}
+
+	// Public share: the link owner's scope stands in for the (absent) user.
+	file, err := h.fileRepo.FindByID(r.Context(), repository.ForUser(link.UserID), link.FileID)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Shared file not found", logger.ErrorDetails{
+			Code: "FILE_NOT_FOUND", Details: err.Error(),
+		})
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+
+	if h.serveSharedFile(w, r, file, link.UserID) {
+		logger.Info(r.Context(), "Shared file downloaded successfully", map[string]interface{}{
+			"token": token, "file_id": file.ID, "file_name": file.Name, "total_size": file.TotalSize,
+		})
+	}
+}
+
+// openShareLink resolves the {token} file share link and checks that it may be used:
+// not taken down, owner active, enabled, unexpired and the password (if any) given.
+// Writes the error response and returns false otherwise.
+func openShareLink(w http.ResponseWriter, r *http.Request, shareRepo *repository.ShareLinkRepository) (*model.ShareLink, bool) {
+	token := chi.URLParam(r, "token")
+
+	link, err := shareRepo.FindByToken(r.Context(), token)
 	if err != nil || link == nil {
-		logger.Warn(r.Context(), "Share link not found", map[string]interface{}{"token": token})
+		logger.Warn(r.Context(), "Share link not found", map[string]interface{}{"token": token, "client_ip": proxy.ClientIP(r)})
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "share link not found"})
-		return
+		return nil, false
+	}
+
+	if link.TakenDownAt != nil {
+		writeJSON(w, http.StatusUnavailableForLegalReasons, ErrorResponse{Error: "taken_down", Message: "this content was taken down following a report"})
+		return nil, false
+	}
+
+	if link.OwnerDeactivated {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_unavailable", Message: "this link is no longer available"})
+		return nil, false
+	}
+
+	if !link.Enabled {
+		logger.Warn(r.Context(), "Disabled share link accessed", map[string]interface{}{
+			"token": token, "link_id": link.ID,
+		})
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_disabled", Message: "share link has been disabled by its owner"})
+		return nil, false
 	}
 
 	// Check expiry
@@ -215,60 +564,181 @@ func (h *ShareHandler) DownloadShared(w http.ResponseWriter, r *http.Request) {
 			"token": token, "link_id": link.ID, "expired_at": link.ExpiresAt.Format(time.RFC3339),
 		})
 		writeJSON(w, http.StatusGone, ErrorResponse{Error: "expired", Message: "share link has expired"})
-		return
+		return nil, false
 	}
 
-	// Fetch file (no user check — public share)
-	file, err := h.fileRepo.FindByID(r.Context(), link.FileID)
-	if err != nil {
-		logger.ErrorLog(r.Context(), "Shared file not found", logger.ErrorDetails{
-			Code: "FILE_NOT_FOUND", Details: err.Error(),
+	if !checkSharePassword(w, r, link.PasswordHash, map[string]interface{}{
+		"link_id": link.ID, "file_id": link.FileID, "owner_id": link.UserID,
+	}) {
+		return nil, false
+	}
+	return link, true
+}
+
+// checkSharePassword verifies the password a recipient sent against hash; a nil hash
+// means the link has none. The password comes in X-Share-Password or, from an HTML
+// form, a "password" field of a url-encoded POST body; never the query string, which
+// ends up in logs, browser history and Referer headers. Writes the error response,
+// recording failures with details, and returns false if it does not match.
+func checkSharePassword(w http.ResponseWriter, r *http.Request, hash *string, details map[string]interface{}) bool {
+	if hash == nil {
+		return true
+	}
+	password := r.Header.Get("X-Share-Password")
+	if password == "" && r.Method == http.MethodPost {
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "application/x-www-form-urlencoded" {
+			password = r.PostFormValue("password")
+		}
+	}
+	if password == "" {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "password_required", Message: "this share link is password protected"})
+		return false
+	}
+	if !sharePasswordMatches(r, *hash, password, details) {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid_password", Message: "incorrect share link password"})
+		return false
+	}
+	return true
+}
+
+// sharePasswordMatches compares password with a link's hash, logging and recording a
+// mismatch with details.
+func sharePasswordMatches(r *http.Request, hash, password string, details map[string]interface{}) bool {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		logger.Warn(r.Context(), "Wrong share link password", map[string]interface{}{
+			"link_id": details["link_id"], "client_ip": proxy.ClientIP(r),
 		})
-		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found"})
-		return
+		security.Record(r, model.SecuritySharePasswordFailed, 0, details)
+		return false
 	}
+	return true
+}
 
-	blockIDs, err := h.fileRepo.GetBlockIDs(r.Context(), file.ID)
-	if err != nil {
-		logger.ErrorLog(r.Context(), "Failed to fetch block IDs for shared download", logger.ErrorDetails{
-			Code: "DB_ERR", Details: err.Error(),
+// serveSharedFile answers a public request for file, owned by ownerID, once the link
+// it came through has been checked: scan gate, the owner's egress limit,
+// ETag/If-None-Match, Range, HEAD, preview headers, streaming and access stats. Returns true if the file was streamed.
+func (h *ShareHandler) serveSharedFile(w http.ResponseWriter, r *http.Request, file *model.File, ownerID int64) bool {
+	if status, resp := h.scanGate.check(file); status != 0 {
+		logger.Warn(r.Context(), "Shared download blocked by scan status", map[string]interface{}{
+			"file_id": file.ID, "scan_status": file.ScanStatus,
 		})
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch block ids"})
-		return
+		if status == http.StatusLocked {
+			w.Header().Set("Retry-After", scanRetryAfter)
+		}
+		writeJSON(w, status, resp)
+		return false
+	}
+	// Traffic through a link counts towards its owner's monthly egress.
+	rate, ok := checkEgress(w, r, h.egress, ownerID)
+	if !ok {
+		return false
 	}
 
-	blocks, err := h.blockRepo.FindByIDs(r.Context(), blockIDs)
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(ownerID), file.ID)
 	if err != nil {
 		logger.ErrorLog(r.Context(), "Failed to fetch blocks for shared download", logger.ErrorDetails{
 			Code: "DB_ERR", Details: err.Error(),
 		})
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
-		return
+		return false
+	}
+
+	etag := model.BlocksETag(blocks)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return false
+	}
+
+	// Every chunk of a resumed download passes the link checks above, so disabling
+	// or expiring a link also stops downloads already in progress.
+	rng, err := requestedRange(r, etag, file.TotalSize)
+	if err != nil {
+		w.Header().Set("Content-Range", "bytes */"+strconv.FormatInt(file.TotalSize, 10))
+		writeJSON(w, http.StatusRequestedRangeNotSatisfiable, ErrorResponse{Error: "range_not_satisfiable", Message: "requested range is outside the file"})
+		return false
 	}
 
-	mimeType := file.MimeType
-	if mimeType == "" {
-		mimeType = "application/octet-stream"
+	// Inline display only for allowlisted types (see PreviewPolicy).
+	preview := h.preview.SetContentHeaders(w, file, r.URL.Query().Get("preview") == "true")
+	cut := h.preview.Truncate(w, file, preview, rng)
+	status := http.StatusOK
+	switch {
+	case rng != nil:
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", rng.contentRange(file.TotalSize))
+		w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+	case cut != nil:
+		// A truncated preview streams only its first bytes, still as a 200.
+		w.Header().Set("Content-Length", strconv.FormatInt(cut.length, 10))
+		rng = cut
+	default:
+		w.Header().Set("Content-Length", strconv.FormatInt(file.TotalSize, 10))
 	}
 
-	// Check if preview is requested (inline display)
-	if r.URL.Query().Get("preview") == "true" {
-		w.Header().Set("Content-Type", mimeType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, file.Name))
-	} else {
-		w.Header().Set("Content-Type", mimeType)
-		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.Name))
+	w.WriteHeader(status)
+	// HEAD probes the file without streaming it or counting as a download.
+	if r.Method == http.MethodHead {
+		return false
 	}
-	w.Header().Set("Content-Length", strconv.FormatInt(file.TotalSize, 10))
 
-	if err := block.BlocksToStream(r.Context(), blocks, h.s3, w); err != nil {
+	mw := newMeteredWriter(r.Context(), w, rate)
+	err = h.streamShared(r.Context(), mw, file, blocks, etag, rng)
+	recordEgress(r.Context(), h.egress, ownerID, mw)
+	if err != nil {
 		logger.ErrorLog(r.Context(), "Shared file streaming failed", logger.ErrorDetails{
 			Code: "S3_STREAM_ERR", Details: err.Error(),
 		})
-		return
+		return false
 	}
 
-	logger.Info(r.Context(), "Shared file downloaded successfully", map[string]interface{}{
-		"token": token, "file_id": file.ID, "file_name": file.Name, "total_size": file.TotalSize,
-	})
+	// Count a download once, not once per resumed chunk.
+	if rng == nil || rng.start == 0 {
+		access := repository.AccessShareDownload
+		if preview {
+			access = repository.AccessSharePreview
+		}
+		if err := h.statsRepo.RecordAccess(r.Context(), file.ID, access); err != nil {
+			logger.Warn(r.Context(), "Failed to record file access", map[string]interface{}{
+				"file_id": file.ID, "error": err.Error(),
+			})
+		}
+	}
+	return true
+}
+
+// streamShared writes the file (or rng of it) to w. Small files are served from the
+// local file cache when one is configured, filling it on a miss, so a link that
+// goes viral costs one S3 read per version instead of one per visitor.
+func (h *ShareHandler) streamShared(ctx context.Context, w io.Writer, file *model.File, blocks []*model.Block, etag string, rng *byteRange) error {
+	if h.cache != nil && h.cache.Cacheable(file.TotalSize) {
+		key := strings.Trim(etag, `"`)
+		f, ok := h.cache.Open(key)
+		if !ok {
+			err := h.cache.Fill(file.ID, key, file.TotalSize, func(cw io.Writer) error {
+				return block.BlocksToStream(ctx, blocks, h.s3, cw)
+			})
+			if err != nil && !errors.Is(err, block.ErrCacheBusy) {
+				logger.Warn(ctx, "Share cache fill failed", map[string]interface{}{
+					"file_id": file.ID, "error": err.Error(),
+				})
+			}
+			f, ok = h.cache.Open(key)
+		}
+		if ok {
+			defer f.Close()
+			var src io.Reader = f
+			if rng != nil {
+				src = io.NewSectionReader(f, rng.start, rng.length)
+			}
+			_, err := io.Copy(w, src)
+			return err
+		}
+	}
+
+	if rng != nil {
+		return block.BlocksRangeToStream(ctx, blocks, h.s3, w, rng.start, rng.length)
+	}
+	return block.BlocksToStream(ctx, blocks, h.s3, w)
 }
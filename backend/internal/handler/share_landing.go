@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/i18n"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// shareLandingTmpl is the page recipients see when opening a share URL. Its download
+// form posts back to the page, so a password travels in the body, not the URL.
+var shareLandingTmpl = template.Must(template.New("share").Funcs(template.FuncMap{"t": i18n.T}).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .FileName}}{{.FileName}} · {{end}}{{.Brand}}</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f5f5f5;margin:0;display:flex;min-height:100vh;align-items:center;justify-content:center}
+main{background:#fff;border-radius:12px;box-shadow:0 1px 4px rgba(0,0,0,.1);padding:2rem;max-width:28rem;width:100%}
+h1{font-size:1.1rem;word-break:break-all}
+p{color:#555;font-size:.9rem}
+input,button{font:inherit;padding:.5rem .75rem;border-radius:6px;border:1px solid #ccc;width:100%;box-sizing:border-box;margin-top:.5rem}
+button{background:#111;color:#fff;border:none;cursor:pointer}
+.brand{font-weight:600;color:#111;margin-bottom:1rem}
+.error{color:#b00020}
+</style>
+</head>
+<body>
+<main>
+<div class="brand">{{.Brand}}</div>
+{{if .Error}}
+<h1>{{.Error}}</h1>
+{{else}}
+<h1>{{.FileName}}</h1>
+<p>{{.Size}}{{if .ExpiresAt}} · {{t .Lang "available until"}} {{.ExpiresAt}}{{end}}</p>
+<form method="post">
+{{if .PasswordError}}<p class="error">{{t .Lang .PasswordError}}</p>{{end}}
+{{if .Protected}}<input type="password" name="password" placeholder="{{t .Lang "Password"}}" required autofocus>{{end}}
+<button type="submit">{{t .Lang "Download"}}</button>
+</form>
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+type shareLandingData struct {
+	Lang          string
+	Brand         string
+	Error         string
+	FileName      string
+	Size          string
+	ExpiresAt     string
+	Protected     bool
+	PasswordError string
+}
+
+// ShareLanding serves GET /s/{token}, the public page returned as "url" in share link
+// responses. It shows the file name and size with a download button (and a password
+// field for protected links). The button POSTs the form back here, and the file is
+// served in answer once the password (read from the body) matches. It lives outside
+// /api/v1 so links stay short and brandable.
+func (h *ShareHandler) ShareLanding(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	data := shareLandingData{Brand: h.brandName}
+
+	link, err := h.shareRepo.FindByToken(r.Context(), token)
+	if err != nil || link == nil {
+		data.Error = "This share link does not exist."
+		h.renderLanding(w, r, http.StatusNotFound, data)
+		return
+	}
+	if link.TakenDownAt != nil {
+		data.Error = "This content was taken down following a report."
+		h.renderLanding(w, r, http.StatusUnavailableForLegalReasons, data)
+		return
+	}
+	if link.OwnerDeactivated {
+		data.Error = "This share link is no longer available."
+		h.renderLanding(w, r, http.StatusForbidden, data)
+		return
+	}
+	if !link.Enabled {
+		data.Error = "This share link has been disabled by its owner."
+		h.renderLanding(w, r, http.StatusForbidden, data)
+		return
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		data.Error = "This share link has expired."
+		h.renderLanding(w, r, http.StatusGone, data)
+		return
+	}
+
+	file, err := h.fileRepo.FindByID(r.Context(), repository.ForUser(link.UserID), link.FileID)
+	if err != nil {
+		data.Error = "The shared file is no longer available."
+		h.renderLanding(w, r, http.StatusNotFound, data)
+		return
+	}
+
+	if status, resp := h.scanGate.check(file); status != 0 {
+		data.Error = resp.Message
+		h.renderLanding(w, r, status, data)
+		return
+	}
+
+	data.FileName = file.Name
+	data.Size = formatBytes(file.TotalSize)
+	data.Protected = link.PasswordHash != nil
+	if link.ExpiresAt != nil {
+		data.ExpiresAt = link.ExpiresAt.UTC().Format("2 Jan 2006 15:04 UTC")
+	}
+
+	if r.Method == http.MethodPost {
+		details := map[string]interface{}{"link_id": link.ID, "file_id": link.FileID, "owner_id": link.UserID}
+		if !data.Protected || sharePasswordMatches(r, *link.PasswordHash, r.PostFormValue("password"), details) {
+			if h.serveSharedFile(w, r, file, link.UserID) {
+				logger.Info(r.Context(), "Shared file downloaded successfully", map[string]interface{}{
+					"token": token, "file_id": file.ID, "file_name": file.Name, "total_size": file.TotalSize,
+				})
+			}
+			return
+		}
+		data.PasswordError = "Incorrect password."
+		h.renderLanding(w, r, http.StatusUnauthorized, data)
+		return
+	}
+	h.renderLanding(w, r, http.StatusOK, data)
+}
+
+func (h *ShareHandler) renderLanding(w http.ResponseWriter, r *http.Request, status int, data shareLandingData) {
+	data.Lang = i18n.Lang(r.Context())
+	data.Error = i18n.T(data.Lang, data.Error)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := shareLandingTmpl.Execute(w, data); err != nil {
+		logger.ErrorLog(r.Context(), "Failed to render share landing page", logger.ErrorDetails{
+			Code: "TEMPLATE_ERR", Details: err.Error(),
+		})
+	}
+}
+
+// formatBytes renders a byte count as a short human-readable size.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
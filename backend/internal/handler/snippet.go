@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// snippetExtensions maps the accepted syntax hints to the extension the snippet is
+// saved with, so previews and downloads pick the right type from the name.
+var snippetExtensions = map[string]string{
+	"text":       ".txt",
+	"markdown":   ".md",
+	"json":       ".json",
+	"yaml":       ".yaml",
+	"xml":        ".xml",
+	"html":       ".html",
+	"css":        ".css",
+	"javascript": ".js",
+	"typescript": ".ts",
+	"go":         ".go",
+	"python":     ".py",
+	"java":       ".java",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"rust":       ".rs",
+	"php":        ".php",
+	"ruby":       ".rb",
+	"shell":      ".sh",
+	"sql":        ".sql",
+	"diff":       ".diff",
+}
+
+// SnippetHandler turns pasted text into a stored file with a share link in one call.
+type SnippetHandler struct {
+	files *service.FileService
+	share *ShareHandler
+
+	maxBytes int // largest snippet accepted, in bytes
+}
+
+func NewSnippetHandler(files *service.FileService, share *ShareHandler, maxBytes int) *SnippetHandler {
+	return &SnippetHandler{
+		files:    files,
+		share:    share,
+		maxBytes: maxBytes,
+	}
+}
+
+// CreateSnippetRequest is the payload for POST /snippets.
+type CreateSnippetRequest struct {
+	Content        string `json:"content"`
+	Language       string `json:"language,omitempty"         example:"go"`      // syntax hint; default "text"
+	Name           string `json:"name,omitempty"             example:"main.go"` // default "snippet-<time>.<ext>"
+	FolderID       *int64 `json:"folder_id,omitempty"`                          // default root
+	Password       string `json:"password,omitempty"`                           // share link password
+	ExpiresInHours int    `json:"expires_in_hours,omitempty" example:"24"`      // share link expiry
+}
+
+// SnippetResponse is the stored snippet and its share link.
+type SnippetResponse struct {
+	File      *model.File       `json:"file"`
+	Language  string            `json:"language"`
+	ShareLink ShareLinkResponse `json:"share_link"`
+}
+
+// CreateSnippet godoc
+// @Summary      Share a text snippet
+// @Description  Stores pasted text as a file and returns a share link for it right away. language is a
+// @Description  syntax hint that picks the file extension (and so the preview type); the share link follows
+// @Description  the same folder rules and defaults as POST /files/{id}/share.
+// @Tags         share
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreateSnippetRequest true "Snippet"
+// @Success      201  {object} respond.Envelope{data=SnippetResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      403  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      413  {object} ErrorResponse
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /snippets [post]
+func (h *SnippetHandler) CreateSnippet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	// JSON escaping can double the size of the text, and the other fields are small.
+	r.Body = http.MaxBytesReader(w, r.Body, int64(2*h.maxBytes+4096))
+	var req CreateSnippetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "too_large", Message: h.tooLargeMessage()})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "content is required"})
+		return
+	}
+	if len(req.Content) > h.maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "too_large", Message: h.tooLargeMessage()})
+		return
+	}
+	if req.ExpiresInHours < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_in_hours must be positive"})
+		return
+	}
+
+	language := strings.ToLower(req.Language)
+	if language == "" {
+		language = "text"
+	}
+	ext, ok := snippetExtensions[language]
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("unsupported language %q", req.Language)})
+		return
+	}
+
+	name := strings.TrimSpace(strings.ReplaceAll(req.Name, "/", "_"))
+	if name == "" {
+		name = "snippet-" + time.Now().UTC().Format("20060102-150405") + ext
+	} else if filepath.Ext(name) == "" {
+		name += ext
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = "text/plain; charset=utf-8"
+	}
+
+	if err := h.files.RequireFolder(r.Context(), userID, req.FolderID); err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "folder not found"})
+		return
+	}
+
+	// Check the folder's share rules before storing anything, so a refused link
+	// doesn't leave an orphaned snippet behind.
+	linkReq := CreateShareLinkRequest{Password: req.Password, ExpiresInHours: req.ExpiresInHours}
+	settings, ok := h.share.linkSettings(w, r, userID, req.FolderID, linkReq)
+	if !ok {
+		return
+	}
+
+	file, _, err := h.files.Store(r.Context(), service.StoreRequest{
+		UserID: userID, Name: name, MimeType: mimeType, Size: int64(len(req.Content)),
+		FolderID: req.FolderID, Client: requestClient(r), Content: strings.NewReader(req.Content),
+	})
+	if writePlanError(w, err) {
+		return
+	}
+	var contentErr *service.ContentError
+	if errors.As(err, &contentErr) {
+		logger.ErrorLog(r.Context(), "Snippet block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: "failed to store snippet"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save file metadata"})
+		return
+	}
+
+	link, ok := h.share.issueLink(w, r, file, userID, linkReq, settings)
+	if !ok {
+		return
+	}
+
+	logger.Info(r.Context(), "Snippet shared", map[string]interface{}{
+		"user_id": userID, "file_id": file.ID, "language": language, "size": file.TotalSize,
+	})
+
+	resp := newShareLinkResponse(link, h.share.baseURL(r))
+	resp.FileName = file.Name
+	writeJSON(w, http.StatusCreated, SnippetResponse{File: file, Language: language, ShareLink: resp})
+}
+
+func (h *SnippetHandler) tooLargeMessage() string {
+	return fmt.Sprintf("snippets may be at most %d KB", h.maxBytes/1024)
+}
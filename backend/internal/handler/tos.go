@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// TOSHandler publishes the current terms of service version and records acceptances.
+type TOSHandler struct {
+	userRepo *repository.UserRepository
+	version  string // empty = no terms configured
+	url      string
+}
+
+func NewTOSHandler(userRepo *repository.UserRepository, version, url string) *TOSHandler {
+	return &TOSHandler{userRepo: userRepo, version: version, url: url}
+}
+
+// TOSResponse describes the terms of service users must accept.
+type TOSResponse struct {
+	Version string `json:"version" example:"2026-01"`
+	URL     string `json:"url"     example:"https://box.example.com/terms"`
+}
+
+// AcceptTOSRequest is the payload for POST /auth/tos/accept.
+type AcceptTOSRequest struct {
+	Version string `json:"version" example:"2026-01"` // must be the current version
+}
+
+// GetTOS godoc
+// @Summary      Get the current terms of service
+// @Description  Returns the version users must accept and where to read it. An empty version means no
+// @Description  acceptance is required. Compare with tos_version from GET /auth/me.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=TOSResponse}
+// @Router       /tos [get]
+func (h *TOSHandler) GetTOS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, TOSResponse{Version: h.version, URL: h.url})
+}
+
+// AcceptTOS godoc
+// @Summary      Accept the terms of service
+// @Description  Records the user's consent to the current version, with IP address and user agent.
+// @Description  File, folder and sharing endpoints answer 403 tos_required until this is done, and again
+// @Description  whenever the version changes.
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        body body     AcceptTOSRequest true "Accepted version"
+// @Success      200  {object} respond.Envelope{data=UserResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      409  {object} ErrorResponse "Not the current version"
+// @Security     BearerAuth
+// @Router       /auth/tos/accept [post]
+func (h *TOSHandler) AcceptTOS(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req AcceptTOSRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Version == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "version is required"})
+		return
+	}
+	if h.version == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "no terms of service are configured"})
+		return
+	}
+	// Accepting a stale version (e.g. from a page loaded before an update) must not count.
+	if req.Version != h.version {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "version_mismatch", Message: "the terms of service have changed; review version " + h.version})
+		return
+	}
+
+	user, err := h.userRepo.AcceptTOS(r.Context(), userID, req.Version, proxy.ClientIP(r), r.UserAgent())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to record acceptance"})
+		return
+	}
+
+	logger.Info(r.Context(), "Terms of service accepted", map[string]interface{}{
+		"user_id": userID, "version": req.Version,
+	})
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
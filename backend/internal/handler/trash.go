@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// TrashHandler serves the trash: files deleted by the user that still hold their blocks
+// until they are restored or purged. Restores and purges go through FileService.
+type TrashHandler struct {
+	fileRepo *repository.FileRepository
+	files    *service.FileService
+}
+
+func NewTrashHandler(fileRepo *repository.FileRepository, files *service.FileService) *TrashHandler {
+	return &TrashHandler{fileRepo: fileRepo, files: files}
+}
+
+// ListTrash godoc
+// @Summary      List trashed files
+// @Description  Returns the user's trashed files, most recently deleted first. With folder_id, only
+// @Description  files trashed from that folder are listed, even if the folder has been deleted since.
+// @Tags         trash
+// @Produce      json
+// @Param        folder_id query int false "Folder the files were trashed from"
+// @Success      200 {object}  respond.Envelope{data=[]model.File}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /trash [get]
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var folderID *int64
+	if fid := r.URL.Query().Get("folder_id"); fid != "" {
+		parsed, err := strconv.ParseInt(fid, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid folder_id"})
+			return
+		}
+		folderID = &parsed
+	}
+
+	files, err := h.fileRepo.ListTrash(r.Context(), userID, folderID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list trash"})
+		return
+	}
+	if files == nil {
+		files = []*model.File{}
+	}
+
+	writeJSON(w, http.StatusOK, files)
+}
+
+// RestoreFile godoc
+// @Summary      Restore a trashed file
+// @Description  Moves a file out of the trash back into its folder (or the root if the folder no longer exists).
+// @Tags         trash
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      200 {object} respond.Envelope{data=model.File}
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /trash/{id}/restore [post]
+func (h *TrashHandler) RestoreFile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+
+	file, err := h.files.Restore(r.Context(), userID, fileID, requestClient(r))
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found in trash"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to restore file"})
+		return
+	}
+
+	logger.Info(r.Context(), "File restored from trash", map[string]interface{}{
+		"user_id": userID, "file_id": fileID,
+	})
+	writeJSON(w, http.StatusOK, file)
+}
+
+// PurgeFile godoc
+// @Summary      Permanently delete a trashed file
+// @Description  Deletes a trashed file for good and releases its block references; unreferenced blocks are garbage collected in the background.
+// @Tags         trash
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      204 "No Content"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /trash/{id} [delete]
+func (h *TrashHandler) PurgeFile(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+
+	released, err := h.files.Purge(r.Context(), userID, fileID)
+	if err != nil {
+		if errors.Is(err, repository.ErrFileNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file not found in trash"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete file"})
+		return
+	}
+
+	logger.Info(r.Context(), "File purged from trash", map[string]interface{}{
+		"user_id": userID, "file_id": fileID, "blocks_released": released,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EmptyTrashResponse reports what POST /trash/empty removed.
+type EmptyTrashResponse struct {
+	FilesPurged    int64 `json:"files_purged"`
+	BlocksReleased int64 `json:"blocks_released"`
+}
+
+// EmptyTrash godoc
+// @Summary      Empty the trash
+// @Description  Permanently deletes every trashed file now instead of waiting for retention expiry,
+// @Description  and starts block garbage collection right away.
+// @Tags         trash
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=EmptyTrashResponse}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /trash/empty [post]
+func (h *TrashHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var resp EmptyTrashResponse
+	var err error
+	resp.FilesPurged, resp.BlocksReleased, err = h.files.EmptyTrash(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to empty trash"})
+		return
+	}
+
+	logger.Info(r.Context(), "Trash emptied", map[string]interface{}{
+		"user_id": userID, "files_purged": resp.FilesPurged, "blocks_released": resp.BlocksReleased,
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/naratel/naratel-box/backend/internal/organize"
+)
+
+// UploadPolicy is the operator's server-wide limit on uploaded files: a maximum size
+// and allow/deny lists of file types (extensions and/or MIME types, as in file-drop
+// accepted types). It is checked before any content is processed. Copies share the
+// size limit, so SetMaxBytes applies to every handler holding one.
+type UploadPolicy struct {
+	maxBytes *atomic.Int64 // 0 = no limit
+	allowed  []string      // empty = any type not denied
+	denied   []string
+}
+
+// NewUploadPolicy validates the type lists. The deny list wins over the allow list.
+func NewUploadPolicy(maxBytes int64, allowed, denied []string) (UploadPolicy, error) {
+	if err := organize.ValidateAccepted(allowed); err != nil {
+		return UploadPolicy{}, fmt.Errorf("handler.NewUploadPolicy: allowed types: %w", err)
+	}
+	if err := organize.ValidateAccepted(denied); err != nil {
+		return UploadPolicy{}, fmt.Errorf("handler.NewUploadPolicy: denied types: %w", err)
+	}
+	p := UploadPolicy{maxBytes: new(atomic.Int64), allowed: allowed, denied: denied}
+	p.maxBytes.Store(maxBytes)
+	return p, nil
+}
+
+// SetMaxBytes changes the size limit for uploads that start from now on (0 = no limit).
+func (p UploadPolicy) SetMaxBytes(maxBytes int64) {
+	p.maxBytes.Store(maxBytes)
+}
+
+// limit returns the smaller of the policy's size limit and max (0 = no limit for either).
+func (p UploadPolicy) limit(max int64) int64 {
+	if maxBytes := p.maxBytes.Load(); maxBytes > 0 && (max <= 0 || maxBytes < max) {
+		return maxBytes
+	}
+	return max
+}
+
+// tooLarge is the response for files over maxBytes.
+func tooLarge(maxBytes int64) ErrorResponse {
+	return ErrorResponse{Error: "file_too_large", Message: fmt.Sprintf("files may be at most %d bytes", maxBytes)}
+}
+
+// check returns the status and error an upload of the file is refused with, or 0 if
+// the policy allows it.
+func (p UploadPolicy) check(fileName, mimeType string, size int64) (int, ErrorResponse) {
+	if maxBytes := p.maxBytes.Load(); maxBytes > 0 && size > maxBytes {
+		return http.StatusRequestEntityTooLarge, tooLarge(maxBytes)
+	}
+	if len(p.denied) > 0 && organize.Accepts(p.denied, fileName, mimeType) {
+		return http.StatusUnsupportedMediaType, ErrorResponse{Error: "type_not_allowed", Message: "this file type is not allowed on this server"}
+	}
+	if !organize.Accepts(p.allowed, fileName, mimeType) {
+		return http.StatusUnsupportedMediaType, ErrorResponse{
+			Error: "type_not_allowed", Message: "allowed file types: " + strings.Join(p.allowed, ", "),
+		}
+	}
+	return 0, ErrorResponse{}
+}
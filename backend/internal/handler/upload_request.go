@@ -0,0 +1,491 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/organize"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// File-drop request text limits.
+const (
+	maxUploadRequestTitleLen        = 200
+	maxUploadRequestInstructionsLen = 2000
+	maxUploadRequestAcceptedTypes   = 50
+)
+
+// UploadRequestHandler manages file-drop links and receives the files dropped
+// through them by anonymous senders.
+type UploadRequestHandler struct {
+	files       *service.FileService
+	requestRepo *repository.UploadRequestRepository
+	folderRepo  *repository.FolderRepository
+	notifRepo   *repository.NotificationRepository
+	limiter     *block.Limiter
+	policy      UploadPolicy
+
+	maxBytes      int64 // server-wide cap on one dropped file
+	publicBaseURL string
+}
+
+func NewUploadRequestHandler(
+	files *service.FileService,
+	requestRepo *repository.UploadRequestRepository,
+	folderRepo *repository.FolderRepository,
+	notifRepo *repository.NotificationRepository,
+	limiter *block.Limiter,
+	policy UploadPolicy,
+	maxBytes int64,
+	publicBaseURL string,
+) *UploadRequestHandler {
+	return &UploadRequestHandler{
+		files:         files,
+		requestRepo:   requestRepo,
+		folderRepo:    folderRepo,
+		notifRepo:     notifRepo,
+		limiter:       limiter,
+		policy:        policy,
+		maxBytes:      maxBytes,
+		publicBaseURL: publicBaseURL,
+	}
+}
+
+// UploadRequestResponse is a file-drop link with the URL to hand to senders.
+type UploadRequestResponse struct {
+	*model.UploadRequest
+	UploadURL string `json:"upload_url" example:"https://box.example.com/api/v1/drop/3f9a..."`
+}
+
+// CreateUploadRequestRequest is the payload for POST /upload-requests.
+type CreateUploadRequestRequest struct {
+	FolderID       int64    `json:"folder_id"`
+	Title          string   `json:"title"                      example:"Conference photos"`
+	Instructions   string   `json:"instructions,omitempty"     example:"Please name files after your session."`
+	AcceptedTypes  []string `json:"accepted_types,omitempty"   example:"jpg,image/png"` // empty = any type
+	MaxSizeBytes   *int64   `json:"max_size_bytes,omitempty"`                           // per file; default server limit
+	ExpiresInHours int      `json:"expires_in_hours,omitempty" example:"168"`           // 0 = never
+}
+
+// UpdateUploadRequestRequest is the payload for PATCH /upload-requests/{id}.
+type UpdateUploadRequestRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// DropMetadata is what the public upload page needs to render a file-drop link.
+type DropMetadata struct {
+	Title         string     `json:"title"`
+	Instructions  string     `json:"instructions"`
+	AcceptedTypes []string   `json:"accepted_types"` // extensions and/or MIME types; empty = any
+	MaxSizeBytes  int64      `json:"max_size_bytes"` // per file, including the server limit
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// DropReceipt confirms a file received through a file-drop link.
+type DropReceipt struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+func (h *UploadRequestHandler) response(r *http.Request, u *model.UploadRequest) UploadRequestResponse {
+	return UploadRequestResponse{
+		UploadRequest: u,
+		UploadURL:     fmt.Sprintf("%s/api/v1/drop/%s", resolveBaseURL(h.publicBaseURL, r), u.Token),
+	}
+}
+
+// maxSizeFor is the effective per-file limit of a file-drop link.
+func (h *UploadRequestHandler) maxSizeFor(u *model.UploadRequest) int64 {
+	if u.MaxSizeBytes != nil && *u.MaxSizeBytes < h.maxBytes {
+		return h.policy.limit(*u.MaxSizeBytes)
+	}
+	return h.policy.limit(h.maxBytes)
+}
+
+// CreateUploadRequest godoc
+// @Summary      Create a file-drop link
+// @Description  Creates a public link through which anyone holding it can upload files into one of your
+// @Description  folders, restricted to the accepted types and size limit.
+// @Tags         upload-requests
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreateUploadRequestRequest true "File-drop link"
+// @Success      201  {object} respond.Envelope{data=UploadRequestResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-requests [post]
+func (h *UploadRequestHandler) CreateUploadRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CreateUploadRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	switch {
+	case req.Title == "":
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "title is required"})
+		return
+	case utf8.RuneCountInString(req.Title) > maxUploadRequestTitleLen:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("title must be at most %d characters", maxUploadRequestTitleLen)})
+		return
+	case utf8.RuneCountInString(req.Instructions) > maxUploadRequestInstructionsLen:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("instructions must be at most %d characters", maxUploadRequestInstructionsLen)})
+		return
+	case len(req.AcceptedTypes) > maxUploadRequestAcceptedTypes:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("at most %d accepted types", maxUploadRequestAcceptedTypes)})
+		return
+	case req.MaxSizeBytes != nil && *req.MaxSizeBytes <= 0:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "max_size_bytes must be positive"})
+		return
+	case req.ExpiresInHours < 0:
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "expires_in_hours must be positive"})
+		return
+	}
+	if err := organize.ValidateAccepted(req.AcceptedTypes); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), req.FolderID, userID)
+	if err != nil || folder == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "folder not found"})
+		return
+	}
+
+	token, err := service.NewLinkToken()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "failed to generate token"})
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &t
+	}
+
+	accepted := make([]string, 0, len(req.AcceptedTypes))
+	for _, a := range req.AcceptedTypes {
+		accepted = append(accepted, strings.ToLower(strings.TrimSpace(a)))
+	}
+
+	created, err := h.requestRepo.Create(r.Context(), &model.UploadRequest{
+		UserID:        userID,
+		FolderID:      folder.ID,
+		Token:         token,
+		Title:         req.Title,
+		Instructions:  req.Instructions,
+		AcceptedTypes: accepted,
+		MaxSizeBytes:  req.MaxSizeBytes,
+		ExpiresAt:     expiresAt,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create file-drop link"})
+		return
+	}
+
+	logger.Info(r.Context(), "File-drop link created", map[string]interface{}{
+		"user_id": userID, "request_id": created.ID, "folder_id": folder.ID,
+	})
+	writeJSON(w, http.StatusCreated, h.response(r, created))
+}
+
+// ListUploadRequests godoc
+// @Summary      List file-drop links
+// @Tags         upload-requests
+// @Produce      json
+// @Success      200 {object}  respond.Envelope{data=[]UploadRequestResponse}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-requests [get]
+func (h *UploadRequestHandler) ListUploadRequests(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	requests, err := h.requestRepo.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list file-drop links"})
+		return
+	}
+
+	out := make([]UploadRequestResponse, 0, len(requests))
+	for _, u := range requests {
+		out = append(out, h.response(r, u))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// UpdateUploadRequest godoc
+// @Summary      Enable or disable a file-drop link
+// @Tags         upload-requests
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int                        true "File-drop link ID"
+// @Param        body body     UpdateUploadRequestRequest true "New state"
+// @Success      200  {object} respond.Envelope{data=UploadRequestResponse}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-requests/{id} [patch]
+func (h *UploadRequestHandler) UpdateUploadRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file-drop link id"})
+		return
+	}
+
+	var req UpdateUploadRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+
+	updated, err := h.requestRepo.SetEnabled(r.Context(), requestID, userID, req.Enabled)
+	if err != nil {
+		if errors.Is(err, repository.ErrUploadRequestNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file-drop link not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to update file-drop link"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.response(r, updated))
+}
+
+// DeleteUploadRequest godoc
+// @Summary      Delete a file-drop link
+// @Description  Files already received stay in the folder.
+// @Tags         upload-requests
+// @Param        id  path int true "File-drop link ID"
+// @Success      204
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-requests/{id} [delete]
+func (h *UploadRequestHandler) DeleteUploadRequest(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file-drop link id"})
+		return
+	}
+
+	if err := h.requestRepo.Delete(r.Context(), requestID, userID); err != nil {
+		if errors.Is(err, repository.ErrUploadRequestNotFound) {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file-drop link not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete file-drop link"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findOpenRequest loads the file-drop link named by the URL token and checks that it
+// still accepts files. Writes the error response and returns nil otherwise.
+func (h *UploadRequestHandler) findOpenRequest(w http.ResponseWriter, r *http.Request) *model.UploadRequest {
+	token := chi.URLParam(r, "token")
+
+	u, err := h.requestRepo.FindByToken(r.Context(), token)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to look up file-drop link"})
+		return nil
+	}
+	if u == nil {
+		logger.Warn(r.Context(), "File-drop link not found", map[string]interface{}{"token": token, "client_ip": proxy.ClientIP(r)})
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "file-drop link not found"})
+		return nil
+	}
+	if u.OwnerDeactivated {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_unavailable", Message: "this link is no longer available"})
+		return nil
+	}
+	if !u.Enabled {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "link_disabled", Message: "file-drop link has been disabled by its owner"})
+		return nil
+	}
+	if u.ExpiresAt != nil && time.Now().After(*u.ExpiresAt) {
+		writeJSON(w, http.StatusGone, ErrorResponse{Error: "expired", Message: "file-drop link has expired"})
+		return nil
+	}
+	return u
+}
+
+// GetDropMetadata godoc
+// @Summary      Describe a file-drop link
+// @Description  Public. Returns what the upload page shows: title, instructions, accepted types and size limit.
+// @Tags         upload-requests
+// @Produce      json
+// @Param        token path     string true "File-drop token"
+// @Success      200   {object} respond.Envelope{data=DropMetadata}
+// @Failure      403   {object} ErrorResponse "link disabled, or its owner deactivated"
+// @Failure      404   {object} ErrorResponse
+// @Failure      410   {object} ErrorResponse "link expired"
+// @Failure      429   {object} ErrorResponse "Too many requests, or too many unknown tokens, from this client"
+// @Router       /drop/{token} [get]
+func (h *UploadRequestHandler) GetDropMetadata(w http.ResponseWriter, r *http.Request) {
+	u := h.findOpenRequest(w, r)
+	if u == nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DropMetadata{
+		Title:         u.Title,
+		Instructions:  u.Instructions,
+		AcceptedTypes: u.AcceptedTypes,
+		MaxSizeBytes:  h.maxSizeFor(u),
+		ExpiresAt:     u.ExpiresAt,
+	})
+}
+
+// SubmitDrop godoc
+// @Summary      Upload a file through a file-drop link
+// @Description  Public. The file is stored in the link owner's folder if it matches the link's accepted
+// @Description  types and size limit; the owner gets a notification.
+// @Tags         upload-requests
+// @Accept       mpfd
+// @Produce      json
+// @Param        token path     string true "File-drop token"
+// @Param        file  formData file   true "File to upload"
+// @Success      201   {object} respond.Envelope{data=DropReceipt}
+// @Failure      400   {object} ErrorResponse
+// @Failure      403   {object} ErrorResponse "link disabled, or its owner deactivated"
+// @Failure      404   {object} ErrorResponse
+// @Failure      410   {object} ErrorResponse "link expired"
+// @Failure      413   {object} ErrorResponse "file exceeds the size limit"
+// @Failure      415   {object} ErrorResponse "file type not accepted"
+// @Failure      429   {object} ErrorResponse "Too many requests, or too many unknown tokens, from this client"
+// @Failure      503   {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Router       /drop/{token} [post]
+func (h *UploadRequestHandler) SubmitDrop(w http.ResponseWriter, r *http.Request) {
+	u := h.findOpenRequest(w, r)
+	if u == nil {
+		return
+	}
+	maxSize := h.maxSizeFor(u)
+
+	// Reject oversized bodies early; 1 MB covers the multipart framing.
+	if r.ContentLength > maxSize+1<<20 {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "file_too_large", Message: fmt.Sprintf("files may be at most %d bytes", maxSize)})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "file_too_large", Message: fmt.Sprintf("files may be at most %d bytes", maxSize)})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "failed to parse multipart form"})
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	f, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "field 'file' is required"})
+		return
+	}
+	defer f.Close()
+
+	if fileHeader.Size > maxSize {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "file_too_large", Message: fmt.Sprintf("files may be at most %d bytes", maxSize)})
+		return
+	}
+	mimeType := mime.TypeByExtension(filepath.Ext(fileHeader.Filename))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if !organize.Accepts(u.AcceptedTypes, fileHeader.Filename, mimeType) {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error: "type_not_accepted", Message: "accepted file types: " + strings.Join(u.AcceptedTypes, ", "),
+		})
+		return
+	}
+	if status, resp := h.policy.check(fileHeader.Filename, mimeType, fileHeader.Size); status != 0 {
+		writeJSON(w, status, resp)
+		return
+	}
+
+	// Take a processing slot only once the body is spooled, so a slow client does
+	// not hold one while it trickles the upload in.
+	release, err := h.limiter.Acquire(r.Context())
+	if err != nil {
+		if errors.Is(err, block.ErrSaturated) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(h.limiter.RetryAfter().Seconds())))
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "server_busy", Message: "too many uploads in progress, please retry shortly"})
+		}
+		return
+	}
+	defer release()
+
+	file, _, err := h.files.Store(r.Context(), service.StoreRequest{
+		UserID: u.UserID, Name: fileHeader.Filename, MimeType: mimeType, Size: fileHeader.Size,
+		FolderID: &u.FolderID, Client: requestClient(r), Content: f,
+	})
+	if writePlanError(w, err) {
+		return
+	}
+	var contentErr *service.ContentError
+	if errors.As(err, &contentErr) {
+		logger.ErrorLog(r.Context(), "File-drop block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: "failed to store file"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save file metadata"})
+		return
+	}
+
+	// The file is stored; bookkeeping failures are logged by the repositories only.
+	_ = h.requestRepo.RecordUpload(r.Context(), u.ID)
+	_, _ = h.notifRepo.Create(r.Context(), u.UserID, model.NotificationFileDrop, model.FileDropNotice{
+		RequestID: u.ID, Title: u.Title, FileID: file.ID, Name: file.Name, Size: file.TotalSize,
+	})
+
+	logger.Info(r.Context(), "File received through file-drop link", map[string]interface{}{
+		"request_id": u.ID, "user_id": u.UserID, "file_id": file.ID, "size": file.TotalSize, "client_ip": proxy.ClientIP(r),
+	})
+	writeJSON(w, http.StatusCreated, DropReceipt{Name: file.Name, Size: file.TotalSize, ReceivedAt: file.CreatedAt})
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/organize"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// maxUploadRules caps rules per user; every root upload evaluates all of them.
+const maxUploadRules = 100
+
+// UploadRuleHandler manages the rules that file root uploads into folders automatically.
+type UploadRuleHandler struct {
+	ruleRepo   *repository.UploadRuleRepository
+	folderRepo *repository.FolderRepository
+}
+
+func NewUploadRuleHandler(ruleRepo *repository.UploadRuleRepository, folderRepo *repository.FolderRepository) *UploadRuleHandler {
+	return &UploadRuleHandler{ruleRepo: ruleRepo, folderRepo: folderRepo}
+}
+
+// UploadRuleRequest is the payload for creating (all fields but position and enabled
+// required) or updating (omitted fields unchanged) an upload rule.
+type UploadRuleRequest struct {
+	Name           *string `json:"name,omitempty"             example:"Camera uploads"`
+	MatchType      *string `json:"match_type,omitempty"       example:"extension"`
+	Pattern        *string `json:"pattern,omitempty"          example:"jpg,jpeg,heic"`
+	TargetFolderID *int64  `json:"target_folder_id,omitempty" example:"12"`
+	Position       *int    `json:"position,omitempty"         example:"0"`
+	Enabled        *bool   `json:"enabled,omitempty"          example:"true"`
+}
+
+// ListUploadRules godoc
+// @Summary      List upload rules
+// @Description  Returns the user's upload rules in evaluation order.
+// @Tags         upload-rules
+// @Produce      json
+// @Success      200 {object}  respond.Envelope{data=[]model.UploadRule}
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-rules [get]
+func (h *UploadRuleHandler) ListUploadRules(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	rules, err := h.ruleRepo.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list upload rules"})
+		return
+	}
+	if rules == nil {
+		rules = []*model.UploadRule{}
+	}
+
+	writeJSON(w, http.StatusOK, rules)
+}
+
+// CreateUploadRule godoc
+// @Summary      Create an upload rule
+// @Description  Files uploaded without a folder_id are filed into the target folder of the first enabled
+// @Description  rule that matches: by extension list, MIME type list ("image/*" allowed) or name glob.
+// @Tags         upload-rules
+// @Accept       json
+// @Produce      json
+// @Param        body body     UploadRuleRequest true "Rule"
+// @Success      201  {object} respond.Envelope{data=model.UploadRule}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Failure      422  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-rules [post]
+func (h *UploadRuleHandler) CreateUploadRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req UploadRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+	if req.Name == nil || req.MatchType == nil || req.Pattern == nil || req.TargetFolderID == nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "name, match_type, pattern and target_folder_id are required"})
+		return
+	}
+
+	rule := &model.UploadRule{UserID: userID, Enabled: true}
+	if !h.apply(w, r, rule, &req) {
+		return
+	}
+
+	existing, err := h.ruleRepo.ListByUser(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create upload rule"})
+		return
+	}
+	if len(existing) >= maxUploadRules {
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{
+			Error: "too_many_rules", Message: fmt.Sprintf("at most %d upload rules are allowed", maxUploadRules),
+		})
+		return
+	}
+
+	created, err := h.ruleRepo.Create(r.Context(), rule, req.Position)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create upload rule"})
+		return
+	}
+
+	logger.Info(r.Context(), "Upload rule created", map[string]interface{}{
+		"user_id": userID, "rule_id": created.ID, "match_type": created.MatchType, "target_folder_id": created.TargetFolderID,
+	})
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdateUploadRule godoc
+// @Summary      Update an upload rule
+// @Tags         upload-rules
+// @Accept       json
+// @Produce      json
+// @Param        id   path     int               true "Rule ID"
+// @Param        body body     UploadRuleRequest true "Fields to change"
+// @Success      200  {object} respond.Envelope{data=model.UploadRule}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-rules/{id} [patch]
+func (h *UploadRuleHandler) UpdateUploadRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid rule id"})
+		return
+	}
+
+	var req UploadRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid request body"})
+		return
+	}
+
+	rule, err := h.ruleRepo.FindByIDAndUserID(r.Context(), ruleID, userID)
+	if err != nil || rule == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "upload rule not found"})
+		return
+	}
+	if !h.apply(w, r, rule, &req) {
+		return
+	}
+	if req.Position != nil {
+		rule.Position = *req.Position
+	}
+
+	updated, err := h.ruleRepo.Update(r.Context(), rule)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to update upload rule"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteUploadRule godoc
+// @Summary      Delete an upload rule
+// @Tags         upload-rules
+// @Param        id  path     int true "Rule ID"
+// @Success      204
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /upload-rules/{id} [delete]
+func (h *UploadRuleHandler) DeleteUploadRule(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid rule id"})
+		return
+	}
+
+	removed, err := h.ruleRepo.Delete(r.Context(), ruleID, userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to delete upload rule"})
+		return
+	}
+	if !removed {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "upload rule not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply validates the fields set in req and copies them onto rule. Writes the error
+// response and returns false if any is invalid.
+func (h *UploadRuleHandler) apply(w http.ResponseWriter, r *http.Request, rule *model.UploadRule, req *UploadRuleRequest) bool {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 100 {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "name must be 1-100 characters"})
+			return false
+		}
+		rule.Name = name
+	}
+	if req.MatchType != nil {
+		rule.MatchType = *req.MatchType
+	}
+	if req.Pattern != nil {
+		rule.Pattern = strings.TrimSpace(*req.Pattern)
+	}
+	if err := organize.Validate(rule.MatchType, rule.Pattern); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return false
+	}
+	if req.TargetFolderID != nil {
+		folder, err := h.folderRepo.FindByIDAndUserID(r.Context(), *req.TargetFolderID, rule.UserID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to look up folder"})
+			return false
+		}
+		if folder == nil {
+			writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "folder_not_found", Message: "target folder not found"})
+			return false
+		}
+		rule.TargetFolderID = folder.ID
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return true
+}
@@ -0,0 +1,557 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/organize"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// CreateUploadSessionRequest is the payload for POST /uploads.
+type CreateUploadSessionRequest struct {
+	FileName string `json:"file_name" example:"holiday.mp4"`
+	Size     int64  `json:"size"      example:"734003200"`
+	FolderID *int64 `json:"folder_id" example:"12"` // null = root, or the upload rules pick a folder
+}
+
+// ReceivedRange is a contiguous run of bytes the server already holds.
+type ReceivedRange struct {
+	Offset int64 `json:"offset" example:"0"`
+	Length int64 `json:"length" example:"33554432"`
+}
+
+// UploadManifest describes an upload session and which of its chunks have arrived.
+// Chunk i starts at byte i*chunk_size; a client resuming the upload only needs to
+// send missing_chunks, then finalize once complete.
+type UploadManifest struct {
+	*model.UploadSession
+	ChunkCount    int             `json:"chunk_count"    example:"22"`
+	ReceivedBytes int64           `json:"received_bytes" example:"33554432"`
+	Received      []ReceivedRange `json:"received"`
+	MissingChunks []int           `json:"missing_chunks"`
+	Complete      bool            `json:"complete"`
+}
+
+// newUploadManifest builds the manifest of s from its received chunk indexes (ascending).
+func newUploadManifest(s *model.UploadSession, received []int) UploadManifest {
+	m := UploadManifest{
+		UploadSession: s,
+		ChunkCount:    s.ChunkCount(),
+		Received:      []ReceivedRange{},
+		MissingChunks: []int{},
+	}
+	if s.FinalizedAt != nil {
+		// The chunks went into the file.
+		m.ReceivedBytes, m.Complete = s.TotalSize, true
+		if s.TotalSize > 0 {
+			m.Received = append(m.Received, ReceivedRange{Offset: 0, Length: s.TotalSize})
+		}
+		return m
+	}
+
+	next := 0
+	for _, i := range received {
+		for ; next < i; next++ {
+			m.MissingChunks = append(m.MissingChunks, next)
+		}
+		next = i + 1
+
+		offset, length := s.ChunkOffset(i), s.ChunkLength(i)
+		m.ReceivedBytes += length
+		if n := len(m.Received); n > 0 && m.Received[n-1].Offset+m.Received[n-1].Length == offset {
+			m.Received[n-1].Length += length
+			continue
+		}
+		m.Received = append(m.Received, ReceivedRange{Offset: offset, Length: length})
+	}
+	for ; next < m.ChunkCount; next++ {
+		m.MissingChunks = append(m.MissingChunks, next)
+	}
+	m.Complete = len(m.MissingChunks) == 0
+	return m
+}
+
+// findUploadSession loads the caller's upload session named by the {id} URL parameter,
+// writing an error response if there is none.
+func (h *UploadHandler) findUploadSession(w http.ResponseWriter, r *http.Request) (*model.UploadSession, bool) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return nil, false
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid upload session id"})
+		return nil, false
+	}
+
+	s, err := h.sessionRepo.FindByIDAndUserID(r.Context(), sessionID, userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to look up upload session"})
+		return nil, false
+	}
+	if s == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "upload_session_not_found", Message: "upload session not found or expired"})
+		return nil, false
+	}
+	return s, true
+}
+
+// writeUploadManifest responds with the current manifest of s, and in Upload-Offset
+// how many bytes from the start of the file have arrived without a gap.
+func (h *UploadHandler) writeUploadManifest(w http.ResponseWriter, r *http.Request, status int, s *model.UploadSession) {
+	received, err := h.sessionRepo.ListChunks(r.Context(), s.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list received chunks"})
+		return
+	}
+	m := newUploadManifest(s, received)
+	contiguous := int64(0)
+	if len(m.Received) > 0 && m.Received[0].Offset == 0 {
+		contiguous = m.Received[0].Length
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(contiguous, 10))
+	writeJSON(w, status, m)
+}
+
+// CreateUploadSession godoc
+// @Summary      Start a resumable upload
+// @Description  Opens an upload session for a file of the given size. The server picks chunk_size; send the
+// @Description  chunks with PUT /uploads/{id}/chunks/{index} in any order and over as many requests as needed.
+// @Description  Sessions that are not finished within UPLOAD_SESSION_TTL_HOURS are discarded.
+// @Tags         uploads
+// @Accept       json
+// @Produce      json
+// @Param        body body     CreateUploadSessionRequest true "File to upload"
+// @Success      201  {object} respond.Envelope{data=UploadManifest}
+// @Failure      400  {object} ErrorResponse
+// @Failure      401  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse "folder_id not found or not owned by the user"
+// @Failure      413  {object} ErrorResponse "Larger than UPLOAD_MAX_FILE_MB or the plan's max_file_bytes"
+// @Failure      415  {object} ErrorResponse "File type not allowed by UPLOAD_ALLOWED_TYPES / UPLOAD_DENIED_TYPES"
+// @Failure      500  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /uploads [post]
+func (h *UploadHandler) CreateUploadSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CreateUploadSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid JSON body"})
+		return
+	}
+	if req.FileName == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "file_name is required"})
+		return
+	}
+	if req.Size < 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "size must not be negative"})
+		return
+	}
+	if !h.requireOwnFolder(w, r, userID, req.FolderID) {
+		return
+	}
+
+	mimeType := mime.TypeByExtension(filepath.Ext(req.FileName))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if status, resp := h.policy.check(req.FileName, mimeType, req.Size); status != 0 {
+		logger.Warn(r.Context(), "Upload session refused by upload policy", map[string]interface{}{
+			"user_id": userID, "file_name": req.FileName, "mime_type": mimeType, "file_size": req.Size,
+		})
+		writeJSON(w, status, resp)
+		return
+	}
+
+	if err := h.files.CheckUpload(r.Context(), userID, req.Size); err != nil {
+		if !writePlanError(w, err) {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check plan limits"})
+		}
+		return
+	}
+
+	s, err := h.sessionRepo.Create(r.Context(), userID, req.FolderID, req.FileName, mimeType, req.Size,
+		h.processor.BlockSizeFor(req.Size), time.Now().Add(h.sessionTTL))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to create upload session"})
+		return
+	}
+
+	logger.Info(r.Context(), "Upload session created", map[string]interface{}{
+		"user_id": userID, "session_id": s.ID, "file_name": s.FileName, "file_size": s.TotalSize, "chunk_size": s.ChunkSize,
+	})
+	writeJSON(w, http.StatusCreated, newUploadManifest(s, nil))
+}
+
+// GetUploadSession godoc
+// @Summary      Get an upload session's manifest
+// @Description  Returns which byte ranges of the file were already received and which chunks are still
+// @Description  missing, so a client resuming after a restart only re-sends what the server lacks.
+// @Tags         uploads
+// @Produce      json
+// @Param        id  path     int true "Upload session ID"
+// @Success      200 {object} respond.Envelope{data=UploadManifest}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse "Unknown or expired upload session"
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /uploads/{id} [get]
+func (h *UploadHandler) GetUploadSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.findUploadSession(w, r)
+	if !ok {
+		return
+	}
+	h.writeUploadManifest(w, r, http.StatusOK, s)
+}
+
+// PutUploadChunk godoc
+// @Summary      Upload one chunk of a resumable upload
+// @Description  The raw request body is chunk {index}: exactly chunk_size bytes starting at index*chunk_size,
+// @Description  or the remainder for the last chunk. Re-sending a chunk that already arrived is harmless.
+// @Tags         uploads
+// @Accept       application/octet-stream
+// @Produce      json
+// @Param        id    path     int true "Upload session ID"
+// @Param        index path     int true "Chunk index"
+// @Success      200   {object} respond.Envelope{data=UploadManifest}
+// @Failure      400   {object} ErrorResponse "Bad chunk index or body length"
+// @Failure      401   {object} ErrorResponse
+// @Failure      404   {object} ErrorResponse "Unknown or expired upload session"
+// @Failure      409   {object} ErrorResponse "Session already finalized"
+// @Failure      500   {object} ErrorResponse
+// @Failure      503   {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Security     BearerAuth
+// @Router       /uploads/{id}/chunks/{index} [put]
+func (h *UploadHandler) PutUploadChunk(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.findUploadSession(w, r)
+	if !ok {
+		return
+	}
+
+	if s.FinalizedAt != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "upload_finalized", Message: "upload session is already finalized"})
+		return
+	}
+
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	if err != nil || index < 0 || index >= s.ChunkCount() {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid chunk index"})
+		return
+	}
+	h.storeChunk(w, r, s, index)
+}
+
+// PatchUploadSession godoc
+// @Summary      Upload the chunk at a byte offset (tus-style)
+// @Description  Same as PUT /uploads/{id}/chunks/{index} for clients that address data by offset, as in the
+// @Description  tus protocol: Upload-Offset must be a multiple of chunk_size and the body is the chunk that
+// @Description  starts there. The Upload-Offset response header (also on GET) is the number of bytes the
+// @Description  server holds from the start of the file without a gap, where a sequential client resumes.
+// @Tags         uploads
+// @Accept       application/offset+octet-stream
+// @Produce      json
+// @Param        id            path     int true "Upload session ID"
+// @Param        Upload-Offset header   int true "Byte offset of the chunk"
+// @Success      200           {object} respond.Envelope{data=UploadManifest}
+// @Failure      400           {object} ErrorResponse "Offset not on a chunk boundary, or bad body length"
+// @Failure      401           {object} ErrorResponse
+// @Failure      404           {object} ErrorResponse "Unknown or expired upload session"
+// @Failure      409           {object} ErrorResponse "Session already finalized"
+// @Failure      500           {object} ErrorResponse
+// @Failure      503           {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Security     BearerAuth
+// @Router       /uploads/{id} [patch]
+func (h *UploadHandler) PatchUploadSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.findUploadSession(w, r)
+	if !ok {
+		return
+	}
+
+	if s.FinalizedAt != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "upload_finalized", Message: "upload session is already finalized"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 || offset%int64(s.ChunkSize) != 0 || offset >= s.TotalSize {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error: "bad_offset", Message: "Upload-Offset must be a multiple of chunk_size inside the file",
+		})
+		return
+	}
+	h.storeChunk(w, r, s, int(offset/int64(s.ChunkSize)))
+}
+
+// storeChunk reads chunk index of s from the request body, stores it as a block and
+// responds with the updated manifest.
+func (h *UploadHandler) storeChunk(w http.ResponseWriter, r *http.Request, s *model.UploadSession, index int) {
+	length := s.ChunkLength(index)
+	if r.ContentLength >= 0 && r.ContentLength != length {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error:   "bad_chunk_length",
+			Message: "chunk " + strconv.Itoa(index) + " must be exactly " + strconv.FormatInt(length, 10) + " bytes",
+		})
+		return
+	}
+
+	release, ok := h.acquireSlot(w, r, s.UserID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Read one byte past the chunk so an overlong body is caught before anything is stored.
+	buf := make([]byte, length+1)
+	n, err := io.ReadFull(r.Body, buf)
+	if int64(n) != length || err == nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{
+			Error:   "bad_chunk_length",
+			Message: "chunk " + strconv.Itoa(index) + " must be exactly " + strconv.FormatInt(length, 10) + " bytes",
+		})
+		return
+	}
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer ctxCancel()
+
+	// Propagate request context values to the new context
+	ctx = logger.WithRequestID(ctx, logger.GetRequestID(r.Context()))
+	ctx = logger.WithMethod(ctx, logger.GetMethod(r.Context()))
+	ctx = logger.WithPath(ctx, logger.GetPath(r.Context()))
+
+	// chunk_size is the session's block size, so each chunk becomes exactly one block.
+	blockIDs, stats, err := h.processor.Process(ctx, s.UserID, bytes.NewReader(buf[:length]), block.Fixed(s.ChunkSize), s.MimeType)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Upload chunk block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: err.Error()})
+		return
+	}
+
+	stored, err := h.sessionRepo.PutChunk(ctx, s.ID, index, blockIDs[0], length, stats.BlocksNew == 1, stats.UploadedBytes)
+	if errors.Is(err, repository.ErrUploadSessionNotFound) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "upload_session_not_found", Message: "upload session not found or expired"})
+		return
+	}
+	if errors.Is(err, repository.ErrUploadSessionFinalized) {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "upload_finalized", Message: "upload session is already finalized"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save chunk"})
+		return
+	}
+
+	logger.Info(r.Context(), "Upload chunk received", map[string]interface{}{
+		"user_id": s.UserID, "session_id": s.ID, "chunk_index": index, "size_bytes": length, "duplicate": !stored,
+		"block_new": stats.BlocksNew == 1, "bytes_uploaded_to_s3": stats.UploadedBytes,
+	})
+	h.writeUploadManifest(w, r, http.StatusOK, s)
+}
+
+// FinalizeUploadSession godoc
+// @Summary      Finish a resumable upload
+// @Description  Turns a session whose chunks have all arrived into a file. Without a folder_id on the session,
+// @Description  the first matching upload rule picks the folder. Finalizing is idempotent: retrying (even
+// @Description  concurrently) returns the file created by the first call instead of creating another one.
+// @Description  The storage quota is checked again here, so files of sessions started in parallel cannot
+// @Description  together exceed it. With content-defined chunking (BLOCK_CHUNKING=fastcdc) the chunks are read
+// @Description  back and split again here, so the file deduplicates like one uploaded in one piece.
+// @Tags         uploads
+// @Produce      json
+// @Param        id  path     int true "Upload session ID"
+// @Success      201 {object} respond.Envelope{data=UploadResponse}
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse "Unknown or expired upload session"
+// @Failure      409 {object} ErrorResponse "Chunks are still missing; see GET /uploads/{id}"
+// @Failure      410 {object} ErrorResponse "The file created by this session was since deleted"
+// @Failure      413 {object} ErrorResponse "File exceeds the plan's size limit"
+// @Failure      500 {object} ErrorResponse
+// @Failure      503 {object} ErrorResponse "Server busy; retry after the Retry-After header"
+// @Failure      507 {object} ErrorResponse "Storage quota of the user's plan exceeded"
+// @Security     BearerAuth
+// @Router       /uploads/{id}/complete [post]
+func (h *UploadHandler) FinalizeUploadSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.findUploadSession(w, r)
+	if !ok {
+		return
+	}
+
+	// Pick the folder and quota up front; a replayed finalize ignores them and keeps
+	// the first result.
+	folderID, ruleID, quota := s.FolderID, (*int64)(nil), (*int64)(nil)
+	if s.FinalizedAt == nil {
+		var err error
+		if quota, err = h.files.UploadQuota(r.Context(), s.UserID, s.TotalSize); err != nil {
+			if !writePlanError(w, err) {
+				writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to check plan limits"})
+			}
+			return
+		}
+	}
+	if folderID == nil && s.FinalizedAt == nil {
+		rules, err := h.ruleRepo.ListByUser(r.Context(), s.UserID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to load upload rules"})
+			return
+		}
+		if rule := organize.Match(rules, s.FileName, s.MimeType); rule != nil {
+			folderID, ruleID = &rule.TargetFolderID, &rule.ID
+		}
+	}
+
+	// Chunks cut at fixed offsets would not deduplicate against content-defined blocks,
+	// so under content-defined chunking the file's content is split again.
+	var split *repository.SessionBlocks
+	if s.FinalizedAt == nil && h.processor.ChunkerFor(s.TotalSize).ContentDefined() {
+		if split, ok = h.splitUploadSession(w, r, s); !ok {
+			return
+		}
+	}
+
+	file, s, replayed, err := h.sessionRepo.Finalize(r.Context(), s.ID, s.UserID, folderID, ruleID, split, quota, requestClient(r), time.Now().Add(h.sessionTTL))
+	if split != nil && (err != nil || replayed) {
+		h.files.Release(context.WithoutCancel(r.Context()), split)
+	}
+	switch {
+	case errors.Is(err, repository.ErrUploadOverQuota):
+		writePlanError(w, &service.PlanLimitError{Err: service.ErrQuotaExceeded, Limit: *quota})
+		return
+	case errors.Is(err, repository.ErrUploadSessionNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "upload_session_not_found", Message: "upload session not found or expired"})
+		return
+	case errors.Is(err, repository.ErrUploadIncomplete):
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "upload_incomplete", Message: "some chunks have not been received yet"})
+		return
+	case errors.Is(err, repository.ErrFileNotFound):
+		writeJSON(w, http.StatusGone, ErrorResponse{Error: "file_gone", Message: "the file from this upload has been deleted"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to finalize upload"})
+		return
+	}
+
+	logger.Info(r.Context(), "Upload session finalized", map[string]interface{}{
+		"user_id": s.UserID, "session_id": s.ID, "file_id": file.ID, "total_size": file.TotalSize, "replayed": replayed,
+		"blocks_count": s.FileBlocks(), "blocks_new": s.BlocksNew, "blocks_deduped": s.BlocksDeduped(),
+		"logical_bytes": s.TotalSize, "bytes_uploaded_to_s3": s.UploadedBytes,
+	})
+
+	// The response is rebuilt from the session, so a replay answers exactly like the first call.
+	writeJSON(w, http.StatusCreated, UploadResponse{
+		FileID:        file.ID,
+		Name:          s.FileName,
+		MimeType:      s.MimeType,
+		Size:          s.TotalSize,
+		BlocksCount:   s.FileBlocks(),
+		BlocksNew:     s.BlocksNew,
+		BlocksDeduped: s.BlocksDeduped(),
+		BytesUploaded: s.UploadedBytes,
+		CreatedAt:     file.CreatedAt.Format(time.RFC3339),
+		FolderID:      s.FolderID,
+		RuleID:        s.RuleID,
+	})
+}
+
+// splitUploadSession splits the content of the complete session s again with the
+// configured chunking (see service.FileService.SplitUpload). It writes the error
+// response and returns false on failure.
+func (h *UploadHandler) splitUploadSession(w http.ResponseWriter, r *http.Request, s *model.UploadSession) (*repository.SessionBlocks, bool) {
+	chunks, err := h.sessionRepo.ListChunkBlocks(r.Context(), s.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list received chunks"})
+		return nil, false
+	}
+	if len(chunks) != s.ChunkCount() {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "upload_incomplete", Message: "some chunks have not been received yet"})
+		return nil, false
+	}
+
+	release, ok := h.acquireSlot(w, r, s.UserID)
+	if !ok {
+		return nil, false
+	}
+	defer release()
+
+	ctx, ctxCancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer ctxCancel()
+
+	// Propagate request context values to the new context
+	ctx = logger.WithRequestID(ctx, logger.GetRequestID(r.Context()))
+	ctx = logger.WithMethod(ctx, logger.GetMethod(r.Context()))
+	ctx = logger.WithPath(ctx, logger.GetPath(r.Context()))
+
+	split, err := h.files.SplitUpload(ctx, s, chunks)
+	if err != nil {
+		logger.ErrorLog(r.Context(), "Upload session block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: err.Error()})
+		return nil, false
+	}
+	return split, true
+}
+
+// AbortUploadSession godoc
+// @Summary      Abort a resumable upload
+// @Description  Discards the upload session and every chunk received so far.
+// @Tags         uploads
+// @Param        id  path int true "Upload session ID"
+// @Success      204
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse "Unknown upload session"
+// @Failure      500 {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /uploads/{id} [delete]
+func (h *UploadHandler) AbortUploadSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid upload session id"})
+		return
+	}
+
+	err = h.sessionRepo.Delete(r.Context(), sessionID, userID)
+	if errors.Is(err, repository.ErrUploadSessionNotFound) {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "upload_session_not_found", Message: "upload session not found or expired"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to abort upload session"})
+		return
+	}
+
+	logger.Info(r.Context(), "Upload session aborted", map[string]interface{}{"user_id": userID, "session_id": sessionID})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// Usage history windows, in days.
+const (
+	defaultUsageHistoryDays = 90
+	maxUsageHistoryDays     = 730
+)
+
+// UsageHandler serves current storage and egress usage and the daily storage usage
+// snapshots taken by the snapshot-storage-usage task.
+type UsageHandler struct {
+	snapshotRepo *repository.UsageSnapshotRepository
+	userRepo     *repository.UserRepository
+	plans        *service.PlanService
+	egress       *service.EgressService
+}
+
+func NewUsageHandler(snapshotRepo *repository.UsageSnapshotRepository, userRepo *repository.UserRepository, plans *service.PlanService, egress *service.EgressService) *UsageHandler {
+	return &UsageHandler{snapshotRepo: snapshotRepo, userRepo: userRepo, plans: plans, egress: egress}
+}
+
+// UsageResponse is a user's current storage and this month's egress. ChargedBytes is
+// the storage that counts towards their quota under the server's usage accounting.
+type UsageResponse struct {
+	UsedBytes    int64              `json:"used_bytes"    example:"1073741824"`
+	ChargedBytes int64              `json:"charged_bytes" example:"805306368"`
+	QuotaBytes   *int64             `json:"quota_bytes"   example:"10737418240"` // yours, else your plan's; null = unlimited
+	Accounting   string             `json:"accounting"    example:"logical"`
+	Egress       *model.EgressUsage `json:"egress"`
+}
+
+// GetMyUsage godoc
+// @Summary      Get your current usage
+// @Description  Storage in use and this month's (UTC) egress: bytes served from your files, through your
+// @Description  share links included, against your plan's monthly limit. Over the limit downloads are
+// @Description  counted only, throttled or refused, depending on the server's EGRESS_OVER_LIMIT.
+// @Description  charged_bytes is the storage counted towards your quota under the server's USAGE_ACCOUNTING;
+// @Description  quota_bytes is the quota an admin set for you, else your plan's (null = unlimited).
+// @Description  Also served at /auth/me/usage.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} respond.Envelope{data=UsageResponse}
+// @Security     BearerAuth
+// @Router       /me/usage [get]
+func (h *UsageHandler) GetMyUsage(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch user"})
+		return
+	}
+	charged, err := h.plans.ChargedBytes(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch storage usage"})
+		return
+	}
+	quota, err := h.plans.Quota(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch quota"})
+		return
+	}
+	egress, err := h.egress.Usage(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch egress usage"})
+		return
+	}
+	writeJSON(w, http.StatusOK, UsageResponse{
+		UsedBytes: user.UsedBytes, ChargedBytes: charged, QuotaBytes: quota, Accounting: h.plans.Accounting(), Egress: egress,
+	})
+}
+
+// UsagePoint is the usage on one day.
+type UsagePoint struct {
+	Day       string `json:"day" example:"2026-10-16"`
+	Bytes     int64  `json:"bytes"`
+	FileCount int64  `json:"file_count"`
+}
+
+// FolderUsageSeries is the usage history of one top-level folder's subtree. Name is
+// the folder's name at its latest snapshot.
+type FolderUsageSeries struct {
+	FolderID int64        `json:"folder_id"`
+	Name     string       `json:"name"`
+	Points   []UsagePoint `json:"points"`
+}
+
+// UsageHistory is a usage time series, one point per day a snapshot was taken. Total
+// counts every file, trash included (as used_bytes does); Folders count the files
+// currently in each top-level folder's subtree.
+type UsageHistory struct {
+	Since   string              `json:"since" example:"2026-07-18"`
+	Total   []UsagePoint        `json:"total"`
+	Folders []FolderUsageSeries `json:"folders,omitempty"`
+}
+
+// GetMyUsageHistory godoc
+// @Summary      Get your storage usage over time
+// @Description  Daily snapshots of your total usage and of each top-level folder, oldest first.
+// @Tags         auth
+// @Produce      json
+// @Param        days query    int false "Days of history (1-730, default 90)"
+// @Success      200  {object} respond.Envelope{data=UsageHistory}
+// @Failure      400  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /auth/me/usage/history [get]
+func (h *UsageHandler) GetMyUsageHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	h.writeUserHistory(w, r, userID)
+}
+
+// GetUserUsageHistory godoc
+// @Summary      Get a user's storage usage over time
+// @Description  Same as /auth/me/usage/history for any user.
+// @Tags         admin
+// @Produce      json
+// @Param        id   path     int true  "User ID"
+// @Param        days query    int false "Days of history (1-730, default 90)"
+// @Success      200  {object} respond.Envelope{data=UsageHistory}
+// @Failure      400  {object} ErrorResponse
+// @Failure      404  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/{id}/usage/history [get]
+func (h *UsageHandler) GetUserUsageHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseUserID(w, r)
+	if !ok {
+		return
+	}
+	if _, err := h.userRepo.FindByID(r.Context(), userID); err != nil {
+		writeRepoError(w, err, "user not found", "failed to fetch user")
+		return
+	}
+	h.writeUserHistory(w, r, userID)
+}
+
+// GetSystemUsageHistory godoc
+// @Summary      Get total storage usage over time
+// @Description  Daily sum of every user's total usage, oldest first.
+// @Tags         admin
+// @Produce      json
+// @Param        days query    int false "Days of history (1-730, default 90)"
+// @Success      200  {object} respond.Envelope{data=UsageHistory}
+// @Failure      400  {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/usage/history [get]
+func (h *UsageHandler) GetSystemUsageHistory(w http.ResponseWriter, r *http.Request) {
+	since, ok := usageHistorySince(w, r)
+	if !ok {
+		return
+	}
+
+	snapshots, err := h.snapshotRepo.ListTotals(r.Context(), since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch usage history"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newUsageHistory(since, snapshots))
+}
+
+func (h *UsageHandler) writeUserHistory(w http.ResponseWriter, r *http.Request, userID int64) {
+	since, ok := usageHistorySince(w, r)
+	if !ok {
+		return
+	}
+
+	snapshots, err := h.snapshotRepo.ListByUser(r.Context(), userID, since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch usage history"})
+		return
+	}
+	writeJSON(w, http.StatusOK, newUsageHistory(since, snapshots))
+}
+
+// usageHistorySince reads ?days= into the first day to include. Writes the error
+// response and returns false if it is invalid.
+func usageHistorySince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	days := defaultUsageHistoryDays
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > maxUsageHistoryDays {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{
+				Error: "bad_request", Message: fmt.Sprintf("days must be between 1 and %d", maxUsageHistoryDays),
+			})
+			return time.Time{}, false
+		}
+		days = n
+	}
+	return time.Now().UTC().AddDate(0, 0, 1-days), true
+}
+
+// newUsageHistory groups snapshots (oldest first) into the total and per-folder series.
+func newUsageHistory(since time.Time, snapshots []*model.UsageSnapshot) UsageHistory {
+	history := UsageHistory{Since: since.Format(time.DateOnly), Total: []UsagePoint{}}
+	byFolder := map[int64]int{}
+	for _, s := range snapshots {
+		point := UsagePoint{Day: s.Day.Format(time.DateOnly), Bytes: s.Bytes, FileCount: s.FileCount}
+		if s.FolderID == nil {
+			history.Total = append(history.Total, point)
+			continue
+		}
+		i, seen := byFolder[*s.FolderID]
+		if !seen {
+			i = len(history.Folders)
+			byFolder[*s.FolderID] = i
+			history.Folders = append(history.Folders, FolderUsageSeries{FolderID: *s.FolderID})
+		}
+		if s.FolderName != nil {
+			history.Folders[i].Name = *s.FolderName
+		}
+		history.Folders[i].Points = append(history.Folders[i].Points, point)
+	}
+	return history
+}
@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/audit"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/i18n"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/mailer"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// Bulk import limits.
+const (
+	maxImportRows  = 1000
+	maxImportBytes = 1 << 20
+)
+
+// UserImportHandler provisions accounts in bulk and exports all of them.
+type UserImportHandler struct {
+	userRepo      *repository.UserRepository
+	inviteRepo    *repository.UserInviteRepository
+	planRepo      *repository.PlanRepository
+	mail          *mailer.Mailer
+	publicBaseURL string
+	inviteTTL     time.Duration
+}
+
+func NewUserImportHandler(
+	userRepo *repository.UserRepository,
+	inviteRepo *repository.UserInviteRepository,
+	planRepo *repository.PlanRepository,
+	mail *mailer.Mailer,
+	publicBaseURL string,
+	inviteTTL time.Duration,
+) *UserImportHandler {
+	return &UserImportHandler{
+		userRepo:      userRepo,
+		inviteRepo:    inviteRepo,
+		planRepo:      planRepo,
+		mail:          mail,
+		publicBaseURL: publicBaseURL,
+		inviteTTL:     inviteTTL,
+	}
+}
+
+// UserImportRow is one account to provision. Role defaults to "user"; Plan names a
+// plan (its quota and limits apply), empty = the default plan.
+type UserImportRow struct {
+	Email string `json:"email" example:"alice@example.com"`
+	Role  string `json:"role"  example:"user"`
+	Plan  string `json:"plan"  example:"Team"`
+}
+
+// UserImportResult reports what happened to one row: the new user and their invite
+// link, or why it was skipped.
+type UserImportResult struct {
+	Row       int    `json:"row"` // 1-based, not counting a CSV header
+	Email     string `json:"email"`
+	UserID    int64  `json:"user_id,omitempty"`
+	InviteURL string `json:"invite_url,omitempty" example:"https://box.example.com/invite/3f9a..."`
+	Error     string `json:"error,omitempty"`
+}
+
+// UserImportResponse is returned by POST /admin/users/import.
+type UserImportResponse struct {
+	Created   int                `json:"created"`
+	Failed    int                `json:"failed"`
+	ExpiresAt time.Time          `json:"expires_at"` // when the invite links stop working
+	Results   []UserImportResult `json:"results"`
+}
+
+// ImportUsers godoc
+// @Summary      Provision users in bulk
+// @Description  Creates an account for each row, without a password, and returns an invite link per account through
+// @Description  which the user chooses one. Send a JSON array, or CSV with a header row naming the email, role and plan
+// @Description  columns. Rows are independent: one that fails (bad email, taken email, unknown plan) is reported and
+// @Description  the rest still go ahead. With send_email=true the invite links are also emailed to the users.
+// @Tags         admin
+// @Accept       json,text/csv
+// @Produce      json
+// @Param        body       body     []UserImportRow true  "Accounts to create (at most 1000)"
+// @Param        send_email query    bool            false "Email each new user their invite link"
+// @Success      200        {object} respond.Envelope{data=UserImportResponse}
+// @Failure      400        {object} ErrorResponse
+// @Failure      403        {object} ErrorResponse
+// @Failure      413        {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/import [post]
+func (h *UserImportHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := auth.GetUserID(r)
+	sendEmail := r.URL.Query().Get("send_email") == "true"
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	rows, err := parseImportRows(r)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxImportBytes))
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+	if len(rows) == 0 || len(rows) > maxImportRows {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("send 1-%d users", maxImportRows)})
+		return
+	}
+
+	plans, err := h.planRepo.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list plans"})
+		return
+	}
+	planIDs := make(map[string]int64, len(plans))
+	for _, p := range plans {
+		planIDs[strings.ToLower(p.Name)] = p.ID
+	}
+
+	resp := UserImportResponse{ExpiresAt: time.Now().Add(h.inviteTTL).UTC(), Results: make([]UserImportResult, 0, len(rows))}
+	baseURL := resolveBaseURL(h.publicBaseURL, r)
+	var created []int64
+	for i, row := range rows {
+		res := UserImportResult{Row: i + 1, Email: strings.TrimSpace(row.Email)}
+		res.UserID, res.InviteURL, res.Error = h.provision(r, row, res.Email, planIDs, adminID, resp.ExpiresAt, baseURL)
+		if res.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Created++
+			created = append(created, res.UserID)
+			if sendEmail {
+				h.sendInvite(r, res.Email, res.InviteURL)
+			}
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	audit.Describe(r.Context(), "users", "import", nil, map[string]interface{}{
+		"created_user_ids": created, "failed": resp.Failed, "emailed": sendEmail,
+	})
+	logger.Info(r.Context(), "Users imported", map[string]interface{}{
+		"admin_id": adminID, "created": resp.Created, "failed": resp.Failed, "emailed": sendEmail,
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// provision creates the account for one import row and returns its ID and invite URL,
+// or the reason it was skipped.
+func (h *UserImportHandler) provision(r *http.Request, row UserImportRow, email string, planIDs map[string]int64, adminID int64, expiresAt time.Time, baseURL string) (int64, string, string) {
+	if !emailRegex.MatchString(email) {
+		return 0, "", "invalid email format"
+	}
+	role := strings.ToLower(strings.TrimSpace(row.Role))
+	if role == "" {
+		role = model.RoleUser
+	}
+	if role != model.RoleUser && role != model.RoleAdmin {
+		return 0, "", "role must be user or admin"
+	}
+	var planID *int64
+	if name := strings.TrimSpace(row.Plan); name != "" {
+		id, ok := planIDs[strings.ToLower(name)]
+		if !ok {
+			return 0, "", fmt.Sprintf("unknown plan %q", name)
+		}
+		planID = &id
+	}
+
+	token, err := service.NewLinkToken()
+	if err != nil {
+		return 0, "", "failed to generate invite"
+	}
+	user, err := h.inviteRepo.CreateUser(r.Context(), email, role, planID, hashInviteToken(token), expiresAt, adminID)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailExists) {
+			return 0, "", "email already registered"
+		}
+		return 0, "", "failed to create user"
+	}
+	return user.ID, fmt.Sprintf("%s/invite/%s", baseURL, token), ""
+}
+
+// sendInvite emails an invite link. Failures are logged; the link is in the response anyway.
+func (h *UserImportHandler) sendInvite(r *http.Request, email, url string) {
+	err := h.mail.Send(r.Context(), email, i18n.DefaultLang, mailer.TemplateInvite, mailer.InviteData{
+		Name: email, InvitedBy: auth.GetUserEmail(r), URL: url, ExpiresHours: int(h.inviteTTL.Hours()),
+	})
+	if err != nil {
+		logger.Warn(r.Context(), "Failed to email invite", map[string]interface{}{"email": email, "error": err.Error()})
+	}
+}
+
+// parseImportRows reads the import body as CSV when its Content-Type says so, else as a JSON array.
+func parseImportRows(r *http.Request) ([]UserImportRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "text/csv" {
+		var rows []UserImportRow
+		if err := json.NewDecoder(r.Body).Decode(&rows); err != nil {
+			var tooBig *http.MaxBytesError
+			if errors.As(err, &tooBig) {
+				return nil, err
+			}
+			return nil, errors.New("body must be a JSON array of users")
+		}
+		return rows, nil
+	}
+
+	cr := csv.NewReader(r.Body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, csvError(err)
+	}
+	col := map[string]int{"email": -1, "role": -1, "plan": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := col[name]; ok {
+			col[name] = i
+		}
+	}
+	if col["email"] < 0 {
+		return nil, errors.New("the CSV header must have an email column")
+	}
+	field := func(rec []string, name string) string {
+		if i := col[name]; i >= 0 && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+
+	var rows []UserImportRow
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("send 1-%d users", maxImportRows)
+		}
+		rows = append(rows, UserImportRow{Email: field(rec, "email"), Role: field(rec, "role"), Plan: field(rec, "plan")})
+	}
+}
+
+// csvError keeps a body size error recognisable and describes anything else as bad CSV.
+func csvError(err error) error {
+	var tooBig *http.MaxBytesError
+	if errors.As(err, &tooBig) {
+		return err
+	}
+	return fmt.Errorf("invalid CSV: %s", err.Error())
+}
+
+// hashInviteToken returns the hash invites are stored under.
+func hashInviteToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// ExportUsers godoc
+// @Summary      Export all users
+// @Description  Every account with its role, effective plan and quota, storage used, this month's egress and whether
+// @Description  an invite is still pending, as a JSON array or, with format=csv, a CSV download.
+// @Tags         admin
+// @Produce      json,text/csv
+// @Param        format query    string false "json (default) or csv"
+// @Success      200    {object}  respond.Envelope{data=[]model.UserExport}
+// @Failure      400    {object} ErrorResponse
+// @Failure      403    {object} ErrorResponse
+// @Security     BearerAuth
+// @Router       /admin/users/export [get]
+func (h *UserImportHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "format must be json or csv"})
+		return
+	}
+
+	users, err := h.userRepo.Export(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to export users"})
+		return
+	}
+	if users == nil {
+		users = []*model.UserExport{}
+	}
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, users)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "email", "role", "plan_id", "plan_name", "quota_bytes", "used_bytes", "egress_bytes", "invite_pending", "created_at", "deactivated_at"})
+	for _, u := range users {
+		cw.Write([]string{
+			strconv.FormatInt(u.ID, 10), u.Email, u.Role, optionalInt(u.PlanID), optionalString(u.PlanName), optionalInt(u.QuotaBytes),
+			strconv.FormatInt(u.UsedBytes, 10), strconv.FormatInt(u.EgressBytes, 10), strconv.FormatBool(u.InvitePending),
+			u.CreatedAt.UTC().Format(time.RFC3339), optionalTime(u.DeactivatedAt),
+		})
+	}
+	cw.Flush()
+}
+
+func optionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func optionalString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func optionalTime(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.UTC().Format(time.RFC3339)
+}
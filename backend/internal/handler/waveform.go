@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/naratel/naratel-box/backend/internal/audio"
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// waveformRetryAfter is the Retry-After (seconds) sent while a waveform is computed.
+const waveformRetryAfter = "3"
+
+// WaveformHandler serves the duration, bitrate and waveform outline of audio files,
+// computed in the background by the analyze-audio task.
+type WaveformHandler struct {
+	fileRepo  *repository.FileRepository
+	blockRepo *repository.BlockRepository
+	shareRepo *repository.ShareLinkRepository
+	waveRepo  *repository.WaveformRepository
+	scheduler *jobs.Scheduler
+	enabled   bool  // false = no analyzer; only finished waveforms are served
+	maxBytes  int64 // largest file analysed
+}
+
+func NewWaveformHandler(fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository, shareRepo *repository.ShareLinkRepository,
+	waveRepo *repository.WaveformRepository, scheduler *jobs.Scheduler, enabled bool, maxBytes int64) *WaveformHandler {
+	return &WaveformHandler{
+		fileRepo: fileRepo, blockRepo: blockRepo, shareRepo: shareRepo, waveRepo: waveRepo,
+		scheduler: scheduler, enabled: enabled, maxBytes: maxBytes,
+	}
+}
+
+// GetWaveform godoc
+// @Summary      Get an audio file's duration and waveform
+// @Description  Returns the duration, bitrate, sample rate, channels, codec and a waveform outline of an audio
+// @Description  file: peaks holds up to 1000 values from 0 to 1, the loudest sample of each equal slice of the
+// @Description  recording, for drawing a seekable waveform. Audio files are analysed in the background after
+// @Description  upload; until the analysis of the current content is done this returns 202 with status
+// @Description  pending or running and a Retry-After header. A failed analysis returns status failed and error.
+// @Tags         files
+// @Produce      json
+// @Param        id  path     int true "File ID"
+// @Success      200 {object} respond.Envelope{data=model.Waveform}
+// @Success      202 {object} respond.Envelope{data=model.Waveform} "Analysis queued or running"
+// @Success      304 "Not Modified"
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      413 {object} ErrorResponse "File too large to analyse"
+// @Failure      415 {object} ErrorResponse "Not an audio file"
+// @Failure      501 {object} ErrorResponse "Audio analysis is not available on this server"
+// @Security     BearerAuth
+// @Router       /files/{id}/waveform [get]
+func (h *WaveformHandler) GetWaveform(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	fileID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid file id"})
+		return
+	}
+	file, err := h.fileRepo.FindByIDAndUserID(r.Context(), fileID, userID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+	h.serve(w, r, file)
+}
+
+// SharedWaveform godoc
+// @Summary      Get the duration and waveform of a shared audio file
+// @Description  Same as GET /files/{id}/waveform, for the file behind a share link, so the shared player can
+// @Description  show the length and seek. The link's checks (state, expiry, password) apply.
+// @Tags         share
+// @Produce      json
+// @Param        token            path   string true  "Share token"
+// @Param        X-Share-Password header string false "Link password"
+// @Success      200 {object} respond.Envelope{data=model.Waveform}
+// @Success      202 {object} respond.Envelope{data=model.Waveform} "Analysis queued or running"
+// @Success      304 "Not Modified"
+// @Failure      401 {object} ErrorResponse
+// @Failure      403 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      410 {object} ErrorResponse
+// @Failure      413 {object} ErrorResponse "File too large to analyse"
+// @Failure      415 {object} ErrorResponse "Not an audio file"
+// @Failure      451 {object} ErrorResponse
+// @Failure      501 {object} ErrorResponse "Audio analysis is not available on this server"
+// @Router       /share/{token}/waveform [get]
+func (h *WaveformHandler) SharedWaveform(w http.ResponseWriter, r *http.Request) {
+	link, ok := openShareLink(w, r, h.shareRepo)
+	if !ok {
+		return
+	}
+	// Public share: the link owner's scope stands in for the (absent) user.
+	file, err := h.fileRepo.FindByID(r.Context(), repository.ForUser(link.UserID), link.FileID)
+	if err != nil {
+		writeRepoError(w, err, "file not found", "failed to fetch file")
+		return
+	}
+	h.serve(w, r, file)
+}
+
+// serve answers with the waveform of the file's current content, queueing an
+// analysis (and waking the task) if there is none yet or the content changed.
+func (h *WaveformHandler) serve(w http.ResponseWriter, r *http.Request, file *model.File) {
+	if !audio.Supported(file.MimeType, file.Name) {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "not_audio", Message: "only audio files have a waveform"})
+		return
+	}
+
+	blocks, err := h.blockRepo.FindByFileID(r.Context(), repository.ForUser(file.UserID), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+		return
+	}
+	etag := model.BlocksETag(blocks)
+
+	wf, err := h.waveRepo.FindByFileID(r.Context(), file.ID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch waveform"})
+		return
+	}
+	current := wf != nil && wf.ContentETag != nil && *wf.ContentETag == etag
+	if current && wf.Status == model.WaveformReady {
+		tag := `"` + strings.Trim(etag, `"`) + `-w"`
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeJSON(w, http.StatusOK, wf)
+		return
+	}
+	if current && wf.Status == model.WaveformFailed {
+		writeJSON(w, http.StatusOK, wf)
+		return
+	}
+
+	if !h.enabled {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: "audio_analysis_unavailable", Message: "audio analysis is not available on this server"})
+		return
+	}
+	if file.TotalSize > h.maxBytes {
+		writeJSON(w, http.StatusRequestEntityTooLarge, ErrorResponse{Error: "file_too_large", Message: "the file is too large to analyse"})
+		return
+	}
+
+	// Missing, or made from earlier content: queue it. A queued or running analysis
+	// reads the content when it starts, so it is left to finish.
+	if wf == nil || wf.Status == model.WaveformReady || wf.Status == model.WaveformFailed {
+		if _, err := h.waveRepo.Request(r.Context(), file.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to queue analysis"})
+			return
+		}
+		h.scheduler.Trigger(jobs.TaskAnalyzeAudio)
+		wf = &model.Waveform{FileID: file.ID, Status: model.WaveformPending, UpdatedAt: time.Now()}
+	} else {
+		wf = &model.Waveform{FileID: file.ID, Status: wf.Status, UpdatedAt: wf.UpdatedAt}
+	}
+	w.Header().Set("Retry-After", waveformRetryAfter)
+	writeJSON(w, http.StatusAccepted, wf)
+}
@@ -0,0 +1,709 @@
+package handler
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/auth"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/service"
+)
+
+// WebDAVMethods are the request methods WebDAVHandler answers beyond standard HTTP. The
+// router must know them (chi.RegisterMethod) before the routes are added.
+var WebDAVMethods = []string{"PROPFIND", "MKCOL", "MOVE", "LOCK", "UNLOCK"}
+
+const davAllow = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, MKCOL, MOVE, LOCK, UNLOCK"
+
+// errDAVNoParent means a path goes through a folder that does not exist.
+var errDAVNoParent = errors.New("parent folder does not exist")
+
+// WebDAVHandler serves the user's folders and files over WebDAV below prefix, so OS file
+// managers and tools like rclone can mount the box. Requests are translated into the
+// same operations as the REST API: downloads stream blocks with egress metering,
+// uploads go through the upload policy, slots and plan checks, deletes move files to
+// the trash. Paths are resolved by name from the root; where names repeat in a folder
+// the oldest folder, then the oldest file, is the one addressed. Mounts are not shown.
+// Exclusive write locks are enforced (class 2), see davLocks.
+type WebDAVHandler struct {
+	prefix    string
+	uploads   *UploadHandler
+	downloads *DownloadHandler
+	folders   *FolderHandler
+	locks     *davLocks
+}
+
+func NewWebDAVHandler(prefix string, uploads *UploadHandler, downloads *DownloadHandler, folders *FolderHandler) *WebDAVHandler {
+	return &WebDAVHandler{
+		prefix:    strings.TrimSuffix(prefix, "/"),
+		uploads:   uploads,
+		downloads: downloads,
+		folders:   folders,
+		locks:     newDAVLocks(),
+	}
+}
+
+// davTarget is what a path names: the root, a folder, a file or nothing yet, with the
+// folder holding its last name.
+type davTarget struct {
+	root     bool
+	parentID *int64 // nil = root
+	name     string
+	folder   *model.Folder
+	file     *model.File
+}
+
+func (t *davTarget) exists() bool { return t.root || t.folder != nil || t.file != nil }
+
+// ServeHTTP dispatches a WebDAV request for the authenticated user.
+func (h *WebDAVHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.GetUserID(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+	names, ok := h.davPath(r.URL.Path)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "no such file or folder"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 2")
+		w.Header().Set("MS-Author-Via", "DAV")
+		w.Header().Set("Allow", davAllow)
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		h.propfind(w, r, userID, names)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, userID, names)
+	case http.MethodPut:
+		h.put(w, r, userID, names)
+	case http.MethodDelete:
+		h.delete(w, r, userID, names)
+	case "MKCOL":
+		h.mkcol(w, r, userID, names)
+	case "MOVE":
+		h.move(w, r, userID, names)
+	case "LOCK":
+		h.lock(w, r, userID, names)
+	case "UNLOCK":
+		h.unlock(w, r, userID, names)
+	default:
+		w.Header().Set("Allow", davAllow)
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method_not_allowed", Message: "method not supported over WebDAV"})
+	}
+}
+
+// davPath splits a request path below the prefix into names; none is the root.
+func (h *WebDAVHandler) davPath(urlPath string) ([]string, bool) {
+	rest, ok := strings.CutPrefix(urlPath, h.prefix)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return nil, false
+	}
+	var names []string
+	for _, name := range strings.Split(rest, "/") {
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			return nil, false
+		}
+		names = append(names, name)
+	}
+	return names, true
+}
+
+// davKey is the path of names that locks are taken on: "/" for the root.
+func davKey(names []string) string {
+	return "/" + strings.Join(names, "/")
+}
+
+// checkLocks writes a 423 and returns false unless r submits the token of every lock
+// on names (with deep, also those on anything below it).
+func (h *WebDAVHandler) checkLocks(w http.ResponseWriter, r *http.Request, userID int64, names []string, deep bool) bool {
+	if h.locks.allowed(userID, davKey(names), deep, davSubmittedTokens(r)) {
+		return true
+	}
+	writeJSON(w, http.StatusLocked, ErrorResponse{Error: "locked", Message: "the resource is locked; submit the lock token in an If header"})
+	return false
+}
+
+// href is the escaped URL path of names; folders end in a slash.
+func (h *WebDAVHandler) href(names []string, folder bool) string {
+	p := h.prefix + "/" + strings.Join(names, "/")
+	if folder && len(names) > 0 {
+		p += "/"
+	}
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// resolve walks names from the user's root. A folder wins over a file of the same name.
+// It returns errDAVNoParent if a folder on the way does not exist.
+func (h *WebDAVHandler) resolve(ctx context.Context, userID int64, names []string) (*davTarget, error) {
+	t := &davTarget{}
+	if len(names) == 0 {
+		t.root = true
+		return t, nil
+	}
+	for _, name := range names[:len(names)-1] {
+		folder, err := h.folders.folderRepo.FindByName(ctx, userID, t.parentID, name)
+		if err != nil {
+			return nil, err
+		}
+		if folder == nil {
+			return nil, errDAVNoParent
+		}
+		t.parentID = &folder.ID
+	}
+
+	t.name = names[len(names)-1]
+	folder, err := h.folders.folderRepo.FindByName(ctx, userID, t.parentID, t.name)
+	if err != nil {
+		return nil, err
+	}
+	if folder != nil {
+		t.folder = folder
+		return t, nil
+	}
+	t.file, err = h.uploads.fileRepo.FindByName(ctx, userID, t.parentID, t.name)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// resolveExisting resolves names, writing a 404 if nothing is there.
+func (h *WebDAVHandler) resolveExisting(w http.ResponseWriter, r *http.Request, userID int64, names []string) (*davTarget, bool) {
+	t, err := h.resolve(r.Context(), userID, names)
+	if err != nil && !errors.Is(err, errDAVNoParent) {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to resolve path"})
+		return nil, false
+	}
+	if err != nil || !t.exists() {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "not_found", Message: "no such file or folder"})
+		return nil, false
+	}
+	return t, true
+}
+
+// resolveNew resolves names as somewhere to create an item, writing a 409 if the
+// folder it would go in does not exist.
+func (h *WebDAVHandler) resolveNew(w http.ResponseWriter, r *http.Request, userID int64, names []string) (*davTarget, bool) {
+	t, err := h.resolve(r.Context(), userID, names)
+	if errors.Is(err, errDAVNoParent) {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "conflict", Message: "parent folder does not exist"})
+		return nil, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to resolve path"})
+		return nil, false
+	}
+	return t, true
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	XMLNS     string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string          `xml:"D:displayname"`
+	ResourceType  davResourceType `xml:"D:resourcetype"`
+	ContentLength *int64          `xml:"D:getcontentlength,omitempty"`
+	ContentType   string          `xml:"D:getcontenttype,omitempty"`
+	ETag          string          `xml:"D:getetag,omitempty"`
+	CreationDate  string          `xml:"D:creationdate,omitempty"`
+	LastModified  string          `xml:"D:getlastmodified,omitempty"`
+}
+
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection"`
+}
+
+func davFolderResponse(href, name string, folder *model.Folder) davResponse {
+	prop := davProp{DisplayName: name, ResourceType: davResourceType{Collection: &struct{}{}}}
+	if folder != nil {
+		prop.CreationDate = folder.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+		prop.LastModified = folder.UpdatedAt.UTC().Format(http.TimeFormat)
+	}
+	return davResponse{Href: href, Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"}}
+}
+
+func davFileResponse(href string, file *model.File, etag string) davResponse {
+	size := file.TotalSize
+	return davResponse{Href: href, Propstat: davPropstat{Prop: davProp{
+		DisplayName:   file.Name,
+		ContentLength: &size,
+		ContentType:   file.MimeType,
+		ETag:          etag,
+		CreationDate:  file.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		LastModified:  file.UpdatedAt.UTC().Format(http.TimeFormat),
+	}, Status: "HTTP/1.1 200 OK"}}
+}
+
+// propfind lists a folder (Depth: 1) or describes one item (Depth: 0). Every property
+// known is returned whatever the body asks for. Depth: infinity, the default, is
+// refused rather than walking the whole tree in one response.
+func (h *WebDAVHandler) propfind(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	depth := r.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, xml.Header+`<D:error xmlns:D="DAV:"><D:propfind-finite-depth/></D:error>`)
+		return
+	}
+	t, ok := h.resolveExisting(w, r, userID, names)
+	if !ok {
+		return
+	}
+
+	if t.file != nil {
+		blocks, err := h.downloads.blockRepo.FindByFileID(r.Context(), repository.ForUser(userID), t.file.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to fetch blocks"})
+			return
+		}
+		writeMultistatus(w, r, []davResponse{davFileResponse(h.href(names, false), t.file, model.BlocksETag(blocks))})
+		return
+	}
+	responses := []davResponse{davFolderResponse(h.href(names, true), t.name, t.folder)}
+	if depth == "1" {
+		var parentID *int64
+		if t.folder != nil {
+			parentID = &t.folder.ID
+		}
+		folders, err := h.folders.folderRepo.ListByParent(r.Context(), userID, parentID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list folders"})
+			return
+		}
+		files, err := h.uploads.fileRepo.ListByFolder(r.Context(), userID, parentID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to list files"})
+			return
+		}
+		child := append(names[:len(names):len(names)], "")
+		for _, f := range folders {
+			child[len(names)] = f.Name
+			responses = append(responses, davFolderResponse(h.href(child, true), f.Name, f))
+		}
+		for _, f := range files {
+			child[len(names)] = f.Name
+			responses = append(responses, davFileResponse(h.href(child, false), f.File, f.ETag))
+		}
+	}
+	writeMultistatus(w, r, responses)
+}
+
+func writeMultistatus(w http.ResponseWriter, r *http.Request, responses []davResponse) {
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(davMultistatus{XMLNS: "DAV:", Responses: responses}); err != nil {
+		logger.Warn(r.Context(), "Failed to write WebDAV multistatus", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// get downloads a file exactly like GET /files/{id}.
+func (h *WebDAVHandler) get(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	t, ok := h.resolveExisting(w, r, userID, names)
+	if !ok {
+		return
+	}
+	if t.file == nil {
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, MKCOL, MOVE, DELETE")
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method_not_allowed", Message: "folders cannot be downloaded"})
+		return
+	}
+	h.downloads.serveFile(w, r, userID, t.file)
+}
+
+// put stores the body as the file at the path. A file already there is replaced: the
+// new content is stored as a new file, which takes the name while the old one moves to
+// the trash. If-None-Match: * only creates the file if the name is free.
+func (h *WebDAVHandler) put(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	if !h.checkLocks(w, r, userID, names, false) {
+		return
+	}
+	t, ok := h.resolveNew(w, r, userID, names)
+	if !ok {
+		return
+	}
+	if t.root || t.folder != nil {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method_not_allowed", Message: "a folder has this name"})
+		return
+	}
+	ifNoneExists := strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+	if ifNoneExists && t.file != nil {
+		writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "file_exists", Message: "a file with this name already exists"})
+		return
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(t.name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if status, resp := h.uploads.policy.check(t.name, mimeType, r.ContentLength); status != 0 {
+		logger.Warn(r.Context(), "Upload refused by upload policy", map[string]interface{}{
+			"user_id": userID, "file_name": t.name, "mime_type": mimeType, "file_size": r.ContentLength,
+		})
+		writeJSON(w, status, resp)
+		return
+	}
+
+	release, ok := h.uploads.acquireSlot(w, r, userID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	body := r.Body
+	maxBytes := h.uploads.policy.limit(0)
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	client := requestClient(r)
+	file, stats, err := h.uploads.files.Store(r.Context(), service.StoreRequest{
+		UserID: userID, Name: t.name, MimeType: mimeType, Size: r.ContentLength,
+		FolderID: t.parentID, Client: client, Content: body, IfNoneExists: ifNoneExists,
+	})
+	if writePlanError(w, err) {
+		return
+	}
+	var (
+		tooLargeErr *http.MaxBytesError
+		contentErr  *service.ContentError
+	)
+	switch {
+	case errors.Is(err, repository.ErrFileExists):
+		writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "file_exists", Message: "a file with this name already exists"})
+		return
+	case errors.As(err, &tooLargeErr):
+		writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge(maxBytes))
+		return
+	case errors.As(err, &contentErr):
+		logger.ErrorLog(r.Context(), "WebDAV upload block processing failed", logger.ErrorDetails{
+			Code: "UPLOAD_PROCESS_ERR", Details: err.Error(),
+		})
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "upload_failed", Message: err.Error()})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to save file metadata"})
+		return
+	}
+
+	// Claiming the name after the fact also trashes a file a concurrent PUT stored
+	// under it, so the last upload wins and the name holds one file. The content is a
+	// new file, so it waits for its own scan verdict rather than inheriting the old one.
+	var replaced []int64
+	if !ifNoneExists {
+		if _, replaced, err = h.uploads.files.Rename(r.Context(), userID, file.ID, t.name, client, true); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to replace file"})
+			return
+		}
+	}
+
+	logger.Info(r.Context(), "File stored over WebDAV", map[string]interface{}{
+		"user_id": userID, "file_id": file.ID, "file_name": file.Name, "folder_id": file.FolderID,
+		"total_size": file.TotalSize, "replaced": replaced,
+		"blocks_new": stats.BlocksNew, "bytes_uploaded_to_s3": stats.UploadedBytes,
+	})
+	if t.file != nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// delete moves a file to the trash, or starts the same job as DELETE /folders/{id}
+// for a folder and answers 202 while it runs.
+func (h *WebDAVHandler) delete(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	if !h.checkLocks(w, r, userID, names, true) {
+		return
+	}
+	t, ok := h.resolveExisting(w, r, userID, names)
+	if !ok {
+		return
+	}
+	client := requestClient(r)
+	switch {
+	case t.root:
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "the root folder cannot be deleted"})
+	case t.file != nil:
+		if err := h.uploads.files.Trash(r.Context(), userID, t.file.ID, client); err != nil {
+			writeRepoError(w, err, "file not found", "failed to delete file")
+			return
+		}
+		h.locks.release(userID, davKey(names))
+		logger.Info(r.Context(), "File trashed over WebDAV", map[string]interface{}{"user_id": userID, "file_id": t.file.ID})
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		job, err := h.folders.runner.Submit(r.Context(), userID, jobs.KindFolderDelete, jobs.FolderDeletePayload{FolderID: t.folder.ID},
+			jobs.DeleteFolderTree(h.folders.folderRepo, h.uploads.fileRepo, t.folder.ID, userID, client))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "db_error", Message: "failed to start folder deletion"})
+			return
+		}
+		h.locks.release(userID, davKey(names))
+		logger.Info(r.Context(), "Folder deletion started over WebDAV", map[string]interface{}{
+			"user_id": userID, "folder_id": t.folder.ID, "job_id": job.ID,
+		})
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// mkcol creates a folder, within the same depth and children limits as POST /folders.
+func (h *WebDAVHandler) mkcol(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	if r.ContentLength > 0 {
+		writeJSON(w, http.StatusUnsupportedMediaType, ErrorResponse{Error: "unsupported_media_type", Message: "MKCOL takes no body"})
+		return
+	}
+	if !h.checkLocks(w, r, userID, names, false) {
+		return
+	}
+	t, ok := h.resolveNew(w, r, userID, names)
+	if !ok {
+		return
+	}
+	if t.exists() {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method_not_allowed", Message: "the name is already taken"})
+		return
+	}
+	folder, err := h.folders.folders.Create(r.Context(), userID, t.parentID, t.name)
+	if err != nil {
+		writeFolderError(w, err, "parent folder not found", "failed to create folder")
+		return
+	}
+	logger.Info(r.Context(), "Folder created over WebDAV", map[string]interface{}{
+		"user_id": userID, "folder_id": folder.ID, "folder_name": folder.Name, "parent_id": t.parentID,
+	})
+	w.WriteHeader(http.StatusCreated)
+}
+
+// move renames and/or moves a file or folder to the Destination header's path. A file
+// at the destination is moved to the trash unless Overwrite: F, which gets 412 instead.
+// A folder at the destination is never replaced, even with Overwrite: T: unlike RFC 4918
+// §9.9.3 the request gets 409 and the client has to DELETE the folder first, since
+// deleting a folder tree is a background job that cannot share a transaction with the
+// move. Locks stay on the paths they were taken on; those on the source are released.
+func (h *WebDAVHandler) move(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	dest, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || dest.Path == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "Destination header is required"})
+		return
+	}
+	destNames, ok := h.davPath(dest.Path)
+	if !ok || len(destNames) == 0 {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "Destination must be a path below " + h.prefix + "/"})
+		return
+	}
+	overwrite := !strings.EqualFold(r.Header.Get("Overwrite"), "F")
+	if !h.checkLocks(w, r, userID, names, true) || !h.checkLocks(w, r, userID, destNames, true) {
+		return
+	}
+
+	src, ok := h.resolveExisting(w, r, userID, names)
+	if !ok {
+		return
+	}
+	if src.root {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "the root folder cannot be moved"})
+		return
+	}
+	dst, ok := h.resolveNew(w, r, userID, destNames)
+	if !ok {
+		return
+	}
+	switch {
+	case (src.file != nil && dst.file != nil && src.file.ID == dst.file.ID) || (src.folder != nil && dst.folder != nil && src.folder.ID == dst.folder.ID):
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "forbidden", Message: "source and destination are the same"})
+		return
+	case dst.exists() && !overwrite:
+		writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "precondition_failed", Message: "the destination exists"})
+		return
+	case dst.root || dst.folder != nil:
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "conflict", Message: "a folder exists at the destination; delete it first"})
+		return
+	}
+
+	client := requestClient(r)
+	if src.file != nil {
+		file, replaced, err := h.uploads.fileRepo.Replace(r.Context(), src.file.ID, userID,
+			repository.FilePlacement{Name: dst.name, FolderID: dst.parentID, Move: true}, client)
+		if err != nil {
+			writeRepoError(w, err, "file not found", "failed to move file")
+			return
+		}
+		logger.Info(r.Context(), "File moved over WebDAV", map[string]interface{}{
+			"user_id": userID, "file_id": file.ID, "file_name": file.Name, "folder_id": file.FolderID, "replaced": replaced,
+		})
+	} else {
+		// A folder may share its name with a file, so the file at the destination is
+		// only trashed once the folder has taken the name: a failed move leaves it be.
+		if !sameFolderID(src.folder.ParentID, dst.parentID) {
+			if _, err := h.folders.folders.Move(r.Context(), userID, src.folder.ID, dst.parentID); err != nil {
+				writeFolderError(w, err, "folder not found", "failed to move folder")
+				return
+			}
+		}
+		if dst.name != src.folder.Name {
+			if _, err := h.folders.folderRepo.Rename(r.Context(), src.folder.ID, userID, dst.name); err != nil {
+				writeRepoError(w, err, "folder not found", "failed to rename folder")
+				return
+			}
+		}
+		if dst.file != nil {
+			if err := h.uploads.files.Trash(r.Context(), userID, dst.file.ID, client); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				writeRepoError(w, err, "file not found", "folder moved but failed to replace the file at the destination")
+				return
+			}
+		}
+		logger.Info(r.Context(), "Folder moved over WebDAV", map[string]interface{}{
+			"user_id": userID, "folder_id": src.folder.ID, "folder_name": dst.name, "parent_id": dst.parentID,
+		})
+	}
+	h.locks.release(userID, davKey(names))
+
+	if dst.exists() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func sameFolderID(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+type davLockDiscovery struct {
+	XMLName xml.Name      `xml:"D:prop"`
+	XMLNS   string        `xml:"xmlns:D,attr"`
+	Lock    davActiveLock `xml:"D:lockdiscovery>D:activelock"`
+}
+
+type davActiveLock struct {
+	Write     struct{} `xml:"D:locktype>D:write"`
+	Exclusive struct{} `xml:"D:lockscope>D:exclusive"`
+	Depth     string   `xml:"D:depth"`
+	Timeout   string   `xml:"D:timeout"`
+	Token     string   `xml:"D:locktoken>D:href"`
+	Root      string   `xml:"D:lockroot>D:href"`
+}
+
+// davLockInfo is the body of a LOCK request creating a lock.
+type davLockInfo struct {
+	Scope struct {
+		Shared *struct{} `xml:"shared"`
+	} `xml:"lockscope"`
+}
+
+// lock creates an exclusive write lock on the path, or refreshes the lock whose token
+// the If header submits when there is no body. A path that does not exist yet can be
+// locked before it is created (201); the lock then guards the PUT or MKCOL creating it.
+// Shared locks are not supported.
+func (h *WebDAVHandler) lock(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "failed to read the request body"})
+		return
+	}
+	timeout := davTimeout(r)
+
+	var l *davLock
+	status := http.StatusOK
+	if len(strings.TrimSpace(string(body))) == 0 {
+		if l, err = h.locks.refresh(userID, davKey(names), davSubmittedTokens(r), timeout); err != nil {
+			writeJSON(w, http.StatusPreconditionFailed, ErrorResponse{Error: "precondition_failed", Message: "no lock of this path is submitted in the If header"})
+			return
+		}
+	} else {
+		var info davLockInfo
+		if err := xml.Unmarshal(body, &info); err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "invalid lockinfo body"})
+			return
+		}
+		if info.Scope.Shared != nil {
+			writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: "not_implemented", Message: "only exclusive locks are supported"})
+			return
+		}
+		var deep bool
+		switch d := r.Header.Get("Depth"); strings.ToLower(d) {
+		case "", "infinity":
+			deep = true
+		case "0":
+		default:
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "Depth must be 0 or infinity"})
+			return
+		}
+		t, ok := h.resolveNew(w, r, userID, names)
+		if !ok {
+			return
+		}
+		if !t.exists() {
+			status = http.StatusCreated
+		}
+		l, err = h.locks.create(userID, davKey(names), deep, timeout)
+		switch {
+		case errors.Is(err, errDAVLocked):
+			writeJSON(w, http.StatusLocked, ErrorResponse{Error: "locked", Message: "the resource is already locked"})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "too_many_locks", Message: err.Error()})
+			return
+		}
+		w.Header().Set("Lock-Token", "<"+l.token+">")
+	}
+
+	active := davActiveLock{Depth: "0", Timeout: "Second-" + strconv.Itoa(int(timeout/time.Second)), Token: l.token, Root: h.href(strings.Split(strings.TrimPrefix(l.path, "/"), "/"), false)}
+	if l.deep {
+		active.Depth = "infinity"
+	}
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(davLockDiscovery{XMLNS: "DAV:", Lock: active}); err != nil {
+		logger.Warn(r.Context(), "Failed to write WebDAV lock", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// unlock removes the lock named by the Lock-Token header, which must cover the path.
+func (h *WebDAVHandler) unlock(w http.ResponseWriter, r *http.Request, userID int64, names []string) {
+	token := strings.Trim(strings.TrimSpace(r.Header.Get("Lock-Token")), "<>")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: "Lock-Token header is required"})
+		return
+	}
+	if err := h.locks.unlock(userID, davKey(names), token); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: "conflict", Message: "no such lock on this path"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// davMaxLockTimeout is the longest a lock lives without a refresh; clients asking
+	// for longer (or Infinite) get this.
+	davMaxLockTimeout = time.Hour
+	// davMaxLocks caps the live locks of one user, which are held in memory.
+	davMaxLocks = 1000
+)
+
+// davLock is an exclusive write lock on a path ("/" for the root, "/a/b" below it),
+// covering everything below it too when deep.
+type davLock struct {
+	token   string
+	userID  int64
+	path    string
+	deep    bool // Depth: infinity
+	expires time.Time
+}
+
+// covers reports whether l applies to a change of p.
+func (l *davLock) covers(p string) bool {
+	return l.path == p || (l.deep && davUnder(p, l.path))
+}
+
+// davUnder reports whether p lies strictly below folder.
+func davUnder(p, folder string) bool {
+	if folder == "/" {
+		return p != "/"
+	}
+	return strings.HasPrefix(p, folder+"/")
+}
+
+// davLocks tracks the WebDAV locks granted by this process. Locks are exclusive write
+// locks: while one is held, PUT, DELETE, MKCOL and MOVE of a path it covers must
+// submit its token in an If header or get 423 Locked. Locks live in memory, so behind
+// several API instances a client must reach the same instance (sticky sessions) for
+// them to hold, and a restart drops them; clients then get 412 on their next refresh
+// and lock again.
+type davLocks struct {
+	mu    sync.Mutex
+	locks map[string]*davLock // by token
+	now   func() time.Time
+}
+
+func newDAVLocks() *davLocks {
+	return &davLocks{locks: make(map[string]*davLock), now: time.Now}
+}
+
+// sweepLocked drops expired locks; the caller holds mu.
+func (s *davLocks) sweepLocked() {
+	now := s.now()
+	for token, l := range s.locks {
+		if !now.Before(l.expires) {
+			delete(s.locks, token)
+		}
+	}
+}
+
+// davLockError is why a lock operation failed.
+type davLockError int
+
+const (
+	errDAVLocked davLockError = iota + 1
+	errDAVTooManyLocks
+	errDAVNoLock
+)
+
+func (e davLockError) Error() string {
+	switch e {
+	case errDAVLocked:
+		return "the resource is locked"
+	case errDAVTooManyLocks:
+		return "too many locks held"
+	default:
+		return "no matching lock"
+	}
+}
+
+// create grants userID a new lock on p unless it conflicts with a live one: a lock
+// covering p, or with deep set, a lock anywhere below p.
+func (s *davLocks) create(userID int64, p string, deep bool, timeout time.Duration) (*davLock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	held := 0
+	for _, l := range s.locks {
+		if l.userID != userID {
+			continue
+		}
+		held++
+		if l.covers(p) || (deep && davUnder(l.path, p)) {
+			return nil, errDAVLocked
+		}
+	}
+	if held >= davMaxLocks {
+		return nil, errDAVTooManyLocks
+	}
+	l := &davLock{token: "opaquelocktoken:" + uuid.NewString(), userID: userID, path: p, deep: deep, expires: s.now().Add(timeout)}
+	s.locks[l.token] = l
+	return l, nil
+}
+
+// refresh extends the first of tokens that is a live lock of userID covering p.
+func (s *davLocks) refresh(userID int64, p string, tokens []string, timeout time.Duration) (*davLock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	for _, token := range tokens {
+		if l := s.locks[token]; l != nil && l.userID == userID && l.covers(p) {
+			l.expires = s.now().Add(timeout)
+			copied := *l
+			return &copied, nil
+		}
+	}
+	return nil, errDAVNoLock
+}
+
+// unlock removes userID's lock token, which must cover p.
+func (s *davLocks) unlock(userID int64, p, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	l := s.locks[token]
+	if l == nil || l.userID != userID || !l.covers(p) {
+		return errDAVNoLock
+	}
+	delete(s.locks, token)
+	return nil
+}
+
+// allowed reports whether a change of p (and with deep, of everything below it) by
+// userID may go ahead: every live lock it touches must have its token in tokens.
+func (s *davLocks) allowed(userID int64, p string, deep bool, tokens []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepLocked()
+
+	for _, l := range s.locks {
+		if l.userID != userID || !(l.covers(p) || (deep && davUnder(l.path, p))) {
+			continue
+		}
+		submitted := false
+		for _, t := range tokens {
+			if t == l.token {
+				submitted = true
+				break
+			}
+		}
+		if !submitted {
+			return false
+		}
+	}
+	return true
+}
+
+// release drops userID's locks on p and below, whose resources a DELETE or MOVE
+// removed.
+func (s *davLocks) release(userID int64, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, l := range s.locks {
+		if l.userID == userID && (l.path == p || davUnder(l.path, p)) {
+			delete(s.locks, token)
+		}
+	}
+}
+
+var davTokenPattern = regexp.MustCompile(`<(opaquelocktoken:[^>]+)>`)
+
+// davSubmittedTokens returns the lock tokens of r's If header. Conditions are not
+// evaluated beyond that: submitting a lock's token is what proves holding it.
+func davSubmittedTokens(r *http.Request) []string {
+	var tokens []string
+	for _, m := range davTokenPattern.FindAllStringSubmatch(r.Header.Get("If"), -1) {
+		tokens = append(tokens, m[1])
+	}
+	return tokens
+}
+
+// davTimeout reads the Timeout header ("Second-N" or "Infinite", best first), capped
+// at davMaxLockTimeout.
+func davTimeout(r *http.Request) time.Duration {
+	for _, v := range strings.Split(r.Header.Get("Timeout"), ",") {
+		v = strings.TrimSpace(v)
+		if n, ok := strings.CutPrefix(v, "Second-"); ok {
+			if secs, err := strconv.Atoi(n); err == nil && secs > 0 {
+				return min(time.Duration(secs)*time.Second, davMaxLockTimeout)
+			}
+		}
+	}
+	return davMaxLockTimeout
+}
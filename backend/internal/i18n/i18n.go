@@ -0,0 +1,160 @@
+// Package i18n localizes the human-readable text of API responses. Machine error
+// codes stay stable; only messages are translated. Catalogs are keyed by the English
+// message itself, so untranslated text falls back to English without extra work.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLang is the language messages are written in.
+const DefaultLang = "en"
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalogs maps a language tag to its English -> translated messages.
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	entries, err := localeFS.ReadDir("locales")
+	if err != nil {
+		panic("i18n: read locales: " + err.Error())
+	}
+	out := make(map[string]map[string]string, len(entries))
+	for _, e := range entries {
+		raw, err := localeFS.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic("i18n: read " + e.Name() + ": " + err.Error())
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic("i18n: parse " + e.Name() + ": " + err.Error())
+		}
+		out[strings.TrimSuffix(e.Name(), ".json")] = messages
+	}
+	return out
+}
+
+// Supported lists the available languages, DefaultLang first.
+func Supported() []string {
+	langs := []string{DefaultLang}
+	for lang := range catalogs {
+		if lang != DefaultLang {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// T translates msg into lang, or returns it unchanged if there is no translation.
+func T(lang, msg string) string {
+	if t, ok := catalogs[lang][msg]; ok && t != "" {
+		return t
+	}
+	return msg
+}
+
+// Negotiate picks the best supported language for an Accept-Language header,
+// e.g. "id-ID,id;q=0.9,en;q=0.8". Region subtags fall back to the base language.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		base, _, _ := strings.Cut(c.tag, "-")
+		for _, tag := range []string{c.tag, base} {
+			if tag == DefaultLang {
+				return DefaultLang
+			}
+			if _, ok := catalogs[tag]; ok {
+				return tag
+			}
+		}
+	}
+	return DefaultLang
+}
+
+type contextKey struct{}
+
+// WithLang returns ctx carrying lang.
+func WithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// Lang returns the language negotiated for the request ctx belongs to.
+func Lang(ctx context.Context) string {
+	if lang, ok := ctx.Value(contextKey{}).(string); ok {
+		return lang
+	}
+	return DefaultLang
+}
+
+// Middleware negotiates the response language from Accept-Language and makes it
+// available through Lang(ctx) and, for code that only has the ResponseWriter,
+// through Localize. It must be the innermost wrapper of the ResponseWriter.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := Negotiate(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(&langWriter{ResponseWriter: w, lang: lang}, r.WithContext(WithLang(r.Context(), lang)))
+	})
+}
+
+// langWriter tags a ResponseWriter with the request's language.
+type langWriter struct {
+	http.ResponseWriter
+	lang string
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *langWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush keeps streaming responses working for handlers that assert http.Flusher.
+func (w *langWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Localize translates msg into the language of the request w is answering and
+// reports which language that is.
+func Localize(w http.ResponseWriter, msg string) (string, string) {
+	for {
+		if lw, ok := w.(*langWriter); ok {
+			return T(lw.lang, msg), lw.lang
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return msg, DefaultLang
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// KindBlockIntegrity is the job kind for block reference integrity checks.
+const KindBlockIntegrity = "block_integrity_check"
+
+// BlockIntegrityPayload configures a block integrity check.
+type BlockIntegrityPayload struct {
+	Repair bool `json:"repair"`
+}
+
+// CheckBlockIntegrity cross-checks every block's ref_count against its actual
+// references and, when repair is set, rewrites drifted counts.
+//
+// Uploads in flight bump ref_count before linking the block to the new file, so
+// a check during heavy upload traffic can report (and repair) transient drift
+// on those blocks. Run repairs during quiet periods.
+func CheckBlockIntegrity(repo *repository.BlockRepository, repair bool) Func {
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		start := time.Now()
+
+		drift, total, unreferenced, err := repo.FindRefCountDrift(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Set(50)
+
+		report := &model.BlockIntegrityReport{
+			CheckedAt:     start.UTC(),
+			BlocksChecked: total,
+			Discrepancies: drift,
+			Unreferenced:  unreferenced,
+		}
+		if report.Discrepancies == nil {
+			report.Discrepancies = []*model.BlockRefDrift{}
+		}
+
+		if repair && len(drift) > 0 {
+			ids := make([]int64, len(drift))
+			for i, d := range drift {
+				ids[i] = d.BlockID
+			}
+			report.Repaired, err = repo.RepairRefCounts(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		logger.Info(ctx, "Block integrity check finished", map[string]interface{}{
+			"blocks_checked": total,
+			"discrepancies":  len(drift),
+			"unreferenced":   unreferenced,
+			"repaired":       report.Repaired,
+			"duration_ms":    time.Since(start).Milliseconds(),
+		})
+		return report, nil
+	}
+}
@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// KindBlockRekey is the job kind for moving blocks to their signed S3 keys.
+const KindBlockRekey = "block_rekey"
+
+const (
+	rekeyBatchSize = 500
+
+	// rekeyGrace is how long an old object is kept after its block moves, so
+	// downloads that looked up the old key just before can still read it.
+	rekeyGrace = time.Hour
+)
+
+// RekeyBlocks copies every block whose S3 object is not stored under the key keys
+// derives for it (e.g. raw-hash keys from before BLOCK_KEY_SECRET was set) to that
+// key, repoints the block and queues the old object for deletion. It is safe to run
+// while the service is in use and to rerun: blocks already on their key are skipped,
+// and a block that fails to copy stays on its old key.
+func RekeyBlocks(repo *repository.BlockRepository, s3 *storage.S3Client, keys block.KeySigner) Func {
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		start := time.Now()
+
+		total, err := repo.Count(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		report := &model.BlockRekeyReport{}
+		var afterID int64
+		for {
+			batch, err := repo.ListAfter(ctx, afterID, rekeyBatchSize)
+			if err != nil {
+				return nil, err
+			}
+			if len(batch) == 0 {
+				break
+			}
+
+			for _, b := range batch {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				report.BlocksChecked++
+				newKey := keys.ObjectKey(b.SHA256Hash, b.Compression)
+				if b.S3Key == newKey {
+					continue
+				}
+
+				if err := s3.CopyObject(ctx, b.S3Key, newKey); err != nil {
+					report.Failed++
+					logger.Warn(ctx, "Block rekey copy failed", map[string]interface{}{
+						"block_id": b.ID, "error": err.Error(),
+					})
+					continue
+				}
+				moved, err := repo.ReplaceS3Key(ctx, b.ID, b.S3Key, newKey, rekeyGrace)
+				if err != nil {
+					return nil, err
+				}
+				if moved {
+					report.Rekeyed++
+				}
+			}
+
+			afterID = batch[len(batch)-1].ID
+			p.SetFraction(report.BlocksChecked, total)
+		}
+
+		logger.Info(ctx, "Block rekey finished", map[string]interface{}{
+			"blocks_checked": report.BlocksChecked,
+			"rekeyed":        report.Rekeyed,
+			"failed":         report.Failed,
+			"duration_ms":    time.Since(start).Milliseconds(),
+		})
+		return report, nil
+	}
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// TaskCollectBlockGarbage is the scheduler name of the CollectBlockGarbage task, for Trigger.
+const TaskCollectBlockGarbage = "collect-block-garbage"
+
+// gcBatchSize bounds how many blocks one transaction locks and queues for deletion.
+const gcBatchSize = 100
+
+// CollectBlockGarbage removes blocks whose ref_count dropped to zero from the database
+// and queues their objects for ProcessS3Deletions, batch by batch until none are left
+// or ctx is cancelled.
+func CollectBlockGarbage(repo *repository.BlockRepository) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		var total int64
+		for ctx.Err() == nil {
+			n, err := repo.CollectGarbage(ctx, gcBatchSize)
+			if err != nil {
+				return err
+			}
+			total += n
+			if n < gcBatchSize {
+				break
+			}
+		}
+
+		if total > 0 {
+			logger.Info(ctx, "Orphaned blocks garbage collected", map[string]interface{}{
+				"collected":   total,
+				"duration_ms": time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// PreviewBlockGarbage previews CollectBlockGarbage.
+func PreviewBlockGarbage(repo *repository.BlockRepository) Preview {
+	return func(ctx context.Context) (*model.CleanupPreview, error) {
+		p, err := repo.PreviewCollectGarbage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Description = "unreferenced blocks deleted and queued for S3 deletion"
+		return p, nil
+	}
+}
@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// Scheduler names of the destructive tasks besides TaskCollectBlockGarbage.
+const (
+	TaskPurgeShareLinks     = "purge-expired-share-links"
+	TaskPurgeTrash          = "purge-trash"
+	TaskPurgeUploadSessions = "purge-upload-sessions"
+	TaskProcessS3Deletions  = "process-s3-deletions"
+)
+
+// Preview reports what a destructive task would remove if it ran now, without
+// removing anything.
+type Preview func(ctx context.Context) (*model.CleanupPreview, error)
+
+type cleanupTask struct {
+	name    string
+	preview Preview
+}
+
+// Cleanup tracks the destructive maintenance tasks so operators can see what they
+// would remove before letting them run. In dry-run mode the tasks it wraps only log
+// their preview.
+type Cleanup struct {
+	dryRun bool
+	tasks  []cleanupTask
+}
+
+// NewCleanup creates a Cleanup; with dryRun set, tasks passed through Register never
+// delete anything.
+func NewCleanup(dryRun bool) *Cleanup {
+	if dryRun {
+		logger.Info(context.Background(), "Maintenance dry-run mode: destructive tasks only report what they would remove", nil)
+	}
+	return &Cleanup{dryRun: dryRun}
+}
+
+// DryRun reports whether destructive tasks are disabled.
+func (c *Cleanup) DryRun() bool {
+	return c.dryRun
+}
+
+// Register adds the destructive task name to the report and returns the task to
+// schedule: task itself, or in dry-run mode a task logging preview instead. Register
+// all tasks before the scheduler starts.
+func (c *Cleanup) Register(name string, preview Preview, task Task) Task {
+	c.tasks = append(c.tasks, cleanupTask{name: name, preview: preview})
+	if !c.dryRun {
+		return task
+	}
+	return func(ctx context.Context) error {
+		p, err := preview(ctx)
+		if err != nil {
+			return err
+		}
+		if p.Items > 0 || p.Blocks > 0 {
+			logger.Info(ctx, "Dry run: maintenance task skipped", map[string]interface{}{
+				"task":        name,
+				"items":       p.Items,
+				"blocks":      p.Blocks,
+				"bytes_freed": p.BytesFreed,
+			})
+		}
+		return nil
+	}
+}
+
+// Report previews every registered task, in registration order.
+func (c *Cleanup) Report(ctx context.Context) (*model.CleanupReport, error) {
+	report := &model.CleanupReport{
+		DryRun:      c.dryRun,
+		GeneratedAt: time.Now().UTC(),
+		Tasks:       make([]*model.CleanupPreview, 0, len(c.tasks)),
+	}
+	for _, t := range c.tasks {
+		p, err := t.preview(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Task = t.name
+		report.Tasks = append(report.Tasks, p)
+		report.BytesFreed += p.BytesFreed
+	}
+	return report, nil
+}
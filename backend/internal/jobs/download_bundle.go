@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// KindDownloadBundle is the job kind for multi-file download bundles.
+const KindDownloadBundle = "download_bundle"
+
+// DownloadBundlePayload lists the files requested for a bundle.
+type DownloadBundlePayload struct {
+	FileIDs []int64 `json:"file_ids"`
+}
+
+// DownloadBundleResult describes a finished bundle, stored as the job result.
+type DownloadBundleResult struct {
+	Bundle    string    `json:"bundle"` // file name inside the BundleStore
+	SizeBytes int64     `json:"size_bytes"`
+	FileCount int       `json:"file_count"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var bundleNamePattern = regexp.MustCompile(`^[0-9a-f]{32}\.zip$`)
+
+// BundleStore keeps finished download bundles on local disk until they expire.
+type BundleStore struct {
+	dir string
+	ttl time.Duration
+}
+
+// NewBundleStore creates dir if needed. Bundles older than ttl are purged by PurgeExpired.
+func NewBundleStore(dir string, ttl time.Duration) (*BundleStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("jobs.NewBundleStore: %w", err)
+	}
+	return &BundleStore{dir: dir, ttl: ttl}, nil
+}
+
+// Open returns a finished bundle by name, or os.ErrNotExist if it was purged.
+func (s *BundleStore) Open(name string) (*os.File, error) {
+	if !bundleNamePattern.MatchString(name) {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+// PurgeExpired deletes bundles older than the store's TTL.
+func (s *BundleStore) PurgeExpired() Task {
+	return func(ctx context.Context) error {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-s.ttl)
+		var purged int
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || e.IsDir() || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.dir, e.Name())); err == nil {
+				purged++
+			}
+		}
+		if purged > 0 {
+			logger.Info(ctx, "Expired download bundles purged", map[string]interface{}{
+				"purged": purged,
+			})
+		}
+		return nil
+	}
+}
+
+// BuildDownloadBundle zips files into a new bundle in store, reporting progress by
+// bytes written. Entries are stored uncompressed: most large files are already
+// compressed, and Store keeps the job I/O-bound rather than CPU-bound.
+func BuildDownloadBundle(
+	blockRepo *repository.BlockRepository,
+	s3 *storage.S3Client,
+	store *BundleStore,
+	files []*model.File,
+) Func {
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		var totalBytes int64
+		for _, f := range files {
+			totalBytes += f.TotalSize
+		}
+
+		var random [16]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			return nil, err
+		}
+		name := hex.EncodeToString(random[:]) + ".zip"
+		path := filepath.Join(store.dir, name)
+
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		ok := false
+		defer func() {
+			out.Close()
+			if !ok {
+				os.Remove(path)
+			}
+		}()
+
+		zw := zip.NewWriter(out)
+		names := map[string]int{}
+		var written int64
+		for _, f := range files {
+			blocks, err := blockRepo.FindByFileID(ctx, repository.ForUser(f.UserID), f.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			entry, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     uniqueEntryName(names, f.Name),
+				Method:   zip.Store,
+				Modified: f.UpdatedAt,
+			})
+			if err != nil {
+				return nil, err
+			}
+			cw := &countingWriter{w: entry, onWrite: func(n int64) {
+				written += n
+				p.SetFraction(written, totalBytes)
+			}}
+			if err := block.BlocksToStream(ctx, blocks, s3, cw); err != nil {
+				return nil, fmt.Errorf("file_id=%d: %w", f.ID, err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+
+		info, err := out.Stat()
+		if err != nil {
+			return nil, err
+		}
+		ok = true
+		return &DownloadBundleResult{
+			Bundle:    name,
+			SizeBytes: info.Size(),
+			FileCount: len(files),
+			ExpiresAt: time.Now().Add(store.ttl).UTC(),
+		}, nil
+	}
+}
+
+// uniqueEntryName turns duplicate names into "name (2).ext", "name (3).ext", ...
+func uniqueEntryName(seen map[string]int, name string) string {
+	name = strings.ReplaceAll(name, "/", "_")
+	seen[name]++
+	if n := seen[name]; n > 1 {
+		ext := filepath.Ext(name)
+		candidate := fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+		seen[candidate]++
+		return candidate
+	}
+	return name
+}
+
+type countingWriter struct {
+	w       io.Writer
+	onWrite func(n int64)
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.onWrite(int64(n))
+	return n, err
+}
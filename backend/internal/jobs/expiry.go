@@ -0,0 +1,127 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// expiryBatchSize bounds how many items one expiry statement locks.
+const expiryBatchSize = 100
+
+// expiryClient is recorded as the modifying client of items removed by expiry.
+const expiryClient = "expiry"
+
+// ExpireItems enforces scheduled expiry. Owners are notified once an item is within
+// notice of its expiry; expired files are then moved to the trash, and expired folders
+// are deleted with everything in them by a folder delete job, like a user delete. A
+// folder whose job cannot be queued, or fails, gets its expiry back and is retried on
+// the next run.
+func ExpireItems(
+	fileRepo *repository.FileRepository,
+	folderRepo *repository.FolderRepository,
+	notifRepo *repository.NotificationRepository,
+	runner *Runner,
+	notice time.Duration,
+) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		var warned int64
+		if notice > 0 {
+			noticeBefore := start.Add(notice)
+			for _, notify := range []func(context.Context, time.Time, int) (int64, error){
+				fileRepo.NotifyExpiring, folderRepo.NotifyExpiring,
+			} {
+				for ctx.Err() == nil {
+					n, err := notify(ctx, noticeBefore, expiryBatchSize)
+					if err != nil {
+						return err
+					}
+					warned += n
+					if n < expiryBatchSize {
+						break
+					}
+				}
+			}
+		}
+
+		var trashed int64
+		for ctx.Err() == nil {
+			n, err := fileRepo.TrashExpired(ctx, expiryBatchSize, expiryClient)
+			if err != nil {
+				return err
+			}
+			trashed += n
+			if n < expiryBatchSize {
+				break
+			}
+		}
+
+		var deleted int
+		for ctx.Err() == nil {
+			folders, err := folderRepo.ClaimExpired(ctx, expiryBatchSize)
+			if err != nil {
+				return err
+			}
+			for i, f := range folders {
+				if _, err := runner.Submit(ctx, f.UserID, KindFolderDelete, FolderDeletePayload{FolderID: f.ID},
+					deleteExpiredFolder(folderRepo, fileRepo, f)); err != nil {
+					restoreExpiry(ctx, folderRepo, folders[i:])
+					return err
+				}
+				deleted++
+				if _, err := notifRepo.Create(ctx, f.UserID, model.NotificationExpired, model.ExpiryNotice{
+					ItemType: "folder", ItemID: f.ID, Name: f.Name, ExpiresAt: *f.ExpiresAt,
+				}); err != nil {
+					restoreExpiry(ctx, folderRepo, folders[i+1:])
+					return err
+				}
+			}
+			if len(folders) < expiryBatchSize {
+				break
+			}
+		}
+
+		if warned > 0 || trashed > 0 || deleted > 0 {
+			logger.Info(ctx, "Scheduled expiry applied", map[string]interface{}{
+				"notices_sent":    warned,
+				"files_trashed":   trashed,
+				"folders_deleted": deleted,
+				"duration_ms":     time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// deleteExpiredFolder deletes a claimed folder like DeleteFolderTree, restoring its
+// expiry if the delete fails so the folder does not silently stop expiring.
+func deleteExpiredFolder(folderRepo *repository.FolderRepository, fileRepo *repository.FileRepository, f *model.Folder) Func {
+	deleteTree := DeleteFolderTree(folderRepo, fileRepo, f.ID, f.UserID, expiryClient)
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		result, err := deleteTree(ctx, p)
+		if err != nil && !errors.Is(err, ErrFolderNotFound) {
+			restoreExpiry(ctx, folderRepo, []*model.Folder{f})
+		}
+		return result, err
+	}
+}
+
+// restoreExpiry hands claimed folders back to a later expiry run. It runs even when
+// ctx is done, since shutdown is one of the reasons a delete does not happen.
+func restoreExpiry(ctx context.Context, folderRepo *repository.FolderRepository, folders []*model.Folder) {
+	ctx = context.WithoutCancel(ctx)
+	for _, f := range folders {
+		if err := folderRepo.RestoreExpiry(ctx, f.ID, *f.ExpiresAt); err != nil {
+			logger.ErrorLog(ctx, "Failed to restore folder expiry", logger.ErrorDetails{
+				Code: "EXPIRY_RESTORE_ERR", Details: fmt.Sprintf("folder_id=%d: %s", f.ID, err.Error()),
+			})
+		}
+	}
+}
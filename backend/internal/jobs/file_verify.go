@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// KindFileVerify is the job kind for file integrity verifications.
+const KindFileVerify = "file_verify"
+
+// FileVerifyPayload names the file being verified.
+type FileVerifyPayload struct {
+	FileID int64 `json:"file_id"`
+}
+
+// VerifyFile re-reads file from S3 and checks every block against its recorded hash
+// and the content against the checksum recorded at upload or by an earlier
+// verification. A file that passes gets its checksums recorded (if they were not)
+// and its verified_at set; a failed check leaves the recorded checksums alone so
+// they stay the reference.
+func VerifyFile(checksumRepo *repository.ChecksumRepository, blockRepo *repository.BlockRepository, s3 *storage.S3Client, file *model.File) Func {
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		blocks, err := blockRepo.FindByFileID(ctx, repository.ForUser(file.UserID), file.ID)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := checksumRepo.FindByFileID(ctx, file.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		sums, err := block.Checksum(ctx, blocks, s3, file.UserID, func(done int64) {
+			p.SetFraction(done, file.TotalSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		res := &model.FileVerification{
+			FileID:        file.ID,
+			SHA256:        sums.SHA256,
+			BlocksChecked: len(blocks),
+			Mismatched:    sums.Mismatched,
+			VerifiedAt:    time.Now().UTC(),
+		}
+		if res.Mismatched == nil {
+			res.Mismatched = []int{}
+		}
+		// Checksums recorded for other content (the file changed since) prove nothing.
+		if stored != nil && model.ContentETag(stored.ContentHash) == model.BlocksETag(blocks) {
+			res.ExpectedSHA256 = &stored.SHA256
+		}
+		res.OK = len(res.Mismatched) == 0 && (res.ExpectedSHA256 == nil || *res.ExpectedSHA256 == res.SHA256)
+
+		if res.OK {
+			if err := checksumRepo.Put(ctx, file.ID, sums.SHA256, sums.BlockSHA256, true); err != nil {
+				return nil, err
+			}
+		} else {
+			logger.ErrorLog(ctx, "File failed integrity verification", logger.ErrorDetails{
+				Code:    "FILE_INTEGRITY_ERR",
+				Details: fmt.Sprintf("file_id=%d mismatched_blocks=%v sha256=%s", file.ID, res.Mismatched, res.SHA256),
+			})
+		}
+		return res, nil
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// KindFolderDelete is the job kind for recursive folder deletes.
+const KindFolderDelete = "folder_delete"
+
+// folderTrashBatchSize bounds how many files one UPDATE moves to the trash.
+const folderTrashBatchSize = 500
+
+// ErrFolderNotFound is returned when the folder to delete is gone or not owned by the user.
+var ErrFolderNotFound = errors.New("folder not found or unauthorized")
+
+// FolderDeletePayload identifies the folder being deleted.
+type FolderDeletePayload struct {
+	FolderID int64 `json:"folder_id"`
+}
+
+// FolderDeleteResult summarises a finished recursive delete.
+type FolderDeleteResult struct {
+	FoldersDeleted int   `json:"folders_deleted"`
+	FilesTrashed   int64 `json:"files_trashed"`
+}
+
+// DeleteFolderTree deletes a folder with everything under it. Files are moved to the
+// trash in batches, so their block references are released by the trash purge like
+// any other deleted file (the FK alone would just drop them at the root), and then
+// the folder rows are removed. Progress follows the number of files trashed.
+func DeleteFolderTree(folderRepo *repository.FolderRepository, fileRepo *repository.FileRepository, folderID, userID int64, client string) Func {
+	return func(ctx context.Context, p *Progress) (interface{}, error) {
+		folderIDs, err := folderRepo.ListSubtreeIDs(ctx, folderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(folderIDs) == 0 {
+			return nil, ErrFolderNotFound
+		}
+
+		total, err := fileRepo.CountInFolders(ctx, userID, folderIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		var trashed int64
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			n, err := fileRepo.TrashInFolders(ctx, userID, folderIDs, folderTrashBatchSize, client)
+			if err != nil {
+				return nil, err
+			}
+			trashed += n
+			p.SetFraction(trashed, total)
+			if n < folderTrashBatchSize {
+				break
+			}
+		}
+
+		// Folder rows go last; the FK cascades to the subfolders.
+		if err := folderRepo.Delete(ctx, folderID, userID); err != nil {
+			return nil, err
+		}
+		return &FolderDeleteResult{FoldersDeleted: len(folderIDs), FilesTrashed: trashed}, nil
+	}
+}
@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/push"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+const (
+	// pushBatchSize caps how many notifications one pass claims.
+	pushBatchSize = 200
+
+	// pushMaxAge skips notifications that waited longer than this (e.g. while the
+	// server was down), so a restart does not buzz phones with stale news.
+	pushMaxAge = time.Hour
+)
+
+var (
+	pushSent   = expvar.NewInt("push_sent")
+	pushFailed = expvar.NewInt("push_failed")
+)
+
+// DispatchPush pushes new notifications to their users' registered devices, unless the
+// user turned push off or muted the kind. Every notification is claimed once; devices
+// the push service reports as gone are removed.
+// With no push platform enabled, notifications are still claimed so they don't pile up.
+func DispatchPush(notifRepo *repository.NotificationRepository, deviceRepo *repository.PushDeviceRepository, userRepo *repository.UserRepository, pusher *push.Pusher) Task {
+	return func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			batch, err := notifRepo.ClaimUnpushed(ctx, pushBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 || !pusher.Enabled() {
+				if len(batch) < pushBatchSize {
+					return nil
+				}
+				continue
+			}
+			if err := pushBatch(ctx, deviceRepo, userRepo, pusher, batch); err != nil {
+				return err
+			}
+			if len(batch) < pushBatchSize {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func pushBatch(ctx context.Context, deviceRepo *repository.PushDeviceRepository, userRepo *repository.UserRepository, pusher *push.Pusher, batch []*model.Notification) error {
+	start := time.Now()
+
+	var userIDs []int64
+	seen := map[int64]bool{}
+	for _, n := range batch {
+		if !seen[n.UserID] {
+			seen[n.UserID] = true
+			userIDs = append(userIDs, n.UserID)
+		}
+	}
+	devices, err := deviceRepo.ListByUsers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+	byUser := map[int64][]*model.PushDevice{}
+	for _, d := range devices {
+		byUser[d.UserID] = append(byUser[d.UserID], d)
+	}
+	prefs, err := userRepo.PreferencesByUsers(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+
+	var sent, failed int
+	var used, gone []int64
+	goneSet := map[int64]bool{}
+	for _, n := range batch {
+		if time.Since(n.CreatedAt) > pushMaxAge || len(byUser[n.UserID]) == 0 {
+			continue
+		}
+		if p, ok := prefs[n.UserID]; !ok || !p.Notifications.WantsPush(n.Kind) {
+			continue
+		}
+		msg, ok := push.MessageFor(n)
+		if !ok {
+			continue
+		}
+		for _, d := range byUser[n.UserID] {
+			if goneSet[d.ID] || !pusher.Supports(d.Platform) {
+				continue
+			}
+			err := pusher.Send(ctx, d, msg)
+			switch {
+			case err == nil:
+				sent++
+				used = append(used, d.ID)
+			case errors.Is(err, push.ErrGone):
+				goneSet[d.ID] = true
+				gone = append(gone, d.ID)
+			default:
+				failed++
+				logger.Warn(ctx, "Push delivery failed", map[string]interface{}{
+					"notification_id": n.ID, "device_id": d.ID, "platform": d.Platform, "error": err.Error(),
+				})
+			}
+		}
+	}
+	pushSent.Add(int64(sent))
+	pushFailed.Add(int64(failed))
+
+	if len(gone) > 0 {
+		if err := deviceRepo.DeleteByIDs(ctx, gone); err != nil {
+			return err
+		}
+	}
+	if len(used) > 0 {
+		if err := deviceRepo.MarkUsed(ctx, used); err != nil {
+			return err
+		}
+	}
+	if sent+failed+len(gone) > 0 {
+		logger.Info(ctx, "Push notifications dispatched", map[string]interface{}{
+			"notifications":   len(batch),
+			"sent":            sent,
+			"failed":          failed,
+			"devices_removed": len(gone),
+			"duration_ms":     time.Since(start).Milliseconds(),
+		})
+	}
+	return nil
+}
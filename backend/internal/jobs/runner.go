@@ -0,0 +1,136 @@
+// Package jobs runs long-running work outside the request path and records its
+// state in the jobs table so clients can poll for the outcome.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// Func is the body of a background job. The returned value is stored as the job result.
+type Func func(ctx context.Context, progress *Progress) (interface{}, error)
+
+// Progress lets a running job report how far along it is.
+type Progress struct {
+	ctx   context.Context
+	repo  *repository.JobRepository
+	jobID int64
+	last  int
+}
+
+// Set records progress as a percentage (0-100). Unchanged values are not written.
+func (p *Progress) Set(pct int) {
+	if pct < 0 {
+		pct = 0
+	}
+	if pct > 99 {
+		pct = 99 // 100 is reserved for completion
+	}
+	if pct == p.last {
+		return
+	}
+	p.last = pct
+	if err := p.repo.UpdateProgress(p.ctx, p.jobID, pct); err != nil {
+		logger.Warn(p.ctx, "Failed to record job progress", map[string]interface{}{
+			"job_id": p.jobID, "error": err.Error(),
+		})
+	}
+}
+
+// SetFraction records progress as done/total.
+func (p *Progress) SetFraction(done, total int64) {
+	if total <= 0 {
+		return
+	}
+	p.Set(int(done * 100 / total))
+}
+
+// Runner executes jobs in background goroutines.
+type Runner struct {
+	repo   *repository.JobRepository
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner creates a Runner whose jobs are cancelled on Shutdown.
+func NewRunner(repo *repository.JobRepository) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{repo: repo, ctx: ctx, cancel: cancel}
+}
+
+// Submit records a pending job and starts fn in the background.
+// The request ID from ctx is carried over so job logs can be correlated with the request.
+func (r *Runner) Submit(ctx context.Context, userID int64, kind string, payload interface{}, fn Func) (*model.Job, error) {
+	job, err := r.repo.Create(ctx, userID, kind, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	jobCtx := logger.WithRequestID(r.ctx, logger.GetRequestID(ctx))
+	jobCtx = logger.WithPath(jobCtx, "Job/"+kind)
+	jobCtx = logger.WithUserID(jobCtx, userID)
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(jobCtx, job, fn)
+	}()
+	return job, nil
+}
+
+func (r *Runner) run(ctx context.Context, job *model.Job, fn Func) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			msg := fmt.Sprintf("panic: %v", rec)
+			logger.ErrorLog(ctx, "Background job panicked", logger.ErrorDetails{
+				Code: "JOB_PANIC", Details: fmt.Sprintf("job_id=%d kind=%s: %s", job.ID, job.Kind, msg),
+			})
+			_ = r.repo.Fail(context.Background(), job.ID, msg)
+		}
+	}()
+
+	if err := r.repo.MarkRunning(ctx, job.ID); err != nil {
+		return
+	}
+	logger.Info(ctx, "Background job started", map[string]interface{}{
+		"job_id": job.ID, "kind": job.Kind,
+	})
+
+	result, err := fn(ctx, &Progress{ctx: ctx, repo: r.repo, jobID: job.ID})
+	if err != nil {
+		logger.ErrorLog(ctx, "Background job failed", logger.ErrorDetails{
+			Code: "JOB_FAILED", Details: fmt.Sprintf("job_id=%d kind=%s: %s", job.ID, job.Kind, err.Error()),
+		})
+		// Use a fresh context so the failure is recorded even when shutting down.
+		_ = r.repo.Fail(context.Background(), job.ID, err.Error())
+		return
+	}
+
+	if err := r.repo.Complete(ctx, job.ID, result); err != nil {
+		_ = r.repo.Fail(context.Background(), job.ID, err.Error())
+		return
+	}
+	logger.Info(ctx, "Background job completed", map[string]interface{}{
+		"job_id": job.ID, "kind": job.Kind,
+	})
+}
+
+// Shutdown cancels running jobs and waits for them to return or for ctx to expire.
+func (r *Runner) Shutdown(ctx context.Context) {
+	r.cancel()
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
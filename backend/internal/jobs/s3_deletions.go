@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+const (
+	// deletionBatchSize caps how many queue entries one run handles.
+	deletionBatchSize = 500
+
+	deletionBaseBackoff = time.Minute
+	deletionMaxBackoff  = 6 * time.Hour
+)
+
+var (
+	s3DeletionsDone       = expvar.NewInt("s3_deletions_done")
+	s3DeletionsFailed     = expvar.NewInt("s3_deletions_failed")
+	s3DeletionsDeadLetter = expvar.NewInt("s3_deletions_dead_lettered")
+)
+
+// deletionBackoff doubles the retry delay with every failed attempt, up to deletionMaxBackoff.
+func deletionBackoff(attempts int) time.Duration {
+	d := deletionBaseBackoff
+	for i := 1; i < attempts && d < deletionMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > deletionMaxBackoff {
+		d = deletionMaxBackoff
+	}
+	return d
+}
+
+// ProcessS3Deletions drains due entries of the S3 deletion queue. Failed deletions are
+// retried with exponential backoff; after maxAttempts they are dead-lettered and logged
+// as errors so an admin can inspect and requeue them.
+func ProcessS3Deletions(repo *repository.S3DeletionRepository, s3 *storage.S3Client, maxAttempts int) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		counts := map[repository.DeletionOutcome]int{}
+		for i := 0; i < deletionBatchSize && ctx.Err() == nil; i++ {
+			d, outcome, err := repo.ProcessNext(ctx, maxAttempts, deletionBackoff, s3.DeleteObject)
+			if err != nil {
+				return err
+			}
+			if outcome == repository.DeletionNone {
+				break
+			}
+			counts[outcome]++
+
+			switch outcome {
+			case repository.DeletionDone:
+				s3DeletionsDone.Add(1)
+			case repository.DeletionRetrying:
+				s3DeletionsFailed.Add(1)
+			case repository.DeletionDeadLetter:
+				s3DeletionsFailed.Add(1)
+				s3DeletionsDeadLetter.Add(1)
+				logger.ErrorLog(ctx, "S3 deletion dead-lettered", logger.ErrorDetails{
+					Code:    "S3_DELETE_DEAD_LETTER",
+					Details: fmt.Sprintf("deletion_id=%d s3_key=%s attempts=%d: %s", d.ID, d.S3Key, d.Attempts, *d.LastError),
+				})
+			}
+		}
+
+		if len(counts) > 0 {
+			logger.Info(ctx, "S3 deletion queue processed", map[string]interface{}{
+				"deleted":       counts[repository.DeletionDone],
+				"skipped":       counts[repository.DeletionSkipped],
+				"retrying":      counts[repository.DeletionRetrying],
+				"dead_lettered": counts[repository.DeletionDeadLetter],
+				"duration_ms":   time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// PreviewProcessS3Deletions previews ProcessS3Deletions.
+func PreviewProcessS3Deletions(repo *repository.S3DeletionRepository) Preview {
+	return func(ctx context.Context) (*model.CleanupPreview, error) {
+		p, err := repo.PreviewDue(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Description = "queued block objects due for deletion from S3"
+		return p, nil
+	}
+}
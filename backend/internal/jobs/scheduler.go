@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// Task is a periodic maintenance function run by the Scheduler.
+type Task func(ctx context.Context) error
+
+type scheduledTask struct {
+	name     string
+	interval time.Duration
+	task     Task
+	wake     chan struct{} // buffered(1): a pending Trigger
+}
+
+// Scheduler runs registered tasks at fixed intervals until stopped.
+type Scheduler struct {
+	tasks  []*scheduledTask
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler creates an idle Scheduler; register tasks with Every then call Start.
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every registers task to run once at startup and then every interval.
+// A non-positive interval disables the task.
+func (s *Scheduler) Every(name string, interval time.Duration, task Task) {
+	if interval <= 0 {
+		logger.Infof("Scheduled task %s disabled", name)
+		return
+	}
+	s.tasks = append(s.tasks, &scheduledTask{name: name, interval: interval, task: task, wake: make(chan struct{}, 1)})
+}
+
+// Trigger asks the named task to run as soon as it is idle instead of waiting for its
+// next tick. Triggers made while a run is pending collapse into one. Returns false if
+// no such task is registered (e.g. it is disabled).
+func (s *Scheduler) Trigger(name string) bool {
+	for _, t := range s.tasks {
+		if t.name == name {
+			select {
+			case t.wake <- struct{}{}:
+			default:
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Start launches one goroutine per registered task.
+func (s *Scheduler) Start() {
+	for _, t := range s.tasks {
+		s.wg.Add(1)
+		go s.loop(t)
+		logger.Infof("Scheduled task %s every %s", t.name, t.interval)
+	}
+}
+
+func (s *Scheduler) loop(t *scheduledTask) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(t)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-t.wake:
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(t *scheduledTask) {
+	ctx := logger.WithMethod(s.ctx, "INTERNAL")
+	ctx = logger.WithPath(ctx, "Task/"+t.name)
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.ErrorLog(ctx, "Scheduled task panicked", logger.ErrorDetails{
+				Code: "TASK_PANIC", Details: fmt.Sprintf("task=%s: %v", t.name, rec),
+			})
+		}
+	}()
+
+	if err := t.task(ctx); err != nil && s.ctx.Err() == nil {
+		logger.ErrorLog(ctx, "Scheduled task failed", logger.ErrorDetails{
+			Code: "TASK_FAILED", Details: fmt.Sprintf("task=%s: %s", t.name, err.Error()),
+		})
+	}
+}
+
+// Stop cancels all tasks and waits for in-flight runs to return or for ctx to expire.
+func (s *Scheduler) Stop(ctx context.Context) {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// PurgeSecurityEvents deletes security log entries older than retention.
+func PurgeSecurityEvents(repo *repository.SecurityEventRepository, retention time.Duration) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		cutoff := start.Add(-retention)
+
+		purged, err := repo.DeleteOlderThan(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+
+		logger.Info(ctx, "Old security events purged", map[string]interface{}{
+			"purged":      purged,
+			"cutoff":      cutoff.UTC().Format(time.RFC3339),
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// PurgeExpiredSessions deletes expired cookie sessions. Expired sessions are already
+// rejected on lookup; this only keeps the table small.
+func PurgeExpiredSessions(repo *repository.SessionRepository) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		purged, err := repo.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			logger.Info(ctx, "Expired sessions purged", map[string]interface{}{
+				"purged":      purged,
+				"duration_ms": time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// PurgeExpiredRefreshTokens deletes expired refresh tokens. Rotated and revoked tokens
+// are kept until then so that their reuse is still recognised.
+func PurgeExpiredRefreshTokens(repo *repository.RefreshTokenRepository) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		purged, err := repo.DeleteExpired(ctx)
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			logger.Info(ctx, "Expired refresh tokens purged", map[string]interface{}{
+				"purged":      purged,
+				"duration_ms": time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+var (
+	shareLinksPurged   = expvar.NewInt("share_links_purged")    // links deleted, over all runs
+	shareLinkPurgeRuns = expvar.NewInt("share_link_purge_runs") // runs that completed
+	shareLinkPurgeLast = expvar.NewInt("share_link_purge_last") // links deleted by the last completed run
+)
+
+// PurgeExpiredShareLinks deletes share links that expired more than retention ago.
+// The retention window keeps recently expired links around so recipients still get
+// 410 Gone instead of 404 for a while. Counts are published on /debug/vars
+// (share_link_purge_*, share_links_purged).
+func PurgeExpiredShareLinks(repo *repository.ShareLinkRepository, retention time.Duration) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		cutoff := start.Add(-retention)
+
+		purged, err := repo.DeleteExpired(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		shareLinksPurged.Add(purged)
+		shareLinkPurgeRuns.Add(1)
+		shareLinkPurgeLast.Set(purged)
+
+		logger.Info(ctx, "Expired share links purged", map[string]interface{}{
+			"purged":      purged,
+			"cutoff":      cutoff.UTC().Format(time.RFC3339),
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+		return nil
+	}
+}
+
+// PreviewPurgeExpiredShareLinks previews PurgeExpiredShareLinks with the same retention.
+func PreviewPurgeExpiredShareLinks(repo *repository.ShareLinkRepository, retention time.Duration) Preview {
+	return func(ctx context.Context) (*model.CleanupPreview, error) {
+		p, err := repo.PreviewDeleteExpired(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return nil, err
+		}
+		p.Description = "share links expired past retention"
+		return p, nil
+	}
+}
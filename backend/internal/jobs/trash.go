@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// trashPurgeBatchSize bounds how many file rows one purge transaction locks.
+const trashPurgeBatchSize = 100
+
+// PurgeTrash permanently deletes files that have been in the trash longer than retention,
+// releasing their block references for the block GC job.
+func PurgeTrash(repo *repository.FileRepository, retention time.Duration) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		cutoff := start.Add(-retention)
+
+		var purged, released int64
+		for ctx.Err() == nil {
+			n, blocks, err := repo.PurgeTrashedBefore(ctx, cutoff, trashPurgeBatchSize)
+			if err != nil {
+				return err
+			}
+			purged += n
+			released += blocks
+			if n < trashPurgeBatchSize {
+				break
+			}
+		}
+
+		if purged > 0 {
+			logger.Info(ctx, "Expired trash purged", map[string]interface{}{
+				"files_purged":    purged,
+				"blocks_released": released,
+				"cutoff":          cutoff.UTC().Format(time.RFC3339),
+				"duration_ms":     time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// PreviewPurgeTrash previews PurgeTrash with the same retention.
+func PreviewPurgeTrash(repo *repository.FileRepository, retention time.Duration) Preview {
+	return func(ctx context.Context) (*model.CleanupPreview, error) {
+		p, err := repo.PreviewPurgeTrashedBefore(ctx, time.Now().Add(-retention))
+		if err != nil {
+			return nil, err
+		}
+		p.Description = "files in the trash past retention, permanently deleted"
+		return p, nil
+	}
+}
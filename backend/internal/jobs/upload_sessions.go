@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// uploadSessionPurgeBatchSize bounds how many sessions one purge transaction locks.
+const uploadSessionPurgeBatchSize = 100
+
+// PurgeExpiredUploadSessions discards upload sessions that were never finalized,
+// releasing the block references held by their chunks for the block GC job.
+func PurgeExpiredUploadSessions(repo *repository.UploadSessionRepository) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		var purged, released int64
+		for ctx.Err() == nil {
+			n, blocks, err := repo.DeleteExpired(ctx, uploadSessionPurgeBatchSize)
+			if err != nil {
+				return err
+			}
+			purged += n
+			released += blocks
+			if n < uploadSessionPurgeBatchSize {
+				break
+			}
+		}
+
+		if purged > 0 {
+			logger.Info(ctx, "Expired upload sessions purged", map[string]interface{}{
+				"sessions_purged": purged,
+				"blocks_released": released,
+				"duration_ms":     time.Since(start).Milliseconds(),
+			})
+		}
+		return nil
+	}
+}
+
+// PreviewPurgeExpiredUploadSessions previews PurgeExpiredUploadSessions.
+func PreviewPurgeExpiredUploadSessions(repo *repository.UploadSessionRepository) Preview {
+	return func(ctx context.Context) (*model.CleanupPreview, error) {
+		p, err := repo.PreviewDeleteExpired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.Description = "expired upload sessions discarded with their chunks"
+		return p, nil
+	}
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// ReconcileStorageUsage recomputes every user's used_bytes from their files and
+// corrects counters that drifted from the incremental accounting. Each drifted user
+// is logged so the code path that missed an update can be tracked down.
+func ReconcileStorageUsage(repo *repository.UserRepository) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		drift, checked, err := repo.FindUsageDrift(ctx)
+		if err != nil {
+			return err
+		}
+		if len(drift) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(drift))
+		for i, d := range drift {
+			ids[i] = d.UserID
+			logger.Warn(ctx, "Storage usage drift detected", map[string]interface{}{
+				"user_id":      d.UserID,
+				"used_bytes":   d.UsedBytes,
+				"actual_bytes": d.ActualBytes,
+				"delta_bytes":  d.UsedBytes - d.ActualBytes,
+			})
+		}
+		repaired, err := repo.RepairUsage(ctx, ids)
+		if err != nil {
+			return err
+		}
+
+		logger.Info(ctx, "Storage usage reconciled", map[string]interface{}{
+			"users_checked": checked,
+			"drifted":       len(drift),
+			"repaired":      repaired,
+			"duration_ms":   time.Since(start).Milliseconds(),
+		})
+		return nil
+	}
+}
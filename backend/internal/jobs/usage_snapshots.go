@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// SnapshotStorageUsage records today's per-user and per-top-level-folder usage and
+// drops snapshots older than retention (0 keeps them forever). Running it more than
+// once a day refreshes that day's snapshot.
+func SnapshotStorageUsage(repo *repository.UsageSnapshotRepository, retention time.Duration) Task {
+	return func(ctx context.Context) error {
+		start := time.Now()
+
+		written, err := repo.Snapshot(ctx, start)
+		if err != nil {
+			return err
+		}
+		var purged int64
+		if retention > 0 {
+			if purged, err = repo.DeleteOlderThan(ctx, start.Add(-retention)); err != nil {
+				return err
+			}
+		}
+
+		logger.Info(ctx, "Storage usage snapshot taken", map[string]interface{}{
+			"day":         start.UTC().Format(time.DateOnly),
+			"rows":        written,
+			"purged":      purged,
+			"duration_ms": time.Since(start).Milliseconds(),
+		})
+		return nil
+	}
+}
@@ -0,0 +1,147 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/audio"
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+// TaskAnalyzeAudio is the scheduler name of the AnalyzeAudio task, for Trigger.
+const TaskAnalyzeAudio = "analyze-audio"
+
+const (
+	// WaveformPeaks is how many peaks a waveform outline has (fewer for very short files).
+	WaveformPeaks = 1000
+
+	// audioEnqueueBatch caps how many never-analysed files one run queues, and
+	// audioAnalyzeBatch how many queued files it analyses.
+	audioEnqueueBatch = 100
+	audioAnalyzeBatch = 20
+
+	// audioClaimStale is when a file left running by a replica that died is retried.
+	audioClaimStale = 30 * time.Minute
+)
+
+// AnalyzeAudio fills in waveforms: it queues audio files that were never analysed
+// (files up to maxBytes, so new uploads are ready before anyone asks) and analyses
+// queued files one at a time with analyzer. Files that cannot be analysed are marked
+// failed and are not retried until their content changes.
+func AnalyzeAudio(waveRepo *repository.WaveformRepository, fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository,
+	s3 *storage.S3Client, analyzer *audio.Analyzer, maxBytes int64) Task {
+	return func(ctx context.Context) error {
+		if _, err := waveRepo.EnqueueMissing(ctx, maxBytes, audioEnqueueBatch); err != nil {
+			return err
+		}
+
+		var analysed, failed int
+		for i := 0; i < audioAnalyzeBatch && ctx.Err() == nil; i++ {
+			fileID, err := waveRepo.ClaimNext(ctx, audioClaimStale)
+			if err != nil {
+				return err
+			}
+			if fileID == 0 {
+				break
+			}
+
+			etag, err := analyzeAudioFile(ctx, waveRepo, fileRepo, blockRepo, s3, analyzer, maxBytes, fileID)
+			if ctx.Err() != nil {
+				// Shutting down: the claim goes stale and another run retries the file.
+				return ctx.Err()
+			}
+			if err != nil {
+				failed++
+				logger.Warn(ctx, "Audio analysis failed", map[string]interface{}{
+					"file_id": fileID, "error": err.Error(),
+				})
+				if err := waveRepo.Fail(ctx, fileID, etag, waveformFailure(err)); err != nil {
+					return err
+				}
+				continue
+			}
+			analysed++
+		}
+
+		if analysed+failed > 0 {
+			logger.Info(ctx, "Audio files analysed", map[string]interface{}{
+				"analysed": analysed, "failed": failed,
+			})
+		}
+		return nil
+	}
+}
+
+// errNotAnalysable marks files the analysis refuses before reading them.
+var errNotAnalysable = errors.New("not analysable")
+
+// analyzeAudioFile analyses one claimed file and stores the result. It returns the
+// content version it read, if it got that far.
+func analyzeAudioFile(ctx context.Context, waveRepo *repository.WaveformRepository, fileRepo *repository.FileRepository, blockRepo *repository.BlockRepository,
+	s3 *storage.S3Client, analyzer *audio.Analyzer, maxBytes int64, fileID int64) (string, error) {
+	// The queue holds files of every user; each is read under its own owner below.
+	file, err := fileRepo.FindByID(ctx, repository.Unscoped("audio analysis: file queued by ID"), fileID)
+	if err != nil {
+		return "", err
+	}
+	if !audio.Supported(file.MimeType, file.Name) {
+		return "", fmt.Errorf("%w: not an audio file", errNotAnalysable)
+	}
+	if file.TotalSize > maxBytes {
+		return "", fmt.Errorf("%w: file is over %d bytes", errNotAnalysable, maxBytes)
+	}
+
+	blocks, err := blockRepo.FindByFileID(ctx, repository.ForUser(file.UserID), file.ID)
+	if err != nil {
+		return "", err
+	}
+	etag := model.BlocksETag(blocks)
+
+	// ffmpeg seeks around some containers (e.g. MP4 with the index at the end), so the
+	// audio is copied to a temporary file rather than piped.
+	tmp, err := os.CreateTemp("", "waveform-*")
+	if err != nil {
+		return etag, err
+	}
+	defer os.Remove(tmp.Name())
+	err = block.BlocksToStream(ctx, blocks, s3, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return etag, err
+	}
+
+	a, err := analyzer.Analyze(ctx, tmp.Name(), file.TotalSize, WaveformPeaks)
+	if err != nil {
+		return etag, err
+	}
+	return etag, waveRepo.Complete(ctx, &model.Waveform{
+		FileID:     file.ID,
+		DurationMs: &a.DurationMs,
+		Bitrate:    &a.Bitrate,
+		SampleRate: &a.SampleRate,
+		Channels:   &a.Channels,
+		Codec:      &a.Codec,
+		Peaks:      a.Peaks,
+	}, etag)
+}
+
+// waveformFailure is the reason shown to users for a failed analysis; details such
+// as ffmpeg's output stay in the logs.
+func waveformFailure(err error) string {
+	switch {
+	case errors.Is(err, audio.ErrNoAudio):
+		return "the file has no audio that could be decoded"
+	case errors.Is(err, errNotAnalysable):
+		return err.Error()
+	}
+	return "the file could not be analysed"
+}
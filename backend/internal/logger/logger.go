@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +17,7 @@ const (
 	methodKey    contextKey = "method"
 	pathKey      contextKey = "path"
 	userIDKey    contextKey = "log_user_id"
+	stateKey     contextKey = "request_state"
 )
 
 // Entry represents a single structured log line (Grafana/Loki compatible).
@@ -73,7 +75,11 @@ func GetPath(ctx context.Context) string {
 }
 
 // WithUserID stores the user ID in the logging context.
+// It is also reported back to Middleware so the completion log carries it.
 func WithUserID(ctx context.Context, userID int64) context.Context {
+	if st, ok := ctx.Value(stateKey).(*requestState); ok {
+		st.setUserID(userID)
+	}
 	return context.WithValue(ctx, userIDKey, userID)
 }
 
@@ -87,8 +93,27 @@ func GetUserID(ctx context.Context) (int64, bool) {
 
 // ─── Logging Functions ─────────────────────────────────────────────────────────
 
+// levels orders the log levels; entries below the level set with SetLevel are dropped.
+var levels = map[string]int32{"info": 0, "warn": 1, "error": 2}
+
+var minLevel atomic.Int32
+
+// SetLevel drops entries below level ("info", "warn" or "error") from now on. It can be
+// called at any time, e.g. when the configuration is reloaded.
+func SetLevel(level string) error {
+	l, ok := levels[level]
+	if !ok {
+		return fmt.Errorf("logger.SetLevel: unknown level %q (want info, warn or error)", level)
+	}
+	minLevel.Store(l)
+	return nil
+}
+
 // emit writes a single JSON log line to stdout.
 func emit(entry Entry) {
+	if levels[entry.Level] < minLevel.Load() {
+		return
+	}
 	if entry.Timestamp == "" {
 		entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
 	}
@@ -153,10 +178,13 @@ type QueryAttributes struct {
 	RowsAffected int64  `json:"rows_affected"`
 }
 
-// SlowQueryMetrics holds warning metrics for slow queries.
+// SlowQueryMetrics holds warning metrics for slow queries. Query names the repository
+// method and ParamsHash identifies its arguments; both are empty for slow requests.
 type SlowQueryMetrics struct {
-	ExecutionTimeMs int64 `json:"executionTimeMs"`
-	ThresholdMs     int64 `json:"thresholdMs"`
+	ExecutionTimeMs int64  `json:"executionTimeMs"`
+	ThresholdMs     int64  `json:"thresholdMs"`
+	Query           string `json:"query,omitempty"`
+	ParamsHash      string `json:"paramsHash,omitempty"`
 }
 
 // ─── Startup / Plain Logs ──────────────────────────────────────────────────────
@@ -1,18 +1,56 @@
 package logger
 
 import (
+	"context"
+	"encoding/json"
+	"expvar"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+// Per-route traffic counters, keyed by "METHOD /route/{pattern}".
+// Published through expvar so they can be scraped without parsing logs.
+var (
+	routeRequests = expvar.NewMap("http_route_requests")
+	routeBytesIn  = expvar.NewMap("http_route_bytes_in")
+	routeBytesOut = expvar.NewMap("http_route_bytes_out")
+)
+
+// maxErrorBodyCapture bounds how much of an error response is kept to extract its code.
+const maxErrorBodyCapture = 1024
+
+// requestState is shared between Middleware and inner handlers so that values
+// discovered deeper in the chain (e.g. the authenticated user) reach the completion log.
+type requestState struct {
+	mu      sync.Mutex
+	userID  int64
+	hasUser bool
+}
+
+func (s *requestState) setUserID(id int64) {
+	s.mu.Lock()
+	s.userID, s.hasUser = id, true
+	s.mu.Unlock()
+}
+
+func (s *requestState) getUserID() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.userID, s.hasUser
+}
+
 // responseWriter wraps http.ResponseWriter to capture the status code.
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
 	written    int64
+	errBody    []byte // start of the body for 4xx/5xx responses
 }
 
 func newResponseWriter(w http.ResponseWriter) *responseWriter {
@@ -25,6 +63,13 @@ func (rw *responseWriter) WriteHeader(code int) {
 }
 
 func (rw *responseWriter) Write(b []byte) (int, error) {
+	if rw.statusCode >= 400 && len(rw.errBody) < maxErrorBodyCapture {
+		n := maxErrorBodyCapture - len(rw.errBody)
+		if n > len(b) {
+			n = len(b)
+		}
+		rw.errBody = append(rw.errBody, b[:n]...)
+	}
 	n, err := rw.ResponseWriter.Write(b)
 	rw.written += int64(n)
 	return n, err
@@ -37,50 +82,122 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to lift the
+// write deadline for long-lived streams.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// errorCode extracts the "error" field of an ErrorResponse-shaped body, if any.
+func (rw *responseWriter) errorCode() string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	if len(rw.errBody) == 0 || json.Unmarshal(rw.errBody, &body) != nil {
+		return ""
+	}
+	return body.Error
+}
+
+// countingReader counts request body bytes consumed by the handler.
+type countingReader struct {
+	io.ReadCloser
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
 // Middleware injects requestId, method, and path into the context,
 // logs the incoming request and the completed response.
+// The completion entry carries the authenticated user, route pattern, bytes in/out
+// and the response error code, so per-user traffic can be accounted from logs alone.
 func Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Generate or reuse X-Request-Id header
+		// Generate or reuse X-Request-Id header. It is passed on to S3 and the database,
+		// so a client-supplied ID is only kept if it is short and plain.
 		requestID := r.Header.Get("X-Request-Id")
-		if requestID == "" {
+		if !validRequestID(requestID) {
 			requestID = uuid.New().String()
 		}
 
 		// Inject into context
+		state := &requestState{}
 		ctx := WithRequestID(r.Context(), requestID)
 		ctx = WithMethod(ctx, r.Method)
 		ctx = WithPath(ctx, r.URL.Path)
+		ctx = context.WithValue(ctx, stateKey, state)
 
 		// Set response header for tracing
 		w.Header().Set("X-Request-Id", requestID)
 
 		// Log incoming request
 		Info(ctx, "Incoming request", map[string]interface{}{
-			"remote_addr":    r.RemoteAddr,
+			"remote_addr":    r.RemoteAddr, // client IP when behind a trusted proxy
 			"user_agent":     r.UserAgent(),
 			"content_length": r.ContentLength,
 			"query":          r.URL.RawQuery,
 		})
 
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+
 		wrapped := newResponseWriter(w)
 		next.ServeHTTP(wrapped, r.WithContext(ctx))
 
 		duration := time.Since(start)
 
+		// Unmatched paths share one key so scanners cannot grow the counters without bound.
+		route := "(unmatched)"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		routeKey := r.Method + " " + route
+		routeRequests.Add(routeKey, 1)
+		routeBytesIn.Add(routeKey, body.read)
+		routeBytesOut.Add(routeKey, wrapped.written)
+
 		// Log completed response
 		attrs := map[string]interface{}{
-			"status_code":  wrapped.statusCode,
-			"duration_ms":  duration.Milliseconds(),
+			"status_code":   wrapped.statusCode,
+			"duration_ms":   duration.Milliseconds(),
+			"route":         route,
+			"bytes_read":    body.read,
 			"bytes_written": wrapped.written,
 		}
+		if userID, ok := state.getUserID(); ok {
+			attrs["user_id"] = userID
+		}
+		errCode := ""
+		if wrapped.statusCode >= 400 {
+			if errCode = wrapped.errorCode(); errCode != "" {
+				attrs["error_code"] = errCode
+			}
+		}
 
 		if wrapped.statusCode >= 500 {
-			ErrorLog(ctx, fmt.Sprintf("Request completed with server error %d", wrapped.statusCode), ErrorDetails{
-				Code:    fmt.Sprintf("HTTP_%d", wrapped.statusCode),
-				Details: fmt.Sprintf("%s %s responded %d in %dms", r.Method, r.URL.Path, wrapped.statusCode, duration.Milliseconds()),
+			code := fmt.Sprintf("HTTP_%d", wrapped.statusCode)
+			if errCode != "" {
+				code += ":" + errCode
+			}
+			emit(Entry{
+				Level:      "error",
+				RequestID:  requestID,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Message:    fmt.Sprintf("Request completed with server error %d", wrapped.statusCode),
+				Attributes: attrs,
+				Error: ErrorDetails{
+					Code:    code,
+					Details: fmt.Sprintf("%s %s responded %d in %dms", r.Method, r.URL.Path, wrapped.statusCode, duration.Milliseconds()),
+				},
 			})
 		} else if wrapped.statusCode >= 400 {
 			Warn(ctx, fmt.Sprintf("Request completed with client error %d", wrapped.statusCode), attrs)
@@ -97,3 +214,17 @@ func Middleware(next http.Handler) http.Handler {
 		}
 	})
 }
+
+// validRequestID reports whether a client-supplied request ID is safe to reuse in
+// headers, logs and connection names: 1-64 letters, digits, '.', '_' or '-'.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
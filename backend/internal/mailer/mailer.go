@@ -0,0 +1,266 @@
+// Package mailer renders and sends outbound email. Messages are queued and delivered
+// by a background worker that retries failed sends with backoff, so request handlers
+// never wait on the SMTP server.
+package mailer
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+const (
+	retryBaseBackoff = 30 * time.Second
+	retryMaxBackoff  = 30 * time.Minute
+
+	// sendTimeout bounds one delivery attempt, including connecting.
+	sendTimeout = 30 * time.Second
+)
+
+var (
+	mailSent       = expvar.NewInt("mail_sent")
+	mailFailed     = expvar.NewInt("mail_failed")
+	mailDeadLetter = expvar.NewInt("mail_dead_lettered")
+)
+
+// ErrQueueFull is returned by Send when the outbound queue has no room left.
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Config configures a Mailer. An empty SMTPHost or LogOnly selects log-only mode, where
+// messages are written to the log instead of sent, for development.
+type Config struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPTLS is "starttls" (upgrade a plain connection), "tls" (implicit TLS, usually
+	// port 465) or "none".
+	SMTPTLS string
+	From    string
+	LogOnly bool
+
+	// Brand and BaseURL are available to templates as .Brand and .BaseURL.
+	Brand   string
+	BaseURL string
+
+	QueueSize   int
+	MaxAttempts int
+}
+
+// Message is a rendered email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type sender interface {
+	send(ctx context.Context, msg *Message) error
+}
+
+type envelope struct {
+	msg      *Message
+	attempts int
+	next     time.Time
+}
+
+// Mailer queues messages and delivers them in the background; call Start before
+// sending and Stop on shutdown.
+type Mailer struct {
+	sender      sender
+	brand       string
+	baseURL     string
+	maxAttempts int
+
+	queue  chan *envelope
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Mailer from cfg.
+func New(cfg Config) (*Mailer, error) {
+	var s sender
+	if cfg.LogOnly || cfg.SMTPHost == "" {
+		s = logSender{}
+	} else {
+		smtp, err := newSMTPSender(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("mailer.New: %w", err)
+		}
+		s = smtp
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mailer{
+		sender:      s,
+		brand:       cfg.Brand,
+		baseURL:     cfg.BaseURL,
+		maxAttempts: cfg.MaxAttempts,
+		queue:       make(chan *envelope, cfg.QueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+	}, nil
+}
+
+// LogOnly reports whether messages are logged rather than sent.
+func (m *Mailer) LogOnly() bool {
+	_, ok := m.sender.(logSender)
+	return ok
+}
+
+// Send renders tmpl in lang (see i18n.Negotiate) and queues it for delivery to to.
+// data is the template's data struct, e.g. VerificationData.
+func (m *Mailer) Send(ctx context.Context, to, lang string, tmpl Template, data interface{}) error {
+	msg, err := m.Render(to, lang, tmpl, data)
+	if err != nil {
+		return err
+	}
+	select {
+	case m.queue <- &envelope{msg: msg}:
+		return nil
+	default:
+		logger.Warn(ctx, "Mail queue full, message dropped", map[string]interface{}{
+			"template": string(tmpl),
+		})
+		return ErrQueueFull
+	}
+}
+
+// Start launches the delivery worker.
+func (m *Mailer) Start() {
+	m.wg.Add(1)
+	go m.loop()
+	if m.LogOnly() {
+		logger.Infof("Mailer in log-only mode: messages are logged, not sent")
+	}
+}
+
+// Stop halts delivery and waits for an in-flight send to finish or for ctx to expire.
+// Messages still queued are logged and dropped.
+func (m *Mailer) Stop(ctx context.Context) {
+	m.cancel()
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (m *Mailer) loop() {
+	defer m.wg.Done()
+	ctx := logger.WithMethod(m.ctx, "INTERNAL")
+	ctx = logger.WithPath(ctx, "Task/mailer")
+
+	var retries []*envelope
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		if len(retries) > 0 {
+			timer.Reset(time.Until(retries[0].next))
+		}
+		select {
+		case <-m.ctx.Done():
+			if n := len(retries) + len(m.queue); n > 0 {
+				logger.Warn(ctx, "Mailer stopped with unsent messages", map[string]interface{}{"dropped": n})
+			}
+			return
+		case e := <-m.queue:
+			retries = m.deliver(ctx, e, retries)
+		case <-timer.C:
+			now := time.Now()
+			due := retries
+			retries = nil
+			for _, e := range due {
+				if e.next.After(now) {
+					retries = insertRetry(retries, e)
+					continue
+				}
+				retries = m.deliver(ctx, e, retries)
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// deliver makes one attempt at e and returns retries with e added if it should be tried again.
+func (m *Mailer) deliver(ctx context.Context, e *envelope, retries []*envelope) []*envelope {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	err := m.sender.send(sendCtx, e.msg)
+	cancel()
+	e.attempts++
+	if err == nil {
+		mailSent.Add(1)
+		return retries
+	}
+
+	mailFailed.Add(1)
+	if e.attempts >= m.maxAttempts {
+		mailDeadLetter.Add(1)
+		logger.ErrorLog(ctx, "Mail dead-lettered", logger.ErrorDetails{
+			Code:    "MAIL_DEAD_LETTER",
+			Details: fmt.Sprintf("to=%s subject=%q attempts=%d: %s", e.msg.To, e.msg.Subject, e.attempts, err.Error()),
+		})
+		return retries
+	}
+	e.next = time.Now().Add(retryBackoff(e.attempts))
+	logger.Warn(ctx, "Mail delivery failed, will retry", map[string]interface{}{
+		"to": e.msg.To, "attempts": e.attempts, "retry_at": e.next, "error": err.Error(),
+	})
+	return insertRetry(retries, e)
+}
+
+// insertRetry adds e to retries, which is kept ordered by next attempt time.
+func insertRetry(retries []*envelope, e *envelope) []*envelope {
+	i := len(retries)
+	for i > 0 && retries[i-1].next.After(e.next) {
+		i--
+	}
+	retries = append(retries, nil)
+	copy(retries[i+1:], retries[i:])
+	retries[i] = e
+	return retries
+}
+
+// retryBackoff doubles the retry delay with every failed attempt, up to retryMaxBackoff.
+func retryBackoff(attempts int) time.Duration {
+	d := retryBaseBackoff
+	for i := 1; i < attempts && d < retryMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+	return d
+}
+
+// logSender writes messages to the log instead of sending them.
+type logSender struct{}
+
+func (logSender) send(ctx context.Context, msg *Message) error {
+	logger.Info(ctx, "Mail (log only)", map[string]interface{}{
+		"to": msg.To, "subject": msg.Subject, "text": msg.Text,
+	})
+	return nil
+}
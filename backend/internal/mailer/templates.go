@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/naratel/naratel-box/backend/internal/i18n"
+)
+
+// Template names an email template. Each has templates/<name>.txt defining "subject"
+// and "text", and templates/<name>.html defining "content" for the shared layout.
+type Template string
+
+const (
+	TemplateVerification      Template = "verification"       // data: VerificationData
+	TemplatePasswordReset     Template = "password_reset"     // data: PasswordResetData
+	TemplateShareNotification Template = "share_notification" // data: ShareNotificationData
+	TemplateQuotaWarning      Template = "quota_warning"      // data: QuotaWarningData
+	TemplateInvite            Template = "invite"             // data: InviteData
+)
+
+// VerificationData is the data of TemplateVerification.
+type VerificationData struct {
+	Name         string
+	URL          string
+	ExpiresHours int
+}
+
+// PasswordResetData is the data of TemplatePasswordReset.
+type PasswordResetData struct {
+	Name         string
+	URL          string
+	ExpiresHours int
+}
+
+// ShareNotificationData is the data of TemplateShareNotification.
+type ShareNotificationData struct {
+	SenderName string
+	FileName   string
+	Message    string // optional note from the sender
+	URL        string
+}
+
+// QuotaWarningData is the data of TemplateQuotaWarning.
+type QuotaWarningData struct {
+	Name       string
+	UsedBytes  int64
+	QuotaBytes int64
+	Percent    int
+}
+
+// InviteData is the data of TemplateInvite.
+type InviteData struct {
+	Name         string
+	InvitedBy    string
+	URL          string
+	ExpiresHours int
+}
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var templateFuncs = map[string]interface{}{
+	"t":    i18n.T,
+	"size": formatBytes,
+	"dict": dict,
+}
+
+type parsedTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = mustParseTemplates(TemplateVerification, TemplatePasswordReset, TemplateShareNotification, TemplateQuotaWarning, TemplateInvite)
+
+func mustParseTemplates(names ...Template) map[Template]*parsedTemplate {
+	out := make(map[Template]*parsedTemplate, len(names))
+	for _, name := range names {
+		text := texttemplate.Must(texttemplate.New("").Funcs(templateFuncs).
+			ParseFS(templateFS, "templates/"+string(name)+".txt"))
+		html := htmltemplate.Must(htmltemplate.New("").Funcs(templateFuncs).
+			ParseFS(templateFS, "templates/layout.html", "templates/"+string(name)+".html"))
+		out[name] = &parsedTemplate{text: text, html: html}
+	}
+	return out
+}
+
+// templateData is what templates execute against; Data is the template's own struct.
+type templateData struct {
+	Lang    string
+	Brand   string
+	BaseURL string
+	Data    interface{}
+}
+
+// Render builds the message tmpl produces for data in lang without sending it.
+func (m *Mailer) Render(to, lang string, tmpl Template, data interface{}) (*Message, error) {
+	t, ok := templates[tmpl]
+	if !ok {
+		return nil, fmt.Errorf("mailer.Render: unknown template %q", tmpl)
+	}
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		return nil, fmt.Errorf("mailer.Render: invalid recipient %q: %w", to, err)
+	}
+	td := templateData{Lang: lang, Brand: m.brand, BaseURL: m.baseURL, Data: data}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", td); err != nil {
+		return nil, fmt.Errorf("mailer.Render %s subject: %w", tmpl, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", td); err != nil {
+		return nil, fmt.Errorf("mailer.Render %s text: %w", tmpl, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", td); err != nil {
+		return nil, fmt.Errorf("mailer.Render %s html: %w", tmpl, err)
+	}
+	return &Message{
+		To:      addr.Address,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
+
+// dict builds a map from alternating keys and values, for passing several values
+// to a nested template.
+func dict(kv ...interface{}) (map[string]interface{}, error) {
+	if len(kv)%2 != 0 {
+		return nil, fmt.Errorf("dict: odd number of arguments")
+	}
+	m := make(map[string]interface{}, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", kv[i])
+		}
+		m[k] = kv[i+1]
+	}
+	return m, nil
+}
+
+// formatBytes renders a byte count as a short human-readable size.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// Abuse report reasons accepted by POST /share/{token}/report.
+var AbuseReasons = []string{"copyright", "malware", "phishing", "illegal", "harassment", "spam", "other"}
+
+// Abuse report statuses and the actions an admin can resolve one with.
+const (
+	AbuseStatusOpen      = "open"
+	AbuseStatusDismissed = "dismissed"
+	AbuseStatusActioned  = "actioned"
+
+	AbuseActionLinkDisabled = "link_disabled"
+	AbuseActionFileDisabled = "file_disabled"
+)
+
+// AbuseReport is a complaint filed by anyone holding a share link.
+type AbuseReport struct {
+	ID            int64      `json:"id"`
+	ShareLinkID   *int64     `json:"share_link_id"` // nil once the link is deleted
+	FileID        *int64     `json:"file_id"`       // the link's file at report time; nil once purged
+	Token         string     `json:"token"`
+	Reason        string     `json:"reason"`
+	Details       string     `json:"details"`
+	ReporterEmail *string    `json:"reporter_email,omitempty"`
+	ReporterIP    string     `json:"reporter_ip"`
+	Status        string     `json:"status"`
+	Action        *string    `json:"action,omitempty"`
+	ResolvedBy    *int64     `json:"resolved_by,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// AbuseReportWithFile is a report joined with what the admin needs to judge it.
+type AbuseReportWithFile struct {
+	AbuseReport
+	FileName    *string `json:"file_name"`
+	MimeType    *string `json:"mime_type"`
+	OwnerID     *int64  `json:"owner_id"`
+	LinkEnabled *bool   `json:"link_enabled"`
+	OpenReports int64   `json:"open_reports"` // open reports against the same file, this one included
+}
+
+// Takedown is the outcome of disabling a reported link or file.
+type Takedown struct {
+	Action          string `json:"action"`
+	FileID          int64  `json:"file_id"`
+	FileName        string `json:"file_name"`
+	OwnerID         int64  `json:"owner_id"`
+	LinksDisabled   int64  `json:"links_disabled"`
+	ReportsResolved int64  `json:"reports_resolved"`
+}
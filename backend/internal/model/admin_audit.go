@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AdminAuditEntry records one state-changing admin request. Before and After hold
+// the target's state around the change when the handler described it; Status is the
+// HTTP status the request ended with, so refused and failed attempts are kept too.
+type AdminAuditEntry struct {
+	ID         int64           `json:"id"`
+	ActorID    int64           `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Status     int             `json:"status"`
+	IPAddress  string          `json:"ip_address"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AdminAuditFilter narrows an audit trail query. Zero fields don't filter.
+type AdminAuditFilter struct {
+	ActorID    int64
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	BeforeID   int64 // keyset pagination: only entries older than this ID
+	Limit      int
+}
@@ -1,13 +1,54 @@
 package model
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
 
 // Block represents a deduplicated chunk of file data stored in S3.
 type Block struct {
-	ID         int64     `json:"id"`
-	SHA256Hash string    `json:"sha256_hash"` // hex-encoded, also used as S3 key
-	S3Key      string    `json:"s3_key"`
-	SizeBytes  int64     `json:"size_bytes"`
-	RefCount   int       `json:"ref_count"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          int64     `json:"id"`
+	SHA256Hash  string    `json:"sha256_hash"` // hex-encoded
+	S3Key       string    `json:"s3_key"`      // the hash itself, or HMAC of it when BLOCK_KEY_SECRET is set
+	SizeBytes   int64     `json:"size_bytes"`
+	Compression string    `json:"compression,omitempty"` // algorithm of the S3 object ("zstd"); empty when stored as is
+	StoredBytes int64     `json:"stored_bytes"`          // size of the S3 object
+	RefCount    int       `json:"ref_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BlocksETag derives a strong ETag for a file from its ordered blocks' hashes, which
+// identify the content exactly without reading it.
+func BlocksETag(blocks []*Block) string {
+	h := sha256.New()
+	for _, b := range blocks {
+		h.Write([]byte(b.SHA256Hash))
+		h.Write([]byte{'\n'})
+	}
+	return ContentETag(hex.EncodeToString(h.Sum(nil)))
+}
+
+// BlockRefDrift is a block whose stored ref_count disagrees with its actual references.
+type BlockRefDrift struct {
+	BlockID    int64  `json:"block_id"`
+	SHA256Hash string `json:"sha256_hash"`
+	RefCount   int    `json:"ref_count"`   // stored value
+	ActualRefs int    `json:"actual_refs"` // counted from referencing rows
+}
+
+// BlockIntegrityReport is the result of a block reference integrity check.
+type BlockIntegrityReport struct {
+	CheckedAt     time.Time        `json:"checked_at"`
+	BlocksChecked int64            `json:"blocks_checked"`
+	Discrepancies []*BlockRefDrift `json:"discrepancies"`
+	Unreferenced  int64            `json:"unreferenced"` // blocks with no references left (garbage)
+	Repaired      int64            `json:"repaired"`
+}
+
+// BlockRekeyReport is the result of moving blocks to the keys the current secret derives.
+type BlockRekeyReport struct {
+	BlocksChecked int64 `json:"blocks_checked"`
+	Rekeyed       int64 `json:"rekeyed"`
+	Failed        int64 `json:"failed"` // left on their old key; rerun the job to retry
 }
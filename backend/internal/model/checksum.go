@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// FileChecksums documents a file's fixity: the SHA-256 of its content and of each
+// block, with where the block lies in the file. The hashes are nil until known; they
+// are recorded at upload, except for resumable uploads, and by each verification.
+type FileChecksums struct {
+	FileID     int64            `json:"file_id"`
+	Size       int64            `json:"size"`
+	Algorithm  string           `json:"algorithm" example:"sha256"`
+	SHA256     *string          `json:"sha256"`
+	Blocks     []*BlockChecksum `json:"blocks"`
+	ComputedAt *time.Time       `json:"computed_at"` // when the hashes were recorded
+	VerifiedAt *time.Time       `json:"verified_at"` // last successful verification against S3
+}
+
+// BlockChecksum is one block of a file in FileChecksums.
+type BlockChecksum struct {
+	Index  int     `json:"index"`
+	Offset int64   `json:"offset"`
+	Size   int64   `json:"size"`
+	SHA256 *string `json:"sha256"`
+}
+
+// StoredChecksums is a file_checksums row.
+type StoredChecksums struct {
+	FileID      int64
+	ContentHash string // block digest of the content the hashes belong to
+	SHA256      string
+	BlockSHA256 []string
+	ComputedAt  time.Time
+	VerifiedAt  *time.Time
+}
+
+// FileVerification is the result of re-reading a file from S3 to confirm its
+// integrity, stored as the result of a file_verify job. OK means every block still
+// matches its recorded hash and the content matches the checksum recorded before,
+// if there was one.
+type FileVerification struct {
+	FileID         int64     `json:"file_id"`
+	OK             bool      `json:"ok"`
+	SHA256         string    `json:"sha256"`                    // of the content read
+	ExpectedSHA256 *string   `json:"expected_sha256,omitempty"` // recorded before, if any
+	BlocksChecked  int       `json:"blocks_checked"`
+	Mismatched     []int     `json:"mismatched_blocks"` // indexes of blocks that differ from their record
+	VerifiedAt     time.Time `json:"verified_at"`
+}
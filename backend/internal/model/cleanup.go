@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// CleanupPreview is what one destructive maintenance task would remove if it ran now.
+// Blocks are those whose last reference the task removes; their storage is freed by the
+// block GC (for the GC itself, at once). Queued S3 deletions no longer know the size of
+// their objects, so they report no bytes.
+type CleanupPreview struct {
+	Task        string `json:"task"`        // scheduler task name
+	Description string `json:"description"` // what the task removes
+	Items       int64  `json:"items"`       // rows (files, sessions, links, objects) it would delete
+	Blocks      int64  `json:"blocks"`
+	BytesFreed  int64  `json:"bytes_freed"` // S3 storage: compressed size for compressed blocks
+}
+
+// CleanupReport previews every destructive maintenance task.
+type CleanupReport struct {
+	DryRun      bool              `json:"dry_run"` // MAINTENANCE_DRY_RUN: scheduled runs only log their preview
+	GeneratedAt time.Time         `json:"generated_at"`
+	Tasks       []*CleanupPreview `json:"tasks"`
+	BytesFreed  int64             `json:"bytes_freed"` // over all tasks
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// NearDuplicate is a pair of one user's files that share most of their blocks, such
+// as two exports of the same media that differ in a few places. Deduplication
+// already stores the shared blocks once; deleting one file frees the rest.
+type NearDuplicate struct {
+	UserID        int64          `json:"user_id"`
+	File          *DuplicateFile `json:"file"`
+	Other         *DuplicateFile `json:"other"`
+	SharedBlocks  int64          `json:"shared_blocks"`
+	SharedBytes   int64          `json:"shared_bytes"`
+	SharedPercent int            `json:"shared_percent"` // of the blocks of whichever file has more
+}
+
+// DuplicateFile is one side of a NearDuplicate.
+type DuplicateFile struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	FolderID  *int64    `json:"folder_id"`
+	TotalSize int64     `json:"total_size"`
+	Blocks    int64     `json:"blocks"` // distinct blocks
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NearDuplicateFilter selects near-duplicate pairs.
+type NearDuplicateFilter struct {
+	MinSharedPercent int   // share of the larger file's blocks, 1-100
+	MinSize          int64 // both files at least this many bytes
+	Limit            int
+}
@@ -0,0 +1,25 @@
+package model
+
+// Feature flags. A feature is available to a user unless the deployment disables it
+// (FEATURES_DISABLED) or their plan switches it off.
+const (
+	FeaturePublicLinks     = "public_links"       // share links to files and folders, snippets
+	FeatureFileDrops       = "file_drops"         // creating file-drop links
+	FeatureShareUploads    = "share_uploads"      // folder share links that accept uploads
+	FeatureDownloadBundles = "download_bundles"   // multi-file zip downloads
+	FeaturePush            = "push_notifications" // registering push devices
+	FeatureWebDAV          = "webdav"             // mounting files over WebDAV at /dav/
+)
+
+// Features lists every feature flag, e.g. for validating plans and configuration.
+var Features = []string{FeaturePublicLinks, FeatureFileDrops, FeatureShareUploads, FeatureDownloadBundles, FeaturePush, FeatureWebDAV}
+
+// IsFeature reports whether name is a known feature flag.
+func IsFeature(name string) bool {
+	for _, f := range Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -4,16 +4,31 @@ import "time"
 
 // File represents a file uploaded by a user.
 type File struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	FolderID  *int64    `json:"folder_id"` // nil = root level
-	Name      string    `json:"name"`
-	MimeType  string    `json:"mime_type"`
-	TotalSize int64     `json:"total_size"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                  int64      `json:"id"`
+	UserID              int64      `json:"user_id"`
+	FolderID            *int64     `json:"folder_id"` // nil = root level
+	Name                string     `json:"name"`
+	MimeType            string     `json:"mime_type"`
+	TotalSize           int64      `json:"total_size"`
+	BlockSize           int        `json:"block_size"` // bytes per block at upload time (average for content-defined blocks); 0 = unknown (pre-adaptive uploads)
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	DeletedAt           *time.Time `json:"deleted_at,omitempty"`             // set while the file is in the trash
+	TrashedFromFolderID *int64     `json:"trashed_from_folder_id,omitempty"` // folder the file was trashed from; nil = root
+	ModifiedBy          *int64     `json:"modified_by"`                      // user who made the last change; nil = the system (e.g. expiry) or a deleted user
+	ModifiedClient      *string    `json:"modified_client,omitempty"`        // client/device that made it (X-Client or User-Agent)
+	ExpiresAt           *time.Time `json:"expires_at"`                       // moved to the trash after this; nil = never
+	TakenDownAt         *time.Time `json:"taken_down_at,omitempty"`          // set by an admin after an abuse report; the file can no longer be shared
+	ScanStatus          *string    `json:"scan_status,omitempty"`            // malware scan verdict (ScanPending/ScanClean/ScanInfected); nil = not scanned
 }
 
+// Malware scan verdicts reported for a file.
+const (
+	ScanPending  = "pending"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+)
+
 // FileBlock maps an ordered block to a file.
 type FileBlock struct {
 	ID         int64 `json:"id"`
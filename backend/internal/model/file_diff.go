@@ -0,0 +1,83 @@
+package model
+
+// FileDiff compares two files by the content of their blocks: a block of the other
+// file is unchanged when the file has a block with the same content anywhere, so
+// content inserted or removed mid-file only marks the blocks around the edit changed
+// when content-defined chunking let the rest deduplicate. Files cut into blocks
+// differently (another chunking mode or block size) share few blocks and mostly show
+// as changed even when their content is close.
+type FileDiff struct {
+	FileID        int64        `json:"file_id"`
+	OtherID       int64        `json:"other_id"`
+	ETag          string       `json:"etag"`       // of the file's content
+	OtherETag     string       `json:"other_etag"` // of the other file's content; send as If-Range when fetching changed ranges
+	Identical     bool         `json:"identical"`
+	Blocks        int          `json:"blocks"`
+	OtherBlocks   int          `json:"other_blocks"`
+	Changed       []int        `json:"changed_blocks"` // indexes of the other file's blocks whose content the file does not have
+	Removed       int          `json:"removed_blocks"` // blocks of the file whose content the other file does not have
+	ChangedRanges []*DiffRange `json:"changed_ranges"` // byte ranges of the other file covering the changed blocks, merged
+	ChangedBytes  int64        `json:"changed_bytes"`
+}
+
+// DiffRange is an inclusive byte range of a file, as in a Range header.
+type DiffRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// DiffBlocks compares the ordered blocks of two files of one user. Blocks stored under
+// different dedup scopes have different hashes for the same content; userKey maps a
+// global-scope hash to the user's scope (see block.UserKey) so they still match.
+func DiffBlocks(blocks, other []*Block, userKey func(hash string) string) *FileDiff {
+	d := &FileDiff{
+		ETag:          BlocksETag(blocks),
+		OtherETag:     BlocksETag(other),
+		Blocks:        len(blocks),
+		OtherBlocks:   len(other),
+		Changed:       []int{},
+		ChangedRanges: []*DiffRange{},
+	}
+	// Index both files under both forms of each hash: a global-scope hash and the
+	// user-scope hash of the same content then meet in the same entry.
+	index := func(bs []*Block) map[string]bool {
+		m := make(map[string]bool, 2*len(bs))
+		for _, b := range bs {
+			m[b.SHA256Hash] = true
+			m[userKey(b.SHA256Hash)] = true
+		}
+		return m
+	}
+	has := func(m map[string]bool, b *Block) bool {
+		return m[b.SHA256Hash] || m[userKey(b.SHA256Hash)]
+	}
+
+	have := index(blocks)
+	var offset int64
+	for i, b := range other {
+		if !has(have, b) {
+			d.Changed = append(d.Changed, i)
+			d.ChangedBytes += b.SizeBytes
+			if n := len(d.ChangedRanges); n > 0 && d.ChangedRanges[n-1].End == offset-1 {
+				d.ChangedRanges[n-1].End = offset + b.SizeBytes - 1
+			} else if b.SizeBytes > 0 {
+				d.ChangedRanges = append(d.ChangedRanges, &DiffRange{Start: offset, End: offset + b.SizeBytes - 1})
+			}
+		}
+		offset += b.SizeBytes
+	}
+
+	kept := index(other)
+	for _, b := range blocks {
+		if !has(kept, b) {
+			d.Removed++
+		}
+	}
+
+	d.Identical = len(blocks) == len(other)
+	for i := 0; d.Identical && i < len(blocks); i++ {
+		a, b := blocks[i].SHA256Hash, other[i].SHA256Hash
+		d.Identical = a == b || userKey(a) == b || userKey(b) == a
+	}
+	return d
+}
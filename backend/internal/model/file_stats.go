@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// FileStats holds access counters for a file, split by who accessed it.
+type FileStats struct {
+	FileID         int64      `json:"file_id"`
+	OwnerDownloads int64      `json:"owner_downloads"`
+	OwnerPreviews  int64      `json:"owner_previews"`
+	ShareDownloads int64      `json:"share_downloads"` // via public share links
+	SharePreviews  int64      `json:"share_previews"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+}
@@ -4,10 +4,70 @@ import "time"
 
 // Folder represents a directory in the user's file system.
 type Folder struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	ParentID  *int64    `json:"parent_id"` // nil = root level
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	ParentID    *int64     `json:"parent_id"` // nil = root level
+	Name        string     `json:"name"`
+	Color       *string    `json:"color"` // "#rrggbb"; nil = not set
+	Icon        *string    `json:"icon"`  // icon identifier understood by the client
+	Description *string    `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"` // deleted with its contents after this; nil = never
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// FolderShareSettings are the share defaults set directly on a folder.
+// A nil field means "inherit from the parent folder".
+type FolderShareSettings struct {
+	FolderID           int64 `json:"folder_id"`
+	DefaultExpiryHours *int  `json:"default_expiry_hours"`
+	RequirePassword    *bool `json:"require_password"`
+	AllowPublicLinks   *bool `json:"allow_public_links"`
+}
+
+// EffectiveShareSettings are the share rules that apply to a file after
+// resolving inheritance up the folder tree.
+type EffectiveShareSettings struct {
+	DefaultExpiryHours *int `json:"default_expiry_hours"` // nil = system default
+	RequirePassword    bool `json:"require_password"`
+	AllowPublicLinks   bool `json:"allow_public_links"`
+}
+
+// FolderEntry is a subfolder in a folder listing, with a summary of its direct contents.
+type FolderEntry struct {
+	*Folder
+	ItemCount int64 `json:"item_count"` // direct subfolders + files
+	SizeBytes int64 `json:"size_bytes"` // total size of the files directly inside
+}
+
+// FileEntry is a file in a folder listing.
+type FileEntry struct {
+	*File
+	Shared bool `json:"shared"` // has at least one enabled, unexpired share link
+	ContentDigest
+}
+
+// ContentDigest lets sync clients tell whether a local copy is current without
+// fetching the file's info: ContentHash is the SHA-256 over the file's block hashes in
+// order, and ETag is the value downloads of the file answer with.
+type ContentDigest struct {
+	ETag        string `json:"etag"`
+	ContentHash string `json:"content_hash"`
+	BlockCount  int    `json:"block_count"`
+}
+
+// ContentETag returns the ETag for a file whose ContentHash is contentHash.
+func ContentETag(contentHash string) string {
+	if len(contentHash) > 32 {
+		contentHash = contentHash[:32]
+	}
+	return `"` + contentHash + `"`
+}
+
+// FolderContents is everything shown when opening a folder, or one page of it.
+type FolderContents struct {
+	Folders    []*FolderEntry `json:"folders"`
+	Mounts     []*Mount       `json:"mounts,omitempty"` // read-only external storage, first page only
+	Files      []*FileEntry   `json:"files"`
+	NextCursor string         `json:"next_cursor,omitempty"` // set when more files follow
 }
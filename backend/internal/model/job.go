@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Job status values.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// JobStatuses lists every job status, in lifecycle order.
+var JobStatuses = []string{JobPending, JobRunning, JobCompleted, JobFailed}
+
+// Job tracks a long-running background operation started on behalf of a user.
+type Job struct {
+	ID         int64           `json:"id"`
+	UserID     int64           `json:"user_id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Progress   int             `json:"progress"` // 0-100
+	Payload    json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+	Result     json.RawMessage `json:"result,omitempty" swaggertype:"object"`
+	Error      *string         `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// JobFilter narrows a user's job listing. Zero values mean "any".
+type JobFilter struct {
+	Kind     string
+	Status   string
+	BeforeID int64 // only jobs with a smaller ID (keyset pagination)
+	Limit    int
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Mount kinds.
+const (
+	MountS3    = "s3"    // Location is a key prefix in the configured bucket
+	MountLocal = "local" // Location is a directory on the server
+)
+
+// Mount is external storage an admin surfaced read-only as a virtual folder in a
+// user's tree.
+type Mount struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	ParentID  *int64    `json:"parent_id"` // folder it appears in; nil = root level
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`               // MountS3 or MountLocal
+	Location  string    `json:"location,omitempty"` // only shown to admins
+	CreatedBy *int64    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MountEntry is a file or directory inside a mount. Path is relative to the mount root.
+type MountEntry struct {
+	Name       string     `json:"name"`
+	Path       string     `json:"path"`
+	Dir        bool       `json:"dir"`
+	Size       int64      `json:"size"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Notification kinds.
+const (
+	NotificationExpiryWarning = "expiry_warning" // data: ExpiryNotice; the item expires soon
+	NotificationExpired       = "expired"        // data: ExpiryNotice; the item was trashed/deleted
+	NotificationFileDrop      = "file_drop"      // data: FileDropNotice; a file arrived through a file-drop link
+	NotificationTakedown      = "takedown"       // data: TakedownNotice; an admin disabled a link or file after a report
+	NotificationShareUpload   = "share_upload"   // data: ShareUploadNotice; a file arrived through a folder share link
+)
+
+// NotificationKinds lists every notification kind, e.g. for validating muted kinds.
+var NotificationKinds = []string{NotificationExpiryWarning, NotificationExpired, NotificationFileDrop, NotificationTakedown, NotificationShareUpload}
+
+// Notification is an in-app message for a user. Data holds kind-specific fields.
+type Notification struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Kind      string          `json:"kind"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ExpiryNotice is the data of expiry notifications.
+type ExpiryNotice struct {
+	ItemType  string    `json:"item_type"` // "file" or "folder"
+	ItemID    int64     `json:"item_id"`
+	Name      string    `json:"name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FileDropNotice is the data of file-drop notifications.
+type FileDropNotice struct {
+	RequestID int64  `json:"request_id"`
+	Title     string `json:"title"`
+	FileID    int64  `json:"file_id"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+}
+
+// ShareUploadNotice is the data of share-upload notifications.
+type ShareUploadNotice struct {
+	LinkID       int64  `json:"link_id"`
+	FolderID     int64  `json:"folder_id"`
+	FileID       int64  `json:"file_id"`
+	Name         string `json:"name"`
+	Size         int64  `json:"size"`
+	UploaderName string `json:"uploader_name,omitempty"`
+}
+
+// TakedownNotice is the data of takedown notifications.
+type TakedownNotice struct {
+	Action   string `json:"action"` // AbuseActionLinkDisabled or AbuseActionFileDisabled
+	FileID   int64  `json:"file_id"`
+	FileName string `json:"file_name"`
+	Reason   string `json:"reason"`
+}
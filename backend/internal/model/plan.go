@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Plan is a set of limits admins assign to users. Nil limits are unlimited; Features
+// switches feature flags off (false) by name, and unlisted features stay on. A user
+// without a plan is held to the default plan, if one is marked.
+type Plan struct {
+	ID           int64           `json:"id"`
+	Name         string          `json:"name"`
+	Description  *string         `json:"description,omitempty"`
+	QuotaBytes   *int64          `json:"quota_bytes,omitempty"`          // total size of the user's files, trash included
+	MaxFileBytes *int64          `json:"max_file_bytes,omitempty"`       // largest single file
+	EgressBytes  *int64          `json:"monthly_egress_bytes,omitempty"` // bytes served per calendar month (soft)
+	Features     map[string]bool `json:"features"`
+	IsDefault    bool            `json:"is_default"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// FeatureEnabled reports whether the plan leaves feature on.
+func (p *Plan) FeatureEnabled(feature string) bool {
+	on, listed := p.Features[feature]
+	return !listed || on
+}
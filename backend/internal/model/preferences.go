@@ -0,0 +1,48 @@
+package model
+
+import "slices"
+
+// Sort orders accepted for DefaultSort, matching ?sort= of the folder contents listing.
+var PreferenceSorts = []string{"name", "natural", "created"}
+
+// UserPreferences are UI and behaviour settings stored server-side so they follow the
+// user across devices. Stored keys are laid over DefaultPreferences, so a preference
+// the user never set reads as its default.
+type UserPreferences struct {
+	DefaultSort string `json:"default_sort"`
+	// Language is the UI language; "" follows the browser.
+	Language string `json:"language"`
+	// DefaultShareExpiryHours pre-fills the expiry of new share links; 0 = server default.
+	DefaultShareExpiryHours int                     `json:"default_share_expiry_hours"`
+	Notifications           NotificationPreferences `json:"notifications"`
+}
+
+// NotificationPreferences are the channels a user opted into and the kinds they muted.
+// In-app notifications are always kept; these only control push and email.
+type NotificationPreferences struct {
+	Push       bool     `json:"push"`
+	Email      bool     `json:"email"`
+	MutedKinds []string `json:"muted_kinds"`
+}
+
+// DefaultPreferences returns the preferences of a user who has set none.
+func DefaultPreferences() *UserPreferences {
+	return &UserPreferences{
+		DefaultSort: "name",
+		Notifications: NotificationPreferences{
+			Push:       true,
+			Email:      true,
+			MutedKinds: []string{},
+		},
+	}
+}
+
+// WantsPush reports whether notifications of kind should be pushed to the user.
+func (p *NotificationPreferences) WantsPush(kind string) bool {
+	return p.Push && !slices.Contains(p.MutedKinds, kind)
+}
+
+// WantsEmail reports whether notifications of kind should be emailed to the user.
+func (p *NotificationPreferences) WantsEmail(kind string) bool {
+	return p.Email && !slices.Contains(p.MutedKinds, kind)
+}
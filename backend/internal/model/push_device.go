@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Push platforms a device can register for.
+const (
+	PushPlatformFCM     = "fcm"     // Firebase Cloud Messaging registration token (Android/iOS apps)
+	PushPlatformWebPush = "webpush" // browser Push API subscription
+)
+
+// PushDevice is a device that receives a user's notifications as push messages.
+// For Web Push, Token is the subscription endpoint URL and P256DH/Auth its keys.
+type PushDevice struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Platform   string     `json:"platform"`
+	Token      string     `json:"-"`
+	P256DH     *string    `json:"-"`
+	Auth       *string    `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// QuickAccessItem is a folder pinned to the user's quick-access list.
+type QuickAccessItem struct {
+	Position int       `json:"position"` // 0-based, ascending
+	PinnedAt time.Time `json:"pinned_at"`
+	Folder   *Folder   `json:"folder"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// RefreshToken is a refresh token issued to a bearer client. The token itself is only
+// known to the client; the table keys tokens by its hash. FamilyID links the tokens
+// one login's refreshes have rotated through.
+type RefreshToken struct {
+	ID        int64      `json:"id"`
+	FamilyID  int64      `json:"family_id"`
+	UserID    int64      `json:"user_id"`
+	IPAddress string     `json:"ip_address"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"` // traded for its successor
+	RevokedAt *time.Time `json:"revoked_at"`
+}
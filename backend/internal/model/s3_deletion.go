@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// S3Deletion is an S3 object queued for deletion by the background worker.
+type S3Deletion struct {
+	ID            int64      `json:"id"`
+	S3Key         string     `json:"s3_key"`
+	Attempts      int        `json:"attempts"`
+	LastError     *string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	DeadAt        *time.Time `json:"dead_at,omitempty"` // set once retries are exhausted
+	CreatedAt     time.Time  `json:"created_at"`
+}
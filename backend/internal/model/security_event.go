@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Security event types.
+const (
+	SecurityLoginFailed         = "login_failed"          // wrong email or password
+	SecurityLoginThrottled      = "login_throttled"       // login refused after too many failures
+	SecurityTokenInvalid        = "token_invalid"         // bad or expired JWT, unknown session cookie
+	SecurityPermissionDenied    = "permission_denied"     // authenticated user refused access
+	SecurityCSRFFailed          = "csrf_failed"           // cookie-authenticated request without a valid CSRF token
+	SecuritySharePasswordFailed = "share_password_failed" // wrong password for a protected share link
+	SecurityRefreshTokenReused  = "refresh_token_reused"  // a rotated refresh token came back; its login was revoked
+)
+
+// SecurityEventTypes lists every security event type, e.g. for validating filters.
+var SecurityEventTypes = []string{
+	SecurityLoginFailed, SecurityLoginThrottled, SecurityTokenInvalid,
+	SecurityPermissionDenied, SecurityCSRFFailed, SecuritySharePasswordFailed, SecurityRefreshTokenReused,
+}
+
+// SecurityEvent is an entry of the security log, kept apart from the application log
+// so admins can review it. UserID is the (claimed) user, when known.
+type SecurityEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	UserID    *int64          `json:"user_id"`
+	IPAddress string          `json:"ip_address"`
+	UserAgent string          `json:"user_agent"`
+	Method    string          `json:"method"`
+	Path      string          `json:"path"`
+	RequestID string          `json:"request_id"`
+	Details   json.RawMessage `json:"details" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SecurityEventFilter narrows a security log query. Zero fields don't filter.
+type SecurityEventFilter struct {
+	Type      string
+	UserID    int64
+	IPAddress string // an address, or a CIDR network matching every address in it
+	Since     time.Time
+	Until     time.Time
+	BeforeID  int64 // keyset pagination: only events older than this ID
+	Limit     int
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Session is a server-side browser session. The session ID itself is only known to
+// the client; the table keys sessions by its hash.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Email      string    `json:"-"` // owner's email, joined on lookup
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -4,10 +4,71 @@ import "time"
 
 // ShareLink represents a public share link for a file.
 type ShareLink struct {
-	ID        int64      `json:"id"`
-	FileID    int64      `json:"file_id"`
-	UserID    int64      `json:"user_id"`
-	Token     string     `json:"token"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID      int64  `json:"id"`
+	FileID  int64  `json:"file_id"`
+	UserID  int64  `json:"user_id"`
+	Token   string `json:"token"`
+	Enabled bool   `json:"enabled"` // false = temporarily suspended by owner
+	// PasswordHash is the bcrypt hash recipients must match; nil = no password.
+	PasswordHash *string    `json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// TakenDownAt is set when an admin disabled the link after an abuse report.
+	TakenDownAt *time.Time `json:"taken_down_at,omitempty"`
+	// OwnerDeactivated is set by token lookups when the owner's account is deactivated.
+	OwnerDeactivated bool `json:"-"`
+}
+
+// ShareLinkWithFile is a share link joined with the name of the file it targets.
+type ShareLinkWithFile struct {
+	ShareLink
+	FileName string `json:"file_name"`
+}
+
+// Folder share link views.
+const (
+	FolderViewList    = "list"    // every file in the folder
+	FolderViewGallery = "gallery" // images and videos only, with thumbnails
+)
+
+// FolderShareLink is a public link to the files directly inside a folder.
+type FolderShareLink struct {
+	ID           int64      `json:"id"`
+	FolderID     int64      `json:"folder_id"`
+	UserID       int64      `json:"user_id"`
+	Token        string     `json:"token"`
+	View         string     `json:"view"` // FolderViewList or FolderViewGallery
+	Enabled      bool       `json:"enabled"`
+	PasswordHash *string    `json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// AllowUploads lets link holders add files to the folder, each at most
+	// UploadMaxBytes and UploadMaxFiles in total (nil = only the server limits).
+	AllowUploads   bool   `json:"allow_uploads"`
+	UploadMaxBytes *int64 `json:"upload_max_bytes,omitempty"`
+	UploadMaxFiles *int   `json:"upload_max_files,omitempty"`
+	UploadCount    int    `json:"upload_count"`
+	// OwnerDeactivated is set by token lookups when the owner's account is deactivated.
+	OwnerDeactivated bool `json:"-"`
+}
+
+// FolderShareUpload records a file received through a folder share link. LinkID and
+// FileID are nil once the link or file has been deleted.
+type FolderShareUpload struct {
+	ID           int64     `json:"id"`
+	LinkID       *int64    `json:"link_id,omitempty"`
+	FolderID     int64     `json:"folder_id"`
+	FileID       *int64    `json:"file_id,omitempty"`
+	UploaderName *string   `json:"uploader_name,omitempty"`
+	FileName     string    `json:"file_name"`
+	Size         int64     `json:"size"`
+	ClientIP     *string   `json:"client_ip,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SharedFile is a file listed through a folder share link. In gallery view PosterID
+// is the image shown for a video: one with the same base name in the same folder.
+type SharedFile struct {
+	*File
+	PosterID *int64
 }
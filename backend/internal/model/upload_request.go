@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// UploadRequest is a file-drop link: anyone with the token may upload files into
+// FolderID, within the accepted types and size limit.
+type UploadRequest struct {
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"user_id"`
+	FolderID      int64      `json:"folder_id"`
+	Token         string     `json:"token"`
+	Title         string     `json:"title"`
+	Instructions  string     `json:"instructions"`
+	AcceptedTypes []string   `json:"accepted_types"` // extensions and/or MIME types; empty = any
+	MaxSizeBytes  *int64     `json:"max_size_bytes"` // per file; nil = no limit
+	ExpiresAt     *time.Time `json:"expires_at"`
+	Enabled       bool       `json:"enabled"`
+	UploadCount   int64      `json:"upload_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	// OwnerDeactivated is set by token lookups when the owner's account is deactivated.
+	OwnerDeactivated bool `json:"-"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Upload rule match types.
+const (
+	MatchExtension = "extension" // pattern: comma-separated extensions, e.g. "jpg,jpeg,heic"
+	MatchMime      = "mime"      // pattern: comma-separated MIME types, "image/*" wildcards allowed
+	MatchName      = "name"      // pattern: shell glob on the file name, e.g. "invoice-*.pdf"
+)
+
+// UploadRule files matching root uploads into TargetFolderID automatically.
+type UploadRule struct {
+	ID             int64     `json:"id"`
+	UserID         int64     `json:"user_id"`
+	Name           string    `json:"name"`
+	MatchType      string    `json:"match_type"`
+	Pattern        string    `json:"pattern"`
+	TargetFolderID int64     `json:"target_folder_id"`
+	Position       int       `json:"position"` // lower runs first
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+// UploadSession is a resumable upload in progress. The file is sent in fixed-size
+// chunks, in any order and over any number of requests; chunk i covers bytes
+// [i*ChunkSize, (i+1)*ChunkSize) and only the last one may be shorter. Each chunk is
+// stored as a block; with content-defined chunking the file's content is split again
+// when the session is finalized.
+type UploadSession struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	FolderID  *int64    `json:"folder_id"` // nil = root, or the upload rules pick a folder
+	FileName  string    `json:"file_name"`
+	MimeType  string    `json:"mime_type"`
+	TotalSize int64     `json:"total_size"`
+	ChunkSize int       `json:"chunk_size"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Dedup totals over the chunks received so far, or over the file's blocks once the
+	// content was split again.
+	BlocksNew     int   `json:"blocks_new"`           // blocks stored as new; the rest were deduplicated
+	UploadedBytes int64 `json:"bytes_uploaded_to_s3"` // bytes written to S3 for the session
+	BlockCount    *int  `json:"-"`                    // blocks of the file when split again; nil = one per chunk
+
+	// Set once the session is finalized; a retried finalize replays this result.
+	FinalizedAt *time.Time `json:"finalized_at,omitempty"`
+	FileID      *int64     `json:"file_id,omitempty"` // nil after finalize if the file was since purged
+	RuleID      *int64     `json:"-"`                 // upload rule that chose the folder
+}
+
+// ChunkCount returns how many chunks make up the file.
+func (s *UploadSession) ChunkCount() int {
+	return int((s.TotalSize + int64(s.ChunkSize) - 1) / int64(s.ChunkSize))
+}
+
+// FileBlocks returns how many blocks the session's file is made of.
+func (s *UploadSession) FileBlocks() int {
+	if s.BlockCount != nil {
+		return *s.BlockCount
+	}
+	return s.ChunkCount()
+}
+
+// BlocksDeduped returns how many of the file's blocks reused an existing block.
+// It is only final once every chunk has been received.
+func (s *UploadSession) BlocksDeduped() int {
+	return s.FileBlocks() - s.BlocksNew
+}
+
+// ChunkOffset returns the byte offset at which chunk index starts.
+func (s *UploadSession) ChunkOffset(index int) int64 {
+	return int64(index) * int64(s.ChunkSize)
+}
+
+// ChunkLength returns the exact size of chunk index.
+func (s *UploadSession) ChunkLength(index int) int64 {
+	return min(int64(s.ChunkSize), s.TotalSize-s.ChunkOffset(index))
+}
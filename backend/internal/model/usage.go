@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// UsageSnapshot is a user's storage usage on one day. FolderID is nil for the user's
+// total and otherwise names a top-level folder whose subtree the row covers.
+type UsageSnapshot struct {
+	UserID     int64     `json:"user_id"`
+	FolderID   *int64    `json:"folder_id,omitempty"`
+	FolderName *string   `json:"folder_name,omitempty"`
+	Day        time.Time `json:"day"`
+	Bytes      int64     `json:"bytes"`
+	FileCount  int64     `json:"file_count"`
+}
+
+// EgressUsage is what a user has been served this month (their own downloads and
+// traffic through their share links) against their plan's monthly limit.
+type EgressUsage struct {
+	UsedBytes  int64  `json:"used_bytes"`
+	LimitBytes *int64 `json:"limit_bytes,omitempty"` // nil = unlimited
+	OverLimit  bool   `json:"over_limit"`
+	// OverLimitMode is what happens over the limit: track, throttle or block.
+	OverLimitMode string `json:"over_limit_mode"`
+}
@@ -6,6 +6,53 @@ type User struct {
 	ID        int64     `json:"id"`
 	Email     string    `json:"email"`
 	Password  string    `json:"-"` // bcrypt hash, never expose
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	TOSVersion    *string    `json:"tos_version"` // latest terms of service version accepted; nil = none
+	TOSAcceptedAt *time.Time `json:"tos_accepted_at"`
+
+	// DeactivatedAt is set while an admin has deactivated the account: it cannot sign
+	// in or use the API and its links are disabled, but its data is kept.
+	DeactivatedAt *time.Time `json:"deactivated_at"`
+	DeactivatedBy *int64     `json:"deactivated_by"`
+
+	// UsedBytes is the total size of the user's files, trashed ones included.
+	UsedBytes int64 `json:"used_bytes"`
+
+	// PlanID is the plan an admin assigned; nil = the default plan, if any.
+	PlanID *int64 `json:"plan_id"`
+
+	// QuotaBytes is a storage quota an admin set for this user, overriding their
+	// plan's; nil = the plan's quota applies.
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
+
+// UsageDrift is a user whose stored used_bytes disagrees with their files.
+type UsageDrift struct {
+	UserID      int64 `json:"user_id"`
+	UsedBytes   int64 `json:"used_bytes"`   // stored value
+	ActualBytes int64 `json:"actual_bytes"` // summed from the files table
+}
+
+// User roles. Admins can run maintenance endpoints under /admin.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// UserExport is one row of the admin user export: the account, its plan and what it uses.
+type UserExport struct {
+	ID            int64      `json:"id"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	PlanID        *int64     `json:"plan_id"`
+	PlanName      *string    `json:"plan_name"`   // assigned plan, else the default plan; nil = none
+	QuotaBytes    *int64     `json:"quota_bytes"` // the user's own, else the plan's; nil = unlimited
+	UsedBytes     int64      `json:"used_bytes"`
+	EgressBytes   int64      `json:"egress_bytes"`   // served this calendar month (UTC)
+	InvitePending bool       `json:"invite_pending"` // provisioned by import and no password set yet
+	CreatedAt     time.Time  `json:"created_at"`
+	DeactivatedAt *time.Time `json:"deactivated_at"`
 }
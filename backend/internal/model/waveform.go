@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Waveform status values.
+const (
+	WaveformPending = "pending"
+	WaveformRunning = "running"
+	WaveformReady   = "ready"
+	WaveformFailed  = "failed"
+)
+
+// Waveform is the analysis of an audio file: how long it plays, its bitrate, and an
+// outline of its loudness over time for drawing a seekable waveform. The measurements
+// are set once Status is WaveformReady.
+type Waveform struct {
+	FileID      int64     `json:"file_id"`
+	Status      string    `json:"status"`
+	ContentETag *string   `json:"-"` // content version analysed
+	DurationMs  *int64    `json:"duration_ms,omitempty"`
+	Bitrate     *int64    `json:"bitrate,omitempty"` // bits per second, averaged over the file
+	SampleRate  *int      `json:"sample_rate,omitempty"`
+	Channels    *int      `json:"channels,omitempty"`
+	Codec       *string   `json:"codec,omitempty"`
+	Peaks       []float64 `json:"peaks,omitempty"` // loudest sample of each equal slice of time, 0-1
+	Error       *string   `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,266 @@
+// Package mount reads external storage that admins surface read-only in a user's
+// tree: a key prefix in the configured S3 bucket or a directory on the server. Its
+// content is listed and streamed as is, never split into blocks or deduplicated.
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/storage"
+)
+
+var (
+	// ErrNotFound means the path does not exist inside the mount (or leaves it).
+	ErrNotFound = errors.New("mount entry not found")
+	// ErrIsDir means a directory was opened as a file.
+	ErrIsDir = errors.New("mount entry is a directory")
+	// ErrInvalidLocation means a mount location was rejected when it was created.
+	ErrInvalidLocation = errors.New("invalid mount location")
+)
+
+// maxListEntries bounds one directory listing.
+const maxListEntries = 5000
+
+// Reader lists and opens the content of mounts.
+type Reader struct {
+	s3         *storage.S3Client
+	localRoots []string // directories local mounts must lie within; none = local mounts disabled
+}
+
+// NewReader returns a Reader. localRoots are the server directories admins may mount
+// (or mount subdirectories of); each must be absolute.
+func NewReader(s3 *storage.S3Client, localRoots []string) (*Reader, error) {
+	r := &Reader{s3: s3}
+	for _, root := range localRoots {
+		if !filepath.IsAbs(root) {
+			return nil, fmt.Errorf("mount root %q is not an absolute path", root)
+		}
+		r.localRoots = append(r.localRoots, filepath.Clean(root))
+	}
+	return r, nil
+}
+
+// NormalizeLocation validates a new mount's location and returns it in stored form:
+// S3 prefixes end in "/" (so they cannot reach block objects at the bucket root), and
+// local directories must exist under one of the configured roots once symlinks are
+// resolved; they are stored resolved.
+func (r *Reader) NormalizeLocation(kind, location string) (string, error) {
+	switch kind {
+	case model.MountS3:
+		prefix := strings.Trim(path.Clean("/"+location), "/")
+		if prefix == "" {
+			return "", fmt.Errorf("%w: an S3 mount needs a non-empty prefix", ErrInvalidLocation)
+		}
+		return prefix + "/", nil
+	case model.MountLocal:
+		if len(r.localRoots) == 0 {
+			return "", fmt.Errorf("%w: local mounts are disabled (MOUNT_LOCAL_ROOTS is empty)", ErrInvalidLocation)
+		}
+		if !filepath.IsAbs(location) {
+			return "", fmt.Errorf("%w: a local mount needs an absolute path", ErrInvalidLocation)
+		}
+		dir, err := filepath.EvalSymlinks(location)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s is not a readable directory", ErrInvalidLocation, filepath.Clean(location))
+		}
+		if !r.underRoot(dir) {
+			return "", fmt.Errorf("%w: %s is outside MOUNT_LOCAL_ROOTS", ErrInvalidLocation, dir)
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("%w: %s is not a readable directory", ErrInvalidLocation, dir)
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("%w: kind must be %q or %q", ErrInvalidLocation, model.MountS3, model.MountLocal)
+}
+
+// List returns the entries of the directory at rel inside m, directories first.
+func (r *Reader) List(ctx context.Context, m *model.Mount, rel string) ([]model.MountEntry, error) {
+	rel = cleanRel(rel)
+	var entries []model.MountEntry
+
+	switch m.Kind {
+	case model.MountS3:
+		prefix := m.Location
+		if rel != "" {
+			prefix += rel + "/"
+		}
+		objects, dirs, err := r.s3.ListPrefix(ctx, prefix, maxListEntries)
+		if err != nil {
+			return nil, fmt.Errorf("mount.List: %w", err)
+		}
+		if len(objects) == 0 && len(dirs) == 0 && rel != "" {
+			return nil, ErrNotFound
+		}
+		for _, d := range dirs {
+			name := path.Base(strings.TrimSuffix(d, "/"))
+			entries = append(entries, model.MountEntry{Name: name, Path: path.Join(rel, name), Dir: true})
+		}
+		for _, o := range objects {
+			name := strings.TrimPrefix(o.Key, prefix)
+			if name == "" { // the "directory marker" some tools create
+				continue
+			}
+			modified := o.LastModified
+			entries = append(entries, model.MountEntry{Name: name, Path: path.Join(rel, name), Size: o.Size, ModifiedAt: &modified})
+		}
+
+	case model.MountLocal:
+		dir, err := r.localPath(m, rel)
+		if err != nil {
+			return nil, err
+		}
+		des, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, ErrNotFound
+			}
+			return nil, fmt.Errorf("mount.List: %w", err)
+		}
+		for _, de := range des {
+			if len(entries) >= maxListEntries {
+				break
+			}
+			info, err := de.Info()
+			if err != nil || !(info.IsDir() || info.Mode().IsRegular()) {
+				continue // vanished, or a socket, device, ...
+			}
+			e := model.MountEntry{Name: de.Name(), Path: path.Join(rel, de.Name()), Dir: info.IsDir()}
+			if !e.Dir {
+				modified := info.ModTime()
+				e.Size, e.ModifiedAt = info.Size(), &modified
+			}
+			entries = append(entries, e)
+		}
+
+	default:
+		return nil, fmt.Errorf("mount.List: unknown kind %q", m.Kind)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Dir != entries[j].Dir {
+			return entries[i].Dir
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+	return entries, nil
+}
+
+// Stat describes the file at rel inside m.
+func (r *Reader) Stat(ctx context.Context, m *model.Mount, rel string) (*model.MountEntry, error) {
+	rel = cleanRel(rel)
+	if rel == "" {
+		return nil, ErrIsDir
+	}
+
+	switch m.Kind {
+	case model.MountS3:
+		info, ok := r.s3.StatObject(ctx, m.Location+rel)
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return &model.MountEntry{Name: path.Base(rel), Path: rel, Size: info.Size, ModifiedAt: &info.LastModified}, nil
+
+	case model.MountLocal:
+		p, err := r.localPath(m, rel)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		if info.IsDir() {
+			return nil, ErrIsDir
+		}
+		modified := info.ModTime()
+		return &model.MountEntry{Name: path.Base(rel), Path: rel, Size: info.Size(), ModifiedAt: &modified}, nil
+	}
+	return nil, fmt.Errorf("mount.Stat: unknown kind %q", m.Kind)
+}
+
+// Open returns length bytes of the file at rel inside m, starting at offset start.
+// Caller is responsible for closing the returned body.
+func (r *Reader) Open(ctx context.Context, m *model.Mount, rel string, start, length int64) (io.ReadCloser, error) {
+	rel = cleanRel(rel)
+
+	switch m.Kind {
+	case model.MountS3:
+		if start == 0 {
+			body, err := r.s3.GetObject(ctx, m.Location+rel)
+			if err != nil {
+				return nil, err
+			}
+			return limitedBody{Reader: io.LimitReader(body, length), Closer: body}, nil
+		}
+		return r.s3.GetObjectRange(ctx, m.Location+rel, start, start+length-1)
+
+	case model.MountLocal:
+		p, err := r.localPath(m, rel)
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("mount.Open: %w", err)
+		}
+		return limitedBody{Reader: io.LimitReader(f, length), Closer: f}, nil
+	}
+	return nil, fmt.Errorf("mount.Open: unknown kind %q", m.Kind)
+}
+
+// localPath resolves rel inside a local mount, following symlinks, and refuses
+// anything that ends up outside the mount directory, or a mount directory that has
+// since come to resolve outside MOUNT_LOCAL_ROOTS.
+func (r *Reader) localPath(m *model.Mount, rel string) (string, error) {
+	root, err := filepath.EvalSymlinks(m.Location)
+	if err != nil || !r.underRoot(root) {
+		return "", ErrNotFound
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(m.Location, filepath.FromSlash(rel)))
+	if err != nil || !within(root, p) {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+// underRoot reports whether dir, with symlinks resolved, lies within one of the
+// configured roots, with theirs resolved too. A root that cannot be resolved admits
+// nothing.
+func (r *Reader) underRoot(dir string) bool {
+	for _, root := range r.localRoots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil && within(resolved, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanRel turns a client-supplied path into a slash-separated path relative to the
+// mount root that cannot climb above it ("" is the root itself).
+func cleanRel(rel string) string {
+	return strings.TrimPrefix(path.Clean("/"+rel), "/")
+}
+
+// within reports whether p is dir or lies beneath it.
+func within(dir, p string) bool {
+	return p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
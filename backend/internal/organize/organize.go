@@ -0,0 +1,143 @@
+// Package organize evaluates upload rules, which decide the folder a newly uploaded
+// file is filed into, and the accepted-type lists of file-drop requests.
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// maxPatternLen bounds rule patterns; they are matched on every root upload.
+const maxPatternLen = 256
+
+// Validate reports whether pattern is usable for matchType.
+func Validate(matchType, pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return errors.New("pattern is required")
+	}
+	if len(pattern) > maxPatternLen {
+		return fmt.Errorf("pattern must be at most %d bytes", maxPatternLen)
+	}
+	switch matchType {
+	case model.MatchExtension:
+		if len(splitList(pattern)) == 0 {
+			return errors.New("pattern must list at least one extension")
+		}
+		return nil
+	case model.MatchMime:
+		for _, p := range splitList(pattern) {
+			if !strings.Contains(p, "/") {
+				return fmt.Errorf("%q is not a MIME type", p)
+			}
+		}
+		return nil
+	case model.MatchName:
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("invalid name pattern: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("match_type must be %s, %s or %s", model.MatchExtension, model.MatchMime, model.MatchName)
+	}
+}
+
+// Match returns the first enabled rule, in the given order, that matches the file.
+func Match(rules []*model.UploadRule, fileName, mimeType string) *model.UploadRule {
+	for _, rule := range rules {
+		if rule.Enabled && matches(rule, fileName, mimeType) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func matches(rule *model.UploadRule, fileName, mimeType string) bool {
+	switch rule.MatchType {
+	case model.MatchExtension:
+		return matchExtension(splitList(rule.Pattern), fileName)
+	case model.MatchMime:
+		return matchMime(splitList(rule.Pattern), mimeType)
+	case model.MatchName:
+		ok, _ := path.Match(strings.ToLower(rule.Pattern), strings.ToLower(fileName))
+		return ok
+	}
+	return false
+}
+
+// ValidateAccepted checks a list of accepted types: extensions ("pdf", ".pdf") and
+// MIME types ("application/pdf", "image/*") may be mixed.
+func ValidateAccepted(accepted []string) error {
+	for _, a := range accepted {
+		a = strings.TrimSpace(a)
+		if a == "" || len(a) > maxPatternLen || strings.Contains(a, ",") {
+			return fmt.Errorf("%q is not a valid file type", a)
+		}
+		if i := strings.IndexByte(a, '/'); i >= 0 && (i == 0 || i == len(a)-1) {
+			return fmt.Errorf("%q is not a MIME type", a)
+		}
+	}
+	return nil
+}
+
+// Accepts reports whether a file matches any entry of an accepted-types list (see
+// ValidateAccepted). An empty list accepts everything.
+func Accepts(accepted []string, fileName, mimeType string) bool {
+	if len(accepted) == 0 {
+		return true
+	}
+	var exts, mimes []string
+	for _, a := range accepted {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if strings.Contains(a, "/") {
+			mimes = append(mimes, a)
+		} else {
+			exts = append(exts, a)
+		}
+	}
+	return matchExtension(exts, fileName) || matchMime(mimes, mimeType)
+}
+
+// matchExtension reports whether fileName has one of exts (lower-case, dot optional).
+func matchExtension(exts []string, fileName string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if ext == "" {
+		return false
+	}
+	for _, p := range exts {
+		if strings.TrimPrefix(p, ".") == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMime reports whether mimeType (parameters ignored) is one of mimes, where
+// "type/*" matches a whole top-level type.
+func matchMime(mimes []string, mimeType string) bool {
+	mimeType = strings.ToLower(mimeType)
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+	for _, p := range mimes {
+		if p == mimeType || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated pattern into lower-cased, trimmed entries.
+func splitList(pattern string) []string {
+	var out []string
+	for _, p := range strings.Split(pattern, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+// Package pdfrender renders single PDF pages to PNG by running poppler's pdftoppm,
+// and keeps recently rendered pages in memory.
+package pdfrender
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	renders     = expvar.NewInt("pdf_page_renders")
+	renderFails = expvar.NewInt("pdf_page_render_failures")
+	cacheHits   = expvar.NewInt("pdf_page_cache_hits")
+)
+
+// renderTimeout bounds one pdftoppm run, so a hostile PDF cannot hold a slot forever.
+const renderTimeout = 30 * time.Second
+
+var (
+	// ErrPageNotFound is returned for a page number past the end of the document.
+	ErrPageNotFound = errors.New("pdfrender: no such page")
+	// ErrBusy is returned when every render slot stays taken until the context ends.
+	ErrBusy = errors.New("pdfrender: all render slots busy")
+	// ErrTooLarge is returned when a document is larger than the renderer accepts.
+	ErrTooLarge = errors.New("pdfrender: document too large")
+)
+
+// Renderer runs pdftoppm with at most a fixed number of renders at once.
+type Renderer struct {
+	bin      string
+	slots    chan struct{}
+	maxBytes int64
+	cache    *pageCache
+}
+
+// New returns a Renderer running the pdftoppm binary bin (a name looked up in PATH,
+// or a path) up to concurrency renders at once on documents of up to maxBytes,
+// caching up to cacheBytes of PNGs. It returns an error if bin cannot be found.
+func New(bin string, concurrency int, maxBytes, cacheBytes int64) (*Renderer, error) {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return nil, fmt.Errorf("pdfrender.New: %w", err)
+	}
+	return &Renderer{
+		bin:      path,
+		slots:    make(chan struct{}, max(concurrency, 1)),
+		maxBytes: maxBytes,
+		cache:    newPageCache(cacheBytes),
+	}, nil
+}
+
+// Cached returns the page stored under key by an earlier Render, if it is still cached.
+func (r *Renderer) Cached(key string) ([]byte, bool) {
+	png, ok := r.cache.get(key)
+	if ok {
+		cacheHits.Add(1)
+	}
+	return png, ok
+}
+
+// Render returns page (1-based) of the PDF written by src as a PNG at dpi dots per
+// inch and caches it under key, which should identify the document version, page and
+// dpi. src is only called once a render slot is taken, so requests waiting for one
+// hold no copy of the document; a document over the size limit fails with ErrTooLarge.
+func (r *Renderer) Render(ctx context.Context, key string, src func(io.Writer) error, page, dpi int) ([]byte, error) {
+	select {
+	case r.slots <- struct{}{}:
+		defer func() { <-r.slots }()
+	case <-ctx.Done():
+		return nil, ErrBusy
+	}
+
+	png, err := r.run(ctx, src, page, dpi)
+	if err != nil {
+		if !errors.Is(err, ErrPageNotFound) && !errors.Is(err, ErrTooLarge) {
+			renderFails.Add(1)
+		}
+		return nil, err
+	}
+	renders.Add(1)
+	r.cache.put(key, png)
+	return png, nil
+}
+
+func (r *Renderer) run(ctx context.Context, src func(io.Writer) error, page, dpi int) ([]byte, error) {
+	// pdftoppm seeks around the document, so it reads from a file rather than stdin.
+	tmp, err := os.CreateTemp("", "pdfrender-*.pdf")
+	if err != nil {
+		return nil, fmt.Errorf("pdfrender.Render: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	err = src(&cappedWriter{w: tmp, n: r.maxBytes})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pdfrender.Render: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, renderTimeout)
+	defer cancel()
+	n := strconv.Itoa(page)
+	// Without an output root, -singlefile writes the one page to stdout.
+	cmd := exec.CommandContext(ctx, r.bin, "-png", "-singlefile", "-r", strconv.Itoa(dpi), "-f", n, "-l", n, tmp.Name())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "Wrong page range") {
+			return nil, ErrPageNotFound
+		}
+		return nil, fmt.Errorf("pdfrender.Render: %w: %s", err, msg)
+	}
+	if stdout.Len() == 0 {
+		// Older pdftoppm versions exit cleanly and print nothing for a missing page.
+		return nil, ErrPageNotFound
+	}
+	return stdout.Bytes(), nil
+}
+
+// cappedWriter passes writes on until n bytes have been written, then fails with
+// ErrTooLarge.
+type cappedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *cappedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > c.n {
+		return 0, ErrTooLarge
+	}
+	c.n -= int64(len(p))
+	return c.w.Write(p)
+}
+
+// pageCache is a least-recently-used cache of rendered pages, bounded in bytes.
+type pageCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	lru     *list.List // of *pageEntry, most recently used first
+	entries map[string]*list.Element
+	size    int64
+}
+
+type pageEntry struct {
+	key string
+	png []byte
+}
+
+func newPageCache(maxBytes int64) *pageCache {
+	return &pageCache{maxBytes: maxBytes, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *pageCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*pageEntry).png, true
+}
+
+func (c *pageCache) put(key string, png []byte) {
+	size := int64(len(png))
+	if size > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&pageEntry{key: key, png: png})
+	c.size += size
+	for c.size > c.maxBytes {
+		el := c.lru.Back()
+		e := el.Value.(*pageEntry)
+		c.lru.Remove(el)
+		delete(c.entries, e.key)
+		c.size -= int64(len(e.png))
+	}
+}
@@ -0,0 +1,232 @@
+// Package proxy makes the server aware of reverse proxies (nginx, Traefik, ...)
+// placed in front of it. X-Forwarded-* and Forwarded headers are only honoured when
+// the request arrives from a configured trusted proxy; otherwise anyone could spoof
+// their client IP or the scheme and host used to build absolute URLs.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// DefaultIPv6Prefix is the prefix length ClientKey groups IPv6 clients by when the
+// Resolver middleware did not run: a /64 is what one subscriber usually gets.
+const DefaultIPv6Prefix = 64
+
+// forwarded holds what the trusted proxy told us about the original request, and
+// how IPv6 clients are grouped.
+type forwarded struct {
+	scheme     string
+	ipv6Prefix int
+}
+
+// Resolver rewrites requests from trusted proxies to reflect the original client.
+type Resolver struct {
+	trusted    []*net.IPNet
+	ipv6Prefix int
+}
+
+// NewResolver parses a comma-separated list of IPs and CIDRs (e.g. "10.0.0.0/8, 127.0.0.1").
+// An empty list trusts no one. ipv6Prefix is the prefix length ClientKey groups IPv6
+// clients by, since one host can rotate through the addresses of its whole subnet.
+func NewResolver(trustedProxies string, ipv6Prefix int) (*Resolver, error) {
+	if ipv6Prefix < 16 || ipv6Prefix > 128 {
+		return nil, fmt.Errorf("proxy.NewResolver: IPv6 prefix length must be 16-128, got %d", ipv6Prefix)
+	}
+	res := &Resolver{ipv6Prefix: ipv6Prefix}
+	for _, entry := range strings.Split(trustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("proxy.NewResolver: invalid trusted proxy %q: %w", entry, err)
+		}
+		res.trusted = append(res.trusted, ipNet)
+	}
+	return res, nil
+}
+
+func (p *Resolver) isTrusted(ip net.IP) bool {
+	for _, n := range p.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware replaces r.RemoteAddr with the real client IP and r.Host with the
+// forwarded host, and records the forwarded scheme, when the direct peer is trusted.
+// X-Forwarded-* headers win over the standard Forwarded header (RFC 7239) when a
+// proxy sends both. Host and scheme come from the rightmost entry, the one the peer
+// added; entries left of it may be client-supplied. It must run before anything that
+// logs or acts on the client address.
+func (p *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fwd := forwarded{ipv6Prefix: p.ipv6Prefix}
+		peer := net.ParseIP(hostOnly(r.RemoteAddr))
+		if peer != nil && p.isTrusted(peer) {
+			elems := parseForwarded(r.Header.Values("Forwarded"))
+
+			hops := splitHops(r.Header.Values("X-Forwarded-For"))
+			if len(hops) == 0 {
+				for _, e := range elems {
+					hops = append(hops, e["for"])
+				}
+			}
+			if client := p.clientFromHops(hops); client != "" {
+				r.RemoteAddr = client
+			}
+
+			host := lastValue(r.Header.Values("X-Forwarded-Host"))
+			proto := lastValue(r.Header.Values("X-Forwarded-Proto"))
+			if len(elems) > 0 {
+				nearest := elems[len(elems)-1]
+				if host == "" {
+					host = nearest["host"]
+				}
+				if proto == "" {
+					proto = nearest["proto"]
+				}
+			}
+			if host != "" {
+				r.Host = host
+			}
+			if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+				fwd.scheme = proto
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, fwd)))
+	})
+}
+
+// splitHops flattens X-Forwarded-For headers, possibly repeated, into one list.
+func splitHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseForwarded splits Forwarded headers into their elements, one per hop, each a
+// map of lower-cased parameter names to unquoted values. An IPv6 "for" keeps its
+// brackets and port, e.g. "[2001:db8::1]:4711"; hostOnly strips them.
+func parseForwarded(values []string) []map[string]string {
+	var elems []map[string]string
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			params := make(map[string]string)
+			for _, pair := range strings.Split(elem, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				params[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+			elems = append(elems, params)
+		}
+	}
+	return elems
+}
+
+// clientFromHops walks the forwarded-for hops from the right, skipping our own
+// proxies; the first untrusted hop is the client. Entries left of it are
+// client-supplied and cannot be believed.
+func (p *Resolver) clientFromHops(hops []string) string {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hostOnly(hops[i]))
+		if ip == nil {
+			return ""
+		}
+		if !p.isTrusted(ip) || i == 0 {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// ClientIP returns the client's IP address without port.
+// Behind a trusted proxy this is the forwarded address.
+func ClientIP(r *http.Request) string {
+	return hostOnly(r.RemoteAddr)
+}
+
+// ClientKey returns what per-client limits and counters should key r on: the client
+// IP for IPv4 (IPv4-mapped IPv6 included) and its network for IPv6, e.g.
+// "2001:db8:1:2::/64", so a host cannot dodge a limit by hopping between the
+// addresses of its subnet. Behind a trusted proxy it is based on the forwarded address.
+func ClientKey(r *http.Request) string {
+	prefix := DefaultIPv6Prefix
+	if fwd, ok := r.Context().Value(contextKey{}).(forwarded); ok && fwd.ipv6Prefix > 0 {
+		prefix = fwd.ipv6Prefix
+	}
+	return Key(ClientIP(r), prefix)
+}
+
+// Key normalises ip into a client key, grouping IPv6 addresses by their first
+// ipv6Prefix bits. Anything that is not an IP address is returned as is.
+func Key(ip string, ipv6Prefix int) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.String()
+	}
+	network := &net.IPNet{IP: parsed.Mask(net.CIDRMask(ipv6Prefix, 128)), Mask: net.CIDRMask(ipv6Prefix, 128)}
+	return network.String()
+}
+
+// Scheme returns "https" or "http" as seen by the client.
+func Scheme(r *http.Request) string {
+	if fwd, ok := r.Context().Value(contextKey{}).(forwarded); ok && fwd.scheme != "" {
+		return fwd.scheme
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// BaseURL returns the origin (scheme://host) the client used to reach the server.
+func BaseURL(r *http.Request) string {
+	return Scheme(r) + "://" + r.Host
+}
+
+// hostOnly strips an optional port from addr (IPv6 brackets included).
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
+
+// lastValue returns the last entry of a comma-separated header, possibly repeated,
+// that chained proxies append to: the entry of the nearest one.
+func lastValue(values []string) string {
+	entries := splitHops(values)
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[len(entries)-1]
+}
@@ -0,0 +1,75 @@
+package push
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// MessageFor describes a notification as a push message. It returns false for kinds
+// that are not pushed.
+func MessageFor(n *model.Notification) (Message, bool) {
+	msg := Message{Data: map[string]string{
+		"notification_id": strconv.FormatInt(n.ID, 10),
+		"kind":            n.Kind,
+	}}
+
+	switch n.Kind {
+	case model.NotificationExpiryWarning, model.NotificationExpired:
+		var d model.ExpiryNotice
+		if json.Unmarshal(n.Data, &d) != nil {
+			return Message{}, false
+		}
+		msg.Data["item_type"] = d.ItemType
+		msg.Data["item_id"] = strconv.FormatInt(d.ItemID, 10)
+		if n.Kind == model.NotificationExpired {
+			msg.Title = "Item expired"
+			msg.Body = fmt.Sprintf("%s has expired", d.Name)
+		} else {
+			msg.Title = "Expiring soon"
+			msg.Body = fmt.Sprintf("%s expires on %s", d.Name, d.ExpiresAt.UTC().Format("2 Jan 2006 15:04 MST"))
+		}
+
+	case model.NotificationFileDrop:
+		var d model.FileDropNotice
+		if json.Unmarshal(n.Data, &d) != nil {
+			return Message{}, false
+		}
+		msg.Title = "New file received"
+		msg.Body = fmt.Sprintf("%s was uploaded to %s", d.Name, d.Title)
+		msg.Data["file_id"] = strconv.FormatInt(d.FileID, 10)
+
+	case model.NotificationShareUpload:
+		var d model.ShareUploadNotice
+		if json.Unmarshal(n.Data, &d) != nil {
+			return Message{}, false
+		}
+		msg.Title = "New file in shared folder"
+		if d.UploaderName != "" {
+			msg.Body = fmt.Sprintf("%s uploaded %s", d.UploaderName, d.Name)
+		} else {
+			msg.Body = fmt.Sprintf("%s was uploaded through a share link", d.Name)
+		}
+		msg.Data["file_id"] = strconv.FormatInt(d.FileID, 10)
+		msg.Data["folder_id"] = strconv.FormatInt(d.FolderID, 10)
+
+	case model.NotificationTakedown:
+		var d model.TakedownNotice
+		if json.Unmarshal(n.Data, &d) != nil {
+			return Message{}, false
+		}
+		msg.Title = "Content disabled"
+		if d.Action == model.AbuseActionLinkDisabled {
+			msg.Body = fmt.Sprintf("A share link to %s was disabled after an abuse report", d.FileName)
+		} else {
+			msg.Body = fmt.Sprintf("%s was disabled after an abuse report", d.FileName)
+		}
+		msg.Data["file_id"] = strconv.FormatInt(d.FileID, 10)
+
+	default:
+		return Message{}, false
+	}
+	return msg, true
+}
@@ -0,0 +1,115 @@
+// Package push delivers notifications to users' devices through Firebase Cloud
+// Messaging (mobile apps) and the Web Push protocol (browsers).
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// ErrGone is returned by Send when the push service reports that the device is no
+// longer registered; the device should be forgotten.
+var ErrGone = errors.New("push device is no longer registered")
+
+// Message is what a device displays. Data travels with it for the app to act on.
+type Message struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Config configures the push services. A service without credentials is disabled.
+type Config struct {
+	// VAPIDPrivateKey is the base64url-encoded P-256 private key that signs Web Push
+	// requests; the public key handed to browsers is derived from it. VAPIDSubject is
+	// a mailto: or https: contact for the push services.
+	VAPIDPrivateKey string
+	VAPIDSubject    string
+
+	// FCMCredentialsFile is the path to a Firebase service account JSON key.
+	FCMCredentialsFile string
+}
+
+// Pusher sends messages to devices of any enabled platform.
+type Pusher struct {
+	webPush *webPushSender
+	fcm     *fcmSender
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// New creates a Pusher for the services cfg has credentials for.
+func New(cfg Config) (*Pusher, error) {
+	p := &Pusher{}
+	if cfg.VAPIDPrivateKey != "" {
+		s, err := newWebPushSender(cfg.VAPIDPrivateKey, cfg.VAPIDSubject)
+		if err != nil {
+			return nil, fmt.Errorf("push.New: %w", err)
+		}
+		p.webPush = s
+	}
+	if cfg.FCMCredentialsFile != "" {
+		s, err := newFCMSender(cfg.FCMCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("push.New: %w", err)
+		}
+		p.fcm = s
+	}
+	return p, nil
+}
+
+// Platforms lists the enabled platforms.
+func (p *Pusher) Platforms() []string {
+	out := []string{}
+	if p.fcm != nil {
+		out = append(out, model.PushPlatformFCM)
+	}
+	if p.webPush != nil {
+		out = append(out, model.PushPlatformWebPush)
+	}
+	return out
+}
+
+// Supports reports whether devices of platform can be pushed to.
+func (p *Pusher) Supports(platform string) bool {
+	switch platform {
+	case model.PushPlatformFCM:
+		return p.fcm != nil
+	case model.PushPlatformWebPush:
+		return p.webPush != nil
+	}
+	return false
+}
+
+// Enabled reports whether any platform is enabled.
+func (p *Pusher) Enabled() bool {
+	return p.fcm != nil || p.webPush != nil
+}
+
+// VAPIDPublicKey returns the base64url application server key browsers subscribe
+// with, or "" when Web Push is disabled.
+func (p *Pusher) VAPIDPublicKey() string {
+	if p.webPush == nil {
+		return ""
+	}
+	return p.webPush.publicKey
+}
+
+// Send pushes msg to d. It returns ErrGone when d should be unregistered.
+func (p *Pusher) Send(ctx context.Context, d *model.PushDevice, msg Message) error {
+	if !p.Supports(d.Platform) {
+		return fmt.Errorf("push: platform %q is not enabled", d.Platform)
+	}
+	if d.Platform == model.PushPlatformFCM {
+		return p.fcm.send(ctx, d.Token, msg)
+	}
+	if d.P256DH == nil || d.Auth == nil {
+		return ErrGone
+	}
+	return p.webPush.send(ctx, d.Token, *d.P256DH, *d.Auth, msg)
+}
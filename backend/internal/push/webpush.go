@@ -0,0 +1,249 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// webPushTTL is how long the push service keeps a message for an offline browser.
+	webPushTTL = "86400"
+
+	// webPushRecordSize is the aes128gcm record size; messages must fit in one record.
+	webPushRecordSize = 4096
+)
+
+// webPushSender implements the Web Push protocol (RFC 8030) with VAPID authentication
+// (RFC 8292) and aes128gcm payload encryption (RFC 8291).
+type webPushSender struct {
+	key       *ecdsa.PrivateKey
+	publicKey string // base64url uncompressed point, as browsers expect it
+	subject   string
+}
+
+func newWebPushSender(privateKey, subject string) (*webPushSender, error) {
+	raw, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	ecdhKey, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https://") {
+		return nil, fmt.Errorf("VAPID subject must be a mailto: or https: URL")
+	}
+	pub := ecdhKey.PublicKey().Bytes() // 0x04 || X || Y
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return &webPushSender{key: key, publicKey: base64.RawURLEncoding.EncodeToString(pub), subject: subject}, nil
+}
+
+func (s *webPushSender) send(ctx context.Context, endpoint, p256dh, authSecret string, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	body, err := encryptWebPush(payload, p256dh, authSecret)
+	if err != nil {
+		// Keys that cannot be used will never work; drop the subscription.
+		return ErrGone
+	}
+	token, err := s.vapidToken(endpoint)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	req.Header.Set("Authorization", "vapid t="+token+", k="+s.publicKey)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", webPushTTL)
+	req.Header.Set("Urgency", "normal")
+
+	resp, err := webPushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webpush: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webpush: %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// webPushClient posts to subscription endpoints. Those are URLs users supply, so it
+// only connects to public addresses: the check runs on the resolved address of every
+// connection, redirects included, so a name cannot be pointed at an internal host
+// after the subscription was registered. Proxy settings are ignored, as a proxy would
+// make the connection unchecked on our behalf.
+var webPushClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: dialPublicOnly}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+}
+
+// nonPublicPrefixes are ranges outside the loopback, private, link-local and
+// multicast ones the net/netip predicates cover that still do not reach the public
+// internet: shared address space (where some clouds keep their metadata service),
+// benchmarking, reserved, NAT64 and documentation ranges.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local (including the
+// 169.254.169.254 metadata service), multicast and other non-public addresses.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webpush: unexpected dial address %q", address)
+	}
+	ip := ap.Addr().Unmap()
+	public := ip.IsGlobalUnicast() && !ip.IsPrivate()
+	for _, p := range nonPublicPrefixes {
+		public = public && !p.Contains(ip)
+	}
+	if !public {
+		return fmt.Errorf("webpush: endpoint address %s is not public", ip)
+	}
+	return nil
+}
+
+// vapidToken signs the VAPID JWT for the push service that hosts endpoint.
+func (s *webPushSender) vapidToken(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": s.subject,
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(s.key)
+}
+
+// encryptWebPush encrypts payload for a subscription as a single aes128gcm record.
+func encryptWebPush(payload []byte, p256dh, authSecret string) ([]byte, error) {
+	uaPublicRaw, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return nil, err
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicRaw)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := decodeBase64URL(authSecret)
+	if err != nil {
+		return nil, err
+	}
+	if len(auth) != 16 {
+		return nil, fmt.Errorf("auth secret must be 16 bytes, got %d", len(auth))
+	}
+	if len(payload)+17 > webPushRecordSize {
+		return nil, fmt.Errorf("payload of %d bytes is too large", len(payload))
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	shared, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := "WebPush: info\x00" + string(uaPublicRaw) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, shared, auth, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record.
+	ciphertext := gcm.Seal(nil, nonce, append(payload, 0x02), nil)
+
+	// Header: salt || record size || key id length || key id (our public key).
+	out := make([]byte, 0, 16+4+1+len(asPublic)+len(ciphertext))
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, webPushRecordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+	return append(out, ciphertext...), nil
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers vary.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+var (
+	lookupMisses  = expvar.NewMap("lookup_misses")  // 404s answered, by guard name
+	lookupBlocked = expvar.NewMap("lookup_blocked") // requests rejected, by guard name
+)
+
+// MissGuard blocks clients that fail too many lookups, such as someone guessing share
+// tokens. Every 404 a client receives counts against it; past the limit all of its
+// requests get 429 until the window resets. Reaching the limit is logged as an error
+// so it can be alerted on.
+type MissGuard struct {
+	name   string
+	misses *Limiter
+}
+
+// NewMissGuard allows limit 404s per client in each period of length per. name labels
+// logs and metrics. limit <= 0 disables the guard.
+func NewMissGuard(name string, limit int, per time.Duration) *MissGuard {
+	return &MissGuard{name: name, misses: New(name+"_misses", limit, per)}
+}
+
+// SetLimit changes the guard to allow limit 404s per client in each period of length per.
+func (g *MissGuard) SetLimit(limit int, per time.Duration) {
+	g.misses.SetLimit(limit, per)
+}
+
+// Middleware applies the guard; key picks the client, typically proxy.ClientKey.
+func (g *MissGuard) Middleware(key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if blocked, retryAfter := g.misses.Exceeded(k); blocked {
+				lookupBlocked.Add(g.name, 1)
+				writeLimited(w, retryAfter)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			if sw.status != http.StatusNotFound {
+				return
+			}
+
+			lookupMisses.Add(g.name, 1)
+			g.misses.Allow(k)
+			if blocked, retryAfter := g.misses.Exceeded(k); blocked {
+				logger.ErrorLog(r.Context(), "Lookup brute force suspected", logger.ErrorDetails{
+					Code: "LOOKUP_BRUTE_FORCE",
+					Details: fmt.Sprintf("guard=%s client=%s limit=%d blocked_for=%s path=%s",
+						g.name, k, g.misses.Limit(), retryAfter.Round(time.Second), r.URL.Path),
+				})
+			}
+		})
+	}
+}
+
+// statusWriter records the status code a handler responds with.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController and i18n.Localize reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush keeps streaming downloads flushing through the guard.
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,138 @@
+// Package ratelimit throttles public endpoints per client, e.g. per IP address or,
+// for IPv6, per network (see proxy.ClientKey).
+package ratelimit
+
+import (
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/respond"
+)
+
+var (
+	limitAllowed  = expvar.NewMap("ratelimit_allowed")  // requests counted within the limit, by limiter name
+	limitRejected = expvar.NewMap("ratelimit_rejected") // requests over the limit, by limiter name
+	limitKeys     = expvar.NewMap("ratelimit_keys")     // clients with an open window at the last sweep, by limiter name
+)
+
+// Limiter allows each key a fixed number of requests per window. Windows are fixed
+// rather than sliding, which is enough to stop a client from flooding an endpoint.
+type Limiter struct {
+	name   string
+	limit  int
+	window time.Duration
+	keys   *expvar.Int
+
+	mu      sync.Mutex
+	windows map[string]*window
+	swept   time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// New allows limit requests per key in each period of length per. name labels logs
+// and metrics. limit <= 0 disables limiting.
+func New(name string, limit int, per time.Duration) *Limiter {
+	keys := new(expvar.Int)
+	limitKeys.Set(name, keys)
+	return &Limiter{name: name, limit: limit, window: per, keys: keys, windows: make(map[string]*window), swept: time.Now()}
+}
+
+// Allow counts a request for key and reports whether it is within the limit.
+// When it is not, the returned duration is how long until the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 {
+		return true, 0
+	}
+
+	// Drop finished windows now and then so the map doesn't grow with every client seen.
+	if now.Sub(l.swept) > l.window {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, k)
+			}
+		}
+		l.swept = now
+		l.keys.Set(int64(len(l.windows)))
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		limitRejected.Add(l.name, 1)
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	limitAllowed.Add(l.name, 1)
+	return true, 0
+}
+
+// Exceeded reports whether key has used up its current window, without counting a
+// request. When it has, the returned duration is how long until the window resets.
+func (l *Limiter) Exceeded(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit <= 0 {
+		return false, 0
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= l.window || w.count < l.limit {
+		return false, 0
+	}
+	return true, w.start.Add(l.window).Sub(now)
+}
+
+// SetLimit changes the limit to limit requests per period of length per. Open windows
+// keep their start and count, so clients already over the new limit are held back
+// until their window resets.
+func (l *Limiter) SetLimit(limit int, per time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit, l.window = limit, per
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// Middleware rejects requests over the limit with 429 and Retry-After. key picks the
+// client a request is counted against, typically proxy.ClientKey.
+func (l *Limiter) Middleware(key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if ok, retryAfter := l.Allow(k); !ok {
+				logger.Warn(r.Context(), "Rate limit exceeded", map[string]interface{}{
+					"limiter": l.name, "path": r.URL.Path, "key": k,
+				})
+				writeLimited(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeLimited answers 429 with a Retry-After of retryAfter, rounded up to whole seconds.
+func writeLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	respond.Error(w, http.StatusTooManyRequests, "rate_limited", "too many requests, try again later")
+}
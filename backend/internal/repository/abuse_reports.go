@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const abuseReportColumns = "id, share_link_id, file_id, token, reason, details, reporter_email, reporter_ip, status, action, resolved_by, resolved_at, created_at"
+
+// abuseReportWithFileSelect joins reports with their file and link for the review queue.
+const abuseReportWithFileSelect = `SELECT a.id, a.share_link_id, a.file_id, a.token, a.reason, a.details, a.reporter_email,
+	        a.reporter_ip, a.status, a.action, a.resolved_by, a.resolved_at, a.created_at,
+	        f.name, f.mime_type, f.user_id, s.enabled,
+	        (SELECT COUNT(*) FROM abuse_reports o WHERE o.file_id = a.file_id AND o.status = 'open')
+	 FROM abuse_reports a
+	 LEFT JOIN files f       ON f.id = a.file_id
+	 LEFT JOIN share_links s ON s.id = a.share_link_id`
+
+var (
+	// ErrAbuseReportNotFound is returned when a report does not exist or was already resolved.
+	ErrAbuseReportNotFound = notFoundError("abuse report not found or already resolved")
+	// ErrReportTargetGone is returned when the reported link or file has since been deleted.
+	ErrReportTargetGone = notFoundError("reported link or file no longer exists")
+)
+
+type AbuseReportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAbuseReportRepository(db *pgxpool.Pool) *AbuseReportRepository {
+	return &AbuseReportRepository{db: db}
+}
+
+func scanAbuseReport(row pgx.Row, extra ...interface{}) (*model.AbuseReport, error) {
+	a := &model.AbuseReport{}
+	dest := []interface{}{&a.ID, &a.ShareLinkID, &a.FileID, &a.Token, &a.Reason, &a.Details, &a.ReporterEmail,
+		&a.ReporterIP, &a.Status, &a.Action, &a.ResolvedBy, &a.ResolvedAt, &a.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func scanAbuseReportWithFile(row pgx.Row) (*model.AbuseReportWithFile, error) {
+	out := &model.AbuseReportWithFile{}
+	a, err := scanAbuseReport(row, &out.FileName, &out.MimeType, &out.OwnerID, &out.LinkEnabled, &out.OpenReports)
+	if err != nil {
+		return nil, err
+	}
+	out.AbuseReport = *a
+	return out, nil
+}
+
+// Create files a report against link.
+func (r *AbuseReportRepository) Create(ctx context.Context, link *model.ShareLink, reason, details string, reporterEmail *string, reporterIP string) (*model.AbuseReport, error) {
+	start := time.Now()
+	query := "INSERT INTO abuse_reports (share_link_id, file_id, token, reason, details, reporter_email, reporter_ip) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ..."
+
+	report, err := scanAbuseReport(r.db.QueryRow(ctx,
+		`INSERT INTO abuse_reports (share_link_id, file_id, token, reason, details, reporter_email, reporter_ip)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+abuseReportColumns,
+		link.ID, link.FileID, link.Token, reason, details, reporterEmail, reporterIP,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("AbuseReportRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("AbuseReportRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return report, nil
+}
+
+// List returns up to limit reports with the given status. Open reports come oldest
+// first so the queue is worked in order; resolved ones newest first.
+func (r *AbuseReportRepository) List(ctx context.Context, status string, limit int) ([]*model.AbuseReportWithFile, error) {
+	start := time.Now()
+	query := "SELECT ... FROM abuse_reports a LEFT JOIN files f ... LEFT JOIN share_links s ... WHERE a.status = $1 ORDER BY ... LIMIT $2"
+
+	rows, err := r.db.Query(ctx,
+		abuseReportWithFileSelect+`
+		 WHERE a.status = $1
+		 ORDER BY CASE WHEN $1 = 'open' THEN a.created_at END ASC, a.created_at DESC, a.id
+		 LIMIT $2`,
+		status, limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("AbuseReportRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("AbuseReportRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.AbuseReportWithFile
+	for rows.Next() {
+		a, err := scanAbuseReportWithFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// FindByID fetches a report with its file and link details, or nil if there is none.
+func (r *AbuseReportRepository) FindByID(ctx context.Context, reportID int64) (*model.AbuseReportWithFile, error) {
+	start := time.Now()
+	query := "SELECT ... FROM abuse_reports a LEFT JOIN files f ... LEFT JOIN share_links s ... WHERE a.id = $1"
+
+	a, err := scanAbuseReportWithFile(r.db.QueryRow(ctx, abuseReportWithFileSelect+" WHERE a.id = $1", reportID))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("AbuseReportRepository.FindByID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("AbuseReportRepository.FindByID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return a, nil
+}
+
+// Dismiss closes an open report without acting on it.
+func (r *AbuseReportRepository) Dismiss(ctx context.Context, reportID, adminID int64) error {
+	start := time.Now()
+	query := "UPDATE abuse_reports SET status = 'dismissed', resolved_by = $2, resolved_at = NOW() WHERE id = $1 AND status = 'open'"
+
+	result, err := r.db.Exec(ctx, query, reportID, adminID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("AbuseReportRepository.Dismiss: %s", err.Error()),
+		})
+		return fmt.Errorf("AbuseReportRepository.Dismiss: %w", classify(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAbuseReportNotFound
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// TakeDownLink disables the share link an open report is about so that its owner
+// cannot re-enable it, and resolves every open report against that link.
+func (r *AbuseReportRepository) TakeDownLink(ctx context.Context, reportID, adminID int64) (*model.Takedown, error) {
+	start := time.Now()
+	query := "SELECT share_link_id FROM abuse_reports WHERE id = $1 AND status = 'open' FOR UPDATE; UPDATE share_links SET enabled = FALSE, taken_down_at = ... RETURNING ...; UPDATE abuse_reports SET status = 'actioned', action = 'link_disabled' ... WHERE share_link_id = $1 AND status = 'open'"
+
+	t := &model.Takedown{Action: model.AbuseActionLinkDisabled}
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		linkID, err := lockOpenReport(ctx, tx, reportID, "share_link_id")
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow(ctx,
+			`UPDATE share_links s SET enabled = FALSE, taken_down_at = COALESCE(s.taken_down_at, NOW())
+			 FROM files f
+			 WHERE s.id = $1 AND f.id = s.file_id
+			 RETURNING f.id, f.name, f.user_id`, linkID,
+		).Scan(&t.FileID, &t.FileName, &t.OwnerID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrReportTargetGone
+			}
+			return err
+		}
+		t.LinksDisabled = 1
+
+		result, err := tx.Exec(ctx,
+			`UPDATE abuse_reports SET status = 'actioned', action = $2, resolved_by = $3, resolved_at = NOW()
+			 WHERE share_link_id = $1 AND status = 'open'`,
+			linkID, model.AbuseActionLinkDisabled, adminID)
+		if err != nil {
+			return err
+		}
+		t.ReportsResolved = result.RowsAffected()
+		return nil
+	})
+
+	return r.finishTakedown(ctx, "AbuseReportRepository.TakeDownLink", query, start, t, err)
+}
+
+// TakeDownFile marks the file an open report is about as taken down, disables all
+// of its share links, and resolves every open report against the file.
+func (r *AbuseReportRepository) TakeDownFile(ctx context.Context, reportID, adminID int64) (*model.Takedown, error) {
+	start := time.Now()
+	query := "SELECT file_id FROM abuse_reports WHERE id = $1 AND status = 'open' FOR UPDATE; UPDATE files SET taken_down_at = ... RETURNING ...; UPDATE share_links SET enabled = FALSE, taken_down_at = ... WHERE file_id = $1; UPDATE abuse_reports SET status = 'actioned', action = 'file_disabled' ... WHERE file_id = $1 AND status = 'open'"
+
+	t := &model.Takedown{Action: model.AbuseActionFileDisabled}
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		fileID, err := lockOpenReport(ctx, tx, reportID, "file_id")
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow(ctx,
+			`UPDATE files SET taken_down_at = COALESCE(taken_down_at, NOW())
+			 WHERE id = $1
+			 RETURNING id, name, user_id`, fileID,
+		).Scan(&t.FileID, &t.FileName, &t.OwnerID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrReportTargetGone
+			}
+			return err
+		}
+
+		result, err := tx.Exec(ctx,
+			`UPDATE share_links SET enabled = FALSE, taken_down_at = COALESCE(taken_down_at, NOW())
+			 WHERE file_id = $1`, fileID)
+		if err != nil {
+			return err
+		}
+		t.LinksDisabled = result.RowsAffected()
+
+		result, err = tx.Exec(ctx,
+			`UPDATE abuse_reports SET status = 'actioned', action = $2, resolved_by = $3, resolved_at = NOW()
+			 WHERE file_id = $1 AND status = 'open'`,
+			fileID, model.AbuseActionFileDisabled, adminID)
+		if err != nil {
+			return err
+		}
+		t.ReportsResolved = result.RowsAffected()
+		return nil
+	})
+
+	return r.finishTakedown(ctx, "AbuseReportRepository.TakeDownFile", query, start, t, err)
+}
+
+// lockOpenReport locks an open report and returns its target column (share_link_id or file_id).
+func lockOpenReport(ctx context.Context, tx pgx.Tx, reportID int64, column string) (int64, error) {
+	var target *int64
+	if err := tx.QueryRow(ctx,
+		"SELECT "+column+" FROM abuse_reports WHERE id = $1 AND status = 'open' FOR UPDATE", reportID,
+	).Scan(&target); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrAbuseReportNotFound
+		}
+		return 0, err
+	}
+	if target == nil {
+		return 0, ErrReportTargetGone
+	}
+	return *target, nil
+}
+
+// finishTakedown logs the outcome of a takedown transaction.
+func (r *AbuseReportRepository) finishTakedown(ctx context.Context, method, query string, start time.Time, t *model.Takedown, err error) (*model.Takedown, error) {
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, ErrAbuseReportNotFound) || errors.Is(err, ErrReportTargetGone) {
+			return nil, err
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("%s: %s", method, err.Error()),
+		})
+		return nil, fmt.Errorf("%s: %w", method, classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: t.LinksDisabled + t.ReportsResolved,
+	})
+	return t, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+type AdminAuditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAdminAuditRepository(db *pgxpool.Pool) *AdminAuditRepository {
+	return &AdminAuditRepository{db: db}
+}
+
+// Insert appends e to the admin audit trail and sets its ID and CreatedAt.
+func (r *AdminAuditRepository) Insert(ctx context.Context, e *model.AdminAuditEntry) error {
+	start := time.Now()
+	query := `INSERT INTO admin_audit (actor_id, action, target_type, target_id, before, after, status, ip_address, request_id)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`
+
+	var before, after *string
+	if len(e.Before) > 0 {
+		s := string(e.Before)
+		before = &s
+	}
+	if len(e.After) > 0 {
+		s := string(e.After)
+		after = &s
+	}
+	err := r.db.QueryRow(ctx, query,
+		e.ActorID, e.Action, e.TargetType, e.TargetID, before, after, e.Status, e.IPAddress, e.RequestID,
+	).Scan(&e.ID, &e.CreatedAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("AdminAuditRepository.Insert: %s", err.Error()),
+		})
+		return fmt.Errorf("AdminAuditRepository.Insert: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return nil
+}
+
+// List returns the audit entries matching f, newest first.
+func (r *AdminAuditRepository) List(ctx context.Context, f model.AdminAuditFilter) ([]*model.AdminAuditEntry, error) {
+	start := time.Now()
+	query := "SELECT ... FROM admin_audit WHERE ($1 = 0 OR actor_id = $1) AND ... ORDER BY id DESC LIMIT $7"
+
+	var since, until *time.Time
+	if !f.Since.IsZero() {
+		since = &f.Since
+	}
+	if !f.Until.IsZero() {
+		until = &f.Until
+	}
+	rows, err := r.db.Query(ctx,
+		`SELECT id, actor_id, action, target_type, target_id, before, after, status, ip_address, request_id, created_at
+		 FROM admin_audit
+		 WHERE ($1 = 0 OR actor_id = $1)
+		   AND ($2 = '' OR target_type = $2)
+		   AND ($3 = '' OR target_id = $3)
+		   AND ($4::timestamptz IS NULL OR created_at >= $4)
+		   AND ($5::timestamptz IS NULL OR created_at < $5)
+		   AND ($6 = 0 OR id < $6)
+		 ORDER BY id DESC
+		 LIMIT $7`,
+		f.ActorID, f.TargetType, f.TargetID, since, until, f.BeforeID, f.Limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("AdminAuditRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("AdminAuditRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.AdminAuditEntry
+	for rows.Next() {
+		e := &model.AdminAuditEntry{}
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetType, &e.TargetID, &e.Before, &e.After, &e.Status, &e.IPAddress, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("AdminAuditRepository.List: %w", classify(err))
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("AdminAuditRepository.List: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
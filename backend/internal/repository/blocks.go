@@ -24,11 +24,11 @@ func NewBlockRepository(db *pgxpool.Pool) *BlockRepository {
 // FindByHash returns an existing block by its SHA-256 hash. Returns nil, nil if not found.
 func (r *BlockRepository) FindByHash(ctx context.Context, hash string) (*model.Block, error) {
 	start := time.Now()
-	query := "SELECT id, sha256_hash, s3_key, size_bytes, ref_count, created_at FROM blocks WHERE sha256_hash = $1"
+	query := "SELECT id, sha256_hash, s3_key, size_bytes, compression, stored_bytes, ref_count, created_at FROM blocks WHERE sha256_hash = $1"
 
 	block := &model.Block{}
 	err := r.db.QueryRow(ctx, query, hash,
-	).Scan(&block.ID, &block.SHA256Hash, &block.S3Key, &block.SizeBytes, &block.RefCount, &block.CreatedAt)
+	).Scan(&block.ID, &block.SHA256Hash, &block.S3Key, &block.SizeBytes, &block.Compression, &block.StoredBytes, &block.RefCount, &block.CreatedAt)
 
 	duration := time.Since(start).Milliseconds()
 
@@ -42,7 +42,7 @@ func (r *BlockRepository) FindByHash(ctx context.Context, hash string) (*model.B
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.FindByHash: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("BlockRepository.FindByHash: %w", err)
+		return nil, fmt.Errorf("BlockRepository.FindByHash: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -51,38 +51,57 @@ func (r *BlockRepository) FindByHash(ctx context.Context, hash string) (*model.B
 	return block, nil
 }
 
-// Create inserts a new block record and returns it.
-func (r *BlockRepository) Create(ctx context.Context, hash, s3Key string, sizeBytes int64) (*model.Block, error) {
+// Acquire takes a reference on the block with the given hash, creating the row if needed,
+// in one atomic upsert. inserted reports whether the row is new, in which case the caller
+// must make sure the object exists in S3. Racing with the GC job is safe: GC deletes
+// zero-ref blocks under a row lock, so the upsert either revives the row before GC sees it
+// or waits and inserts a fresh one afterwards. A fresh row also cancels any queued S3
+// deletion of its key; if the deletion worker got there first, the object is gone and the
+// caller's existence check re-uploads it. compression and storedBytes describe the
+// object at s3Key; an existing row keeps its own.
+func (r *BlockRepository) Acquire(ctx context.Context, hash, s3Key string, sizeBytes int64, compression string, storedBytes int64) (int64, bool, error) {
 	start := time.Now()
-	query := "INSERT INTO blocks (sha256_hash, s3_key, size_bytes, ref_count) VALUES ($1, $2, $3, 1) RETURNING ..."
-
-	block := &model.Block{}
-	err := r.db.QueryRow(ctx,
-		`INSERT INTO blocks (sha256_hash, s3_key, size_bytes, ref_count)
-		 VALUES ($1, $2, $3, 1)
-		 RETURNING id, sha256_hash, s3_key, size_bytes, ref_count, created_at`,
-		hash, s3Key, sizeBytes,
-	).Scan(&block.ID, &block.SHA256Hash, &block.S3Key, &block.SizeBytes, &block.RefCount, &block.CreatedAt)
+	query := "INSERT INTO blocks (sha256_hash, s3_key, size_bytes, compression, stored_bytes, ref_count) VALUES ($1, $2, $3, $4, $5, 1) ON CONFLICT (sha256_hash) DO UPDATE SET ref_count = blocks.ref_count + 1 RETURNING id, (xmax = 0); DELETE FROM s3_deletions WHERE s3_key = $1"
+
+	var id int64
+	var inserted bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx,
+			`INSERT INTO blocks (sha256_hash, s3_key, size_bytes, compression, stored_bytes, ref_count)
+			 VALUES ($1, $2, $3, $4, $5, 1)
+			 ON CONFLICT (sha256_hash) DO UPDATE SET ref_count = blocks.ref_count + 1
+			 RETURNING id, (xmax = 0)`,
+			hash, s3Key, sizeBytes, compression, storedBytes,
+		).Scan(&id, &inserted); err != nil {
+			return err
+		}
+		if !inserted {
+			return nil
+		}
+		_, err := tx.Exec(ctx, "DELETE FROM s3_deletions WHERE s3_key = $1", s3Key)
+		return err
+	})
 
 	duration := time.Since(start).Milliseconds()
 
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("BlockRepository.Create: %s", err.Error()),
+			Code: "DB_UPSERT_ERR", Details: fmt.Sprintf("BlockRepository.Acquire: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("BlockRepository.Create: %w", err)
+		return 0, false, fmt.Errorf("BlockRepository.Acquire: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
 		Query: query, DurationMs: duration, RowsAffected: 1,
 	})
-	return block, nil
+	return id, inserted, nil
 }
 
-// IncrementRefCount increments the reference count for an existing block.
-func (r *BlockRepository) IncrementRefCount(ctx context.Context, blockID int64) error {
+// Release drops one reference taken by Acquire (e.g. when an upload fails).
+// Blocks reaching zero are left for the GC job.
+func (r *BlockRepository) Release(ctx context.Context, blockID int64) error {
 	start := time.Now()
-	query := "UPDATE blocks SET ref_count = ref_count + 1 WHERE id = $1"
+	query := "UPDATE blocks SET ref_count = ref_count - 1 WHERE id = $1"
 
 	result, err := r.db.Exec(ctx, query, blockID)
 
@@ -90,9 +109,9 @@ func (r *BlockRepository) IncrementRefCount(ctx context.Context, blockID int64)
 
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("BlockRepository.IncrementRefCount: %s", err.Error()),
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("BlockRepository.Release: %s", err.Error()),
 		})
-		return fmt.Errorf("BlockRepository.IncrementRefCount: %w", err)
+		return fmt.Errorf("BlockRepository.Release: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -101,85 +120,330 @@ func (r *BlockRepository) IncrementRefCount(ctx context.Context, blockID int64)
 	return nil
 }
 
-// DecrementRefCount decrements ref_count. Returns the new ref_count.
-func (r *BlockRepository) DecrementRefCount(ctx context.Context, blockID int64) (int, error) {
+// CollectGarbage deletes up to limit unreferenced blocks and queues their S3 objects in
+// s3_deletions for the deletion worker. Candidate rows are locked (skipping rows other
+// collectors hold) so a concurrent Acquire on the same hash waits and then re-creates the
+// block. Returns the number of blocks collected.
+func (r *BlockRepository) CollectGarbage(ctx context.Context, limit int) (int64, error) {
 	start := time.Now()
-	query := "UPDATE blocks SET ref_count = ref_count - 1 WHERE id = $1 RETURNING ref_count"
-
-	var newCount int
-	err := r.db.QueryRow(ctx, query, blockID).Scan(&newCount)
+	query := "WITH doomed AS (SELECT id, s3_key FROM blocks WHERE ref_count <= 0 ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED), queued AS (INSERT INTO s3_deletions (s3_key) SELECT s3_key FROM doomed ON CONFLICT (s3_key) DO UPDATE ...) DELETE FROM blocks b USING doomed d WHERE b.id = d.id"
+
+	result, err := r.db.Exec(ctx,
+		`WITH doomed AS (
+			SELECT id, s3_key FROM blocks WHERE ref_count <= 0
+			ORDER BY id LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), queued AS (
+			INSERT INTO s3_deletions (s3_key)
+			SELECT s3_key FROM doomed
+			ON CONFLICT (s3_key) DO UPDATE SET dead_at = NULL, attempts = 0, next_attempt_at = NOW()
+		)
+		DELETE FROM blocks b USING doomed d WHERE b.id = d.id`,
+		limit,
+	)
 
 	duration := time.Since(start).Milliseconds()
 
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("BlockRepository.DecrementRefCount: %s", err.Error()),
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("BlockRepository.CollectGarbage: %s", err.Error()),
 		})
-		return 0, fmt.Errorf("BlockRepository.DecrementRefCount: %w", err)
+		return 0, fmt.Errorf("BlockRepository.CollectGarbage: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
-		Query: query, DurationMs: duration, RowsAffected: 1,
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
 	})
-	return newCount, nil
+	return result.RowsAffected(), nil
 }
 
-// Delete permanently removes a block record (call only when ref_count == 0).
-func (r *BlockRepository) Delete(ctx context.Context, blockID int64) error {
+// PreviewCollectGarbage reports what CollectGarbage would remove: every block no longer
+// referenced, and their size.
+func (r *BlockRepository) PreviewCollectGarbage(ctx context.Context) (*model.CleanupPreview, error) {
 	start := time.Now()
-	query := "DELETE FROM blocks WHERE id = $1"
+	query := "SELECT COUNT(*), COALESCE(SUM(stored_bytes), 0) FROM blocks WHERE ref_count <= 0"
 
-	result, err := r.db.Exec(ctx, query, blockID)
+	p := &model.CleanupPreview{}
+	err := r.db.QueryRow(ctx, query).Scan(&p.Blocks, &p.BytesFreed)
 
 	duration := time.Since(start).Milliseconds()
 
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("BlockRepository.Delete: %s", err.Error()),
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.PreviewCollectGarbage: %s", err.Error()),
 		})
-		return fmt.Errorf("BlockRepository.Delete: %w", err)
+		return nil, fmt.Errorf("BlockRepository.PreviewCollectGarbage: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
-		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+		Query: query, DurationMs: duration, RowsAffected: 1,
 	})
-	return nil
+	p.Items = p.Blocks
+	return p, nil
+}
+
+// FindByFileID returns the blocks of a file within scope, in block_index order. A
+// block used twice by the file appears twice. A file outside the scope has no blocks,
+// so block keys of other users' files cannot be read by guessing a file ID.
+func (r *BlockRepository) FindByFileID(ctx context.Context, scope Scope, fileID int64) ([]*model.Block, error) {
+	ownerID, err := scope.owner("BlockRepository.FindByFileID")
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := "SELECT b.* FROM file_blocks fb JOIN files f ... JOIN blocks b ... WHERE fb.file_id = $1 AND (owner) ORDER BY fb.block_index"
+
+	rows, err := r.db.Query(ctx, `
+		SELECT b.id, b.sha256_hash, b.s3_key, b.size_bytes, b.compression, b.stored_bytes, b.ref_count, b.created_at
+		FROM file_blocks fb
+		JOIN files f ON f.id = fb.file_id
+		JOIN blocks b ON b.id = fb.block_id
+		WHERE fb.file_id = $1 AND ($2 = 0 OR f.user_id = $2)
+		ORDER BY fb.block_index ASC`, fileID, ownerID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.FindByFileID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("BlockRepository.FindByFileID: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var blocks []*model.Block
+	for rows.Next() {
+		b := &model.Block{}
+		if err := rows.Scan(&b.ID, &b.SHA256Hash, &b.S3Key, &b.SizeBytes, &b.Compression, &b.StoredBytes, &b.RefCount, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("BlockRepository.FindByFileID: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(blocks)),
+	})
+	return blocks, nil
 }
 
-// FindByIDs returns blocks ordered by the provided ids slice.
-func (r *BlockRepository) FindByIDs(ctx context.Context, ids []int64) ([]*model.Block, error) {
+// ListAfter returns up to limit blocks with IDs above afterID, in ID order, for jobs
+// that walk every block in batches.
+func (r *BlockRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]*model.Block, error) {
 	start := time.Now()
-	query := "SELECT id, sha256_hash, s3_key, size_bytes, ref_count, created_at FROM blocks WHERE id = ANY($1)"
+	query := "SELECT id, sha256_hash, s3_key, size_bytes, compression, stored_bytes, ref_count, created_at FROM blocks WHERE id > $1 ORDER BY id LIMIT $2"
 
-	rows, err := r.db.Query(ctx, query, ids)
+	rows, err := r.db.Query(ctx, query, afterID, limit)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.FindByIDs: %s", err.Error()),
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.ListAfter: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("BlockRepository.FindByIDs: %w", err)
+		return nil, fmt.Errorf("BlockRepository.ListAfter: %w", classify(err))
 	}
 	defer rows.Close()
 
-	blockMap := make(map[int64]*model.Block, len(ids))
+	var blocks []*model.Block
 	for rows.Next() {
 		b := &model.Block{}
-		if err := rows.Scan(&b.ID, &b.SHA256Hash, &b.S3Key, &b.SizeBytes, &b.RefCount, &b.CreatedAt); err != nil {
+		if err := rows.Scan(&b.ID, &b.SHA256Hash, &b.S3Key, &b.SizeBytes, &b.Compression, &b.StoredBytes, &b.RefCount, &b.CreatedAt); err != nil {
 			return nil, err
 		}
-		blockMap[b.ID] = b
+		blocks = append(blocks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("BlockRepository.ListAfter: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(blocks)),
+	})
+	return blocks, nil
+}
+
+// Count returns the number of blocks.
+func (r *BlockRepository) Count(ctx context.Context) (int64, error) {
+	start := time.Now()
+	query := "SELECT COUNT(*) FROM blocks"
+
+	var n int64
+	err := r.db.QueryRow(ctx, query).Scan(&n)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.Count: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("BlockRepository.Count: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return n, nil
+}
+
+// FirstUploaderBytes returns the size of the blocks whose oldest referencing file
+// belongs to userID: each stored block counted once, for the user who uploaded it
+// first. When that file goes, the block passes to the owner of the next oldest one.
+func (r *BlockRepository) FirstUploaderBytes(ctx context.Context, userID int64) (int64, error) {
+	start := time.Now()
+	query := "SELECT COALESCE(SUM(b.size_bytes), 0) FROM blocks b WHERE b.id IN (<blocks of the user's files>) AND (<owner of the oldest file using b>) = $1"
+
+	var n int64
+	err := r.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(b.size_bytes), 0)
+		 FROM blocks b
+		 WHERE b.id IN (SELECT fb.block_id FROM file_blocks fb JOIN files f ON f.id = fb.file_id WHERE f.user_id = $1)
+		   AND (SELECT f.user_id FROM file_blocks fb JOIN files f ON f.id = fb.file_id
+		        WHERE fb.block_id = b.id ORDER BY f.id LIMIT 1) = $1`,
+		userID,
+	).Scan(&n)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.FirstUploaderBytes: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("BlockRepository.FirstUploaderBytes: %w", classify(err))
 	}
 
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return n, nil
+}
+
+// ReplaceS3Key points a block at newKey, whose object the caller has already written,
+// and queues oldKey for deletion once grace has passed so downloads already reading
+// the old object can finish. If the block is gone or no longer on oldKey (GC or another
+// rekey got there first) it is left alone and newKey is queued for deletion instead;
+// the deletion worker skips it if a block does use it. Reports whether the block was moved.
+func (r *BlockRepository) ReplaceS3Key(ctx context.Context, blockID int64, oldKey, newKey string, grace time.Duration) (bool, error) {
+	start := time.Now()
+	query := "UPDATE blocks SET s3_key = $3 WHERE id = $1 AND s3_key = $2; INSERT INTO s3_deletions (s3_key, next_attempt_at) VALUES (<old or new key>, ...) ...; DELETE FROM s3_deletions WHERE s3_key = $3"
+
+	var moved bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, "UPDATE blocks SET s3_key = $3 WHERE id = $1 AND s3_key = $2", blockID, oldKey, newKey)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			_, err := tx.Exec(ctx,
+				`INSERT INTO s3_deletions (s3_key) VALUES ($1)
+				 ON CONFLICT (s3_key) DO UPDATE SET dead_at = NULL, attempts = 0, next_attempt_at = NOW()`,
+				newKey)
+			return err
+		}
+		moved = true
+
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO s3_deletions (s3_key, next_attempt_at)
+			 VALUES ($1, NOW() + $2::interval)
+			 ON CONFLICT (s3_key) DO UPDATE SET dead_at = NULL, attempts = 0, next_attempt_at = EXCLUDED.next_attempt_at`,
+			oldKey, grace.String()); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "DELETE FROM s3_deletions WHERE s3_key = $1", newKey)
+		return err
+	})
+
 	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("BlockRepository.ReplaceS3Key: %s", err.Error()),
+		})
+		return false, fmt.Errorf("BlockRepository.ReplaceS3Key: %w", classify(err))
+	}
+
+	var affected int64
+	if moved {
+		affected = 1
+	}
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
-		Query: query, DurationMs: duration, RowsAffected: int64(len(blockMap)),
+		Query: query, DurationMs: duration, RowsAffected: affected,
 	})
+	return moved, nil
+}
+
+// blockActualRefsSQL counts the actual references to block alias b. Every table that
+// holds block references must be included here so integrity checks see all of them.
+// Trashed files keep their file_blocks rows, so they are already counted; chunks of
+// unfinished upload sessions hold a reference each.
+const blockActualRefsSQL = `((SELECT COUNT(*) FROM file_blocks fb WHERE fb.block_id = b.id) +
+	(SELECT COUNT(*) FROM upload_session_chunks uc WHERE uc.block_id = b.id))`
+
+// FindRefCountDrift returns blocks whose ref_count differs from their actual reference count,
+// plus the total number of blocks and how many have no references at all.
+func (r *BlockRepository) FindRefCountDrift(ctx context.Context) ([]*model.BlockRefDrift, int64, int64, error) {
+	start := time.Now()
+	query := "SELECT b.id, b.sha256_hash, b.ref_count, <actual refs> FROM blocks b ORDER BY b.id"
+
+	rows, err := r.db.Query(ctx,
+		`SELECT b.id, b.sha256_hash, b.ref_count, `+blockActualRefsSQL+` FROM blocks b ORDER BY b.id`,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("BlockRepository.FindRefCountDrift: %s", err.Error()),
+		})
+		return nil, 0, 0, fmt.Errorf("BlockRepository.FindRefCountDrift: %w", classify(err))
+	}
+	defer rows.Close()
 
-	// Return in the requested order
-	ordered := make([]*model.Block, 0, len(ids))
-	for _, id := range ids {
-		if b, ok := blockMap[id]; ok {
-			ordered = append(ordered, b)
+	var drift []*model.BlockRefDrift
+	var total, unreferenced int64
+	for rows.Next() {
+		d := &model.BlockRefDrift{}
+		if err := rows.Scan(&d.BlockID, &d.SHA256Hash, &d.RefCount, &d.ActualRefs); err != nil {
+			return nil, 0, 0, err
 		}
+		total++
+		if d.ActualRefs == 0 {
+			unreferenced++
+		}
+		if d.RefCount != d.ActualRefs {
+			drift = append(drift, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, 0, fmt.Errorf("BlockRepository.FindRefCountDrift: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: total,
+	})
+	return drift, total, unreferenced, nil
+}
+
+// RepairRefCounts sets ref_count to the actual reference count for the given blocks.
+// Counts are recomputed at update time, so references added since the check are respected.
+func (r *BlockRepository) RepairRefCounts(ctx context.Context, blockIDs []int64) (int64, error) {
+	start := time.Now()
+	query := "UPDATE blocks b SET ref_count = <actual refs> WHERE b.id = ANY($1) AND b.ref_count <> <actual refs>"
+
+	result, err := r.db.Exec(ctx,
+		`UPDATE blocks b SET ref_count = `+blockActualRefsSQL+`
+		 WHERE b.id = ANY($1) AND b.ref_count <> `+blockActualRefsSQL,
+		blockIDs,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("BlockRepository.RepairRefCounts: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("BlockRepository.RepairRefCounts: %w", classify(err))
 	}
-	return ordered, nil
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
 }
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+type ChecksumRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewChecksumRepository(db *pgxpool.Pool) *ChecksumRepository {
+	return &ChecksumRepository{db: db}
+}
+
+// FindByFileID returns the checksums recorded for a file, or nil if none were.
+// Callers check ownership of the file first.
+func (r *ChecksumRepository) FindByFileID(ctx context.Context, fileID int64) (*model.StoredChecksums, error) {
+	start := time.Now()
+	query := "SELECT file_id, content_hash, sha256, block_sha256, computed_at, verified_at FROM file_checksums WHERE file_id = $1"
+
+	c := &model.StoredChecksums{}
+	err := r.db.QueryRow(ctx, query, fileID).Scan(&c.FileID, &c.ContentHash, &c.SHA256, &c.BlockSHA256, &c.ComputedAt, &c.VerifiedAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ChecksumRepository.FindByFileID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("ChecksumRepository.FindByFileID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return c, nil
+}
+
+// Put records the checksums of a file's current content, replacing older ones. With
+// verified set they were just confirmed against S3, which sets verified_at.
+func (r *ChecksumRepository) Put(ctx context.Context, fileID int64, sha256 string, blockSHA256 []string, verified bool) error {
+	start := time.Now()
+	query := "INSERT INTO file_checksums (file_id, content_hash, sha256, block_sha256, computed_at, verified_at) SELECT files.id, d.content_hash, ... FROM files <digest> WHERE files.id = $1 ON CONFLICT (file_id) DO UPDATE SET ..."
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO file_checksums (file_id, content_hash, sha256, block_sha256, computed_at, verified_at)
+		 SELECT files.id, d.content_hash, $2, $3, NOW(), CASE WHEN $4 THEN NOW() END
+		   FROM files `+fileDigestJoin+`
+		  WHERE files.id = $1
+		 ON CONFLICT (file_id) DO UPDATE
+		    SET content_hash = EXCLUDED.content_hash, sha256 = EXCLUDED.sha256, block_sha256 = EXCLUDED.block_sha256,
+		        computed_at = EXCLUDED.computed_at, verified_at = EXCLUDED.verified_at`,
+		fileID, sha256, blockSHA256, verified,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("ChecksumRepository.Put: %s", err.Error()),
+		})
+		return fmt.Errorf("ChecksumRepository.Put: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return nil
+}
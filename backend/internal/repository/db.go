@@ -2,19 +2,110 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/naratel/naratel-box/backend/internal/chaos"
+	"github.com/naratel/naratel-box/backend/internal/logger"
 )
 
-// NewPool creates a new PostgreSQL connection pool.
-func NewPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
-	pool, err := pgxpool.New(ctx, dsn)
+// maxApplicationName is PostgreSQL's limit (NAMEDATALEN - 1); longer names are truncated.
+const maxApplicationName = 63
+
+// ConnLabels name the pool's connections in pg_stat_activity and the server log.
+type ConnLabels struct {
+	ApplicationName string // application_name of every connection; "" = driver default
+	// TagRequests appends the request ID (" req=<id>") to application_name while a
+	// request holds the connection, at the cost of one statement per acquire.
+	TagRequests bool
+}
+
+// NewPool creates a new PostgreSQL connection pool whose statements are bounded by
+// limits and whose connections are named by labels. faults, when non-nil, injects
+// connection drops and latency (chaos mode).
+func NewPool(ctx context.Context, dsn string, limits QueryLimits, labels ConnLabels, faults *chaos.Injector) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("pgxpool.ParseConfig: %w", classify(err))
+	}
+	cfg.ConnConfig.Tracer = newQueryTracer(limits)
+	if labels.ApplicationName != "" {
+		cfg.ConnConfig.RuntimeParams["application_name"] = truncateName(labels.ApplicationName)
+	}
+	if labels.TagRequests {
+		tagConnections(cfg, cfg.ConnConfig.RuntimeParams["application_name"])
+	}
+	faults.WrapPool(cfg)
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("pgxpool.New: %w", err)
+		return nil, fmt.Errorf("pgxpool.New: %w", classify(err))
 	}
 	if err := pool.Ping(ctx); err != nil {
-		return nil, fmt.Errorf("db ping failed: %w", err)
+		return nil, fmt.Errorf("db ping failed: %w", classify(err))
 	}
 	return pool, nil
 }
+
+// tagConnections sets application_name to base plus the request ID whenever a
+// connection is acquired for a request, and back to base when it is next acquired
+// outside one, so pg_stat_activity shows which request is running each statement.
+func tagConnections(cfg *pgxpool.Config, base string) {
+	var tags sync.Map // *pgx.Conn -> application_name currently set
+
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		name := base
+		if id := logger.GetRequestID(ctx); id != "" {
+			name = truncateName(strings.TrimSpace(base + " req=" + id))
+		}
+		current, tagged := tags.Load(conn)
+		if (!tagged && name == base) || current == name {
+			return true
+		}
+		if _, err := conn.Exec(ctx, "SELECT set_config('application_name', $1, false)", name); err != nil {
+			logger.Warn(ctx, "Failed to tag database connection", map[string]interface{}{"error": err.Error()})
+			return false // the connection is in an unknown state; the pool replaces it
+		}
+		tags.Store(conn, name)
+		return true
+	}
+	cfg.BeforeClose = func(conn *pgx.Conn) {
+		tags.Delete(conn)
+	}
+}
+
+// truncateName cuts name to what PostgreSQL keeps of an application_name.
+func truncateName(name string) string {
+	if len(name) > maxApplicationName {
+		return name[:maxApplicationName]
+	}
+	return name
+}
+
+// MigrationVersion returns the schema version golang-migrate recorded in
+// schema_migrations, and whether a migration failed half-way (dirty). A database no
+// migration ran against reports version 0.
+func MigrationVersion(ctx context.Context, db *pgxpool.Pool) (int64, bool, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return 0, false, fmt.Errorf("MigrationVersion: %w", classify(err))
+	}
+	if !exists {
+		return 0, false, nil
+	}
+	var version int64
+	var dirty bool
+	err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("MigrationVersion: %w", classify(err))
+	}
+	return version, dirty, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+type EgressUsageRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEgressUsageRepository(db *pgxpool.Pool) *EgressUsageRepository {
+	return &EgressUsageRepository{db: db}
+}
+
+// Add counts bytes served on userID's behalf towards the current month.
+func (r *EgressUsageRepository) Add(ctx context.Context, userID, bytes int64) error {
+	start := time.Now()
+	query := "INSERT INTO egress_usage (user_id, month, bytes) VALUES ($1, date_trunc('month', NOW() AT TIME ZONE 'UTC')::DATE, $2) ON CONFLICT (user_id, month) DO UPDATE SET bytes = egress_usage.bytes + EXCLUDED.bytes, updated_at = NOW()"
+
+	_, err := r.db.Exec(ctx, query, userID, bytes)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("EgressUsageRepository.Add: %s", err.Error()),
+		})
+		return fmt.Errorf("EgressUsageRepository.Add: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return nil
+}
+
+// ThisMonth returns the bytes served on userID's behalf in the current month.
+func (r *EgressUsageRepository) ThisMonth(ctx context.Context, userID int64) (int64, error) {
+	start := time.Now()
+	query := "SELECT COALESCE(SUM(bytes), 0) FROM egress_usage WHERE user_id = $1 AND month = date_trunc('month', NOW() AT TIME ZONE 'UTC')::DATE"
+
+	var bytes int64
+	err := r.db.QueryRow(ctx, query, userID).Scan(&bytes)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("EgressUsageRepository.ThisMonth: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("EgressUsageRepository.ThisMonth: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return bytes, nil
+}
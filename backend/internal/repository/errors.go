@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of repository failure. Every error a repository returns for a missing row, a
+// clash with existing data or a denied ownership check matches one of these with
+// errors.Is, so handlers can choose 404, 409 or 403 without knowing the query;
+// anything else is an internal failure. Specific sentinels (ErrFileNotFound, ...)
+// match their kind too.
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrConflict  = errors.New("conflict")
+	ErrForbidden = errors.New("forbidden")
+)
+
+// kindError is a specific sentinel that also matches its kind.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+func notFoundError(msg string) error { return &kindError{msg: msg, kind: ErrNotFound} }
+func conflictError(msg string) error { return &kindError{msg: msg, kind: ErrConflict} }
+
+// classify tags a database error with its kind: no rows is ErrNotFound, unique and
+// exclusion violations are ErrConflict, and foreign key violations are ErrNotFound
+// (the referenced row is gone). The original error stays wrapped.
+func classify(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505", "23P01": // unique_violation, exclusion_violation
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case "23503": // foreign_key_violation
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// FileAccess identifies which counter an access increments.
+type FileAccess string
+
+const (
+	AccessOwnerDownload FileAccess = "owner_downloads"
+	AccessOwnerPreview  FileAccess = "owner_previews"
+	AccessShareDownload FileAccess = "share_downloads"
+	AccessSharePreview  FileAccess = "share_previews"
+)
+
+type FileStatsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFileStatsRepository(db *pgxpool.Pool) *FileStatsRepository {
+	return &FileStatsRepository{db: db}
+}
+
+// RecordAccess increments the counter for kind and bumps last_accessed_at.
+func (r *FileStatsRepository) RecordAccess(ctx context.Context, fileID int64, kind FileAccess) error {
+	switch kind {
+	case AccessOwnerDownload, AccessOwnerPreview, AccessShareDownload, AccessSharePreview:
+	default:
+		return fmt.Errorf("FileStatsRepository.RecordAccess: unknown access kind %q", kind)
+	}
+
+	start := time.Now()
+	// kind is one of the constants above, so interpolating the column name is safe.
+	query := fmt.Sprintf(
+		`INSERT INTO file_stats (file_id, %[1]s, last_accessed_at) VALUES ($1, 1, NOW())
+		 ON CONFLICT (file_id) DO UPDATE SET %[1]s = file_stats.%[1]s + 1, last_accessed_at = NOW()`, kind)
+
+	result, err := r.db.Exec(ctx, query, fileID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPSERT_ERR", Details: fmt.Sprintf("FileStatsRepository.RecordAccess: %s", err.Error()),
+		})
+		return fmt.Errorf("FileStatsRepository.RecordAccess: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// FindByFileID returns the counters for a file. Files never accessed get zeroed stats.
+func (r *FileStatsRepository) FindByFileID(ctx context.Context, fileID int64) (*model.FileStats, error) {
+	start := time.Now()
+	query := "SELECT file_id, owner_downloads, owner_previews, share_downloads, share_previews, last_accessed_at FROM file_stats WHERE file_id = $1"
+
+	stats := &model.FileStats{}
+	err := r.db.QueryRow(ctx, query, fileID).Scan(&stats.FileID, &stats.OwnerDownloads, &stats.OwnerPreviews, &stats.ShareDownloads, &stats.SharePreviews, &stats.LastAccessedAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return &model.FileStats{FileID: fileID}, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileStatsRepository.FindByFileID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileStatsRepository.FindByFileID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return stats, nil
+}
@@ -2,14 +2,19 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/naratel/naratel-box/backend/internal/logger"
 	"github.com/naratel/naratel-box/backend/internal/model"
 )
 
+const fileColumns = "id, user_id, folder_id, name, mime_type, total_size, block_size, created_at, updated_at, deleted_at, trashed_from_folder_id, modified_by, modified_client, expires_at, taken_down_at, scan_status"
+
 type FileRepository struct {
 	db *pgxpool.Pool
 }
@@ -18,30 +23,108 @@ func NewFileRepository(db *pgxpool.Pool) *FileRepository {
 	return &FileRepository{db: db}
 }
 
-// Create inserts a new file record and returns it.
-func (r *FileRepository) Create(ctx context.Context, userID int64, name, mimeType string, totalSize int64, folderID *int64) (*model.File, error) {
+// scanFile scans fileColumns; extra receives any columns selected after them.
+func scanFile(row pgx.Row, extra ...interface{}) (*model.File, error) {
+	f := &model.File{}
+	dest := []interface{}{&f.ID, &f.UserID, &f.FolderID, &f.Name, &f.MimeType, &f.TotalSize, &f.BlockSize, &f.CreatedAt, &f.UpdatedAt, &f.DeletedAt, &f.TrashedFromFolderID, &f.ModifiedBy, &f.ModifiedClient, &f.ExpiresAt, &f.TakenDownAt, &f.ScanStatus}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// fileSharedSQL selects whether a file row has at least one enabled, unexpired share link.
+const fileSharedSQL = `EXISTS (
+	SELECT 1 FROM share_links s
+	WHERE s.file_id = files.id AND s.enabled AND (s.expires_at IS NULL OR s.expires_at > NOW())
+) AS shared`
+
+// fileDigestJoin makes d.content_hash and d.block_count available for each file row:
+// the SHA-256 over its block hashes in order (as model.BlocksETag computes it)
+// and the number of blocks.
+const fileDigestJoin = `LEFT JOIN LATERAL (
+	SELECT encode(sha256(convert_to(COALESCE(string_agg(b.sha256_hash || E'\n', '' ORDER BY fb.block_index), ''), 'UTF8')), 'hex') AS content_hash,
+	       COUNT(*) AS block_count
+	FROM file_blocks fb JOIN blocks b ON b.id = fb.block_id
+	WHERE fb.file_id = files.id
+) d ON TRUE`
+
+// scanFileEntry scans fileColumns followed by shared, d.content_hash and d.block_count.
+func scanFileEntry(row pgx.Row) (*model.FileEntry, error) {
+	e := &model.FileEntry{}
+	var err error
+	if e.File, err = scanFile(row, &e.Shared, &e.ContentHash, &e.BlockCount); err != nil {
+		return nil, err
+	}
+	e.ETag = model.ContentETag(e.ContentHash)
+	return e, nil
+}
+
+// ErrFileExists is returned by an exclusive CreateWithBlocks when the target folder
+// already holds a live file with the same name.
+var ErrFileExists = conflictError("a file with this name already exists")
+
+// CreateWithBlocks inserts a new file record, adding its size to the owner's used_bytes,
+// and links the ordered block IDs to it in the same transaction, so a failure never
+// leaves a file without content. blockSize is the block size the content was split
+// with; client identifies the uploading client for modified_client. With exclusive,
+// the file is only created if the folder has no live file of that name (ErrFileExists
+// otherwise); concurrent exclusive creates of one name are serialized by a lock.
+func (r *FileRepository) CreateWithBlocks(ctx context.Context, userID int64, name, mimeType string, totalSize int64, blockSize int, folderID *int64, client string, blockIDs []int64, exclusive bool) (*model.File, error) {
 	start := time.Now()
-	query := "INSERT INTO files (user_id, name, mime_type, total_size, folder_id) VALUES ($1, $2, $3, $4, $5) RETURNING ..."
+	query := "[exclusive: SELECT pg_advisory_xact_lock(...); SELECT EXISTS (same name in folder)]; WITH f AS (INSERT INTO files ... RETURNING ...), u AS (UPDATE users SET used_bytes = used_bytes + $4 ...) SELECT ... FROM f; INSERT INTO file_blocks (file_id, block_id, block_index) SELECT $1, id, n - 1 FROM unnest($2::bigint[]) WITH ORDINALITY"
 
-	file := &model.File{}
-	err := r.db.QueryRow(ctx,
-		`INSERT INTO files (user_id, name, mime_type, total_size, folder_id)
-		 VALUES ($1, $2, $3, $4, $5)
-		 RETURNING id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at`,
-		userID, name, mimeType, totalSize, folderID,
-	).Scan(&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.MimeType, &file.TotalSize, &file.CreatedAt, &file.UpdatedAt)
+	var file *model.File
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if exclusive {
+			if err := lockFileName(ctx, tx, userID, folderID, name); err != nil {
+				return err
+			}
+			var exists bool
+			if err := tx.QueryRow(ctx,
+				`SELECT EXISTS (
+					SELECT 1 FROM files
+					WHERE user_id = $1 AND (($2::bigint IS NULL AND folder_id IS NULL) OR folder_id = $2) AND name = $3 AND deleted_at IS NULL
+				)`,
+				userID, folderID, name,
+			).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return ErrFileExists
+			}
+		}
+
+		var err error
+		file, err = scanFile(tx.QueryRow(ctx, createFileSQL,
+			userID, name, mimeType, totalSize, blockSize, folderID, client,
+		))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO file_blocks (file_id, block_id, block_index)
+			 SELECT $1, b.id, b.n - 1 FROM unnest($2::bigint[]) WITH ORDINALITY AS b(id, n)`,
+			file.ID, blockIDs,
+		)
+		return err
+	})
 
 	duration := time.Since(start).Milliseconds()
 
+	if errors.Is(err, ErrFileExists) {
+		return nil, err
+	}
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FileRepository.Create: %s", err.Error()),
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FileRepository.CreateWithBlocks: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.Create: %w", err)
+		return nil, fmt.Errorf("FileRepository.CreateWithBlocks: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
-		Query: query, DurationMs: duration, RowsAffected: 1,
+		Query: query, DurationMs: duration, RowsAffected: int64(1 + len(blockIDs)),
 	})
 	return file, nil
 }
@@ -49,11 +132,9 @@ func (r *FileRepository) Create(ctx context.Context, userID int64, name, mimeTyp
 // FindByIDAndUserID fetches a file only if it belongs to the given user (ownership check).
 func (r *FileRepository) FindByIDAndUserID(ctx context.Context, fileID, userID int64) (*model.File, error) {
 	start := time.Now()
-	query := "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE id = $1 AND user_id = $2"
+	query := "SELECT " + fileColumns + " FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL"
 
-	file := &model.File{}
-	err := r.db.QueryRow(ctx, query, fileID, userID,
-	).Scan(&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.MimeType, &file.TotalSize, &file.CreatedAt, &file.UpdatedAt)
+	file, err := scanFile(r.db.QueryRow(ctx, query, fileID, userID))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -61,7 +142,7 @@ func (r *FileRepository) FindByIDAndUserID(ctx context.Context, fileID, userID i
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.FindByIDAndUserID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.FindByIDAndUserID: %w", err)
+		return nil, fmt.Errorf("FileRepository.FindByIDAndUserID: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -70,14 +151,46 @@ func (r *FileRepository) FindByIDAndUserID(ctx context.Context, fileID, userID i
 	return file, nil
 }
 
-// FindByID fetches a file by ID regardless of ownership (for share links).
-func (r *FileRepository) FindByID(ctx context.Context, fileID int64) (*model.File, error) {
+// FindByName fetches the user's live file called name in folderID (nil = root). Names
+// are not unique, so the oldest match wins. Returns nil, nil if there is none.
+func (r *FileRepository) FindByName(ctx context.Context, userID int64, folderID *int64, name string) (*model.File, error) {
 	start := time.Now()
-	query := "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE id = $1"
+	query := "SELECT " + fileColumns + " FROM files WHERE user_id = $1 AND (($2::bigint IS NULL AND folder_id IS NULL) OR folder_id = $2) AND name = $3 AND deleted_at IS NULL ORDER BY id LIMIT 1"
 
-	file := &model.File{}
-	err := r.db.QueryRow(ctx, query, fileID,
-	).Scan(&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.MimeType, &file.TotalSize, &file.CreatedAt, &file.UpdatedAt)
+	file, err := scanFile(r.db.QueryRow(ctx, query, userID, folderID, name))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.FindByName: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.FindByName: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return file, nil
+}
+
+// FindByID fetches a file by ID within scope; share links use the link owner's scope.
+func (r *FileRepository) FindByID(ctx context.Context, scope Scope, fileID int64) (*model.File, error) {
+	ownerID, err := scope.owner("FileRepository.FindByID")
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := "SELECT " + fileColumns + " FROM files WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND deleted_at IS NULL"
+
+	file, err := scanFile(r.db.QueryRow(ctx, query, fileID, ownerID))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -85,7 +198,7 @@ func (r *FileRepository) FindByID(ctx context.Context, fileID int64) (*model.Fil
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.FindByID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.FindByID: %w", err)
+		return nil, fmt.Errorf("FileRepository.FindByID: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -97,21 +210,21 @@ func (r *FileRepository) FindByID(ctx context.Context, fileID int64) (*model.Fil
 // ListByUserID returns all files for a user ordered by newest first.
 func (r *FileRepository) ListByUserID(ctx context.Context, userID int64) ([]*model.File, error) {
 	start := time.Now()
-	query := "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE user_id = $1 ORDER BY created_at DESC"
+	query := "SELECT " + fileColumns + " FROM files WHERE user_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC"
 
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListByUserID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.ListByUserID: %w", err)
+		return nil, fmt.Errorf("FileRepository.ListByUserID: %w", classify(err))
 	}
 	defer rows.Close()
 
 	var files []*model.File
 	for rows.Next() {
-		f := &model.File{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.Name, &f.MimeType, &f.TotalSize, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		f, err := scanFile(rows)
+		if err != nil {
 			return nil, err
 		}
 		files = append(files, f)
@@ -124,45 +237,28 @@ func (r *FileRepository) ListByUserID(ctx context.Context, userID int64) ([]*mod
 	return files, nil
 }
 
-// ListByFolder returns files in a specific folder (or root if folderID is nil).
-func (r *FileRepository) ListByFolder(ctx context.Context, userID int64, folderID *int64) ([]*model.File, error) {
+// ListByFolder returns files in a specific folder (or root if folderID is nil) with
+// their share state and content digest.
+func (r *FileRepository) ListByFolder(ctx context.Context, userID int64, folderID *int64) ([]*model.FileEntry, error) {
 	start := time.Now()
-	var query string
-	var rows interface{ Next() bool; Scan(dest ...interface{}) error; Close() }
-	var err error
+	query := "SELECT " + fileColumns + ", " + fileSharedSQL + ", d.content_hash, d.block_count FROM files " + fileDigestJoin + " WHERE user_id = $1 AND (($2::bigint IS NULL AND folder_id IS NULL) OR folder_id = $2) AND deleted_at IS NULL ORDER BY name COLLATE name_ci ASC"
 
-	if folderID == nil {
-		query = "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE user_id = $1 AND folder_id IS NULL ORDER BY name ASC"
-		rows2, err2 := r.db.Query(ctx, query, userID)
-		if err2 != nil {
-			logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-				Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListByFolder: %s", err2.Error()),
-			})
-			return nil, fmt.Errorf("FileRepository.ListByFolder: %w", err2)
-		}
-		rows = rows2
-		defer rows2.Close()
-	} else {
-		query = "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE user_id = $1 AND folder_id = $2 ORDER BY name ASC"
-		rows2, err2 := r.db.Query(ctx, query, userID, *folderID)
-		if err2 != nil {
-			logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-				Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListByFolder: %s", err2.Error()),
-			})
-			return nil, fmt.Errorf("FileRepository.ListByFolder: %w", err2)
-		}
-		rows = rows2
-		defer rows2.Close()
+	rows, err := r.db.Query(ctx, query, userID, folderID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListByFolder: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.ListByFolder: %w", classify(err))
 	}
-	_ = err
+	defer rows.Close()
 
-	var files []*model.File
+	var files []*model.FileEntry
 	for rows.Next() {
-		f := &model.File{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.Name, &f.MimeType, &f.TotalSize, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		e, err := scanFileEntry(rows)
+		if err != nil {
 			return nil, err
 		}
-		files = append(files, f)
+		files = append(files, e)
 	}
 
 	duration := time.Since(start).Milliseconds()
@@ -172,27 +268,102 @@ func (r *FileRepository) ListByFolder(ctx context.Context, userID int64, folderI
 	return files, nil
 }
 
-// Search searches files by name for a given user.
-func (r *FileRepository) Search(ctx context.Context, userID int64, query string) ([]*model.File, error) {
+// galleryMediaSQL matches the images and videos a gallery shows. fileBaseNameSQL is a
+// file name without its extension, lower-cased, for pairing videos with posters.
+const (
+	galleryMediaSQL = "(mime_type LIKE 'image/%' OR mime_type LIKE 'video/%')"
+	fileBaseNameSQL = "lower(regexp_replace(%s.name, '\\.[^.]*$', ''))"
+)
+
+// ListShared returns one page of the live files directly inside folderID for a folder
+// share link. With gallery only images and videos are listed, and an image named like
+// a video in the folder (clip.jpg next to clip.mp4) is returned as that video's
+// PosterID instead of on its own. The returned cursor is nil on the last page.
+func (r *FileRepository) ListShared(ctx context.Context, userID, folderID int64, opts ContentsOptions, gallery bool) ([]*model.SharedFile, *FileCursor, error) {
+	start := time.Now()
+	query := "SELECT " + fileColumns + ", poster.poster_id FROM files LEFT JOIN LATERAL (poster image) poster WHERE user_id = $1 AND folder_id = $2 AND live [AND media AND NOT poster] AND <keyset> ORDER BY <sort>, id LIMIT $5"
+
+	seek, order, after, afterID, limit := opts.keyset()
+	base := func(table string) string { return fmt.Sprintf(fileBaseNameSQL, table) }
+	filter := "TRUE"
+	if gallery {
+		filter = galleryMediaSQL + ` AND NOT (files.mime_type LIKE 'image/%' AND EXISTS (
+			SELECT 1 FROM files v
+			WHERE v.folder_id = files.folder_id AND v.deleted_at IS NULL AND v.mime_type LIKE 'video/%'
+			  AND ` + base("v") + ` = ` + base("files") + `))`
+	}
+
+	rows, err := r.db.Query(ctx,
+		`SELECT `+fileColumns+`, poster.poster_id
+		 FROM files
+		 LEFT JOIN LATERAL (
+			SELECT p.id AS poster_id FROM files p
+			WHERE $6 AND files.mime_type LIKE 'video/%' AND p.folder_id = files.folder_id AND p.deleted_at IS NULL
+			  AND p.taken_down_at IS NULL AND p.mime_type LIKE 'image/%' AND `+base("p")+` = `+base("files")+`
+			ORDER BY p.id LIMIT 1
+		 ) poster ON TRUE
+		 WHERE user_id = $1 AND folder_id = $2 AND deleted_at IS NULL AND taken_down_at IS NULL
+		   AND `+filter+`
+		   AND `+seek+`
+		 ORDER BY `+order+`
+		 LIMIT $5`,
+		userID, folderID, after, afterID, limit, gallery)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListShared: %s", err.Error()),
+		})
+		return nil, nil, fmt.Errorf("FileRepository.ListShared: %w", classify(err))
+	}
+	defer rows.Close()
+
+	files := []*model.SharedFile{}
+	for rows.Next() {
+		f := &model.SharedFile{}
+		if f.File, err = scanFile(rows, &f.PosterID); err != nil {
+			return nil, nil, err
+		}
+		files = append(files, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("FileRepository.ListShared: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(files)),
+	})
+
+	var next *FileCursor
+	if opts.Limit > 0 && len(files) > opts.Limit {
+		files = files[:opts.Limit]
+		last := files[opts.Limit-1]
+		next = &FileCursor{Name: last.Name, CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	return files, next, nil
+}
+
+// Search searches files by name for a given user, with their share state and content
+// digest.
+func (r *FileRepository) Search(ctx context.Context, userID int64, query string) ([]*model.FileEntry, error) {
 	start := time.Now()
-	sqlQuery := "SELECT id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at FROM files WHERE user_id = $1 AND LOWER(name) LIKE '%' || LOWER($2) || '%' ORDER BY name ASC LIMIT 50"
+	sqlQuery := "SELECT " + fileColumns + ", " + fileSharedSQL + ", d.content_hash, d.block_count FROM files " + fileDigestJoin + " WHERE user_id = $1 AND deleted_at IS NULL AND LOWER(name) LIKE '%' || LOWER($2) || '%' ORDER BY name COLLATE name_ci ASC LIMIT 50"
 
 	rows, err := r.db.Query(ctx, sqlQuery, userID, query)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.Search: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.Search: %w", err)
+		return nil, fmt.Errorf("FileRepository.Search: %w", classify(err))
 	}
 	defer rows.Close()
 
-	var files []*model.File
+	var files []*model.FileEntry
 	for rows.Next() {
-		f := &model.File{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.FolderID, &f.Name, &f.MimeType, &f.TotalSize, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		e, err := scanFileEntry(rows)
+		if err != nil {
 			return nil, err
 		}
-		files = append(files, f)
+		files = append(files, e)
 	}
 
 	duration := time.Since(start).Milliseconds()
@@ -203,17 +374,16 @@ func (r *FileRepository) Search(ctx context.Context, userID int64, query string)
 }
 
 // Rename updates the name of a file.
-func (r *FileRepository) Rename(ctx context.Context, fileID, userID int64, newName string) (*model.File, error) {
+func (r *FileRepository) Rename(ctx context.Context, fileID, userID int64, newName, client string) (*model.File, error) {
 	start := time.Now()
-	query := "UPDATE files SET name = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3 RETURNING ..."
+	query := "UPDATE files SET name = $1, updated_at = NOW(), modified_by = $3, modified_client = $4 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL RETURNING ..."
 
-	file := &model.File{}
-	err := r.db.QueryRow(ctx,
-		`UPDATE files SET name = $1, updated_at = NOW()
-		 WHERE id = $2 AND user_id = $3
-		 RETURNING id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at`,
-		newName, fileID, userID,
-	).Scan(&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.MimeType, &file.TotalSize, &file.CreatedAt, &file.UpdatedAt)
+	file, err := scanFile(r.db.QueryRow(ctx,
+		`UPDATE files SET name = $1, updated_at = NOW(), modified_by = $3, modified_client = NULLIF($4, '')
+		 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		 RETURNING `+fileColumns,
+		newName, fileID, userID, client,
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -221,7 +391,7 @@ func (r *FileRepository) Rename(ctx context.Context, fileID, userID int64, newNa
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.Rename: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.Rename: %w", err)
+		return nil, fmt.Errorf("FileRepository.Rename: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -230,18 +400,121 @@ func (r *FileRepository) Rename(ctx context.Context, fileID, userID int64, newNa
 	return file, nil
 }
 
+// lockFileName serializes transactions that claim or replace the name of a file in one
+// folder, until the calling transaction ends. Names are not unique in the schema, so
+// this is what makes check-then-write on a name safe.
+func lockFileName(ctx context.Context, tx pgx.Tx, userID int64, folderID *int64, name string) error {
+	_, err := tx.Exec(ctx,
+		"SELECT pg_advisory_xact_lock(hashtextextended(format('file-name:%s:%s:%s', $1::bigint, $2::bigint, $3::text), 0))",
+		userID, folderID, name,
+	)
+	return err
+}
+
+// FilePlacement is where Replace puts a file. An empty Name keeps the file's name;
+// FolderID (nil = root) only applies when Move is set.
+type FilePlacement struct {
+	Name     string
+	FolderID *int64
+	Move     bool
+}
+
+// Replace renames and/or moves a file like Rename and Move, moving any other live file
+// already at the destination to the trash in the same transaction, so the name never
+// points at nothing or at two files. Returns the placed file and the IDs of the files
+// it replaced.
+func (r *FileRepository) Replace(ctx context.Context, fileID, userID int64, to FilePlacement, client string) (*model.File, []int64, error) {
+	start := time.Now()
+	query := "SELECT folder_id, name FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE; SELECT pg_advisory_xact_lock(...); UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id, ... WHERE <same folder and name> AND id <> $1 RETURNING id; UPDATE files SET name = $3, folder_id = $4, ... WHERE id = $1 RETURNING ..."
+
+	var (
+		file     *model.File
+		replaced []int64
+	)
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var (
+			folderID *int64
+			name     string
+		)
+		if err := tx.QueryRow(ctx,
+			"SELECT folder_id, name FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE",
+			fileID, userID,
+		).Scan(&folderID, &name); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrFileNotFound
+			}
+			return err
+		}
+		if to.Name != "" {
+			name = to.Name
+		}
+		if to.Move {
+			folderID = to.FolderID
+		}
+
+		if err := lockFileName(ctx, tx, userID, folderID, name); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx,
+			`UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id, modified_by = $1, modified_client = NULLIF($5, '')
+			 WHERE user_id = $1 AND (($2::bigint IS NULL AND folder_id IS NULL) OR folder_id = $2) AND name = $3 AND id <> $4 AND deleted_at IS NULL
+			 RETURNING id`,
+			userID, folderID, name, fileID, client,
+		)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			replaced = append(replaced, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		file, err = scanFile(tx.QueryRow(ctx,
+			`UPDATE files SET name = $3, folder_id = $4, updated_at = NOW(), modified_by = $2, modified_client = NULLIF($5, '')
+			 WHERE id = $1 AND user_id = $2
+			 RETURNING `+fileColumns,
+			fileID, userID, name, folderID, client,
+		))
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, ErrFileNotFound) {
+		return nil, nil, err
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.Replace: %s", err.Error()),
+		})
+		return nil, nil, fmt.Errorf("FileRepository.Replace: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(1 + len(replaced)),
+	})
+	return file, replaced, nil
+}
+
 // Move updates the folder_id of a file.
-func (r *FileRepository) Move(ctx context.Context, fileID, userID int64, folderID *int64) (*model.File, error) {
+func (r *FileRepository) Move(ctx context.Context, fileID, userID int64, folderID *int64, client string) (*model.File, error) {
 	start := time.Now()
-	query := "UPDATE files SET folder_id = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3 RETURNING ..."
+	query := "UPDATE files SET folder_id = $1, updated_at = NOW(), modified_by = $3, modified_client = $4 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL RETURNING ..."
 
-	file := &model.File{}
-	err := r.db.QueryRow(ctx,
-		`UPDATE files SET folder_id = $1, updated_at = NOW()
-		 WHERE id = $2 AND user_id = $3
-		 RETURNING id, user_id, folder_id, name, mime_type, total_size, created_at, updated_at`,
-		folderID, fileID, userID,
-	).Scan(&file.ID, &file.UserID, &file.FolderID, &file.Name, &file.MimeType, &file.TotalSize, &file.CreatedAt, &file.UpdatedAt)
+	file, err := scanFile(r.db.QueryRow(ctx,
+		`UPDATE files SET folder_id = $1, updated_at = NOW(), modified_by = $3, modified_client = NULLIF($4, '')
+		 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+		 RETURNING `+fileColumns,
+		folderID, fileID, userID, client,
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -249,7 +522,7 @@ func (r *FileRepository) Move(ctx context.Context, fileID, userID int64, folderI
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.Move: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.Move: %w", err)
+		return nil, fmt.Errorf("FileRepository.Move: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -258,26 +531,31 @@ func (r *FileRepository) Move(ctx context.Context, fileID, userID int64, folderI
 	return file, nil
 }
 
-// Delete removes a file record. Call only after decrementing block ref_counts.
-func (r *FileRepository) Delete(ctx context.Context, fileID, userID int64) error {
+// ErrFileNotFound is returned when a file does not exist or is not owned by the user.
+var ErrFileNotFound = notFoundError("file not found or unauthorized")
+
+// Trash moves a file to the trash. Its file_blocks stay in place, so the blocks keep
+// their references and a restore never finds content garbage collected; they are only
+// released when the file is purged.
+func (r *FileRepository) Trash(ctx context.Context, fileID, userID int64, client string) error {
 	start := time.Now()
-	query := "DELETE FROM files WHERE id = $1 AND user_id = $2"
+	query := "UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id, modified_by = $2, modified_client = NULLIF($3, '') WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL"
 
-	result, err := r.db.Exec(ctx, query, fileID, userID)
+	result, err := r.db.Exec(ctx, query, fileID, userID, client)
 
 	duration := time.Since(start).Milliseconds()
 
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("FileRepository.Delete: %s", err.Error()),
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.Trash: %s", err.Error()),
 		})
-		return fmt.Errorf("FileRepository.Delete: %w", err)
+		return fmt.Errorf("FileRepository.Trash: %w", classify(err))
 	}
 	if result.RowsAffected() == 0 {
-		logger.Warn(ctx, "Delete affected 0 rows", map[string]interface{}{
+		logger.Warn(ctx, "Trash affected 0 rows", map[string]interface{}{
 			"file_id": fileID, "user_id": userID,
 		})
-		return fmt.Errorf("file not found or unauthorized")
+		return ErrFileNotFound
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -286,39 +564,448 @@ func (r *FileRepository) Delete(ctx context.Context, fileID, userID int64) error
 	return nil
 }
 
-// LinkBlocks inserts file_blocks rows linking ordered block IDs to a file.
-func (r *FileRepository) LinkBlocks(ctx context.Context, fileID int64, blockIDs []int64) error {
+// CountInFolders counts a user's live files in any of the given folders.
+func (r *FileRepository) CountInFolders(ctx context.Context, userID int64, folderIDs []int64) (int64, error) {
 	start := time.Now()
-	query := "INSERT INTO file_blocks (file_id, block_id, block_index) VALUES ($1, $2, $3)"
+	query := "SELECT COUNT(*) FROM files WHERE user_id = $1 AND folder_id = ANY($2) AND deleted_at IS NULL"
 
-	for i, blockID := range blockIDs {
-		_, err := r.db.Exec(ctx, query, fileID, blockID, i)
+	var n int64
+	err := r.db.QueryRow(ctx, query, userID, folderIDs).Scan(&n)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.CountInFolders: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FileRepository.CountInFolders: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return n, nil
+}
+
+// TrashInFolders moves up to limit of a user's live files in the given folders to the
+// trash and returns how many were moved. Call repeatedly until it returns less than limit.
+func (r *FileRepository) TrashInFolders(ctx context.Context, userID int64, folderIDs []int64, limit int, client string) (int64, error) {
+	start := time.Now()
+	query := "UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id, modified_by = $1, modified_client = NULLIF($4, '') WHERE id IN (SELECT id FROM files WHERE user_id = $1 AND folder_id = ANY($2) AND deleted_at IS NULL LIMIT $3)"
+
+	result, err := r.db.Exec(ctx, query, userID, folderIDs, limit, client)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.TrashInFolders: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FileRepository.TrashInFolders: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// SetExpiry schedules a live file to be moved to the trash at expiresAt (nil clears it).
+// Changing the date re-arms the advance notice.
+func (r *FileRepository) SetExpiry(ctx context.Context, fileID, userID int64, expiresAt *time.Time) (*model.File, error) {
+	start := time.Now()
+	query := "UPDATE files SET expires_at = $3, expiry_notified_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL RETURNING ..."
+
+	file, err := scanFile(r.db.QueryRow(ctx,
+		`UPDATE files SET expires_at = $3, expiry_notified_at = NULL
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+		 RETURNING `+fileColumns,
+		fileID, userID, expiresAt,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFileNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.SetExpiry: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.SetExpiry: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return file, nil
+}
+
+// SetScanStatus records a malware scan verdict for any live file.
+func (r *FileRepository) SetScanStatus(ctx context.Context, fileID int64, status string) (*model.File, error) {
+	start := time.Now()
+	query := "UPDATE files SET scan_status = $2, scanned_at = CASE WHEN $2 = 'pending' THEN NULL ELSE NOW() END WHERE id = $1 AND deleted_at IS NULL RETURNING ..."
+
+	file, err := scanFile(r.db.QueryRow(ctx,
+		`UPDATE files SET scan_status = $2, scanned_at = CASE WHEN $2 = 'pending' THEN NULL ELSE NOW() END
+		 WHERE id = $1 AND deleted_at IS NULL
+		 RETURNING `+fileColumns,
+		fileID, status,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFileNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.SetScanStatus: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.SetScanStatus: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return file, nil
+}
+
+// NotifyExpiring warns the owners of up to limit live files expiring before noticeBefore
+// (and not yet warned), marking them notified in the same statement. Returns how many
+// notifications were created.
+func (r *FileRepository) NotifyExpiring(ctx context.Context, noticeBefore time.Time, limit int) (int64, error) {
+	start := time.Now()
+	query := "WITH due AS (SELECT ... WHERE expires_at <= $3 AND expires_at > NOW() AND expiry_notified_at IS NULL AND deleted_at IS NULL LIMIT $4 FOR UPDATE SKIP LOCKED), notices AS (UPDATE files SET expiry_notified_at = NOW() ...) INSERT INTO notifications ..."
+
+	result, err := r.db.Exec(ctx,
+		`WITH due AS (
+			SELECT id AS due_id FROM files
+			WHERE expires_at <= $3 AND expires_at > NOW() AND expiry_notified_at IS NULL AND deleted_at IS NULL
+			ORDER BY expires_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		 ), notices AS (
+			UPDATE files SET expiry_notified_at = NOW()
+			FROM due WHERE id = due_id
+			RETURNING id, user_id, name, expires_at AS due_at
+		 ) `+expiryNoticeInsert,
+		model.NotificationExpiryWarning, "file", noticeBefore, limit)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.NotifyExpiring: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FileRepository.NotifyExpiring: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// TrashExpired moves up to limit live files whose expiry has passed to the trash and
+// notifies their owners. The expiry is cleared, so a restored file stays restored.
+// Returns how many files were trashed; call repeatedly until it returns less than limit.
+func (r *FileRepository) TrashExpired(ctx context.Context, limit int, client string) (int64, error) {
+	start := time.Now()
+	query := "WITH due AS (SELECT ... WHERE expires_at <= NOW() AND deleted_at IS NULL LIMIT $3 FOR UPDATE SKIP LOCKED), notices AS (UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id, expires_at = NULL, ... RETURNING ...) INSERT INTO notifications ..."
+
+	result, err := r.db.Exec(ctx,
+		`WITH due AS (
+			SELECT id AS due_id, expires_at AS due_at FROM files
+			WHERE expires_at <= NOW() AND deleted_at IS NULL
+			ORDER BY expires_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		 ), notices AS (
+			UPDATE files SET deleted_at = NOW(), trashed_from_folder_id = folder_id,
+			                 expires_at = NULL, expiry_notified_at = NULL,
+			                 modified_by = NULL, modified_client = NULLIF($4, '')
+			FROM due WHERE id = due_id
+			RETURNING id, user_id, name, due_at
+		 ) `+expiryNoticeInsert,
+		model.NotificationExpired, "file", limit, client)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.TrashExpired: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FileRepository.TrashExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// Restore takes a file out of the trash. Files whose folder was deleted meanwhile
+// come back at the root level (folder_id is SET NULL by the FK).
+func (r *FileRepository) Restore(ctx context.Context, fileID, userID int64, client string) (*model.File, error) {
+	start := time.Now()
+	query := "UPDATE files SET deleted_at = NULL, trashed_from_folder_id = NULL, updated_at = NOW(), modified_by = $2, modified_client = $3 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL RETURNING ..."
+
+	file, err := scanFile(r.db.QueryRow(ctx,
+		`UPDATE files SET deleted_at = NULL, trashed_from_folder_id = NULL, updated_at = NOW(),
+		        modified_by = $2, modified_client = NULLIF($3, '')
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		 RETURNING `+fileColumns,
+		fileID, userID, client,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrFileNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.Restore: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.Restore: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return file, nil
+}
+
+// ListTrash returns a user's trashed files, most recently deleted first. A non-nil
+// folderID limits the list to files trashed from that folder (which may since have
+// been deleted itself).
+func (r *FileRepository) ListTrash(ctx context.Context, userID int64, folderID *int64) ([]*model.File, error) {
+	start := time.Now()
+	query := "SELECT " + fileColumns + " FROM files WHERE user_id = $1 AND deleted_at IS NOT NULL AND ($2::bigint IS NULL OR trashed_from_folder_id = $2) ORDER BY deleted_at DESC"
+
+	rows, err := r.db.Query(ctx, query, userID, folderID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.ListTrash: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.ListTrash: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var files []*model.File
+	for rows.Next() {
+		f, err := scanFile(rows)
 		if err != nil {
-			logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-				Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FileRepository.LinkBlocks at index %d: %s", i, err.Error()),
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(files)),
+	})
+	return files, nil
+}
+
+// Purge permanently deletes a trashed file and releases its block references in a single
+// transaction. Returns the number of blocks released.
+func (r *FileRepository) Purge(ctx context.Context, fileID, userID int64) (int64, error) {
+	start := time.Now()
+	query := "SELECT id FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL FOR UPDATE; UPDATE blocks SET ref_count = ref_count - n FROM (file's block usage) ...; DELETE FROM files WHERE id = $1"
+
+	var released int64
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		// Lock the file row first so two concurrent purges cannot both release its blocks.
+		var id int64
+		if err := tx.QueryRow(ctx,
+			"SELECT id FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL FOR UPDATE", fileID, userID,
+		).Scan(&id); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrFileNotFound
+			}
+			return err
+		}
+
+		var err error
+		released, err = purgeLocked(ctx, tx, fileID)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, ErrFileNotFound) {
+			logger.Warn(ctx, "Purge affected 0 rows", map[string]interface{}{
+				"file_id": fileID, "user_id": userID,
 			})
-			return fmt.Errorf("FileRepository.LinkBlocks at index %d: %w", i, err)
+			return 0, err
 		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("FileRepository.Purge: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FileRepository.Purge: %w", classify(err))
 	}
 
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: released,
+	})
+	return released, nil
+}
+
+// PurgeTrashedBefore permanently deletes up to limit files trashed before cutoff, skipping
+// rows locked by a concurrent restore or purge. Returns files purged and blocks released.
+func (r *FileRepository) PurgeTrashedBefore(ctx context.Context, cutoff time.Time, limit int) (int64, int64, error) {
+	return r.purgeTrashBatch(ctx, "FileRepository.PurgeTrashedBefore",
+		"SELECT id FROM files WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED", cutoff, limit)
+}
+
+// PreviewPurgeTrashedBefore reports what purging every file trashed before cutoff would
+// remove: the files, and the blocks only they reference, which the purge leaves
+// unreferenced for the block GC.
+func (r *FileRepository) PreviewPurgeTrashedBefore(ctx context.Context, cutoff time.Time) (*model.CleanupPreview, error) {
+	start := time.Now()
+	query := `WITH doomed AS (
+		SELECT id FROM files WHERE deleted_at < $1
+	), refs AS (
+		SELECT fb.block_id, COUNT(*) AS n FROM file_blocks fb JOIN doomed d ON d.id = fb.file_id GROUP BY fb.block_id
+	)
+	SELECT (SELECT COUNT(*) FROM doomed), COUNT(b.id), COALESCE(SUM(b.stored_bytes), 0)
+	FROM refs JOIN blocks b ON b.id = refs.block_id AND b.ref_count <= refs.n`
+
+	p := &model.CleanupPreview{}
+	err := r.db.QueryRow(ctx, query, cutoff).Scan(&p.Items, &p.Blocks, &p.BytesFreed)
+
 	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.PreviewPurgeTrashedBefore: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.PreviewPurgeTrashedBefore: %w", classify(err))
+	}
+
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
-		Query: query, DurationMs: duration, RowsAffected: int64(len(blockIDs)),
+		Query: query, DurationMs: duration, RowsAffected: 1,
 	})
-	return nil
+	return p, nil
+}
+
+// PurgeTrashOfUser permanently deletes up to limit of a user's trashed files, skipping
+// rows locked by a concurrent restore or purge. Returns files purged and blocks released.
+func (r *FileRepository) PurgeTrashOfUser(ctx context.Context, userID int64, limit int) (int64, int64, error) {
+	return r.purgeTrashBatch(ctx, "FileRepository.PurgeTrashOfUser",
+		"SELECT id FROM files WHERE user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED", userID, limit)
+}
+
+// purgeTrashBatch locks the trashed file rows selected by selectQuery and purges them
+// in one transaction.
+func (r *FileRepository) purgeTrashBatch(ctx context.Context, method, selectQuery string, args ...interface{}) (int64, int64, error) {
+	start := time.Now()
+	query := selectQuery + "; UPDATE blocks ...; DELETE FROM files ..."
+
+	var purged, released int64
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery, args...)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			n, err := purgeLocked(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			released += n
+		}
+		purged = int64(len(ids))
+		return nil
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("%s: %s", method, err.Error()),
+		})
+		return 0, 0, fmt.Errorf("%s: %w", method, classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: purged,
+	})
+	return purged, released, nil
+}
+
+// createFileSQL inserts a file and charges its size to the owner's used_bytes in one
+// statement, so the usage counter cannot miss a file. New files wait for a malware
+// scan verdict.
+const createFileSQL = `WITH f AS (
+	INSERT INTO files (user_id, name, mime_type, total_size, block_size, folder_id, modified_by, modified_client, scan_status)
+	VALUES ($1, $2, $3, $4, $5, $6, $1, NULLIF($7, ''), 'pending')
+	RETURNING ` + fileColumns + `
+), u AS (
+	UPDATE users SET used_bytes = used_bytes + $4 WHERE id = $1
+)
+SELECT ` + fileColumns + ` FROM f`
+
+// purgeLocked releases the block references of a file whose row the caller has locked,
+// then deletes it and takes its size off the owner's used_bytes. Each block's ref_count
+// is decremented by the number of times the file used it in one statement; blocks that
+// drop to zero stay in place for the block GC job.
+func purgeLocked(ctx context.Context, tx pgx.Tx, fileID int64) (int64, error) {
+	// Count usage before the cascade removes file_blocks.
+	result, err := tx.Exec(ctx,
+		`UPDATE blocks b SET ref_count = b.ref_count - u.n
+		 FROM (SELECT block_id, COUNT(*) AS n FROM file_blocks WHERE file_id = $1 GROUP BY block_id) u
+		 WHERE b.id = u.block_id`,
+		fileID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(ctx,
+		`WITH f AS (DELETE FROM files WHERE id = $1 RETURNING user_id, total_size)
+		 UPDATE users u SET used_bytes = u.used_bytes - f.total_size FROM f WHERE u.id = f.user_id`,
+		fileID,
+	); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
-// GetBlockIDs returns block IDs for a file ordered by block_index.
-func (r *FileRepository) GetBlockIDs(ctx context.Context, fileID int64) ([]int64, error) {
+// GetBlockIDs returns block IDs for a file within scope ordered by block_index. A file
+// outside the scope has no blocks.
+func (r *FileRepository) GetBlockIDs(ctx context.Context, scope Scope, fileID int64) ([]int64, error) {
+	ownerID, err := scope.owner("FileRepository.GetBlockIDs")
+	if err != nil {
+		return nil, err
+	}
+
 	start := time.Now()
-	query := "SELECT block_id FROM file_blocks WHERE file_id = $1 ORDER BY block_index ASC"
+	query := "SELECT fb.block_id FROM file_blocks fb JOIN files f ON f.id = fb.file_id WHERE fb.file_id = $1 AND ($2 = 0 OR f.user_id = $2) ORDER BY fb.block_index ASC"
 
-	rows, err := r.db.Query(ctx, query, fileID)
+	rows, err := r.db.Query(ctx, query, fileID, ownerID)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.GetBlockIDs: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FileRepository.GetBlockIDs: %w", err)
+		return nil, fmt.Errorf("FileRepository.GetBlockIDs: %w", classify(err))
 	}
 	defer rows.Close()
 
@@ -337,3 +1024,182 @@ func (r *FileRepository) GetBlockIDs(ctx context.Context, fileID int64) ([]int64
 	})
 	return ids, nil
 }
+
+// ErrFileChanged is returned by ReplaceBlocks when the file's content changed since
+// its blocks were read.
+var ErrFileChanged = conflictError("file content changed")
+
+// ReplaceBlocks changes the content of the user's live file: entries [from, to) of its
+// block list, which must still be current, are replaced by blockIDs and the file gets
+// totalSize. The references of the replaced entries are released and the file takes
+// over the caller's references to blockIDs. The file's scan verdict no longer applies
+// to the new content, so it goes back to pending for the scanner to check again. Fails
+// with ErrFileChanged if the block list is no longer current, leaving everything as it
+// was.
+func (r *FileRepository) ReplaceBlocks(ctx context.Context, fileID, userID int64, current []int64, from, to int, blockIDs []int64, totalSize int64, client string) (*model.File, error) {
+	start := time.Now()
+	query := "SELECT total_size FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE; SELECT array_agg(block_id ORDER BY block_index) FROM file_blocks WHERE file_id = $1; UPDATE blocks SET ref_count = ref_count - n ...; UPDATE/DELETE+INSERT file_blocks ...; UPDATE files SET total_size = $3, scan_status = 'pending', ... RETURNING ...; UPDATE users SET used_bytes = used_bytes + <growth> ..."
+
+	var file *model.File
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var oldSize int64
+		if err := tx.QueryRow(ctx,
+			"SELECT total_size FROM files WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE",
+			fileID, userID,
+		).Scan(&oldSize); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrFileNotFound
+			}
+			return err
+		}
+		var ids []int64
+		if err := tx.QueryRow(ctx,
+			"SELECT COALESCE(array_agg(block_id ORDER BY block_index), '{}') FROM file_blocks WHERE file_id = $1",
+			fileID,
+		).Scan(&ids); err != nil {
+			return err
+		}
+		if !slices.Equal(ids, current) {
+			return ErrFileChanged
+		}
+
+		if _, err := tx.Exec(ctx,
+			`UPDATE blocks b SET ref_count = b.ref_count - u.n
+			 FROM (SELECT id, COUNT(*) AS n FROM unnest($1::bigint[]) AS id GROUP BY id) u
+			 WHERE b.id = u.id`,
+			current[from:to],
+		); err != nil {
+			return err
+		}
+		if len(blockIDs) == to-from {
+			if _, err := tx.Exec(ctx,
+				`UPDATE file_blocks fb SET block_id = b.id
+				 FROM unnest($2::bigint[]) WITH ORDINALITY AS b(id, n)
+				 WHERE fb.file_id = $1 AND fb.block_index = $3 + b.n - 1`,
+				fileID, blockIDs, from,
+			); err != nil {
+				return err
+			}
+		} else {
+			// The blocks after the write move to new indexes.
+			if _, err := tx.Exec(ctx, "DELETE FROM file_blocks WHERE file_id = $1 AND block_index >= $2", fileID, from); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO file_blocks (file_id, block_id, block_index)
+				 SELECT $1, b.id, $3 + b.n - 1 FROM unnest($2::bigint[]) WITH ORDINALITY AS b(id, n)`,
+				fileID, append(slices.Clone(blockIDs), current[to:]...), from,
+			); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		file, err = scanFile(tx.QueryRow(ctx,
+			`UPDATE files SET total_size = $3, updated_at = NOW(), modified_by = $2, modified_client = NULLIF($4, ''),
+			     scan_status = 'pending', scanned_at = NULL
+			 WHERE id = $1
+			 RETURNING `+fileColumns,
+			fileID, userID, totalSize, client,
+		))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE users SET used_bytes = used_bytes + $2 WHERE id = $1", userID, totalSize-oldSize)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, ErrFileNotFound) || errors.Is(err, ErrFileChanged) {
+		return nil, err
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FileRepository.ReplaceBlocks: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.ReplaceBlocks: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(1 + len(blockIDs)),
+	})
+	return file, nil
+}
+
+// nearDuplicateMaxFanout leaves blocks found in more files of one user than this (such
+// as a block of zeros) out of FindNearDuplicates, so they do not pair up every file
+// holding them.
+const nearDuplicateMaxFanout = 100
+
+// FindNearDuplicates returns pairs of files of the same owner, within scope, that share
+// at least f.MinSharedPercent of the distinct blocks of the larger file, most shared
+// bytes first. Trashed files are left out.
+func (r *FileRepository) FindNearDuplicates(ctx context.Context, scope Scope, f model.NearDuplicateFilter) ([]*model.NearDuplicate, error) {
+	ownerID, err := scope.owner("FileRepository.FindNearDuplicates")
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := "WITH fb AS (distinct file/block pairs of live files >= $2 bytes [of user $1]), common AS (blocks in 2..$3 files of a user), counts AS (blocks per file), pairs AS (fb a JOIN fb b ON same block and owner JOIN common GROUP BY file pair) SELECT ... WHERE shared * 100 >= $4 * larger file's blocks ORDER BY shared_bytes DESC LIMIT $5"
+
+	rows, err := r.db.Query(ctx,
+		`WITH fb AS (
+		     SELECT DISTINCT fb.file_id, fb.block_id, f.user_id
+		       FROM file_blocks fb JOIN files f ON f.id = fb.file_id
+		      WHERE f.deleted_at IS NULL AND f.total_size >= $2 AND ($1 = 0 OR f.user_id = $1)
+		 ), common AS (
+		     SELECT user_id, block_id FROM fb GROUP BY user_id, block_id HAVING COUNT(*) BETWEEN 2 AND $3
+		 ), counts AS (
+		     SELECT file_id, COUNT(*) AS blocks FROM fb GROUP BY file_id
+		 ), pairs AS (
+		     SELECT a.user_id, a.file_id AS a_id, b.file_id AS b_id, COUNT(*) AS shared, SUM(bl.size_bytes) AS shared_bytes
+		       FROM fb a
+		       JOIN common c ON c.user_id = a.user_id AND c.block_id = a.block_id
+		       JOIN fb b ON b.user_id = a.user_id AND b.block_id = a.block_id AND b.file_id > a.file_id
+		       JOIN blocks bl ON bl.id = a.block_id
+		      GROUP BY a.user_id, a.file_id, b.file_id
+		 )
+		 SELECT p.user_id, p.shared, p.shared_bytes, (p.shared * 100 / GREATEST(ca.blocks, cb.blocks))::int,
+		        fa.id, fa.name, fa.folder_id, fa.total_size, ca.blocks, fa.created_at,
+		        fo.id, fo.name, fo.folder_id, fo.total_size, cb.blocks, fo.created_at
+		   FROM pairs p
+		   JOIN counts ca ON ca.file_id = p.a_id
+		   JOIN counts cb ON cb.file_id = p.b_id
+		   JOIN files fa ON fa.id = p.a_id
+		   JOIN files fo ON fo.id = p.b_id
+		  WHERE p.shared * 100 >= $4 * GREATEST(ca.blocks, cb.blocks)
+		  ORDER BY p.shared_bytes DESC, p.a_id, p.b_id
+		  LIMIT $5`,
+		ownerID, f.MinSize, nearDuplicateMaxFanout, f.MinSharedPercent, f.Limit,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FileRepository.FindNearDuplicates: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FileRepository.FindNearDuplicates: %w", classify(err))
+	}
+	defer rows.Close()
+
+	out := []*model.NearDuplicate{}
+	for rows.Next() {
+		d := &model.NearDuplicate{File: &model.DuplicateFile{}, Other: &model.DuplicateFile{}}
+		if err := rows.Scan(&d.UserID, &d.SharedBlocks, &d.SharedBytes, &d.SharedPercent,
+			&d.File.ID, &d.File.Name, &d.File.FolderID, &d.File.TotalSize, &d.File.Blocks, &d.File.CreatedAt,
+			&d.Other.ID, &d.Other.Name, &d.Other.FolderID, &d.Other.TotalSize, &d.Other.Blocks, &d.Other.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("FileRepository.FindNearDuplicates: %w", err)
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("FileRepository.FindNearDuplicates: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const folderShareLinkColumns = "id, folder_id, user_id, token, view, enabled, password_hash, expires_at, created_at, allow_uploads, upload_max_bytes, upload_max_files, upload_count"
+
+const folderShareUploadColumns = "id, link_id, folder_id, file_id, uploader_name, file_name, size, client_ip, created_at"
+
+// ErrFolderShareLinkNotFound is returned when a folder share link does not exist or is not owned by the user.
+var ErrFolderShareLinkNotFound = notFoundError("folder share link not found or unauthorized")
+
+// FolderShareLinkChanges are the fields Update may change; nil leaves a field as it
+// is. A zero UploadMaxBytes or UploadMaxFiles removes that cap.
+type FolderShareLinkChanges struct {
+	Enabled        *bool
+	View           *string
+	AllowUploads   *bool
+	UploadMaxBytes *int64
+	UploadMaxFiles *int
+}
+
+type FolderShareLinkRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFolderShareLinkRepository(db *pgxpool.Pool) *FolderShareLinkRepository {
+	return &FolderShareLinkRepository{db: db}
+}
+
+// scanFolderShareLink scans folderShareLinkColumns; extra receives any columns selected after them.
+func scanFolderShareLink(row pgx.Row, extra ...interface{}) (*model.FolderShareLink, error) {
+	l := &model.FolderShareLink{}
+	dest := []interface{}{&l.ID, &l.FolderID, &l.UserID, &l.Token, &l.View, &l.Enabled, &l.PasswordHash, &l.ExpiresAt, &l.CreatedAt,
+		&l.AllowUploads, &l.UploadMaxBytes, &l.UploadMaxFiles, &l.UploadCount}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Create inserts l (folder, owner, token, view, password hash, expiry and upload
+// settings) and returns the stored link.
+func (r *FolderShareLinkRepository) Create(ctx context.Context, l *model.FolderShareLink) (*model.FolderShareLink, error) {
+	start := time.Now()
+	query := "INSERT INTO folder_share_links (folder_id, user_id, token, view, expires_at, password_hash, allow_uploads, upload_max_bytes, upload_max_files) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING ..."
+
+	link, err := scanFolderShareLink(r.db.QueryRow(ctx,
+		`INSERT INTO folder_share_links (folder_id, user_id, token, view, expires_at, password_hash, allow_uploads, upload_max_bytes, upload_max_files)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING `+folderShareLinkColumns,
+		l.FolderID, l.UserID, l.Token, l.View, l.ExpiresAt, l.PasswordHash, l.AllowUploads, l.UploadMaxBytes, l.UploadMaxFiles,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderShareLinkRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return link, nil
+}
+
+// FindByToken returns a folder share link by its token, noting whether its owner is
+// deactivated, or nil if there is none.
+func (r *FolderShareLinkRepository) FindByToken(ctx context.Context, token string) (*model.FolderShareLink, error) {
+	// Tokens that cannot have been issued are turned away without a query.
+	if !shareTokenPattern.MatchString(token) {
+		return nil, nil
+	}
+
+	start := time.Now()
+	query := "SELECT " + folderShareLinkColumns + ", (SELECT u.deactivated_at IS NOT NULL FROM users u WHERE u.id = folder_share_links.user_id) FROM folder_share_links WHERE token = $1"
+
+	var deactivated bool
+	link, err := scanFolderShareLink(r.db.QueryRow(ctx, query, token), &deactivated)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.FindByToken: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderShareLinkRepository.FindByToken: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	link.OwnerDeactivated = deactivated
+	return link, nil
+}
+
+// ListByFolder returns the share links of a folder owned by userID, newest first.
+func (r *FolderShareLinkRepository) ListByFolder(ctx context.Context, folderID, userID int64) ([]*model.FolderShareLink, error) {
+	start := time.Now()
+	query := "SELECT " + folderShareLinkColumns + " FROM folder_share_links WHERE folder_id = $1 AND user_id = $2 ORDER BY created_at DESC"
+
+	rows, err := r.db.Query(ctx, query, folderID, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.ListByFolder: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderShareLinkRepository.ListByFolder: %w", classify(err))
+	}
+	defer rows.Close()
+
+	links := []*model.FolderShareLink{}
+	for rows.Next() {
+		l, err := scanFolderShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(links)),
+	})
+	return links, rows.Err()
+}
+
+// Update applies changes to a link owned by userID. Returns
+// ErrFolderShareLinkNotFound if there is no such link.
+func (r *FolderShareLinkRepository) Update(ctx context.Context, linkID, userID int64, changes FolderShareLinkChanges) (*model.FolderShareLink, error) {
+	start := time.Now()
+	query := "UPDATE folder_share_links SET enabled = COALESCE($3, enabled), view = COALESCE($4, view), allow_uploads = COALESCE($5, allow_uploads), upload_max_bytes = ..., upload_max_files = ... WHERE id = $1 AND user_id = $2 RETURNING ..."
+
+	link, err := scanFolderShareLink(r.db.QueryRow(ctx,
+		`UPDATE folder_share_links
+		 SET enabled          = COALESCE($3, enabled),
+		     view             = COALESCE($4, view),
+		     allow_uploads    = COALESCE($5, allow_uploads),
+		     upload_max_bytes = CASE WHEN $6::BIGINT IS NULL THEN upload_max_bytes ELSE NULLIF($6, 0) END,
+		     upload_max_files = CASE WHEN $7::INT IS NULL THEN upload_max_files ELSE NULLIF($7, 0) END
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+folderShareLinkColumns,
+		linkID, userID, changes.Enabled, changes.View, changes.AllowUploads, changes.UploadMaxBytes, changes.UploadMaxFiles,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("FolderShareLinkRepository.Update: %w", ErrFolderShareLinkNotFound)
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.Update: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderShareLinkRepository.Update: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return link, nil
+}
+
+// ReserveUpload claims one of a link's upload slots before a file is stored. It
+// returns false, claiming nothing, when the link does not accept uploads or its file
+// cap is reached. A slot whose upload then fails is given back with ReleaseUpload.
+func (r *FolderShareLinkRepository) ReserveUpload(ctx context.Context, linkID int64) (bool, error) {
+	start := time.Now()
+	query := "UPDATE folder_share_links SET upload_count = upload_count + 1 WHERE id = $1 AND allow_uploads AND (upload_max_files IS NULL OR upload_count < upload_max_files)"
+
+	result, err := r.db.Exec(ctx, query, linkID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.ReserveUpload: %s", err.Error()),
+		})
+		return false, fmt.Errorf("FolderShareLinkRepository.ReserveUpload: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected() == 1, nil
+}
+
+// ReleaseUpload gives back a slot claimed by ReserveUpload.
+func (r *FolderShareLinkRepository) ReleaseUpload(ctx context.Context, linkID int64) error {
+	start := time.Now()
+	query := "UPDATE folder_share_links SET upload_count = GREATEST(upload_count - 1, 0) WHERE id = $1"
+
+	result, err := r.db.Exec(ctx, query, linkID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.ReleaseUpload: %s", err.Error()),
+		})
+		return fmt.Errorf("FolderShareLinkRepository.ReleaseUpload: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// RecordUpload adds u to the folder's share-upload activity on behalf of ownerID.
+func (r *FolderShareLinkRepository) RecordUpload(ctx context.Context, ownerID int64, u *model.FolderShareUpload) error {
+	start := time.Now()
+	query := "INSERT INTO folder_share_uploads (link_id, folder_id, user_id, file_id, uploader_name, file_name, size, client_ip) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+	_, err := r.db.Exec(ctx, query, u.LinkID, u.FolderID, ownerID, u.FileID, u.UploaderName, u.FileName, u.Size, u.ClientIP)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.RecordUpload: %s", err.Error()),
+		})
+		return fmt.Errorf("FolderShareLinkRepository.RecordUpload: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return nil
+}
+
+// ListUploads returns the files received through share links of a folder owned by
+// userID, newest first, at most limit of them.
+func (r *FolderShareLinkRepository) ListUploads(ctx context.Context, folderID, userID int64, limit int) ([]*model.FolderShareUpload, error) {
+	start := time.Now()
+	query := "SELECT " + folderShareUploadColumns + " FROM folder_share_uploads WHERE folder_id = $1 AND user_id = $2 ORDER BY created_at DESC, id DESC LIMIT $3"
+
+	rows, err := r.db.Query(ctx, query, folderID, userID, limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.ListUploads: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderShareLinkRepository.ListUploads: %w", classify(err))
+	}
+	defer rows.Close()
+
+	uploads := []*model.FolderShareUpload{}
+	for rows.Next() {
+		u := &model.FolderShareUpload{}
+		if err := rows.Scan(&u.ID, &u.LinkID, &u.FolderID, &u.FileID, &u.UploaderName, &u.FileName, &u.Size, &u.ClientIP, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(uploads)),
+	})
+	return uploads, rows.Err()
+}
+
+// Delete removes a folder share link owned by userID.
+func (r *FolderShareLinkRepository) Delete(ctx context.Context, linkID, userID int64) error {
+	start := time.Now()
+	query := "DELETE FROM folder_share_links WHERE id = $1 AND user_id = $2"
+
+	result, err := r.db.Exec(ctx, query, linkID, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("FolderShareLinkRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("FolderShareLinkRepository.Delete: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	if result.RowsAffected() == 0 {
+		return ErrFolderShareLinkNotFound
+	}
+	return nil
+}
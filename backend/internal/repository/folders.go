@@ -12,6 +12,8 @@ import (
 	"github.com/naratel/naratel-box/backend/internal/model"
 )
 
+const folderColumns = "id, user_id, parent_id, name, color, icon, description, expires_at, created_at, updated_at"
+
 type FolderRepository struct {
 	db *pgxpool.Pool
 }
@@ -20,18 +22,27 @@ func NewFolderRepository(db *pgxpool.Pool) *FolderRepository {
 	return &FolderRepository{db: db}
 }
 
+// scanFolder scans folderColumns; extra receives any columns selected after them.
+func scanFolder(row pgx.Row, extra ...interface{}) (*model.Folder, error) {
+	f := &model.Folder{}
+	dest := []interface{}{&f.ID, &f.UserID, &f.ParentID, &f.Name, &f.Color, &f.Icon, &f.Description, &f.ExpiresAt, &f.CreatedAt, &f.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
 // Create inserts a new folder.
 func (r *FolderRepository) Create(ctx context.Context, userID int64, parentID *int64, name string) (*model.Folder, error) {
 	start := time.Now()
 	query := "INSERT INTO folders (user_id, parent_id, name) VALUES ($1, $2, $3) RETURNING ..."
 
-	folder := &model.Folder{}
-	err := r.db.QueryRow(ctx,
+	folder, err := scanFolder(r.db.QueryRow(ctx,
 		`INSERT INTO folders (user_id, parent_id, name)
 		 VALUES ($1, $2, $3)
-		 RETURNING id, user_id, parent_id, name, created_at, updated_at`,
+		 RETURNING `+folderColumns,
 		userID, parentID, name,
-	).Scan(&folder.ID, &folder.UserID, &folder.ParentID, &folder.Name, &folder.CreatedAt, &folder.UpdatedAt)
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -39,7 +50,7 @@ func (r *FolderRepository) Create(ctx context.Context, userID int64, parentID *i
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("FolderRepository.Create: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.Create: %w", err)
+		return nil, fmt.Errorf("FolderRepository.Create: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -51,11 +62,9 @@ func (r *FolderRepository) Create(ctx context.Context, userID int64, parentID *i
 // FindByIDAndUserID fetches a folder by ID and user ownership.
 func (r *FolderRepository) FindByIDAndUserID(ctx context.Context, folderID, userID int64) (*model.Folder, error) {
 	start := time.Now()
-	query := "SELECT id, user_id, parent_id, name, created_at, updated_at FROM folders WHERE id = $1 AND user_id = $2"
+	query := "SELECT " + folderColumns + " FROM folders WHERE id = $1 AND user_id = $2"
 
-	folder := &model.Folder{}
-	err := r.db.QueryRow(ctx, query, folderID, userID,
-	).Scan(&folder.ID, &folder.UserID, &folder.ParentID, &folder.Name, &folder.CreatedAt, &folder.UpdatedAt)
+	folder, err := scanFolder(r.db.QueryRow(ctx, query, folderID, userID))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -69,7 +78,7 @@ func (r *FolderRepository) FindByIDAndUserID(ctx context.Context, folderID, user
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.FindByIDAndUserID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.FindByIDAndUserID: %w", err)
+		return nil, fmt.Errorf("FolderRepository.FindByIDAndUserID: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -78,44 +87,53 @@ func (r *FolderRepository) FindByIDAndUserID(ctx context.Context, folderID, user
 	return folder, nil
 }
 
-// ListByParent returns subfolders within a parent folder (nil = root).
-func (r *FolderRepository) ListByParent(ctx context.Context, userID int64, parentID *int64) ([]*model.Folder, error) {
+// FindByName fetches the user's folder called name in parentID (nil = root). Names are
+// not unique, so the oldest match wins. Returns nil, nil if there is none.
+func (r *FolderRepository) FindByName(ctx context.Context, userID int64, parentID *int64, name string) (*model.Folder, error) {
 	start := time.Now()
-	var query string
-	var rows interface {
-		Next() bool
-		Scan(dest ...interface{}) error
-		Close()
-	}
+	query := "SELECT " + folderColumns + " FROM folders WHERE user_id = $1 AND (($2::bigint IS NULL AND parent_id IS NULL) OR parent_id = $2) AND name = $3 ORDER BY id LIMIT 1"
 
-	if parentID == nil {
-		query = "SELECT id, user_id, parent_id, name, created_at, updated_at FROM folders WHERE user_id = $1 AND parent_id IS NULL ORDER BY name ASC"
-		r2, err := r.db.Query(ctx, query, userID)
-		if err != nil {
-			logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-				Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.ListByParent: %s", err.Error()),
-			})
-			return nil, fmt.Errorf("FolderRepository.ListByParent: %w", err)
-		}
-		rows = r2
-		defer r2.Close()
-	} else {
-		query = "SELECT id, user_id, parent_id, name, created_at, updated_at FROM folders WHERE user_id = $1 AND parent_id = $2 ORDER BY name ASC"
-		r2, err := r.db.Query(ctx, query, userID, *parentID)
-		if err != nil {
-			logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
-				Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.ListByParent: %s", err.Error()),
+	folder, err := scanFolder(r.db.QueryRow(ctx, query, userID, parentID, name))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
 			})
-			return nil, fmt.Errorf("FolderRepository.ListByParent: %w", err)
+			return nil, nil
 		}
-		rows = r2
-		defer r2.Close()
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.FindByName: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.FindByName: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return folder, nil
+}
+
+// ListByParent returns subfolders within a parent folder (nil = root).
+func (r *FolderRepository) ListByParent(ctx context.Context, userID int64, parentID *int64) ([]*model.Folder, error) {
+	start := time.Now()
+	query := "SELECT " + folderColumns + " FROM folders WHERE user_id = $1 AND (($2::bigint IS NULL AND parent_id IS NULL) OR parent_id = $2) ORDER BY name COLLATE name_ci ASC"
+
+	rows, err := r.db.Query(ctx, query, userID, parentID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.ListByParent: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.ListByParent: %w", classify(err))
 	}
+	defer rows.Close()
 
 	var folders []*model.Folder
 	for rows.Next() {
-		f := &model.Folder{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.ParentID, &f.Name, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		f, err := scanFolder(rows)
+		if err != nil {
 			return nil, err
 		}
 		folders = append(folders, f)
@@ -128,18 +146,193 @@ func (r *FolderRepository) ListByParent(ctx context.Context, userID int64, paren
 	return folders, nil
 }
 
+// FileSort selects the order of the files in a folder listing.
+type FileSort string
+
+const (
+	FileSortName    FileSort = "name"    // name ascending, case-insensitive (name_ci collation)
+	FileSortNatural FileSort = "natural" // like name, but digit runs compare as numbers ("file2" < "file10")
+	FileSortCreated FileSort = "created" // newest first
+)
+
+// nameCollation is the collation subfolders and files are ordered by for a sort.
+func (s FileSort) nameCollation() string {
+	if s == FileSortNatural {
+		return "name_natural"
+	}
+	return "name_ci"
+}
+
+// FileCursor marks the last file of a listing page; the next page starts after it.
+// Only the field matching the sort is used alongside ID.
+type FileCursor struct {
+	Name      string
+	CreatedAt time.Time
+	ID        int64
+}
+
+// ContentsOptions pages the files of a folder listing.
+type ContentsOptions struct {
+	Sort  FileSort
+	Limit int         // files per page; 0 = all
+	After *FileCursor // resume after this file; subfolders are only listed on the first page
+}
+
+// keyset returns the paging condition and order for a file query in which $3 is the
+// cursor's sort value (NULL = first page) and $4 its ID, with the values to bind and
+// the LIMIT (one extra row tells whether another page follows; nil = all). The
+// collation names come from FileSort.nameCollation, never from user input.
+func (opts ContentsOptions) keyset() (seek, order string, after interface{}, afterID int64, limit *int) {
+	collation := opts.Sort.nameCollation()
+	seek = "($3::text IS NULL OR (name COLLATE " + collation + ", id) > ($3 COLLATE " + collation + ", $4))"
+	order = "name COLLATE " + collation + " ASC, id ASC"
+	if opts.Sort == FileSortCreated {
+		seek, order = "($3::timestamptz IS NULL OR (created_at, id) < ($3, $4))", "created_at DESC, id DESC"
+	}
+	if opts.After != nil {
+		afterID = opts.After.ID
+		after = opts.After.Name
+		if opts.Sort == FileSortCreated {
+			after = opts.After.CreatedAt
+		}
+	}
+	if opts.Limit > 0 {
+		n := opts.Limit + 1
+		limit = &n
+	}
+	return seek, order, after, afterID, limit
+}
+
+// Contents returns the subfolders, mounts and files directly inside parentID (nil = root) with
+// per-item summaries: folder item counts and sizes, and whether each file is shared.
+// The queries go out in one batch, so opening a folder costs a single round trip.
+// Files are paged by keyset on (sort key, id), so deep pages cost the same as the first;
+// the returned cursor is nil on the last page.
+func (r *FolderRepository) Contents(ctx context.Context, userID int64, parentID *int64, opts ContentsOptions) (*model.FolderContents, *FileCursor, error) {
+	start := time.Now()
+	query := "SELECT " + folderColumns + ", item_count, size_bytes FROM folders ... ; SELECT " + mountColumns + " FROM mounts ... ; SELECT " + fileColumns + ", EXISTS (share_links ...), d.content_hash, d.block_count FROM files LEFT JOIN LATERAL (file_blocks digest) d ... ORDER BY <sort>, id LIMIT $4"
+
+	collation := opts.Sort.nameCollation()
+	seek, order, after, afterID, limit := opts.keyset()
+
+	batch := &pgx.Batch{}
+	if opts.After == nil {
+		batch.Queue(
+			`SELECT `+folderColumns+`,
+			        (SELECT COUNT(*) FROM folders c WHERE c.parent_id = folders.id) + fs.n AS item_count,
+			        fs.size_bytes
+			 FROM folders
+			 CROSS JOIN LATERAL (
+				SELECT COUNT(*) AS n, COALESCE(SUM(f.total_size), 0) AS size_bytes
+				FROM files f WHERE f.folder_id = folders.id AND f.deleted_at IS NULL
+			 ) fs
+			 WHERE user_id = $1 AND (($2::bigint IS NULL AND parent_id IS NULL) OR parent_id = $2)
+			 ORDER BY name COLLATE `+collation+` ASC`,
+			userID, parentID)
+		batch.Queue(
+			`SELECT `+mountColumns+` FROM mounts
+			 WHERE user_id = $1 AND (($2::bigint IS NULL AND parent_id IS NULL) OR parent_id = $2)
+			 ORDER BY name COLLATE `+collation+` ASC`,
+			userID, parentID)
+	}
+	batch.Queue(
+		`SELECT `+fileColumns+`, `+fileSharedSQL+`, d.content_hash, d.block_count
+		 FROM files `+fileDigestJoin+`
+		 WHERE user_id = $1 AND (($2::bigint IS NULL AND folder_id IS NULL) OR folder_id = $2) AND deleted_at IS NULL
+		   AND `+seek+`
+		 ORDER BY `+order+`
+		 LIMIT $5`,
+		userID, parentID, after, afterID, limit)
+
+	contents := &model.FolderContents{Folders: []*model.FolderEntry{}, Files: []*model.FileEntry{}}
+	err := func() error {
+		br := r.db.SendBatch(ctx, batch)
+		defer br.Close()
+
+		if opts.After == nil {
+			rows, err := br.Query()
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				e := &model.FolderEntry{}
+				if e.Folder, err = scanFolder(rows, &e.ItemCount, &e.SizeBytes); err != nil {
+					rows.Close()
+					return err
+				}
+				contents.Folders = append(contents.Folders, e)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			if rows, err = br.Query(); err != nil {
+				return err
+			}
+			for rows.Next() {
+				m, err := scanMount(rows)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				m.Location = "" // admin-only detail
+				contents.Mounts = append(contents.Mounts, m)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+
+		rows, err := br.Query()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			e, err := scanFileEntry(rows)
+			if err != nil {
+				return err
+			}
+			contents.Files = append(contents.Files, e)
+		}
+		return rows.Err()
+	}()
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.Contents: %s", err.Error()),
+		})
+		return nil, nil, fmt.Errorf("FolderRepository.Contents: %w", classify(err))
+	}
+
+	var next *FileCursor
+	if opts.Limit > 0 && len(contents.Files) > opts.Limit {
+		contents.Files = contents.Files[:opts.Limit]
+		last := contents.Files[opts.Limit-1]
+		next = &FileCursor{Name: last.Name, CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(contents.Folders) + len(contents.Files)),
+	})
+	return contents, next, nil
+}
+
 // Rename updates the name of a folder.
 func (r *FolderRepository) Rename(ctx context.Context, folderID, userID int64, newName string) (*model.Folder, error) {
 	start := time.Now()
 	query := "UPDATE folders SET name = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3 RETURNING ..."
 
-	folder := &model.Folder{}
-	err := r.db.QueryRow(ctx,
+	folder, err := scanFolder(r.db.QueryRow(ctx,
 		`UPDATE folders SET name = $1, updated_at = NOW()
 		 WHERE id = $2 AND user_id = $3
-		 RETURNING id, user_id, parent_id, name, created_at, updated_at`,
+		 RETURNING `+folderColumns,
 		newName, folderID, userID,
-	).Scan(&folder.ID, &folder.UserID, &folder.ParentID, &folder.Name, &folder.CreatedAt, &folder.UpdatedAt)
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -147,7 +340,7 @@ func (r *FolderRepository) Rename(ctx context.Context, folderID, userID int64, n
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.Rename: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.Rename: %w", err)
+		return nil, fmt.Errorf("FolderRepository.Rename: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -161,13 +354,12 @@ func (r *FolderRepository) Move(ctx context.Context, folderID, userID int64, new
 	start := time.Now()
 	query := "UPDATE folders SET parent_id = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3 RETURNING ..."
 
-	folder := &model.Folder{}
-	err := r.db.QueryRow(ctx,
+	folder, err := scanFolder(r.db.QueryRow(ctx,
 		`UPDATE folders SET parent_id = $1, updated_at = NOW()
 		 WHERE id = $2 AND user_id = $3
-		 RETURNING id, user_id, parent_id, name, created_at, updated_at`,
+		 RETURNING `+folderColumns,
 		newParentID, folderID, userID,
-	).Scan(&folder.ID, &folder.UserID, &folder.ParentID, &folder.Name, &folder.CreatedAt, &folder.UpdatedAt)
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -175,7 +367,62 @@ func (r *FolderRepository) Move(ctx context.Context, folderID, userID int64, new
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.Move: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.Move: %w", err)
+		return nil, fmt.Errorf("FolderRepository.Move: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return folder, nil
+}
+
+// UpdateMetadata sets a folder's color, icon and description (nil clears a field).
+func (r *FolderRepository) UpdateMetadata(ctx context.Context, folderID, userID int64, color, icon, description *string) (*model.Folder, error) {
+	start := time.Now()
+	query := "UPDATE folders SET color = $1, icon = $2, description = $3, updated_at = NOW() WHERE id = $4 AND user_id = $5 RETURNING ..."
+
+	folder, err := scanFolder(r.db.QueryRow(ctx,
+		`UPDATE folders SET color = $1, icon = $2, description = $3, updated_at = NOW()
+		 WHERE id = $4 AND user_id = $5
+		 RETURNING `+folderColumns,
+		color, icon, description, folderID, userID,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.UpdateMetadata: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.UpdateMetadata: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return folder, nil
+}
+
+// SetExpiry schedules a folder to be deleted with its contents at expiresAt (nil clears
+// it). Changing the date re-arms the advance notice.
+func (r *FolderRepository) SetExpiry(ctx context.Context, folderID, userID int64, expiresAt *time.Time) (*model.Folder, error) {
+	start := time.Now()
+	query := "UPDATE folders SET expires_at = $3, expiry_notified_at = NULL, updated_at = NOW() WHERE id = $1 AND user_id = $2 RETURNING ..."
+
+	folder, err := scanFolder(r.db.QueryRow(ctx,
+		`UPDATE folders SET expires_at = $3, expiry_notified_at = NULL, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+folderColumns,
+		folderID, userID, expiresAt,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.SetExpiry: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.SetExpiry: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -184,7 +431,119 @@ func (r *FolderRepository) Move(ctx context.Context, folderID, userID int64, new
 	return folder, nil
 }
 
-// Delete removes a folder and all its contents (cascades via FK).
+// NotifyExpiring warns the owners of up to limit folders expiring before noticeBefore
+// (and not yet warned), marking them notified in the same statement. Returns how many
+// notifications were created.
+func (r *FolderRepository) NotifyExpiring(ctx context.Context, noticeBefore time.Time, limit int) (int64, error) {
+	start := time.Now()
+	query := "WITH due AS (SELECT ... WHERE expires_at <= $3 AND expires_at > NOW() AND expiry_notified_at IS NULL LIMIT $4 FOR UPDATE SKIP LOCKED), notices AS (UPDATE folders SET expiry_notified_at = NOW() ...) INSERT INTO notifications ..."
+
+	result, err := r.db.Exec(ctx,
+		`WITH due AS (
+			SELECT id AS due_id FROM folders
+			WHERE expires_at <= $3 AND expires_at > NOW() AND expiry_notified_at IS NULL
+			ORDER BY expires_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		 ), notices AS (
+			UPDATE folders SET expiry_notified_at = NOW()
+			FROM due WHERE id = due_id
+			RETURNING id, user_id, name, expires_at AS due_at
+		 ) `+expiryNoticeInsert,
+		model.NotificationExpiryWarning, "folder", noticeBefore, limit)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.NotifyExpiring: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FolderRepository.NotifyExpiring: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// ClaimExpired clears the expiry of up to limit folders whose expiry has passed and
+// returns them, so that each is handed to exactly one delete. The returned folders
+// carry the expiry they had, which RestoreExpiry puts back if the delete fails.
+func (r *FolderRepository) ClaimExpired(ctx context.Context, limit int) ([]*model.Folder, error) {
+	start := time.Now()
+	query := "WITH due AS (SELECT ... WHERE expires_at <= NOW() LIMIT $1 FOR UPDATE SKIP LOCKED) UPDATE folders SET expires_at = NULL, expiry_notified_at = NULL FROM due WHERE id = due_id RETURNING ..., due_at"
+
+	rows, err := r.db.Query(ctx,
+		`WITH due AS (
+			SELECT id AS due_id, expires_at AS due_at FROM folders
+			WHERE expires_at <= NOW()
+			ORDER BY expires_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 UPDATE folders SET expires_at = NULL, expiry_notified_at = NULL
+		 FROM due WHERE id = due_id
+		 RETURNING `+folderColumns+`, due_at`,
+		limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.ClaimExpired: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.ClaimExpired: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var folders []*model.Folder
+	for rows.Next() {
+		var dueAt time.Time
+		f, err := scanFolder(rows, &dueAt)
+		if err != nil {
+			return nil, err
+		}
+		f.ExpiresAt = &dueAt
+		folders = append(folders, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("FolderRepository.ClaimExpired: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(folders)),
+	})
+	return folders, nil
+}
+
+// RestoreExpiry puts back the expiry ClaimExpired cleared from a folder whose delete
+// did not go through, so the next expiry run picks it up again. A folder whose expiry
+// was set again in the meantime, or that no longer exists, is left alone.
+func (r *FolderRepository) RestoreExpiry(ctx context.Context, folderID int64, expiresAt time.Time) error {
+	start := time.Now()
+	query := "UPDATE folders SET expires_at = $2 WHERE id = $1 AND expires_at IS NULL"
+
+	result, err := r.db.Exec(ctx, query, folderID, expiresAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("FolderRepository.RestoreExpiry: %s", err.Error()),
+		})
+		return fmt.Errorf("FolderRepository.RestoreExpiry: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// ErrFolderNotFound is returned when a folder does not exist or is not owned by the user.
+var ErrFolderNotFound = notFoundError("folder not found or unauthorized")
+
+// Delete removes a folder and its subfolders (cascades via FK). Files still inside
+// are moved to the root by the FK, so callers trash them first (see jobs.DeleteFolderTree).
 func (r *FolderRepository) Delete(ctx context.Context, folderID, userID int64) error {
 	start := time.Now()
 	query := "DELETE FROM folders WHERE id = $1 AND user_id = $2"
@@ -197,13 +556,13 @@ func (r *FolderRepository) Delete(ctx context.Context, folderID, userID int64) e
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("FolderRepository.Delete: %s", err.Error()),
 		})
-		return fmt.Errorf("FolderRepository.Delete: %w", err)
+		return fmt.Errorf("FolderRepository.Delete: %w", classify(err))
 	}
 	if result.RowsAffected() == 0 {
 		logger.Warn(ctx, "Delete affected 0 rows", map[string]interface{}{
 			"folder_id": folderID, "user_id": userID,
 		})
-		return fmt.Errorf("folder not found or unauthorized")
+		return ErrFolderNotFound
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -212,34 +571,129 @@ func (r *FolderRepository) Delete(ctx context.Context, folderID, userID int64) e
 	return nil
 }
 
+// ListSubtreeIDs returns the IDs of a folder and all its descendants, or nil if the
+// folder does not exist or is not owned by the user.
+func (r *FolderRepository) ListSubtreeIDs(ctx context.Context, folderID, userID int64) ([]int64, error) {
+	start := time.Now()
+	query := "WITH RECURSIVE subtree AS (...) SELECT id FROM subtree"
+
+	rows, err := r.db.Query(ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id FROM folders WHERE id = $1 AND user_id = $2
+			UNION ALL
+			SELECT f.id FROM folders f INNER JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT id FROM subtree`,
+		folderID, userID,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.ListSubtreeIDs: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.ListSubtreeIDs: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(ids)),
+	})
+	return ids, nil
+}
+
+// SubtreeHeight returns how many levels the folder's subtree spans (1 = no subfolders).
+func (r *FolderRepository) SubtreeHeight(ctx context.Context, folderID, userID int64) (int, error) {
+	start := time.Now()
+	query := "WITH RECURSIVE subtree AS (...) SELECT COALESCE(MAX(level), 0) FROM subtree"
+
+	var height int
+	err := r.db.QueryRow(ctx,
+		`WITH RECURSIVE subtree AS (
+			SELECT id, 1 AS level FROM folders WHERE id = $1 AND user_id = $2
+			UNION ALL
+			SELECT f.id, s.level + 1 FROM folders f INNER JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT COALESCE(MAX(level), 0) FROM subtree`,
+		folderID, userID,
+	).Scan(&height)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.SubtreeHeight: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FolderRepository.SubtreeHeight: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return height, nil
+}
+
+// CountChildren returns how many subfolders a user has directly under parentID (nil = root).
+func (r *FolderRepository) CountChildren(ctx context.Context, userID int64, parentID *int64) (int, error) {
+	start := time.Now()
+	query := "SELECT COUNT(*) FROM folders WHERE user_id = $1 AND (($2::bigint IS NULL AND parent_id IS NULL) OR parent_id = $2)"
+
+	var n int
+	err := r.db.QueryRow(ctx, query, userID, parentID).Scan(&n)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.CountChildren: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("FolderRepository.CountChildren: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return n, nil
+}
+
 // GetBreadcrumb returns the ancestry chain from root to the given folder.
 func (r *FolderRepository) GetBreadcrumb(ctx context.Context, folderID, userID int64) ([]*model.Folder, error) {
 	start := time.Now()
-	query := "WITH RECURSIVE ancestors AS (...) SELECT id, user_id, parent_id, name, created_at, updated_at FROM ancestors"
+	query := "WITH RECURSIVE ancestors AS (...) SELECT " + folderColumns + " FROM folders JOIN ancestors ON id = ancestor_id ORDER BY depth DESC"
 
 	rows, err := r.db.Query(ctx,
-		`WITH RECURSIVE ancestors AS (
-			SELECT id, user_id, parent_id, name, created_at, updated_at
-			FROM folders WHERE id = $1 AND user_id = $2
+		`WITH RECURSIVE ancestors (ancestor_id, next_id, depth) AS (
+			SELECT id, parent_id, 0 FROM folders WHERE id = $1 AND user_id = $2
 			UNION ALL
-			SELECT f.id, f.user_id, f.parent_id, f.name, f.created_at, f.updated_at
-			FROM folders f INNER JOIN ancestors a ON f.id = a.parent_id
+			SELECT f.id, f.parent_id, a.depth + 1
+			FROM folders f INNER JOIN ancestors a ON f.id = a.next_id
 		)
-		SELECT id, user_id, parent_id, name, created_at, updated_at FROM ancestors`,
+		SELECT `+folderColumns+` FROM folders
+		JOIN ancestors ON id = ancestor_id
+		ORDER BY depth DESC`,
 		folderID, userID,
 	)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.GetBreadcrumb: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.GetBreadcrumb: %w", err)
+		return nil, fmt.Errorf("FolderRepository.GetBreadcrumb: %w", classify(err))
 	}
 	defer rows.Close()
 
+	// Root comes first
 	var chain []*model.Folder
 	for rows.Next() {
-		f := &model.Folder{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.ParentID, &f.Name, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		f, err := scanFolder(rows)
+		if err != nil {
 			return nil, err
 		}
 		chain = append(chain, f)
@@ -249,32 +703,27 @@ func (r *FolderRepository) GetBreadcrumb(ctx context.Context, folderID, userID i
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
 		Query: query, DurationMs: duration, RowsAffected: int64(len(chain)),
 	})
-
-	// Reverse so root comes first
-	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
-		chain[i], chain[j] = chain[j], chain[i]
-	}
 	return chain, nil
 }
 
 // ListAllByUser returns all folders for a user (for move dialog).
 func (r *FolderRepository) ListAllByUser(ctx context.Context, userID int64) ([]*model.Folder, error) {
 	start := time.Now()
-	query := "SELECT id, user_id, parent_id, name, created_at, updated_at FROM folders WHERE user_id = $1 ORDER BY name ASC"
+	query := "SELECT " + folderColumns + " FROM folders WHERE user_id = $1 ORDER BY name COLLATE name_ci ASC"
 
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.ListAllByUser: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("FolderRepository.ListAllByUser: %w", err)
+		return nil, fmt.Errorf("FolderRepository.ListAllByUser: %w", classify(err))
 	}
 	defer rows.Close()
 
 	var folders []*model.Folder
 	for rows.Next() {
-		f := &model.Folder{}
-		if err := rows.Scan(&f.ID, &f.UserID, &f.ParentID, &f.Name, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		f, err := scanFolder(rows)
+		if err != nil {
 			return nil, err
 		}
 		folders = append(folders, f)
@@ -286,3 +735,116 @@ func (r *FolderRepository) ListAllByUser(ctx context.Context, userID int64) ([]*
 	})
 	return folders, nil
 }
+
+// GetShareSettings returns the share settings set directly on a folder owned by userID.
+// Folders without settings get an all-nil (inherit everything) record.
+func (r *FolderRepository) GetShareSettings(ctx context.Context, folderID, userID int64) (*model.FolderShareSettings, error) {
+	start := time.Now()
+	query := "SELECT s.default_expiry_hours, s.require_password, s.allow_public_links FROM folders f LEFT JOIN folder_share_settings s ON s.folder_id = f.id WHERE f.id = $1 AND f.user_id = $2"
+
+	settings := &model.FolderShareSettings{FolderID: folderID}
+	err := r.db.QueryRow(ctx, query, folderID, userID).Scan(&settings.DefaultExpiryHours, &settings.RequirePassword, &settings.AllowPublicLinks)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.GetShareSettings: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.GetShareSettings: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return settings, nil
+}
+
+// UpsertShareSettings replaces the share settings set directly on a folder.
+// The caller must have verified folder ownership.
+func (r *FolderRepository) UpsertShareSettings(ctx context.Context, s *model.FolderShareSettings) error {
+	start := time.Now()
+	query := "INSERT INTO folder_share_settings (folder_id, default_expiry_hours, require_password, allow_public_links) VALUES ($1, $2, $3, $4) ON CONFLICT (folder_id) DO UPDATE SET ..."
+
+	result, err := r.db.Exec(ctx,
+		`INSERT INTO folder_share_settings (folder_id, default_expiry_hours, require_password, allow_public_links)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (folder_id) DO UPDATE SET
+		     default_expiry_hours = EXCLUDED.default_expiry_hours,
+		     require_password     = EXCLUDED.require_password,
+		     allow_public_links   = EXCLUDED.allow_public_links,
+		     updated_at           = NOW()`,
+		s.FolderID, s.DefaultExpiryHours, s.RequirePassword, s.AllowPublicLinks,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPSERT_ERR", Details: fmt.Sprintf("FolderRepository.UpsertShareSettings: %s", err.Error()),
+		})
+		return fmt.Errorf("FolderRepository.UpsertShareSettings: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// EffectiveShareSettings resolves share settings for items inside folderID by taking,
+// for each setting, the value from the nearest ancestor (including the folder itself) that sets it.
+// A nil folderID (root) yields the permissive defaults.
+func (r *FolderRepository) EffectiveShareSettings(ctx context.Context, folderID *int64) (*model.EffectiveShareSettings, error) {
+	eff := &model.EffectiveShareSettings{AllowPublicLinks: true}
+	if folderID == nil {
+		return eff, nil
+	}
+
+	start := time.Now()
+	query := "WITH RECURSIVE chain AS (...) SELECT nearest default_expiry_hours, require_password, allow_public_links"
+
+	var requirePassword, allowPublic *bool
+	err := r.db.QueryRow(ctx,
+		`WITH RECURSIVE chain AS (
+			SELECT id, parent_id, 0 AS depth FROM folders WHERE id = $1
+			UNION ALL
+			SELECT f.id, f.parent_id, c.depth + 1 FROM folders f INNER JOIN chain c ON f.id = c.parent_id
+		)
+		SELECT
+			(SELECT s.default_expiry_hours FROM chain c JOIN folder_share_settings s ON s.folder_id = c.id
+			 WHERE s.default_expiry_hours IS NOT NULL ORDER BY c.depth LIMIT 1),
+			(SELECT s.require_password FROM chain c JOIN folder_share_settings s ON s.folder_id = c.id
+			 WHERE s.require_password IS NOT NULL ORDER BY c.depth LIMIT 1),
+			(SELECT s.allow_public_links FROM chain c JOIN folder_share_settings s ON s.folder_id = c.id
+			 WHERE s.allow_public_links IS NOT NULL ORDER BY c.depth LIMIT 1)`,
+		*folderID,
+	).Scan(&eff.DefaultExpiryHours, &requirePassword, &allowPublic)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("FolderRepository.EffectiveShareSettings: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("FolderRepository.EffectiveShareSettings: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+
+	if requirePassword != nil {
+		eff.RequirePassword = *requirePassword
+	}
+	if allowPublic != nil {
+		eff.AllowPublicLinks = *allowPublic
+	}
+	return eff, nil
+}
@@ -0,0 +1,251 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const jobColumns = "id, user_id, kind, status, progress, payload, result, error, created_at, started_at, finished_at"
+
+type JobRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewJobRepository(db *pgxpool.Pool) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+func scanJob(row pgx.Row) (*model.Job, error) {
+	j := &model.Job{}
+	var payload, result []byte
+	if err := row.Scan(&j.ID, &j.UserID, &j.Kind, &j.Status, &j.Progress, &payload, &result,
+		&j.Error, &j.CreatedAt, &j.StartedAt, &j.FinishedAt); err != nil {
+		return nil, err
+	}
+	j.Payload = payload
+	j.Result = result
+	return j, nil
+}
+
+// Create inserts a pending job. payload may be nil.
+func (r *JobRepository) Create(ctx context.Context, userID int64, kind string, payload interface{}) (*model.Job, error) {
+	start := time.Now()
+	query := "INSERT INTO jobs (user_id, kind, payload) VALUES ($1, $2, $3) RETURNING ..."
+
+	var raw []byte
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("JobRepository.Create marshal payload: %w", err)
+		}
+		raw = b
+	}
+
+	job, err := scanJob(r.db.QueryRow(ctx,
+		`INSERT INTO jobs (user_id, kind, payload)
+		 VALUES ($1, $2, $3)
+		 RETURNING `+jobColumns,
+		userID, kind, raw,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("JobRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("JobRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return job, nil
+}
+
+// MarkRunning moves a job to the running state.
+func (r *JobRepository) MarkRunning(ctx context.Context, jobID int64) error {
+	return r.exec(ctx, "JobRepository.MarkRunning",
+		"UPDATE jobs SET status = 'running', started_at = NOW() WHERE id = $1", jobID)
+}
+
+// UpdateProgress records a 0-100 progress percentage.
+func (r *JobRepository) UpdateProgress(ctx context.Context, jobID int64, progress int) error {
+	return r.exec(ctx, "JobRepository.UpdateProgress",
+		"UPDATE jobs SET progress = $2 WHERE id = $1", jobID, progress)
+}
+
+// Complete stores the job result and marks it completed.
+func (r *JobRepository) Complete(ctx context.Context, jobID int64, result interface{}) error {
+	var raw []byte
+	if result != nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("JobRepository.Complete marshal result: %w", err)
+		}
+		raw = b
+	}
+	return r.exec(ctx, "JobRepository.Complete",
+		"UPDATE jobs SET status = 'completed', progress = 100, result = $2, finished_at = NOW() WHERE id = $1", jobID, raw)
+}
+
+// Fail marks a job as failed with the given error message.
+func (r *JobRepository) Fail(ctx context.Context, jobID int64, message string) error {
+	return r.exec(ctx, "JobRepository.Fail",
+		"UPDATE jobs SET status = 'failed', error = $2, finished_at = NOW() WHERE id = $1", jobID, message)
+}
+
+// FailInterrupted marks jobs left pending/running by a previous process as failed.
+// Called once at startup; returns the number of jobs affected.
+func (r *JobRepository) FailInterrupted(ctx context.Context) (int64, error) {
+	start := time.Now()
+	query := "UPDATE jobs SET status = 'failed', error = 'interrupted by server restart', finished_at = NOW() WHERE status IN ('pending', 'running')"
+
+	result, err := r.db.Exec(ctx, query)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("JobRepository.FailInterrupted: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("JobRepository.FailInterrupted: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// FindLatestByKind returns the user's most recent job of a kind. Returns nil, nil if none exists.
+func (r *JobRepository) FindLatestByKind(ctx context.Context, userID int64, kind string) (*model.Job, error) {
+	start := time.Now()
+	query := "SELECT " + jobColumns + " FROM jobs WHERE user_id = $1 AND kind = $2 ORDER BY created_at DESC LIMIT 1"
+
+	job, err := scanJob(r.db.QueryRow(ctx, query, userID, kind))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("JobRepository.FindLatestByKind: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("JobRepository.FindLatestByKind: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return job, nil
+}
+
+// FindByID returns a job by ID within scope. Returns nil, nil if not found.
+func (r *JobRepository) FindByID(ctx context.Context, scope Scope, jobID int64) (*model.Job, error) {
+	ownerID, err := scope.owner("JobRepository.FindByID")
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := "SELECT " + jobColumns + " FROM jobs WHERE id = $1 AND ($2 = 0 OR user_id = $2)"
+
+	job, err := scanJob(r.db.QueryRow(ctx, query, jobID, ownerID))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("JobRepository.FindByID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("JobRepository.FindByID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return job, nil
+}
+
+// ListByUser returns the user's jobs matching f, newest first.
+func (r *JobRepository) ListByUser(ctx context.Context, userID int64, f model.JobFilter) ([]*model.Job, error) {
+	start := time.Now()
+	query := "SELECT " + jobColumns + " FROM jobs WHERE user_id = $1 AND ($2 = '' OR kind = $2) AND ... ORDER BY id DESC LIMIT $5"
+
+	rows, err := r.db.Query(ctx,
+		`SELECT `+jobColumns+`
+		 FROM jobs
+		 WHERE user_id = $1
+		   AND ($2 = '' OR kind = $2)
+		   AND ($3 = '' OR status = $3)
+		   AND ($4 = 0 OR id < $4)
+		 ORDER BY id DESC
+		 LIMIT $5`,
+		userID, f.Kind, f.Status, f.BeforeID, f.Limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("JobRepository.ListByUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("JobRepository.ListByUser: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("JobRepository.ListByUser: %w", classify(err))
+		}
+		out = append(out, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("JobRepository.ListByUser: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// exec runs a single-row state update and logs it.
+func (r *JobRepository) exec(ctx context.Context, op, query string, args ...interface{}) error {
+	start := time.Now()
+
+	result, err := r.db.Exec(ctx, query, args...)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("%s: %s", op, err.Error()),
+		})
+		return fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const mountColumns = "id, user_id, parent_id, name, kind, location, created_by, created_at"
+
+// ErrMountNotFound is returned when a mount does not exist or is not visible to the caller.
+var ErrMountNotFound = notFoundError("mount not found")
+
+type MountRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMountRepository(db *pgxpool.Pool) *MountRepository {
+	return &MountRepository{db: db}
+}
+
+func scanMount(row pgx.Row) (*model.Mount, error) {
+	m := &model.Mount{}
+	if err := row.Scan(&m.ID, &m.UserID, &m.ParentID, &m.Name, &m.Kind, &m.Location, &m.CreatedBy, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Create inserts a mount. The parent folder, if any, must belong to m.UserID.
+func (r *MountRepository) Create(ctx context.Context, m *model.Mount) (*model.Mount, error) {
+	start := time.Now()
+	query := "INSERT INTO mounts (user_id, parent_id, name, kind, location, created_by) SELECT ... WHERE parent is owned by user_id RETURNING ..."
+
+	created, err := scanMount(r.db.QueryRow(ctx,
+		`INSERT INTO mounts (user_id, parent_id, name, kind, location, created_by)
+		 SELECT $1, $2, $3, $4, $5, $6
+		 WHERE $2::bigint IS NULL OR EXISTS (SELECT 1 FROM folders WHERE id = $2 AND user_id = $1)
+		 RETURNING `+mountColumns,
+		m.UserID, m.ParentID, m.Name, m.Kind, m.Location, m.CreatedBy,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("MountRepository.Create: %w", ErrFolderNotFound)
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("MountRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("MountRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return created, nil
+}
+
+// List returns the mounts of userID, or of every user when userID is 0 (admin view).
+func (r *MountRepository) List(ctx context.Context, userID int64) ([]*model.Mount, error) {
+	start := time.Now()
+	query := "SELECT " + mountColumns + " FROM mounts WHERE ($1 = 0 OR user_id = $1) ORDER BY user_id, name, id"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("MountRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("MountRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	mounts := []*model.Mount{}
+	for rows.Next() {
+		m, err := scanMount(rows)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(mounts)),
+	})
+	return mounts, rows.Err()
+}
+
+// FindByID fetches a mount within scope. Returns ErrMountNotFound if there is none.
+func (r *MountRepository) FindByID(ctx context.Context, scope Scope, mountID int64) (*model.Mount, error) {
+	owner, err := scope.owner("MountRepository.FindByID")
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	query := "SELECT " + mountColumns + " FROM mounts WHERE id = $1 AND ($2 = 0 OR user_id = $2)"
+
+	m, err := scanMount(r.db.QueryRow(ctx, query, mountID, owner))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, fmt.Errorf("MountRepository.FindByID: %w", ErrMountNotFound)
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("MountRepository.FindByID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("MountRepository.FindByID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return m, nil
+}
+
+// Delete removes a mount. Only the mount record goes; the external content is untouched.
+func (r *MountRepository) Delete(ctx context.Context, mountID int64) error {
+	start := time.Now()
+	query := "DELETE FROM mounts WHERE id = $1"
+
+	result, err := r.db.Exec(ctx, query, mountID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("MountRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("MountRepository.Delete: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("MountRepository.Delete: %w", ErrMountNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const notificationColumns = "id, user_id, kind, data, read_at, created_at"
+
+// expiryNoticeInsert turns the rows of a "notices" CTE (id, user_id, name, due_at) into
+// expiry notifications. $1 is the notification kind, $2 the item type.
+const expiryNoticeInsert = `INSERT INTO notifications (user_id, kind, data)
+	 SELECT user_id, $1, jsonb_build_object('item_type', $2::text, 'item_id', id, 'name', name, 'expires_at', due_at)
+	 FROM notices`
+
+// ErrNotificationNotFound is returned when a notification does not exist or is not owned by the user.
+var ErrNotificationNotFound = notFoundError("notification not found or unauthorized")
+
+type NotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+func scanNotification(row pgx.Row) (*model.Notification, error) {
+	n := &model.Notification{}
+	var data []byte
+	if err := row.Scan(&n.ID, &n.UserID, &n.Kind, &data, &n.ReadAt, &n.CreatedAt); err != nil {
+		return nil, err
+	}
+	n.Data = data
+	return n, nil
+}
+
+// Create adds an unread notification for userID. data is stored as JSON.
+func (r *NotificationRepository) Create(ctx context.Context, userID int64, kind string, data interface{}) (*model.Notification, error) {
+	start := time.Now()
+	query := "INSERT INTO notifications (user_id, kind, data) VALUES ($1, $2, $3) RETURNING ..."
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("NotificationRepository.Create marshal data: %w", err)
+	}
+
+	n, err := scanNotification(r.db.QueryRow(ctx,
+		`INSERT INTO notifications (user_id, kind, data)
+		 VALUES ($1, $2, $3)
+		 RETURNING `+notificationColumns,
+		userID, kind, raw,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("NotificationRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("NotificationRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return n, nil
+}
+
+// ListByUser returns a user's most recent notifications, newest first.
+func (r *NotificationRepository) ListByUser(ctx context.Context, userID int64, unreadOnly bool, limit int) ([]*model.Notification, error) {
+	start := time.Now()
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL) ORDER BY created_at DESC, id DESC LIMIT $3"
+
+	rows, err := r.db.Query(ctx, query, userID, unreadOnly, limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("NotificationRepository.ListByUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("NotificationRepository.ListByUser: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// MarkRead marks one notification as read. Marking an already read one is a no-op.
+func (r *NotificationRepository) MarkRead(ctx context.Context, notificationID, userID int64) error {
+	start := time.Now()
+	query := "UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $1 AND user_id = $2"
+
+	result, err := r.db.Exec(ctx, query, notificationID, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("NotificationRepository.MarkRead: %s", err.Error()),
+		})
+		return fmt.Errorf("NotificationRepository.MarkRead: %w", classify(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotificationNotFound
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// MarkAllRead marks every unread notification of a user as read and returns how many changed.
+func (r *NotificationRepository) MarkAllRead(ctx context.Context, userID int64) (int64, error) {
+	start := time.Now()
+	query := "UPDATE notifications SET read_at = NOW() WHERE user_id = $1 AND read_at IS NULL"
+
+	result, err := r.db.Exec(ctx, query, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("NotificationRepository.MarkAllRead: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("NotificationRepository.MarkAllRead: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// ClaimUnpushed marks up to limit notifications as pushed and returns them, oldest first.
+// Each notification is claimed once, so a push that then fails is not retried.
+func (r *NotificationRepository) ClaimUnpushed(ctx context.Context, limit int) ([]*model.Notification, error) {
+	start := time.Now()
+	query := "UPDATE notifications SET pushed_at = NOW() WHERE id IN (SELECT id FROM notifications WHERE pushed_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED) RETURNING ..."
+
+	rows, err := r.db.Query(ctx,
+		`UPDATE notifications SET pushed_at = NOW()
+		 WHERE id IN (
+		     SELECT id FROM notifications WHERE pushed_at IS NULL
+		     ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+notificationColumns,
+		limit,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("NotificationRepository.ClaimUnpushed: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("NotificationRepository.ClaimUnpushed: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("NotificationRepository.ClaimUnpushed: %w", classify(err))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const planColumns = "id, name, description, quota_bytes, max_file_bytes, monthly_egress_bytes, features, is_default, created_at, updated_at"
+
+// ErrPlanNotFound is returned when a plan does not exist.
+var ErrPlanNotFound = notFoundError("plan not found")
+
+// PlanChanges are the fields Update may change; nil leaves a field as it is. A zero
+// QuotaBytes, MaxFileBytes or EgressBytes removes that limit, and an empty Description clears it.
+// Features are merged into the plan's, so only the flags given change.
+type PlanChanges struct {
+	Name         *string
+	Description  *string
+	QuotaBytes   *int64
+	MaxFileBytes *int64
+	EgressBytes  *int64
+	Features     map[string]bool
+	IsDefault    *bool
+}
+
+type PlanRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPlanRepository(db *pgxpool.Pool) *PlanRepository {
+	return &PlanRepository{db: db}
+}
+
+func scanPlan(row pgx.Row) (*model.Plan, error) {
+	p := &model.Plan{}
+	if err := row.Scan(&p.ID, &p.Name, &p.Description, &p.QuotaBytes, &p.MaxFileBytes, &p.EgressBytes, &p.Features, &p.IsDefault, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// clearDefault unmarks the current default plan so another can take its place.
+func clearDefault(ctx context.Context, tx pgx.Tx, exceptID int64) error {
+	_, err := tx.Exec(ctx, "UPDATE plans SET is_default = FALSE, updated_at = NOW() WHERE is_default AND id <> $1", exceptID)
+	return err
+}
+
+// Create inserts p. If p is the default, the previous default plan stops being one.
+// Returns ErrConflict if the name is taken.
+func (r *PlanRepository) Create(ctx context.Context, p *model.Plan) (*model.Plan, error) {
+	start := time.Now()
+	query := "[default: UPDATE plans SET is_default = FALSE WHERE is_default]; INSERT INTO plans (name, description, quota_bytes, max_file_bytes, monthly_egress_bytes, features, is_default) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ..."
+
+	var plan *model.Plan
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if p.IsDefault {
+			if err := clearDefault(ctx, tx, 0); err != nil {
+				return err
+			}
+		}
+		var err error
+		plan, err = scanPlan(tx.QueryRow(ctx,
+			`INSERT INTO plans (name, description, quota_bytes, max_file_bytes, monthly_egress_bytes, features, is_default)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 RETURNING `+planColumns,
+			p.Name, p.Description, p.QuotaBytes, p.MaxFileBytes, p.EgressBytes, p.Features, p.IsDefault,
+		))
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("PlanRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PlanRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return plan, nil
+}
+
+// List returns every plan by name.
+func (r *PlanRepository) List(ctx context.Context) ([]*model.Plan, error) {
+	start := time.Now()
+	query := "SELECT " + planColumns + " FROM plans ORDER BY name"
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("PlanRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PlanRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	plans := []*model.Plan{}
+	for rows.Next() {
+		p, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(plans)),
+	})
+	return plans, rows.Err()
+}
+
+// FindByID returns a plan, or ErrPlanNotFound if there is no such plan.
+func (r *PlanRepository) FindByID(ctx context.Context, planID int64) (*model.Plan, error) {
+	start := time.Now()
+	query := "SELECT " + planColumns + " FROM plans WHERE id = $1"
+
+	plan, err := scanPlan(r.db.QueryRow(ctx, query, planID))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("PlanRepository.FindByID: %w", ErrPlanNotFound)
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("PlanRepository.FindByID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PlanRepository.FindByID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return plan, nil
+}
+
+// ForUser returns the plan userID is held to: their assigned plan, else the default
+// plan, else nil (no limits).
+func (r *PlanRepository) ForUser(ctx context.Context, userID int64) (*model.Plan, error) {
+	start := time.Now()
+	query := "SELECT " + planColumns + " FROM plans WHERE id = COALESCE((SELECT plan_id FROM users WHERE id = $1), (SELECT id FROM plans WHERE is_default))"
+
+	plan, err := scanPlan(r.db.QueryRow(ctx, query, userID))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("PlanRepository.ForUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PlanRepository.ForUser: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return plan, nil
+}
+
+// Update applies changes to a plan. Making it the default unmarks the previous
+// default. Returns ErrPlanNotFound if there is no such plan and ErrConflict if the
+// new name is taken.
+func (r *PlanRepository) Update(ctx context.Context, planID int64, changes PlanChanges) (*model.Plan, error) {
+	start := time.Now()
+	query := "[default: UPDATE plans SET is_default = FALSE WHERE is_default AND id <> $1]; UPDATE plans SET name = COALESCE($2, name), ... WHERE id = $1 RETURNING ..."
+
+	var plan *model.Plan
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if changes.IsDefault != nil && *changes.IsDefault {
+			if err := clearDefault(ctx, tx, planID); err != nil {
+				return err
+			}
+		}
+		var err error
+		plan, err = scanPlan(tx.QueryRow(ctx,
+			`UPDATE plans
+			 SET name                 = COALESCE($2, name),
+			     description          = CASE WHEN $3::TEXT IS NULL THEN description ELSE NULLIF($3, '') END,
+			     quota_bytes          = CASE WHEN $4::BIGINT IS NULL THEN quota_bytes ELSE NULLIF($4, 0) END,
+			     max_file_bytes       = CASE WHEN $5::BIGINT IS NULL THEN max_file_bytes ELSE NULLIF($5, 0) END,
+			     monthly_egress_bytes = CASE WHEN $6::BIGINT IS NULL THEN monthly_egress_bytes ELSE NULLIF($6, 0) END,
+			     features             = CASE WHEN $7::JSONB IS NULL THEN features ELSE features || $7 END,
+			     is_default           = COALESCE($8, is_default),
+			     updated_at           = NOW()
+			 WHERE id = $1
+			 RETURNING `+planColumns,
+			planID, changes.Name, changes.Description, changes.QuotaBytes, changes.MaxFileBytes, changes.EgressBytes, changes.Features, changes.IsDefault,
+		))
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("PlanRepository.Update: %w", ErrPlanNotFound)
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("PlanRepository.Update: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PlanRepository.Update: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return plan, nil
+}
+
+// Delete removes a plan. Its users fall back to the default plan.
+func (r *PlanRepository) Delete(ctx context.Context, planID int64) error {
+	start := time.Now()
+	query := "DELETE FROM plans WHERE id = $1"
+
+	result, err := r.db.Exec(ctx, query, planID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("PlanRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("PlanRepository.Delete: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	if result.RowsAffected() == 0 {
+		return ErrPlanNotFound
+	}
+	return nil
+}
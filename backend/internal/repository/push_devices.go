@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const pushDeviceColumns = "id, user_id, platform, token, p256dh, auth_secret, user_agent, created_at, last_used_at"
+
+// ErrPushDeviceNotFound is returned when a push device does not exist or is not owned by the user.
+var ErrPushDeviceNotFound = notFoundError("push device not found or unauthorized")
+
+type PushDeviceRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPushDeviceRepository(db *pgxpool.Pool) *PushDeviceRepository {
+	return &PushDeviceRepository{db: db}
+}
+
+func scanPushDevice(row pgx.Row) (*model.PushDevice, error) {
+	d := &model.PushDevice{}
+	if err := row.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.P256DH, &d.Auth, &d.UserAgent, &d.CreatedAt, &d.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Register adds a device for userID. Tokens are unique: registering a known token again
+// refreshes its keys and moves it to userID, since the device has signed in as them.
+func (r *PushDeviceRepository) Register(ctx context.Context, userID int64, platform, token string, p256dh, authSecret *string, userAgent string) (*model.PushDevice, error) {
+	start := time.Now()
+	query := "INSERT INTO push_devices (...) VALUES (...) ON CONFLICT (token) DO UPDATE SET ... RETURNING ..."
+
+	d, err := scanPushDevice(r.db.QueryRow(ctx,
+		`INSERT INTO push_devices (user_id, platform, token, p256dh, auth_secret, user_agent)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (token) DO UPDATE
+		 SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, p256dh = EXCLUDED.p256dh,
+		     auth_secret = EXCLUDED.auth_secret, user_agent = EXCLUDED.user_agent
+		 RETURNING `+pushDeviceColumns,
+		userID, platform, token, p256dh, authSecret, userAgent,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("PushDeviceRepository.Register: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("PushDeviceRepository.Register: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return d, nil
+}
+
+// ListByUser returns a user's devices, newest first.
+func (r *PushDeviceRepository) ListByUser(ctx context.Context, userID int64) ([]*model.PushDevice, error) {
+	return r.list(ctx, "PushDeviceRepository.ListByUser",
+		"SELECT "+pushDeviceColumns+" FROM push_devices WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+}
+
+// ListByUsers returns the devices of all the given users.
+func (r *PushDeviceRepository) ListByUsers(ctx context.Context, userIDs []int64) ([]*model.PushDevice, error) {
+	return r.list(ctx, "PushDeviceRepository.ListByUsers",
+		"SELECT "+pushDeviceColumns+" FROM push_devices WHERE user_id = ANY($1)", userIDs)
+}
+
+func (r *PushDeviceRepository) list(ctx context.Context, method, query string, args ...interface{}) ([]*model.PushDevice, error) {
+	start := time.Now()
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("%s: %s", method, err.Error()),
+		})
+		return nil, fmt.Errorf("%s: %w", method, classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.PushDevice
+	for rows.Next() {
+		d, err := scanPushDevice(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// Delete removes one of a user's devices.
+func (r *PushDeviceRepository) Delete(ctx context.Context, deviceID, userID int64) error {
+	start := time.Now()
+	query := "DELETE FROM push_devices WHERE id = $1 AND user_id = $2"
+
+	result, err := r.db.Exec(ctx, query, deviceID, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("PushDeviceRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("PushDeviceRepository.Delete: %w", classify(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrPushDeviceNotFound
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// DeleteByIDs removes devices the push service reported as no longer registered.
+func (r *PushDeviceRepository) DeleteByIDs(ctx context.Context, ids []int64) error {
+	start := time.Now()
+	query := "DELETE FROM push_devices WHERE id = ANY($1)"
+
+	result, err := r.db.Exec(ctx, query, ids)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("PushDeviceRepository.DeleteByIDs: %s", err.Error()),
+		})
+		return fmt.Errorf("PushDeviceRepository.DeleteByIDs: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// MarkUsed records a successful push to the given devices.
+func (r *PushDeviceRepository) MarkUsed(ctx context.Context, ids []int64) error {
+	start := time.Now()
+	query := "UPDATE push_devices SET last_used_at = NOW() WHERE id = ANY($1)"
+
+	result, err := r.db.Exec(ctx, query, ids)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("PushDeviceRepository.MarkUsed: %s", err.Error()),
+		})
+		return fmt.Errorf("PushDeviceRepository.MarkUsed: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// ErrQuickAccessMismatch is returned by Reorder when the given IDs are not exactly
+// the user's pinned folders.
+var ErrQuickAccessMismatch = conflictError("folder ids do not match the pinned folders")
+
+type QuickAccessRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewQuickAccessRepository(db *pgxpool.Pool) *QuickAccessRepository {
+	return &QuickAccessRepository{db: db}
+}
+
+// List returns the user's pinned folders in their chosen order.
+func (r *QuickAccessRepository) List(ctx context.Context, userID int64) ([]*model.QuickAccessItem, error) {
+	start := time.Now()
+	query := "SELECT " + folderColumns + ", q.position, q.pinned_at FROM folders JOIN (SELECT folder_id, position, pinned_at FROM quick_access_folders WHERE user_id = $1) q ON q.folder_id = id ORDER BY q.position ASC"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("QuickAccessRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("QuickAccessRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var items []*model.QuickAccessItem
+	for rows.Next() {
+		item := &model.QuickAccessItem{}
+		if item.Folder, err = scanFolder(rows, &item.Position, &item.PinnedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(items)),
+	})
+	return items, nil
+}
+
+// Pin appends a folder to the end of the user's quick-access list. Pinning an
+// already pinned folder keeps its position. The caller checks folder ownership.
+func (r *QuickAccessRepository) Pin(ctx context.Context, userID, folderID int64) error {
+	start := time.Now()
+	query := "INSERT INTO quick_access_folders (user_id, folder_id, position) SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM quick_access_folders WHERE user_id = $1 ON CONFLICT DO NOTHING"
+
+	result, err := r.db.Exec(ctx, query, userID, folderID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("QuickAccessRepository.Pin: %s", err.Error()),
+		})
+		return fmt.Errorf("QuickAccessRepository.Pin: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// Unpin removes a folder from the user's quick-access list. Returns false if it was not pinned.
+func (r *QuickAccessRepository) Unpin(ctx context.Context, userID, folderID int64) (bool, error) {
+	start := time.Now()
+	query := "DELETE FROM quick_access_folders WHERE user_id = $1 AND folder_id = $2"
+
+	result, err := r.db.Exec(ctx, query, userID, folderID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("QuickAccessRepository.Unpin: %s", err.Error()),
+		})
+		return false, fmt.Errorf("QuickAccessRepository.Unpin: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected() > 0, nil
+}
+
+// Reorder sets the quick-access order to folderIDs, which must list every pinned
+// folder exactly once (ErrQuickAccessMismatch otherwise).
+func (r *QuickAccessRepository) Reorder(ctx context.Context, userID int64, folderIDs []int64) error {
+	start := time.Now()
+	query := "UPDATE quick_access_folders q SET position = o.ord - 1 FROM unnest($2::bigint[]) WITH ORDINALITY o(folder_id, ord) WHERE q.user_id = $1 AND q.folder_id = o.folder_id"
+
+	var affected int64
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var pinned int
+		if err := tx.QueryRow(ctx,
+			"SELECT COUNT(*) FROM (SELECT 1 FROM quick_access_folders WHERE user_id = $1 FOR UPDATE) pinned", userID,
+		).Scan(&pinned); err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(ctx, query, userID, folderIDs)
+		if err != nil {
+			return err
+		}
+		affected = result.RowsAffected()
+		// Every pinned row updated once, and nothing else in the list: the sets match.
+		if affected != int64(pinned) || len(folderIDs) != pinned {
+			return ErrQuickAccessMismatch
+		}
+		return nil
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, ErrQuickAccessMismatch) {
+			return err
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("QuickAccessRepository.Reorder: %s", err.Error()),
+		})
+		return fmt.Errorf("QuickAccessRepository.Reorder: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: affected,
+	})
+	return nil
+}
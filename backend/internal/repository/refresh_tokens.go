@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// Refresh token failures. ErrRefreshTokenReused means a token that was already rotated
+// or revoked came back: its family has been revoked.
+var (
+	ErrRefreshTokenInvalid = notFoundError("refresh token unknown or expired")
+	ErrRefreshTokenReused  = &kindError{msg: "refresh token reused", kind: ErrForbidden}
+)
+
+const refreshTokenColumns = "id, family_id, user_id, ip_address, user_agent, created_at, expires_at, rotated_at, revoked_at"
+
+type RefreshTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefreshTokenRepository(db *pgxpool.Pool) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func scanRefreshToken(row pgx.Row) (*model.RefreshToken, error) {
+	t := &model.RefreshToken{}
+	if err := row.Scan(&t.ID, &t.FamilyID, &t.UserID, &t.IPAddress, &t.UserAgent, &t.CreatedAt, &t.ExpiresAt, &t.RotatedAt, &t.RevokedAt); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// insertRefreshToken stores a token in family familyID; 0 starts a new family.
+func insertRefreshToken(ctx context.Context, q pgx.Tx, tokenHash []byte, familyID, userID int64, ipAddress, userAgent string, expiresAt time.Time) (*model.RefreshToken, error) {
+	return scanRefreshToken(q.QueryRow(ctx,
+		`WITH n AS (SELECT nextval(pg_get_serial_sequence('refresh_tokens', 'id')) AS id)
+		 INSERT INTO refresh_tokens (id, token_hash, family_id, user_id, ip_address, user_agent, expires_at)
+		 SELECT n.id, $1, COALESCE(NULLIF($2::BIGINT, 0), n.id), $3, $4, $5, $6 FROM n
+		 RETURNING `+refreshTokenColumns,
+		tokenHash, familyID, userID, ipAddress, userAgent, expiresAt,
+	))
+}
+
+// Create stores the first refresh token of a login for userID, keyed by its hash.
+func (r *RefreshTokenRepository) Create(ctx context.Context, tokenHash []byte, userID int64, ipAddress, userAgent string, expiresAt time.Time) (*model.RefreshToken, error) {
+	start := time.Now()
+	query := "INSERT INTO refresh_tokens (id, token_hash, family_id, user_id, ip_address, user_agent, expires_at) SELECT nextval(...), $1, <new id>, ... RETURNING ..."
+
+	var t *model.RefreshToken
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		t, err = insertRefreshToken(ctx, tx, tokenHash, 0, userID, ipAddress, userAgent, expiresAt)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("RefreshTokenRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("RefreshTokenRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return t, nil
+}
+
+// Rotate trades the token with hash oldHash for a new one with hash newHash in the
+// same family and returns the new token. It returns ErrRefreshTokenInvalid if the
+// old token is unknown or expired. If it was already rotated or revoked, the whole
+// family is revoked and Rotate returns the old token with ErrRefreshTokenReused.
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, oldHash, newHash []byte, ipAddress, userAgent string, expiresAt time.Time) (*model.RefreshToken, error) {
+	start := time.Now()
+	query := "SELECT ... FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE; UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1; INSERT INTO refresh_tokens ... [reused: UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL]"
+
+	var old, next *model.RefreshToken
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		old, err = scanRefreshToken(tx.QueryRow(ctx,
+			"SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE", oldHash))
+		if err != nil {
+			return err
+		}
+		if old.RotatedAt != nil || old.RevokedAt != nil {
+			// Committed with the transaction, unlike an error return.
+			_, err := tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", old.FamilyID)
+			return err
+		}
+		if !old.ExpiresAt.After(time.Now()) {
+			return pgx.ErrNoRows
+		}
+		if _, err := tx.Exec(ctx, "UPDATE refresh_tokens SET rotated_at = NOW() WHERE id = $1", old.ID); err != nil {
+			return err
+		}
+		next, err = insertRefreshToken(ctx, tx, newHash, old.FamilyID, old.UserID, ipAddress, userAgent, expiresAt)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("RefreshTokenRepository.Rotate: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("RefreshTokenRepository.Rotate: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 2,
+	})
+	if next == nil {
+		return old, ErrRefreshTokenReused
+	}
+	return next, nil
+}
+
+// RevokeFamily revokes the token with the given hash and every other token of its
+// login, returning how many were revoked. Unknown tokens are not an error.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, tokenHash []byte) (int64, error) {
+	start := time.Now()
+	query := "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1) AND revoked_at IS NULL"
+
+	result, err := r.db.Exec(ctx, query, tokenHash)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("RefreshTokenRepository.RevokeFamily: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("RefreshTokenRepository.RevokeFamily: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// DeleteExpired removes refresh tokens that have expired and returns how many were removed.
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM refresh_tokens WHERE expires_at < NOW()"
+
+	result, err := r.db.Exec(ctx, query)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("RefreshTokenRepository.DeleteExpired: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("RefreshTokenRepository.DeleteExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const s3DeletionColumns = "id, s3_key, attempts, last_error, next_attempt_at, dead_at, created_at"
+
+// DeletionOutcome reports what happened to a queued S3 deletion.
+type DeletionOutcome int
+
+const (
+	DeletionNone       DeletionOutcome = iota // queue empty (nothing due)
+	DeletionDone                              // object deleted
+	DeletionSkipped                           // key is referenced again; dropped without deleting
+	DeletionRetrying                          // failed, rescheduled
+	DeletionDeadLetter                        // failed for the last time
+)
+
+type S3DeletionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewS3DeletionRepository(db *pgxpool.Pool) *S3DeletionRepository {
+	return &S3DeletionRepository{db: db}
+}
+
+func scanS3Deletion(row pgx.Row) (*model.S3Deletion, error) {
+	d := &model.S3Deletion{}
+	if err := row.Scan(&d.ID, &d.S3Key, &d.Attempts, &d.LastError, &d.NextAttemptAt, &d.DeadAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ProcessNext claims the next due deletion and runs deleteObject for it, all in one
+// transaction holding the row lock. A key that a block references again is dropped
+// without touching S3. On failure the row is rescheduled after backoff(attempts), or
+// dead-lettered once maxAttempts is reached.
+func (r *S3DeletionRepository) ProcessNext(
+	ctx context.Context,
+	maxAttempts int,
+	backoff func(attempts int) time.Duration,
+	deleteObject func(ctx context.Context, key string) error,
+) (*model.S3Deletion, DeletionOutcome, error) {
+	start := time.Now()
+	query := "SELECT ... FROM s3_deletions WHERE dead_at IS NULL AND next_attempt_at <= NOW() ORDER BY next_attempt_at LIMIT 1 FOR UPDATE SKIP LOCKED"
+
+	var d *model.S3Deletion
+	outcome := DeletionNone
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		d, err = scanS3Deletion(tx.QueryRow(ctx,
+			`SELECT `+s3DeletionColumns+` FROM s3_deletions
+			 WHERE dead_at IS NULL AND next_attempt_at <= NOW()
+			 ORDER BY next_attempt_at LIMIT 1
+			 FOR UPDATE SKIP LOCKED`))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				d = nil
+				return nil
+			}
+			return err
+		}
+
+		var referenced bool
+		if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM blocks WHERE s3_key = $1)", d.S3Key).Scan(&referenced); err != nil {
+			return err
+		}
+		if referenced {
+			outcome = DeletionSkipped
+			_, err := tx.Exec(ctx, "DELETE FROM s3_deletions WHERE id = $1", d.ID)
+			return err
+		}
+
+		if delErr := deleteObject(ctx, d.S3Key); delErr != nil {
+			d.Attempts++
+			msg := delErr.Error()
+			d.LastError = &msg
+			if d.Attempts >= maxAttempts {
+				outcome = DeletionDeadLetter
+				_, err := tx.Exec(ctx,
+					"UPDATE s3_deletions SET attempts = $2, last_error = $3, dead_at = NOW() WHERE id = $1",
+					d.ID, d.Attempts, msg)
+				return err
+			}
+			outcome = DeletionRetrying
+			_, err := tx.Exec(ctx,
+				"UPDATE s3_deletions SET attempts = $2, last_error = $3, next_attempt_at = NOW() + $4::interval WHERE id = $1",
+				d.ID, d.Attempts, msg, backoff(d.Attempts).String())
+			return err
+		}
+
+		outcome = DeletionDone
+		_, err = tx.Exec(ctx, "DELETE FROM s3_deletions WHERE id = $1", d.ID)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("S3DeletionRepository.ProcessNext: %s", err.Error()),
+		})
+		return nil, DeletionNone, fmt.Errorf("S3DeletionRepository.ProcessNext: %w", classify(err))
+	}
+
+	var affected int64
+	if d != nil {
+		affected = 1
+	}
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: affected,
+	})
+	return d, outcome, nil
+}
+
+// PreviewDue reports how many queued deletions are due, i.e. how many objects the next
+// deletion runs would remove from S3. Their sizes are not known any more.
+func (r *S3DeletionRepository) PreviewDue(ctx context.Context) (*model.CleanupPreview, error) {
+	start := time.Now()
+	query := "SELECT COUNT(*) FROM s3_deletions WHERE dead_at IS NULL AND next_attempt_at <= NOW()"
+
+	p := &model.CleanupPreview{}
+	err := r.db.QueryRow(ctx, query).Scan(&p.Items)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("S3DeletionRepository.PreviewDue: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("S3DeletionRepository.PreviewDue: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return p, nil
+}
+
+// ListDead returns dead-lettered deletions, oldest first.
+func (r *S3DeletionRepository) ListDead(ctx context.Context) ([]*model.S3Deletion, error) {
+	start := time.Now()
+	query := "SELECT " + s3DeletionColumns + " FROM s3_deletions WHERE dead_at IS NOT NULL ORDER BY dead_at ASC"
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("S3DeletionRepository.ListDead: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("S3DeletionRepository.ListDead: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.S3Deletion
+	for rows.Next() {
+		d, err := scanS3Deletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// RetryDead puts all dead-lettered deletions back in the queue with a fresh attempt budget.
+func (r *S3DeletionRepository) RetryDead(ctx context.Context) (int64, error) {
+	start := time.Now()
+	query := "UPDATE s3_deletions SET dead_at = NULL, attempts = 0, next_attempt_at = NOW() WHERE dead_at IS NOT NULL"
+
+	result, err := r.db.Exec(ctx, query)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("S3DeletionRepository.RetryDead: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("S3DeletionRepository.RetryDead: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
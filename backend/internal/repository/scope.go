@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingScope is returned when a scoped method is called with the zero Scope or
+// an invalid user ID, instead of silently running unscoped.
+var ErrMissingScope = errors.New("repository call is missing a tenancy scope")
+
+// Scope says whose rows a repository call may touch. Methods that look up user-owned
+// rows by ID alone take a Scope, so a caller cannot forget the ownership check: it has
+// to pass either ForUser or an explicit, greppable Unscoped. New methods on user-owned
+// tables should take a Scope (or a userID) the same way.
+type Scope struct {
+	userID int64  // owner the rows must belong to; 0 when unscoped
+	reason string // why ownership does not apply, for Unscoped
+}
+
+// ForUser limits a call to rows owned by userID.
+func ForUser(userID int64) Scope {
+	return Scope{userID: userID}
+}
+
+// Unscoped lets a call see rows of any user. reason documents why that is safe (e.g.
+// "share link: owner checked by token", "admin") and must not be empty.
+func Unscoped(reason string) Scope {
+	return Scope{reason: reason}
+}
+
+// owner returns the user ID to filter on (0 = any user), or ErrMissingScope if the
+// scope was not built with ForUser or Unscoped.
+func (s Scope) owner(method string) (int64, error) {
+	if s.userID > 0 || (s.userID == 0 && s.reason != "") {
+		return s.userID, nil
+	}
+	return 0, fmt.Errorf("%s: %w", method, ErrMissingScope)
+}
+
+// String describes the scope for logs.
+func (s Scope) String() string {
+	if s.userID > 0 {
+		return fmt.Sprintf("user %d", s.userID)
+	}
+	if s.reason != "" {
+		return "unscoped (" + s.reason + ")"
+	}
+	return "missing"
+}
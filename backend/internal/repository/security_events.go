@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+type SecurityEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSecurityEventRepository(db *pgxpool.Pool) *SecurityEventRepository {
+	return &SecurityEventRepository{db: db}
+}
+
+// Insert appends events to the security log in one COPY.
+func (r *SecurityEventRepository) Insert(ctx context.Context, events []*model.SecurityEvent) error {
+	start := time.Now()
+	query := "COPY security_events (type, user_id, ip_address, user_agent, method, path, request_id, details, created_at) FROM STDIN"
+
+	rows := make([][]interface{}, len(events))
+	for i, e := range events {
+		details := e.Details
+		if details == nil {
+			details = []byte("{}")
+		}
+		rows[i] = []interface{}{e.Type, e.UserID, e.IPAddress, e.UserAgent, e.Method, e.Path, e.RequestID, string(details), e.CreatedAt}
+	}
+	n, err := r.db.CopyFrom(ctx, pgx.Identifier{"security_events"},
+		[]string{"type", "user_id", "ip_address", "user_agent", "method", "path", "request_id", "details", "created_at"},
+		pgx.CopyFromRows(rows))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("SecurityEventRepository.Insert: %s", err.Error()),
+		})
+		return fmt.Errorf("SecurityEventRepository.Insert: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: n,
+	})
+	return nil
+}
+
+// List returns the events matching f, newest first.
+func (r *SecurityEventRepository) List(ctx context.Context, f model.SecurityEventFilter) ([]*model.SecurityEvent, error) {
+	start := time.Now()
+	query := "SELECT ... FROM security_events WHERE ($1 = '' OR type = $1) AND ... ORDER BY id DESC LIMIT $7"
+
+	var since, until *time.Time
+	if !f.Since.IsZero() {
+		since = &f.Since
+	}
+	if !f.Until.IsZero() {
+		until = &f.Until
+	}
+	rows, err := r.db.Query(ctx,
+		`SELECT id, type, user_id, ip_address, user_agent, method, path, request_id, details, created_at
+		 FROM security_events
+		 WHERE ($1 = '' OR type = $1)
+		   AND ($2 = 0 OR user_id = $2)
+		   AND CASE WHEN $3 = '' THEN TRUE
+		            WHEN $3 NOT LIKE '%/%' THEN ip_address = $3
+		            WHEN ip_address ~ '^[0-9A-Fa-f:.]+$' THEN ip_address::inet <<= $3::inet
+		            ELSE FALSE END
+		   AND ($4::timestamptz IS NULL OR created_at >= $4)
+		   AND ($5::timestamptz IS NULL OR created_at < $5)
+		   AND ($6 = 0 OR id < $6)
+		 ORDER BY id DESC
+		 LIMIT $7`,
+		f.Type, f.UserID, f.IPAddress, since, until, f.BeforeID, f.Limit)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("SecurityEventRepository.List: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("SecurityEventRepository.List: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.SecurityEvent
+	for rows.Next() {
+		e := &model.SecurityEvent{}
+		if err := rows.Scan(&e.ID, &e.Type, &e.UserID, &e.IPAddress, &e.UserAgent, &e.Method, &e.Path, &e.RequestID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("SecurityEventRepository.List: %w", classify(err))
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("SecurityEventRepository.List: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// DeleteOlderThan removes events created before cutoff and returns how many were removed.
+func (r *SecurityEventRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM security_events WHERE created_at < $1"
+
+	result, err := r.db.Exec(ctx, query, cutoff)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("SecurityEventRepository.DeleteOlderThan: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("SecurityEventRepository.DeleteOlderThan: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
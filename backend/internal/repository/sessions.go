@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// sessionTouchInterval limits how often a session's last_seen_at is written back, so
+// busy clients do not turn every request into an UPDATE.
+const sessionTouchInterval = time.Minute
+
+type SessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create stores a new session for userID, keyed by the hash of its ID.
+func (r *SessionRepository) Create(ctx context.Context, tokenHash []byte, userID int64, ipAddress, userAgent string, expiresAt time.Time) (*model.Session, error) {
+	start := time.Now()
+	query := "INSERT INTO sessions (token_hash, user_id, ip_address, user_agent, expires_at) VALUES (...) RETURNING ..."
+
+	s := &model.Session{UserID: userID, IPAddress: ipAddress, UserAgent: userAgent, ExpiresAt: expiresAt}
+	err := r.db.QueryRow(ctx,
+		`INSERT INTO sessions (token_hash, user_id, ip_address, user_agent, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING id, created_at, last_seen_at`,
+		tokenHash, userID, ipAddress, userAgent, expiresAt,
+	).Scan(&s.ID, &s.CreatedAt, &s.LastSeenAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("SessionRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("SessionRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return s, nil
+}
+
+// FindActive returns the unexpired session with the given hash, or nil if there is
+// none, and records that it was seen.
+func (r *SessionRepository) FindActive(ctx context.Context, tokenHash []byte) (*model.Session, error) {
+	start := time.Now()
+	query := "WITH touched AS (UPDATE sessions SET last_seen_at = NOW() WHERE token_hash = $1 AND ...) SELECT ... FROM sessions s JOIN users u ..."
+
+	s := &model.Session{}
+	err := r.db.QueryRow(ctx,
+		`WITH touched AS (
+		     UPDATE sessions SET last_seen_at = NOW()
+		     WHERE token_hash = $1 AND expires_at > NOW() AND last_seen_at < NOW() - make_interval(secs => $2)
+		 )
+		 SELECT s.id, s.user_id, u.email, s.ip_address, s.user_agent, s.created_at, s.last_seen_at, s.expires_at
+		 FROM sessions s JOIN users u ON u.id = s.user_id
+		 WHERE s.token_hash = $1 AND s.expires_at > NOW()`,
+		tokenHash, sessionTouchInterval.Seconds(),
+	).Scan(&s.ID, &s.UserID, &s.Email, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("SessionRepository.FindActive: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("SessionRepository.FindActive: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return s, nil
+}
+
+// Delete ends the session with the given hash. Unknown sessions are not an error.
+func (r *SessionRepository) Delete(ctx context.Context, tokenHash []byte) error {
+	start := time.Now()
+	query := "DELETE FROM sessions WHERE token_hash = $1"
+
+	result, err := r.db.Exec(ctx, query, tokenHash)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("SessionRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("SessionRepository.Delete: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// DeleteExpired removes sessions that have expired and returns how many were removed.
+func (r *SessionRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM sessions WHERE expires_at < NOW()"
+
+	result, err := r.db.Exec(ctx, query)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("SessionRepository.DeleteExpired: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("SessionRepository.DeleteExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
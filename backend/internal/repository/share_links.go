@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -12,6 +13,11 @@ import (
 	"github.com/naratel/naratel-box/backend/internal/model"
 )
 
+const shareLinkColumns = "id, file_id, user_id, token, enabled, password_hash, expires_at, created_at, taken_down_at"
+
+// shareTokenPattern matches issued share tokens: 24 random bytes, hex-encoded.
+var shareTokenPattern = regexp.MustCompile(`^[0-9a-f]{48}$`)
+
 type ShareLinkRepository struct {
 	db *pgxpool.Pool
 }
@@ -20,18 +26,25 @@ func NewShareLinkRepository(db *pgxpool.Pool) *ShareLinkRepository {
 	return &ShareLinkRepository{db: db}
 }
 
-// Create inserts a new share link.
-func (r *ShareLinkRepository) Create(ctx context.Context, fileID, userID int64, token string, expiresAt *time.Time) (*model.ShareLink, error) {
+func scanShareLink(row pgx.Row) (*model.ShareLink, error) {
+	l := &model.ShareLink{}
+	if err := row.Scan(&l.ID, &l.FileID, &l.UserID, &l.Token, &l.Enabled, &l.PasswordHash, &l.ExpiresAt, &l.CreatedAt, &l.TakenDownAt); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Create inserts a new share link. passwordHash may be nil for links without a password.
+func (r *ShareLinkRepository) Create(ctx context.Context, fileID, userID int64, token string, expiresAt *time.Time, passwordHash *string) (*model.ShareLink, error) {
 	start := time.Now()
-	query := "INSERT INTO share_links (file_id, user_id, token, expires_at) VALUES ($1, $2, $3, $4) RETURNING ..."
+	query := "INSERT INTO share_links (file_id, user_id, token, expires_at, password_hash) VALUES ($1, $2, $3, $4, $5) RETURNING ..."
 
-	link := &model.ShareLink{}
-	err := r.db.QueryRow(ctx,
-		`INSERT INTO share_links (file_id, user_id, token, expires_at)
-		 VALUES ($1, $2, $3, $4)
-		 RETURNING id, file_id, user_id, token, expires_at, created_at`,
-		fileID, userID, token, expiresAt,
-	).Scan(&link.ID, &link.FileID, &link.UserID, &link.Token, &link.ExpiresAt, &link.CreatedAt)
+	link, err := scanShareLink(r.db.QueryRow(ctx,
+		`INSERT INTO share_links (file_id, user_id, token, expires_at, password_hash)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING `+shareLinkColumns,
+		fileID, userID, token, expiresAt, passwordHash,
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -39,7 +52,7 @@ func (r *ShareLinkRepository) Create(ctx context.Context, fileID, userID int64,
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("ShareLinkRepository.Create: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("ShareLinkRepository.Create: %w", err)
+		return nil, fmt.Errorf("ShareLinkRepository.Create: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -48,14 +61,19 @@ func (r *ShareLinkRepository) Create(ctx context.Context, fileID, userID int64,
 	return link, nil
 }
 
-// FindByToken returns a share link by its unique token.
+// FindByToken returns a share link by its unique token, noting whether its owner is deactivated.
 func (r *ShareLinkRepository) FindByToken(ctx context.Context, token string) (*model.ShareLink, error) {
+	// Tokens that cannot have been issued are turned away without a query.
+	if !shareTokenPattern.MatchString(token) {
+		return nil, nil
+	}
+
 	start := time.Now()
-	query := "SELECT id, file_id, user_id, token, expires_at, created_at FROM share_links WHERE token = $1"
+	query := "SELECT " + shareLinkColumns + ", (SELECT u.deactivated_at IS NOT NULL FROM users u WHERE u.id = share_links.user_id) FROM share_links WHERE token = $1"
 
 	link := &model.ShareLink{}
-	err := r.db.QueryRow(ctx, query, token,
-	).Scan(&link.ID, &link.FileID, &link.UserID, &link.Token, &link.ExpiresAt, &link.CreatedAt)
+	err := r.db.QueryRow(ctx, query, token).Scan(&link.ID, &link.FileID, &link.UserID, &link.Token, &link.Enabled,
+		&link.PasswordHash, &link.ExpiresAt, &link.CreatedAt, &link.TakenDownAt, &link.OwnerDeactivated)
 
 	duration := time.Since(start).Milliseconds()
 
@@ -69,7 +87,7 @@ func (r *ShareLinkRepository) FindByToken(ctx context.Context, token string) (*m
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ShareLinkRepository.FindByToken: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("ShareLinkRepository.FindByToken: %w", err)
+		return nil, fmt.Errorf("ShareLinkRepository.FindByToken: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -81,21 +99,21 @@ func (r *ShareLinkRepository) FindByToken(ctx context.Context, token string) (*m
 // FindByFileID returns share links for a file.
 func (r *ShareLinkRepository) FindByFileID(ctx context.Context, fileID, userID int64) ([]*model.ShareLink, error) {
 	start := time.Now()
-	query := "SELECT id, file_id, user_id, token, expires_at, created_at FROM share_links WHERE file_id = $1 AND user_id = $2 ORDER BY created_at DESC"
+	query := "SELECT " + shareLinkColumns + " FROM share_links WHERE file_id = $1 AND user_id = $2 ORDER BY created_at DESC"
 
 	rows, err := r.db.Query(ctx, query, fileID, userID)
 	if err != nil {
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ShareLinkRepository.FindByFileID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("ShareLinkRepository.FindByFileID: %w", err)
+		return nil, fmt.Errorf("ShareLinkRepository.FindByFileID: %w", classify(err))
 	}
 	defer rows.Close()
 
 	var links []*model.ShareLink
 	for rows.Next() {
-		l := &model.ShareLink{}
-		if err := rows.Scan(&l.ID, &l.FileID, &l.UserID, &l.Token, &l.ExpiresAt, &l.CreatedAt); err != nil {
+		l, err := scanShareLink(rows)
+		if err != nil {
 			return nil, err
 		}
 		links = append(links, l)
@@ -108,6 +126,49 @@ func (r *ShareLinkRepository) FindByFileID(ctx context.Context, fileID, userID i
 	return links, nil
 }
 
+// Update changes the enabled flag and/or expiry of a link owned by userID.
+// A nil enabled or expiresAt leaves the field unchanged; clearExpiry removes the expiry.
+// A link taken down by an admin stays disabled.
+// Returns nil, nil if the link does not exist or belongs to another user.
+func (r *ShareLinkRepository) Update(ctx context.Context, linkID, userID int64, enabled *bool, expiresAt *time.Time, clearExpiry bool) (*model.ShareLink, error) {
+	start := time.Now()
+	query := "UPDATE share_links SET enabled = COALESCE($3, enabled) AND taken_down_at IS NULL, expires_at = CASE ... END WHERE id = $1 AND user_id = $2 RETURNING ..."
+
+	link, err := scanShareLink(r.db.QueryRow(ctx,
+		`UPDATE share_links
+		 SET enabled    = COALESCE($3, enabled) AND taken_down_at IS NULL,
+		     expires_at = CASE WHEN $5 THEN NULL
+		                       WHEN $4::timestamptz IS NOT NULL THEN $4::timestamptz
+		                       ELSE expires_at END
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+shareLinkColumns,
+		linkID, userID, enabled, expiresAt, clearExpiry,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("ShareLinkRepository.Update: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("ShareLinkRepository.Update: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return link, nil
+}
+
+// ErrShareLinkNotFound is returned when a share link does not exist or is not owned by the user.
+var ErrShareLinkNotFound = notFoundError("share link not found or unauthorized")
+
 // Delete removes a share link.
 func (r *ShareLinkRepository) Delete(ctx context.Context, linkID, userID int64) error {
 	start := time.Now()
@@ -121,13 +182,13 @@ func (r *ShareLinkRepository) Delete(ctx context.Context, linkID, userID int64)
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("ShareLinkRepository.Delete: %s", err.Error()),
 		})
-		return fmt.Errorf("ShareLinkRepository.Delete: %w", err)
+		return fmt.Errorf("ShareLinkRepository.Delete: %w", classify(err))
 	}
 	if result.RowsAffected() == 0 {
 		logger.Warn(ctx, "Delete affected 0 rows", map[string]interface{}{
 			"link_id": linkID, "user_id": userID,
 		})
-		return fmt.Errorf("share link not found or unauthorized")
+		return ErrShareLinkNotFound
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -135,3 +196,130 @@ func (r *ShareLinkRepository) Delete(ctx context.Context, linkID, userID int64)
 	})
 	return nil
 }
+
+// ListByUserID returns all share links created by a user, newest first.
+func (r *ShareLinkRepository) ListByUserID(ctx context.Context, userID int64) ([]*model.ShareLink, error) {
+	start := time.Now()
+	query := "SELECT " + shareLinkColumns + " FROM share_links WHERE user_id = $1 ORDER BY created_at DESC"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ShareLinkRepository.ListByUserID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("ShareLinkRepository.ListByUserID: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var links []*model.ShareLink
+	for rows.Next() {
+		l, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(links)),
+	})
+	return links, nil
+}
+
+// DeleteExpired removes share links whose expiry is before cutoff. Returns the number deleted.
+func (r *ShareLinkRepository) DeleteExpired(ctx context.Context, cutoff time.Time) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM share_links WHERE expires_at IS NOT NULL AND expires_at < $1"
+
+	result, err := r.db.Exec(ctx, query, cutoff)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("ShareLinkRepository.DeleteExpired: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("ShareLinkRepository.DeleteExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// PreviewDeleteExpired reports how many share links DeleteExpired would delete for cutoff.
+func (r *ShareLinkRepository) PreviewDeleteExpired(ctx context.Context, cutoff time.Time) (*model.CleanupPreview, error) {
+	start := time.Now()
+	query := "SELECT COUNT(*) FROM share_links WHERE expires_at IS NOT NULL AND expires_at < $1"
+
+	p := &model.CleanupPreview{}
+	err := r.db.QueryRow(ctx, query, cutoff).Scan(&p.Items)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ShareLinkRepository.PreviewDeleteExpired: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("ShareLinkRepository.PreviewDeleteExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return p, nil
+}
+
+// ListWithFilesByUserID returns all of a user's share links with their target file names, newest first.
+func (r *ShareLinkRepository) ListWithFilesByUserID(ctx context.Context, userID int64) ([]*model.ShareLinkWithFile, error) {
+	start := time.Now()
+	query := "SELECT s.id, s.file_id, s.user_id, s.token, s.enabled, s.password_hash, s.expires_at, s.created_at, s.taken_down_at, f.name FROM share_links s JOIN files f ON f.id = s.file_id WHERE s.user_id = $1 ORDER BY s.created_at DESC"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("ShareLinkRepository.ListWithFilesByUserID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("ShareLinkRepository.ListWithFilesByUserID: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var links []*model.ShareLinkWithFile
+	for rows.Next() {
+		l := &model.ShareLinkWithFile{}
+		if err := rows.Scan(&l.ID, &l.FileID, &l.UserID, &l.Token, &l.Enabled, &l.PasswordHash, &l.ExpiresAt, &l.CreatedAt, &l.TakenDownAt, &l.FileName); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(links)),
+	})
+	return links, nil
+}
+
+// DeleteExpiredByUserID removes all of a user's expired share links. Returns the number deleted.
+func (r *ShareLinkRepository) DeleteExpiredByUserID(ctx context.Context, userID int64) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM share_links WHERE user_id = $1 AND expires_at IS NOT NULL AND expires_at < NOW()"
+
+	result, err := r.db.Exec(ctx, query, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("ShareLinkRepository.DeleteExpiredByUserID: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("ShareLinkRepository.DeleteExpiredByUserID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// QueryLimits bounds every statement the pool runs.
+type QueryLimits struct {
+	Timeout       time.Duration // deadline per statement on top of the caller's; 0 = none
+	SlowThreshold time.Duration // statements slower than this log a warning; 0 = never
+}
+
+// queryTracer applies QueryLimits to each Query, QueryRow and Exec, including those
+// inside transactions, so repository methods get a deadline without threading one
+// through every call. The slow query threshold can be changed while the pool is in use.
+type queryTracer struct {
+	limits QueryLimits
+	slow   atomic.Int64 // SlowThreshold
+}
+
+func newQueryTracer(limits QueryLimits) *queryTracer {
+	t := &queryTracer{limits: limits}
+	t.slow.Store(int64(limits.SlowThreshold))
+	return t
+}
+
+// SetSlowQueryThreshold changes the SlowThreshold of a pool created by NewPool.
+func SetSlowQueryThreshold(pool *pgxpool.Pool, threshold time.Duration) {
+	if t, ok := pool.Config().ConnConfig.Tracer.(*queryTracer); ok {
+		t.slow.Store(int64(threshold))
+	}
+}
+
+type traceKey struct{}
+
+type traceState struct {
+	start  time.Time
+	args   []any
+	cancel context.CancelFunc
+}
+
+func (t *queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	state := &traceState{start: time.Now(), args: data.Args, cancel: func() {}}
+	if t.limits.Timeout > 0 {
+		ctx, state.cancel = context.WithTimeout(ctx, t.limits.Timeout)
+	}
+	return context.WithValue(ctx, traceKey{}, state)
+}
+
+func (t *queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryEndData) {
+	state, ok := ctx.Value(traceKey{}).(*traceState)
+	if !ok {
+		return
+	}
+	state.cancel()
+
+	elapsed := time.Since(state.start)
+	threshold := time.Duration(t.slow.Load())
+	if threshold <= 0 || elapsed <= threshold {
+		return
+	}
+	logger.Warn(ctx, "Slow query detected", logger.SlowQueryMetrics{
+		ExecutionTimeMs: elapsed.Milliseconds(),
+		ThresholdMs:     threshold.Milliseconds(),
+		Query:           queryName(),
+		ParamsHash:      paramsHash(state.args),
+	})
+}
+
+// queryName returns the repository method running the statement, e.g.
+// "FileRepository.Move", by finding the innermost repository frame on the stack.
+// Rows are closed and transactions run inside the method, so it is always there.
+func queryName() string {
+	const pkg = "/internal/repository."
+
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if i := strings.Index(frame.Function, pkg); i >= 0 {
+			name := frame.Function[i+len(pkg):]
+			if strings.HasPrefix(name, "(*") && !strings.HasPrefix(name, "(*queryTracer)") {
+				name = strings.Replace(strings.TrimPrefix(name, "(*"), ")", "", 1)
+				if j := strings.Index(name, ".func"); j >= 0 {
+					name = name[:j]
+				}
+				return name
+			}
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// paramsHash identifies a statement's arguments without logging their values, so
+// repeated slow calls with the same input can be grouped.
+func paramsHash(args []any) string {
+	// JSON follows pointers, so equal values hash the same wherever they live.
+	b, err := json.Marshal(args)
+	if err != nil {
+		b = []byte(fmt.Sprint(args))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const uploadRequestColumns = "id, user_id, folder_id, token, title, instructions, accepted_types, max_size_bytes, expires_at, enabled, upload_count, created_at"
+
+// ErrUploadRequestNotFound is returned when a file-drop link does not exist or is not owned by the user.
+var ErrUploadRequestNotFound = notFoundError("upload request not found or unauthorized")
+
+type UploadRequestRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUploadRequestRepository(db *pgxpool.Pool) *UploadRequestRepository {
+	return &UploadRequestRepository{db: db}
+}
+
+func scanUploadRequest(row pgx.Row) (*model.UploadRequest, error) {
+	u := &model.UploadRequest{}
+	if err := row.Scan(&u.ID, &u.UserID, &u.FolderID, &u.Token, &u.Title, &u.Instructions, &u.AcceptedTypes,
+		&u.MaxSizeBytes, &u.ExpiresAt, &u.Enabled, &u.UploadCount, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Create stores a new file-drop link from u's owner, folder, token and constraints.
+func (r *UploadRequestRepository) Create(ctx context.Context, u *model.UploadRequest) (*model.UploadRequest, error) {
+	start := time.Now()
+	query := "INSERT INTO upload_requests (user_id, folder_id, token, title, instructions, accepted_types, max_size_bytes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING ..."
+
+	if u.AcceptedTypes == nil {
+		u.AcceptedTypes = []string{}
+	}
+	created, err := scanUploadRequest(r.db.QueryRow(ctx,
+		`INSERT INTO upload_requests (user_id, folder_id, token, title, instructions, accepted_types, max_size_bytes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING `+uploadRequestColumns,
+		u.UserID, u.FolderID, u.Token, u.Title, u.Instructions, u.AcceptedTypes, u.MaxSizeBytes, u.ExpiresAt,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UploadRequestRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRequestRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return created, nil
+}
+
+// ListByUser returns a user's file-drop links, newest first.
+func (r *UploadRequestRepository) ListByUser(ctx context.Context, userID int64) ([]*model.UploadRequest, error) {
+	start := time.Now()
+	query := "SELECT " + uploadRequestColumns + " FROM upload_requests WHERE user_id = $1 ORDER BY created_at DESC"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadRequestRepository.ListByUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRequestRepository.ListByUser: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var out []*model.UploadRequest
+	for rows.Next() {
+		u, err := scanUploadRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// FindByToken fetches a file-drop link by its public token, or nil if there is none,
+// noting whether its owner is deactivated.
+func (r *UploadRequestRepository) FindByToken(ctx context.Context, token string) (*model.UploadRequest, error) {
+	start := time.Now()
+	query := "SELECT " + uploadRequestColumns + ", (SELECT u.deactivated_at IS NOT NULL FROM users u WHERE u.id = upload_requests.user_id) FROM upload_requests WHERE token = $1"
+
+	u := &model.UploadRequest{}
+	err := r.db.QueryRow(ctx, query, token).Scan(&u.ID, &u.UserID, &u.FolderID, &u.Token, &u.Title, &u.Instructions, &u.AcceptedTypes,
+		&u.MaxSizeBytes, &u.ExpiresAt, &u.Enabled, &u.UploadCount, &u.CreatedAt, &u.OwnerDeactivated)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadRequestRepository.FindByToken: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRequestRepository.FindByToken: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return u, nil
+}
+
+// SetEnabled turns a file-drop link on or off.
+func (r *UploadRequestRepository) SetEnabled(ctx context.Context, requestID, userID int64, enabled bool) (*model.UploadRequest, error) {
+	start := time.Now()
+	query := "UPDATE upload_requests SET enabled = $3 WHERE id = $1 AND user_id = $2 RETURNING ..."
+
+	u, err := scanUploadRequest(r.db.QueryRow(ctx,
+		`UPDATE upload_requests SET enabled = $3
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+uploadRequestColumns,
+		requestID, userID, enabled,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUploadRequestNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UploadRequestRepository.SetEnabled: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRequestRepository.SetEnabled: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return u, nil
+}
+
+// RecordUpload counts a file received through a file-drop link.
+func (r *UploadRequestRepository) RecordUpload(ctx context.Context, requestID int64) error {
+	start := time.Now()
+	query := "UPDATE upload_requests SET upload_count = upload_count + 1 WHERE id = $1"
+
+	result, err := r.db.Exec(ctx, query, requestID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UploadRequestRepository.RecordUpload: %s", err.Error()),
+		})
+		return fmt.Errorf("UploadRequestRepository.RecordUpload: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// Delete removes a file-drop link. Files already received stay in the folder.
+func (r *UploadRequestRepository) Delete(ctx context.Context, requestID, userID int64) error {
+	start := time.Now()
+	query := "DELETE FROM upload_requests WHERE id = $1 AND user_id = $2"
+
+	result, err := r.db.Exec(ctx, query, requestID, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("UploadRequestRepository.Delete: %s", err.Error()),
+		})
+		return fmt.Errorf("UploadRequestRepository.Delete: %w", classify(err))
+	}
+	if result.RowsAffected() == 0 {
+		return ErrUploadRequestNotFound
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const uploadRuleColumns = "id, user_id, name, match_type, pattern, target_folder_id, position, enabled, created_at, updated_at"
+
+type UploadRuleRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUploadRuleRepository(db *pgxpool.Pool) *UploadRuleRepository {
+	return &UploadRuleRepository{db: db}
+}
+
+func scanUploadRule(row pgx.Row) (*model.UploadRule, error) {
+	u := &model.UploadRule{}
+	if err := row.Scan(&u.ID, &u.UserID, &u.Name, &u.MatchType, &u.Pattern, &u.TargetFolderID, &u.Position, &u.Enabled, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ListByUser returns a user's upload rules in evaluation order.
+func (r *UploadRuleRepository) ListByUser(ctx context.Context, userID int64) ([]*model.UploadRule, error) {
+	start := time.Now()
+	query := "SELECT " + uploadRuleColumns + " FROM upload_rules WHERE user_id = $1 ORDER BY position ASC, id ASC"
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadRuleRepository.ListByUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRuleRepository.ListByUser: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var rules []*model.UploadRule
+	for rows.Next() {
+		u, err := scanUploadRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, u)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(rules)),
+	})
+	return rules, nil
+}
+
+// FindByIDAndUserID fetches a rule owned by userID, or nil if there is none.
+func (r *UploadRuleRepository) FindByIDAndUserID(ctx context.Context, ruleID, userID int64) (*model.UploadRule, error) {
+	start := time.Now()
+	query := "SELECT " + uploadRuleColumns + " FROM upload_rules WHERE id = $1 AND user_id = $2"
+
+	rule, err := scanUploadRule(r.db.QueryRow(ctx, query, ruleID, userID))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadRuleRepository.FindByIDAndUserID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRuleRepository.FindByIDAndUserID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return rule, nil
+}
+
+// Create inserts a rule. A nil position appends it after the user's existing rules.
+func (r *UploadRuleRepository) Create(ctx context.Context, rule *model.UploadRule, position *int) (*model.UploadRule, error) {
+	start := time.Now()
+	query := "INSERT INTO upload_rules (user_id, name, match_type, pattern, target_folder_id, position, enabled) VALUES (..., COALESCE($6, next position), $7) RETURNING ..."
+
+	created, err := scanUploadRule(r.db.QueryRow(ctx,
+		`INSERT INTO upload_rules (user_id, name, match_type, pattern, target_folder_id, position, enabled)
+		 VALUES ($1, $2, $3, $4, $5,
+		         COALESCE($6, (SELECT COALESCE(MAX(position) + 1, 0) FROM upload_rules WHERE user_id = $1)), $7)
+		 RETURNING `+uploadRuleColumns,
+		rule.UserID, rule.Name, rule.MatchType, rule.Pattern, rule.TargetFolderID, position, rule.Enabled,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UploadRuleRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRuleRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return created, nil
+}
+
+// Update overwrites the editable fields of a rule owned by rule.UserID.
+func (r *UploadRuleRepository) Update(ctx context.Context, rule *model.UploadRule) (*model.UploadRule, error) {
+	start := time.Now()
+	query := "UPDATE upload_rules SET name = $3, match_type = $4, pattern = $5, target_folder_id = $6, position = $7, enabled = $8, updated_at = NOW() WHERE id = $1 AND user_id = $2 RETURNING ..."
+
+	updated, err := scanUploadRule(r.db.QueryRow(ctx,
+		`UPDATE upload_rules
+		 SET name = $3, match_type = $4, pattern = $5, target_folder_id = $6, position = $7, enabled = $8, updated_at = NOW()
+		 WHERE id = $1 AND user_id = $2
+		 RETURNING `+uploadRuleColumns,
+		rule.ID, rule.UserID, rule.Name, rule.MatchType, rule.Pattern, rule.TargetFolderID, rule.Position, rule.Enabled,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UploadRuleRepository.Update: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadRuleRepository.Update: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return updated, nil
+}
+
+// Delete removes a rule owned by userID. Returns false if there was none.
+func (r *UploadRuleRepository) Delete(ctx context.Context, ruleID, userID int64) (bool, error) {
+	start := time.Now()
+	query := "DELETE FROM upload_rules WHERE id = $1 AND user_id = $2"
+
+	result, err := r.db.Exec(ctx, query, ruleID, userID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("UploadRuleRepository.Delete: %s", err.Error()),
+		})
+		return false, fmt.Errorf("UploadRuleRepository.Delete: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected() > 0, nil
+}
@@ -0,0 +1,496 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+var (
+	// ErrUploadSessionNotFound is returned when an upload session does not exist, has
+	// expired, or belongs to another user.
+	ErrUploadSessionNotFound = notFoundError("upload session not found or unauthorized")
+	// ErrUploadSessionFinalized is returned when chunks are sent to a finalized session.
+	ErrUploadSessionFinalized = conflictError("upload session already finalized")
+	// ErrUploadIncomplete is returned when finalizing a session that is missing chunks.
+	ErrUploadIncomplete = conflictError("upload session is missing chunks")
+	// ErrUploadOverQuota is returned when finalizing a session would take the user's
+	// used_bytes past the quota it was given.
+	ErrUploadOverQuota = errors.New("upload would exceed the storage quota")
+)
+
+const uploadSessionColumns = "id, user_id, folder_id, file_name, mime_type, total_size, chunk_size, created_at, expires_at, finalized_at, file_id, rule_id, blocks_new, uploaded_bytes, block_count"
+
+type UploadSessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUploadSessionRepository(db *pgxpool.Pool) *UploadSessionRepository {
+	return &UploadSessionRepository{db: db}
+}
+
+func scanUploadSession(row pgx.Row) (*model.UploadSession, error) {
+	s := &model.UploadSession{}
+	err := row.Scan(&s.ID, &s.UserID, &s.FolderID, &s.FileName, &s.MimeType, &s.TotalSize, &s.ChunkSize, &s.CreatedAt, &s.ExpiresAt, &s.FinalizedAt, &s.FileID, &s.RuleID, &s.BlocksNew, &s.UploadedBytes, &s.BlockCount)
+	return s, err
+}
+
+// SessionBlocks is content Finalize gives a session's file in place of its chunks:
+// BlockIDs, holding one reference each, cut BlockSize bytes on average, of which
+// BlocksNew were stored as new blocks, writing UploadedBytes to S3.
+type SessionBlocks struct {
+	BlockIDs      []int64
+	BlockSize     int
+	BlocksNew     int
+	UploadedBytes int64
+}
+
+// Create starts an upload session for a file of totalSize bytes sent in chunkSize chunks.
+func (r *UploadSessionRepository) Create(ctx context.Context, userID int64, folderID *int64, fileName, mimeType string, totalSize int64, chunkSize int, expiresAt time.Time) (*model.UploadSession, error) {
+	start := time.Now()
+	query := "INSERT INTO upload_sessions (user_id, folder_id, file_name, mime_type, total_size, chunk_size, expires_at) VALUES (...) RETURNING ..."
+
+	s, err := scanUploadSession(r.db.QueryRow(ctx,
+		`INSERT INTO upload_sessions (user_id, folder_id, file_name, mime_type, total_size, chunk_size, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+uploadSessionColumns,
+		userID, folderID, fileName, mimeType, totalSize, chunkSize, expiresAt,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UploadSessionRepository.Create: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadSessionRepository.Create: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return s, nil
+}
+
+// FindByIDAndUserID returns the user's unexpired upload session, or nil if there is none.
+func (r *UploadSessionRepository) FindByIDAndUserID(ctx context.Context, sessionID, userID int64) (*model.UploadSession, error) {
+	start := time.Now()
+	query := "SELECT ... FROM upload_sessions WHERE id = $1 AND user_id = $2 AND expires_at > NOW()"
+
+	s, err := scanUploadSession(r.db.QueryRow(ctx,
+		"SELECT "+uploadSessionColumns+" FROM upload_sessions WHERE id = $1 AND user_id = $2 AND expires_at > NOW()",
+		sessionID, userID,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadSessionRepository.FindByIDAndUserID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadSessionRepository.FindByIDAndUserID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return s, nil
+}
+
+// ListChunks returns the indexes of the chunks received so far, in ascending order.
+func (r *UploadSessionRepository) ListChunks(ctx context.Context, sessionID int64) ([]int, error) {
+	start := time.Now()
+	query := "SELECT chunk_index FROM upload_session_chunks WHERE session_id = $1 ORDER BY chunk_index"
+
+	rows, err := r.db.Query(ctx, query, sessionID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadSessionRepository.ListChunks: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadSessionRepository.ListChunks: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var indexes []int
+	for rows.Next() {
+		var i int
+		if err := rows.Scan(&i); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UploadSessionRepository.ListChunks: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(indexes)),
+	})
+	return indexes, nil
+}
+
+// ListChunkBlocks returns the blocks of the chunks received so far, in chunk order.
+func (r *UploadSessionRepository) ListChunkBlocks(ctx context.Context, sessionID int64) ([]*model.Block, error) {
+	start := time.Now()
+	query := "SELECT b.* FROM upload_session_chunks c JOIN blocks b ON b.id = c.block_id WHERE c.session_id = $1 ORDER BY c.chunk_index"
+
+	rows, err := r.db.Query(ctx, `
+		SELECT b.id, b.sha256_hash, b.s3_key, b.size_bytes, b.compression, b.stored_bytes, b.ref_count, b.created_at
+		FROM upload_session_chunks c
+		JOIN blocks b ON b.id = c.block_id
+		WHERE c.session_id = $1
+		ORDER BY c.chunk_index ASC`, sessionID)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadSessionRepository.ListChunkBlocks: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadSessionRepository.ListChunkBlocks: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var blocks []*model.Block
+	for rows.Next() {
+		b := &model.Block{}
+		if err := rows.Scan(&b.ID, &b.SHA256Hash, &b.S3Key, &b.SizeBytes, &b.Compression, &b.StoredBytes, &b.RefCount, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UploadSessionRepository.ListChunkBlocks: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(blocks)),
+	})
+	return blocks, nil
+}
+
+// PutChunk records that chunk index of the session is stored as blockID, taking over the
+// block reference the caller acquired. A chunk that was already received keeps its
+// first block and the new reference is released, so retried chunk uploads are harmless.
+// stored reports whether the chunk was new; only then do blockNew (the block was
+// stored for the first time) and uploadedBytes count towards the session's dedup totals. Returns ErrUploadSessionNotFound or
+// ErrUploadSessionFinalized (after releasing the reference) when the session was
+// discarded or finalized in the meantime.
+func (r *UploadSessionRepository) PutChunk(ctx context.Context, sessionID int64, index int, blockID, sizeBytes int64, blockNew bool, uploadedBytes int64) (bool, error) {
+	start := time.Now()
+	query := "SELECT finalized_at IS NOT NULL FROM upload_sessions WHERE id = $1 FOR SHARE; INSERT INTO upload_session_chunks (...) VALUES (...) ON CONFLICT DO NOTHING; [new block: UPDATE upload_sessions SET blocks_new = blocks_new + 1, uploaded_bytes = uploaded_bytes + $2 WHERE id = $1]"
+
+	var stored, found, finalized bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		// Lock the session so a concurrent discard or finalize either sees this chunk
+		// or makes us see the session gone or finalized.
+		err := tx.QueryRow(ctx, "SELECT finalized_at IS NOT NULL FROM upload_sessions WHERE id = $1 FOR SHARE", sessionID).Scan(&finalized)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		found = err == nil
+
+		if found && !finalized {
+			result, err := tx.Exec(ctx,
+				`INSERT INTO upload_session_chunks (session_id, chunk_index, block_id, size_bytes)
+				 VALUES ($1, $2, $3, $4)
+				 ON CONFLICT (session_id, chunk_index) DO NOTHING`,
+				sessionID, index, blockID, sizeBytes,
+			)
+			if err != nil {
+				return err
+			}
+			stored = result.RowsAffected() == 1
+		}
+		if stored {
+			if !blockNew {
+				return nil
+			}
+			_, err = tx.Exec(ctx,
+				"UPDATE upload_sessions SET blocks_new = blocks_new + 1, uploaded_bytes = uploaded_bytes + $2 WHERE id = $1",
+				sessionID, uploadedBytes,
+			)
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE blocks SET ref_count = ref_count - 1 WHERE id = $1", blockID)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UploadSessionRepository.PutChunk: %s", err.Error()),
+		})
+		return false, fmt.Errorf("UploadSessionRepository.PutChunk: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	if !found {
+		return false, ErrUploadSessionNotFound
+	}
+	if finalized {
+		return false, ErrUploadSessionFinalized
+	}
+	return stored, nil
+}
+
+// Finalize turns the user's completed upload session into a file in folderID (chosen by
+// ruleID, if any) and returns it. Without split, the chunks' block references pass to
+// the file as they are, so no ref_count changes; with it, the file gets split's blocks
+// and references instead, the chunks' references are released and the session's dedup
+// totals become split's. The caller releases split's references itself when Finalize
+// fails or replays. The session row is locked for the whole operation and
+// keeps the result until keepUntil: a concurrent or retried Finalize waits, then gets the
+// same file back with replayed set, and its folderID and ruleID are ignored. Unless
+// quota is nil, the file must fit in it together with the user's used_bytes, read with
+// the user row locked so that sessions finalized in parallel cannot pass the check
+// together. Returns ErrUploadIncomplete if chunks are missing, ErrUploadOverQuota if the
+// file does not fit, ErrUploadSessionNotFound if there is no such session, and
+// ErrFileNotFound when replaying a finalize whose file was since purged.
+func (r *UploadSessionRepository) Finalize(ctx context.Context, sessionID, userID int64, folderID, ruleID *int64, split *SessionBlocks, quota *int64, client string, keepUntil time.Time) (*model.File, *model.UploadSession, bool, error) {
+	start := time.Now()
+	query := "SELECT ... FROM upload_sessions WHERE id = $1 AND user_id = $2 FOR UPDATE; [quota: SELECT used_bytes FROM users WHERE id = $1 FOR UPDATE]; INSERT INTO files ... (charging used_bytes); INSERT INTO file_blocks SELECT ... FROM upload_session_chunks ... [split: FROM unnest($2::bigint[]); UPDATE blocks SET ref_count = ref_count - n ...]; DELETE FROM upload_session_chunks ...; UPDATE upload_sessions SET finalized_at = NOW() ..."
+
+	var file *model.File
+	var s *model.UploadSession
+	var replayed bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		s, err = scanUploadSession(tx.QueryRow(ctx,
+			"SELECT "+uploadSessionColumns+" FROM upload_sessions WHERE id = $1 AND user_id = $2 AND (expires_at > NOW() OR finalized_at IS NOT NULL) FOR UPDATE",
+			sessionID, userID,
+		))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUploadSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if s.FinalizedAt != nil {
+			replayed = true
+			if s.FileID == nil {
+				return ErrFileNotFound
+			}
+			file, err = scanFile(tx.QueryRow(ctx, "SELECT "+fileColumns+" FROM files WHERE id = $1", *s.FileID))
+			return err
+		}
+
+		var received int
+		if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM upload_session_chunks WHERE session_id = $1", s.ID).Scan(&received); err != nil {
+			return err
+		}
+		if received != s.ChunkCount() {
+			return ErrUploadIncomplete
+		}
+
+		if quota != nil {
+			var used int64
+			if err := tx.QueryRow(ctx, "SELECT used_bytes FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&used); err != nil {
+				return err
+			}
+			if used+s.TotalSize > *quota {
+				return ErrUploadOverQuota
+			}
+		}
+
+		blockSize := s.ChunkSize
+		if split != nil {
+			blockSize = split.BlockSize
+		}
+		file, err = scanFile(tx.QueryRow(ctx, createFileSQL,
+			userID, s.FileName, s.MimeType, s.TotalSize, blockSize, folderID, client,
+		))
+		if err != nil {
+			return err
+		}
+
+		if split == nil {
+			// Each chunk is one block, so chunk_index is the file's block_index.
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO file_blocks (file_id, block_id, block_index)
+				 SELECT $1, block_id, chunk_index FROM upload_session_chunks WHERE session_id = $2`,
+				file.ID, s.ID,
+			); err != nil {
+				return err
+			}
+		} else {
+			if _, err := tx.Exec(ctx,
+				`INSERT INTO file_blocks (file_id, block_id, block_index)
+				 SELECT $1, b.id, b.n - 1 FROM unnest($2::bigint[]) WITH ORDINALITY AS b(id, n)`,
+				file.ID, split.BlockIDs,
+			); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				`UPDATE blocks b SET ref_count = b.ref_count - u.n
+				 FROM (SELECT block_id, COUNT(*) AS n FROM upload_session_chunks WHERE session_id = $1 GROUP BY block_id) u
+				 WHERE b.id = u.block_id`,
+				s.ID,
+			); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx,
+				"UPDATE upload_sessions SET blocks_new = $2, uploaded_bytes = uploaded_bytes + $3, block_count = $4 WHERE id = $1",
+				s.ID, split.BlocksNew, split.UploadedBytes, len(split.BlockIDs),
+			); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM upload_session_chunks WHERE session_id = $1", s.ID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx,
+			`UPDATE upload_sessions
+			 SET finalized_at = NOW(), file_id = $2, folder_id = $3, rule_id = $4, expires_at = GREATEST(expires_at, $5)
+			 WHERE id = $1
+			 RETURNING finalized_at, folder_id, rule_id, blocks_new, uploaded_bytes, block_count`,
+			s.ID, file.ID, folderID, ruleID, keepUntil,
+		).Scan(&s.FinalizedAt, &s.FolderID, &s.RuleID, &s.BlocksNew, &s.UploadedBytes, &s.BlockCount)
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, ErrUploadSessionNotFound) || errors.Is(err, ErrUploadIncomplete) || errors.Is(err, ErrUploadOverQuota) || errors.Is(err, ErrFileNotFound) {
+		return nil, nil, false, err
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UploadSessionRepository.Finalize: %s", err.Error()),
+		})
+		return nil, nil, false, fmt.Errorf("UploadSessionRepository.Finalize: %w", classify(err))
+	}
+
+	s.FileID = &file.ID
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return file, s, replayed, nil
+}
+
+// Delete discards the user's upload session and releases the block references held
+// by its chunks. Returns ErrUploadSessionNotFound if there is no such session.
+func (r *UploadSessionRepository) Delete(ctx context.Context, sessionID, userID int64) error {
+	n, _, err := r.discardBatch(ctx, "UploadSessionRepository.Delete",
+		"SELECT id FROM upload_sessions WHERE id = $1 AND user_id = $2 FOR UPDATE", sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUploadSessionNotFound
+	}
+	return nil
+}
+
+// DeleteExpired discards up to limit expired upload sessions, skipping rows locked by a
+// concurrent request. Returns sessions discarded and block references released.
+func (r *UploadSessionRepository) DeleteExpired(ctx context.Context, limit int) (int64, int64, error) {
+	return r.discardBatch(ctx, "UploadSessionRepository.DeleteExpired",
+		"SELECT id FROM upload_sessions WHERE expires_at <= NOW() ORDER BY expires_at LIMIT $1 FOR UPDATE SKIP LOCKED", limit)
+}
+
+// PreviewDeleteExpired reports what discarding every expired upload session would
+// remove: the sessions, and the blocks only their chunks reference, which are left
+// unreferenced for the block GC.
+func (r *UploadSessionRepository) PreviewDeleteExpired(ctx context.Context) (*model.CleanupPreview, error) {
+	start := time.Now()
+	query := `WITH doomed AS (
+		SELECT id FROM upload_sessions WHERE expires_at <= NOW()
+	), refs AS (
+		SELECT c.block_id, COUNT(*) AS n FROM upload_session_chunks c JOIN doomed d ON d.id = c.session_id GROUP BY c.block_id
+	)
+	SELECT (SELECT COUNT(*) FROM doomed), COUNT(b.id), COALESCE(SUM(b.stored_bytes), 0)
+	FROM refs JOIN blocks b ON b.id = refs.block_id AND b.ref_count <= refs.n`
+
+	p := &model.CleanupPreview{}
+	err := r.db.QueryRow(ctx, query).Scan(&p.Items, &p.Blocks, &p.BytesFreed)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UploadSessionRepository.PreviewDeleteExpired: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UploadSessionRepository.PreviewDeleteExpired: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return p, nil
+}
+
+// discardBatch locks the sessions selected by selectQuery, releases their chunks' block
+// references and deletes them in one transaction. Blocks that drop to zero stay in place
+// for the block GC job.
+func (r *UploadSessionRepository) discardBatch(ctx context.Context, method, selectQuery string, args ...interface{}) (int64, int64, error) {
+	start := time.Now()
+	query := selectQuery + "; UPDATE blocks ...; DELETE FROM upload_sessions ..."
+
+	var discarded, released int64
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, selectQuery, args...)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		result, err := tx.Exec(ctx,
+			`UPDATE blocks b SET ref_count = b.ref_count - u.n
+			 FROM (SELECT block_id, COUNT(*) AS n FROM upload_session_chunks WHERE session_id = ANY($1) GROUP BY block_id) u
+			 WHERE b.id = u.block_id`,
+			ids,
+		)
+		if err != nil {
+			return err
+		}
+		released = result.RowsAffected()
+
+		if _, err := tx.Exec(ctx, "DELETE FROM upload_sessions WHERE id = ANY($1)", ids); err != nil {
+			return err
+		}
+		discarded = int64(len(ids))
+		return nil
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("%s: %s", method, err.Error()),
+		})
+		return 0, 0, fmt.Errorf("%s: %w", method, classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: discarded,
+	})
+	return discarded, released, nil
+}
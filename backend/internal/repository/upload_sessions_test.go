@@ -0,0 +1,244 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// TestFinalizeConcurrent races several Finalize calls on one session: exactly one
+// creates the file, the others wait and replay it, and the chunks' block references
+// pass to the file without being counted twice.
+func TestFinalizeConcurrent(t *testing.T) {
+	db := testPool(t)
+	ctx := context.Background()
+	users, sessions, blocks := NewUserRepository(db), NewUploadSessionRepository(db), NewBlockRepository(db)
+
+	user, err := users.Create(ctx, "finalize-race@example.com", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chunkSize, chunks = 4, 3
+	s, err := sessions.Create(ctx, user.ID, nil, "race.bin", "application/octet-stream", chunkSize*chunks, chunkSize, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockIDs := make([]int64, chunks)
+	for i := range blockIDs {
+		hash := fmt.Sprintf("%064x", i+1)
+		blockIDs[i], _, err = blocks.Acquire(ctx, hash, hash, chunkSize, "", chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sessions.PutChunk(ctx, s.ID, i, blockIDs[i], chunkSize, true, chunkSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const callers = 8
+	type result struct {
+		file     *model.File
+		replayed bool
+		err      error
+	}
+	results := make([]result, callers)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			file, _, replayed, err := sessions.Finalize(ctx, s.ID, user.ID, nil, nil, nil, nil, "", time.Now().Add(time.Hour))
+			results[i] = result{file, replayed, err}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	var created int
+	var fileID int64
+	for i, r := range results {
+		if r.err != nil {
+			t.Fatalf("caller %d: %v", i, r.err)
+		}
+		if !r.replayed {
+			created++
+		}
+		if fileID == 0 {
+			fileID = r.file.ID
+		} else if r.file.ID != fileID {
+			t.Errorf("caller %d got file %d, want %d", i, r.file.ID, fileID)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d callers created the file, want exactly 1", created)
+	}
+
+	var files int
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM files WHERE user_id = $1", user.ID).Scan(&files); err != nil {
+		t.Fatal(err)
+	}
+	if files != 1 {
+		t.Errorf("%d files created, want 1", files)
+	}
+
+	for i, id := range blockIDs {
+		var refs int
+		if err := db.QueryRow(ctx, "SELECT ref_count FROM blocks WHERE id = $1", id).Scan(&refs); err != nil {
+			t.Fatal(err)
+		}
+		if refs != 1 {
+			t.Errorf("block %d ref_count = %d, want 1", i, refs)
+		}
+	}
+
+	var used int64
+	if err := db.QueryRow(ctx, "SELECT used_bytes FROM users WHERE id = $1", user.ID).Scan(&used); err != nil {
+		t.Fatal(err)
+	}
+	if used != chunkSize*chunks {
+		t.Errorf("used_bytes = %d, want %d", used, chunkSize*chunks)
+	}
+}
+
+// TestFinalizeQuota finalizes two sessions in parallel that each fit the quota but
+// not together: one file is created, the other finalize fails with ErrUploadOverQuota.
+func TestFinalizeQuota(t *testing.T) {
+	db := testPool(t)
+	ctx := context.Background()
+	users, sessions, blocks := NewUserRepository(db), NewUploadSessionRepository(db), NewBlockRepository(db)
+
+	user, err := users.Create(ctx, "finalize-quota@example.com", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 8
+	ids := make([]int64, 2)
+	for i := range ids {
+		s, err := sessions.Create(ctx, user.ID, nil, fmt.Sprintf("quota-%d.bin", i), "application/octet-stream", size, size, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash := fmt.Sprintf("%064x", 100+i)
+		blockID, _, err := blocks.Acquire(ctx, hash, hash, size, "", size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sessions.PutChunk(ctx, s.ID, 0, blockID, size, true, size); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = s.ID
+	}
+
+	quota := int64(size + size/2)
+	errs := make([]error, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, _, errs[i] = sessions.Finalize(ctx, id, user.ID, nil, nil, nil, &quota, "", time.Now().Add(time.Hour))
+		}()
+	}
+	wg.Wait()
+
+	var ok, over int
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, ErrUploadOverQuota):
+			over++
+		default:
+			t.Fatalf("session %d: %v", i, err)
+		}
+	}
+	if ok != 1 || over != 1 {
+		t.Errorf("%d finalized and %d refused, want 1 and 1", ok, over)
+	}
+
+	var used int64
+	if err := db.QueryRow(ctx, "SELECT used_bytes FROM users WHERE id = $1", user.ID).Scan(&used); err != nil {
+		t.Fatal(err)
+	}
+	if used != size {
+		t.Errorf("used_bytes = %d, want %d", used, size)
+	}
+}
+
+// TestFinalizeSplit finalizes a session with its content split again: the file gets
+// the split's blocks, the chunks' references are released and the session reports
+// the split's block count.
+func TestFinalizeSplit(t *testing.T) {
+	db := testPool(t)
+	ctx := context.Background()
+	users, sessions, blocks := NewUserRepository(db), NewUploadSessionRepository(db), NewBlockRepository(db)
+
+	user, err := users.Create(ctx, "finalize-split@example.com", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const chunkSize, chunks = 4, 2
+	s, err := sessions.Create(ctx, user.ID, nil, "split.bin", "application/octet-stream", chunkSize*chunks, chunkSize, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkIDs := make([]int64, chunks)
+	for i := range chunkIDs {
+		hash := fmt.Sprintf("%064x", 200+i)
+		chunkIDs[i], _, err = blocks.Acquire(ctx, hash, hash, chunkSize, "", chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sessions.PutChunk(ctx, s.ID, i, chunkIDs[i], chunkSize, true, chunkSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	split := &SessionBlocks{BlockSize: 3, BlocksNew: 3, UploadedBytes: chunkSize * chunks}
+	for i, size := range []int64{3, 3, 2} {
+		hash := fmt.Sprintf("%064x", 300+i)
+		id, _, err := blocks.Acquire(ctx, hash, hash, size, "", size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		split.BlockIDs = append(split.BlockIDs, id)
+	}
+
+	file, s, _, err := sessions.Finalize(ctx, s.ID, user.ID, nil, nil, split, nil, "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.FileBlocks() != len(split.BlockIDs) || s.BlocksDeduped() != 0 {
+		t.Errorf("session reports %d blocks, %d deduped, want %d and 0", s.FileBlocks(), s.BlocksDeduped(), len(split.BlockIDs))
+	}
+
+	fileBlocks, err := blocks.FindByFileID(ctx, ForUser(user.ID), file.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileBlocks) != len(split.BlockIDs) {
+		t.Fatalf("file has %d blocks, want %d", len(fileBlocks), len(split.BlockIDs))
+	}
+	for i, b := range fileBlocks {
+		if b.ID != split.BlockIDs[i] {
+			t.Errorf("file block %d = %d, want %d", i, b.ID, split.BlockIDs[i])
+		}
+	}
+
+	for i, id := range chunkIDs {
+		var refs int
+		if err := db.QueryRow(ctx, "SELECT ref_count FROM blocks WHERE id = $1", id).Scan(&refs); err != nil {
+			t.Fatal(err)
+		}
+		if refs != 0 {
+			t.Errorf("chunk block %d ref_count = %d, want 0", i, refs)
+		}
+	}
+}
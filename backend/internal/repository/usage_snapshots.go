@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+type UsageSnapshotRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUsageSnapshotRepository(db *pgxpool.Pool) *UsageSnapshotRepository {
+	return &UsageSnapshotRepository{db: db}
+}
+
+// Snapshot records every user's usage for day: their total and one row per top-level
+// folder subtree. Taking it again on the same day replaces that day's rows, so the
+// last run of a day wins. Returns the number of rows written.
+func (r *UsageSnapshotRepository) Snapshot(ctx context.Context, day time.Time) (int64, error) {
+	start := time.Now()
+	query := "WITH RECURSIVE tree AS (...) INSERT INTO usage_snapshots (user_id, folder_id, folder_name, day, bytes, file_count) SELECT <per top-level folder> UNION ALL SELECT <per user> ON CONFLICT DO UPDATE"
+
+	result, err := r.db.Exec(ctx,
+		`WITH RECURSIVE tree AS (
+		     SELECT id, id AS top_id, user_id FROM folders WHERE parent_id IS NULL
+		     UNION ALL
+		     SELECT f.id, t.top_id, t.user_id FROM folders f JOIN tree t ON f.parent_id = t.id
+		 )
+		 INSERT INTO usage_snapshots (user_id, folder_id, folder_name, day, bytes, file_count)
+		 SELECT t.user_id, t.top_id, MAX(top.name), $1::DATE, COALESCE(SUM(fi.total_size), 0), COUNT(fi.id)
+		 FROM tree t
+		 JOIN folders top ON top.id = t.top_id
+		 LEFT JOIN files fi ON fi.folder_id = t.id AND fi.deleted_at IS NULL
+		 GROUP BY t.user_id, t.top_id
+		 UNION ALL
+		 SELECT u.id, NULL, NULL, $1::DATE, COALESCE(SUM(f.total_size), 0), COUNT(f.id)
+		 FROM users u
+		 LEFT JOIN files f ON f.user_id = u.id
+		 GROUP BY u.id
+		 ON CONFLICT (user_id, day, (COALESCE(folder_id, 0))) DO UPDATE
+		 SET folder_name = EXCLUDED.folder_name, bytes = EXCLUDED.bytes,
+		     file_count = EXCLUDED.file_count, taken_at = NOW()`,
+		day.UTC().Format(time.DateOnly),
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UsageSnapshotRepository.Snapshot: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("UsageSnapshotRepository.Snapshot: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// DeleteOlderThan removes snapshots of days before cutoff and returns how many were removed.
+func (r *UsageSnapshotRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time) (int64, error) {
+	start := time.Now()
+	query := "DELETE FROM usage_snapshots WHERE day < $1"
+
+	result, err := r.db.Exec(ctx, query, cutoff.UTC().Format(time.DateOnly))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_DELETE_ERR", Details: fmt.Sprintf("UsageSnapshotRepository.DeleteOlderThan: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("UsageSnapshotRepository.DeleteOlderThan: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// ListByUser returns a user's snapshots from since onwards, oldest first, totals
+// before folders within a day.
+func (r *UsageSnapshotRepository) ListByUser(ctx context.Context, userID int64, since time.Time) ([]*model.UsageSnapshot, error) {
+	start := time.Now()
+	query := "SELECT user_id, folder_id, folder_name, day, bytes, file_count FROM usage_snapshots WHERE user_id = $1 AND day >= $2 ORDER BY day, folder_id NULLS FIRST"
+
+	return r.list(ctx, "ListByUser", start, query, userID, since.UTC().Format(time.DateOnly))
+}
+
+// ListTotals returns the sum of all users' totals per day from since onwards, oldest
+// first. UserID is 0 in the returned snapshots.
+func (r *UsageSnapshotRepository) ListTotals(ctx context.Context, since time.Time) ([]*model.UsageSnapshot, error) {
+	start := time.Now()
+	query := "SELECT 0, NULL::BIGINT, NULL, day, SUM(bytes)::BIGINT, SUM(file_count)::BIGINT FROM usage_snapshots WHERE folder_id IS NULL AND day >= $1 GROUP BY day ORDER BY day"
+
+	return r.list(ctx, "ListTotals", start, query, since.UTC().Format(time.DateOnly))
+}
+
+func (r *UsageSnapshotRepository) list(ctx context.Context, method string, start time.Time, query string, args ...interface{}) ([]*model.UsageSnapshot, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UsageSnapshotRepository.%s: %s", method, err.Error()),
+		})
+		return nil, fmt.Errorf("UsageSnapshotRepository.%s: %w", method, classify(err))
+	}
+	defer rows.Close()
+
+	snapshots := []*model.UsageSnapshot{}
+	for rows.Next() {
+		s := &model.UsageSnapshot{}
+		if err := rows.Scan(&s.UserID, &s.FolderID, &s.FolderName, &s.Day, &s.Bytes, &s.FileCount); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(snapshots)),
+	})
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// ErrInviteNotFound is returned for an invite token that is unknown, used or expired.
+var ErrInviteNotFound = notFoundError("invite not found")
+
+// UserInviteRepository provisions accounts that sign up through an invite link.
+type UserInviteRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserInviteRepository(db *pgxpool.Pool) *UserInviteRepository {
+	return &UserInviteRepository{db: db}
+}
+
+// CreateUser inserts a user without a password, with the given role and plan (nil =
+// the default plan), and an invite under tokenHash that lets them set one until
+// expiresAt. Returns ErrEmailExists if the email is taken.
+func (r *UserInviteRepository) CreateUser(ctx context.Context, email, role string, planID *int64, tokenHash []byte, expiresAt time.Time, createdBy int64) (*model.User, error) {
+	start := time.Now()
+	query := "INSERT INTO users (email, password, role, plan_id) VALUES ($1, '', $2, $3) RETURNING ...; INSERT INTO user_invites (user_id, token_hash, created_by, expires_at) VALUES (...)"
+
+	var user *model.User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx,
+			`INSERT INTO users (email, password, role, plan_id)
+			 VALUES ($1, '', $2, $3)
+			 RETURNING `+userColumns,
+			email, role, planID,
+		))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			"INSERT INTO user_invites (user_id, token_hash, created_by, expires_at) VALUES ($1, $2, $3, $4)",
+			user.ID, tokenHash, createdBy, expiresAt)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, ErrEmailExists
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UserInviteRepository.CreateUser: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserInviteRepository.CreateUser: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 2,
+	})
+	return user, nil
+}
+
+// Accept sets the password of the user invited under tokenHash and uses up the
+// invite. Returns ErrInviteNotFound if the invite is unknown, used or expired.
+func (r *UserInviteRepository) Accept(ctx context.Context, tokenHash []byte, hashedPassword string) (*model.User, error) {
+	start := time.Now()
+	query := "UPDATE user_invites SET accepted_at = NOW() WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW() RETURNING user_id; UPDATE users SET password = $2 WHERE id = ... RETURNING ..."
+
+	var user *model.User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var userID int64
+		err := tx.QueryRow(ctx,
+			`UPDATE user_invites SET accepted_at = NOW()
+			 WHERE token_hash = $1 AND accepted_at IS NULL AND expires_at > NOW()
+			 RETURNING user_id`,
+			tokenHash,
+		).Scan(&userID)
+		if err != nil {
+			return err
+		}
+		user, err = scanUser(tx.QueryRow(ctx,
+			`UPDATE users SET password = $2, updated_at = NOW()
+			 WHERE id = $1
+			 RETURNING `+userColumns,
+			userID, hashedPassword,
+		))
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			logger.Info(ctx, "Executed query", logger.QueryAttributes{
+				Query: query, DurationMs: duration, RowsAffected: 0,
+			})
+			return nil, ErrInviteNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserInviteRepository.Accept: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserInviteRepository.Accept: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 2,
+	})
+	return user, nil
+}
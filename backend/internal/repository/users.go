@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/naratel/naratel-box/backend/internal/logger"
@@ -13,7 +15,12 @@ import (
 )
 
 // ErrEmailExists is returned when attempting to create a user with a duplicate email.
-var ErrEmailExists = errors.New("email already registered")
+var ErrEmailExists = conflictError("email already registered")
+
+// ErrUserNotFound is returned when a user does not exist.
+var ErrUserNotFound = notFoundError("user not found")
+
+const userColumns = "id, email, password, role, created_at, updated_at, tos_version, tos_accepted_at, deactivated_at, deactivated_by, used_bytes, plan_id, quota_bytes"
 
 type UserRepository struct {
 	db *pgxpool.Pool
@@ -23,18 +30,25 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+func scanUser(row pgx.Row) (*model.User, error) {
+	u := &model.User{}
+	if err := row.Scan(&u.ID, &u.Email, &u.Password, &u.Role, &u.CreatedAt, &u.UpdatedAt, &u.TOSVersion, &u.TOSAcceptedAt, &u.DeactivatedAt, &u.DeactivatedBy, &u.UsedBytes, &u.PlanID, &u.QuotaBytes); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // Create inserts a new user and returns the created record.
 func (r *UserRepository) Create(ctx context.Context, email, hashedPassword string) (*model.User, error) {
 	start := time.Now()
 	query := "INSERT INTO users (email, password) VALUES ($1, $2) RETURNING ..."
 
-	user := &model.User{}
-	err := r.db.QueryRow(ctx,
+	user, err := scanUser(r.db.QueryRow(ctx,
 		`INSERT INTO users (email, password)
 		 VALUES ($1, $2)
-		 RETURNING id, email, password, created_at, updated_at`,
+		 RETURNING `+userColumns,
 		email, hashedPassword,
-	).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -49,7 +63,7 @@ func (r *UserRepository) Create(ctx context.Context, email, hashedPassword strin
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("UserRepository.Create: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("UserRepository.Create: %w", err)
+		return nil, fmt.Errorf("UserRepository.Create: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -61,11 +75,9 @@ func (r *UserRepository) Create(ctx context.Context, email, hashedPassword strin
 // FindByEmail returns a user by email address.
 func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	start := time.Now()
-	query := "SELECT id, email, password, created_at, updated_at FROM users WHERE email = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE email = $1"
 
-	user := &model.User{}
-	err := r.db.QueryRow(ctx, query, email,
-	).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	user, err := scanUser(r.db.QueryRow(ctx, query, email))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -73,7 +85,7 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.FindByEmail: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("UserRepository.FindByEmail: %w", err)
+		return nil, fmt.Errorf("UserRepository.FindByEmail: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -85,11 +97,9 @@ func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*model.
 // FindByID returns a user by ID.
 func (r *UserRepository) FindByID(ctx context.Context, id int64) (*model.User, error) {
 	start := time.Now()
-	query := "SELECT id, email, password, created_at, updated_at FROM users WHERE id = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1"
 
-	user := &model.User{}
-	err := r.db.QueryRow(ctx, query, id,
-	).Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	user, err := scanUser(r.db.QueryRow(ctx, query, id))
 
 	duration := time.Since(start).Milliseconds()
 
@@ -97,7 +107,129 @@ func (r *UserRepository) FindByID(ctx context.Context, id int64) (*model.User, e
 		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
 			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.FindByID: %s", err.Error()),
 		})
-		return nil, fmt.Errorf("UserRepository.FindByID: %w", err)
+		return nil, fmt.Errorf("UserRepository.FindByID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return user, nil
+}
+
+// IsAdmin reports whether the user currently has the admin role.
+func (r *UserRepository) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+	start := time.Now()
+	query := "SELECT role = 'admin' FROM users WHERE id = $1"
+
+	var isAdmin bool
+	err := r.db.QueryRow(ctx, query, userID).Scan(&isAdmin)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.IsAdmin: %s", err.Error()),
+		})
+		return false, fmt.Errorf("UserRepository.IsAdmin: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return isAdmin, nil
+}
+
+// PromoteAdmins grants the admin role to existing users with the given emails.
+// Used at startup to bootstrap admins from configuration.
+func (r *UserRepository) PromoteAdmins(ctx context.Context, emails []string) (int64, error) {
+	start := time.Now()
+	query := "UPDATE users SET role = 'admin', updated_at = NOW() WHERE email = ANY($1) AND role <> 'admin'"
+
+	result, err := r.db.Exec(ctx, query, emails)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.PromoteAdmins: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("UserRepository.PromoteAdmins: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// IsActive reports whether the user exists and is not deactivated.
+func (r *UserRepository) IsActive(ctx context.Context, userID int64) (bool, error) {
+	start := time.Now()
+	query := "SELECT deactivated_at IS NULL FROM users WHERE id = $1"
+
+	var active bool
+	err := r.db.QueryRow(ctx, query, userID).Scan(&active)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.IsActive: %s", err.Error()),
+		})
+		return false, fmt.Errorf("UserRepository.IsActive: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return active, nil
+}
+
+// Deactivate marks the user deactivated by adminID, ends their cookie sessions and
+// revokes their refresh tokens.
+// Deactivating an already deactivated user keeps the original time and admin.
+func (r *UserRepository) Deactivate(ctx context.Context, userID, adminID int64) (*model.User, error) {
+	start := time.Now()
+	query := "UPDATE users SET deactivated_at = COALESCE(deactivated_at, NOW()), ... WHERE id = $1 RETURNING ...; DELETE FROM sessions WHERE user_id = $1; UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL"
+
+	var user *model.User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx,
+			`UPDATE users
+			 SET deactivated_by = CASE WHEN deactivated_at IS NULL THEN $2 ELSE deactivated_by END,
+			     deactivated_at = COALESCE(deactivated_at, NOW()),
+			     updated_at = NOW()
+			 WHERE id = $1
+			 RETURNING `+userColumns,
+			userID, adminID,
+		))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM sessions WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.Deactivate: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.Deactivate: %w", classify(err))
 	}
 
 	logger.Info(ctx, "Executed query", logger.QueryAttributes{
@@ -105,3 +237,380 @@ func (r *UserRepository) FindByID(ctx context.Context, id int64) (*model.User, e
 	})
 	return user, nil
 }
+
+// Reactivate clears the user's deactivation.
+func (r *UserRepository) Reactivate(ctx context.Context, userID int64) (*model.User, error) {
+	start := time.Now()
+	query := "UPDATE users SET deactivated_at = NULL, deactivated_by = NULL, updated_at = NOW() WHERE id = $1 RETURNING ..."
+
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET deactivated_at = NULL, deactivated_by = NULL, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+userColumns,
+		userID,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.Reactivate: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.Reactivate: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return user, nil
+}
+
+// SetPlan assigns planID to the user; nil returns them to the default plan. Returns
+// ErrUserNotFound for an unknown user and ErrPlanNotFound for an unknown plan.
+func (r *UserRepository) SetPlan(ctx context.Context, userID int64, planID *int64) (*model.User, error) {
+	start := time.Now()
+	query := "UPDATE users SET plan_id = $2, updated_at = NOW() WHERE id = $1 RETURNING ..."
+
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET plan_id = $2, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+userColumns,
+		userID, planID,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(classify(err), ErrNotFound) { // foreign key: no such plan
+			return nil, ErrPlanNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.SetPlan: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.SetPlan: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return user, nil
+}
+
+// AcceptedTOSVersion returns the latest terms of service version the user accepted,
+// or "" if none.
+func (r *UserRepository) AcceptedTOSVersion(ctx context.Context, userID int64) (string, error) {
+	start := time.Now()
+	query := "SELECT COALESCE(tos_version, '') FROM users WHERE id = $1"
+
+	var version string
+	err := r.db.QueryRow(ctx, query, userID).Scan(&version)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.AcceptedTOSVersion: %s", err.Error()),
+		})
+		return "", fmt.Errorf("UserRepository.AcceptedTOSVersion: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return version, nil
+}
+
+// AcceptTOS records that the user accepted a terms of service version, keeping the
+// request's origin in the acceptance history.
+func (r *UserRepository) AcceptTOS(ctx context.Context, userID int64, version, ipAddress, userAgent string) (*model.User, error) {
+	start := time.Now()
+	query := "INSERT INTO tos_acceptances (user_id, version, ip_address, user_agent) VALUES ($1, $2, $3, $4); UPDATE users SET tos_version = $2, tos_accepted_at = NOW() WHERE id = $1 RETURNING ..."
+
+	var user *model.User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO tos_acceptances (user_id, version, ip_address, user_agent) VALUES ($1, $2, $3, $4)",
+			userID, version, ipAddress, userAgent,
+		); err != nil {
+			return err
+		}
+		var err error
+		user, err = scanUser(tx.QueryRow(ctx,
+			`UPDATE users SET tos_version = $2, tos_accepted_at = NOW(), updated_at = NOW()
+			 WHERE id = $1
+			 RETURNING `+userColumns,
+			userID, version,
+		))
+		return err
+	})
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.AcceptTOS: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.AcceptTOS: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 2,
+	})
+	return user, nil
+}
+
+// decodePreferences lays stored preferences over the defaults.
+func decodePreferences(raw []byte) (*model.UserPreferences, error) {
+	p := model.DefaultPreferences()
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, err
+	}
+	if p.Notifications.MutedKinds == nil {
+		p.Notifications.MutedKinds = []string{}
+	}
+	return p, nil
+}
+
+// Preferences returns the user's preferences, or nil if the user does not exist.
+func (r *UserRepository) Preferences(ctx context.Context, userID int64) (*model.UserPreferences, error) {
+	start := time.Now()
+	query := "SELECT preferences FROM users WHERE id = $1"
+
+	var raw []byte
+	err := r.db.QueryRow(ctx, query, userID).Scan(&raw)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.Preferences: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.Preferences: %w", classify(err))
+	}
+	prefs, err := decodePreferences(raw)
+	if err != nil {
+		return nil, fmt.Errorf("UserRepository.Preferences: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return prefs, nil
+}
+
+// PreferencesByUsers returns the preferences of each existing user in userIDs.
+func (r *UserRepository) PreferencesByUsers(ctx context.Context, userIDs []int64) (map[int64]*model.UserPreferences, error) {
+	start := time.Now()
+	query := "SELECT id, preferences FROM users WHERE id = ANY($1)"
+
+	rows, err := r.db.Query(ctx, query, userIDs)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.PreferencesByUsers: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.PreferencesByUsers: %w", classify(err))
+	}
+	defer rows.Close()
+
+	out := make(map[int64]*model.UserPreferences, len(userIDs))
+	for rows.Next() {
+		var id int64
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, err
+		}
+		prefs, err := decodePreferences(raw)
+		if err != nil {
+			return nil, fmt.Errorf("UserRepository.PreferencesByUsers: user %d: %w", id, classify(err))
+		}
+		out[id] = prefs
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(out)),
+	})
+	return out, nil
+}
+
+// UpdatePreferences replaces the user's stored preferences.
+func (r *UserRepository) UpdatePreferences(ctx context.Context, userID int64, prefs *model.UserPreferences) error {
+	start := time.Now()
+	query := "UPDATE users SET preferences = $2, updated_at = NOW() WHERE id = $1"
+
+	raw, err := json.Marshal(prefs)
+	if err != nil {
+		return fmt.Errorf("UserRepository.UpdatePreferences marshal: %w", err)
+	}
+
+	result, err := r.db.Exec(ctx, query, userID, raw)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.UpdatePreferences: %s", err.Error()),
+		})
+		return fmt.Errorf("UserRepository.UpdatePreferences: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return nil
+}
+
+// userActualUsageSQL sums the sizes of user alias u's files, trashed ones included.
+const userActualUsageSQL = `(SELECT COALESCE(SUM(f.total_size), 0) FROM files f WHERE f.user_id = u.id)`
+
+// FindUsageDrift returns users whose used_bytes differs from the total size of their
+// files, plus the number of users checked.
+func (r *UserRepository) FindUsageDrift(ctx context.Context) ([]*model.UsageDrift, int64, error) {
+	start := time.Now()
+	query := "SELECT u.id, u.used_bytes, <actual usage> FROM users u ORDER BY u.id"
+
+	rows, err := r.db.Query(ctx,
+		`SELECT u.id, u.used_bytes, `+userActualUsageSQL+` FROM users u ORDER BY u.id`,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.FindUsageDrift: %s", err.Error()),
+		})
+		return nil, 0, fmt.Errorf("UserRepository.FindUsageDrift: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var drift []*model.UsageDrift
+	var total int64
+	for rows.Next() {
+		d := &model.UsageDrift{}
+		if err := rows.Scan(&d.UserID, &d.UsedBytes, &d.ActualBytes); err != nil {
+			return nil, 0, err
+		}
+		total++
+		if d.UsedBytes != d.ActualBytes {
+			drift = append(drift, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("UserRepository.FindUsageDrift: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: total,
+	})
+	return drift, total, nil
+}
+
+// RepairUsage sets used_bytes to the actual total for the given users. Totals are
+// recomputed at update time, so files created or purged since the check are respected.
+func (r *UserRepository) RepairUsage(ctx context.Context, userIDs []int64) (int64, error) {
+	start := time.Now()
+	query := "UPDATE users u SET used_bytes = <actual usage> WHERE u.id = ANY($1) AND u.used_bytes <> <actual usage>"
+
+	result, err := r.db.Exec(ctx,
+		`UPDATE users u SET used_bytes = `+userActualUsageSQL+`
+		 WHERE u.id = ANY($1) AND u.used_bytes <> `+userActualUsageSQL,
+		userIDs,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.RepairUsage: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("UserRepository.RepairUsage: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: result.RowsAffected(),
+	})
+	return result.RowsAffected(), nil
+}
+
+// SetQuota sets the user's own storage quota, which overrides their plan's; nil
+// returns them to the plan's quota. Returns ErrUserNotFound for an unknown user.
+func (r *UserRepository) SetQuota(ctx context.Context, userID int64, quotaBytes *int64) (*model.User, error) {
+	start := time.Now()
+	query := "UPDATE users SET quota_bytes = $2, updated_at = NOW() WHERE id = $1 RETURNING ..."
+
+	user, err := scanUser(r.db.QueryRow(ctx,
+		`UPDATE users SET quota_bytes = $2, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+userColumns,
+		userID, quotaBytes,
+	))
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("UserRepository.SetQuota: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.SetQuota: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return user, nil
+}
+
+// Export returns every user with their effective plan, storage used and this month's
+// egress, ordered by ID.
+func (r *UserRepository) Export(ctx context.Context) ([]*model.UserExport, error) {
+	start := time.Now()
+	query := "SELECT u.id, u.email, ... FROM users u LEFT JOIN plans p ON ... LEFT JOIN egress_usage e ON ... ORDER BY u.id"
+
+	rows, err := r.db.Query(ctx,
+		`SELECT u.id, u.email, u.role, u.plan_id, p.name, COALESCE(u.quota_bytes, p.quota_bytes), u.used_bytes, COALESCE(e.bytes, 0),
+		        u.password = '', u.created_at, u.deactivated_at
+		 FROM users u
+		 LEFT JOIN plans p ON p.id = COALESCE(u.plan_id, (SELECT id FROM plans WHERE is_default))
+		 LEFT JOIN egress_usage e ON e.user_id = u.id AND e.month = date_trunc('month', NOW() AT TIME ZONE 'UTC')::DATE
+		 ORDER BY u.id`,
+	)
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("UserRepository.Export: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("UserRepository.Export: %w", classify(err))
+	}
+	defer rows.Close()
+
+	var users []*model.UserExport
+	for rows.Next() {
+		u := &model.UserExport{}
+		if err := rows.Scan(&u.ID, &u.Email, &u.Role, &u.PlanID, &u.PlanName, &u.QuotaBytes, &u.UsedBytes, &u.EgressBytes,
+			&u.InvitePending, &u.CreatedAt, &u.DeactivatedAt); err != nil {
+			return nil, fmt.Errorf("UserRepository.Export: %w", classify(err))
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserRepository.Export: %w", classify(err))
+	}
+
+	duration := time.Since(start).Milliseconds()
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: int64(len(users)),
+	})
+	return users, nil
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+const waveformColumns = "file_id, status, content_etag, duration_ms, bitrate, sample_rate, channels, codec, peaks, error, updated_at"
+
+type WaveformRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWaveformRepository(db *pgxpool.Pool) *WaveformRepository {
+	return &WaveformRepository{db: db}
+}
+
+// FindByFileID returns the waveform row of a file, or nil if it was never queued.
+// Callers check ownership of the file first.
+func (r *WaveformRepository) FindByFileID(ctx context.Context, fileID int64) (*model.Waveform, error) {
+	start := time.Now()
+	query := "SELECT ... FROM file_waveforms WHERE file_id = $1"
+
+	w := &model.Waveform{}
+	err := r.db.QueryRow(ctx, "SELECT "+waveformColumns+" FROM file_waveforms WHERE file_id = $1", fileID).Scan(
+		&w.FileID, &w.Status, &w.ContentETag, &w.DurationMs, &w.Bitrate, &w.SampleRate, &w.Channels, &w.Codec, &w.Peaks, &w.Error, &w.UpdatedAt,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_QUERY_ERR", Details: fmt.Sprintf("WaveformRepository.FindByFileID: %s", err.Error()),
+		})
+		return nil, fmt.Errorf("WaveformRepository.FindByFileID: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return w, nil
+}
+
+// Request queues a file for analysis. A finished (ready or failed) row is queued
+// again, for content that changed since; a queued or running one is left alone.
+// Returns whether anything was queued.
+func (r *WaveformRepository) Request(ctx context.Context, fileID int64) (bool, error) {
+	return r.exec(ctx, "WaveformRepository.Request",
+		`INSERT INTO file_waveforms (file_id) VALUES ($1)
+		 ON CONFLICT (file_id) DO UPDATE SET status = 'pending', error = NULL, updated_at = NOW()
+		 WHERE file_waveforms.status IN ('ready', 'failed')`,
+		fileID,
+	)
+}
+
+// EnqueueMissing queues up to limit audio files (by MIME type, outside the trash, at
+// most maxBytes) that were never analysed, newest first, and returns how many.
+func (r *WaveformRepository) EnqueueMissing(ctx context.Context, maxBytes int64, limit int) (int64, error) {
+	start := time.Now()
+	query := "INSERT INTO file_waveforms (file_id) SELECT id FROM files WHERE audio, not trashed, total_size <= $1 AND no waveform row ORDER BY id DESC LIMIT $2"
+
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO file_waveforms (file_id)
+		 SELECT f.id FROM files f
+		  WHERE f.mime_type ILIKE 'audio/%' AND f.deleted_at IS NULL AND f.total_size <= $1
+		    AND NOT EXISTS (SELECT 1 FROM file_waveforms w WHERE w.file_id = f.id)
+		  ORDER BY f.id DESC
+		  LIMIT $2
+		 ON CONFLICT (file_id) DO NOTHING`,
+		maxBytes, limit,
+	)
+
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_INSERT_ERR", Details: fmt.Sprintf("WaveformRepository.EnqueueMissing: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("WaveformRepository.EnqueueMissing: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: tag.RowsAffected(),
+	})
+	return tag.RowsAffected(), nil
+}
+
+// ClaimNext marks the longest-waiting queued file as running and returns its ID, or 0
+// if the queue is empty. A row left running for longer than staleAfter (its worker
+// died) is claimed again.
+func (r *WaveformRepository) ClaimNext(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	start := time.Now()
+	query := "UPDATE file_waveforms SET status = 'running' WHERE file_id = (SELECT ... WHERE pending OR stale running ORDER BY updated_at LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING file_id"
+
+	var fileID int64
+	err := r.db.QueryRow(ctx,
+		`UPDATE file_waveforms SET status = 'running', updated_at = NOW()
+		 WHERE file_id = (
+		     SELECT file_id FROM file_waveforms
+		      WHERE status = 'pending' OR (status = 'running' AND updated_at < NOW() - make_interval(secs => $1))
+		      ORDER BY updated_at
+		      LIMIT 1
+		      FOR UPDATE SKIP LOCKED)
+		 RETURNING file_id`,
+		staleAfter.Seconds(),
+	).Scan(&fileID)
+
+	duration := time.Since(start).Milliseconds()
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("WaveformRepository.ClaimNext: %s", err.Error()),
+		})
+		return 0, fmt.Errorf("WaveformRepository.ClaimNext: %w", classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: 1,
+	})
+	return fileID, nil
+}
+
+// Complete stores the analysis of a claimed file, made of the content version etag.
+func (r *WaveformRepository) Complete(ctx context.Context, w *model.Waveform, etag string) error {
+	_, err := r.exec(ctx, "WaveformRepository.Complete",
+		`UPDATE file_waveforms
+		    SET status = 'ready', content_etag = $2, duration_ms = $3, bitrate = $4, sample_rate = $5,
+		        channels = $6, codec = $7, peaks = $8, error = NULL, updated_at = NOW()
+		  WHERE file_id = $1 AND status = 'running'`,
+		w.FileID, etag, w.DurationMs, w.Bitrate, w.SampleRate, w.Channels, w.Codec, w.Peaks,
+	)
+	return err
+}
+
+// Fail records why a claimed file could not be analysed. etag is the content version
+// tried, so the file is not retried until it changes; empty if it was not read.
+func (r *WaveformRepository) Fail(ctx context.Context, fileID int64, etag, message string) error {
+	_, err := r.exec(ctx, "WaveformRepository.Fail",
+		`UPDATE file_waveforms
+		    SET status = 'failed', content_etag = NULLIF($2, ''), duration_ms = NULL, bitrate = NULL, sample_rate = NULL,
+		        channels = NULL, codec = NULL, peaks = NULL, error = $3, updated_at = NOW()
+		  WHERE file_id = $1 AND status = 'running'`,
+		fileID, etag, message,
+	)
+	return err
+}
+
+// exec runs a statement and reports whether it touched a row.
+func (r *WaveformRepository) exec(ctx context.Context, op, query string, args ...interface{}) (bool, error) {
+	start := time.Now()
+	tag, err := r.db.Exec(ctx, query, args...)
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		logger.ErrorLog(ctx, "Query failed", logger.ErrorDetails{
+			Code: "DB_UPDATE_ERR", Details: fmt.Sprintf("%s: %s", op, err.Error()),
+		})
+		return false, fmt.Errorf("%s: %w", op, classify(err))
+	}
+
+	logger.Info(ctx, "Executed query", logger.QueryAttributes{
+		Query: query, DurationMs: duration, RowsAffected: tag.RowsAffected(),
+	})
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,76 @@
+// Package respond writes the API's JSON bodies in one shape. A successful response
+// is an Envelope around the payload; an error stays a flat {"error", "message"}
+// object so clients can read its code without knowing the endpoint. Both carry the
+// request ID, which users can quote in bug reports to find the request in the logs.
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// RequestIDHeader is the response header logger.Middleware sets to the request ID.
+// The body repeats it, so it must run before anything writes a response.
+const RequestIDHeader = "X-Request-Id"
+
+// Envelope wraps the payload of every successful JSON response.
+type Envelope struct {
+	Data       interface{} `json:"data"`
+	RequestID  string      `json:"request_id"           example:"4f9c2a4e-1c1b-4d55-9e43-0c1f6a3e8b21"`
+	Pagination *Pagination `json:"pagination,omitempty"` // set for lists
+}
+
+// Pagination describes one page of a list.
+type Pagination struct {
+	Count      int    `json:"count"                 example:"50"`          // items on this page
+	Limit      int    `json:"limit,omitempty"       example:"50"`          // page size asked for; 0 = unpaged
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJzIjoi..."` // pass back as cursor for the next page
+}
+
+// ErrorBody is the body of every error response.
+type ErrorBody struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Page is a list payload with its pagination metadata. JSON sends Items as the data
+// and Pagination alongside it; plain slices get a Pagination with only the count.
+type Page struct {
+	Items interface{}
+	Pagination
+}
+
+// RequestID returns the request ID logger.Middleware put on w, or "".
+func RequestID(w http.ResponseWriter) string {
+	return w.Header().Get(RequestIDHeader)
+}
+
+// Write sends v as the JSON body as it is.
+func Write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// JSON sends v wrapped in an Envelope.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	env := Envelope{Data: v, RequestID: RequestID(w)}
+	switch p := v.(type) {
+	case Page:
+		env.Data, env.Pagination = p.Items, &p.Pagination
+	case *Page:
+		env.Data, env.Pagination = p.Items, &p.Pagination
+	default:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+			env.Pagination = &Pagination{Count: rv.Len()}
+		}
+	}
+	Write(w, status, env)
+}
+
+// Error sends an error response with the given code and message.
+func Error(w http.ResponseWriter, status int, code, message string) {
+	Write(w, status, ErrorBody{Error: code, Message: message, RequestID: RequestID(w)})
+}
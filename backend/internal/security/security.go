@@ -0,0 +1,165 @@
+// Package security records security events (failed logins, rejected tokens,
+// permission denials, ...) to the security log, a store separate from the application
+// log that admins can query. Events are counted in the security_events expvar map as
+// they happen and written to the store in batches by a background worker.
+package security
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/proxy"
+)
+
+const (
+	// batchSize caps how many events one write stores.
+	batchSize = 100
+
+	// flushInterval bounds how long an event waits in the queue before it is written.
+	flushInterval = time.Second
+)
+
+var (
+	eventsRecorded = expvar.NewMap("security_events")         // by type
+	eventsDropped  = expvar.NewInt("security_events_dropped") // queue full or store failed
+)
+
+// Store persists a batch of events.
+type Store func(ctx context.Context, events []*model.SecurityEvent) error
+
+var (
+	mu     sync.RWMutex
+	queue  chan *model.SecurityEvent
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+)
+
+// Start launches the worker that writes recorded events to store. Until Start is
+// called (and after Stop) events are only counted.
+func Start(store Store, queueSize int) {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	ctx, stop := context.WithCancel(context.Background())
+
+	mu.Lock()
+	queue = make(chan *model.SecurityEvent, queueSize)
+	cancel = stop
+	mu.Unlock()
+
+	wg.Add(1)
+	go loop(ctx, queue, store)
+}
+
+// Stop writes the events still queued and halts the worker, waiting at most until ctx expires.
+func Stop(ctx context.Context) {
+	mu.Lock()
+	stop := cancel
+	queue, cancel = nil, nil
+	mu.Unlock()
+	if stop == nil {
+		return
+	}
+	stop()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Record logs an event of type typ (one of model.SecurityEventTypes) for request r.
+// userID is the user the request was made as or claimed to be, 0 if unknown; details
+// holds type-specific fields and may be nil. Record never blocks.
+func Record(r *http.Request, typ string, userID int64, details map[string]interface{}) {
+	eventsRecorded.Add(typ, 1)
+
+	e := &model.SecurityEvent{
+		Type:      typ,
+		IPAddress: proxy.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		RequestID: logger.GetRequestID(r.Context()),
+		CreatedAt: time.Now(),
+	}
+	if userID != 0 {
+		e.UserID = &userID
+	}
+	if details != nil {
+		if raw, err := json.Marshal(details); err == nil {
+			e.Details = raw
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- e:
+	default:
+		eventsDropped.Add(1)
+		logger.Warn(r.Context(), "Security log queue full, event dropped", map[string]interface{}{"type": typ})
+	}
+}
+
+func loop(ctx context.Context, queue <-chan *model.SecurityEvent, store Store) {
+	defer wg.Done()
+	logCtx := logger.WithMethod(context.Background(), "INTERNAL")
+	logCtx = logger.WithPath(logCtx, "Task/security-log")
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []*model.SecurityEvent
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := store(logCtx, batch); err != nil {
+			eventsDropped.Add(int64(len(batch)))
+			logger.ErrorLog(logCtx, "Failed to write security events", logger.ErrorDetails{
+				Code: "SECURITY_LOG_ERR", Details: err.Error(),
+			})
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case e := <-queue:
+			batch = append(batch, e)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-queue:
+					batch = append(batch, e)
+					if len(batch) >= batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// Usage accounting strategies decide how stored bytes are charged to users when
+// deduplication lets files of several users share blocks.
+const (
+	// AccountingLogical charges every file its full size, shared blocks included, so
+	// a user pays the same whether or not anyone else stores the same content.
+	AccountingLogical = "logical"
+	// AccountingFirstUploader charges each stored block once, to the user whose file
+	// has referenced it longest; everyone else stores it for free.
+	AccountingFirstUploader = "first_uploader"
+)
+
+// Accounting attributes stored bytes to users. Plan quotas are checked against it.
+type Accounting interface {
+	// Strategy names the strategy, one of the Accounting* constants.
+	Strategy() string
+	// ChargedBytes returns the bytes userID is charged for.
+	ChargedBytes(ctx context.Context, userID int64) (int64, error)
+}
+
+// NewAccounting returns the accounting for strategy.
+func NewAccounting(strategy string, userRepo *repository.UserRepository, blockRepo *repository.BlockRepository) (Accounting, error) {
+	switch strategy {
+	case AccountingLogical:
+		return logicalAccounting{userRepo: userRepo}, nil
+	case AccountingFirstUploader:
+		return firstUploaderAccounting{blockRepo: blockRepo}, nil
+	}
+	return nil, fmt.Errorf("unknown usage accounting %q (want %s or %s)", strategy, AccountingLogical, AccountingFirstUploader)
+}
+
+// logicalAccounting charges the user's used_bytes, kept up to date as files come and go.
+type logicalAccounting struct {
+	userRepo *repository.UserRepository
+}
+
+func (logicalAccounting) Strategy() string { return AccountingLogical }
+
+func (a logicalAccounting) ChargedBytes(ctx context.Context, userID int64) (int64, error) {
+	user, err := a.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return user.UsedBytes, nil
+}
+
+// firstUploaderAccounting sums the blocks the user is the first holder of.
+type firstUploaderAccounting struct {
+	blockRepo *repository.BlockRepository
+}
+
+func (firstUploaderAccounting) Strategy() string { return AccountingFirstUploader }
+
+func (a firstUploaderAccounting) ChargedBytes(ctx context.Context, userID int64) (int64, error) {
+	return a.blockRepo.FirstUploaderBytes(ctx, userID)
+}
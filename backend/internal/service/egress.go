@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// What happens once a user is over their plan's monthly egress (EGRESS_OVER_LIMIT).
+const (
+	EgressTrack    = "track"
+	EgressThrottle = "throttle"
+	EgressBlock    = "block"
+)
+
+// ErrEgressExceeded is returned, wrapped in a *PlanLimitError, when the owner of the
+// content is over their monthly egress and over-limit traffic is blocked.
+var ErrEgressExceeded = errors.New("monthly download traffic limit reached")
+
+// EgressService counts the bytes served on each user's behalf (their own downloads
+// and traffic through their share links) and applies their plan's monthly limit.
+type EgressService struct {
+	repo     *repository.EgressUsageRepository
+	planRepo *repository.PlanRepository
+	mode     string
+	rate     int64 // bytes per second once throttled
+}
+
+// NewEgressService returns an EgressService applying mode over the limit; throttled
+// traffic is paced to throttleKBps.
+func NewEgressService(repo *repository.EgressUsageRepository, planRepo *repository.PlanRepository, mode string, throttleKBps int) (*EgressService, error) {
+	switch mode {
+	case EgressTrack, EgressBlock:
+	case EgressThrottle:
+		if throttleKBps <= 0 {
+			return nil, fmt.Errorf("throttle rate must be positive, got %d KB/s", throttleKBps)
+		}
+	default:
+		return nil, fmt.Errorf("unknown over-limit mode %q (want %s, %s or %s)", mode, EgressTrack, EgressThrottle, EgressBlock)
+	}
+	return &EgressService{repo: repo, planRepo: planRepo, mode: mode, rate: int64(throttleKBps) * 1024}, nil
+}
+
+// Usage returns userID's egress this month.
+func (s *EgressService) Usage(ctx context.Context, userID int64) (*model.EgressUsage, error) {
+	used, err := s.repo.ThisMonth(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.planRepo.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage := &model.EgressUsage{UsedBytes: used, OverLimitMode: s.mode}
+	if plan != nil && plan.EgressBytes != nil {
+		usage.LimitBytes = plan.EgressBytes
+		usage.OverLimit = used >= *plan.EgressBytes
+	}
+	return usage, nil
+}
+
+// Allow is checked before serving content on ownerID's behalf. It returns the rate in
+// bytes per second to pace the transfer to (0 = full speed), or a *PlanLimitError
+// wrapping ErrEgressExceeded if the owner is over their limit and such traffic is
+// blocked.
+func (s *EgressService) Allow(ctx context.Context, ownerID int64) (int64, error) {
+	if s.mode == EgressTrack {
+		return 0, nil
+	}
+	usage, err := s.Usage(ctx, ownerID)
+	if err != nil || !usage.OverLimit {
+		return 0, err
+	}
+	if s.mode == EgressBlock {
+		return 0, &PlanLimitError{Err: ErrEgressExceeded, Limit: *usage.LimitBytes}
+	}
+	return s.rate, nil
+}
+
+// Record counts bytes served on ownerID's behalf towards this month.
+func (s *EgressService) Record(ctx context.Context, ownerID, bytes int64) error {
+	if bytes <= 0 {
+		return nil
+	}
+	return s.repo.Add(ctx, ownerID, bytes)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// ErrFeatureDisabled is returned, wrapped in a *FeatureError, for a feature that is off
+// for the user.
+var ErrFeatureDisabled = errors.New("feature not available")
+
+// FeatureError names the feature that refused an operation.
+type FeatureError struct {
+	Feature string
+}
+
+func (e *FeatureError) Error() string {
+	return fmt.Sprintf("%s: %s is not available on this server or your plan", ErrFeatureDisabled, e.Feature)
+}
+func (e *FeatureError) Unwrap() error { return ErrFeatureDisabled }
+
+// FeatureService answers which feature flags are on for a user: a feature is on
+// unless the deployment disables it or the user's plan switches it off.
+type FeatureService struct {
+	planRepo *repository.PlanRepository
+	disabled map[string]bool
+}
+
+// NewFeatureService returns a FeatureService with the deployment-wide disabled
+// features; unknown names are rejected so a typo does not leave a feature on.
+func NewFeatureService(planRepo *repository.PlanRepository, disabled []string) (*FeatureService, error) {
+	s := &FeatureService{planRepo: planRepo, disabled: make(map[string]bool)}
+	for _, name := range disabled {
+		if !model.IsFeature(name) {
+			return nil, fmt.Errorf("unknown feature %q", name)
+		}
+		s.disabled[name] = true
+	}
+	return s, nil
+}
+
+// ForUser returns every feature flag and whether it is on for userID.
+func (s *FeatureService) ForUser(ctx context.Context, userID int64) (map[string]bool, error) {
+	plan, err := s.planRepo.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	features := make(map[string]bool, len(model.Features))
+	for _, name := range model.Features {
+		features[name] = !s.disabled[name] && (plan == nil || plan.FeatureEnabled(name))
+	}
+	return features, nil
+}
+
+// Enabled reports whether feature is on for userID.
+func (s *FeatureService) Enabled(ctx context.Context, userID int64, feature string) (bool, error) {
+	if s.disabled[feature] {
+		return false, nil
+	}
+	plan, err := s.planRepo.ForUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return plan == nil || plan.FeatureEnabled(feature), nil
+}
+
+// Require returns a *FeatureError if feature is off for userID.
+func (s *FeatureService) Require(ctx context.Context, userID int64, feature string) error {
+	on, err := s.Enabled(ctx, userID, feature)
+	if err != nil {
+		return fmt.Errorf("check feature %s: %w", feature, err)
+	}
+	if !on {
+		return &FeatureError{Feature: feature}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+// Package service holds the multi-step business operations behind the HTTP handlers
+// (storing uploads, deleting and purging files, moving files and folders, issuing
+// share links), so any frontend can reuse them and the steps that must succeed
+// together run in one place. Services return repository errors unchanged plus the
+// validation sentinels below; turning them into responses is the caller's job.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/naratel/naratel-box/backend/internal/block"
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// ErrFolderNotFound is returned when a target or parent folder does not exist or
+// belongs to another user. Both cases look the same so folder IDs cannot be probed.
+var ErrFolderNotFound = errors.New("folder not found")
+
+// ErrContentChanged is returned by WriteAt when the file's content is not the version
+// the write was meant for.
+var ErrContentChanged = errors.New("file content changed")
+
+// ContentError reports that uploaded content could not be split into blocks and
+// stored. Its message is the processor's.
+type ContentError struct {
+	Err error
+}
+
+func (e *ContentError) Error() string { return e.Err.Error() }
+func (e *ContentError) Unwrap() error { return e.Err }
+
+type FileService struct {
+	processor  *block.Processor
+	fileRepo   *repository.FileRepository
+	folderRepo *repository.FolderRepository
+	blockRepo  *repository.BlockRepository
+	checksums  *repository.ChecksumRepository
+	plans      *PlanService
+	scheduler  *jobs.Scheduler // runs the block GC after purges
+}
+
+func NewFileService(processor *block.Processor, fileRepo *repository.FileRepository, folderRepo *repository.FolderRepository, blockRepo *repository.BlockRepository, checksums *repository.ChecksumRepository, plans *PlanService, scheduler *jobs.Scheduler) *FileService {
+	return &FileService{
+		processor:  processor,
+		fileRepo:   fileRepo,
+		folderRepo: folderRepo,
+		blockRepo:  blockRepo,
+		checksums:  checksums,
+		plans:      plans,
+		scheduler:  scheduler,
+	}
+}
+
+// StoreRequest describes content to store as a new file.
+type StoreRequest struct {
+	UserID   int64
+	Name     string
+	MimeType string
+	Size     int64 // expected size, picks the block size; -1 if unknown
+	FolderID *int64
+	Client   string // modified_client of the new file
+	Content  io.Reader
+
+	// IfNoneExists only creates the file if the folder has no live file called Name.
+	IfNoneExists bool
+}
+
+// Store splits req.Content into blocks and records the file with its block list in one
+// transaction. If the file cannot be recorded, the block references taken for it are
+// released again so the block GC can collect content nothing points to. The caller
+// checks that req.FolderID is the user's (see RequireFolder).
+//
+// Besides the file it returns how its content was stored (new versus deduplicated
+// blocks, bytes written to S3). The content's checksums are recorded with the file.
+//
+// The owner's plan limits are checked against req.Size before the content is read and
+// against the actual size once it is stored; a refusal is a *PlanLimitError.
+//
+// With req.IfNoneExists, a name already taken in the folder fails with
+// repository.ErrFileExists and returns the existing file (nil if it vanished
+// meanwhile). The name is checked before the content is stored and again atomically
+// with the insert, so concurrent conditional uploads cannot both succeed.
+func (s *FileService) Store(ctx context.Context, req StoreRequest) (*model.File, block.Stats, error) {
+	if req.IfNoneExists {
+		existing, err := s.fileRepo.FindByName(ctx, req.UserID, req.FolderID, req.Name)
+		if err != nil {
+			return nil, block.Stats{}, err
+		}
+		if existing != nil {
+			return existing, block.Stats{}, repository.ErrFileExists
+		}
+	}
+	if req.Size >= 0 {
+		if err := s.plans.CheckUpload(ctx, req.UserID, req.Size); err != nil {
+			return nil, block.Stats{}, err
+		}
+	}
+
+	chunker := s.processor.ChunkerFor(req.Size)
+	blockIDs, stats, err := s.processor.Process(ctx, req.UserID, req.Content, chunker, req.MimeType)
+	if err != nil {
+		return nil, block.Stats{}, &ContentError{Err: err}
+	}
+	if stats.LogicalBytes != req.Size {
+		if err := s.plans.CheckUpload(ctx, req.UserID, stats.LogicalBytes); err != nil {
+			s.release(ctx, blockIDs)
+			return nil, block.Stats{}, err
+		}
+	}
+
+	file, err := s.fileRepo.CreateWithBlocks(ctx, req.UserID, req.Name, req.MimeType, stats.LogicalBytes, chunker.BlockSize(), req.FolderID, req.Client, blockIDs, req.IfNoneExists)
+	if err != nil {
+		s.release(ctx, blockIDs)
+		if errors.Is(err, repository.ErrFileExists) {
+			existing, findErr := s.fileRepo.FindByName(ctx, req.UserID, req.FolderID, req.Name)
+			if findErr != nil {
+				return nil, block.Stats{}, findErr
+			}
+			return existing, block.Stats{}, err
+		}
+		return nil, block.Stats{}, err
+	}
+
+	// The checksums can be rebuilt by verifying the file, so failing to record them
+	// does not fail the upload.
+	if err := s.checksums.Put(ctx, file.ID, stats.SHA256, stats.BlockSHA256, false); err != nil {
+		logger.Warn(ctx, "Failed to record file checksums", map[string]interface{}{
+			"file_id": file.ID, "error": err.Error(),
+		})
+	}
+	return file, stats, nil
+}
+
+// CheckUpload checks a file of size bytes against userID's plan before any content is
+// received, e.g. when a resumable upload starts. Store checks again itself.
+func (s *FileService) CheckUpload(ctx context.Context, userID, size int64) error {
+	return s.plans.CheckUpload(ctx, userID, size)
+}
+
+// UploadQuota returns the quota a resumable upload of size bytes is held to when it
+// is finalized (see PlanService.UsedBytesQuota); nil means there is nothing left to
+// check there.
+func (s *FileService) UploadQuota(ctx context.Context, userID, size int64) (*int64, error) {
+	return s.plans.UsedBytesQuota(ctx, userID, size)
+}
+
+// SplitUpload stores the content of a resumable upload, received as the fixed-size
+// blocks chunks, split again with the chunking configured for its size (see
+// block.Processor.Resplit), so the file deduplicates like one uploaded in one piece.
+// The result is for UploadSessionRepository.Finalize; its blocks hold one reference
+// each, which go back through Release if the session is not finalized with them.
+func (s *FileService) SplitUpload(ctx context.Context, sess *model.UploadSession, chunks []*model.Block) (*repository.SessionBlocks, error) {
+	chunker := s.processor.ChunkerFor(sess.TotalSize)
+	blockIDs, stats, err := s.processor.Resplit(ctx, sess.UserID, chunks, chunker, sess.MimeType)
+	if err != nil {
+		return nil, &ContentError{Err: err}
+	}
+	return &repository.SessionBlocks{
+		BlockIDs: blockIDs, BlockSize: chunker.BlockSize(), BlocksNew: stats.BlocksNew, UploadedBytes: stats.UploadedBytes,
+	}, nil
+}
+
+// Release gives back the block references of a SplitUpload result that was not used.
+func (s *FileService) Release(ctx context.Context, blocks *repository.SessionBlocks) {
+	s.release(ctx, blocks.BlockIDs)
+}
+
+// WriteRequest is a write of Data at Offset into an existing file.
+type WriteRequest struct {
+	UserID int64
+	FileID int64
+	Offset int64
+	Data   []byte
+	Client string // modified_client of the file
+
+	// IfMatch, if set, is called with the ETag of the file's current content and
+	// refuses the write (ErrContentChanged) by returning false.
+	IfMatch func(etag string) bool
+}
+
+// WriteResult is how WriteAt stored a write: blocks [From, To) of the old block list
+// were replaced by Blocks new entries, stored as Stats describes. ETag identifies the
+// new content.
+type WriteResult struct {
+	ETag   string
+	From   int
+	To     int
+	Blocks int
+	Stats  block.Stats
+}
+
+// WriteAt writes req.Data into the user's file at req.Offset, replacing only the
+// blocks the write touches (see block.Processor.Rewrite); the file may grow but not
+// get a hole. The new content is a new version of the file with its own ETag, waiting
+// for a new malware scan verdict. A write racing another change of the file fails with
+// ErrContentChanged and stores nothing.
+// Growth is checked against the owner's plan like an upload of that many bytes; a
+// refusal is a *PlanLimitError. Returns the updated file and how the write was stored.
+func (s *FileService) WriteAt(ctx context.Context, req WriteRequest) (*model.File, *WriteResult, error) {
+	file, err := s.fileRepo.FindByIDAndUserID(ctx, req.FileID, req.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	blocks, err := s.blockRepo.FindByFileID(ctx, repository.ForUser(req.UserID), file.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.IfMatch != nil && !req.IfMatch(model.BlocksETag(blocks)) {
+		return nil, nil, ErrContentChanged
+	}
+	if size := req.Offset + int64(len(req.Data)); size > file.TotalSize {
+		if err := s.plans.CheckResize(ctx, req.UserID, size, size-file.TotalSize); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	chunker := s.processor.ChunkerFor(file.TotalSize)
+	if !chunker.ContentDefined() && file.BlockSize > 0 {
+		chunker = block.Fixed(file.BlockSize)
+	}
+	rw, err := s.processor.Rewrite(ctx, req.UserID, blocks, req.Offset, req.Data, chunker, file.MimeType)
+	if err != nil {
+		if errors.Is(err, block.ErrWriteOutOfRange) {
+			return nil, nil, err
+		}
+		return nil, nil, &ContentError{Err: err}
+	}
+
+	current := make([]int64, len(blocks))
+	for i, b := range blocks {
+		current[i] = b.ID
+	}
+	updated, err := s.fileRepo.ReplaceBlocks(ctx, file.ID, req.UserID, current, rw.From, rw.To, rw.BlockIDs, rw.TotalSize, req.Client)
+	if err != nil {
+		s.release(ctx, rw.BlockIDs)
+		if errors.Is(err, repository.ErrFileChanged) {
+			return nil, nil, ErrContentChanged
+		}
+		return nil, nil, err
+	}
+
+	res := &WriteResult{From: rw.From, To: rw.To, Blocks: len(rw.BlockIDs), Stats: rw.Stats}
+	if blocks, err = s.blockRepo.FindByFileID(ctx, repository.ForUser(req.UserID), file.ID); err == nil {
+		res.ETag = model.BlocksETag(blocks)
+	} else {
+		logger.Warn(ctx, "Failed to read back blocks of written file", map[string]interface{}{
+			"file_id": file.ID, "error": err.Error(),
+		})
+	}
+	return updated, res, nil
+}
+
+// release gives back one reference per entry of blockIDs. Failures are logged only: a
+// leaked reference keeps a block alive, it never loses data.
+func (s *FileService) release(ctx context.Context, blockIDs []int64) {
+	for _, id := range blockIDs {
+		if err := s.blockRepo.Release(ctx, id); err != nil {
+			logger.Warn(ctx, "Failed to release block of unsaved file", map[string]interface{}{
+				"block_id": id, "error": err.Error(),
+			})
+		}
+	}
+}
+
+// RequireFolder checks that folderID (nil = root) exists and belongs to userID,
+// returning ErrFolderNotFound otherwise.
+func (s *FileService) RequireFolder(ctx context.Context, userID int64, folderID *int64) error {
+	return requireFolder(ctx, s.folderRepo, userID, folderID)
+}
+
+// Rename gives the user's file a new name. With overwrite, any other file already
+// called name in its folder is moved to the trash atomically with the rename, as
+// editors saving through a temporary file expect; the IDs of those files are returned.
+func (s *FileService) Rename(ctx context.Context, userID, fileID int64, name, client string, overwrite bool) (*model.File, []int64, error) {
+	if !overwrite {
+		file, err := s.fileRepo.Rename(ctx, fileID, userID, name, client)
+		return file, nil, err
+	}
+	return s.fileRepo.Replace(ctx, fileID, userID, repository.FilePlacement{Name: name}, client)
+}
+
+// Move puts the user's file into folderID (nil = root) after checking the folder. With
+// overwrite, a file of the same name already there is trashed as in Rename.
+func (s *FileService) Move(ctx context.Context, userID, fileID int64, folderID *int64, client string, overwrite bool) (*model.File, []int64, error) {
+	if err := s.RequireFolder(ctx, userID, folderID); err != nil {
+		return nil, nil, err
+	}
+	if !overwrite {
+		file, err := s.fileRepo.Move(ctx, fileID, userID, folderID, client)
+		return file, nil, err
+	}
+	return s.fileRepo.Replace(ctx, fileID, userID, repository.FilePlacement{FolderID: folderID, Move: true}, client)
+}
+
+func requireFolder(ctx context.Context, folderRepo *repository.FolderRepository, userID int64, folderID *int64) error {
+	if folderID == nil {
+		return nil
+	}
+	folder, err := folderRepo.FindByIDAndUserID(ctx, *folderID, userID)
+	if err != nil {
+		return fmt.Errorf("look up folder: %w", err)
+	}
+	if folder == nil {
+		return ErrFolderNotFound
+	}
+	return nil
+}
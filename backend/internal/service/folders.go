@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// Folder validation failures.
+var (
+	ErrFolderCycle      = errors.New("cannot move folder into itself or its subfolders")
+	ErrDepthExceeded    = errors.New("folder depth limit exceeded")
+	ErrChildrenExceeded = errors.New("subfolder limit exceeded")
+)
+
+// LimitError reports which configured limit a folder placement would exceed. It
+// matches ErrDepthExceeded or ErrChildrenExceeded with errors.Is.
+type LimitError struct {
+	Kind  error
+	Limit int
+}
+
+func (e *LimitError) Error() string { return fmt.Sprintf("%s (limit %d)", e.Kind, e.Limit) }
+func (e *LimitError) Unwrap() error { return e.Kind }
+
+type FolderService struct {
+	folderRepo *repository.FolderRepository
+
+	maxDepth    int // deepest allowed nesting level (root-level folders are 1); 0 = unlimited
+	maxChildren int // subfolders allowed directly under one folder (or the root); 0 = unlimited
+}
+
+func NewFolderService(folderRepo *repository.FolderRepository, maxDepth, maxChildren int) *FolderService {
+	return &FolderService{
+		folderRepo:  folderRepo,
+		maxDepth:    maxDepth,
+		maxChildren: maxChildren,
+	}
+}
+
+// checkPlacement verifies that a folder subtree height levels tall may be placed under
+// parentID (nil = root): the parent must be the user's, and neither the depth nor the
+// children limit may be exceeded.
+func (s *FolderService) checkPlacement(ctx context.Context, userID int64, parentID *int64, height int) error {
+	parentDepth := 0
+	if parentID != nil {
+		crumbs, err := s.folderRepo.GetBreadcrumb(ctx, *parentID, userID)
+		if err != nil {
+			return fmt.Errorf("look up parent folder: %w", err)
+		}
+		if len(crumbs) == 0 {
+			return ErrFolderNotFound
+		}
+		parentDepth = len(crumbs)
+	}
+
+	if s.maxDepth > 0 && parentDepth+height > s.maxDepth {
+		return &LimitError{Kind: ErrDepthExceeded, Limit: s.maxDepth}
+	}
+
+	if s.maxChildren > 0 {
+		n, err := s.folderRepo.CountChildren(ctx, userID, parentID)
+		if err != nil {
+			return fmt.Errorf("count subfolders: %w", err)
+		}
+		if n >= s.maxChildren {
+			return &LimitError{Kind: ErrChildrenExceeded, Limit: s.maxChildren}
+		}
+	}
+	return nil
+}
+
+// Create makes a folder called name under parentID (nil = root) within the limits.
+func (s *FolderService) Create(ctx context.Context, userID int64, parentID *int64, name string) (*model.Folder, error) {
+	if err := s.checkPlacement(ctx, userID, parentID, 1); err != nil {
+		return nil, err
+	}
+	return s.folderRepo.Create(ctx, userID, parentID, name)
+}
+
+// Move re-parents the user's folder under parentID (nil = root). Moving a folder into
+// its own subtree would detach it into a cycle and is refused with ErrFolderCycle; the
+// limits are only checked when the parent actually changes. A missing folder is
+// repository.ErrFolderNotFound.
+func (s *FolderService) Move(ctx context.Context, userID, folderID int64, parentID *int64) (*model.Folder, error) {
+	current, err := s.folderRepo.FindByIDAndUserID(ctx, folderID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("look up folder: %w", err)
+	}
+	if current == nil {
+		return nil, repository.ErrFolderNotFound
+	}
+
+	if parentID != nil {
+		subtree, err := s.folderRepo.ListSubtreeIDs(ctx, folderID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("look up folder: %w", err)
+		}
+		for _, id := range subtree {
+			if id == *parentID {
+				return nil, ErrFolderCycle
+			}
+		}
+	}
+
+	if !sameParent(current.ParentID, parentID) {
+		height, err := s.folderRepo.SubtreeHeight(ctx, folderID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("look up folder: %w", err)
+		}
+		if err := s.checkPlacement(ctx, userID, parentID, height); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.folderRepo.Move(ctx, folderID, userID, parentID)
+}
+
+func sameParent(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
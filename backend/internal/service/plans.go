@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// Plan limit failures; they arrive wrapped in a *PlanLimitError.
+var (
+	ErrQuotaExceeded    = errors.New("storage quota exceeded")
+	ErrPlanFileTooLarge = errors.New("file exceeds the plan's size limit")
+)
+
+// PlanLimitError reports which limit of the user's plan refused an operation. Limit
+// is the limit in bytes for ErrQuotaExceeded and ErrPlanFileTooLarge.
+type PlanLimitError struct {
+	Err   error
+	Limit int64
+}
+
+func (e *PlanLimitError) Error() string { return e.Err.Error() }
+func (e *PlanLimitError) Unwrap() error { return e.Err }
+
+// PlanService resolves users' plans and enforces their limits. Quotas are checked
+// against the bytes accounting charges the user; a quota set on the user overrides
+// their plan's.
+type PlanService struct {
+	planRepo   *repository.PlanRepository
+	userRepo   *repository.UserRepository
+	accounting Accounting
+}
+
+func NewPlanService(planRepo *repository.PlanRepository, userRepo *repository.UserRepository, accounting Accounting) *PlanService {
+	return &PlanService{planRepo: planRepo, userRepo: userRepo, accounting: accounting}
+}
+
+// ForUser returns the plan userID is held to, or nil if no limits apply.
+func (s *PlanService) ForUser(ctx context.Context, userID int64) (*model.Plan, error) {
+	return s.planRepo.ForUser(ctx, userID)
+}
+
+// ChargedBytes returns the stored bytes userID is charged for under the configured accounting.
+func (s *PlanService) ChargedBytes(ctx context.Context, userID int64) (int64, error) {
+	return s.accounting.ChargedBytes(ctx, userID)
+}
+
+// Accounting returns the configured usage accounting strategy.
+func (s *PlanService) Accounting() string {
+	return s.accounting.Strategy()
+}
+
+// Quota returns the storage quota userID is held to: their own if an admin set one,
+// else their plan's. nil = unlimited.
+func (s *PlanService) Quota(ctx context.Context, userID int64) (*int64, error) {
+	plan, err := s.planRepo.ForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.quota(ctx, userID, plan)
+}
+
+// CheckUpload checks that userID may add a file of size bytes: within the plan's file
+// size limit and, together with what they are already charged for, within their quota.
+// The whole size counts, even if some of it may turn out to be deduplicated.
+func (s *PlanService) CheckUpload(ctx context.Context, userID, size int64) error {
+	return s.CheckResize(ctx, userID, size, size)
+}
+
+// UsedBytesQuota returns the limit a new file of size bytes must fit under together
+// with userID's used_bytes, for a caller that checks it in the same transaction that
+// adds the file: their quota under logical accounting, where used_bytes is what they
+// are charged. Other accountings cannot be checked that way, so the file is checked
+// against them here instead and nil returned.
+func (s *PlanService) UsedBytesQuota(ctx context.Context, userID, size int64) (*int64, error) {
+	if s.accounting.Strategy() != AccountingLogical {
+		return nil, s.CheckUpload(ctx, userID, size)
+	}
+	return s.Quota(ctx, userID)
+}
+
+// CheckResize checks that userID may grow a file by growth bytes to size bytes: the
+// new size within the plan's file size limit and the growth within their quota.
+func (s *PlanService) CheckResize(ctx context.Context, userID, size, growth int64) error {
+	plan, err := s.planRepo.ForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if plan != nil && plan.MaxFileBytes != nil && size > *plan.MaxFileBytes {
+		return &PlanLimitError{Err: ErrPlanFileTooLarge, Limit: *plan.MaxFileBytes}
+	}
+	quota, err := s.quota(ctx, userID, plan)
+	if err != nil || quota == nil {
+		return err
+	}
+	charged, err := s.accounting.ChargedBytes(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("look up storage usage: %w", err)
+	}
+	if charged+growth > *quota {
+		return &PlanLimitError{Err: ErrQuotaExceeded, Limit: *quota}
+	}
+	return nil
+}
+
+// quota resolves userID's quota given the plan they are held to (nil = none).
+func (s *PlanService) quota(ctx context.Context, userID int64, plan *model.Plan) (*int64, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("look up user quota: %w", err)
+	}
+	if user.QuotaBytes != nil {
+		return user.QuotaBytes, nil
+	}
+	if plan != nil {
+		return plan.QuotaBytes, nil
+	}
+	return nil, nil
+}
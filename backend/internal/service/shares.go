@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/naratel/naratel-box/backend/internal/model"
+	"github.com/naratel/naratel-box/backend/internal/repository"
+)
+
+// Share link validation failures.
+var (
+	ErrTakenDown             = errors.New("this file was taken down after an abuse report and cannot be shared")
+	ErrPublicLinksForbidden  = errors.New("public links are not allowed in this folder")
+	ErrSharePasswordRequired = errors.New("links in this folder must have a password")
+)
+
+// LinkOptions are the caller's choices for a new share link; zero values fall back to
+// the folder defaults.
+type LinkOptions struct {
+	Password       string
+	ExpiresInHours int
+}
+
+// defaultShareExpiry applies when neither the request nor any enclosing folder sets one.
+const defaultShareExpiry = 7 * 24 * time.Hour
+
+type ShareService struct {
+	shareRepo       *repository.ShareLinkRepository
+	folderShareRepo *repository.FolderShareLinkRepository
+	folderRepo      *repository.FolderRepository
+	features        *FeatureService
+}
+
+func NewShareService(shareRepo *repository.ShareLinkRepository, folderShareRepo *repository.FolderShareLinkRepository, folderRepo *repository.FolderRepository, features *FeatureService) *ShareService {
+	return &ShareService{
+		shareRepo:       shareRepo,
+		folderShareRepo: folderShareRepo,
+		folderRepo:      folderRepo,
+		features:        features,
+	}
+}
+
+// Settings checks that public links are enabled for userID, then resolves the share
+// settings for a file in folderID (nil = root) and checks opts against them, so
+// callers can refuse a link before doing any other work.
+func (s *ShareService) Settings(ctx context.Context, userID int64, folderID *int64, opts LinkOptions) (*model.EffectiveShareSettings, error) {
+	if err := s.features.Require(ctx, userID, model.FeaturePublicLinks); err != nil {
+		return nil, err
+	}
+	settings, err := s.folderRepo.EffectiveShareSettings(ctx, folderID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve folder share settings: %w", err)
+	}
+	if !settings.AllowPublicLinks {
+		return nil, ErrPublicLinksForbidden
+	}
+	if settings.RequirePassword && opts.Password == "" {
+		return nil, ErrSharePasswordRequired
+	}
+	return settings, nil
+}
+
+// Issue creates a share link for file with opts, falling back to settings (from
+// Settings) for the expiry: explicit request > folder default > system default.
+func (s *ShareService) Issue(ctx context.Context, file *model.File, userID int64, opts LinkOptions, settings *model.EffectiveShareSettings) (*model.ShareLink, error) {
+	if file.TakenDownAt != nil {
+		return nil, ErrTakenDown
+	}
+
+	token, passwordHash, expiresAt, err := newLinkSecrets(opts, settings)
+	if err != nil {
+		return nil, err
+	}
+	return s.shareRepo.Create(ctx, file.ID, userID, token, expiresAt, passwordHash)
+}
+
+// CheckShareUploads checks that userID may let folder share links accept uploads.
+func (s *ShareService) CheckShareUploads(ctx context.Context, userID int64) error {
+	return s.features.Require(ctx, userID, model.FeatureShareUploads)
+}
+
+// IssueFolder creates a share link to the files of folder with opts. link carries the
+// view (model.FolderViewList or model.FolderViewGallery) and upload settings; the
+// rest is filled in here, with settings resolved for the folder itself. Expiry falls
+// back the same way as in Issue.
+func (s *ShareService) IssueFolder(ctx context.Context, folder *model.Folder, userID int64, link *model.FolderShareLink, opts LinkOptions, settings *model.EffectiveShareSettings) (*model.FolderShareLink, error) {
+	token, passwordHash, expiresAt, err := newLinkSecrets(opts, settings)
+	if err != nil {
+		return nil, err
+	}
+	link.FolderID, link.UserID, link.Token = folder.ID, userID, token
+	link.PasswordHash, link.ExpiresAt = passwordHash, expiresAt
+	return s.folderShareRepo.Create(ctx, link)
+}
+
+// newLinkSecrets generates a link token and derives its password hash (nil without a
+// password) and expiry: explicit request > folder default > system default.
+func newLinkSecrets(opts LinkOptions, settings *model.EffectiveShareSettings) (string, *string, *time.Time, error) {
+	var passwordHash *string
+	if opts.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("hash share link password: %w", err)
+		}
+		hashedStr := string(hashed)
+		passwordHash = &hashedStr
+	}
+
+	token, err := NewLinkToken()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("generate share token: %w", err)
+	}
+
+	expiry := defaultShareExpiry
+	if opts.ExpiresInHours > 0 {
+		expiry = time.Duration(opts.ExpiresInHours) * time.Hour
+	} else if settings.DefaultExpiryHours != nil {
+		expiry = time.Duration(*settings.DefaultExpiryHours) * time.Hour
+	}
+	expiresAt := time.Now().Add(expiry)
+	return token, passwordHash, &expiresAt, nil
+}
+
+// NewLinkToken returns an unguessable token for public links: share links, upload
+// request links and invitations.
+func NewLinkToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+
+	"github.com/naratel/naratel-box/backend/internal/jobs"
+	"github.com/naratel/naratel-box/backend/internal/model"
+)
+
+// emptyTrashBatchSize bounds how many file rows one purge transaction locks.
+const emptyTrashBatchSize = 100
+
+// Deleting a file is two steps: Trash hides it while its blocks stay referenced, so a
+// Restore always finds its content; Purge (or EmptyTrash, or retention expiry) deletes
+// it and releases its block references, after which the block GC removes blocks no
+// file uses any more from S3.
+
+// Trash moves the user's file to the trash. Returns repository.ErrFileNotFound if the
+// user has no such live file.
+func (s *FileService) Trash(ctx context.Context, userID, fileID int64, client string) error {
+	return s.fileRepo.Trash(ctx, fileID, userID, client)
+}
+
+// Restore takes the user's file out of the trash, back into its folder or the root if
+// the folder is gone. Returns repository.ErrFileNotFound if it is not in the trash.
+func (s *FileService) Restore(ctx context.Context, userID, fileID int64, client string) (*model.File, error) {
+	return s.fileRepo.Restore(ctx, fileID, userID, client)
+}
+
+// Purge permanently deletes the user's trashed file and releases its block references,
+// starting the block GC if any were released. Returns the number of blocks released,
+// or repository.ErrFileNotFound if the file is not in the trash.
+func (s *FileService) Purge(ctx context.Context, userID, fileID int64) (int64, error) {
+	released, err := s.fileRepo.Purge(ctx, fileID, userID)
+	if err != nil {
+		return 0, err
+	}
+	s.collectGarbage(released)
+	return released, nil
+}
+
+// EmptyTrash permanently deletes every trashed file of the user in batches, then starts
+// the block GC. On failure the batches already purged stay purged; their counts are
+// returned with the error.
+func (s *FileService) EmptyTrash(ctx context.Context, userID int64) (files, released int64, err error) {
+	defer func() { s.collectGarbage(released) }()
+	for {
+		n, r, err := s.fileRepo.PurgeTrashOfUser(ctx, userID, emptyTrashBatchSize)
+		if err != nil {
+			return files, released, err
+		}
+		files += n
+		released += r
+		if n < emptyTrashBatchSize {
+			return files, released, nil
+		}
+	}
+}
+
+// collectGarbage starts the block GC now rather than at its next tick when released
+// block references may have left blocks unused.
+func (s *FileService) collectGarbage(released int64) {
+	if released > 0 && s.scheduler != nil {
+		s.scheduler.Trigger(jobs.TaskCollectBlockGarbage)
+	}
+}
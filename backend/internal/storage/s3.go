@@ -2,18 +2,46 @@ package storage
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"github.com/naratel/naratel-box/backend/internal/chaos"
+	"github.com/naratel/naratel-box/backend/internal/logger"
+)
+
+// Runtime counters published on the admin /debug/vars endpoint.
+var (
+	s3InFlight    = expvar.NewInt("s3_inflight_requests") // requests awaiting a response (GETs until the body is closed)
+	s3OpenStreams = expvar.NewInt("s3_open_get_streams")
 )
 
+// trackedBody decrements the open-stream counter once the caller closes a GET body.
+type trackedBody struct {
+	io.ReadCloser
+	once bool
+}
+
+func (b *trackedBody) Close() error {
+	if !b.once {
+		b.once = true
+		s3OpenStreams.Add(-1)
+	}
+	return b.ReadCloser.Close()
+}
+
 // S3Client wraps the AWS S3 client for QNAP-compatible operations.
 type S3Client struct {
 	client *s3.Client
 	bucket string
+	faults *chaos.Injector // nil outside chaos mode
 }
 
 // NewS3Client creates a new S3 client configured for QNAP (or any S3-compatible store).
@@ -28,6 +56,7 @@ func NewS3Client(endpoint, accessKey, secretKey, region, bucket string, forcePat
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
 		o.BaseEndpoint = aws.String(endpoint)
 		o.UsePathStyle = forcePathStyle // required for QNAP / MinIO
+		o.APIOptions = append(o.APIOptions, addRequestID)
 	})
 
 	return &S3Client{
@@ -36,8 +65,36 @@ func NewS3Client(endpoint, accessKey, secretKey, region, bucket string, forcePat
 	}, nil
 }
 
-// PutObject uploads data to S3 with key as filename. The key is the SHA-256 hash.
+// addRequestID tags every S3 call made for an API request with its ID: in an
+// X-Request-Id header and at the end of the User-Agent, which most S3 servers
+// (QNAP and MinIO included) write to their access logs.
+func addRequestID(stack *middleware.Stack) error {
+	return stack.Build.Add(middleware.BuildMiddlewareFunc("NaratelRequestID",
+		func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (middleware.BuildOutput, middleware.Metadata, error) {
+			if req, ok := in.Request.(*smithyhttp.Request); ok {
+				if id := logger.GetRequestID(ctx); id != "" {
+					req.Header.Set("X-Request-Id", id)
+					req.Header.Set("User-Agent", req.Header.Get("User-Agent")+" request-id/"+id)
+				}
+			}
+			return next.HandleBuild(ctx, in)
+		}), middleware.After)
+}
+
+// InjectFaults makes every operation consult in first (chaos mode, test environments only).
+func (s *S3Client) InjectFaults(in *chaos.Injector) {
+	s.faults = in
+}
+
+// PutObject uploads data to S3 with key as filename.
 func (s *S3Client) PutObject(ctx context.Context, key string, body io.Reader, sizeBytes int64) error {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "PutObject"); err != nil {
+		return fmt.Errorf("S3Client.PutObject key=%s: %w", key, err)
+	}
+
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:        aws.String(s.bucket),
 		Key:           aws.String(key),
@@ -53,6 +110,13 @@ func (s *S3Client) PutObject(ctx context.Context, key string, body io.Reader, si
 // GetObject fetches an object from S3 and returns a ReadCloser.
 // Caller is responsible for closing the returned body.
 func (s *S3Client) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "GetObject"); err != nil {
+		return nil, fmt.Errorf("S3Client.GetObject key=%s: %w", key, err)
+	}
+
 	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -60,11 +124,61 @@ func (s *S3Client) GetObject(ctx context.Context, key string) (io.ReadCloser, er
 	if err != nil {
 		return nil, fmt.Errorf("S3Client.GetObject key=%s: %w", key, err)
 	}
-	return out.Body, nil
+	s3OpenStreams.Add(1)
+	return &trackedBody{ReadCloser: out.Body}, nil
+}
+
+// GetObjectRange fetches bytes [start, end] (inclusive) of an object.
+// Caller is responsible for closing the returned body.
+func (s *S3Client) GetObjectRange(ctx context.Context, key string, start, end int64) (io.ReadCloser, error) {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "GetObjectRange"); err != nil {
+		return nil, fmt.Errorf("S3Client.GetObjectRange key=%s: %w", key, err)
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("S3Client.GetObjectRange key=%s: %w", key, err)
+	}
+	s3OpenStreams.Add(1)
+	return &trackedBody{ReadCloser: out.Body}, nil
+}
+
+// CopyObject copies the object at srcKey to dstKey within the bucket.
+func (s *S3Client) CopyObject(ctx context.Context, srcKey, dstKey string) error {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "CopyObject"); err != nil {
+		return fmt.Errorf("S3Client.CopyObject src=%s dst=%s: %w", srcKey, dstKey, err)
+	}
+
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		CopySource: aws.String(s.bucket + "/" + srcKey),
+		Key:        aws.String(dstKey),
+	})
+	if err != nil {
+		return fmt.Errorf("S3Client.CopyObject src=%s dst=%s: %w", srcKey, dstKey, err)
+	}
+	return nil
 }
 
 // DeleteObject removes an object from S3 (used during block garbage collection).
 func (s *S3Client) DeleteObject(ctx context.Context, key string) error {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "DeleteObject"); err != nil {
+		return fmt.Errorf("S3Client.DeleteObject key=%s: %w", key, err)
+	}
+
 	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -77,6 +191,13 @@ func (s *S3Client) DeleteObject(ctx context.Context, key string) error {
 
 // ObjectExists checks whether a key already exists in the bucket.
 func (s *S3Client) ObjectExists(ctx context.Context, key string) (bool, error) {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "ObjectExists"); err != nil {
+		return false, nil // like any failed HEAD below
+	}
+
 	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -87,3 +208,69 @@ func (s *S3Client) ObjectExists(ctx context.Context, key string) (bool, error) {
 	}
 	return true, nil
 }
+
+// ObjectInfo describes one object returned by ListPrefix.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ListPrefix lists the objects directly under prefix and the common prefixes one level
+// below it (the "directories", each ending in "/"), following pagination up to limit
+// entries in total.
+func (s *S3Client) ListPrefix(ctx context.Context, prefix string, limit int) ([]ObjectInfo, []string, error) {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "ListPrefix"); err != nil {
+		return nil, nil, fmt.Errorf("S3Client.ListPrefix prefix=%s: %w", prefix, err)
+	}
+
+	var (
+		objects []ObjectInfo
+		dirs    []string
+	)
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for pages.HasMorePages() && len(objects)+len(dirs) < limit {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("S3Client.ListPrefix prefix=%s: %w", prefix, err)
+		}
+		for _, p := range page.CommonPrefixes {
+			dirs = append(dirs, aws.ToString(p.Prefix))
+		}
+		for _, o := range page.Contents {
+			objects = append(objects, ObjectInfo{
+				Key:          aws.ToString(o.Key),
+				Size:         aws.ToInt64(o.Size),
+				LastModified: aws.ToTime(o.LastModified),
+			})
+		}
+	}
+	return objects, dirs, nil
+}
+
+// StatObject returns an object's size and modification time; ok is false if the
+// object does not exist (or cannot be read).
+func (s *S3Client) StatObject(ctx context.Context, key string) (info ObjectInfo, ok bool) {
+	s3InFlight.Add(1)
+	defer s3InFlight.Add(-1)
+
+	if err := s.faults.S3(ctx, "StatObject"); err != nil {
+		return ObjectInfo{}, false
+	}
+
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectInfo{}, false
+	}
+	return ObjectInfo{Key: key, Size: aws.ToInt64(out.ContentLength), LastModified: aws.ToTime(out.LastModified)}, true
+}
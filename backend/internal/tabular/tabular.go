@@ -0,0 +1,197 @@
+// Package tabular reads the first rows of CSV, TSV and XLSX files into a grid with
+// guessed column types, using only the standard library, so data files can be shown
+// without downloading them.
+package tabular
+
+import (
+	"encoding/csv"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a tabular file format.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatTSV  Format = "tsv"
+	FormatXLSX Format = "xlsx"
+)
+
+// Column types guessed from the cells of a column.
+const (
+	TypeEmpty   = "empty" // no non-blank cells
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeString  = "string"
+)
+
+// MaxCellBytes bounds each cell; longer values are cut.
+const MaxCellBytes = 4096
+
+// Column describes one column of a Table.
+type Column struct {
+	Name string `json:"name" example:"amount"`
+	Type string `json:"type" example:"number"`
+}
+
+// Table is the start of a data file: its header row as columns, then up to the
+// requested number of data rows. Truncated is set when more rows follow.
+type Table struct {
+	Format    Format     `json:"format"    example:"csv"`
+	Columns   []Column   `json:"columns"`
+	Rows      [][]string `json:"rows"`
+	Truncated bool       `json:"truncated"`
+}
+
+// Detect returns the format of a file by MIME type, falling back to the name's
+// extension, or "" if it is not tabular.
+func Detect(mimeType, name string) Format {
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])) {
+	case "text/csv", "application/csv":
+		return FormatCSV
+	case "text/tab-separated-values":
+		return FormatTSV
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return FormatXLSX
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".tsv", ".tab":
+		return FormatTSV
+	case ".xlsx":
+		return FormatXLSX
+	}
+	return ""
+}
+
+// ReadDelimited reads a CSV (or, with FormatTSV, tab-separated) stream up to maxRows
+// data rows. Ragged rows are accepted and padded to the widest row.
+func ReadDelimited(r io.Reader, format Format, maxRows int) (*Table, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if format == FormatTSV {
+		cr.Comma = '\t'
+	}
+
+	var rows [][]string
+	truncated := false
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == maxRows+1 { // header plus maxRows
+			truncated = true
+			break
+		}
+		for i := range rec {
+			rec[i] = clip(rec[i])
+		}
+		rows = append(rows, rec)
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff") // UTF-8 BOM
+	}
+	return build(format, rows, truncated), nil
+}
+
+// build turns raw rows (header first) into a Table, padding every row to the same
+// width and guessing the column types from the data rows.
+func build(format Format, rows [][]string, truncated bool) *Table {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	t := &Table{Format: format, Columns: make([]Column, width), Rows: [][]string{}, Truncated: truncated}
+	for i := range rows {
+		for len(rows[i]) < width {
+			rows[i] = append(rows[i], "")
+		}
+	}
+	if len(rows) == 0 {
+		return t
+	}
+	for c := range t.Columns {
+		name := strings.TrimSpace(rows[0][c])
+		if name == "" {
+			name = columnName(c)
+		}
+		t.Columns[c] = Column{Name: name, Type: guessType(rows[1:], c)}
+	}
+	t.Rows = rows[1:]
+	return t
+}
+
+// guessType returns the narrowest type that fits every non-blank cell of column c.
+func guessType(rows [][]string, c int) string {
+	typ := TypeEmpty
+	for _, row := range rows {
+		v := strings.TrimSpace(row[c])
+		if v == "" {
+			continue
+		}
+		typ = widen(typ, cellType(v))
+		if typ == TypeString {
+			break
+		}
+	}
+	return typ
+}
+
+func cellType(v string) string {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return TypeInteger
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return TypeNumber
+	}
+	switch strings.ToLower(v) {
+	case "true", "false":
+		return TypeBoolean
+	}
+	for _, layout := range []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05"} {
+		if _, err := time.Parse(layout, v); err == nil {
+			return TypeDate
+		}
+	}
+	return TypeString
+}
+
+// widen combines the type so far with a cell's type: integers widen to numbers, and
+// any other mix is a string.
+func widen(have, cell string) string {
+	switch {
+	case have == TypeEmpty || have == cell:
+		return cell
+	case (have == TypeInteger && cell == TypeNumber) || (have == TypeNumber && cell == TypeInteger):
+		return TypeNumber
+	}
+	return TypeString
+}
+
+// columnName returns the spreadsheet name of column index c: A, B, ..., Z, AA, ...
+func columnName(c int) string {
+	name := ""
+	for c++; c > 0; c = (c - 1) / 26 {
+		name = string(rune('A'+(c-1)%26)) + name
+	}
+	return name
+}
+
+func clip(v string) string {
+	if len(v) > MaxCellBytes {
+		return v[:MaxCellBytes]
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package tabular
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxXMLPartBytes bounds how much of one decompressed workbook part is read, so a
+// small archive that inflates to gigabytes cannot exhaust memory.
+const maxXMLPartBytes = 64 << 20
+
+// ErrTooLarge is returned when a workbook part is over maxXMLPartBytes.
+var ErrTooLarge = errors.New("tabular: workbook part too large")
+
+// ReadXLSX reads up to maxRows data rows of the first worksheet of an XLSX workbook.
+// Cells are returned as stored: numbers (including dates, which XLSX keeps as serial
+// numbers) as their decimal text, booleans as "true" or "false".
+func ReadXLSX(r io.ReaderAt, size int64, maxRows int) (*Table, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("tabular.ReadXLSX: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheet := files[firstSheetPath(files)]
+	if sheet == nil {
+		return nil, fmt.Errorf("tabular.ReadXLSX: workbook has no worksheet")
+	}
+	var shared []string
+	if f := files["xl/sharedStrings.xml"]; f != nil {
+		if shared, err = readSharedStrings(f); err != nil {
+			return nil, fmt.Errorf("tabular.ReadXLSX: %w", err)
+		}
+	}
+	rows, truncated, err := readSheet(sheet, shared, maxRows)
+	if err != nil {
+		return nil, fmt.Errorf("tabular.ReadXLSX: %w", err)
+	}
+	return build(FormatXLSX, rows, truncated), nil
+}
+
+// openPart opens a workbook part for XML decoding, bounded by maxXMLPartBytes.
+func openPart(f *zip.File) (*xml.Decoder, io.Closer, error) {
+	if f.UncompressedSize64 > maxXMLPartBytes {
+		return nil, nil, ErrTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	return xml.NewDecoder(io.LimitReader(rc, maxXMLPartBytes)), rc, nil
+}
+
+// firstSheetPath returns the archive path of the workbook's first worksheet, by
+// following the workbook's relationships, or the conventional path if that fails.
+func firstSheetPath(files map[string]*zip.File) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if decodePart(files["xl/workbook.xml"], &workbook) != nil || len(workbook.Sheets) == 0 ||
+		decodePart(files["xl/_rels/workbook.xml.rels"], &rels) != nil {
+		return fallback
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return fallback
+}
+
+func decodePart(f *zip.File, v interface{}) error {
+	if f == nil {
+		return errors.New("missing part")
+	}
+	dec, c, err := openPart(f)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return dec.Decode(v)
+}
+
+// readSharedStrings returns the workbook's shared string table. Rich text runs of
+// one entry are joined.
+func readSharedStrings(f *zip.File) ([]string, error) {
+	dec, c, err := openPart(f)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	var (
+		table []string
+		cur   strings.Builder
+		inSI  bool
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "si":
+				inSI = true
+				cur.Reset()
+			case "t":
+				if inSI {
+					var text string
+					if err := dec.DecodeElement(&text, &el); err != nil {
+						return nil, err
+					}
+					if cur.Len() < MaxCellBytes {
+						cur.WriteString(text)
+					}
+				}
+			case "rPh": // phonetic hints are not part of the text
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+			}
+		case xml.EndElement:
+			if el.Name.Local == "si" {
+				inSI = false
+				table = append(table, clip(cur.String()))
+			}
+		}
+	}
+}
+
+// readSheet returns the first maxRows+1 rows (header and data) of a worksheet, and
+// whether more rows follow. Rows and cells the sheet skips come back blank.
+func readSheet(f *zip.File, shared []string, maxRows int) ([][]string, bool, error) {
+	dec, c, err := openPart(f)
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.Close()
+
+	var rows [][]string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok || el.Name.Local != "row" {
+			continue
+		}
+		if n, err := strconv.Atoi(attr(el, "r")); err == nil {
+			for len(rows) < n-1 && len(rows) <= maxRows {
+				rows = append(rows, nil)
+			}
+		}
+		if len(rows) > maxRows {
+			return rows[:maxRows+1], true, nil
+		}
+		row, err := readRow(dec, shared)
+		if err != nil {
+			return nil, false, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readRow reads the cells of a <row> element whose start tag was just consumed.
+func readRow(dec *xml.Decoder, shared []string) ([]string, error) {
+	var row []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch el := tok.(type) {
+		case xml.EndElement:
+			if el.Name.Local == "row" {
+				return row, nil
+			}
+		case xml.StartElement:
+			if el.Name.Local != "c" {
+				continue
+			}
+			var cell struct {
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			}
+			if err := dec.DecodeElement(&cell, &el); err != nil {
+				return nil, err
+			}
+			col := len(row)
+			if i, ok := columnIndex(attr(el, "r")); ok {
+				col = i
+			}
+			if col > 16383 { // XLSX has at most 16384 columns
+				continue
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			row[col] = clip(cellValue(attr(el, "t"), cell.Value, cell.Inline, shared))
+		}
+	}
+}
+
+// cellValue renders a cell by its type attribute t.
+func cellValue(t, value, inline string, shared []string) string {
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "inlineStr":
+		return inline
+	case "b":
+		if strings.TrimSpace(value) == "1" {
+			return "true"
+		}
+		return "false"
+	}
+	return value
+}
+
+// columnIndex parses the column letters of a cell reference such as "AB12".
+func columnIndex(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+		n++
+	}
+	return col - 1, n > 0 && n <= 3
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
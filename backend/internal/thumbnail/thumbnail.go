@@ -0,0 +1,89 @@
+// Package thumbnail scales JPEG, PNG and GIF images down to small JPEG previews using
+// only the standard library.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder
+	"strings"
+)
+
+// MaxPixels bounds the decoded size of a source image, so a small file that expands
+// to a huge bitmap cannot exhaust memory.
+const MaxPixels = 50_000_000
+
+// ErrTooLarge is returned for images above MaxPixels.
+var ErrTooLarge = errors.New("image too large to thumbnail")
+
+// Supported reports whether images of mimeType can be thumbnailed.
+func Supported(mimeType string) bool {
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])) {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// Generate decodes src and returns a JPEG whose longer side is at most maxDim pixels.
+// Images already small enough are re-encoded at their own size.
+func Generate(src []byte, maxDim int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail.Generate: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail.Generate: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(img, maxDim), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("thumbnail.Generate: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scale shrinks img to fit within maxDim x maxDim, averaging each source box of pixels
+// into one destination pixel. Transparent areas come out white.
+func scale(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if w > maxDim || h > maxDim {
+		if w >= h {
+			dw, dh = maxDim, max(1, h*maxDim/w)
+		} else {
+			dw, dh = max(1, w*maxDim/h), maxDim
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+max((x+1)*w/dw, x*w/dw+1)
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// Composite onto white; the values are alpha-premultiplied.
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: 0xff})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,3 @@
+-- 006_create_jobs.down.sql
+DROP INDEX IF EXISTS idx_jobs_user_kind;
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,16 @@
+-- 006_create_jobs.up.sql
+CREATE TABLE IF NOT EXISTS jobs (
+    id          BIGSERIAL    PRIMARY KEY,
+    user_id     BIGINT       NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind        TEXT         NOT NULL,
+    status      TEXT         NOT NULL DEFAULT 'pending',
+    progress    INT          NOT NULL DEFAULT 0,
+    payload     JSONB,
+    result      JSONB,
+    error       TEXT,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_kind ON jobs(user_id, kind, created_at DESC);
@@ -0,0 +1,2 @@
+-- 007_index_share_links_expires_at.down.sql
+DROP INDEX IF EXISTS idx_share_links_expires_at;
@@ -0,0 +1,2 @@
+-- 007_index_share_links_expires_at.up.sql
+CREATE INDEX IF NOT EXISTS idx_share_links_expires_at ON share_links(expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- 008_add_share_links_enabled.down.sql
+ALTER TABLE share_links DROP COLUMN IF EXISTS enabled;
@@ -0,0 +1,2 @@
+-- 008_add_share_links_enabled.up.sql
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS enabled BOOLEAN NOT NULL DEFAULT TRUE;
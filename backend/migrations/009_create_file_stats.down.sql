@@ -0,0 +1,2 @@
+-- 009_create_file_stats.down.sql
+DROP TABLE IF EXISTS file_stats;
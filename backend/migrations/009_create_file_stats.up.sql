@@ -0,0 +1,9 @@
+-- 009_create_file_stats.up.sql
+CREATE TABLE IF NOT EXISTS file_stats (
+    file_id          BIGINT       PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    owner_downloads  BIGINT       NOT NULL DEFAULT 0,
+    owner_previews   BIGINT       NOT NULL DEFAULT 0,
+    share_downloads  BIGINT       NOT NULL DEFAULT 0,
+    share_previews   BIGINT       NOT NULL DEFAULT 0,
+    last_accessed_at TIMESTAMPTZ
+);
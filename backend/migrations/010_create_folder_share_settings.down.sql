@@ -0,0 +1,3 @@
+-- 010_create_folder_share_settings.down.sql
+ALTER TABLE share_links DROP COLUMN IF EXISTS password_hash;
+DROP TABLE IF EXISTS folder_share_settings;
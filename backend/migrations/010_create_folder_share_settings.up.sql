@@ -0,0 +1,11 @@
+-- 010_create_folder_share_settings.up.sql
+-- NULL columns inherit the value from the nearest ancestor folder that sets one.
+CREATE TABLE IF NOT EXISTS folder_share_settings (
+    folder_id            BIGINT       PRIMARY KEY REFERENCES folders(id) ON DELETE CASCADE,
+    default_expiry_hours INT,
+    require_password     BOOLEAN,
+    allow_public_links   BOOLEAN,
+    updated_at           TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS password_hash TEXT;
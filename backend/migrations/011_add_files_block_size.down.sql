@@ -0,0 +1,2 @@
+-- 011_add_files_block_size.down.sql
+ALTER TABLE files DROP COLUMN IF EXISTS block_size;
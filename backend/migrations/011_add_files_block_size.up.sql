@@ -0,0 +1,3 @@
+-- 011_add_files_block_size.up.sql
+-- Block size each file was split with; 0 for files uploaded before adaptive sizing.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS block_size INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- 012_add_users_role.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS role;
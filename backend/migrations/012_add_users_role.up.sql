@@ -0,0 +1,3 @@
+-- 012_add_users_role.up.sql
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
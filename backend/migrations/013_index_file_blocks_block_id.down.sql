@@ -0,0 +1,2 @@
+-- 013_index_file_blocks_block_id.down.sql
+DROP INDEX IF EXISTS idx_file_blocks_block;
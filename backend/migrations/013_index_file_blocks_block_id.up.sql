@@ -0,0 +1,3 @@
+-- 013_index_file_blocks_block_id.up.sql
+-- Counting references per block (integrity checks, GC) needs a lookup by block_id.
+CREATE INDEX IF NOT EXISTS idx_file_blocks_block ON file_blocks(block_id);
@@ -0,0 +1,2 @@
+-- 014_create_s3_deletions.down.sql
+DROP TABLE IF EXISTS s3_deletions;
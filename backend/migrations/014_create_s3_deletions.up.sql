@@ -0,0 +1,14 @@
+-- 014_create_s3_deletions.up.sql
+-- Objects waiting to be removed from S3. Rows are processed by a retrying worker;
+-- dead_at is set once max attempts are exhausted (dead letter).
+CREATE TABLE IF NOT EXISTS s3_deletions (
+    id              BIGSERIAL    PRIMARY KEY,
+    s3_key          TEXT         NOT NULL UNIQUE,
+    attempts        INT          NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    dead_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_s3_deletions_due ON s3_deletions(next_attempt_at) WHERE dead_at IS NULL;
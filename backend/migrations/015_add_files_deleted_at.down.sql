@@ -0,0 +1,3 @@
+-- 015_add_files_deleted_at.down.sql
+DROP INDEX IF EXISTS idx_files_deleted_at;
+ALTER TABLE files DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- 015_add_files_deleted_at.up.sql
+-- Trashed files keep their file_blocks (and therefore their block references)
+-- until the trash purge job removes them for good.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_files_deleted_at ON files(deleted_at) WHERE deleted_at IS NOT NULL;
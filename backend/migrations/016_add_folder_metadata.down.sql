@@ -0,0 +1,4 @@
+-- 016_add_folder_metadata.down.sql
+ALTER TABLE folders DROP COLUMN IF EXISTS description;
+ALTER TABLE folders DROP COLUMN IF EXISTS icon;
+ALTER TABLE folders DROP COLUMN IF EXISTS color;
@@ -0,0 +1,4 @@
+-- 016_add_folder_metadata.up.sql
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS color       TEXT;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS icon        TEXT;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS description TEXT;
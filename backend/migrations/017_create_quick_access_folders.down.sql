@@ -0,0 +1,2 @@
+-- 017_create_quick_access_folders.down.sql
+DROP TABLE IF EXISTS quick_access_folders;
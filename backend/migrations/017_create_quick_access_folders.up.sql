@@ -0,0 +1,11 @@
+-- 017_create_quick_access_folders.up.sql
+-- Folders pinned to a user's quick-access sidebar, in the user's chosen order.
+CREATE TABLE IF NOT EXISTS quick_access_folders (
+    user_id   BIGINT      NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+    folder_id BIGINT      NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    position  INT         NOT NULL,
+    pinned_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, folder_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quick_access_folders_folder_id ON quick_access_folders(folder_id);
@@ -0,0 +1,3 @@
+-- 018_add_files_trashed_from_folder.down.sql
+DROP INDEX IF EXISTS idx_files_trashed_from_folder;
+ALTER TABLE files DROP COLUMN IF EXISTS trashed_from_folder_id;
@@ -0,0 +1,9 @@
+-- 018_add_files_trashed_from_folder.up.sql
+-- The folder a trashed file was deleted from. Deliberately not a foreign key: deleting
+-- the folder nulls files.folder_id, but the trash view should still group by it.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS trashed_from_folder_id BIGINT;
+
+UPDATE files SET trashed_from_folder_id = folder_id WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_files_trashed_from_folder
+    ON files(user_id, trashed_from_folder_id) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- 019_add_files_modified_by.down.sql
+ALTER TABLE files DROP COLUMN IF EXISTS modified_client;
+ALTER TABLE files DROP COLUMN IF EXISTS modified_by;
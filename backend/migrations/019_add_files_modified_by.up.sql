@@ -0,0 +1,7 @@
+-- 019_add_files_modified_by.up.sql
+-- Who last changed a file (upload, rename, move, trash, restore) and from which client.
+-- Today that is always the owner; it differs once folders are shared between users.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS modified_by     BIGINT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE files ADD COLUMN IF NOT EXISTS modified_client TEXT;
+
+UPDATE files SET modified_by = user_id WHERE modified_by IS NULL;
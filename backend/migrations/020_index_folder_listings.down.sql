@@ -0,0 +1,3 @@
+-- 020_index_folder_listings.down.sql
+DROP INDEX IF EXISTS idx_folders_user_parent_name;
+DROP INDEX IF EXISTS idx_files_user_folder_name;
@@ -0,0 +1,7 @@
+-- 020_index_folder_listings.up.sql
+-- Folder listings filter by owner and parent and sort by name; serve them from one index
+-- each instead of intersecting the single-column ones.
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name
+    ON files(user_id, folder_id, name) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_folders_user_parent_name
+    ON folders(user_id, parent_id, name);
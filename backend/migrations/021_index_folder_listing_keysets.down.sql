@@ -0,0 +1,5 @@
+-- 021_index_folder_listing_keysets.down.sql
+DROP INDEX IF EXISTS idx_files_user_folder_created_id;
+DROP INDEX IF EXISTS idx_files_user_folder_name_id;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name
+    ON files(user_id, folder_id, name) WHERE deleted_at IS NULL;
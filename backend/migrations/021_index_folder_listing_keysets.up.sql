@@ -0,0 +1,7 @@
+-- 021_index_folder_listing_keysets.up.sql
+-- Keyset pagination of folder listings seeks on (name, id) or (created_at, id).
+DROP INDEX IF EXISTS idx_files_user_folder_name;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name_id
+    ON files(user_id, folder_id, name, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_created_id
+    ON files(user_id, folder_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;
@@ -0,0 +1,11 @@
+-- 022_create_name_collations.down.sql
+DROP INDEX IF EXISTS idx_folders_user_parent_name_ci;
+CREATE INDEX IF NOT EXISTS idx_folders_user_parent_name ON folders(user_id, parent_id, name);
+
+DROP INDEX IF EXISTS idx_files_user_folder_name_natural_id;
+DROP INDEX IF EXISTS idx_files_user_folder_name_ci_id;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name_id
+    ON files(user_id, folder_id, name, id) WHERE deleted_at IS NULL;
+
+DROP COLLATION IF EXISTS name_natural;
+DROP COLLATION IF EXISTS name_ci;
@@ -0,0 +1,16 @@
+-- 022_create_name_collations.up.sql
+-- ICU collations for listing order. name_ci sorts case-insensitively by Unicode rules
+-- ("apple" < "Banana" < "cherry"); name_natural additionally compares digit runs as
+-- numbers ("file2" < "file10"). Both stay deterministic so they can back indexes.
+CREATE COLLATION IF NOT EXISTS name_ci      (provider = icu, locale = 'und-u-ks-level2');
+CREATE COLLATION IF NOT EXISTS name_natural (provider = icu, locale = 'und-u-ks-level2-kn-true');
+
+DROP INDEX IF EXISTS idx_files_user_folder_name_id;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name_ci_id
+    ON files(user_id, folder_id, name COLLATE name_ci, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_files_user_folder_name_natural_id
+    ON files(user_id, folder_id, name COLLATE name_natural, id) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_folders_user_parent_name;
+CREATE INDEX IF NOT EXISTS idx_folders_user_parent_name_ci
+    ON folders(user_id, parent_id, name COLLATE name_ci);
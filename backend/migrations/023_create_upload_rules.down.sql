@@ -0,0 +1,2 @@
+-- 023_create_upload_rules.down.sql
+DROP TABLE IF EXISTS upload_rules;
@@ -0,0 +1,18 @@
+-- 023_create_upload_rules.up.sql
+-- User-defined rules that file root uploads into a folder. The enabled rule with the
+-- lowest position that matches wins. A rule goes away with its target folder.
+CREATE TABLE IF NOT EXISTS upload_rules (
+    id               BIGSERIAL   PRIMARY KEY,
+    user_id          BIGINT      NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+    name             TEXT        NOT NULL,
+    match_type       TEXT        NOT NULL CHECK (match_type IN ('extension', 'mime', 'name')),
+    pattern          TEXT        NOT NULL,
+    target_folder_id BIGINT      NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    position         INT         NOT NULL DEFAULT 0,
+    enabled          BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_rules_user_id          ON upload_rules(user_id, position);
+CREATE INDEX IF NOT EXISTS idx_upload_rules_target_folder_id ON upload_rules(target_folder_id);
@@ -0,0 +1,2 @@
+-- 024_create_notifications.down.sql
+DROP TABLE IF EXISTS notifications;
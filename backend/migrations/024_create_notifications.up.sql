@@ -0,0 +1,14 @@
+-- 024_create_notifications.up.sql
+-- In-app notifications shown to a user, e.g. ahead of an item's scheduled expiry.
+-- data carries kind-specific fields (item ids, names, dates) for the client to render.
+CREATE TABLE IF NOT EXISTS notifications (
+    id         BIGSERIAL   PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       TEXT        NOT NULL,
+    data       JSONB       NOT NULL DEFAULT '{}',
+    read_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread  ON notifications(user_id) WHERE read_at IS NULL;
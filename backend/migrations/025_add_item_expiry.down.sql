@@ -0,0 +1,7 @@
+-- 025_add_item_expiry.down.sql
+DROP INDEX IF EXISTS idx_folders_expires_at;
+DROP INDEX IF EXISTS idx_files_expires_at;
+ALTER TABLE folders DROP COLUMN IF EXISTS expiry_notified_at;
+ALTER TABLE folders DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE files   DROP COLUMN IF EXISTS expiry_notified_at;
+ALTER TABLE files   DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,12 @@
+-- 025_add_item_expiry.up.sql
+-- Scheduled expiry: once expires_at passes, a file is moved to the trash and a folder is
+-- deleted with everything in it. expiry_notified_at records that the owner was warned.
+ALTER TABLE files   ADD COLUMN IF NOT EXISTS expires_at         TIMESTAMPTZ;
+ALTER TABLE files   ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMPTZ;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS expires_at         TIMESTAMPTZ;
+ALTER TABLE folders ADD COLUMN IF NOT EXISTS expiry_notified_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_files_expires_at
+    ON files(expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_folders_expires_at
+    ON folders(expires_at) WHERE expires_at IS NOT NULL;
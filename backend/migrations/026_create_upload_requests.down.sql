@@ -0,0 +1,2 @@
+-- 026_create_upload_requests.down.sql
+DROP TABLE IF EXISTS upload_requests;
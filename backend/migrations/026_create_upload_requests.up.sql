@@ -0,0 +1,21 @@
+-- 026_create_upload_requests.up.sql
+-- File-drop links: a public URL through which anyone holding the token can upload files
+-- into one of the owner's folders. accepted_types mixes extensions and MIME types
+-- (empty = anything); max_size_bytes bounds each file (NULL = no limit).
+CREATE TABLE IF NOT EXISTS upload_requests (
+    id             BIGSERIAL   PRIMARY KEY,
+    user_id        BIGINT      NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+    folder_id      BIGINT      NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    token          TEXT        NOT NULL UNIQUE,
+    title          TEXT        NOT NULL,
+    instructions   TEXT        NOT NULL DEFAULT '',
+    accepted_types TEXT[]      NOT NULL DEFAULT '{}',
+    max_size_bytes BIGINT      CHECK (max_size_bytes > 0),
+    expires_at     TIMESTAMPTZ,
+    enabled        BOOLEAN     NOT NULL DEFAULT TRUE,
+    upload_count   BIGINT      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_requests_user_id   ON upload_requests(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_upload_requests_folder_id ON upload_requests(folder_id);
@@ -0,0 +1,4 @@
+-- 027_create_abuse_reports.down.sql
+ALTER TABLE files       DROP COLUMN IF EXISTS taken_down_at;
+ALTER TABLE share_links DROP COLUMN IF EXISTS taken_down_at;
+DROP TABLE IF EXISTS abuse_reports;
@@ -0,0 +1,28 @@
+-- 027_create_abuse_reports.up.sql
+-- Abuse/copyright reports filed against public share links, reviewed by admins.
+-- file_id is the link's file at report time so reports survive the link being deleted.
+-- taken_down_at marks links and files disabled by an admin; owners cannot re-enable
+-- or re-share them.
+CREATE TABLE IF NOT EXISTS abuse_reports (
+    id             BIGSERIAL   PRIMARY KEY,
+    share_link_id  BIGINT      REFERENCES share_links(id) ON DELETE SET NULL,
+    file_id        BIGINT      REFERENCES files(id)       ON DELETE SET NULL,
+    token          TEXT        NOT NULL,
+    reason         TEXT        NOT NULL,
+    details        TEXT        NOT NULL DEFAULT '',
+    reporter_email TEXT,
+    reporter_ip    TEXT        NOT NULL,
+    status         TEXT        NOT NULL DEFAULT 'open'
+                               CHECK (status IN ('open', 'dismissed', 'actioned')),
+    action         TEXT        CHECK (action IN ('link_disabled', 'file_disabled')),
+    resolved_by    BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at    TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_status  ON abuse_reports(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_link_id ON abuse_reports(share_link_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_abuse_reports_file_id ON abuse_reports(file_id) WHERE status = 'open';
+
+ALTER TABLE share_links ADD COLUMN IF NOT EXISTS taken_down_at TIMESTAMPTZ;
+ALTER TABLE files       ADD COLUMN IF NOT EXISTS taken_down_at TIMESTAMPTZ;
@@ -0,0 +1,4 @@
+-- 028_add_file_scan_status.down.sql
+DROP INDEX IF EXISTS idx_files_scan_status;
+ALTER TABLE files DROP COLUMN IF EXISTS scanned_at;
+ALTER TABLE files DROP COLUMN IF EXISTS scan_status;
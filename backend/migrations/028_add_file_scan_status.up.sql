@@ -0,0 +1,9 @@
+-- 028_add_file_scan_status.up.sql
+-- Malware scan verdict reported by an external scanner. NULL = never scanned
+-- (no scanner configured); public downloads are gated on it per SHARE_SCAN_GATE.
+ALTER TABLE files ADD COLUMN IF NOT EXISTS scan_status TEXT
+    CHECK (scan_status IN ('pending', 'clean', 'infected'));
+ALTER TABLE files ADD COLUMN IF NOT EXISTS scanned_at  TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_files_scan_status
+    ON files(scan_status) WHERE scan_status IN ('pending', 'infected');
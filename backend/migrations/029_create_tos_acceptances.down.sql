@@ -0,0 +1,4 @@
+-- 029_create_tos_acceptances.down.sql
+DROP TABLE IF EXISTS tos_acceptances;
+ALTER TABLE users DROP COLUMN IF EXISTS tos_accepted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS tos_version;
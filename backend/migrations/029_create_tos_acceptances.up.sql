@@ -0,0 +1,16 @@
+-- 029_create_tos_acceptances.up.sql
+-- Terms-of-service consent. users.tos_version is the latest version a user accepted;
+-- tos_acceptances keeps every acceptance with where it came from, as evidence of consent.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tos_version     TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tos_accepted_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS tos_acceptances (
+    id          BIGSERIAL   PRIMARY KEY,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    version     TEXT        NOT NULL,
+    ip_address  TEXT        NOT NULL,
+    user_agent  TEXT        NOT NULL DEFAULT '',
+    accepted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tos_acceptances_user_id ON tos_acceptances(user_id, accepted_at DESC);
@@ -0,0 +1,4 @@
+-- 030_create_push_devices.down.sql
+DROP INDEX IF EXISTS idx_notifications_unpushed;
+ALTER TABLE notifications DROP COLUMN IF EXISTS pushed_at;
+DROP TABLE IF EXISTS push_devices;
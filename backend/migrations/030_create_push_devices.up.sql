@@ -0,0 +1,23 @@
+-- 030_create_push_devices.up.sql
+-- Devices that receive push notifications: FCM registration tokens (mobile apps) and
+-- Web Push subscriptions (browsers; token is the endpoint URL, with its p256dh/auth keys).
+CREATE TABLE IF NOT EXISTS push_devices (
+    id           BIGSERIAL   PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform     TEXT        NOT NULL CHECK (platform IN ('fcm', 'webpush')),
+    token        TEXT        NOT NULL UNIQUE,
+    p256dh       TEXT,
+    auth_secret  TEXT,
+    user_agent   TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_devices_user_id ON push_devices(user_id);
+
+-- Notifications are pushed by a background task; pushed_at marks the ones it has handled.
+-- Existing notifications count as handled so enabling push does not replay history.
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS pushed_at TIMESTAMPTZ;
+UPDATE notifications SET pushed_at = created_at WHERE pushed_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unpushed ON notifications(id) WHERE pushed_at IS NULL;
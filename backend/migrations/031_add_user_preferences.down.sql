@@ -0,0 +1,2 @@
+-- 031_add_user_preferences.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS preferences;
@@ -0,0 +1,4 @@
+-- 031_add_user_preferences.up.sql
+-- UI and behaviour preferences that roam across a user's devices. Keys that are missing
+-- take their defaults, so new preferences need no migration.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS preferences JSONB NOT NULL DEFAULT '{}';
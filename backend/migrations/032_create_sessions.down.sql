@@ -0,0 +1,2 @@
+-- 032_create_sessions.down.sql
+DROP TABLE IF EXISTS sessions;
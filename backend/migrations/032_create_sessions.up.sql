@@ -0,0 +1,17 @@
+-- 032_create_sessions.up.sql
+-- Server-side browser sessions for SESSION_MODE=cookie|both. The client holds an
+-- opaque random ID in an HttpOnly cookie; only its SHA-256 is stored, so a leaked
+-- table does not hand out live sessions.
+CREATE TABLE IF NOT EXISTS sessions (
+    id           BIGSERIAL   PRIMARY KEY,
+    token_hash   BYTEA       NOT NULL UNIQUE,
+    user_id      BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip_address   TEXT        NOT NULL DEFAULT '',
+    user_agent   TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
@@ -0,0 +1,2 @@
+-- 033_create_security_events.down.sql
+DROP TABLE IF EXISTS security_events;
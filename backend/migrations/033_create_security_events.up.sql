@@ -0,0 +1,21 @@
+-- 033_create_security_events.up.sql
+-- Security log: failed logins, rejected tokens, permission denials, wrong share link
+-- passwords and the like, kept apart from the application log for admin review.
+-- user_id has no foreign key so events outlive the accounts they mention.
+CREATE TABLE IF NOT EXISTS security_events (
+    id          BIGSERIAL   PRIMARY KEY,
+    type        TEXT        NOT NULL,
+    user_id     BIGINT,
+    ip_address  TEXT        NOT NULL DEFAULT '',
+    user_agent  TEXT        NOT NULL DEFAULT '',
+    method      TEXT        NOT NULL DEFAULT '',
+    path        TEXT        NOT NULL DEFAULT '',
+    request_id  TEXT        NOT NULL DEFAULT '',
+    details     JSONB       NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_created_at ON security_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events(type, id);
+CREATE INDEX IF NOT EXISTS idx_security_events_user_id ON security_events(user_id, id) WHERE user_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_security_events_ip_address ON security_events(ip_address, id);
@@ -0,0 +1,3 @@
+-- 034_add_user_deactivation.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_by;
+ALTER TABLE users DROP COLUMN IF EXISTS deactivated_at;
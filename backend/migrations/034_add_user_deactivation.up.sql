@@ -0,0 +1,5 @@
+-- 034_add_user_deactivation.up.sql
+-- Deactivated accounts cannot sign in or use the API and their share and file-drop
+-- links stop working, but nothing is deleted; reactivating restores everything.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deactivated_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
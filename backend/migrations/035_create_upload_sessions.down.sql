@@ -0,0 +1,3 @@
+-- 035_create_upload_sessions.down.sql
+DROP TABLE IF EXISTS upload_session_chunks;
+DROP TABLE IF EXISTS upload_sessions;
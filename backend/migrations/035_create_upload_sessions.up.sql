@@ -0,0 +1,28 @@
+-- 035_create_upload_sessions.up.sql
+-- Resumable uploads. A session fixes the file's size and chunk size up front; chunk i
+-- covers bytes [i*chunk_size, (i+1)*chunk_size). Each received chunk is stored as a
+-- block and holds one reference to it until the session is finalized or discarded.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id          BIGSERIAL   PRIMARY KEY,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id   BIGINT      REFERENCES folders(id) ON DELETE SET NULL,
+    file_name   TEXT        NOT NULL,
+    mime_type   TEXT        NOT NULL,
+    total_size  BIGINT      NOT NULL,
+    chunk_size  INT         NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS upload_session_chunks (
+    session_id  BIGINT      NOT NULL REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    chunk_index INT         NOT NULL,
+    block_id    BIGINT      NOT NULL REFERENCES blocks(id),
+    size_bytes  BIGINT      NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, chunk_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_user_id    ON upload_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_expires_at ON upload_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_upload_session_chunks_block ON upload_session_chunks(block_id);
@@ -0,0 +1,5 @@
+-- 036_add_upload_session_result.down.sql
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS rule_id,
+    DROP COLUMN IF EXISTS file_id,
+    DROP COLUMN IF EXISTS finalized_at;
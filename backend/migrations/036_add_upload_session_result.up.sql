@@ -0,0 +1,8 @@
+-- 036_add_upload_session_result.up.sql
+-- Finalizing an upload session records the file it produced, so a retried finalize
+-- replays that result instead of creating a second file. The session row stays until
+-- it expires; its chunks are handed over to the file on finalize.
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS finalized_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS file_id      BIGINT REFERENCES files(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS rule_id      BIGINT REFERENCES upload_rules(id) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+-- 037_index_jobs_user_id.down.sql
+DROP INDEX IF EXISTS idx_jobs_user_id;
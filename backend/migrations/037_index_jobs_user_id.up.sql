@@ -0,0 +1,3 @@
+-- 037_index_jobs_user_id.up.sql
+-- GET /jobs pages through a user's jobs newest first by ID.
+CREATE INDEX IF NOT EXISTS idx_jobs_user_id ON jobs(user_id, id DESC);
@@ -0,0 +1,2 @@
+-- 038_add_users_used_bytes.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS used_bytes;
@@ -0,0 +1,8 @@
+-- 038_add_users_used_bytes.up.sql
+-- Per-user storage usage: the logical size of the user's files, trashed ones included
+-- until they are purged. Kept up to date incrementally as files are created and purged;
+-- a nightly job recomputes it from the files table and corrects any drift.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS used_bytes BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users u
+SET used_bytes = COALESCE((SELECT SUM(f.total_size) FROM files f WHERE f.user_id = u.id), 0);
@@ -0,0 +1,4 @@
+-- 039_notify_changes.down.sql
+DROP TRIGGER IF EXISTS folders_notify_change ON folders;
+DROP TRIGGER IF EXISTS files_notify_change ON files;
+DROP FUNCTION IF EXISTS notify_change();
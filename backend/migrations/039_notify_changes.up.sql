@@ -0,0 +1,33 @@
+-- 039_notify_changes.up.sql
+-- Announce every file and folder change on the naratel_changes channel so all API
+-- replicas (not just the one that made the change) can invalidate caches and push
+-- events to clients. NOTIFY is delivered on commit and identical payloads within a
+-- transaction are collapsed, so bulk updates stay cheap.
+CREATE OR REPLACE FUNCTION notify_change() RETURNS trigger AS $$
+DECLARE
+    rec RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := OLD;
+    ELSE
+        rec := NEW;
+    END IF;
+    PERFORM pg_notify('naratel_changes', json_build_object(
+        'table',   TG_TABLE_NAME,
+        'op',      lower(TG_OP),
+        'id',      rec.id,
+        'user_id', rec.user_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS files_notify_change ON files;
+CREATE TRIGGER files_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON files
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
+
+DROP TRIGGER IF EXISTS folders_notify_change ON folders;
+CREATE TRIGGER folders_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON folders
+    FOR EACH ROW EXECUTE FUNCTION notify_change();
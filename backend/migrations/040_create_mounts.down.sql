@@ -0,0 +1,2 @@
+-- 040_create_mounts.down.sql
+DROP TABLE IF EXISTS mounts;
@@ -0,0 +1,16 @@
+-- 040_create_mounts.up.sql
+-- Mounts surface external storage read-only inside a user's tree: an S3 prefix in the
+-- configured bucket (kind 's3') or a directory on the server (kind 'local'). Their
+-- content is streamed directly and never split into blocks. parent_id NULL = root.
+CREATE TABLE IF NOT EXISTS mounts (
+    id         BIGSERIAL   PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+    parent_id  BIGINT      REFERENCES folders(id)          ON DELETE CASCADE,
+    name       TEXT        NOT NULL,
+    kind       TEXT        NOT NULL CHECK (kind IN ('s3', 'local')),
+    location   TEXT        NOT NULL,
+    created_by BIGINT      REFERENCES users(id)            ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mounts_user_parent ON mounts(user_id, parent_id);
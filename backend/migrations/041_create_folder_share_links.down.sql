@@ -0,0 +1,2 @@
+-- 041_create_folder_share_links.down.sql
+DROP TABLE IF EXISTS folder_share_links;
@@ -0,0 +1,16 @@
+-- 041_create_folder_share_links.up.sql
+-- Public links to a whole folder. view 'list' shows every file; 'gallery' shows only
+-- images and videos, with thumbnails, for handing clients a browsable album.
+CREATE TABLE IF NOT EXISTS folder_share_links (
+    id            BIGSERIAL   PRIMARY KEY,
+    folder_id     BIGINT      NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    user_id       BIGINT      NOT NULL REFERENCES users(id)   ON DELETE CASCADE,
+    token         TEXT        NOT NULL UNIQUE,
+    view          TEXT        NOT NULL DEFAULT 'list' CHECK (view IN ('list', 'gallery')),
+    enabled       BOOLEAN     NOT NULL DEFAULT TRUE,
+    password_hash TEXT,
+    expires_at    TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_share_links_folder_id ON folder_share_links(folder_id);
@@ -0,0 +1,8 @@
+-- 042_add_folder_share_uploads.down.sql
+DROP TABLE IF EXISTS folder_share_uploads;
+
+ALTER TABLE folder_share_links
+    DROP COLUMN IF EXISTS allow_uploads,
+    DROP COLUMN IF EXISTS upload_max_bytes,
+    DROP COLUMN IF EXISTS upload_max_files,
+    DROP COLUMN IF EXISTS upload_count;
@@ -0,0 +1,27 @@
+-- 042_add_folder_share_uploads.up.sql
+-- Folder share links may accept uploads into the shared folder, turning them into
+-- two-way exchange spaces. upload_max_bytes caps each file and upload_max_files the
+-- number of files received; upload_count is what has been received so far.
+ALTER TABLE folder_share_links
+    ADD COLUMN IF NOT EXISTS allow_uploads    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS upload_max_bytes BIGINT  CHECK (upload_max_bytes > 0),
+    ADD COLUMN IF NOT EXISTS upload_max_files INT     CHECK (upload_max_files > 0),
+    ADD COLUMN IF NOT EXISTS upload_count     INT     NOT NULL DEFAULT 0;
+
+-- Activity record of files received through folder share links: who sent what, and
+-- when. Rows outlive the link and the file so the owner keeps the history.
+CREATE TABLE IF NOT EXISTS folder_share_uploads (
+    id            BIGSERIAL   PRIMARY KEY,
+    link_id       BIGINT      REFERENCES folder_share_links(id) ON DELETE SET NULL,
+    folder_id     BIGINT      NOT NULL REFERENCES folders(id)   ON DELETE CASCADE,
+    user_id       BIGINT      NOT NULL REFERENCES users(id)     ON DELETE CASCADE,
+    file_id       BIGINT      REFERENCES files(id)              ON DELETE SET NULL,
+    uploader_name TEXT,
+    file_name     TEXT        NOT NULL,
+    size          BIGINT      NOT NULL,
+    client_ip     TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_folder_share_uploads_folder_id ON folder_share_uploads(folder_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_folder_share_uploads_link_id   ON folder_share_uploads(link_id);
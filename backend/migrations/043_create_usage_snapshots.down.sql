@@ -0,0 +1,2 @@
+-- 043_create_usage_snapshots.down.sql
+DROP TABLE IF EXISTS usage_snapshots;
@@ -0,0 +1,17 @@
+-- 043_create_usage_snapshots.up.sql
+-- Daily storage usage per user, for growth trends. The row with folder_id NULL is the
+-- user's total (every file, trash included, as counted against used_bytes); the other
+-- rows cover each top-level folder's subtree (files not in the trash). folder_id has
+-- no foreign key and folder_name is copied so history survives the folder.
+CREATE TABLE IF NOT EXISTS usage_snapshots (
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    folder_id   BIGINT,
+    folder_name TEXT,
+    day         DATE        NOT NULL,
+    bytes       BIGINT      NOT NULL,
+    file_count  BIGINT      NOT NULL,
+    taken_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_snapshots_key ON usage_snapshots(user_id, day, (COALESCE(folder_id, 0)));
+CREATE INDEX IF NOT EXISTS idx_usage_snapshots_day ON usage_snapshots(day);
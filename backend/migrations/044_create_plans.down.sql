@@ -0,0 +1,3 @@
+-- 044_create_plans.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS plan_id;
+DROP TABLE IF EXISTS plans;
@@ -0,0 +1,22 @@
+-- 044_create_plans.up.sql
+-- Plans bundle the limits a user is held to: storage quota, largest file, and whether
+-- public links may be created. NULL limits are unlimited. Users without a plan get
+-- the default plan, or no limits if there is none.
+CREATE TABLE IF NOT EXISTS plans (
+    id                 BIGSERIAL   PRIMARY KEY,
+    name               TEXT        NOT NULL UNIQUE,
+    description        TEXT,
+    quota_bytes        BIGINT      CHECK (quota_bytes > 0),
+    max_file_bytes     BIGINT      CHECK (max_file_bytes > 0),
+    allow_public_links BOOLEAN     NOT NULL DEFAULT TRUE,
+    is_default         BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- At most one default plan.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_plans_default ON plans(is_default) WHERE is_default;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan_id BIGINT REFERENCES plans(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_plan_id ON users(plan_id);
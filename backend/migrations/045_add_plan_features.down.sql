@@ -0,0 +1,6 @@
+-- 045_add_plan_features.down.sql
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS allow_public_links BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE plans SET allow_public_links = FALSE WHERE features->>'public_links' = 'false';
+
+ALTER TABLE plans DROP COLUMN IF EXISTS features;
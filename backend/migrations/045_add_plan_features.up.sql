@@ -0,0 +1,8 @@
+-- 045_add_plan_features.up.sql
+-- Plans switch features off by name ({"public_links": false}); features a plan does
+-- not mention stay on. allow_public_links becomes the public_links feature.
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS features JSONB NOT NULL DEFAULT '{}';
+
+UPDATE plans SET features = features || '{"public_links": false}' WHERE NOT allow_public_links;
+
+ALTER TABLE plans DROP COLUMN IF EXISTS allow_public_links;
@@ -0,0 +1,4 @@
+-- 046_create_egress_usage.down.sql
+ALTER TABLE plans DROP COLUMN IF EXISTS monthly_egress_bytes;
+
+DROP TABLE IF EXISTS egress_usage;
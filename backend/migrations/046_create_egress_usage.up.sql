@@ -0,0 +1,12 @@
+-- 046_create_egress_usage.up.sql
+-- Bytes served per user per calendar month (UTC): their own downloads plus traffic
+-- through their share links. Plans may cap it with monthly_egress_bytes.
+CREATE TABLE IF NOT EXISTS egress_usage (
+    user_id    BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    month      DATE        NOT NULL, -- first day of the month
+    bytes      BIGINT      NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, month)
+);
+
+ALTER TABLE plans ADD COLUMN IF NOT EXISTS monthly_egress_bytes BIGINT CHECK (monthly_egress_bytes > 0);
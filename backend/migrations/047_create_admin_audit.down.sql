@@ -0,0 +1,4 @@
+-- 047_create_admin_audit.down.sql
+DROP TABLE IF EXISTS admin_audit;
+
+DROP FUNCTION IF EXISTS admin_audit_append_only();
@@ -0,0 +1,31 @@
+-- 047_create_admin_audit.up.sql
+-- Every state-changing admin request: who made it, what it targeted, the target's
+-- state before and after, and how it ended. Rows can only be added; a trigger refuses
+-- updates and deletes. actor_id has no foreign key so records outlive the account.
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id          BIGSERIAL   PRIMARY KEY,
+    actor_id    BIGINT      NOT NULL,
+    action      TEXT        NOT NULL, -- method and route, e.g. "PATCH /admin/plans/{id}"
+    target_type TEXT        NOT NULL DEFAULT '',
+    target_id   TEXT        NOT NULL DEFAULT '',
+    before      JSONB,
+    after       JSONB,
+    status      INT         NOT NULL,
+    ip_address  TEXT        NOT NULL DEFAULT '',
+    request_id  TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_actor ON admin_audit(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_target ON admin_audit(target_type, target_id, id);
+
+CREATE OR REPLACE FUNCTION admin_audit_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_append_only ON admin_audit;
+CREATE TRIGGER admin_audit_append_only
+    BEFORE UPDATE OR DELETE ON admin_audit
+    FOR EACH ROW EXECUTE FUNCTION admin_audit_append_only();
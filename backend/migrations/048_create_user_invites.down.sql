@@ -0,0 +1,2 @@
+-- 048_create_user_invites.down.sql
+DROP TABLE IF EXISTS user_invites;
@@ -0,0 +1,14 @@
+-- 048_create_user_invites.up.sql
+-- Invite links for accounts admins provision in bulk. Such accounts start without a
+-- password; following the link sets one. Only a SHA-256 hash of the token is kept.
+CREATE TABLE IF NOT EXISTS user_invites (
+    id          BIGSERIAL   PRIMARY KEY,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash  BYTEA       NOT NULL UNIQUE,
+    created_by  BIGINT      REFERENCES users(id) ON DELETE SET NULL,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_invites_user ON user_invites(user_id);
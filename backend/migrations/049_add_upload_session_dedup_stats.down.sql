@@ -0,0 +1,4 @@
+-- 049_add_upload_session_dedup_stats.down.sql
+ALTER TABLE upload_sessions
+    DROP COLUMN IF EXISTS blocks_new,
+    DROP COLUMN IF EXISTS uploaded_bytes;
@@ -0,0 +1,6 @@
+-- 049_add_upload_session_dedup_stats.up.sql
+-- Dedup totals of a resumable upload, added up as chunks arrive: how many chunks
+-- were stored as new blocks and how many bytes that wrote to S3.
+ALTER TABLE upload_sessions
+    ADD COLUMN IF NOT EXISTS blocks_new     INT    NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS uploaded_bytes BIGINT NOT NULL DEFAULT 0;
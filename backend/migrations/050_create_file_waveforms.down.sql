@@ -0,0 +1,2 @@
+-- 050_create_file_waveforms.down.sql
+DROP TABLE IF EXISTS file_waveforms;
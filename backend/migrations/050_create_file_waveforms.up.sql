@@ -0,0 +1,20 @@
+-- 050_create_file_waveforms.up.sql
+-- Audio analysis per file: duration, bitrate and a waveform outline (peaks, 0-1) for
+-- players to draw and seek in. content_etag is the content version analysed, so an
+-- edit leaves the row stale. Rows are queued as 'pending' and filled by the
+-- analyze-audio task; updated_at of a 'running' row shows when it was claimed.
+CREATE TABLE IF NOT EXISTS file_waveforms (
+    file_id      BIGINT      PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    status       TEXT        NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'ready', 'failed')),
+    content_etag TEXT,
+    duration_ms  BIGINT,
+    bitrate      BIGINT,
+    sample_rate  INT,
+    channels     INT,
+    codec        TEXT,
+    peaks        DOUBLE PRECISION[],
+    error        TEXT,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_waveforms_queue ON file_waveforms(updated_at) WHERE status IN ('pending', 'running');
@@ -0,0 +1,2 @@
+-- 051_create_file_checksums.down.sql
+DROP TABLE IF EXISTS file_checksums;
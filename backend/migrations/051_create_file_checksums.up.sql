@@ -0,0 +1,14 @@
+-- 051_create_file_checksums.up.sql
+-- SHA-256 of each file's content and of its blocks' content, for documenting fixity.
+-- Block hashes in the blocks table are namespaced under per-user dedup, so they are
+-- not content checksums. content_hash is the file's block digest when the row was
+-- written (see fileDigestJoin): a row whose digest no longer matches is stale.
+-- verified_at is the last time the content was re-read from S3 and matched.
+CREATE TABLE IF NOT EXISTS file_checksums (
+    file_id      BIGINT      PRIMARY KEY REFERENCES files(id) ON DELETE CASCADE,
+    content_hash TEXT        NOT NULL,
+    sha256       TEXT        NOT NULL,
+    block_sha256 TEXT[]      NOT NULL,
+    computed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    verified_at  TIMESTAMPTZ
+);
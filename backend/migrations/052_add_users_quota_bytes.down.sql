@@ -0,0 +1,2 @@
+-- 052_add_users_quota_bytes.down.sql
+ALTER TABLE users DROP COLUMN IF EXISTS quota_bytes;
@@ -0,0 +1,4 @@
+-- 052_add_users_quota_bytes.up.sql
+-- A storage quota an admin set for one user, overriding their plan's quota_bytes.
+-- NULL = the plan's quota applies (or none, without a plan).
+ALTER TABLE users ADD COLUMN IF NOT EXISTS quota_bytes BIGINT CHECK (quota_bytes > 0);
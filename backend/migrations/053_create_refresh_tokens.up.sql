@@ -0,0 +1,22 @@
+-- 053_create_refresh_tokens.up.sql
+-- Refresh tokens, traded at POST /auth/refresh for a new JWT and a new refresh token.
+-- Only the SHA-256 of a token is stored. Each refresh rotates the token: the old row
+-- gets rotated_at and its successor joins the same family (family_id = the id of the
+-- token issued at login). A rotated token presented again means it leaked, so the
+-- whole family is revoked. Rows are kept until they expire to recognise such reuse.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          BIGSERIAL   PRIMARY KEY,
+    token_hash  BYTEA       NOT NULL UNIQUE,
+    family_id   BIGINT      NOT NULL,
+    user_id     BIGINT      NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ip_address  TEXT        NOT NULL DEFAULT '',
+    user_agent  TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at  TIMESTAMPTZ NOT NULL,
+    rotated_at  TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,4 @@
+-- 054_add_blocks_compression.down.sql
+-- Compressed blocks cannot be read without the marker; decompress them first.
+ALTER TABLE blocks DROP COLUMN IF EXISTS stored_bytes;
+ALTER TABLE blocks DROP COLUMN IF EXISTS compression;
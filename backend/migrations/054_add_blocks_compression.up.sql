@@ -0,0 +1,8 @@
+-- 054_add_blocks_compression.up.sql
+-- Blocks may be stored compressed (BLOCK_COMPRESSION). compression names the
+-- algorithm of the S3 object ('' = stored as is) and stored_bytes its size;
+-- size_bytes stays the size of the content.
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS compression TEXT NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS stored_bytes BIGINT;
+UPDATE blocks SET stored_bytes = size_bytes WHERE stored_bytes IS NULL;
+ALTER TABLE blocks ALTER COLUMN stored_bytes SET NOT NULL;
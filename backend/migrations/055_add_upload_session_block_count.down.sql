@@ -0,0 +1,2 @@
+-- 055_add_upload_session_block_count.down.sql
+ALTER TABLE upload_sessions DROP COLUMN IF EXISTS block_count;
@@ -0,0 +1,5 @@
+-- 055_add_upload_session_block_count.up.sql
+-- With content-defined chunking a resumable upload is split again when it is
+-- finalized, so its file has block_count blocks instead of one per chunk.
+-- NULL = the chunks became the file's blocks.
+ALTER TABLE upload_sessions ADD COLUMN IF NOT EXISTS block_count INT;
@@ -0,0 +1,26 @@
+// Package migrations embeds the SQL migrations, applied with golang-migrate, so the
+// binary knows which schema version it expects.
+package migrations
+
+import (
+	"embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.up.sql
+var files embed.FS
+
+// Latest returns the version of the newest migration, the schema version the code
+// expects the database to be at.
+func Latest() int64 {
+	entries, _ := files.ReadDir(".")
+	var latest int64
+	for _, e := range entries {
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		if v, err := strconv.ParseInt(prefix, 10, 64); err == nil && v > latest {
+			latest = v
+		}
+	}
+	return latest
+}
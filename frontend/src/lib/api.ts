@@ -1,18 +1,102 @@
 import axios from 'axios';
-import { PUBLIC_API_BASE_URL } from '$env/static/public';
-import type { User, TokenResponse, NaratelFile, UploadResponse, Folder, FolderContents, ShareLink } from './types';
+import { PUBLIC_API_BASE_URL, PUBLIC_COOKIE_SESSIONS } from '$env/static/public';
+import type { User, TokenResponse, TermsOfService, UserPreferences, PreferencesUpdate, NaratelFile, UploadResponse, TablePreview, Folder, FolderContents, FolderMetadataUpdate, QuickAccessItem, ShareLink, Job, UploadRule, UploadRuleInput, Notification, PushConfig, PushDevice, SnippetInput, SnippetResponse, UploadRequest, UploadRequestInput, DropMetadata, DropReceipt, AbuseReportInput } from './types';
+
+// Cookie sessions need credentialed requests, which browsers only allow when the API
+// names this origin in CORS_ALLOWED_ORIGINS
+const cookieSessions = PUBLIC_COOKIE_SESSIONS === 'true';
 
 export const api = axios.create({
-	baseURL: `${PUBLIC_API_BASE_URL}/api/v1`
+	baseURL: `${PUBLIC_API_BASE_URL}/api/v1`,
+	// Recorded as modified_client on the files this client changes
+	headers: { 'X-Client': 'naratel-web' },
+	withCredentials: cookieSessions
 });
 
+// Value of a cookie readable by scripts (the CSRF token; the session cookie is HttpOnly)
+function readCookie(name: string): string | null {
+	const match = document.cookie.split('; ').find((c) => c.startsWith(`${name}=`));
+	return match ? decodeURIComponent(match.slice(name.length + 1)) : null;
+}
+
 // Inject Bearer token from localStorage on every request
 api.interceptors.request.use((config) => {
+	// Error messages come back in the browser's language where the API has a translation
+	config.headers['Accept-Language'] = navigator.language;
 	const token = localStorage.getItem('token');
 	if (token) config.headers.Authorization = `Bearer ${token}`;
+	// Cookie sessions must echo the CSRF cookie on anything that changes state
+	const method = (config.method ?? 'get').toLowerCase();
+	const csrf = readCookie('nb_csrf');
+	if (csrf && !['get', 'head', 'options'].includes(method)) config.headers['X-CSRF-Token'] = csrf;
 	return config;
 });
 
+// Successful JSON responses arrive as { data, request_id, pagination? }; callers get
+// the data. Error bodies stay flat ({ error, message, request_id }).
+api.interceptors.response.use(
+	(res) => {
+		const body = res.data;
+		if (body && typeof body === 'object' && 'request_id' in body && 'data' in body) {
+			res.data = body.data;
+		}
+		return res;
+	},
+	// An expired bearer token is refreshed once and the request retried with the new one
+	async (error) => {
+		const config = error.config;
+		if (
+			error.response?.status !== 401 ||
+			!config ||
+			retried.has(config) ||
+			['/auth/login', '/auth/refresh'].includes(config.url)
+		) {
+			throw error;
+		}
+		// A refresh that finished after this request was sent has already done the work
+		const current = localStorage.getItem('token');
+		const stale = !!current && config.headers?.Authorization !== `Bearer ${current}`;
+		if (!stale && !(await refreshSession())) throw error;
+		retried.add(config);
+		return api(config);
+	}
+);
+
+const retried = new WeakSet<object>();
+
+// Keeps a bearer login's tokens; each refresh replaces both
+export function saveTokens(res: TokenResponse) {
+	if (res.token) localStorage.setItem('token', res.token);
+	if (res.refresh_token) localStorage.setItem('refresh_token', res.refresh_token);
+}
+
+// A refresh token works once: the server takes a second use as theft and signs the
+// login out everywhere. Requests failing together therefore share one refresh.
+let refreshing: Promise<boolean> | null = null;
+
+// Trades the stored refresh token for new tokens; false if there is none or it was refused
+export function refreshSession(): Promise<boolean> {
+	if (!refreshing) {
+		refreshing = trade().finally(() => {
+			refreshing = null;
+		});
+	}
+	return refreshing;
+}
+
+async function trade(): Promise<boolean> {
+	const refreshToken = localStorage.getItem('refresh_token');
+	if (!refreshToken) return false;
+	try {
+		const res = await api.post<TokenResponse>('/auth/refresh', { refresh_token: refreshToken });
+		saveTokens(res.data);
+		return true;
+	} catch {
+		localStorage.removeItem('refresh_token');
+		return false;
+	}
+}
+
 // ── Auth ──────────────────────────────────────────────────────────────────────
 
 export async function register(email: string, password: string): Promise<User> {
@@ -20,16 +104,51 @@ export async function register(email: string, password: string): Promise<User> {
 	return res.data;
 }
 
+// Sets the password of an account an admin imported, from its invite link
+export async function acceptInvite(token: string, password: string): Promise<User> {
+	const res = await api.post<User>(`/auth/invite/${encodeURIComponent(token)}`, { password });
+	return res.data;
+}
+
 export async function login(email: string, password: string): Promise<TokenResponse> {
 	const res = await api.post<TokenResponse>('/auth/login', { email, password });
 	return res.data;
 }
 
+// Ends a cookie session and revokes the refresh token; Bearer tokens are simply
+// forgotten by the caller
+export async function logout(): Promise<void> {
+	const refreshToken = localStorage.getItem('refresh_token');
+	await api.post('/auth/logout', refreshToken ? { refresh_token: refreshToken } : undefined);
+}
+
 export async function getMe(): Promise<User> {
 	const res = await api.get<User>('/auth/me');
 	return res.data;
 }
 
+export async function getTermsOfService(): Promise<TermsOfService> {
+	const res = await api.get<TermsOfService>('/tos');
+	return res.data;
+}
+
+// Files, folders and sharing answer 403 tos_required until the current version is accepted
+export async function acceptTermsOfService(version: string): Promise<User> {
+	const res = await api.post<User>('/auth/tos/accept', { version });
+	return res.data;
+}
+
+export async function getPreferences(): Promise<UserPreferences> {
+	const res = await api.get<UserPreferences>('/auth/me/preferences');
+	return res.data;
+}
+
+// Saves only the given preferences and returns all of them.
+export async function updatePreferences(changes: PreferencesUpdate): Promise<UserPreferences> {
+	const res = await api.patch<UserPreferences>('/auth/me/preferences', changes);
+	return res.data;
+}
+
 // ── Files ─────────────────────────────────────────────────────────────────────
 
 export async function listFiles(folderId?: number | null, search?: string): Promise<NaratelFile[]> {
@@ -61,6 +180,28 @@ export async function deleteFile(id: number): Promise<void> {
 	await api.delete(`/files/${id}`);
 }
 
+// Pass folderId to list only files trashed from that folder.
+export async function listTrash(folderId?: number): Promise<NaratelFile[]> {
+	const res = await api.get<NaratelFile[]>('/trash', {
+		params: folderId !== undefined ? { folder_id: folderId } : undefined
+	});
+	return res.data;
+}
+
+export async function restoreFile(id: number): Promise<NaratelFile> {
+	const res = await api.post<NaratelFile>(`/trash/${id}/restore`);
+	return res.data;
+}
+
+export async function purgeFile(id: number): Promise<void> {
+	await api.delete(`/trash/${id}`);
+}
+
+export async function emptyTrash(): Promise<{ files_purged: number; blocks_released: number }> {
+	const res = await api.post<{ files_purged: number; blocks_released: number }>('/trash/empty');
+	return res.data;
+}
+
 export async function renameFile(id: number, name: string): Promise<NaratelFile> {
 	const res = await api.patch<NaratelFile>(`/files/${id}/rename`, { name });
 	return res.data;
@@ -71,6 +212,12 @@ export async function moveFile(id: number, folderId: number | null): Promise<Nar
 	return res.data;
 }
 
+// expiresAt: RFC 3339 date in the future, or null to never expire
+export async function setFileExpiry(id: number, expiresAt: string | null): Promise<NaratelFile> {
+	const res = await api.put<NaratelFile>(`/files/${id}/expiry`, { expires_at: expiresAt });
+	return res.data;
+}
+
 export async function getFileInfo(id: number): Promise<NaratelFile> {
 	const res = await api.get<NaratelFile>(`/files/${id}/info`);
 	return res.data;
@@ -81,6 +228,33 @@ export function downloadUrl(id: number): string {
 	return `${PUBLIC_API_BASE_URL}/api/v1/files/${id}?token=${token}`;
 }
 
+// Multi-file downloads are zipped server-side by a job; poll until completed, then fetch.
+export async function createDownload(fileIds: number[]): Promise<Job> {
+	const res = await api.post<Job>('/downloads', { file_ids: fileIds });
+	return res.data;
+}
+
+export async function getDownload(id: number): Promise<Job> {
+	const res = await api.get<Job>(`/downloads/${id}`);
+	return res.data;
+}
+
+export async function saveDownload(id: number): Promise<void> {
+	const token = localStorage.getItem('token');
+	const res = await fetch(`${PUBLIC_API_BASE_URL}/api/v1/downloads/${id}/content`, {
+		headers: token ? { Authorization: `Bearer ${token}` } : {},
+		credentials: cookieSessions ? 'include' : 'same-origin'
+	});
+	if (!res.ok) throw new Error('Download failed');
+	const blob = await res.blob();
+	const url = URL.createObjectURL(blob);
+	const a = document.createElement('a');
+	a.href = url;
+	a.download = `download-${id}.zip`;
+	a.click();
+	URL.revokeObjectURL(url);
+}
+
 export function previewUrl(id: number): string {
 	return `${PUBLIC_API_BASE_URL}/api/v1/files/${id}?preview=true`;
 }
@@ -88,7 +262,8 @@ export function previewUrl(id: number): string {
 export async function downloadFile(id: number, name: string): Promise<void> {
 	const token = localStorage.getItem('token');
 	const res = await fetch(`${PUBLIC_API_BASE_URL}/api/v1/files/${id}`, {
-		headers: { Authorization: `Bearer ${token}` }
+		headers: token ? { Authorization: `Bearer ${token}` } : {},
+		credentials: cookieSessions ? 'include' : 'same-origin'
 	});
 	if (!res.ok) throw new Error('Download failed');
 	const blob = await res.blob();
@@ -100,14 +275,79 @@ export async function downloadFile(id: number, name: string): Promise<void> {
 	URL.revokeObjectURL(url);
 }
 
-export async function getFilePreviewBlob(id: number): Promise<{ blob: Blob; mimeType: string }> {
+// truncated is set when the API cut a large text preview short (X-Preview-Truncated)
+export async function getFilePreviewBlob(
+	id: number
+): Promise<{ blob: Blob; mimeType: string; truncated: boolean }> {
 	const token = localStorage.getItem('token');
 	const res = await fetch(`${PUBLIC_API_BASE_URL}/api/v1/files/${id}?preview=true`, {
-		headers: { Authorization: `Bearer ${token}` }
+		headers: token ? { Authorization: `Bearer ${token}` } : {},
+		credentials: cookieSessions ? 'include' : 'same-origin'
 	});
 	if (!res.ok) throw new Error('Preview failed');
 	const blob = await res.blob();
-	return { blob, mimeType: blob.type };
+	return { blob, mimeType: blob.type, truncated: res.headers.get('X-Preview-Truncated') === 'true' };
+}
+
+// Parses the start of a CSV, TSV or XLSX file on the server; rows is 1-1000
+export async function getFileTable(id: number, rows = 100): Promise<TablePreview> {
+	const res = await api.get<TablePreview>(`/files/${id}/table`, { params: { rows } });
+	return res.data;
+}
+
+// ── Upload rules ──────────────────────────────────────────────────────────────
+
+export async function listUploadRules(): Promise<UploadRule[]> {
+	const res = await api.get<UploadRule[]>('/upload-rules');
+	return res.data;
+}
+
+export async function createUploadRule(rule: UploadRuleInput): Promise<UploadRule> {
+	const res = await api.post<UploadRule>('/upload-rules', rule);
+	return res.data;
+}
+
+export async function updateUploadRule(id: number, rule: UploadRuleInput): Promise<UploadRule> {
+	const res = await api.patch<UploadRule>(`/upload-rules/${id}`, rule);
+	return res.data;
+}
+
+export async function deleteUploadRule(id: number): Promise<void> {
+	await api.delete(`/upload-rules/${id}`);
+}
+
+// ── File-drop links ───────────────────────────────────────────────────────────
+
+export async function listUploadRequests(): Promise<UploadRequest[]> {
+	const res = await api.get<UploadRequest[]>('/upload-requests');
+	return res.data;
+}
+
+export async function createUploadRequest(input: UploadRequestInput): Promise<UploadRequest> {
+	const res = await api.post<UploadRequest>('/upload-requests', input);
+	return res.data;
+}
+
+export async function setUploadRequestEnabled(id: number, enabled: boolean): Promise<UploadRequest> {
+	const res = await api.patch<UploadRequest>(`/upload-requests/${id}`, { enabled });
+	return res.data;
+}
+
+export async function deleteUploadRequest(id: number): Promise<void> {
+	await api.delete(`/upload-requests/${id}`);
+}
+
+// Public: no login needed
+export async function getDropMetadata(token: string): Promise<DropMetadata> {
+	const res = await api.get<DropMetadata>(`/drop/${token}`);
+	return res.data;
+}
+
+export async function submitDrop(token: string, file: File): Promise<DropReceipt> {
+	const form = new FormData();
+	form.append('file', file);
+	const res = await api.post<DropReceipt>(`/drop/${token}`, form);
+	return res.data;
 }
 
 // ── Folders ───────────────────────────────────────────────────────────────────
@@ -119,9 +359,16 @@ export async function createFolder(name: string, parentId?: number | null): Prom
 	return res.data;
 }
 
-export async function listFolderContents(folderId?: number | null): Promise<FolderContents> {
+// With limit, files are paged; pass the returned next_cursor as cursor to load more.
+export async function listFolderContents(
+	folderId?: number | null,
+	page?: { sort?: 'name' | 'natural' | 'created'; limit?: number; cursor?: string }
+): Promise<FolderContents> {
 	const params: Record<string, string> = {};
 	if (folderId != null) params.folder_id = String(folderId);
+	if (page?.sort) params.sort = page.sort;
+	if (page?.limit) params.limit = String(page.limit);
+	if (page?.cursor) params.cursor = page.cursor;
 	const res = await api.get<FolderContents>('/folders/contents', { params });
 	return res.data;
 }
@@ -141,13 +388,59 @@ export async function renameFolder(id: number, name: string): Promise<Folder> {
 	return res.data;
 }
 
+// Omitted fields stay unchanged; pass '' to clear one.
+export async function updateFolder(id: number, update: FolderMetadataUpdate): Promise<Folder> {
+	const res = await api.patch<Folder>(`/folders/${id}`, update);
+	return res.data;
+}
+
+export async function setFolderExpiry(id: number, expiresAt: string | null): Promise<Folder> {
+	const res = await api.put<Folder>(`/folders/${id}/expiry`, { expires_at: expiresAt });
+	return res.data;
+}
+
+export async function listQuickAccess(): Promise<QuickAccessItem[]> {
+	const res = await api.get<QuickAccessItem[]>('/quick-access');
+	return res.data;
+}
+
+export async function pinFolder(id: number): Promise<QuickAccessItem[]> {
+	const res = await api.put<QuickAccessItem[]>(`/quick-access/${id}`);
+	return res.data;
+}
+
+export async function unpinFolder(id: number): Promise<void> {
+	await api.delete(`/quick-access/${id}`);
+}
+
+// folderIds must list every pinned folder exactly once.
+export async function reorderQuickAccess(folderIds: number[]): Promise<QuickAccessItem[]> {
+	const res = await api.put<QuickAccessItem[]>('/quick-access/order', { folder_ids: folderIds });
+	return res.data;
+}
+
 export async function moveFolder(id: number, parentId: number | null): Promise<Folder> {
 	const res = await api.patch<Folder>(`/folders/${id}/move`, { parent_id: parentId });
 	return res.data;
 }
 
-export async function deleteFolder(id: number): Promise<void> {
-	await api.delete(`/folders/${id}`);
+// Folder deletes run as a background job; poll getJob for progress.
+export async function deleteFolder(id: number): Promise<Job> {
+	const res = await api.delete<Job>(`/folders/${id}`);
+	return res.data;
+}
+
+export async function getJob(id: number): Promise<Job> {
+	const res = await api.get<Job>(`/jobs/${id}`);
+	return res.data;
+}
+
+// Newest first; pass the last ID of a page as beforeId to fetch the next one.
+export async function listJobs(params: { kind?: string; status?: Job['status']; beforeId?: number; limit?: number } = {}): Promise<Job[]> {
+	const res = await api.get<Job[]>('/jobs', {
+		params: { kind: params.kind, status: params.status, before_id: params.beforeId, limit: params.limit }
+	});
+	return res.data;
 }
 
 // ── Share Links ───────────────────────────────────────────────────────────────
@@ -157,6 +450,12 @@ export async function createShareLink(fileId: number): Promise<ShareLink> {
 	return res.data;
 }
 
+// Stores pasted text as a file and returns it with a ready-to-send share link
+export async function createSnippet(snippet: SnippetInput): Promise<SnippetResponse> {
+	const res = await api.post<SnippetResponse>('/snippets', snippet);
+	return res.data;
+}
+
 export async function getShareLinks(fileId: number): Promise<ShareLink[]> {
 	const res = await api.get<ShareLink[]>(`/files/${fileId}/share`);
 	return res.data;
@@ -173,3 +472,48 @@ export function shareDownloadUrl(token: string): string {
 export function sharePreviewUrl(token: string): string {
 	return `${PUBLIC_API_BASE_URL}/api/v1/share/${token}?preview=true`;
 }
+
+// Public: reports the content behind a share link to the admins (rate-limited per IP)
+export async function reportShareLink(token: string, report: AbuseReportInput): Promise<{ id: number; status: string }> {
+	const res = await api.post<{ id: number; status: string }>(`/share/${token}/report`, report);
+	return res.data;
+}
+
+// ── Notifications ─────────────────────────────────────────────────────────────
+
+export async function listNotifications(unreadOnly = false): Promise<Notification[]> {
+	const res = await api.get<Notification[]>('/notifications', { params: unreadOnly ? { unread: true } : {} });
+	return res.data;
+}
+
+export async function markNotificationRead(id: number): Promise<void> {
+	await api.post(`/notifications/${id}/read`);
+}
+
+export async function markAllNotificationsRead(): Promise<{ marked: number }> {
+	const res = await api.post<{ marked: number }>('/notifications/read-all');
+	return res.data;
+}
+
+// ── Push ──────────────────────────────────────────────────────────────────────
+
+export async function getPushConfig(): Promise<PushConfig> {
+	const res = await api.get<PushConfig>('/push/config');
+	return res.data;
+}
+
+export async function listPushDevices(): Promise<PushDevice[]> {
+	const res = await api.get<PushDevice[]>('/push/devices');
+	return res.data;
+}
+
+// Registers this browser's PushManager subscription for Web Push.
+export async function registerWebPush(subscription: PushSubscription): Promise<PushDevice> {
+	const { endpoint, keys } = subscription.toJSON();
+	const res = await api.post<PushDevice>('/push/devices', { platform: 'webpush', token: endpoint, keys });
+	return res.data;
+}
+
+export async function deletePushDevice(id: number): Promise<void> {
+	await api.delete(`/push/devices/${id}`);
+}
@@ -1,9 +1,18 @@
 import { goto } from '$app/navigation';
 import { browser } from '$app/environment';
-import { login as apiLogin, register as apiRegister, getMe } from './api';
+import {
+	acceptInvite as apiAcceptInvite,
+	login as apiLogin,
+	logout as apiLogout,
+	register as apiRegister,
+	getMe,
+	saveTokens
+} from './api';
 import type { User } from './types';
 
-// Svelte 5 rune-based reactive store
+// Svelte 5 rune-based reactive store. In cookie session mode there is no token; the
+// 'session' marker only remembers that a login happened, the cookie itself is HttpOnly.
+const SESSION_MARKER = 'session';
 let token = $state<string | null>(null);
 let user = $state<User | null>(null);
 let loading = $state(false);
@@ -11,7 +20,7 @@ let initialized = $state(false);
 
 // Hydrate from localStorage on module load (client-side only)
 if (typeof localStorage !== 'undefined') {
-	token = localStorage.getItem('token');
+	token = localStorage.getItem('token') ?? localStorage.getItem(SESSION_MARKER);
 }
 
 export const auth = {
@@ -35,6 +44,8 @@ export const auth = {
 			token = null;
 			user = null;
 			localStorage.removeItem('token');
+			localStorage.removeItem('refresh_token');
+			localStorage.removeItem(SESSION_MARKER);
 		} finally {
 			initialized = true;
 		}
@@ -44,8 +55,13 @@ export const auth = {
 		loading = true;
 		try {
 			const res = await apiLogin(email, password);
-			token = res.token;
-			localStorage.setItem('token', res.token);
+			if (res.token) {
+				token = res.token;
+				saveTokens(res);
+			} else {
+				token = SESSION_MARKER;
+				localStorage.setItem(SESSION_MARKER, SESSION_MARKER);
+			}
 			user = await getMe();
 			goto('/dashboard');
 		} finally {
@@ -64,10 +80,24 @@ export const auth = {
 		}
 	},
 
-	logout() {
+	async acceptInvite(inviteToken: string, password: string) {
+		loading = true;
+		try {
+			const invited = await apiAcceptInvite(inviteToken, password);
+			await auth.login(invited.email, password);
+		} finally {
+			loading = false;
+		}
+	},
+
+	async logout() {
+		// Best effort: the local state is cleared even if the server is unreachable
+		await apiLogout().catch(() => {});
 		token = null;
 		user = null;
 		localStorage.removeItem('token');
+		localStorage.removeItem('refresh_token');
+		localStorage.removeItem(SESSION_MARKER);
 		goto('/login');
 	}
 };
@@ -1,12 +1,27 @@
 export interface User {
 	user_id: number;
 	email: string;
+	role: 'user' | 'admin';
 	created_at: string;
+	tos_version: string | null; // latest terms of service version accepted
+	tos_accepted_at: string | null;
+	deactivated_at?: string; // set while an admin has deactivated the account
+	used_bytes: number; // total size of your files, trash included
 }
 
+// Current terms of service; an empty version means none need accepting
+export interface TermsOfService {
+	version: string;
+	url: string;
+}
+
+// token is absent when the API runs in cookie session mode
 export interface TokenResponse {
-	token: string;
+	token?: string;
 	expires_at: string;
+	// Only with bearer tokens, when the server issues refresh tokens
+	refresh_token?: string;
+	refresh_expires_at?: string;
 }
 
 export interface NaratelFile {
@@ -16,8 +31,16 @@ export interface NaratelFile {
 	name: string;
 	mime_type: string;
 	total_size: number;
+	block_size: number;
 	created_at: string;
 	updated_at: string;
+	deleted_at?: string;
+	trashed_from_folder_id?: number | null;
+	modified_by: number | null;
+	modified_client?: string | null;
+	expires_at: string | null;
+	taken_down_at?: string; // set by an admin after an abuse report; can no longer be shared
+	scan_status?: 'pending' | 'clean' | 'infected'; // malware scan verdict; absent = not scanned
 }
 
 export interface Folder {
@@ -25,13 +48,42 @@ export interface Folder {
 	user_id: number;
 	parent_id: number | null;
 	name: string;
+	color: string | null;
+	icon: string | null;
+	description: string | null;
+	expires_at: string | null;
 	created_at: string;
 	updated_at: string;
 }
 
+export interface FolderMetadataUpdate {
+	color?: string;
+	icon?: string;
+	description?: string;
+}
+
+export interface QuickAccessItem {
+	position: number;
+	pinned_at: string;
+	folder: Folder;
+}
+
+export interface FolderEntry extends Folder {
+	item_count: number;
+	size_bytes: number;
+}
+
+export interface FileEntry extends NaratelFile {
+	shared: boolean;
+	etag: string; // matches the download ETag
+	content_hash: string; // SHA-256 over the block hashes in order
+	block_count: number;
+}
+
 export interface FolderContents {
-	folders: Folder[];
-	files: NaratelFile[];
+	folders: FolderEntry[];
+	files: FileEntry[];
+	next_cursor?: string;
 }
 
 export interface ShareLink {
@@ -39,16 +91,189 @@ export interface ShareLink {
 	file_id: number;
 	token: string;
 	url: string;
+	download_url: string;
+	enabled: boolean;
+	taken_down: boolean; // disabled by an admin after an abuse report; cannot be re-enabled
+	password_protected: boolean;
+	expired: boolean;
+	file_name?: string;
 	expires_at: string | null;
 	created_at: string;
 }
 
+export interface SnippetInput {
+	content: string;
+	language?: string; // syntax hint, e.g. 'go', 'python'; default 'text'
+	name?: string;
+	folder_id?: number | null;
+	password?: string;
+	expires_in_hours?: number;
+}
+
+export interface SnippetResponse {
+	file: NaratelFile;
+	language: string;
+	share_link: ShareLink;
+}
+
+export interface Job {
+	id: number;
+	kind: string;
+	status: 'pending' | 'running' | 'completed' | 'failed';
+	progress: number;
+	error?: string;
+	created_at: string;
+	started_at?: string;
+	finished_at?: string;
+}
+
 export interface UploadResponse {
 	file_id: number;
 	name: string;
 	mime_type: string;
 	size: number;
 	created_at: string;
+	folder_id: number | null;
+	rule_id: number | null;
+}
+
+// First rows of a CSV, TSV or XLSX file, from GET /files/{id}/table
+export interface TablePreview {
+	format: 'csv' | 'tsv' | 'xlsx';
+	columns: { name: string; type: 'integer' | 'number' | 'boolean' | 'date' | 'string' | 'empty' }[];
+	rows: string[][];
+	truncated: boolean;
+}
+
+export interface UploadRule {
+	id: number;
+	user_id: number;
+	name: string;
+	match_type: 'extension' | 'mime' | 'name';
+	pattern: string;
+	target_folder_id: number;
+	position: number;
+	enabled: boolean;
+	created_at: string;
+	updated_at: string;
+}
+
+export type UploadRuleInput = Partial<
+	Pick<UploadRule, 'name' | 'match_type' | 'pattern' | 'target_folder_id' | 'position' | 'enabled'>
+>;
+
+export interface UploadRequest {
+	id: number;
+	user_id: number;
+	folder_id: number;
+	token: string;
+	title: string;
+	instructions: string;
+	accepted_types: string[]; // extensions and/or MIME types; empty = any
+	max_size_bytes: number | null;
+	expires_at: string | null;
+	enabled: boolean;
+	upload_count: number;
+	created_at: string;
+	upload_url: string;
+}
+
+export interface UploadRequestInput {
+	folder_id: number;
+	title: string;
+	instructions?: string;
+	accepted_types?: string[];
+	max_size_bytes?: number;
+	expires_in_hours?: number;
+}
+
+export type AbuseReason = 'copyright' | 'malware' | 'phishing' | 'illegal' | 'harassment' | 'spam' | 'other';
+
+// Filed by anyone holding a share link; reviewed by admins
+export interface AbuseReportInput {
+	reason: AbuseReason;
+	details?: string;
+	email?: string; // optional contact for follow-up
+}
+
+// What the public upload page of a file-drop link shows
+export interface DropMetadata {
+	title: string;
+	instructions: string;
+	accepted_types: string[];
+	max_size_bytes: number;
+	expires_at: string | null;
+}
+
+export interface DropReceipt {
+	name: string;
+	size: number;
+	received_at: string;
+}
+
+export interface FileDropNotice {
+	request_id: number;
+	title: string;
+	file_id: number;
+	name: string;
+	size: number;
+}
+
+export interface TakedownNotice {
+	action: 'link_disabled' | 'file_disabled';
+	file_id: number;
+	file_name: string;
+	reason: AbuseReason;
+}
+
+export interface ExpiryNotice {
+	item_type: 'file' | 'folder';
+	item_id: number;
+	name: string;
+	expires_at: string;
+}
+
+export interface Notification {
+	id: number;
+	user_id: number;
+	kind: 'expiry_warning' | 'expired' | 'file_drop' | 'takedown';
+	data: ExpiryNotice | FileDropNotice | TakedownNotice;
+	read_at: string | null;
+	created_at: string;
+}
+
+export interface UserPreferences {
+	default_sort: 'name' | 'natural' | 'created';
+	// UI language; empty follows the browser
+	language: string;
+	// Pre-filled expiry for new share links; 0 = server default
+	default_share_expiry_hours: number;
+	notifications: {
+		push: boolean;
+		email: boolean;
+		muted_kinds: Notification['kind'][];
+	};
+}
+
+export type PreferencesUpdate = Partial<Omit<UserPreferences, 'notifications'>> & {
+	notifications?: Partial<UserPreferences['notifications']>;
+};
+
+export type PushPlatform = 'fcm' | 'webpush';
+
+export interface PushConfig {
+	platforms: PushPlatform[];
+	// applicationServerKey for PushManager.subscribe; absent when Web Push is off
+	webpush_public_key?: string;
+}
+
+export interface PushDevice {
+	id: number;
+	user_id: number;
+	platform: PushPlatform;
+	user_agent: string;
+	created_at: string;
+	last_used_at: string | null;
 }
 
 export interface ApiError {